@@ -65,6 +65,17 @@ var (
 		},
 	}
 
+	pluginLintCommand = &cobra.Command{
+		Use:   "lint [plugin_path]",
+		Short: "Lint",
+		Long:  "Check the declaration of the plugin for problems beyond the schema, like missing translations or broad permissions, you need specify the plugin path or .difypkg file path",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pluginPath := args[0]
+			plugin.LintPlugin(pluginPath)
+		},
+	}
+
 	pluginModuleCommand = &cobra.Command{
 		Use:   "module",
 		Short: "Module",
@@ -160,6 +171,7 @@ func init() {
 	pluginCommand.AddCommand(pluginInitCommand)
 	pluginCommand.AddCommand(pluginPackageCommand)
 	pluginCommand.AddCommand(pluginChecksumCommand)
+	pluginCommand.AddCommand(pluginLintCommand)
 	pluginCommand.AddCommand(pluginEditPermissionCommand)
 	pluginCommand.AddCommand(pluginModuleCommand)
 	pluginModuleCommand.AddCommand(pluginModuleListCommand)
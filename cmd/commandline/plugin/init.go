@@ -30,7 +30,7 @@ func InitPlugin() {
 				m.createPlugin()
 			}
 		} else {
-			log.Error("Error running program:", err)
+			log.Error("Error running program: %s", err)
 			return
 		}
 	}
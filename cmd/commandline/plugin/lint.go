@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"os"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+func LintPlugin(pluginPath string) {
+	var pluginDecoder decoder.PluginDecoder
+	if stat, err := os.Stat(pluginPath); err == nil {
+		if stat.IsDir() {
+			pluginDecoder, err = decoder.NewFSPluginDecoder(pluginPath)
+			if err != nil {
+				log.Error("failed to create plugin decoder, plugin path: %s, error: %v", pluginPath, err)
+				os.Exit(1)
+				return
+			}
+		} else {
+			bytes, err := os.ReadFile(pluginPath)
+			if err != nil {
+				log.Error("failed to read plugin file, plugin path: %s, error: %v", pluginPath, err)
+				os.Exit(1)
+				return
+			}
+
+			pluginDecoder, err = decoder.NewZipPluginDecoder(bytes)
+			if err != nil {
+				log.Error("failed to create plugin decoder, plugin path: %s, error: %v", pluginPath, err)
+				os.Exit(1)
+				return
+			}
+		}
+	} else {
+		log.Error("failed to get plugin file info, plugin path: %s, error: %v", pluginPath, err)
+		os.Exit(1)
+		return
+	}
+
+	declaration, err := pluginDecoder.Manifest()
+	if err != nil {
+		log.Error("failed to get manifest, plugin path: %s, error: %v", pluginPath, err)
+		os.Exit(1)
+		return
+	}
+
+	warnings := plugin_entities.ValidateDeclaration(&declaration)
+	if len(warnings) == 0 {
+		log.Info("no problems found in the declaration")
+		return
+	}
+	logDeclarationWarnings(warnings)
+}
+
+func logDeclarationWarnings(warnings []plugin_entities.DeclarationWarning) {
+	for _, warning := range warnings {
+		log.Warn("%s: %s (%s)", warning.Path, warning.Message, warning.Rule)
+	}
+}
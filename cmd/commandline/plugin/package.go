@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
 )
@@ -20,6 +21,10 @@ func PackagePlugin(inputPath string, outputPath string) {
 		return
 	}
 
+	if declaration, err := decoder.Manifest(); err == nil {
+		logDeclarationWarnings(plugin_entities.ValidateDeclaration(&declaration))
+	}
+
 	packager := packager.NewPackager(decoder)
 	zipFile, err := packager.Pack(MaxPluginPackageSize)
 
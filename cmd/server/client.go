@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/mcp"
+	"github.com/langgenius/dify-plugin-daemon/internal/manifest"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+var (
+	apiURL string
+	apiKey string
+)
+
+func init() {
+	rootCommand.PersistentFlags().StringVar(&apiURL, "api", "", "url of the daemon, http://127.0.0.1:$SERVER_PORT by default")
+	rootCommand.PersistentFlags().StringVar(&apiKey, "key", "", "server key or api token, $SERVER_KEY by default")
+}
+
+func daemonURL() string {
+	if apiURL != "" {
+		return strings.TrimSuffix(apiURL, "/")
+	}
+	port := os.Getenv("SERVER_PORT")
+	if port == "" {
+		port = "5002"
+	}
+	return "http://127.0.0.1:" + port
+}
+
+func daemonKey() string {
+	if apiKey != "" {
+		return apiKey
+	}
+	return os.Getenv("SERVER_KEY")
+}
+
+// send requests the api of the running daemon as the caller configured
+func send(method string, path string, query url.Values, body any) (*http.Response, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := daemonURL() + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	request, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	// the key wins over operators signed in once both are sent
+	if key := daemonKey(); key != "" {
+		request.Header.Set("X-Api-Key", key)
+	}
+	if authorization := operatorAuthorization(); authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	return client.Do(request)
+}
+
+// call requests the api of the running daemon and returns the data of the response
+func call(method string, path string, query url.Values, body any) (json.RawMessage, error) {
+	response, err := send(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	envelope := entities.GenericResponse[json.RawMessage]{}
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("unexpected response with status %d", response.StatusCode)
+	}
+	if envelope.Code != 0 {
+		return nil, errors.New(envelope.Message)
+	}
+	return envelope.Data, nil
+}
+
+// printCall requests the api and prints the data of the response
+func printCall(method string, path string, query url.Values, body any) error {
+	data, err := call(method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	output := bytes.Buffer{}
+	if err := json.Indent(&output, data, "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(output.String())
+	return nil
+}
+
+// download requests the api and writes the response to the file, the name of the file defaults to the one
+// given by the daemon
+func download(path string, output string) error {
+	response, err := send(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// errors are responded in json
+	if response.StatusCode != http.StatusOK || strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+		envelope := entities.GenericResponse[json.RawMessage]{}
+		if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil || envelope.Code == 0 {
+			return fmt.Errorf("unexpected response with status %d", response.StatusCode)
+		}
+		return errors.New(envelope.Message)
+	}
+
+	if output == "" {
+		_, params, err := mime.ParseMediaType(response.Header.Get("Content-Disposition"))
+		if err != nil || params["filename"] == "" {
+			return errors.New("no file name given by the daemon, set one by --output")
+		}
+		output = filepath.Base(params["filename"])
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, response.Body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Println(output)
+	return nil
+}
+
+// serveMCP serves mcp clients over stdio, tools are listed and invoked by the api of the running daemon
+func serveMCP(ctx context.Context) error {
+	credentials := map[string]map[string]any{}
+	if mcpCredentials != "" {
+		data, err := os.ReadFile(mcpCredentials)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &credentials); err != nil {
+			return fmt.Errorf("invalid credentials file: %w", err)
+		}
+	}
+
+	tools := mcp.NewPluginTools(mcp.PluginToolsOptions{
+		Transport:   http.DefaultTransport,
+		BaseURL:     daemonURL(),
+		Header:      http.Header{"X-Api-Key": {daemonKey()}},
+		TenantID:    tenantID,
+		UserID:      mcpUserID,
+		Credentials: credentials,
+	})
+	return mcp.ServeStdio(ctx, mcp.NewServer(tools, manifest.VersionX), os.Stdin, os.Stdout)
+}
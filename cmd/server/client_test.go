@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+func TestCallReturnsDataOfEnvelope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" {
+			json.NewEncoder(w).Encode(entities.NewDaemonErrorResponse(-401, "unauthorized"))
+			return
+		}
+		json.NewEncoder(w).Encode(entities.NewSuccessResponse(r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	apiURL = server.URL + "/"
+	apiKey = "key"
+	tenantID = "tenant/1"
+	page, pageSize = 2, 10
+
+	data, err := call(http.MethodGet, tenantPath("/management/list"), pageQuery(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := json.Marshal("/plugin/tenant/1/management/list?" + url.Values{
+		"page": {"2"}, "page_size": {"10"},
+	}.Encode())
+	if string(data) != string(expected) {
+		t.Fatalf("unexpected data %s", data)
+	}
+
+	apiKey = "wrong"
+	if _, err := call(http.MethodGet, "/cluster/nodes", nil, nil); err == nil || err.Error() != "unauthorized" {
+		t.Fatalf("error of the envelope should be returned, got %v", err)
+	}
+}
+
+func TestDownloadWritesFileNamedByDaemon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(entities.NewDaemonErrorResponse(-401, "unauthorized"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="../bundle.zip"`)
+		w.Write([]byte("archive"))
+	}))
+	defer server.Close()
+
+	apiURL = server.URL
+	apiKey = "key"
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	if err := download("/admin/support-bundle", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("bundle.zip")
+	if err != nil || string(data) != "archive" {
+		t.Fatalf("the bundle should be written to the file named by the daemon, got %s, %v", data, err)
+	}
+
+	apiKey = "wrong"
+	if err := download("/admin/support-bundle", "other.zip"); err == nil || err.Error() != "unauthorized" {
+		t.Fatalf("error of the envelope should be returned, got %v", err)
+	}
+	if _, err := os.Stat("other.zip"); !os.IsNotExist(err) {
+		t.Fatal("no file should be written on errors")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tenantID string
+	page     int
+	pageSize int
+	source   string
+
+	mcpUserID      string
+	mcpCredentials string
+
+	supportBundleOutput string
+
+	pluginCommand = &cobra.Command{
+		Use:   "plugin",
+		Short: "Plugin",
+		Long:  "Manage plugins of a tenant on the running daemon",
+	}
+
+	pluginListCommand = &cobra.Command{
+		Use:   "list",
+		Short: "List installed plugins",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodGet, tenantPath("/management/list"), pageQuery(), nil)
+		},
+	}
+
+	pluginInstallCommand = &cobra.Command{
+		Use:   "install [plugin_unique_identifier...]",
+		Short: "Install plugins",
+		Long:  "Install plugins by unique identifiers, packages of the plugins should have been uploaded or be available on the marketplace",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			metas := make([]map[string]any, len(args))
+			for i := range metas {
+				metas[i] = map[string]any{}
+			}
+			return printCall(http.MethodPost, tenantPath("/management/install/identifiers"), nil, map[string]any{
+				"plugin_unique_identifiers": args,
+				"source":                    source,
+				"metas":                     metas,
+			})
+		},
+	}
+
+	pluginRemoveCommand = &cobra.Command{
+		Use:   "remove [plugin_installation_id]",
+		Short: "Uninstall a plugin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodPost, tenantPath("/management/uninstall"), nil, map[string]any{
+				"plugin_installation_id": args[0],
+			})
+		},
+	}
+
+	endpointCommand = &cobra.Command{
+		Use:   "endpoint",
+		Short: "Endpoint",
+		Long:  "Manage endpoints of a tenant on the running daemon",
+	}
+
+	endpointListCommand = &cobra.Command{
+		Use:   "list",
+		Short: "List endpoints",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodGet, tenantPath("/endpoint/list"), pageQuery(), nil)
+		},
+	}
+
+	endpointEnableCommand = &cobra.Command{
+		Use:   "enable [endpoint_id]",
+		Short: "Enable an endpoint",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodPost, tenantPath("/endpoint/enable"), nil, map[string]any{
+				"endpoint_id": args[0],
+			})
+		},
+	}
+
+	endpointDisableCommand = &cobra.Command{
+		Use:   "disable [endpoint_id]",
+		Short: "Disable an endpoint",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodPost, tenantPath("/endpoint/disable"), nil, map[string]any{
+				"endpoint_id": args[0],
+			})
+		},
+	}
+
+	sessionCommand = &cobra.Command{
+		Use:   "session",
+		Short: "Session",
+		Long:  "Manage sessions served by the daemon, sessions are served by the node the request is sent to",
+	}
+
+	sessionListCommand = &cobra.Command{
+		Use:   "list",
+		Short: "List sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodGet, "/admin/sessions", nil, nil)
+		},
+	}
+
+	sessionKillCommand = &cobra.Command{
+		Use:   "kill [session_id]",
+		Short: "Kill a session",
+		Long:  "Kill a session, the invocation it serves is stopped and replied with an error",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodPost, "/admin/sessions/kill", nil, map[string]any{
+				"session_id": args[0],
+			})
+		},
+	}
+
+	clusterCommand = &cobra.Command{
+		Use:   "cluster",
+		Short: "Cluster",
+		Long:  "Inspect the cluster of the running daemon",
+	}
+
+	clusterNodesCommand = &cobra.Command{
+		Use:   "nodes",
+		Short: "List nodes of the cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodGet, "/cluster/nodes", nil, nil)
+		},
+	}
+
+	supportBundleCommand = &cobra.Command{
+		Use:   "support-bundle",
+		Short: "Download a support bundle",
+		Long: "Download a redacted archive of the configuration, versions, plugins, recent logs, goroutines " +
+			"and metrics of the node serving the request, to attach to bug reports",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return download("/admin/support-bundle", supportBundleOutput)
+		},
+	}
+
+	mcpCommand = &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve tools to mcp clients over stdio",
+		Long: "Serve tools of installed plugins of a tenant to mcp clients launching the command, " +
+			"messages are exchanged over stdin and stdout and tools are invoked on the running daemon",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serveMCP(cmd.Context())
+		},
+	}
+
+	migrateCommand = &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate the database",
+		Long:  "Migrate the database to the schema of this version offline, the daemon doesn't need to be running",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.Init(loadConfig())
+			db.Close()
+			fmt.Println("database migrated")
+			return nil
+		},
+	}
+)
+
+func tenantPath(path string) string {
+	return "/plugin/" + url.PathEscape(tenantID) + path
+}
+
+func pageQuery() url.Values {
+	return url.Values{
+		"page":      {strconv.Itoa(page)},
+		"page_size": {strconv.Itoa(pageSize)},
+	}
+}
+
+func init() {
+	for _, command := range []*cobra.Command{pluginCommand, endpointCommand, mcpCommand} {
+		command.PersistentFlags().StringVar(&tenantID, "tenant", "", "tenant id")
+		command.MarkPersistentFlagRequired("tenant")
+	}
+	for _, command := range []*cobra.Command{pluginListCommand, endpointListCommand} {
+		command.Flags().IntVar(&page, "page", 1, "page")
+	}
+	pluginListCommand.Flags().IntVar(&pageSize, "page-size", 256, "page size")
+	endpointListCommand.Flags().IntVar(&pageSize, "page-size", 100, "page size")
+	pluginInstallCommand.Flags().StringVar(&source, "source", "marketplace", "source of the plugins")
+	supportBundleCommand.Flags().StringVarP(&supportBundleOutput, "output", "o", "",
+		"file the bundle is written to, the name given by the daemon in the working directory by default")
+	mcpCommand.Flags().StringVar(&mcpUserID, "user", "", "user id the tools are invoked as")
+	mcpCommand.Flags().StringVar(&mcpCredentials, "credentials", "",
+		"json file of credentials of tool providers, keyed by `plugin_id/provider`")
+
+	pluginCommand.AddCommand(pluginListCommand)
+	pluginCommand.AddCommand(pluginInstallCommand)
+	pluginCommand.AddCommand(pluginRemoveCommand)
+	endpointCommand.AddCommand(endpointListCommand)
+	endpointCommand.AddCommand(endpointEnableCommand)
+	endpointCommand.AddCommand(endpointDisableCommand)
+	sessionCommand.AddCommand(sessionListCommand)
+	sessionCommand.AddCommand(sessionKillCommand)
+	clusterCommand.AddCommand(clusterNodesCommand)
+
+	rootCommand.AddCommand(pluginCommand)
+	rootCommand.AddCommand(endpointCommand)
+	rootCommand.AddCommand(sessionCommand)
+	rootCommand.AddCommand(clusterCommand)
+	rootCommand.AddCommand(supportBundleCommand)
+	rootCommand.AddCommand(mcpCommand)
+	rootCommand.AddCommand(migrateCommand)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/conformance"
+	"github.com/spf13/cobra"
+)
+
+var (
+	conformanceOptions conformance.Options
+	conformanceCommand string
+	conformanceJSON    bool
+
+	conformanceRunCommand = &cobra.Command{
+		Use:   "conformance [plugin-package]",
+		Short: "Check a plugin against the plugin protocol",
+		Long: "Run a plugin, a .difypkg or a directory of it, against a battery of interactions of the stdio " +
+			"protocol, session events, heartbeats, error cases, large payloads and cancellation, and report how " +
+			"it behaved, the daemon doesn't need to be running. Plugins of languages other than python are " +
+			"launched by --command, dependencies of plugins should have been installed",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options := conformanceOptions
+			options.Command = strings.Fields(conformanceCommand)
+
+			report, err := conformance.Run(cmd.Context(), args[0], options)
+			if err != nil {
+				return err
+			}
+
+			if conformanceJSON {
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+			} else {
+				printConformanceReport(report)
+			}
+
+			if !report.Passed {
+				return errors.New("plugin doesn't conform to the protocol")
+			}
+			return nil
+		},
+	}
+)
+
+func printConformanceReport(report *conformance.Report) {
+	fmt.Printf("%s (%s): %s\n\n", report.Plugin, report.Language, strings.Join(report.Command, " "))
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, result := range report.Results {
+		fmt.Fprintf(writer, "%s\t%s\t%dms\t%s\n", result.Case, result.Status, result.Duration, result.Message)
+	}
+	writer.Flush()
+
+	if report.Stderr != "" {
+		fmt.Printf("\nstderr of the plugin:\n%s\n", report.Stderr)
+	}
+}
+
+func init() {
+	flags := conformanceRunCommand.Flags()
+	flags.StringVar(&conformanceCommand, "command", "",
+		"command launching the plugin in its directory, `python -m <entrypoint>` for python plugins by default")
+	flags.StringVar(&conformanceOptions.PythonInterpreter, "python", "python3", "python interpreter of python plugins")
+	flags.DurationVar(&conformanceOptions.StartupTimeout, "startup-timeout", time.Minute,
+		"timeout of the first heartbeat")
+	flags.DurationVar(&conformanceOptions.SessionTimeout, "session-timeout", 30*time.Second, "timeout of sessions")
+	flags.DurationVar(&conformanceOptions.HeartbeatTimeout, "heartbeat-timeout", time.Minute,
+		"longest interval of heartbeats")
+	flags.IntVar(&conformanceOptions.PayloadSize, "payload-size", 1024*1024, "size of the large payload, in bytes")
+	flags.BoolVar(&conformanceJSON, "json", false, "print the report in json")
+
+	rootCommand.AddCommand(conformanceRunCommand)
+}
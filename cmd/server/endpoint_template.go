@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	endpointTemplateUserID      string
+	endpointTemplateEndpointIDs []string
+
+	endpointTemplateCommand = &cobra.Command{
+		Use:   "template",
+		Short: "Endpoint templates",
+		Long:  "Manage endpoints materialized from endpoint templates of a tenant",
+	}
+
+	endpointTemplateListCommand = &cobra.Command{
+		Use:   "list",
+		Short: "List endpoint templates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodGet, tenantPath("/endpoint/templates"), nil, nil)
+		},
+	}
+
+	endpointTemplateInstancesCommand = &cobra.Command{
+		Use:   "instances [template_id]",
+		Short: "List endpoints materialized from a template",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodGet, tenantPath("/endpoint/templates/instances"), url.Values{
+				"template_id": {args[0]},
+			}, nil)
+		},
+	}
+
+	endpointTemplateManageCommand = &cobra.Command{
+		Use:       "manage [template_id] [sync|enable|disable|remove]",
+		Short:     "Sync, enable, disable or remove endpoints of a template",
+		Long:      "Run an action against endpoints materialized from a template, all of them unless --endpoint is given",
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"sync", "enable", "disable", "remove"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodPost, tenantPath("/endpoint/templates/instances/manage"), nil, map[string]any{
+				"template_id":  args[0],
+				"action":       args[1],
+				"user_id":      endpointTemplateUserID,
+				"endpoint_ids": endpointTemplateEndpointIDs,
+			})
+		},
+	}
+)
+
+func init() {
+	endpointTemplateManageCommand.Flags().StringVar(&endpointTemplateUserID, "user", "", "user id endpoints are synced as")
+	endpointTemplateManageCommand.Flags().StringSliceVar(&endpointTemplateEndpointIDs, "endpoint", nil, "ids of endpoints to manage")
+
+	endpointTemplateCommand.AddCommand(endpointTemplateListCommand)
+	endpointTemplateCommand.AddCommand(endpointTemplateInstancesCommand)
+	endpointTemplateCommand.AddCommand(endpointTemplateManageCommand)
+	endpointCommand.AddCommand(endpointTemplateCommand)
+}
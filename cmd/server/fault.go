@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	faultRulesFile string
+
+	faultCommand = &cobra.Command{
+		Use:   "fault",
+		Short: "Fault",
+		Long: "Inject faults into the node serving the request to check the daemon recovers from them, " +
+			"FAULT_INJECTION_ENABLED has to be set on the node",
+	}
+
+	faultListCommand = &cobra.Command{
+		Use:   "list",
+		Short: "List fault injection rules",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodGet, "/admin/faults", nil, nil)
+		},
+	}
+
+	faultSetCommand = &cobra.Command{
+		Use:   "set",
+		Short: "Replace fault injection rules",
+		Long: "Replace fault injection rules by the ones of a yaml or json file, a list of rules like " +
+			`{"point": "db", "kind": "error", "target": "plugin_installations", "probability": 0.1}`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(faultRulesFile)
+			if err != nil {
+				return err
+			}
+			rules := []map[string]any{}
+			if err := yaml.Unmarshal(data, &rules); err != nil {
+				return fmt.Errorf("invalid rules file %s: %w", faultRulesFile, err)
+			}
+			return printCall(http.MethodPost, "/admin/faults/set", nil, map[string]any{
+				"rules": rules,
+			})
+		},
+	}
+
+	faultClearCommand = &cobra.Command{
+		Use:   "clear",
+		Short: "Remove all fault injection rules",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodPost, "/admin/faults/clear", nil, nil)
+		},
+	}
+)
+
+func init() {
+	faultSetCommand.Flags().StringVarP(&faultRulesFile, "file", "f", "", "yaml or json file of the rules")
+	faultSetCommand.MarkFlagRequired("file")
+
+	faultCommand.AddCommand(faultListCommand)
+	faultCommand.AddCommand(faultSetCommand)
+	faultCommand.AddCommand(faultClearCommand)
+
+	rootCommand.AddCommand(faultCommand)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/admin_auth"
+	"github.com/spf13/cobra"
+)
+
+var (
+	oidcIssuer   string
+	oidcClientID string
+	ldapUser     string
+
+	// intervals of polling the token endpoint are in units of it, they're seconds by the spec of the device flow
+	pollUnit = time.Second
+
+	loginCommand = &cobra.Command{
+		Use:   "login",
+		Short: "Sign in by oidc",
+		Long: "Sign in to the admin apis of the running daemon by the oidc device flow, the id token is saved " +
+			"and sent by later commands unless a key is given",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := login(cmd.Context(), oidcIssuer, oidcClientID)
+			if err != nil {
+				return err
+			}
+			if err := saveIDToken(token); err != nil {
+				return err
+			}
+			fmt.Println("signed in")
+			return nil
+		},
+	}
+
+	logoutCommand = &cobra.Command{
+		Use:   "logout",
+		Short: "Sign out",
+		Long:  "Remove the id token saved by login",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := idTokenPath()
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			fmt.Println("signed out")
+			return nil
+		},
+	}
+
+	whoamiCommand = &cobra.Command{
+		Use:   "whoami",
+		Short: "Show the caller",
+		Long:  "Show the caller of the admin apis, along with the role if it's an operator signed in by oidc or ldap",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printCall(http.MethodGet, "/admin/whoami", nil, nil)
+		},
+	}
+)
+
+func init() {
+	loginCommand.Flags().StringVar(&oidcIssuer, "issuer", os.Getenv("ADMIN_AUTH_OIDC_ISSUER"),
+		"oidc issuer, $ADMIN_AUTH_OIDC_ISSUER by default")
+	loginCommand.Flags().StringVar(&oidcClientID, "client-id", os.Getenv("ADMIN_AUTH_OIDC_CLIENT_ID"),
+		"oidc client id, $ADMIN_AUTH_OIDC_CLIENT_ID by default")
+	rootCommand.PersistentFlags().StringVar(&ldapUser, "ldap-user", "",
+		"ldap user signing in to the admin apis, the password is read from $ADMIN_AUTH_LDAP_PASSWORD")
+
+	rootCommand.AddCommand(loginCommand)
+	rootCommand.AddCommand(logoutCommand)
+	rootCommand.AddCommand(whoamiCommand)
+}
+
+func idTokenPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dify-plugin-daemon", "id_token"), nil
+}
+
+func saveIDToken(token string) error {
+	path, err := idTokenPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(token), 0o600)
+}
+
+// operatorAuthorization returns the Authorization header of the operator, the ldap user given or the id token
+// saved by login, empty if neither of them is
+func operatorAuthorization() string {
+	if ldapUser != "" {
+		credentials := ldapUser + ":" + os.Getenv("ADMIN_AUTH_LDAP_PASSWORD")
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+	path, err := idTokenPath()
+	if err != nil {
+		return ""
+	}
+	token, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return "Bearer " + strings.TrimSpace(string(token))
+}
+
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+	Error                   string `json:"error"`
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+	Error   string `json:"error"`
+}
+
+// login signs in by the device authorization grant, RFC 8628, and returns the id token
+func login(ctx context.Context, issuer string, clientID string) (string, error) {
+	if issuer == "" || clientID == "" {
+		return "", errors.New("oidc issuer and client id are required")
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	metadata, err := admin_auth.Discover(ctx, client, issuer)
+	if err != nil {
+		return "", err
+	}
+	if metadata.DeviceAuthorizationEndpoint == "" {
+		return "", errors.New("oidc issuer doesn't support the device flow")
+	}
+
+	authorization := deviceAuthorization{}
+	if err := postForm(ctx, client, metadata.DeviceAuthorizationEndpoint, url.Values{
+		"client_id": {clientID},
+		"scope":     {"openid profile email"},
+	}, &authorization); err != nil {
+		return "", fmt.Errorf("authorize device failed: %w", err)
+	}
+	if authorization.Error != "" {
+		return "", fmt.Errorf("authorize device failed: %s", authorization.Error)
+	}
+	if authorization.VerificationURIComplete != "" {
+		fmt.Printf("open %s to sign in\n", authorization.VerificationURIComplete)
+	} else {
+		fmt.Printf("open %s and enter %s to sign in\n", authorization.VerificationURI, authorization.UserCode)
+	}
+
+	interval := authorization.Interval
+	if interval <= 0 {
+		interval = 5
+	}
+	expiresIn := authorization.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = 600
+	}
+	deadline := time.Now().Add(time.Duration(expiresIn) * pollUnit)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Duration(interval) * pollUnit):
+		}
+
+		token := tokenResponse{}
+		if err := postForm(ctx, client, metadata.TokenEndpoint, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {authorization.DeviceCode},
+			"client_id":   {clientID},
+		}, &token); err != nil {
+			return "", fmt.Errorf("request token failed: %w", err)
+		}
+
+		switch token.Error {
+		case "":
+			if token.IDToken == "" {
+				return "", errors.New("oidc issuer returned no id token")
+			}
+			return token.IDToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5
+		default:
+			return "", fmt.Errorf("sign in failed: %s", token.Error)
+		}
+	}
+	return "", errors.New("sign in expired")
+}
+
+// postForm posts the form and decodes the json response, errors of oauth are returned in bodies of 400
+func postForm(ctx context.Context, client *http.Client, endpoint string, form url.Values, target any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/admin_auth"
+)
+
+func TestLoginByDeviceFlow(t *testing.T) {
+	pollUnit = time.Millisecond
+	defer func() { pollUnit = time.Second }()
+
+	polls := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(admin_auth.ProviderMetadata{
+				Issuer:                      server.URL,
+				TokenEndpoint:               server.URL + "/token",
+				DeviceAuthorizationEndpoint: server.URL + "/device",
+			})
+		case "/device":
+			if r.FormValue("client_id") != "cli" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(tokenResponse{Error: "invalid_client"})
+				return
+			}
+			json.NewEncoder(w).Encode(deviceAuthorization{
+				DeviceCode: "device", UserCode: "ABCD", VerificationURI: server.URL + "/activate", Interval: 1,
+			})
+		case "/token":
+			if r.FormValue("device_code") != "device" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(tokenResponse{Error: "invalid_grant"})
+				return
+			}
+			polls++
+			if polls < 3 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(tokenResponse{Error: "authorization_pending"})
+				return
+			}
+			json.NewEncoder(w).Encode(tokenResponse{IDToken: "id-token"})
+		}
+	}))
+	defer server.Close()
+
+	token, err := login(context.Background(), server.URL, "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "id-token" || polls != 3 {
+		t.Fatalf("expected the id token after 3 polls, got %s after %d", token, polls)
+	}
+
+	if _, err := login(context.Background(), server.URL, "others"); err == nil || err.Error() != "authorize device failed: invalid_client" {
+		t.Fatalf("errors of the issuer should be returned, got %v", err)
+	}
+}
@@ -1,29 +1,43 @@
 package main
 
 import (
+	"os"
+
 	"github.com/joho/godotenv"
-	"github.com/kelseyhightower/envconfig"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
 	"github.com/langgenius/dify-plugin-daemon/internal/server"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/spf13/cobra"
 )
 
-func main() {
-	var config app.Config
-
-	// load env
-	godotenv.Load()
+var rootCommand = &cobra.Command{
+	Use:   "dify-plugin-daemon",
+	Short: "Dify plugin daemon",
+	Long: "Runs the daemon if no command is given, commands manage a running daemon over its api " +
+		"or work on the database offline",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		(&server.App{}).Run(loadConfig())
+	},
+}
 
-	err := envconfig.Process("", &config)
+func loadConfig() *app.Config {
+	// settings are read from the environment and CONFIG_FILE
+	config, err := config_loader.Load()
 	if err != nil {
-		log.Panic("Error processing environment variables: %s", err.Error())
+		log.Panic("Error loading config: %s", err.Error())
 	}
 
-	config.SetDefault()
+	return config
+}
 
-	if err := config.Validate(); err != nil {
-		log.Panic("Invalid configuration: %s", err.Error())
-	}
+func main() {
+	// load env, it's shared by the daemon and the commands
+	godotenv.Load()
 
-	(&server.App{}).Run(&config)
+	if err := rootCommand.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/mock_marketplace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mockMarketplaceDir    string
+	mockMarketplaceListen string
+
+	mockMarketplaceCommand = &cobra.Command{
+		Use:   "mock-marketplace",
+		Short: "Serve plugins of a directory as the marketplace",
+		Long: "Serve .difypkg files of a directory by the api of the marketplace, point MARKETPLACE_API_URL of dify " +
+			"and MARKETPLACE_URL of the daemon to it to install and upgrade plugins from fixtures, packages dropped " +
+			"in are served without restarting",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			marketplace, err := mock_marketplace.New(mockMarketplaceDir)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("serving plugins of %s on %s\n", mockMarketplaceDir, mockMarketplaceListen)
+			return http.ListenAndServe(mockMarketplaceListen, marketplace.Handler())
+		},
+	}
+)
+
+func init() {
+	mockMarketplaceCommand.Flags().StringVar(&mockMarketplaceDir, "dir", ".", "directory of plugin packages")
+	mockMarketplaceCommand.Flags().StringVar(&mockMarketplaceListen, "listen", "127.0.0.1:8090", "address to listen on")
+
+	rootCommand.AddCommand(mockMarketplaceCommand)
+}
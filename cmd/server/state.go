@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	stateFile string
+
+	stateCommand = &cobra.Command{
+		Use:   "state",
+		Short: "State",
+		Long: "Reconcile plugins and endpoints of tenants to a declared state, a yaml or json file listing plugins " +
+			"and endpoints each tenant should have, tenants not listed are left untouched",
+	}
+
+	statePlanCommand = &cobra.Command{
+		Use:   "plan",
+		Short: "Show changes applying the state would make",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, err := readState(stateFile)
+			if err != nil {
+				return err
+			}
+			return printCall(http.MethodPost, "/admin/state/plan", nil, state)
+		},
+	}
+
+	stateApplyCommand = &cobra.Command{
+		Use:   "apply",
+		Short: "Apply the state",
+		Long: "Install missing plugins, upgrade drifted ones, set up endpoints and remove plugins and endpoints " +
+			"not declared, the status of each change is printed",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, err := readState(stateFile)
+			if err != nil {
+				return err
+			}
+			return printCall(http.MethodPost, "/admin/state/apply", nil, state)
+		},
+	}
+)
+
+// readState reads the declared state, json files are read as yaml as well
+func readState(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state := map[string]any{}
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+func init() {
+	for _, command := range []*cobra.Command{statePlanCommand, stateApplyCommand} {
+		command.Flags().StringVarP(&stateFile, "file", "f", "", "yaml or json file of the declared state")
+		command.MarkFlagRequired("file")
+	}
+
+	stateCommand.AddCommand(statePlanCommand)
+	stateCommand.AddCommand(stateApplyCommand)
+
+	rootCommand.AddCommand(stateCommand)
+}
@@ -1,6 +1,8 @@
 package cluster
 
 import (
+	"crypto/tls"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -8,9 +10,14 @@ import (
 	"github.com/google/uuid"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/mapping"
 )
 
+var (
+	logger = log.Component(log.COMPONENT_CLUSTER)
+)
+
 type Cluster struct {
 	// id is the unique id of the cluster
 	id string
@@ -20,6 +27,26 @@ type Cluster struct {
 
 	// main http port of the current node
 	port uint16
+	// scheme and client of requests to other nodes, see UseTLS
+	scheme string
+	client *http.Client
+
+	// role of the current node, gateways run no plugin so requests are always redirected to runners
+	role app.NodeRole
+
+	startedAt time.Time
+	// draining is set once the current node is requested to drain, see DrainNode
+	draining atomic.Bool
+
+	// epoch the current node joined the cluster with and the last time its status was published,
+	// fenced is set once it fails to publish its status for too long, see fencing.go
+	epoch    atomic.Int64
+	syncedAt atomic.Int64
+	fenced   atomic.Bool
+	// masterRenewedAt is the last time the master lock was renewed by the current node
+	masterRenewedAt time.Time
+	// lifetimeTickedAt is the last time the lifetime loop handled an event, in unix nanoseconds
+	lifetimeTickedAt atomic.Int64
 
 	// plugins stores all the plugin life time of the current node
 	plugins    mapping.Map[string, *pluginLifeTime]
@@ -29,6 +56,8 @@ type Cluster struct {
 
 	// nodes stores all the nodes of the cluster
 	nodes mapping.Map[string, node]
+	// incompatibleNodes stores nodes speaking an incompatible cluster protocol which have been warned
+	incompatibleNodes mapping.Map[string, bool]
 
 	// signals for waiting for the cluster to stop
 	stopChan chan bool
@@ -51,6 +80,21 @@ type Cluster struct {
 
 	showLog bool
 
+	// loadBalanceThreshold is how many more sessions the current node serves than the least loaded one
+	// before new sessions are redirected, sessions are always served locally if load balancing is disabled
+	loadBalancingEnabled bool
+	loadBalanceThreshold int
+
+	// maxSessions is how many sessions the node serves before it's at capacity, 0 means unlimited
+	maxSessions int
+
+	// placementReplicas is how many nodes each plugin is placed on, every node runs all plugins if it's 0
+	placementReplicas int
+	placementRing     atomic.Pointer[hashRing]
+
+	// reconcileInterval is how often local plugins are reconciled with installations, 0 if disabled
+	reconcileInterval time.Duration
+
 	masterGcInterval              time.Duration
 	masterLockingInterval         time.Duration
 	masterLockExpiredTime         time.Duration
@@ -66,8 +110,17 @@ func NewCluster(config *app.Config, plugin_manager *plugin_manager.PluginManager
 	return &Cluster{
 		id:                            uuid.New().String(),
 		port:                          uint16(config.ServerPort),
+		scheme:                        "http",
+		client:                        http.DefaultClient,
+		role:                          config.NodeRole,
+		startedAt:                     time.Now(),
 		stopChan:                      make(chan bool),
 		showLog:                       config.DisplayClusterLog,
+		loadBalancingEnabled:          config.ClusterLoadBalancingEnabled != nil && *config.ClusterLoadBalancingEnabled,
+		loadBalanceThreshold:          config.ClusterLoadBalanceThreshold,
+		maxSessions:                   config.NodeMaxSessions,
+		placementReplicas:             config.PluginPlacementReplicas,
+		reconcileInterval:             reconcileInterval(config),
 		masterGcInterval:              MASTER_GC_INTERVAL,
 		masterLockingInterval:         MASTER_LOCKING_INTERVAL,
 		masterLockExpiredTime:         MASTER_LOCK_EXPIRED_TIME,
@@ -95,6 +148,28 @@ func NewCluster(config *app.Config, plugin_manager *plugin_manager.PluginManager
 
 func (c *Cluster) Launch() {
 	go c.clusterLifetime()
+
+	if c.reconcileInterval > 0 && c.manager != nil {
+		c.startReconciler()
+	}
+}
+
+// UseTLS makes requests to other nodes over tls, it's called before the cluster is launched
+func (c *Cluster) UseTLS(config *tls.Config) {
+	c.scheme = "https"
+	c.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: config,
+		},
+	}
+}
+
+func reconcileInterval(config *app.Config) time.Duration {
+	if config.PluginReconcileEnabled == nil || !*config.PluginReconcileEnabled {
+		return 0
+	}
+	return time.Duration(config.PluginReconcileInterval) * time.Second
 }
 
 func (c *Cluster) Close() error {
@@ -105,6 +180,11 @@ func (c *Cluster) Close() error {
 	return nil
 }
 
+// LoadBalancingEnabled returns true if new sessions are routed to the least loaded node running the plugin
+func (c *Cluster) LoadBalancingEnabled() bool {
+	return c.loadBalancingEnabled
+}
+
 func (c *Cluster) ID() string {
 	return c.id
 }
@@ -1,10 +1,11 @@
 package cluster
 
 import (
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 )
 
@@ -47,7 +48,7 @@ const (
 func (c *Cluster) clusterLifetime() {
 	defer func() {
 		if err := c.removeSelfNode(); err != nil {
-			log.Error("failed to remove the self node from the cluster: %s", err.Error())
+			logger.Error("failed to remove the self node from the cluster: %s", err.Error())
 		}
 		c.notifyClusterStopped()
 
@@ -84,17 +85,17 @@ func (c *Cluster) clusterLifetime() {
 		"function": "voteAddressesWhenInit",
 	}, func() {
 		if err := c.updateNodeStatus(); err != nil {
-			log.Error("failed to update the status of the node: %s", err.Error())
+			logger.Error("failed to update the status of the node: %s", err.Error())
 		}
 
 		if err := cache.Publish(CLUSTER_NEW_NODE_CHANNEL, newNodeEvent{
 			NodeID: c.id,
 		}); err != nil {
-			log.Error("failed to publish the new node event: %s", err.Error())
+			logger.Error("failed to publish the new node event: %s", err.Error())
 		}
 
 		if err := c.voteAddresses(); err != nil {
-			log.Error("failed to vote the ips of the nodes: %s", err.Error())
+			logger.Error("failed to vote the ips of the nodes: %s", err.Error())
 		}
 	})
 
@@ -102,60 +103,92 @@ func (c *Cluster) clusterLifetime() {
 	defer cancel()
 
 	for {
+		c.lifetimeTickedAt.Store(time.Now().UnixNano())
 		select {
 		case <-tickerLockMaster.C:
-			if !c.iAmMaster {
+			if c.IsFenced() {
+				// a fenced node never takes the master, it may be gone in the view of other nodes
+			} else if !c.iAmMaster {
 				// try lock the slot
 				if success, err := c.lockMaster(); err != nil {
-					log.Error("failed to lock the slot to be the master of the cluster: %s", err.Error())
+					logger.Error("failed to lock the slot to be the master of the cluster: %s", err.Error())
 				} else if success {
 					c.iAmMaster = true
-					log.Info("current node has become the master of the cluster")
+					c.masterRenewedAt = time.Now()
+					logger.Info("current node has become the master of the cluster")
 					c.notifyBecomeMaster()
-				} else {
-					if c.iAmMaster {
-						c.iAmMaster = false
-						log.Info("current node has released the master slot")
-					}
 				}
 			} else {
 				// update the master
-				if err := c.updateMaster(); err != nil {
-					log.Error("failed to update the master: %s", err.Error())
+				if renewed, err := c.updateMaster(); err != nil {
+					logger.Error("failed to update the master: %s", err.Error())
+					// the lock has expired in redis by now, another node may have taken it
+					if time.Since(c.masterRenewedAt) >= c.masterLockExpiredTime {
+						c.iAmMaster = false
+						logger.Warn("current node has stepped down from the master as the lock is not renewed in time")
+					}
+				} else if !renewed {
+					c.iAmMaster = false
+					logger.Info("current node has released the master slot")
+				} else {
+					c.masterRenewedAt = time.Now()
 				}
 			}
 		case <-tickerUpdateNodeStatus.C:
 			if err := c.updateNodeStatus(); err != nil {
-				log.Error("failed to update the status of the node: %s", err.Error())
+				logger.Error("failed to update the status of the node: %s", err.Error())
+			}
+			c.checkFencing()
+			if err := c.syncLogLevels(); err != nil {
+				logger.Error("failed to sync log levels of the cluster: %s", err.Error())
 			}
 		case <-masterGcTicker.C:
 			if c.iAmMaster {
 				c.notifyMasterGC()
 				if err := c.autoGCNodes(); err != nil {
-					log.Error("failed to gc the nodes have already deactivated: %s", err.Error())
+					logger.Error("failed to gc the nodes have already deactivated: %s", err.Error())
 				}
 				if err := c.autoGCPlugins(); err != nil {
-					log.Error("failed to gc the plugins have already stopped: %s", err.Error())
+					logger.Error("failed to gc the plugins have already stopped: %s", err.Error())
 				}
 				c.notifyMasterGCCompleted()
 			}
 		case <-nodeVoteTicker.C:
 			if err := c.voteAddresses(); err != nil {
-				log.Error("failed to vote the ips of the nodes: %s", err.Error())
+				logger.Error("failed to vote the ips of the nodes: %s", err.Error())
 			}
 		case _, ok := <-newNodeChan:
 			if ok {
 				// vote for the new node
 				if err := c.voteAddresses(); err != nil {
-					log.Error("failed to vote the ips of the nodes: %s", err.Error())
+					logger.Error("failed to vote the ips of the nodes: %s", err.Error())
 				}
 			}
 		case <-pluginSchedulerTicker.C:
+			// plugins of a fenced node are no longer published, so that they're not routed to
+			if c.IsFenced() {
+				continue
+			}
 			if err := c.schedulePlugins(); err != nil {
-				log.Error("failed to schedule the plugins: %s", err.Error())
+				logger.Error("failed to schedule the plugins: %s", err.Error())
 			}
 		case <-c.stopChan:
 			return
 		}
 	}
 }
+
+// CheckLifetime returns an error if the lifetime loop has handled no event for the timeout, the loop wakes
+// up every $MASTER_LOCKING_INTERVAL so it's stuck in a handler then
+func (c *Cluster) CheckLifetime(timeout time.Duration) error {
+	tickedAt := c.lifetimeTickedAt.Load()
+	if tickedAt == 0 || atomic.LoadInt32(&c.stopped) == 1 {
+		// not launched yet or stopped on purpose
+		return nil
+	}
+
+	if stalled := time.Since(time.Unix(0, tickedAt)); stalled > timeout {
+		return fmt.Errorf("cluster lifetime loop has not progressed for %s", stalled.Round(time.Second))
+	}
+	return nil
+}
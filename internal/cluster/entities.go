@@ -2,6 +2,8 @@ package cluster
 
 import (
 	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 )
 
 type address struct {
@@ -23,6 +25,17 @@ type vote struct {
 type node struct {
 	Addresses  []address `json:"ips"`
 	LastPingAt int64     `json:"last_ping_at"`
+	// Load is nil if the node is running a version without load reporting
+	Load      *nodeLoad    `json:"load,omitempty"`
+	Role      app.NodeRole `json:"role,omitempty"`
+	Version   string       `json:"version,omitempty"`
+	StartedAt int64        `json:"started_at,omitempty"`
+	Draining  bool         `json:"draining,omitempty"`
+	// versions of the cluster protocol, see CLUSTER_PROTOCOL_VERSION
+	ProtocolVersion    int `json:"protocol_version,omitempty"`
+	MinProtocolVersion int `json:"min_protocol_version,omitempty"`
+	// Epoch is taken each time the node joins the cluster, see fencing.go
+	Epoch int64 `json:"epoch,omitempty"`
 }
 
 type newNodeEvent struct {
@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+// Nodes partitioned from redis can't tell whether other nodes still consider them alive, so a node which
+// fails to publish its status for $NODE_DISCONNECTED_TIMEOUT fences itself, as other nodes have stopped
+// routing to it and the master may have collected it by then.
+//
+// A fenced node:
+//   - stops accepting new sessions
+//   - stops publishing its plugins so they're no longer routed to
+//   - steps down from the master and singleton jobs
+//   - disconnects debugging plugins, they reconnect once the node has rejoined or to another node
+//
+// Each time a node joins the cluster or rejoins after being fenced, it takes a new epoch from a counter
+// in redis, the epoch is published along with its status so that a rejoined node is told apart from the
+// incarnation other nodes have seen before the partition.
+
+const (
+	CLUSTER_MEMBERSHIP_EPOCH_KEY = "cluster-membership-epoch"
+)
+
+// IsFenced returns true if the current node is partitioned from the cluster
+func (c *Cluster) IsFenced() bool {
+	return c.fenced.Load()
+}
+
+// Epoch returns the epoch the current node joined the cluster with, 0 if it has not joined yet
+func (c *Cluster) Epoch() int64 {
+	return c.epoch.Load()
+}
+
+// joinEpoch returns the epoch to publish, a new one is taken if the node has never joined or was fenced
+func (c *Cluster) joinEpoch() (int64, error) {
+	if epoch := c.epoch.Load(); epoch != 0 && !c.IsFenced() {
+		return epoch, nil
+	}
+
+	epoch, err := cache.Increase(CLUSTER_MEMBERSHIP_EPOCH_KEY)
+	if err != nil {
+		return 0, err
+	}
+	return epoch, nil
+}
+
+// markSynced is called once the status of the node is published with the epoch
+func (c *Cluster) markSynced(epoch int64) {
+	c.syncedAt.Store(time.Now().UnixNano())
+	c.epoch.Store(epoch)
+
+	if c.fenced.CompareAndSwap(true, false) {
+		logger.Info("current node has rejoined the cluster with epoch %d", epoch)
+	}
+}
+
+// shouldFence returns true if the node has not published its status since others consider it gone
+func shouldFence(syncedAt time.Time, timeout time.Duration, now time.Time) bool {
+	return !syncedAt.IsZero() && now.Sub(syncedAt) >= timeout
+}
+
+// checkFencing fences the current node once it has been partitioned for too long
+func (c *Cluster) checkFencing() {
+	syncedAt := time.Time{}
+	if nano := c.syncedAt.Load(); nano != 0 {
+		syncedAt = time.Unix(0, nano)
+	}
+
+	if !shouldFence(syncedAt, c.nodeDisconnectedTimeout, time.Now()) {
+		return
+	}
+	if !c.fenced.CompareAndSwap(false, true) {
+		return
+	}
+
+	logger.Warn(
+		"current node has been partitioned from the cluster since %s, fencing it until it rejoins",
+		syncedAt.Format(time.RFC3339),
+	)
+
+	if c.iAmMaster {
+		c.iAmMaster = false
+		logger.Warn("current node has stepped down from the master as it's fenced")
+	}
+
+	if c.manager == nil {
+		return
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "cluster",
+		"function": "disconnectDebuggingPlugins",
+	}, c.manager.DisconnectDebuggingPlugins)
+}
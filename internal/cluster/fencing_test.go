@@ -0,0 +1,26 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShouldFence(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name     string
+		syncedAt time.Time
+		expected bool
+	}{
+		{"never synced", time.Time{}, false},
+		{"synced recently", now.Add(-time.Second), false},
+		{"partitioned for the timeout", now.Add(-NODE_DISCONNECTED_TIMEOUT), true},
+		{"partitioned for long", now.Add(-time.Minute), true},
+	}
+
+	for _, c := range cases {
+		if fence := shouldFence(c.syncedAt, NODE_DISCONNECTED_TIMEOUT, now); fence != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, fence)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// nodeLoad is published along with the status of the node every $UPDATE_NODE_STATUS_INTERVAL,
+// it's used to route new sessions to the least loaded node running the plugin
+type nodeLoad struct {
+	// Sessions is the number of sessions being served by the node
+	Sessions int `json:"sessions"`
+	// Plugins is the number of plugins running on the node
+	Plugins int `json:"plugins"`
+	// AtCapacity is true if the node serves $NODE_MAX_SESSIONS sessions, no new session is routed to it
+	AtCapacity bool  `json:"at_capacity"`
+	UpdatedAt  int64 `json:"updated_at"`
+}
+
+func (c *Cluster) currentLoad() nodeLoad {
+	return nodeLoad{
+		Sessions:   session_manager.Count(),
+		Plugins:    c.plugins.Len(),
+		AtCapacity: c.AtCapacity(),
+		UpdatedAt:  time.Now().Unix(),
+	}
+}
+
+// nodeSessions returns sessions of the node, the live number is used for the current node
+// as the published one is updated periodically
+func (c *Cluster) nodeSessions(nodeId string) int {
+	if nodeId == c.id {
+		return session_manager.Count()
+	}
+
+	node, ok := c.nodes.Load(nodeId)
+	if !ok || node.Load == nil {
+		return 0
+	}
+	return node.Load.Sessions
+}
+
+// pickLeastLoadedNode returns the node with the fewest sessions among candidates, the current node is kept
+// if it's a candidate and it serves no more than threshold sessions beyond the least loaded one, which avoids
+// redirecting requests for a negligible difference as redirecting costs a round trip
+func pickLeastLoadedNode(current string, candidates map[string]int, threshold int) string {
+	picked := ""
+	for nodeId, sessions := range candidates {
+		if picked == "" || sessions < candidates[picked] || (sessions == candidates[picked] && nodeId < picked) {
+			picked = nodeId
+		}
+	}
+
+	if sessions, ok := candidates[current]; ok && sessions-candidates[picked] <= threshold {
+		return current
+	}
+	return picked
+}
+
+// PickNodeForPlugin returns the node a new session of the plugin should be served by, only nodes which
+// already have the plugin running are picked so that no node starts a cold copy of it,
+// an empty string is returned if no node is running the plugin or all of them are at capacity
+func (c *Cluster) PickNodeForPlugin(identity plugin_entities.PluginUniqueIdentifier) (string, error) {
+	nodes, err := c.FetchPluginAvailableNodesById(identity.String())
+	if err != nil {
+		return "", err
+	}
+
+	candidates := map[string]int{}
+	for _, nodeId := range nodes {
+		candidates[nodeId] = c.nodeSessions(nodeId)
+	}
+	if _, ok := c.plugins.Load(identity.String()); ok {
+		candidates[c.id] = c.nodeSessions(c.id)
+	}
+
+	candidates = withoutDrainingNodes(withoutSaturatedNodes(candidates, c.IsNodeAtCapacity), c.isNodeDraining)
+	return pickLeastLoadedNode(c.id, candidates, c.loadBalanceThreshold), nil
+}
+
+// isNodeDraining returns whether the node is draining, the live flag is used for the current node
+func (c *Cluster) isNodeDraining(nodeId string) bool {
+	if nodeId == c.id {
+		return c.IsDraining()
+	}
+
+	node, ok := c.nodes.Load(nodeId)
+	return ok && node.Draining
+}
+
+// withoutDrainingNodes drops draining nodes from candidates, they're kept if all candidates are draining
+// as serving the session on a draining node is better than failing it
+func withoutDrainingNodes(candidates map[string]int, draining func(nodeId string) bool) map[string]int {
+	result := map[string]int{}
+	for nodeId, sessions := range candidates {
+		if !draining(nodeId) {
+			result[nodeId] = sessions
+		}
+	}
+
+	if len(result) == 0 {
+		return candidates
+	}
+	return result
+}
+
+// AtCapacity returns true if the current node serves as many sessions as $NODE_MAX_SESSIONS
+func (c *Cluster) AtCapacity() bool {
+	return c.maxSessions > 0 && session_manager.Count() >= c.maxSessions
+}
+
+// IsNodeAtCapacity returns whether the node is at capacity, the live state is used for the current node
+func (c *Cluster) IsNodeAtCapacity(nodeId string) bool {
+	if nodeId == c.id {
+		return c.AtCapacity()
+	}
+
+	node, ok := c.nodes.Load(nodeId)
+	return ok && node.Load != nil && node.Load.AtCapacity
+}
+
+// MarkNodeAtCapacity marks the node at capacity once it rejects a request, so that no more sessions are
+// routed to it until it publishes its load again
+func (c *Cluster) MarkNodeAtCapacity(nodeId string) {
+	node, ok := c.nodes.Load(nodeId)
+	if !ok {
+		return
+	}
+
+	load := nodeLoad{}
+	if node.Load != nil {
+		load = *node.Load
+	}
+	load.AtCapacity = true
+	node.Load = &load
+	c.nodes.Store(nodeId, node)
+}
+
+// withoutSaturatedNodes drops nodes at capacity from candidates, unlike draining nodes they're never kept
+// as they reject new sessions anyway
+func withoutSaturatedNodes(candidates map[string]int, saturated func(nodeId string) bool) map[string]int {
+	result := map[string]int{}
+	for nodeId, sessions := range candidates {
+		if !saturated(nodeId) {
+			result[nodeId] = sessions
+		}
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package cluster
+
+import "testing"
+
+func TestPickLeastLoadedNode(t *testing.T) {
+	cases := []struct {
+		name       string
+		current    string
+		candidates map[string]int
+		threshold  int
+		expected   string
+	}{
+		{"no candidates", "a", map[string]int{}, 10, ""},
+		{"current is not running the plugin", "a", map[string]int{"b": 20, "c": 5}, 10, "c"},
+		{"current within threshold", "a", map[string]int{"a": 15, "b": 5}, 10, "a"},
+		{"current overloaded", "a", map[string]int{"a": 16, "b": 5}, 10, "b"},
+		{"ties are broken by id", "a", map[string]int{"c": 3, "b": 3}, 0, "b"},
+	}
+
+	for _, c := range cases {
+		if picked := pickLeastLoadedNode(c.current, c.candidates, c.threshold); picked != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, picked)
+		}
+	}
+}
+
+func TestWithoutDrainingNodes(t *testing.T) {
+	draining := func(nodeId string) bool { return nodeId == "a" }
+
+	candidates := withoutDrainingNodes(map[string]int{"a": 0, "b": 30}, draining)
+	if _, ok := candidates["a"]; ok || len(candidates) != 1 {
+		t.Errorf("expected draining node to be excluded, got %v", candidates)
+	}
+
+	candidates = withoutDrainingNodes(map[string]int{"a": 0}, draining)
+	if _, ok := candidates["a"]; !ok {
+		t.Errorf("expected draining node to be kept if it's the only candidate, got %v", candidates)
+	}
+}
+
+func TestWithoutSaturatedNodes(t *testing.T) {
+	saturated := func(nodeId string) bool { return nodeId == "a" }
+
+	candidates := withoutSaturatedNodes(map[string]int{"a": 0, "b": 30}, saturated)
+	if _, ok := candidates["a"]; ok || len(candidates) != 1 {
+		t.Errorf("expected saturated node to be excluded, got %v", candidates)
+	}
+
+	candidates = withoutSaturatedNodes(map[string]int{"a": 0}, saturated)
+	if len(candidates) != 0 {
+		t.Errorf("expected saturated node to be excluded even if it's the only candidate, got %v", candidates)
+	}
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// Log levels overridden at runtime are stored in redis so that they apply to every node, each node
+// loads them along with its status every $UPDATE_NODE_STATUS_INTERVAL, the node overriding them applies
+// them at once. Levels not overridden fall back to $LOG_LEVEL.
+
+const (
+	CLUSTER_LOG_LEVELS_HASH_MAP_KEY = "cluster-log-levels"
+	// LOG_LEVEL_GLOBAL is the field of the level applying to logs of all components
+	LOG_LEVEL_GLOBAL = "global"
+)
+
+var (
+	ErrInvalidLogLevel     = errors.New("log level should be one of debug, info, warn and error")
+	ErrUnknownLogComponent = errors.New("unknown log component, should be one of " + strings.Join(log.Components, ", "))
+)
+
+type logLevelOverride struct {
+	Level     string `json:"level"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// LogLevels are the levels overridden at runtime, empty ones are not overridden
+type LogLevels struct {
+	Global     string            `json:"global"`
+	Components map[string]string `json:"components"`
+}
+
+// ListLogLevels returns the levels overridden across the cluster
+func (c *Cluster) ListLogLevels() (*LogLevels, error) {
+	overrides, err := cache.GetMap[logLevelOverride](CLUSTER_LOG_LEVELS_HASH_MAP_KEY)
+	if err != nil && err != cache.ErrNotFound {
+		return nil, err
+	}
+
+	levels := &LogLevels{
+		Components: map[string]string{},
+	}
+	for field, override := range overrides {
+		if field == LOG_LEVEL_GLOBAL {
+			levels.Global = override.Level
+		} else if log.ValidComponent(field) {
+			levels.Components[field] = override.Level
+		}
+	}
+	return levels, nil
+}
+
+// SetLogLevel overrides the level of the component across the cluster, the global level if component is
+// empty or LOG_LEVEL_GLOBAL, an empty level removes the override
+func (c *Cluster) SetLogLevel(component string, level string) error {
+	if component == "" {
+		component = LOG_LEVEL_GLOBAL
+	}
+	if component != LOG_LEVEL_GLOBAL && !log.ValidComponent(component) {
+		return ErrUnknownLogComponent
+	}
+
+	level = strings.ToLower(level)
+	if level == "" {
+		if err := cache.DelMapField(CLUSTER_LOG_LEVELS_HASH_MAP_KEY, component); err != nil {
+			return err
+		}
+	} else {
+		if !log.ValidLevel(level) {
+			return ErrInvalidLogLevel
+		}
+		if err := cache.SetMapOneField(CLUSTER_LOG_LEVELS_HASH_MAP_KEY, component, logLevelOverride{
+			Level:     level,
+			UpdatedAt: time.Now().Unix(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return c.syncLogLevels()
+}
+
+// syncLogLevels applies the levels overridden across the cluster to the current node
+func (c *Cluster) syncLogLevels() error {
+	levels, err := c.ListLogLevels()
+	if err != nil {
+		return err
+	}
+
+	log.SetOverrides(levels.Global, levels.Components)
+	return nil
+}
@@ -7,8 +7,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/manifest"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -69,13 +69,30 @@ func (c *Cluster) updateNodeStatus() error {
 		}
 	}
 
-	// refresh the last ping time
+	// refresh the last ping time and the load
 	nodeStatus.LastPingAt = time.Now().Unix()
+	load := c.currentLoad()
+	nodeStatus.Load = &load
+	nodeStatus.Role = c.role
+	nodeStatus.Version = manifest.VersionX
+	nodeStatus.StartedAt = c.startedAt.Unix()
+	if err := c.syncDraining(); err != nil {
+		logger.Error("failed to sync drain request of the node: %s", err.Error())
+	}
+	nodeStatus.Draining = c.IsDraining()
+	nodeStatus.ProtocolVersion = CLUSTER_PROTOCOL_VERSION
+	nodeStatus.MinProtocolVersion = MIN_CLUSTER_PROTOCOL_VERSION
+	epoch, err := c.joinEpoch()
+	if err != nil {
+		return err
+	}
+	nodeStatus.Epoch = epoch
 
 	// update the status of the node
 	if err := cache.SetMapOneField(CLUSTER_STATUS_HASH_MAP_KEY, c.id, nodeStatus); err != nil {
 		return err
 	}
+	c.markSynced(epoch)
 
 	// get all the nodes
 	nodes, err := c.GetNodes()
@@ -88,6 +105,8 @@ func (c *Cluster) updateNodeStatus() error {
 	for nodeId, node := range nodes {
 		c.nodes.Store(nodeId, node)
 	}
+	c.warnIncompatibleNodes()
+	c.updatePlacement()
 
 	return nil
 }
@@ -127,7 +146,7 @@ func (c *Cluster) FetchPluginAvailableNodesByHashedId(hashedPluginId string) ([]
 		if err != nil {
 			continue
 		}
-		if c.nodes.Exists(nodeId) {
+		if c.nodes.Exists(nodeId) && c.isNodeCompatible(nodeId) {
 			nodes = append(nodes, nodeId)
 		}
 	}
@@ -206,11 +225,19 @@ func (c *Cluster) gcNode(nodeId string) error {
 	}
 	defer c.UnlockNodeStatus(nodeId)
 
+	if err := cache.DelMapField(CLUSTER_DRAINING_NODES_HASH_MAP_KEY, nodeId); err != nil && err != cache.ErrNotFound {
+		return err
+	}
+
+	if err := cache.DelMapField(CLUSTER_RECONCILIATION_HASH_MAP_KEY, nodeId); err != nil && err != cache.ErrNotFound {
+		return err
+	}
+
 	err := cache.DelMapField(CLUSTER_STATUS_HASH_MAP_KEY, nodeId)
 	if err != nil {
 		return err
 	} else {
-		log.Info("node %s has been removed from the cluster due to being disconnected", nodeId)
+		logger.Info("node %s has been removed from the cluster due to being disconnected", nodeId)
 	}
 
 	return nil
@@ -0,0 +1,159 @@
+package cluster
+
+import (
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// Plugins are placed on $PLUGIN_PLACEMENT_REPLICAS runner nodes by consistent hashing of their identifiers,
+// so that each node only runs a share of the installed plugins instead of all of them.
+// Once nodes join or leave, only plugins placed on them move, the nodes launch plugins newly placed on them
+// and stop the ones moved away once the new nodes are running them.
+//
+// Requests reaching a node which isn't running the plugin are redirected to the nodes running it.
+
+const (
+	// virtual nodes of each node on the ring, more virtual nodes spread plugins more evenly
+	PLACEMENT_VIRTUAL_NODES = 64
+)
+
+type ringNode struct {
+	hash   uint32
+	nodeId string
+}
+
+type hashRing struct {
+	nodes   []string
+	entries []ringNode
+}
+
+func newHashRing(nodes []string) *hashRing {
+	ring := &hashRing{
+		nodes:   nodes,
+		entries: make([]ringNode, 0, len(nodes)*PLACEMENT_VIRTUAL_NODES),
+	}
+
+	for _, nodeId := range nodes {
+		for i := 0; i < PLACEMENT_VIRTUAL_NODES; i++ {
+			ring.entries = append(ring.entries, ringNode{
+				hash:   crc32.ChecksumIEEE([]byte(nodeId + "#" + strconv.Itoa(i))),
+				nodeId: nodeId,
+			})
+		}
+	}
+
+	sort.Slice(ring.entries, func(i, j int) bool {
+		if ring.entries[i].hash == ring.entries[j].hash {
+			return ring.entries[i].nodeId < ring.entries[j].nodeId
+		}
+		return ring.entries[i].hash < ring.entries[j].hash
+	})
+	return ring
+}
+
+// owners returns up to replicas distinct nodes the key is placed on, walking the ring clockwise from the key
+func (r *hashRing) owners(key string, replicas int) []string {
+	if len(r.entries) == 0 {
+		return nil
+	}
+
+	replicas = min(replicas, len(r.nodes))
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(r.entries), func(i int) bool {
+		return r.entries[i].hash >= hash
+	})
+
+	owners := make([]string, 0, replicas)
+	for i := 0; i < len(r.entries) && len(owners) < replicas; i++ {
+		nodeId := r.entries[(start+i)%len(r.entries)].nodeId
+		if !slices.Contains(owners, nodeId) {
+			owners = append(owners, nodeId)
+		}
+	}
+	return owners
+}
+
+// PlacementEnabled returns true if each plugin only runs on $PLUGIN_PLACEMENT_REPLICAS nodes
+func (c *Cluster) PlacementEnabled() bool {
+	return c.placementReplicas > 0
+}
+
+// placementMembers returns nodes plugins are placed on, gateways run no plugin
+// and incompatible nodes are not routed to
+func (c *Cluster) placementMembers() []string {
+	members := []string{}
+	c.nodes.Range(func(nodeId string, node node) bool {
+		if node.Role != app.NODE_ROLE_GATEWAY && c.isNodeCompatible(nodeId) {
+			members = append(members, nodeId)
+		}
+		return true
+	})
+
+	sort.Strings(members)
+	return members
+}
+
+// updatePlacement rebuilds the ring once nodes join or leave and rebalances plugins of the current node
+func (c *Cluster) updatePlacement() {
+	if !c.PlacementEnabled() {
+		return
+	}
+
+	members := c.placementMembers()
+	if ring := c.placementRing.Load(); ring != nil && slices.Equal(ring.nodes, members) {
+		return
+	}
+
+	c.placementRing.Store(newHashRing(members))
+	logger.Info("plugin placement updated, placing plugins on nodes: %s", strings.Join(members, ", "))
+
+	if c.manager == nil {
+		return
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "cluster",
+		"function": "rebalancePlugins",
+	}, c.manager.RebalanceLocalPlugins)
+}
+
+func (c *Cluster) pluginOwners(identity plugin_entities.PluginUniqueIdentifier) []string {
+	ring := c.placementRing.Load()
+	if ring == nil {
+		return nil
+	}
+	return ring.owners(identity.String(), c.placementReplicas)
+}
+
+// IsPluginPlacedOnCurrentNode returns true if the current node should run the plugin,
+// plugins are placed nowhere until the members of the cluster are fetched
+func (c *Cluster) IsPluginPlacedOnCurrentNode(identity plugin_entities.PluginUniqueIdentifier) bool {
+	if !c.PlacementEnabled() {
+		return true
+	}
+
+	return slices.Contains(c.pluginOwners(identity), c.id)
+}
+
+// IsPluginRunningOnPlacedNodes returns true if any other node the plugin is placed on is running it
+func (c *Cluster) IsPluginRunningOnPlacedNodes(identity plugin_entities.PluginUniqueIdentifier) (bool, error) {
+	nodes, err := c.FetchPluginAvailableNodesById(identity.String())
+	if err != nil {
+		return false, err
+	}
+
+	owners := c.pluginOwners(identity)
+	for _, nodeId := range nodes {
+		if nodeId != c.id && slices.Contains(owners, nodeId) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
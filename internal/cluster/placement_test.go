@@ -0,0 +1,40 @@
+package cluster
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestHashRingOwners(t *testing.T) {
+	ring := newHashRing([]string{"a", "b", "c"})
+
+	owners := ring.owners("langgenius/openai:0.0.1@checksum", 2)
+	if len(owners) != 2 || owners[0] == owners[1] {
+		t.Fatalf("expected 2 distinct owners, got %v", owners)
+	}
+	if !slices.Equal(owners, ring.owners("langgenius/openai:0.0.1@checksum", 2)) {
+		t.Errorf("expected owners to be stable")
+	}
+
+	if owners := ring.owners("langgenius/openai:0.0.1@checksum", 5); len(owners) != 3 {
+		t.Errorf("expected replicas to be capped by nodes, got %v", owners)
+	}
+
+	if owners := newHashRing([]string{}).owners("langgenius/openai:0.0.1@checksum", 2); len(owners) != 0 {
+		t.Errorf("expected no owners on an empty ring, got %v", owners)
+	}
+}
+
+func TestHashRingMovesOnlyPluginsOfLeavingNode(t *testing.T) {
+	before := newHashRing([]string{"a", "b", "c", "d"})
+	after := newHashRing([]string{"a", "b", "c"})
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("plugin-%d", i)
+		owner := before.owners(key, 1)[0]
+		if owner != "d" && after.owners(key, 1)[0] != owner {
+			t.Fatalf("expected %s to stay on %s", key, owner)
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -29,7 +28,7 @@ func (c *Cluster) RegisterPlugin(lifetime plugin_entities.PluginLifetime) error
 	}
 
 	if c.showLog {
-		log.Info("registering plugin %s", identity.String())
+		logger.Info("registering plugin %s", identity.String())
 	}
 
 	if c.plugins.Exists(identity.String()) {
@@ -62,7 +61,7 @@ func (c *Cluster) RegisterPlugin(lifetime plugin_entities.PluginLifetime) error
 	c.pluginLock.Unlock()
 
 	if c.showLog {
-		log.Info("start to schedule plugin %s", identity)
+		logger.Info("start to schedule plugin %s", identity)
 	}
 
 	return nil
@@ -90,7 +89,7 @@ func (c *Cluster) getScanPluginsByIdKey(plugin_id string) string {
 // but once a plugin is not removed, it will be gc by the master node
 func (c *Cluster) schedulePlugins() error {
 	if c.showLog {
-		log.Info("scheduling %d plugins", c.plugins.Len())
+		logger.Info("scheduling %d plugins", c.plugins.Len())
 	}
 
 	c.notifyPluginSchedule()
@@ -101,23 +100,23 @@ func (c *Cluster) schedulePlugins() error {
 			return true
 		}
 		if c.showLog {
-			log.Info("scheduling plugin %s", key)
+			logger.Info("scheduling plugin %s", key)
 		}
 		// do plugin state update
 		err := c.doPluginStateUpdate(value)
 		if err != nil {
-			log.Error("failed to update plugin state: %s", err.Error())
+			logger.Error("failed to update plugin state: %s", err.Error())
 		}
 
 		if c.showLog {
-			log.Info("scheduled plugin %s", key)
+			logger.Info("scheduled plugin %s", key)
 		}
 
 		return true
 	})
 
 	if c.showLog {
-		log.Info("scheduled %d plugins", c.plugins.Len())
+		logger.Info("scheduled %d plugins", c.plugins.Len())
 	}
 
 	return nil
@@ -132,7 +131,7 @@ func (c *Cluster) doPluginStateUpdate(lifetime *pluginLifeTime) error {
 	}
 
 	if c.showLog {
-		log.Info("updating plugin state %s", identity.String())
+		logger.Info("updating plugin state %s", identity.String())
 	}
 
 	hashedIdentity := plugin_entities.HashedIdentity(identity.String())
@@ -147,7 +146,7 @@ func (c *Cluster) doPluginStateUpdate(lifetime *pluginLifeTime) error {
 	// check if the plugin has been removed
 	if !c.plugins.Exists(identity.String()) {
 		if c.showLog {
-			log.Info("removing plugin state %s due no longer exists", identity.String())
+			logger.Info("removing plugin state %s due no longer exists", identity.String())
 		}
 		// remove state
 		err = c.removePluginState(c.id, hashedIdentity)
@@ -156,7 +155,7 @@ func (c *Cluster) doPluginStateUpdate(lifetime *pluginLifeTime) error {
 		}
 	} else {
 		if c.showLog {
-			log.Info("updating plugin state %s", identity.String())
+			logger.Info("updating plugin state %s", identity.String())
 		}
 		// update plugin state
 		scheduleState.ScheduledAt = &[]time.Time{time.Now()}[0]
@@ -166,7 +165,7 @@ func (c *Cluster) doPluginStateUpdate(lifetime *pluginLifeTime) error {
 		}
 		lifetime.lifetime.UpdateScheduledAt(*scheduleState.ScheduledAt)
 		if c.showLog {
-			log.Info("updated plugin state %s", identity.String())
+			logger.Info("updated plugin state %s", identity.String())
 		}
 	}
 
@@ -177,7 +176,7 @@ func (c *Cluster) doPluginStateUpdate(lifetime *pluginLifeTime) error {
 
 func (c *Cluster) removePluginState(nodeId string, hashed_identity string) error {
 	if c.showLog {
-		log.Info("removing plugin state %s", hashed_identity)
+		logger.Info("removing plugin state %s", hashed_identity)
 	}
 	err := cache.DelMapField(PLUGIN_STATE_MAP_KEY, c.getPluginStateKey(nodeId, hashed_identity))
 	if err != nil {
@@ -185,7 +184,7 @@ func (c *Cluster) removePluginState(nodeId string, hashed_identity string) error
 	}
 
 	if c.showLog {
-		log.Info("plugin %s has been removed from node %s", hashed_identity, c.id)
+		logger.Info("plugin %s has been removed from node %s", hashed_identity, c.id)
 	}
 
 	return nil
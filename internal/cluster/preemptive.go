@@ -57,12 +57,9 @@ func (c *Cluster) lockMaster() (bool, error) {
 	return false, finalError
 }
 
-// update master
-func (c *Cluster) updateMaster() error {
-	// update expired time of master key
-	if _, err := cache.Expire(PREEMPTION_LOCK_KEY, c.masterLockExpiredTime); err != nil {
-		return err
-	}
-
-	return nil
+// update master, returns false if the lock is no longer held by the current node,
+// the lock is only renewed by its holder so that a node recovering from a partition never extends
+// the lock of the node which took over
+func (c *Cluster) updateMaster() (bool, error) {
+	return cache.ExpireIfEqual(PREEMPTION_LOCK_KEY, c.id, c.masterLockExpiredTime)
 }
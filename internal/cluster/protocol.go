@@ -0,0 +1,83 @@
+package cluster
+
+// Nodes of different versions coexist while a cluster is upgraded node by node, so each node publishes
+// the version of the cluster protocol it speaks and the oldest version it works with along with its status.
+//
+// Data shared in redis only gets new optional fields, which are ignored by older nodes and missing from
+// the data of older nodes. Once a change is not compatible, CLUSTER_PROTOCOL_VERSION is bumped and the
+// new behavior is only used with nodes supporting it, nodes out of the compatible range are neither
+// routed to nor placed plugins on.
+//
+// Nodes without a version are considered version 1.
+
+const (
+	CLUSTER_PROTOCOL_VERSION = 2
+	// MIN_CLUSTER_PROTOCOL_VERSION is the oldest version of nodes the current node works with
+	MIN_CLUSTER_PROTOCOL_VERSION = 1
+
+	// nodes consume session events relayed from other nodes since version 2
+	PROTOCOL_VERSION_SESSION_RELAY = 2
+)
+
+func (n *node) protocolVersion() int {
+	if n.ProtocolVersion == 0 {
+		return 1
+	}
+	return n.ProtocolVersion
+}
+
+func (n *node) minProtocolVersion() int {
+	if n.MinProtocolVersion == 0 {
+		return 1
+	}
+	return n.MinProtocolVersion
+}
+
+// protocolCompatible returns true if nodes speaking the two ranges of versions work with each other
+func protocolCompatible(version int, minVersion int, peerVersion int, peerMinVersion int) bool {
+	return peerVersion >= minVersion && version >= peerMinVersion
+}
+
+func (c *Cluster) isNodeCompatible(nodeId string) bool {
+	if nodeId == c.id {
+		return true
+	}
+
+	node, ok := c.nodes.Load(nodeId)
+	if !ok {
+		return false
+	}
+	return protocolCompatible(
+		CLUSTER_PROTOCOL_VERSION, MIN_CLUSTER_PROTOCOL_VERSION, node.protocolVersion(), node.minProtocolVersion(),
+	)
+}
+
+// NodeSupports returns true if the node speaks the version of the protocol
+func (c *Cluster) NodeSupports(nodeId string, version int) bool {
+	if nodeId == c.id {
+		return CLUSTER_PROTOCOL_VERSION >= version
+	}
+
+	node, ok := c.nodes.Load(nodeId)
+	return ok && node.protocolVersion() >= version
+}
+
+// warnIncompatibleNodes logs nodes the current node doesn't work with once they join
+func (c *Cluster) warnIncompatibleNodes() {
+	c.nodes.Range(func(nodeId string, node node) bool {
+		if c.isNodeCompatible(nodeId) {
+			c.incompatibleNodes.Delete(nodeId)
+			return true
+		}
+
+		if _, warned := c.incompatibleNodes.LoadOrStore(nodeId, true); !warned {
+			logger.Warn(
+				"node %s speaks cluster protocol %d-%d which is incompatible with %d-%d of the current node, "+
+					"requests won't be routed to it",
+				nodeId, node.minProtocolVersion(), node.protocolVersion(),
+				MIN_CLUSTER_PROTOCOL_VERSION, CLUSTER_PROTOCOL_VERSION,
+			)
+		}
+		return true
+	})
+}
@@ -0,0 +1,33 @@
+package cluster
+
+import "testing"
+
+func TestProtocolCompatible(t *testing.T) {
+	cases := []struct {
+		name           string
+		version        int
+		minVersion     int
+		peerVersion    int
+		peerMinVersion int
+		expected       bool
+	}{
+		{"same version", 2, 1, 2, 1, true},
+		{"older peer within range", 3, 2, 2, 1, true},
+		{"newer peer within range", 2, 1, 3, 2, true},
+		{"peer too old", 3, 3, 2, 1, false},
+		{"current node too old for peer", 2, 1, 4, 3, false},
+	}
+
+	for _, c := range cases {
+		if compatible := protocolCompatible(c.version, c.minVersion, c.peerVersion, c.peerMinVersion); compatible != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, compatible)
+		}
+	}
+}
+
+func TestNodeWithoutProtocolVersion(t *testing.T) {
+	n := node{}
+	if n.protocolVersion() != 1 || n.minProtocolVersion() != 1 {
+		t.Errorf("expected nodes without versions to speak version 1, got %d-%d", n.minProtocolVersion(), n.protocolVersion())
+	}
+}
@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"sort"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+const (
+	// CLUSTER_RECONCILIATION_HASH_MAP_KEY stores the last reconciliation report of each node
+	CLUSTER_RECONCILIATION_HASH_MAP_KEY = "cluster-plugin-reconciliation-hash-map"
+)
+
+// reconcilePlugins makes the current node run exactly the installed plugins placed on it and reports the drift
+func (c *Cluster) reconcilePlugins() error {
+	report, err := c.manager.ReconcileLocalPlugins(false)
+	if err != nil {
+		return err
+	}
+	report.NodeID = c.id
+
+	if report.Drifted() {
+		logger.Info(
+			"reconciled plugins of the node, %d missing and %d orphaned",
+			len(report.Missing), len(report.Orphaned),
+		)
+	}
+
+	return cache.SetMapOneField(CLUSTER_RECONCILIATION_HASH_MAP_KEY, c.id, report)
+}
+
+func (c *Cluster) startReconciler() {
+	go func() {
+		ticker := time.NewTicker(c.reconcileInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.reconcilePlugins(); err != nil {
+					logger.Error("failed to reconcile plugins of the node: %s", err.Error())
+				}
+			case <-c.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// ListReconciliationReports returns the last reconciliation reports of nodes alive in the cluster
+func (c *Cluster) ListReconciliationReports() ([]plugin_manager.ReconciliationReport, error) {
+	reports, err := cache.GetMap[plugin_manager.ReconciliationReport](CLUSTER_RECONCILIATION_HASH_MAP_KEY)
+	if err == cache.ErrNotFound {
+		return []plugin_manager.ReconciliationReport{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := make([]plugin_manager.ReconciliationReport, 0, len(reports))
+	for nodeId, report := range reports {
+		if c.IsNodeAlive(nodeId) {
+			result = append(result, report)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NodeID < result[j].NodeID
+	})
+	return result, nil
+}
@@ -4,6 +4,11 @@ import (
 	"errors"
 	"io"
 	"net/http"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RedirectRequest redirects the request to the specified node
@@ -22,10 +27,16 @@ func (c *Cluster) RedirectRequest(
 
 	ip := ips[0]
 
-	// create a new request
-	redirectedRequest, err := http.NewRequest(
+	// create a new request, the span of the redirect is propagated to the node
+	ctx, span := tracing.Start(request.Context(), "cluster.redirect", trace.SpanKindClient,
+		attribute.String("cluster.node_id", node_id),
+	)
+	defer span.End()
+
+	redirectedRequest, err := http.NewRequestWithContext(
+		ctx,
 		request.Method,
-		"http://"+ip.fullAddress()+request.URL.Path,
+		c.scheme+"://"+ip.fullAddress()+request.URL.Path,
 		request.Body,
 	)
 
@@ -40,12 +51,16 @@ func (c *Cluster) RedirectRequest(
 		}
 	}
 
-	client := http.DefaultClient
-	resp, err := client.Do(redirectedRequest)
+	tracing.Inject(ctx, propagation.HeaderCarrier(redirectedRequest.Header))
+
+	resp, err := c.client.Do(redirectedRequest)
 
 	if err != nil {
+		tracing.Fail(span, err)
 		return 0, nil, nil, err
 	}
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
 	return resp.StatusCode, resp.Header, resp.Body, nil
 }
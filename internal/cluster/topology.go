@@ -0,0 +1,142 @@
+package cluster
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+const (
+	// CLUSTER_DRAINING_NODES_HASH_MAP_KEY stores nodes requested to drain, a node stops taking new sessions
+	// once it sees itself in the map, restarted nodes join with a new id so they're never drained
+	CLUSTER_DRAINING_NODES_HASH_MAP_KEY = "cluster-draining-nodes-hash-map"
+)
+
+var (
+	ErrNodeNotFound = errors.New("node not found")
+)
+
+type drainRequest struct {
+	RequestedAt int64 `json:"requested_at"`
+}
+
+// NodeStatus is the status of a node reported to operators
+type NodeStatus struct {
+	ID        string       `json:"id"`
+	Version   string       `json:"version"`
+	Role      app.NodeRole `json:"role"`
+	Master    bool         `json:"master"`
+	Addresses []string     `json:"addresses"`
+	StartedAt time.Time    `json:"started_at"`
+	// Uptime is in seconds
+	Uptime     int64     `json:"uptime"`
+	Plugins    int       `json:"plugins"`
+	Sessions   int       `json:"sessions"`
+	Draining   bool      `json:"draining"`
+	AtCapacity bool      `json:"at_capacity"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// versions of the cluster protocol, see CLUSTER_PROTOCOL_VERSION
+	ProtocolVersion    int   `json:"protocol_version"`
+	MinProtocolVersion int   `json:"min_protocol_version"`
+	Compatible         bool  `json:"compatible"`
+	Epoch              int64 `json:"epoch"`
+}
+
+// ListNodes returns status of the nodes alive in the cluster, the status is published by each node
+// every $UPDATE_NODE_STATUS_INTERVAL, so it may be a few seconds behind
+func (c *Cluster) ListNodes() ([]NodeStatus, error) {
+	nodes, err := c.GetNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	// the lock is stored by SetNX which encodes the value
+	master := ""
+	if lockedBy, err := cache.Get[string](PREEMPTION_LOCK_KEY); err == nil {
+		master = *lockedBy
+	} else if err != cache.ErrNotFound {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := make([]NodeStatus, 0, len(nodes))
+	for nodeId, node := range nodes {
+		status := NodeStatus{
+			ID:         nodeId,
+			Version:    node.Version,
+			Role:       node.Role,
+			Master:     nodeId == master,
+			Addresses:  []string{},
+			Draining:   node.Draining,
+			LastSeenAt: time.Unix(node.LastPingAt, 0),
+			Epoch:      node.Epoch,
+
+			ProtocolVersion:    node.protocolVersion(),
+			MinProtocolVersion: node.minProtocolVersion(),
+			Compatible: protocolCompatible(
+				CLUSTER_PROTOCOL_VERSION, MIN_CLUSTER_PROTOCOL_VERSION,
+				node.protocolVersion(), node.minProtocolVersion(),
+			),
+		}
+		for _, address := range c.SortIps(node) {
+			status.Addresses = append(status.Addresses, address.fullAddress())
+		}
+		if node.StartedAt > 0 {
+			status.StartedAt = time.Unix(node.StartedAt, 0)
+			status.Uptime = int64(now.Sub(status.StartedAt).Seconds())
+		}
+		if node.Load != nil {
+			status.Plugins = node.Load.Plugins
+			status.Sessions = node.Load.Sessions
+			status.AtCapacity = node.Load.AtCapacity
+		}
+		result = append(result, status)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+	return result, nil
+}
+
+// DrainNode stops the node from taking new sessions, sessions of plugins running on other nodes are
+// redirected to them while ongoing sessions are kept until they end, the node is drained once its
+// sessions reach 0
+func (c *Cluster) DrainNode(nodeId string) error {
+	if !c.IsNodeAlive(nodeId) {
+		return ErrNodeNotFound
+	}
+
+	if err := cache.SetMapOneField(CLUSTER_DRAINING_NODES_HASH_MAP_KEY, nodeId, drainRequest{
+		RequestedAt: time.Now().Unix(),
+	}); err != nil {
+		return err
+	}
+
+	if nodeId == c.id {
+		c.draining.Store(true)
+	}
+	return nil
+}
+
+// IsDraining returns true if the current node is requested to drain
+func (c *Cluster) IsDraining() bool {
+	return c.draining.Load()
+}
+
+// syncDraining loads the drain request of the current node
+func (c *Cluster) syncDraining() error {
+	_, err := cache.GetMapField[drainRequest](CLUSTER_DRAINING_NODES_HASH_MAP_KEY, c.id)
+	if err == cache.ErrNotFound {
+		c.draining.Store(false)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	c.draining.Store(true)
+	return nil
+}
@@ -0,0 +1,177 @@
+// Package admin_auth authenticates human operators of the admin apis by single sign-on, apart from the server key
+// shared by machines. Operators send OIDC id tokens as bearer tokens or LDAP users by basic auth, and they're
+// granted the highest role mapped from their groups, roles grant the scopes of api tokens to them.
+package admin_auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/api_token"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+type Role string
+
+const (
+	// ROLE_VIEWER reads states of the daemon
+	ROLE_VIEWER Role = "viewer"
+	// ROLE_OPERATOR changes settings of the daemon as well, like log levels, slos and sessions
+	ROLE_OPERATOR Role = "operator"
+	// ROLE_ADMIN is granted all scopes, including managing api tokens
+	ROLE_ADMIN Role = "admin"
+
+	METHOD_OIDC = "oidc"
+	METHOD_LDAP = "ldap"
+
+	// GROUP_ANY matches all operators signed in
+	GROUP_ANY = "*"
+)
+
+var roleRanks = map[Role]int{
+	ROLE_VIEWER:   1,
+	ROLE_OPERATOR: 2,
+	ROLE_ADMIN:    3,
+}
+
+var roleScopes = map[Role][]api_token.Scope{
+	ROLE_VIEWER:   {api_token.SCOPE_ADMIN_READ, api_token.SCOPE_PLUGINS_READ},
+	ROLE_OPERATOR: {api_token.SCOPE_ADMIN_READ, api_token.SCOPE_ADMIN_WRITE, api_token.SCOPE_PLUGINS_READ},
+}
+
+var (
+	ErrUnauthenticated = errors.New("invalid credentials of operator")
+	ErrNoRole          = errors.New("operator is granted no role")
+)
+
+// Granted returns true if the role grants the scope
+func (r Role) Granted(scope api_token.Scope) bool {
+	if r == ROLE_ADMIN {
+		return true
+	}
+	return slices.Contains(roleScopes[r], scope)
+}
+
+// Identity is an operator signed in
+type Identity struct {
+	Method string `json:"method"`
+	// Subject is the subject of the id token or the dn of the ldap user
+	Subject string   `json:"subject"`
+	Name    string   `json:"name"`
+	Groups  []string `json:"groups"`
+	Role    Role     `json:"role"`
+}
+
+// roleMapping maps groups to roles
+type roleMapping map[string]Role
+
+// parseRoleMapping parses entries like `dify-admins=admin`
+func parseRoleMapping(entries []string) (roleMapping, error) {
+	mapping := roleMapping{}
+	for _, entry := range entries {
+		group, role, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		group = strings.TrimSpace(group)
+		role = strings.TrimSpace(role)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid role mapping %s, it should be like group=role", entry)
+		}
+		if _, ok := roleRanks[Role(role)]; !ok {
+			return nil, fmt.Errorf("invalid role %s, it should be one of viewer, operator and admin", role)
+		}
+		mapping[group] = Role(role)
+	}
+	return mapping, nil
+}
+
+// resolve returns the highest role of the groups, empty if none of them is mapped
+func (m roleMapping) resolve(groups []string) Role {
+	role := m[GROUP_ANY]
+	for _, group := range groups {
+		if mapped, ok := m[group]; ok && roleRanks[mapped] > roleRanks[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+type authenticator struct {
+	oidc  *oidcVerifier
+	ldap  *ldapAuthenticator
+	roles roleMapping
+}
+
+var current *authenticator
+
+func Init(config *app.Config) {
+	if config.AdminAuthOIDCIssuer == "" && config.AdminAuthLDAPURL == "" {
+		return
+	}
+
+	roles, err := parseRoleMapping(config.AdminAuthRoleMapping)
+	if err != nil {
+		log.Panic("init admin auth failed: %s", err.Error())
+	}
+
+	a := &authenticator{roles: roles}
+	if config.AdminAuthOIDCIssuer != "" {
+		a.oidc = newOIDCVerifier(config.AdminAuthOIDCIssuer, config.AdminAuthOIDCClientID, config.AdminAuthOIDCGroupsClaim)
+		log.Info("operators are authenticated by oidc of %s", config.AdminAuthOIDCIssuer)
+	}
+	if config.AdminAuthLDAPURL != "" {
+		a.ldap = newLDAPAuthenticator(config)
+		log.Info("operators are authenticated by ldap of %s", config.AdminAuthLDAPURL)
+	}
+	current = a
+}
+
+// Enabled returns true if operators are able to sign in by oidc or ldap
+func Enabled() bool {
+	return current != nil
+}
+
+// Authenticate returns the operator of the Authorization header, ErrUnauthenticated is returned if the
+// credentials are invalid and ErrNoRole if they're valid but none of the groups is mapped to a role
+func Authenticate(ctx context.Context, authorization string) (*Identity, error) {
+	if current == nil {
+		return nil, ErrUnauthenticated
+	}
+	return current.authenticate(ctx, authorization)
+}
+
+func (a *authenticator) authenticate(ctx context.Context, authorization string) (*Identity, error) {
+	scheme, credentials, _ := strings.Cut(authorization, " ")
+	credentials = strings.TrimSpace(credentials)
+
+	var identity *Identity
+	var err error
+	switch {
+	case strings.EqualFold(scheme, "Bearer") && a.oidc != nil:
+		identity, err = a.oidc.verify(ctx, credentials)
+	case strings.EqualFold(scheme, "Basic") && a.ldap != nil:
+		decoded, decodeErr := base64.StdEncoding.DecodeString(credentials)
+		if decodeErr != nil {
+			return nil, ErrUnauthenticated
+		}
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil, ErrUnauthenticated
+		}
+		identity, err = a.ldap.authenticate(username, password)
+	default:
+		return nil, ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identity.Role = a.roles.resolve(identity.Groups)
+	if identity.Role == "" {
+		return nil, ErrNoRole
+	}
+	return identity, nil
+}
@@ -0,0 +1,218 @@
+package admin_auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/api_token"
+)
+
+func TestRoleMapping(t *testing.T) {
+	mapping, err := parseRoleMapping([]string{"dify-admins=admin", " sre = operator", "*=viewer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		groups []string
+		role   Role
+	}{
+		{nil, ROLE_VIEWER},
+		{[]string{"sre"}, ROLE_OPERATOR},
+		{[]string{"sre", "dify-admins"}, ROLE_ADMIN},
+	}
+	for _, c := range cases {
+		if role := mapping.resolve(c.groups); role != c.role {
+			t.Fatalf("expected role %s of %v, got %s", c.role, c.groups, role)
+		}
+	}
+
+	delete(mapping, GROUP_ANY)
+	if role := mapping.resolve([]string{"others"}); role != "" {
+		t.Fatalf("unmapped groups should be granted no role, got %s", role)
+	}
+
+	for _, invalid := range []string{"admins", "=admin", "admins=root"} {
+		if _, err := parseRoleMapping([]string{invalid}); err == nil {
+			t.Fatalf("invalid mapping %s should be rejected", invalid)
+		}
+	}
+
+	if !ROLE_VIEWER.Granted(api_token.SCOPE_ADMIN_READ) || ROLE_VIEWER.Granted(api_token.SCOPE_ADMIN_WRITE) ||
+		ROLE_OPERATOR.Granted(api_token.SCOPE_TOKENS_MANAGE) || !ROLE_ADMIN.Granted(api_token.SCOPE_TOKENS_MANAGE) {
+		t.Fatal("unexpected scopes of roles")
+	}
+}
+
+type testIssuer struct {
+	server *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+	encode := func(n *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(n.Bytes())
+	}
+	issuer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(ProviderMetadata{Issuer: issuer.server.URL, JWKSURI: issuer.server.URL + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]any{"keys": []jwk{
+				{Kty: "RSA", Kid: "rsa", Use: "sig", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+				{Kty: "EC", Kid: "ec", Crv: "P-256", X: encode(ecKey.X), Y: encode(ecKey.Y)},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) sign(t *testing.T, alg string, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(jwtHeader{Alg: alg, Kid: kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		signature = make([]byte, 64)
+		if err == nil {
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// tamper replaces the claims of the token and keeps its signature
+func tamper(token string, claims map[string]any) string {
+	parts := strings.Split(token, ".")
+	payload, _ := json.Marshal(claims)
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+}
+
+func TestOIDCAuthentication(t *testing.T) {
+	issuer := newTestIssuer(t)
+	mapping, _ := parseRoleMapping([]string{"dify-admins=admin", "sre=operator"})
+	a := &authenticator{
+		oidc:  newOIDCVerifier(issuer.server.URL, "daemon", "groups"),
+		roles: mapping,
+	}
+
+	claims := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{
+			"iss":                issuer.server.URL,
+			"sub":                "user-1",
+			"aud":                []string{"daemon", "console"},
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"preferred_username": "alex",
+			"groups":             []string{"sre"},
+		}
+		for key, value := range overrides {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	identity, err := a.authenticate(context.Background(), "Bearer "+issuer.sign(t, "RS256", "rsa", claims(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Method != METHOD_OIDC || identity.Subject != "user-1" || identity.Name != "alex" || identity.Role != ROLE_OPERATOR {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	identity, err = a.authenticate(context.Background(), "bearer "+issuer.sign(t, "ES256", "ec", claims(map[string]any{
+		"aud":    "daemon",
+		"groups": "dify-admins others",
+	})))
+	if err != nil || identity.Role != ROLE_ADMIN {
+		t.Fatalf("tokens signed by ec keys with groups in a string should be accepted, got %+v, %v", identity, err)
+	}
+
+	rejected := map[string]string{
+		"wrong audience":  issuer.sign(t, "RS256", "rsa", claims(map[string]any{"aud": "console"})),
+		"wrong issuer":    issuer.sign(t, "RS256", "rsa", claims(map[string]any{"iss": "https://evil.example.com"})),
+		"expired":         issuer.sign(t, "RS256", "rsa", claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"unknown key":     issuer.sign(t, "RS256", "unknown", claims(nil)),
+		"mismatched key":  issuer.sign(t, "RS256", "ec", claims(nil)),
+		"tampered claims": tamper(issuer.sign(t, "RS256", "rsa", claims(nil)), claims(map[string]any{"groups": "dify-admins"})),
+		"malformed":       "not-a-token",
+	}
+	for name, token := range rejected {
+		if _, err := a.authenticate(context.Background(), "Bearer "+token); !errors.Is(err, ErrUnauthenticated) {
+			t.Fatalf("%s: expected unauthenticated, got %v", name, err)
+		}
+	}
+
+	header, _ := json.Marshal(jwtHeader{Alg: "none", Kid: "rsa"})
+	payload, _ := json.Marshal(claims(nil))
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+	if _, err := a.authenticate(context.Background(), "Bearer "+unsigned); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("unsigned tokens should be rejected, got %v", err)
+	}
+
+	token := issuer.sign(t, "RS256", "rsa", claims(map[string]any{"groups": []string{"others"}}))
+	if _, err := a.authenticate(context.Background(), "Bearer "+token); !errors.Is(err, ErrNoRole) {
+		t.Fatalf("operators of unmapped groups should be granted no role, got %v", err)
+	}
+
+	if _, err := a.authenticate(context.Background(), "Basic YWxleDpwYXNzd29yZA=="); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("basic auth should be rejected without ldap, got %v", err)
+	}
+}
+
+func TestLDAPGroups(t *testing.T) {
+	groups := ldapGroups([]string{"cn=dify-admins,ou=groups,dc=example,dc=com", "sre"})
+	expected := []string{"cn=dify-admins,ou=groups,dc=example,dc=com", "dify-admins", "sre"}
+	if len(groups) != len(expected) {
+		t.Fatalf("expected groups %v, got %v", expected, groups)
+	}
+	for i := range expected {
+		if groups[i] != expected[i] {
+			t.Fatalf("expected groups %v, got %v", expected, groups)
+		}
+	}
+
+	a := &ldapAuthenticator{cache: map[string]ldapCacheEntry{}}
+	if _, err := a.authenticate("alex", ""); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("empty passwords should be rejected before binding, got %v", err)
+	}
+}
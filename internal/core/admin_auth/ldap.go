@@ -0,0 +1,145 @@
+package admin_auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+const (
+	ldapTimeout = 10 * time.Second
+	// operators signed in are cached for a while, so consoles polling the apis don't bind for each request
+	ldapCacheTTL = time.Minute
+)
+
+type ldapAuthenticator struct {
+	url            string
+	bindDN         string
+	bindPassword   string
+	baseDN         string
+	userFilter     string
+	groupAttribute string
+
+	mu    sync.Mutex
+	cache map[string]ldapCacheEntry
+}
+
+type ldapCacheEntry struct {
+	identity  Identity
+	expiresAt time.Time
+}
+
+func newLDAPAuthenticator(config *app.Config) *ldapAuthenticator {
+	return &ldapAuthenticator{
+		url:            config.AdminAuthLDAPURL,
+		bindDN:         config.AdminAuthLDAPBindDN,
+		bindPassword:   config.AdminAuthLDAPBindPassword,
+		baseDN:         config.AdminAuthLDAPBaseDN,
+		userFilter:     config.AdminAuthLDAPUserFilter,
+		groupAttribute: config.AdminAuthLDAPGroupAttribute,
+		cache:          map[string]ldapCacheEntry{},
+	}
+}
+
+// authenticate searches the user by the service account and binds as the user with the password
+func (a *ldapAuthenticator) authenticate(username string, password string) (*Identity, error) {
+	// binds with empty passwords are unauthenticated binds, which succeed on most servers
+	if username == "" || password == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	// the password is part of the key, so changed passwords never hit stale entries
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+	cacheKey := hex.EncodeToString(sum[:])
+	a.mu.Lock()
+	entry, ok := a.cache[cacheKey]
+	a.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		identity := entry.identity
+		return &identity, nil
+	}
+
+	identity, err := a.bind(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	for key, entry := range a.cache {
+		if time.Now().After(entry.expiresAt) {
+			delete(a.cache, key)
+		}
+	}
+	a.cache[cacheKey] = ldapCacheEntry{identity: *identity, expiresAt: time.Now().Add(ldapCacheTTL)}
+	a.mu.Unlock()
+
+	return identity, nil
+}
+
+func (a *ldapAuthenticator) bind(username string, password string) (*Identity, error) {
+	conn, err := ldap.DialURL(a.url, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return nil, fmt.Errorf("connect ldap failed: %w", err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(ldapTimeout)
+
+	if a.bindDN != "" {
+		if err := conn.Bind(a.bindDN, a.bindPassword); err != nil {
+			return nil, fmt.Errorf("bind ldap service account failed: %w", err)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		strings.ReplaceAll(a.userFilter, "%s", ldap.EscapeFilter(username)),
+		[]string{a.groupAttribute}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("search ldap user failed: %w", err)
+	}
+	// ambiguous users are rejected rather than guessed
+	if len(result.Entries) != 1 {
+		return nil, ErrUnauthenticated
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrUnauthenticated
+		}
+		return nil, fmt.Errorf("bind ldap user failed: %w", err)
+	}
+
+	return &Identity{
+		Method:  METHOD_LDAP,
+		Subject: entry.DN,
+		Name:    username,
+		Groups:  ldapGroups(entry.GetAttributeValues(a.groupAttribute)),
+	}, nil
+}
+
+// ldapGroups returns the groups as they are along with their common names, so mappings name groups by either
+func ldapGroups(values []string) []string {
+	groups := []string{}
+	for _, value := range values {
+		groups = append(groups, value)
+		dn, err := ldap.ParseDN(value)
+		if err != nil || len(dn.RDNs) == 0 {
+			continue
+		}
+		for _, attribute := range dn.RDNs[0].Attributes {
+			if strings.EqualFold(attribute.Type, "cn") {
+				groups = append(groups, attribute.Value)
+			}
+		}
+	}
+	return groups
+}
@@ -0,0 +1,345 @@
+package admin_auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keys of the issuer are refetched once they're older than keysTTL, or at most once per keysMinRefetch
+	// if a token is signed by an unknown key, issuers rotate keys by publishing new ones beforehand
+	keysTTL        = time.Hour
+	keysMinRefetch = time.Minute
+
+	// clocks of the issuer and the daemon may drift apart
+	clockSkew = time.Minute
+
+	discoveryTimeout = 10 * time.Second
+)
+
+// ProviderMetadata is the discovery document of an OIDC issuer
+type ProviderMetadata struct {
+	Issuer                      string `json:"issuer"`
+	JWKSURI                     string `json:"jwks_uri"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+}
+
+// Discover fetches the discovery document of the issuer
+func Discover(ctx context.Context, client *http.Client, issuer string) (*ProviderMetadata, error) {
+	metadata := ProviderMetadata{}
+	if err := getJSON(ctx, client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("discover oidc issuer failed: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, fmt.Errorf("oidc issuer %s doesn't match the discovered one %s", issuer, metadata.Issuer)
+	}
+	return &metadata, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, target any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d of %s", response.StatusCode, url)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+// oidcVerifier verifies id tokens issued for the client by the issuer
+type oidcVerifier struct {
+	issuer      string
+	clientID    string
+	groupsClaim string
+	client      *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newOIDCVerifier(issuer string, clientID string, groupsClaim string) *oidcVerifier {
+	return &oidcVerifier{
+		issuer:      strings.TrimSuffix(issuer, "/"),
+		clientID:    clientID,
+		groupsClaim: groupsClaim,
+		client:      &http.Client{Timeout: discoveryTimeout},
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer            string          `json:"iss"`
+	Subject           string          `json:"sub"`
+	Audience          json.RawMessage `json:"aud"`
+	ExpiresAt         int64           `json:"exp"`
+	NotBefore         int64           `json:"nbf"`
+	PreferredUsername string          `json:"preferred_username"`
+	Email             string          `json:"email"`
+	Name              string          `json:"name"`
+}
+
+var signingHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+func (v *oidcVerifier) verify(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrUnauthenticated
+	}
+
+	header := jwtHeader{}
+	if decodeSegment(parts[0], &header) != nil {
+		return nil, ErrUnauthenticated
+	}
+	// algorithms are pinned to asymmetric ones, `none` and HMAC keyed by public keys are rejected
+	hash, ok := signingHashes[header.Alg]
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := hash.New()
+	digest.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(key, header.Alg, hash, digest.Sum(nil), signature) {
+		return nil, ErrUnauthenticated
+	}
+
+	claims := jwtClaims{}
+	if decodeSegment(parts[1], &claims) != nil {
+		return nil, ErrUnauthenticated
+	}
+	now := time.Now()
+	if strings.TrimSuffix(claims.Issuer, "/") != v.issuer || claims.Subject == "" ||
+		!containsAudience(claims.Audience, v.clientID) ||
+		claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) ||
+		(claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0))) {
+		return nil, ErrUnauthenticated
+	}
+
+	raw := map[string]any{}
+	if decodeSegment(parts[1], &raw) != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	name := claims.PreferredUsername
+	if name == "" {
+		name = claims.Email
+	}
+	if name == "" {
+		name = claims.Name
+	}
+	if name == "" {
+		name = claims.Subject
+	}
+	return &Identity{
+		Method:  METHOD_OIDC,
+		Subject: claims.Subject,
+		Name:    name,
+		Groups:  stringsOfClaim(raw[v.groupsClaim]),
+	}, nil
+}
+
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// containsAudience checks `aud` which is a string or an array of strings
+func containsAudience(audience json.RawMessage, clientID string) bool {
+	var single string
+	if json.Unmarshal(audience, &single) == nil {
+		return single == clientID
+	}
+	var multiple []string
+	if json.Unmarshal(audience, &multiple) == nil {
+		return slices.Contains(multiple, clientID)
+	}
+	return false
+}
+
+// stringsOfClaim reads claims of groups which are arrays of strings, or a string separated by spaces
+func stringsOfClaim(claim any) []string {
+	switch claim := claim.(type) {
+	case string:
+		return strings.Fields(claim)
+	case []any:
+		values := []string{}
+		for _, value := range claim {
+			if value, ok := value.(string); ok {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func verifySignature(key crypto.PublicKey, alg string, hash crypto.Hash, digest []byte, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// key returns the key of the issuer by its id, keys are refetched if it's unknown
+func (v *oidcVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[kid]
+	if ok && time.Since(v.fetchedAt) < keysTTL {
+		return key, nil
+	}
+	if !ok && v.keys != nil && time.Since(v.fetchedAt) < keysMinRefetch {
+		return nil, ErrUnauthenticated
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		if ok {
+			// keys fetched before are still trusted while the issuer is unreachable
+			return key, nil
+		}
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	if key, ok = keys[kid]; !ok {
+		return nil, ErrUnauthenticated
+	}
+	return key, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *oidcVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+
+	metadata, err := Discover(ctx, v.client, v.issuer)
+	if err != nil {
+		return nil, err
+	}
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := getJSON(ctx, v.client, metadata.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch keys of oidc issuer failed: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// keys of unsupported types are skipped, tokens signed by them are rejected
+		if key, err := parseJWK(k); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func parseJWK(k jwk) (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(data) == 0 {
+			return nil, errors.New("invalid key")
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("invalid key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
@@ -0,0 +1,177 @@
+// Package api_token authenticates callers of the apis by scoped tokens, apart from the server key
+// which is granted all scopes. Tokens are stored hashed, they're cached on each node for a short while
+// so revoked tokens are rejected by all nodes within tokenCacheTTL. Keys matching no token are cached as
+// well, and once too many of them are looked up within a minute, keys not cached are rejected without
+// looking them up until the minute ends, so that guessing keys doesn't flood the database.
+package api_token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"gorm.io/gorm"
+)
+
+type Scope string
+
+const (
+	SCOPE_PLUGINS_READ     Scope = "plugins:read"
+	SCOPE_PLUGINS_INSTALL  Scope = "plugins:install"
+	SCOPE_PLUGINS_MANAGE   Scope = "plugins:manage"
+	SCOPE_PLUGINS_INVOKE   Scope = "plugins:invoke"
+	SCOPE_PLUGINS_DEBUG    Scope = "plugins:debug"
+	SCOPE_ENDPOINTS_MANAGE Scope = "endpoints:manage"
+	SCOPE_TOOLS_INVOKE     Scope = "tools:invoke"
+	SCOPE_ADMIN_READ       Scope = "admin:read"
+	SCOPE_ADMIN_WRITE      Scope = "admin:write"
+	// SCOPE_ALL grants all scopes above
+	SCOPE_ALL Scope = "*"
+
+	// SCOPE_TOKENS_MANAGE is never granted to tokens, tokens are managed by the server key only
+	// so that no token grants scopes it doesn't have
+	SCOPE_TOKENS_MANAGE Scope = "tokens:manage"
+)
+
+// Scopes are the ones able to be granted to tokens
+var Scopes = []Scope{
+	SCOPE_PLUGINS_READ,
+	SCOPE_PLUGINS_INSTALL,
+	SCOPE_PLUGINS_MANAGE,
+	SCOPE_PLUGINS_INVOKE,
+	SCOPE_PLUGINS_DEBUG,
+	SCOPE_ENDPOINTS_MANAGE,
+	SCOPE_TOOLS_INVOKE,
+	SCOPE_ADMIN_READ,
+	SCOPE_ADMIN_WRITE,
+	SCOPE_ALL,
+}
+
+const (
+	TOKEN_PREFIX = "dpt_"
+
+	tokenCacheTTL  = 30 * time.Second
+	tokenCacheSize = 4096
+	// keys matching no token looked up by each node within a minute
+	failedLookupLimit = 600
+	// last used time is written at most once per interval for each token
+	touchInterval = time.Minute
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid api token")
+	ErrTooManyLookups = errors.New("too many invalid api tokens, try again later")
+)
+
+// Granted returns true if the scopes grant the scope
+func Granted(scopes []string, scope Scope) bool {
+	if scope == SCOPE_TOKENS_MANAGE {
+		return false
+	}
+	return slices.Contains(scopes, string(SCOPE_ALL)) || slices.Contains(scopes, string(scope))
+}
+
+// ValidScope returns true if the scope is able to be granted to tokens
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, Scope(scope))
+}
+
+// IsToken returns true if the key looks like an api token rather than the server key
+func IsToken(key string) bool {
+	return strings.HasPrefix(key, TOKEN_PREFIX)
+}
+
+// Generate returns a new token along with its hash and the prefix to tell it apart
+func Generate() (token string, hash string, prefix string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	token = TOKEN_PREFIX + hex.EncodeToString(buf)
+	return token, Hash(token), token[:len(TOKEN_PREFIX)+8], nil
+}
+
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type cachedToken struct {
+	token *models.APIToken // nil if the token doesn't exist
+}
+
+var (
+	// cache is keyed by hashes of keys, the least recently used ones are evicted once it's full
+	cache = expirable.NewLRU[string, cachedToken](tokenCacheSize, nil, tokenCacheTTL)
+
+	failedLookups = newRateLimiter()
+
+	touchedAt sync.Map // token id -> time.Time
+)
+
+// Authenticate returns the token if it's valid, neither expired nor revoked, ErrTooManyLookups is
+// returned instead of looking the key up once too many invalid keys are looked up recently
+func Authenticate(key string) (*models.APIToken, error) {
+	hash := Hash(key)
+	now := time.Now()
+
+	cached, ok := cache.Get(hash)
+	if !ok {
+		if failedLookups.exhausted("", failedLookupLimit) {
+			return nil, ErrTooManyLookups
+		}
+
+		token, err := db.GetOne[models.APIToken](db.Equal("token_hash", hash))
+		if err != nil && err != db.ErrDatabaseNotFound {
+			return nil, err
+		}
+
+		if err == nil {
+			cached.token = &token
+		} else {
+			failedLookups.allow("", failedLookupLimit)
+		}
+		cache.Add(hash, cached)
+	}
+
+	token := cached.token
+	if token == nil || token.Revoked || (token.ExpiresAt != nil && token.ExpiresAt.Before(now)) {
+		return nil, ErrInvalidToken
+	}
+
+	touch(token.ID, now)
+	return token, nil
+}
+
+// Invalidate drops the token from the cache of this node, other nodes drop it within tokenCacheTTL
+func Invalidate(hash string) {
+	cache.Remove(hash)
+}
+
+func touch(id string, now time.Time) {
+	if last, ok := touchedAt.Load(id); ok && now.Sub(last.(time.Time)) < touchInterval {
+		return
+	}
+	touchedAt.Store(id, now)
+
+	routine.Submit(map[string]string{
+		"module":   "api_token",
+		"function": "touch",
+	}, func() {
+		if err := db.WithTransaction(func(tx *gorm.DB) error {
+			return tx.Model(&models.APIToken{}).Where("id = ?", id).Update("last_used_at", now).Error
+		}); err != nil {
+			log.Warn("failed to update last used time of api token %s: %s", id, err.Error())
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package api_token
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGranted(t *testing.T) {
+	scopes := []string{string(SCOPE_PLUGINS_READ)}
+	if !Granted(scopes, SCOPE_PLUGINS_READ) || Granted(scopes, SCOPE_PLUGINS_INSTALL) {
+		t.Fatal("only scopes granted should be allowed")
+	}
+
+	all := []string{string(SCOPE_ALL)}
+	if !Granted(all, SCOPE_ADMIN_WRITE) {
+		t.Fatal("* should grant all scopes")
+	}
+	if Granted(all, SCOPE_TOKENS_MANAGE) || ValidScope(string(SCOPE_TOKENS_MANAGE)) {
+		t.Fatal("tokens should never manage tokens")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	token, hash, prefix, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsToken(token) || Hash(token) != hash || token[:len(prefix)] != prefix {
+		t.Fatalf("unexpected token %s, hash %s, prefix %s", token, hash, prefix)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !l.allow("token", 3) {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if l.allow("token", 3) {
+		t.Fatal("request exceeding the limit should be rejected")
+	}
+	if !l.exhausted("token", 3) || l.exhausted("another", 3) {
+		t.Fatal("only the token reaching the limit should be exhausted")
+	}
+	if !l.allow("another", 3) {
+		t.Fatal("tokens should be limited separately")
+	}
+
+	now = now.Add(time.Minute)
+	if l.exhausted("token", 3) || !l.allow("token", 3) {
+		t.Fatal("requests should be allowed in the next window")
+	}
+}
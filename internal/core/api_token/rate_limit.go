@@ -0,0 +1,64 @@
+package api_token
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter counts requests of each token within fixed windows of a minute, limits are
+// enforced by each node on its own
+type rateLimiter struct {
+	lock    sync.Mutex
+	windows map[string]*window
+	now     func() time.Time
+}
+
+type window struct {
+	startedAt time.Time
+	count     int
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		windows: map[string]*window{},
+		now:     time.Now,
+	}
+}
+
+func (l *rateLimiter) allow(id string, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	w, ok := l.windows[id]
+	if !ok || now.Sub(w.startedAt) >= time.Minute {
+		w = &window{startedAt: now}
+		l.windows[id] = w
+	}
+
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// exhausted returns true if the id reaches the limit within the current window, nothing is counted
+func (l *rateLimiter) exhausted(id string, limit int) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	w, ok := l.windows[id]
+	return ok && l.now().Sub(w.startedAt) < time.Minute && w.count >= limit
+}
+
+var limiter = newRateLimiter()
+
+// Allow returns false if the token exceeds its rate limit
+func Allow(token string, limit int) bool {
+	return limiter.allow(token, limit)
+}
@@ -0,0 +1,99 @@
+// Package asset_url signs urls of plugin assets and packages, signed urls are served on /assets without the
+// server key until they expire, so consoles, marketplaces and cdns in front of the daemon fetch them directly.
+//
+// The signature is the base64url encoded HMAC-SHA256 of `<resource>\n<expires>` keyed by ASSET_SIGNING_SECRET,
+// resources are `asset/<id>` or `package/<plugin_unique_identifier>`.
+package asset_url
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	QUERY_EXPIRES   = "expires"
+	QUERY_SIGNATURE = "signature"
+	// QUERY_PLUGIN_UNIQUE_IDENTIFIER names the package of package urls
+	QUERY_PLUGIN_UNIQUE_IDENTIFIER = "plugin_unique_identifier"
+
+	// paths of signed urls, relative to the base url
+	ASSET_PATH   = "/assets/"
+	PACKAGE_PATH = "/assets/package"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature of asset url")
+	ErrExpired          = errors.New("asset url expired")
+)
+
+func AssetResource(id string) string {
+	return "asset/" + id
+}
+
+func PackageResource(identifier string) string {
+	return "package/" + identifier
+}
+
+func sign(secret string, resource string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(resource + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Expiry returns the expiry of urls signed now, it's aligned to the ttl so urls of an asset signed within
+// the same window are identical and cached once by browsers and cdns, urls live between ttl and 2*ttl
+func Expiry(now time.Time, ttl time.Duration) time.Time {
+	seconds := int64(ttl / time.Second)
+	if seconds <= 0 {
+		return now
+	}
+	return time.Unix((now.Unix()/seconds+2)*seconds, 0)
+}
+
+// Query returns the query signing the resource until it expires
+func Query(secret string, resource string, expires time.Time) url.Values {
+	return url.Values{
+		QUERY_EXPIRES:   {strconv.FormatInt(expires.Unix(), 10)},
+		QUERY_SIGNATURE: {sign(secret, resource, expires.Unix())},
+	}
+}
+
+// AssetURL returns the signed url of the asset on the base url, a relative one if the base url is empty
+func AssetURL(baseURL string, secret string, id string, expires time.Time) string {
+	return strings.TrimSuffix(baseURL, "/") + ASSET_PATH + url.PathEscape(id) + "?" +
+		Query(secret, AssetResource(id), expires).Encode()
+}
+
+// PackageURL returns the signed url of the package on the base url, a relative one if the base url is empty
+func PackageURL(baseURL string, secret string, identifier string, expires time.Time) string {
+	query := Query(secret, PackageResource(identifier), expires)
+	query.Set(QUERY_PLUGIN_UNIQUE_IDENTIFIER, identifier)
+	return strings.TrimSuffix(baseURL, "/") + PACKAGE_PATH + "?" + query.Encode()
+}
+
+// Verify checks the signature of the resource and returns its expiry
+func Verify(secret string, resource string, query url.Values, now time.Time) (time.Time, error) {
+	if secret == "" {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(query.Get(QUERY_EXPIRES), 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(query.Get(QUERY_SIGNATURE)), []byte(sign(secret, resource, expires))) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	expiresAt := time.Unix(expires, 0)
+	if !now.Before(expiresAt) {
+		return time.Time{}, ErrExpired
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,59 @@
+package asset_url
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1_700_000_100, 0)
+	expires := Expiry(now, time.Hour)
+	if lifetime := expires.Sub(now); lifetime < time.Hour || lifetime > 2*time.Hour {
+		t.Fatalf("urls should live between the ttl and twice of it, got %s", lifetime)
+	}
+	if !Expiry(now.Add(time.Minute), time.Hour).Equal(expires) {
+		t.Fatal("urls signed within the same window should expire at the same time")
+	}
+
+	id := strings.Repeat("a", 64) + ".svg"
+	signed, err := url.Parse(AssetURL("https://cdn.example.com/", "secret", id, expires))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Host != "cdn.example.com" || signed.Path != ASSET_PATH+id {
+		t.Fatalf("unexpected url %s", signed)
+	}
+
+	if _, err := Verify("secret", AssetResource(id), signed.Query(), now); err != nil {
+		t.Fatalf("signed urls should be verified, got %v", err)
+	}
+	if _, err := Verify("secret", AssetResource(id), signed.Query(), expires); err != ErrExpired {
+		t.Fatalf("expired urls should be rejected, got %v", err)
+	}
+	if _, err := Verify("rotated", AssetResource(id), signed.Query(), now); err != ErrInvalidSignature {
+		t.Fatalf("urls signed by other secrets should be rejected, got %v", err)
+	}
+	if _, err := Verify("secret", AssetResource(strings.Repeat("b", 64)+".svg"), signed.Query(), now); err != ErrInvalidSignature {
+		t.Fatalf("signatures should not be reused by other assets, got %v", err)
+	}
+
+	extended := signed.Query()
+	extended.Set(QUERY_EXPIRES, "9999999999")
+	if _, err := Verify("secret", AssetResource(id), extended, now); err != ErrInvalidSignature {
+		t.Fatalf("extended expiries should be rejected, got %v", err)
+	}
+
+	identifier := "langgenius/test:1.0.0@" + strings.Repeat("c", 64)
+	pkg, err := url.Parse(PackageURL("", "secret", identifier, expires))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Host != "" || pkg.Path != PACKAGE_PATH || pkg.Query().Get(QUERY_PLUGIN_UNIQUE_IDENTIFIER) != identifier {
+		t.Fatalf("unexpected url %s", pkg)
+	}
+	if _, err := Verify("secret", PackageResource(identifier), pkg.Query(), now); err != nil {
+		t.Fatalf("signed urls of packages should be verified, got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Package config_loader loads settings of the daemon from environment variables and the yaml file set by
+// CONFIG_FILE, keys of the file are names of the variables. Values of the file are exported as variables
+// unless they're set in the environment, so variables of the environment take precedence over the file.
+//
+// Settings tagged by `reload` in app.Config are reloaded on SIGHUP or once the file changes, they're copied
+// into a new live config read through Live and applied by subscribers, other settings take effect after
+// restarting.
+package config_loader
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_schema"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/secrets"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+const CONFIG_FILE = "CONFIG_FILE"
+
+var (
+	lock sync.Mutex
+
+	// environ are names of variables set in the environment before the file is read
+	environ map[string]bool
+	// exported are values of the file exported as variables
+	exported map[string]string
+)
+
+// setting is a field of app.Config read from a variable
+type setting struct {
+	name   string
+	index  int
+	reload bool
+}
+
+var settings = func() []setting {
+	t := reflect.TypeOf(app.Config{})
+	result := make([]setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("envconfig")
+		if name == "" {
+			continue
+		}
+		result = append(result, setting{
+			name:   name,
+			index:  i,
+			reload: t.Field(i).Tag.Get("reload") == "true",
+		})
+	}
+	return result
+}()
+
+func lookupSetting(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// Load reads the settings from the environment and the config file, then resolves secrets, sets defaults
+// and validates them
+func Load() (*app.Config, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return load()
+}
+
+func load() (*app.Config, error) {
+	if environ == nil {
+		environ = map[string]bool{}
+		for _, s := range settings {
+			if _, ok := os.LookupEnv(s.name); ok {
+				environ[s.name] = true
+			}
+		}
+	}
+
+	values := map[string]string{}
+	if path := os.Getenv(CONFIG_FILE); path != "" {
+		var err error
+		if values, err = readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	previous := exported
+	export(values, previous)
+
+	config, err := process()
+	if err != nil {
+		// restore variables of the previous file, settings reloaded later are read from them
+		export(previous, values)
+		return nil, err
+	}
+
+	exported = values
+	return config, nil
+}
+
+func process() (*app.Config, error) {
+	var config app.Config
+
+	if err := envconfig.Process("", &config); err != nil {
+		return nil, fmt.Errorf("error processing environment variables: %w", err)
+	}
+
+	// read config values referencing secret managers
+	if err := secrets.Resolve(&config); err != nil {
+		return nil, fmt.Errorf("error resolving secrets: %w", err)
+	}
+
+	config.SetDefault()
+
+	diagnostics, err := config_schema.Check(&config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration, %w", err)
+	}
+	for _, d := range diagnostics {
+		log.Warn("config: %s", d.String())
+	}
+
+	return &config, nil
+}
+
+// readFile reads the yaml file into values of variables, lists are joined by commas
+func readFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	document := map[string]any{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(document))
+	for key, value := range document {
+		name := strings.ToUpper(key)
+		if name == CONFIG_FILE {
+			return nil, fmt.Errorf("%s can't be set by the config file", CONFIG_FILE)
+		}
+		if _, ok := lookupSetting(name); !ok {
+			return nil, fmt.Errorf("unknown setting %s in config file %s", key, path)
+		}
+
+		switch v := value.(type) {
+		case nil:
+			continue
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if !scalar(item) {
+					return nil, fmt.Errorf("items of setting %s should be scalars", key)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			if !scalar(v) {
+				return nil, fmt.Errorf("setting %s should be a scalar or a list", key)
+			}
+			values[name] = fmt.Sprint(v)
+		}
+
+		if environ[name] {
+			log.Warn("setting %s of the config file is overridden by the environment", name)
+		}
+	}
+	return values, nil
+}
+
+func scalar(value any) bool {
+	switch value.(type) {
+	case string, bool, int, int64, uint64, float64:
+		return true
+	}
+	return false
+}
+
+// export sets variables of the values and unsets those only set by the previous values, variables set
+// in the environment are left alone
+func export(values map[string]string, previous map[string]string) {
+	for name := range previous {
+		if _, ok := values[name]; !ok && !environ[name] {
+			os.Unsetenv(name)
+		}
+	}
+	for name, value := range values {
+		if !environ[name] {
+			os.Setenv(name, value)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package config_loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+const baseConfig = `
+SERVER_KEY: key
+DIFY_INNER_API_URL: http://localhost:5001
+DIFY_INNER_API_KEY: key
+PLUGIN_STORAGE_TYPE: local
+PLUGIN_INSTALLED_PATH: plugin
+PLUGIN_WORKING_PATH: cwd
+PLUGIN_PACKAGE_CACHE_PATH: plugin_packages
+PLUGIN_LOCAL_LAUNCHING_CONCURRENT: 2
+PLATFORM: local
+REDIS_HOST: localhost
+REDIS_PORT: 6379
+DB_USERNAME: postgres
+DB_PASSWORD: postgres
+DB_HOST: localhost
+DB_PORT: 5432
+DB_DATABASE: dify_plugin
+DB_DEFAULT_DATABASE: postgres
+DB_SSL_MODE: disable
+PYTHON_ENV_INIT_TIMEOUT: 120
+PLUGIN_REMOTE_INSTALLING_ENABLED: false
+`
+
+// setup writes the config file and resets variables exported by previous tests
+func setup(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, content)
+
+	t.Setenv(CONFIG_FILE, path)
+	t.Cleanup(func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		export(map[string]string{}, exported)
+		environ = nil
+		exported = nil
+		live.Store(nil)
+		subscribers = nil
+		status = Status{}
+	})
+	return path
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadReadsConfigFile(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warn")
+	setup(t, baseConfig+`
+log_level: error
+PLUGIN_MAX_EXECUTION_TIMEOUT: 30
+SSRF_ALLOWED_CIDRS: [10.0.0.0/8, 192.168.1.1]
+SLOW_LOG_PAYLOAD_SAMPLE_RATE: 0.5
+`)
+
+	config, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.PluginMaxExecutionTimeout != 30 {
+		t.Errorf("expected the timeout of the file, got %d", config.PluginMaxExecutionTimeout)
+	}
+	if len(config.SSRFAllowedCIDRs) != 2 || config.SSRFAllowedCIDRs[1] != "192.168.1.1" {
+		t.Errorf("expected the list of the file, got %v", config.SSRFAllowedCIDRs)
+	}
+	if config.SlowLogPayloadSampleRate != 0.5 {
+		t.Errorf("expected the rate of the file, got %v", config.SlowLogPayloadSampleRate)
+	}
+	if config.LogLevel != "warn" {
+		t.Errorf("expected the environment to take precedence, got %s", config.LogLevel)
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown setting": baseConfig + "UNKNOWN_SETTING: 1\n",
+		"nested setting":  baseConfig + "LOG_LEVEL:\n  level: info\n",
+		"config file":     baseConfig + "CONFIG_FILE: other.yaml\n",
+		"invalid value":   baseConfig + "LOG_LEVEL: verbose\n",
+		"malformed yaml":  baseConfig + "LOG_LEVEL: [info\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			setup(t, content)
+			if _, err := Load(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestReloadAppliesReloadableSettings(t *testing.T) {
+	path := setup(t, baseConfig+"LOG_LEVEL: info\nPLUGIN_MAX_EXECUTION_TIMEOUT: 30\n")
+
+	config, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Watch(config)
+
+	applied := 0
+	Subscribe(func(config *app.Config) error {
+		applied++
+		return nil
+	})
+
+	writeFile(t, path, baseConfig+"LOG_LEVEL: error\nPLUGIN_MAX_EXECUTION_TIMEOUT: 60\nNODE_MAX_SESSIONS: 10\n")
+	status, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := Live(nil)
+	if reloaded.LogLevel != "error" || reloaded.PluginMaxExecutionTimeout != 60 {
+		t.Errorf("expected reloadable settings to be applied, got %s and %d", reloaded.LogLevel, reloaded.PluginMaxExecutionTimeout)
+	}
+	if reloaded.NodeMaxSessions != 0 {
+		t.Errorf("expected the session limit to be kept until restarting, got %d", reloaded.NodeMaxSessions)
+	}
+	if config.LogLevel != "info" || config.PluginMaxExecutionTimeout != 30 {
+		t.Errorf("expected the config to be replaced rather than updated in place, got %s and %d", config.LogLevel, config.PluginMaxExecutionTimeout)
+	}
+	if len(status.Applied) != 2 || len(status.RestartRequired) != 1 || status.RestartRequired[0] != "NODE_MAX_SESSIONS" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if applied != 1 {
+		t.Errorf("expected subscribers to be called once, got %d", applied)
+	}
+
+	// invalid settings are not applied and the previous file is still exported
+	writeFile(t, path, baseConfig+"LOG_LEVEL: verbose\n")
+	if _, err := Reload(); err == nil {
+		t.Fatal("expected invalid settings to fail the reload")
+	}
+	if Live(nil).LogLevel != "error" {
+		t.Errorf("expected the previous log level to be kept, got %s", Live(nil).LogLevel)
+	}
+	if os.Getenv("LOG_LEVEL") != "error" {
+		t.Errorf("expected the previous file to be exported, got %s", os.Getenv("LOG_LEVEL"))
+	}
+	if GetStatus().Error == "" {
+		t.Error("expected the error to be reported")
+	}
+}
+
+func TestReloadBeforeWatching(t *testing.T) {
+	setup(t, baseConfig)
+	if _, err := Reload(); err != ErrNotWatching {
+		t.Errorf("expected ErrNotWatching, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package config_loader
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// Status is the outcome of the latest reload
+type Status struct {
+	File       string    `json:"file"`
+	Reloads    int       `json:"reloads"`
+	ReloadedAt time.Time `json:"reloaded_at"`
+	// names of settings changed by the reload
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+	Error           string   `json:"error,omitempty"`
+}
+
+var (
+	// live is the config with the latest reloaded settings, it's replaced on each reload rather than
+	// updated in place since requests read it concurrently
+	live        atomic.Pointer[app.Config]
+	subscribers []func(config *app.Config) error
+	status      Status
+	modTime     time.Time
+)
+
+var ErrNotWatching = errors.New("config is not watched")
+
+// Live returns the config with the latest reloaded settings, the config itself is returned until it's
+// watched, reloadable settings should be read through it on each use
+func Live(config *app.Config) *app.Config {
+	if current := live.Load(); current != nil {
+		return current
+	}
+	return config
+}
+
+// Subscribe calls the function with the reloaded config once settings are reloaded, it should apply the
+// settings copied at initialization
+func Subscribe(fn func(config *app.Config) error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	subscribers = append(subscribers, fn)
+}
+
+// Watch reloads settings of the config on SIGHUP and once the config file changes, changes are checked
+// every CONFIG_FILE_WATCH_INTERVAL
+func Watch(config *app.Config) {
+	lock.Lock()
+	live.Store(config)
+	status.File = config.ConfigFile
+	if info, err := os.Stat(config.ConfigFile); err == nil {
+		modTime = info.ModTime()
+	}
+	lock.Unlock()
+
+	if config.ConfigFile == "" {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(time.Duration(config.ConfigFileWatchInterval) * time.Second)
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+			case <-ticker.C:
+				if !changed() {
+					continue
+				}
+			}
+
+			Reload()
+		}
+	}()
+}
+
+func changed() bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	info, err := os.Stat(live.Load().ConfigFile)
+	return err == nil && !info.ModTime().Equal(modTime)
+}
+
+// Reload loads the settings again and applies the reloadable ones, the previous settings are kept if
+// the new ones are invalid
+func Reload() (Status, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	current := live.Load()
+	if current == nil {
+		return status, ErrNotWatching
+	}
+
+	if info, err := os.Stat(current.ConfigFile); err == nil {
+		modTime = info.ModTime()
+	}
+
+	status.Reloads++
+	status.ReloadedAt = time.Now()
+	status.Applied = []string{}
+	status.RestartRequired = []string{}
+	status.Error = ""
+
+	config, err := load()
+	if err != nil {
+		status.Error = err.Error()
+		log.Error("failed to reload config, keep running with the previous one: %s", err.Error())
+		return status, err
+	}
+
+	next := *current
+	status.Applied, status.RestartRequired = apply(&next, config)
+	if len(status.RestartRequired) > 0 {
+		log.Warn("settings %v of the config are changed, they take effect after restarting", status.RestartRequired)
+	}
+	if len(status.Applied) == 0 {
+		return status, nil
+	}
+	live.Store(&next)
+
+	errs := []error{}
+	for _, fn := range subscribers {
+		if err := fn(&next); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		status.Error = err.Error()
+		log.Error("failed to apply reloaded settings %v: %s", status.Applied, err.Error())
+		return status, err
+	}
+
+	log.Info("settings %v of the config reloaded", status.Applied)
+	return status, nil
+}
+
+// GetStatus returns the outcome of the latest reload
+func GetStatus() Status {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return status
+}
+
+// apply copies reloadable settings changed in the config into the copy of the live one, returns names
+// of the settings applied and those changed but not reloadable
+func apply(target *app.Config, config *app.Config) ([]string, []string) {
+	applied := []string{}
+	restartRequired := []string{}
+
+	current := reflect.ValueOf(target).Elem()
+	next := reflect.ValueOf(config).Elem()
+	for _, s := range settings {
+		if s.name == CONFIG_FILE {
+			continue
+		}
+		field := current.Field(s.index)
+		if reflect.DeepEqual(field.Interface(), next.Field(s.index).Interface()) {
+			continue
+		}
+		if !s.reload {
+			restartRequired = append(restartRequired, s.name)
+			continue
+		}
+		field.Set(next.Field(s.index))
+		applied = append(applied, s.name)
+	}
+	return applied, restartRequired
+}
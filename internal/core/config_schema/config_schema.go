@@ -0,0 +1,268 @@
+// Package config_schema checks settings of the daemon as a whole before it starts. Settings are checked against
+// the rules of their fields, settings depending on or excluding each other against rules of this package, and
+// urls the daemon calls are probed. Problems are reported all at once by names of the variables along with what
+// to change, so the daemon fails fast instead of misbehaving once a setting is used.
+package config_schema
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/support_bundle"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	LEVEL_ERROR   = "error"
+	LEVEL_WARNING = "warning"
+)
+
+// Diagnostic is a problem of a setting, errors keep the daemon from starting
+type Diagnostic struct {
+	Setting string `json:"setting,omitempty"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Setting == "" {
+		return d.Message
+	}
+	return d.Setting + ": " + d.Message
+}
+
+// Errors are diagnostics failing the check
+type Errors []Diagnostic
+
+func (e Errors) Error() string {
+	lines := []string{"fix the settings below in the environment or CONFIG_FILE"}
+	for _, d := range e {
+		lines = append(lines, "  - "+d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check checks the config, returns all the problems found, an Errors is returned as well if any of them is
+// an error
+func Check(config *app.Config) ([]Diagnostic, error) {
+	redacted := support_bundle.RedactConfig(config)
+
+	diagnostics := checkFields(config, redacted)
+	diagnostics = append(diagnostics, checkRelations(config)...)
+	diagnostics = append(diagnostics, checkFiles(config)...)
+
+	failed := Errors{}
+	for _, d := range diagnostics {
+		if d.Level == LEVEL_ERROR {
+			failed = append(failed, d)
+		}
+	}
+
+	// rules of the config itself are checked once the ones above pass, it stops at the first failure
+	if len(failed) == 0 {
+		if err := config.Validate(); err != nil {
+			d := Diagnostic{Level: LEVEL_ERROR, Message: err.Error()}
+			diagnostics = append(diagnostics, d)
+			failed = append(failed, d)
+		}
+	}
+
+	if len(failed) > 0 {
+		return diagnostics, failed
+	}
+	return diagnostics, nil
+}
+
+// checkFields checks settings against rules of their fields
+func checkFields(config *app.Config, redacted map[string]any) []Diagnostic {
+	err := validator.New().Struct(config)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return []Diagnostic{{Level: LEVEL_ERROR, Message: err.Error()}}
+	}
+
+	diagnostics := []Diagnostic{}
+	t := reflect.TypeOf(app.Config{})
+	for _, fe := range fieldErrors {
+		name := fe.StructField()
+		if field, ok := t.FieldByName(fe.StructField()); ok {
+			name = field.Tag.Get("envconfig")
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Setting: name,
+			Level:   LEVEL_ERROR,
+			Message: describe(fe, redacted[name]),
+		})
+	}
+	return diagnostics
+}
+
+// describe tells what the rule expects, values are the redacted ones
+func describe(fe validator.FieldError, value any) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("should be at least %s, got %v", fe.Param(), value)
+	case "max":
+		return fmt.Sprintf("should be at most %s, got %v", fe.Param(), value)
+	case "oneof":
+		return fmt.Sprintf("should be one of %s, got %v", strings.ReplaceAll(fe.Param(), " ", ", "), value)
+	case "url":
+		return fmt.Sprintf("should be an absolute url like https://example.com, got %v", value)
+	}
+	return fmt.Sprintf("fails the %s rule, got %v", fe.Tag(), value)
+}
+
+// checkRelations checks settings depending on or excluding each other
+func checkRelations(c *app.Config) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	fail := func(setting string, format string, v ...any) {
+		diagnostics = append(diagnostics, Diagnostic{Setting: setting, Level: LEVEL_ERROR, Message: fmt.Sprintf(format, v...)})
+	}
+	warn := func(setting string, format string, v ...any) {
+		diagnostics = append(diagnostics, Diagnostic{Setting: setting, Level: LEVEL_WARNING, Message: fmt.Sprintf(format, v...)})
+	}
+
+	if c.PluginStorageType == "aws_s3" && c.S3UseAwsManagedIam && (c.AWSAccessKey != "" || c.AWSSecretKey != "") {
+		fail("S3_USE_AWS_MANAGED_IAM", "managed iam excludes AWS_ACCESS_KEY and AWS_SECRET_KEY, unset either of them")
+	}
+	if c.FIPSModeEnabled && c.EncryptionCipher == "chacha20-poly1305" {
+		fail("ENCRYPTION_CIPHER", "chacha20-poly1305 is not approved by FIPS, use aes-gcm once FIPS_MODE_ENABLED is set")
+	}
+	if c.TenantEncryptionEnabled && c.EncryptionKeys == "" {
+		fail("TENANT_ENCRYPTION_ENABLED", "tenant keys are derived from ENCRYPTION_KEYS, set it or disable tenant encryption")
+	}
+	if c.EncryptionActiveKeyVersion != 0 && c.EncryptionKeys == "" {
+		fail("ENCRYPTION_ACTIVE_KEY_VERSION", "no key of ENCRYPTION_KEYS is active since it's empty")
+	}
+	if c.EncryptionActiveKeyVersion == 0 && strings.Contains(strings.Trim(c.EncryptionKeys, " ,"), ",") {
+		fail("ENCRYPTION_ACTIVE_KEY_VERSION", "set it once ENCRYPTION_KEYS has more than one key, after every node has the new key")
+	}
+	if c.Platform == app.PLATFORM_SERVERLESS {
+		if c.ServerlessDefaultTimeout > c.ServerlessMaxTimeout {
+			fail("SERVERLESS_DEFAULT_TIMEOUT", "should be at most SERVERLESS_MAX_TIMEOUT %d, got %d", c.ServerlessMaxTimeout, c.ServerlessDefaultTimeout)
+		}
+		if c.ServerlessDefaultEphemeralStorage > c.ServerlessMaxEphemeralStorage {
+			fail("SERVERLESS_DEFAULT_EPHEMERAL_STORAGE", "should be at most SERVERLESS_MAX_EPHEMERAL_STORAGE %d, got %d",
+				c.ServerlessMaxEphemeralStorage, c.ServerlessDefaultEphemeralStorage)
+		}
+	}
+
+	if _, err := routine.ParsePools(c.RoutinePools); err != nil {
+		fail("ROUTINE_POOLS", "%s", err.Error())
+	}
+
+	if c.PluginEgressEnforceUndeclared && !c.PluginEgressPolicyEnabled {
+		warn("PLUGIN_EGRESS_ENFORCE_UNDECLARED", "has no effect unless PLUGIN_EGRESS_POLICY_ENABLED is set")
+	}
+	if c.ServerTLSClientCAFile != "" && !c.ServerTLSEnabled {
+		warn("SERVER_TLS_CLIENT_CA_FILE", "has no effect unless SERVER_TLS_ENABLED is set")
+	}
+	if c.PluginRunAsUserEnabled && c.Platform != app.PLATFORM_LOCAL {
+		warn("PLUGIN_RUN_AS_USER_ENABLED", "has no effect unless PLATFORM is local")
+	}
+	if c.SSRFProtectionEnabled != nil && !*c.SSRFProtectionEnabled && len(c.SSRFAllowedCIDRs) > 0 {
+		warn("SSRF_ALLOWED_CIDRS", "has no effect unless SSRF_PROTECTION_ENABLED is set")
+	}
+	return diagnostics
+}
+
+// checkFiles checks files read by the daemon can be read
+func checkFiles(c *app.Config) []Diagnostic {
+	if !c.ServerTLSEnabled {
+		return nil
+	}
+
+	diagnostics := []Diagnostic{}
+	for setting, file := range map[string]string{
+		"SERVER_TLS_CERT_FILE":      c.ServerTLSCertFile,
+		"SERVER_TLS_KEY_FILE":       c.ServerTLSKeyFile,
+		"SERVER_TLS_CLIENT_CA_FILE": c.ServerTLSClientCAFile,
+	} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			diagnostics = append(diagnostics, Diagnostic{
+				Setting: setting,
+				Level:   LEVEL_ERROR,
+				Message: fmt.Sprintf("can't read %s: %s", file, err.Error()),
+			})
+		}
+	}
+	sort.Slice(diagnostics, func(i, j int) bool { return diagnostics[i].Setting < diagnostics[j].Setting })
+	return diagnostics
+}
+
+// Dump logs settings in effect, values of sensitive ones are masked
+func Dump(config *app.Config) {
+	redacted := support_bundle.RedactConfig(config)
+	names := make([]string, 0, len(redacted))
+	for name, value := range redacted {
+		if empty(value) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("  %s=%v", name, redacted[name]))
+	}
+	log.Info("effective config, settings of zero values are omitted:\n%s", strings.Join(lines, "\n"))
+}
+
+func empty(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// Diagnose dumps the effective config and probes urls the daemon calls, the daemon exits if they're
+// unreachable and CONFIG_URL_CHECK is enforce
+func Diagnose(config *app.Config) {
+	if config.ConfigDumpEnabled != nil && *config.ConfigDumpEnabled {
+		Dump(config)
+	}
+
+	if config.ConfigURLCheck == URL_CHECK_OFF {
+		return
+	}
+
+	level := LEVEL_WARNING
+	if config.ConfigURLCheck == URL_CHECK_ENFORCE {
+		level = LEVEL_ERROR
+	}
+
+	failed := Errors{}
+	for _, d := range Probe(config, level, time.Duration(config.ConfigURLCheckTimeout)*time.Second) {
+		if d.Level == LEVEL_ERROR {
+			failed = append(failed, d)
+			continue
+		}
+		log.Warn("config: %s", d.String())
+	}
+	if len(failed) > 0 {
+		log.Panic("invalid configuration, %s", failed.Error())
+	}
+}
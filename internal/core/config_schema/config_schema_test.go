@@ -0,0 +1,161 @@
+package config_schema
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func validConfig() *app.Config {
+	disabled := false
+	config := &app.Config{
+		ServerKey:                      "key",
+		DifyInnerApiURL:                "http://localhost:5001",
+		DifyInnerApiKey:                "key",
+		PluginStorageType:              "local",
+		PluginInstalledPath:            "plugin",
+		PluginWorkingPath:              "cwd",
+		PluginPackageCachePath:         "plugin_packages",
+		PluginLocalLaunchingConcurrent: 2,
+		PluginRemoteInstallingEnabled:  &disabled,
+		Platform:                       app.PLATFORM_LOCAL,
+		RedisHost:                      "localhost",
+		RedisPort:                      6379,
+		DBUsername:                     "postgres",
+		DBPassword:                     "postgres",
+		DBHost:                         "localhost",
+		DBPort:                         5432,
+		DBDatabase:                     "dify_plugin",
+		DBDefaultDatabase:              "postgres",
+		DBSslMode:                      "disable",
+		PythonEnvInitTimeout:           120,
+	}
+	config.SetDefault()
+	return config
+}
+
+func TestCheckPasses(t *testing.T) {
+	diagnostics, err := Check(validConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diagnostics)
+	}
+}
+
+func TestCheckReportsAllFieldsAtOnce(t *testing.T) {
+	config := validConfig()
+	config.ServerKey = ""
+	config.LogLevel = "verbose"
+	config.SlowLogPayloadSampleRate = 2
+	config.GuardrailModerationURL = "not a url"
+
+	_, err := Check(config)
+
+	var failed Errors
+	if !errors.As(err, &failed) {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+
+	messages := map[string]string{}
+	for _, d := range failed {
+		messages[d.Setting] = d.Message
+	}
+	expected := map[string]string{
+		"SERVER_KEY":                   "is required",
+		"LOG_LEVEL":                    "should be one of debug, info, warn, error, got verbose",
+		"SLOW_LOG_PAYLOAD_SAMPLE_RATE": "should be at most 1, got 2",
+		"GUARDRAIL_MODERATION_URL":     "should be an absolute url like https://example.com, got not a url",
+	}
+	for setting, message := range expected {
+		if messages[setting] != message {
+			t.Errorf("expected %s of %s, got %q", message, setting, messages[setting])
+		}
+	}
+	if !strings.Contains(err.Error(), "  - LOG_LEVEL: ") {
+		t.Errorf("expected settings to be listed, got %s", err.Error())
+	}
+}
+
+func TestCheckRelations(t *testing.T) {
+	config := validConfig()
+	config.TenantEncryptionEnabled = true
+	config.FIPSModeEnabled = true
+	config.EncryptionCipher = "chacha20-poly1305"
+	config.PluginEgressEnforceUndeclared = true
+
+	diagnostics, err := Check(config)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	levels := map[string]string{}
+	for _, d := range diagnostics {
+		levels[d.Setting] = d.Level
+	}
+	if levels["TENANT_ENCRYPTION_ENABLED"] != LEVEL_ERROR || levels["ENCRYPTION_CIPHER"] != LEVEL_ERROR {
+		t.Errorf("expected conflicting settings to fail, got %v", diagnostics)
+	}
+	if levels["PLUGIN_EGRESS_ENFORCE_UNDECLARED"] != LEVEL_WARNING {
+		t.Errorf("expected settings without effect to be warned, got %v", diagnostics)
+	}
+
+	config = validConfig()
+	config.EncryptionKeys = "1:a,2:b"
+	diagnostics, _ = Check(config)
+	if len(diagnostics) == 0 || diagnostics[0].Setting != "ENCRYPTION_ACTIVE_KEY_VERSION" {
+		t.Errorf("expected several keys without the active version to fail, got %v", diagnostics)
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	config := validConfig()
+	config.ServerTLSEnabled = true
+	config.ServerTLSCertFile = "/nonexistent/cert.pem"
+	config.ServerTLSKeyFile = "/nonexistent/key.pem"
+
+	diagnostics, err := Check(config)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(diagnostics) != 2 || diagnostics[0].Setting != "SERVER_TLS_CERT_FILE" {
+		t.Errorf("expected missing files to be reported, got %v", diagnostics)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// a port nothing listens on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	config := validConfig()
+	config.DifyInnerApiURL = "http://" + listener.Addr().String()
+	config.GuardrailModerationURL = "http://" + closedAddress + "/moderate"
+	config.AdminAuthLDAPURL = "unknown://ldap"
+
+	diagnostics := Probe(config, LEVEL_ERROR, time.Second)
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diagnostics)
+	}
+	if diagnostics[0].Setting != "ADMIN_AUTH_LDAP_URL" || !strings.Contains(diagnostics[0].Message, "port of scheme") {
+		t.Errorf("expected the unknown scheme to be reported, got %v", diagnostics[0])
+	}
+	if diagnostics[1].Setting != "GUARDRAIL_MODERATION_URL" || !strings.Contains(diagnostics[1].Message, "unreachable") {
+		t.Errorf("expected the closed port to be reported, got %v", diagnostics[1])
+	}
+}
@@ -0,0 +1,100 @@
+package config_schema
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+const (
+	URL_CHECK_OFF     = "off"
+	URL_CHECK_WARN    = "warn"
+	URL_CHECK_ENFORCE = "enforce"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ldap":  "389",
+	"ldaps": "636",
+}
+
+// urls returns urls the daemon calls by their settings
+func urls(c *app.Config) map[string]string {
+	urls := map[string]string{
+		"DIFY_INNER_API_URL":          c.DifyInnerApiURL,
+		"GUARDRAIL_MODERATION_URL":    c.GuardrailModerationURL,
+		"CREDENTIAL_AUDIT_EXPORT_URL": c.CredentialAuditExportURL,
+		"ADMIN_AUTH_LDAP_URL":         c.AdminAuthLDAPURL,
+		"ADMIN_AUTH_OIDC_ISSUER":      c.AdminAuthOIDCIssuer,
+	}
+	if c.Platform == app.PLATFORM_SERVERLESS {
+		if c.ServerlessProvider == app.SERVERLESS_PROVIDER_HTTP {
+			urls["SERVERLESS_HTTP_DEPLOYER_URL"] = c.ServerlessHTTPDeployerURL
+		} else if c.DifyPluginServerlessConnectorURL != nil {
+			urls["DIFY_PLUGIN_SERVERLESS_CONNECTOR_URL"] = *c.DifyPluginServerlessConnectorURL
+		}
+	}
+	for setting, u := range urls {
+		if u == "" {
+			delete(urls, setting)
+		}
+	}
+	return urls
+}
+
+// Probe connects to hosts of urls the daemon calls, unreachable ones are reported at the level, nothing is
+// sent over the connections
+func Probe(c *app.Config, level string, timeout time.Duration) []Diagnostic {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	diagnostics := []Diagnostic{}
+	for setting, u := range urls(c) {
+		wg.Add(1)
+		go func(setting string, u string) {
+			defer wg.Done()
+
+			if err := probe(u, timeout); err != nil {
+				lock.Lock()
+				diagnostics = append(diagnostics, Diagnostic{
+					Setting: setting,
+					Level:   level,
+					Message: err.Error(),
+				})
+				lock.Unlock()
+			}
+		}(setting, u)
+	}
+	wg.Wait()
+
+	sort.Slice(diagnostics, func(i, j int) bool { return diagnostics[i].Setting < diagnostics[j].Setting })
+	return diagnostics
+}
+
+func probe(rawURL string, timeout time.Duration) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Hostname() == "" {
+		return fmt.Errorf("should be an absolute url like https://example.com")
+	}
+
+	port := u.Port()
+	if port == "" {
+		if port = defaultPorts[u.Scheme]; port == "" {
+			return fmt.Errorf("port of scheme %s is unknown, set the port in the url", u.Scheme)
+		}
+	}
+
+	address := net.JoinHostPort(u.Hostname(), port)
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return fmt.Errorf("%s is unreachable, check the url and that it's reachable from the daemon: %s", address, err.Error())
+	}
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,331 @@
+// Package conformance runs a plugin against a scripted battery of interactions of the stdio protocol, the one
+// local runtimes speak, and reports how it behaved. SDKs in new languages are validated by running a plugin
+// built on them, the harness plays the part of the daemon so neither the database nor redis is needed.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+type Options struct {
+	// Command launches the plugin in its directory, plugins of python are launched by PythonInterpreter
+	// as `python -m <entrypoint>` if it's empty, dependencies of the plugin should have been installed
+	Command           []string
+	PythonInterpreter string
+	// Env is appended to the environment of the harness
+	Env []string
+
+	StartupTimeout time.Duration
+	SessionTimeout time.Duration
+	// the daemon warns about plugins sending no heartbeat for 60 seconds and restarts them after 120
+	HeartbeatTimeout time.Duration
+	ShutdownTimeout  time.Duration
+	// size of the payload of the large payload case, in bytes
+	PayloadSize int
+	// sessions opened at once by the concurrency case
+	ConcurrentSessions int
+}
+
+func (o *Options) setDefault() {
+	if o.PythonInterpreter == "" {
+		o.PythonInterpreter = "python3"
+	}
+	if o.StartupTimeout == 0 {
+		o.StartupTimeout = 60 * time.Second
+	}
+	if o.SessionTimeout == 0 {
+		o.SessionTimeout = 30 * time.Second
+	}
+	if o.HeartbeatTimeout == 0 {
+		o.HeartbeatTimeout = 60 * time.Second
+	}
+	if o.ShutdownTimeout == 0 {
+		o.ShutdownTimeout = 10 * time.Second
+	}
+	if o.PayloadSize == 0 {
+		o.PayloadSize = 1024 * 1024
+	}
+	if o.ConcurrentSessions == 0 {
+		o.ConcurrentSessions = 16
+	}
+}
+
+type Status string
+
+const (
+	STATUS_PASSED Status = "passed"
+	STATUS_FAILED Status = "failed"
+	// STATUS_WARNED is the status of failed cases which are not required
+	STATUS_WARNED  Status = "warned"
+	STATUS_SKIPPED Status = "skipped"
+)
+
+type Result struct {
+	Case     string `json:"case"`
+	Status   Status `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Duration int64  `json:"duration"` // in milliseconds
+}
+
+type Report struct {
+	Plugin   string   `json:"plugin"`
+	Language string   `json:"language"`
+	Command  []string `json:"command"`
+	Passed   bool     `json:"passed"`
+	Results  []Result `json:"results"`
+	// Stderr is the tail of stderr of the plugin, for failed runs
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// Run extracts the package, a .difypkg or a directory of a plugin, and runs the battery against it
+func Run(ctx context.Context, path string, options Options) (*Report, error) {
+	options.setDefault()
+
+	dir := path
+	var pluginDecoder decoder.PluginDecoder
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		pluginDecoder, err = decoder.NewFSPluginDecoder(path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		zipDecoder, err := decoder.NewZipPluginDecoder(data)
+		if err != nil {
+			return nil, err
+		}
+		dir, err = os.MkdirTemp("", "dify-plugin-conformance-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		if err := zipDecoder.ExtractTo(dir); err != nil {
+			return nil, err
+		}
+		pluginDecoder = zipDecoder
+	}
+
+	declaration, err := pluginDecoder.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	command := options.Command
+	if len(command) == 0 {
+		if declaration.Meta.Runner.Language != constants.Python {
+			return nil, fmt.Errorf("plugins of %s are launched by a command given", declaration.Meta.Runner.Language)
+		}
+		command = []string{options.PythonInterpreter, "-m", declaration.Meta.Runner.Entrypoint}
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir, _ = filepath.Abs(dir)
+	cmd.Env = append(append(os.Environ(), "INSTALL_METHOD=local"), options.Env...)
+
+	report := &Report{
+		Plugin:   declaration.Identity(),
+		Language: string(declaration.Meta.Runner.Language),
+		Command:  command,
+	}
+
+	h, err := startHarness(cmd, options.SessionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	runCases(h, declaration, options, report)
+
+	report.Passed = true
+	for _, result := range report.Results {
+		if result.Status == STATUS_FAILED {
+			report.Passed = false
+		}
+	}
+	if !report.Passed {
+		_, _, report.Stderr = h.snapshot()
+	}
+	return report, nil
+}
+
+// probeOf returns a request declared by the plugin which is answered without credentials, by a validation
+// failure at least, plugins declaring neither tools nor models have none
+func probeOf(declaration plugin_entities.PluginDeclaration) (probe, bool) {
+	switch {
+	case declaration.Tool != nil:
+		return probe{
+			typ:    access_types.PLUGIN_ACCESS_TYPE_TOOL,
+			action: access_types.PLUGIN_ACCESS_ACTION_VALIDATE_TOOL_CREDENTIALS,
+			data:   map[string]any{"provider": declaration.Tool.Identity.Name, "credentials": map[string]any{}},
+		}, true
+	case declaration.Model != nil:
+		return probe{
+			typ:    access_types.PLUGIN_ACCESS_TYPE_MODEL,
+			action: access_types.PLUGIN_ACCESS_ACTION_VALIDATE_PROVIDER_CREDENTIALS,
+			data:   map[string]any{"provider": declaration.Model.Provider, "credentials": map[string]any{}},
+		}, true
+	}
+	return probe{}, false
+}
+
+var errSkipped = errors.New("skipped")
+
+type testCase struct {
+	name string
+	// failures of cases not required are warnings
+	required bool
+	run      func(h *harness) error
+}
+
+func runCases(h *harness, declaration plugin_entities.PluginDeclaration, options Options, report *Report) {
+	declared, hasProbe := probeOf(declaration)
+	unknown := probe{
+		typ:    access_types.PLUGIN_ACCESS_TYPE_TOOL,
+		action: "conformance_unknown_action",
+		data:   map[string]any{},
+	}
+	// requests of cases exercising sessions, unknown actions are answered by errors if nothing is declared
+	request := declared
+	if !hasProbe {
+		request = unknown
+	}
+
+	started := time.Now()
+	cases := []testCase{
+		{"startup_heartbeat", true, func(h *harness) error {
+			_, err := h.waitHeartbeat(started.Add(-time.Second), options.StartupTimeout)
+			return err
+		}},
+		{"session_roundtrip", true, func(h *harness) error {
+			if !hasProbe {
+				return fmt.Errorf("%w, the plugin declares neither tools nor models", errSkipped)
+			}
+			_, err := h.invoke(declared, nil, options.SessionTimeout)
+			return err
+		}},
+		{"unknown_action", true, func(h *harness) error {
+			result, err := h.invoke(unknown, nil, options.SessionTimeout)
+			if err != nil {
+				return err
+			}
+			if result.err == "" {
+				return errors.New("session of an unknown action should end with an error")
+			}
+			return nil
+		}},
+		{"malformed_input", true, func(h *harness) error {
+			for _, line := range []string{"this is not json", "{}", `{"session_id": "x", "event": "request"}`} {
+				if err := h.writeLine([]byte(line)); err != nil {
+					return err
+				}
+			}
+			if _, err := h.invoke(request, nil, options.SessionTimeout); err != nil {
+				return fmt.Errorf("requests after malformed lines are not answered: %w", err)
+			}
+			return nil
+		}},
+		{"large_payload", true, func(h *harness) error {
+			padding := make([]byte, options.PayloadSize)
+			for i := range padding {
+				padding[i] = 'x'
+			}
+			_, err := h.invoke(request, map[string]any{"conformance_padding": string(padding)}, options.SessionTimeout)
+			return err
+		}},
+		{"concurrent_sessions", true, func(h *harness) error {
+			errs := make(chan error, options.ConcurrentSessions)
+			for i := 0; i < options.ConcurrentSessions; i++ {
+				go func() {
+					_, err := h.invoke(request, nil, options.SessionTimeout)
+					errs <- err
+				}()
+			}
+			failed := 0
+			var last error
+			for i := 0; i < options.ConcurrentSessions; i++ {
+				if err := <-errs; err != nil {
+					failed++
+					last = err
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d sessions failed, %w", failed, options.ConcurrentSessions, last)
+			}
+			return nil
+		}},
+		{"cancellation", true, func(h *harness) error {
+			// callers going away abandon their sessions, the plugin keeps serving others
+			sessionId, _, err := h.open(request, nil)
+			if err != nil {
+				return err
+			}
+			h.close(sessionId)
+			if _, err := h.invoke(request, nil, options.SessionTimeout); err != nil {
+				return fmt.Errorf("requests after abandoned sessions are not answered: %w", err)
+			}
+			return nil
+		}},
+		{"heartbeat_interval", true, func(h *harness) error {
+			_, err := h.waitHeartbeat(time.Now(), options.HeartbeatTimeout)
+			return err
+		}},
+		{"stdout_framing", true, func(h *harness) error {
+			_, invalidLines, _ := h.snapshot()
+			if len(invalidLines) > 0 {
+				return fmt.Errorf("%d lines of stdout are not events of the protocol, the first one: %s",
+					len(invalidLines), invalidLines[0])
+			}
+			return nil
+		}},
+		{"shutdown", false, func(h *harness) error {
+			if !h.stop(options.ShutdownTimeout) {
+				return errors.New("plugin didn't exit once stdin was closed, it was killed")
+			}
+			return nil
+		}},
+	}
+
+	for _, c := range cases {
+		start := time.Now()
+		result := Result{Case: c.name, Status: STATUS_PASSED}
+
+		var err error
+		if c.name != "shutdown" && !h.alive() {
+			err = ErrPluginExited
+		} else {
+			err = c.run(h)
+		}
+		switch {
+		case errors.Is(err, errSkipped):
+			result.Status = STATUS_SKIPPED
+			result.Message = err.Error()
+		case err != nil && c.required:
+			result.Status = STATUS_FAILED
+			result.Message = err.Error()
+		case err != nil:
+			result.Status = STATUS_WARNED
+			result.Message = err.Error()
+		}
+		result.Duration = time.Since(start).Milliseconds()
+		report.Results = append(report.Results, result)
+	}
+
+	// the plugin is stopped by the shutdown case, this is in case the battery changes
+	h.stop(0)
+}
@@ -0,0 +1,146 @@
+package conformance
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// the test binary plays the plugin if it's launched with the mode of the fake plugin
+const fakePluginEnv = "CONFORMANCE_FAKE_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakePluginEnv); mode != "" {
+		runFakePlugin(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakePlugin answers requests as sdks do, validations of credentials end normally and unknown actions
+// with errors, the broken mode stops serving once a malformed line is read and never exits by itself
+func runFakePlugin(mode string) {
+	var lock sync.Mutex
+	emit := func(sessionId string, event plugin_entities.PluginEventType, data any) {
+		line, _ := json.Marshal(map[string]any{"session_id": sessionId, "event": event, "data": data})
+		lock.Lock()
+		defer lock.Unlock()
+		os.Stdout.Write(append(line, '\n'))
+	}
+
+	go func() {
+		for {
+			emit("", plugin_entities.PLUGIN_EVENT_HEARTBEAT, map[string]any{})
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 1024), maxLineSize)
+	for scanner.Scan() {
+		request := struct {
+			SessionId string `json:"session_id"`
+			Event     string `json:"event"`
+			Data      struct {
+				Action string `json:"action"`
+			} `json:"data"`
+		}{}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil || request.Data.Action == "" {
+			if mode == "broken" {
+				select {}
+			}
+			emit("", plugin_entities.PLUGIN_EVENT_ERROR, "invalid request")
+			continue
+		}
+
+		go func() {
+			if request.Data.Action != "validate_tool_credentials" {
+				emit(request.SessionId, plugin_entities.PLUGIN_EVENT_SESSION, plugin_entities.SessionMessage{
+					Type: plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
+					Data: json.RawMessage(`{"error_type":"NotFound","message":"unknown action"}`),
+				})
+				return
+			}
+			emit(request.SessionId, plugin_entities.PLUGIN_EVENT_SESSION, plugin_entities.SessionMessage{
+				Type: plugin_entities.SESSION_MESSAGE_TYPE_STREAM,
+				Data: json.RawMessage(`{"result":false}`),
+			})
+			emit(request.SessionId, plugin_entities.PLUGIN_EVENT_SESSION, plugin_entities.SessionMessage{
+				Type: plugin_entities.SESSION_MESSAGE_TYPE_END,
+				Data: json.RawMessage(`{}`),
+			})
+		}()
+	}
+}
+
+func runFake(t *testing.T, mode string) *Report {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable)
+	cmd.Env = append(os.Environ(), fakePluginEnv+"="+mode)
+
+	options := Options{
+		StartupTimeout:   5 * time.Second,
+		SessionTimeout:   time.Second,
+		HeartbeatTimeout: 2 * time.Second,
+		ShutdownTimeout:  2 * time.Second,
+	}
+	options.setDefault()
+
+	h, err := startHarness(cmd, options.SessionTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declaration := plugin_entities.PluginDeclaration{}
+	declaration.Tool = &plugin_entities.ToolProviderDeclaration{
+		Identity: plugin_entities.ToolProviderIdentity{Name: "fake"},
+	}
+
+	report := &Report{}
+	runCases(h, declaration, options, report)
+	return report
+}
+
+func statuses(report *Report) map[string]Status {
+	statuses := map[string]Status{}
+	for _, result := range report.Results {
+		statuses[result.Case] = result.Status
+	}
+	return statuses
+}
+
+func TestConformingPlugin(t *testing.T) {
+	report := runFake(t, "conforming")
+	for _, result := range report.Results {
+		if result.Status != STATUS_PASSED {
+			t.Fatalf("case %s of the conforming plugin should pass, got %s: %s", result.Case, result.Status, result.Message)
+		}
+	}
+}
+
+func TestBrokenPlugin(t *testing.T) {
+	report := runFake(t, "broken")
+	expected := map[string]Status{
+		"startup_heartbeat":  STATUS_PASSED,
+		"session_roundtrip":  STATUS_PASSED,
+		"unknown_action":     STATUS_PASSED,
+		"malformed_input":    STATUS_FAILED,
+		"large_payload":      STATUS_FAILED,
+		"heartbeat_interval": STATUS_PASSED,
+		"shutdown":           STATUS_WARNED,
+	}
+	got := statuses(report)
+	for name, status := range expected {
+		if got[name] != status {
+			t.Fatalf("case %s of the broken plugin should be %s, got %s", name, status, got[name])
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package conformance
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// lines of stdout larger than it are rejected, large payloads are written back in chunks by sdks
+	maxLineSize = 16 * 1024 * 1024
+	// the tail of stderr kept for the report
+	maxStderrSize = 4096
+)
+
+var (
+	ErrSessionTimeout = errors.New("session didn't end in time")
+	ErrPluginExited   = errors.New("plugin exited")
+	ErrWriteTimeout   = errors.New("plugin doesn't read stdin")
+)
+
+// harness speaks the stdio protocol of local runtimes to the plugin process, it plays the part of the daemon
+type harness struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// writes of lines must not interleave
+	writeLock sync.Mutex
+	// writes blocked longer than it fail, plugins not reading stdin would block the harness forever
+	writeTimeout time.Duration
+
+	lock     sync.Mutex
+	sessions map[string]chan plugin_entities.SessionMessage
+	// errors written by the plugin out of sessions
+	pluginErrors []string
+	invalidLines []string
+	stderr       []byte
+
+	heartbeats chan time.Time
+	exited     chan struct{}
+	exitErr    error
+}
+
+func startHarness(cmd *exec.Cmd, writeTimeout time.Duration) (*harness, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start plugin failed: %w", err)
+	}
+
+	h := &harness{
+		cmd:          cmd,
+		stdin:        stdin,
+		writeTimeout: writeTimeout,
+		sessions:     map[string]chan plugin_entities.SessionMessage{},
+		heartbeats:   make(chan time.Time, 64),
+		exited:       make(chan struct{}),
+	}
+
+	stdoutDone := make(chan struct{})
+	go func() {
+		defer close(stdoutDone)
+		h.readStdout(stdout)
+	}()
+	go h.readStderr(stderr)
+	go func() {
+		<-stdoutDone
+		h.exitErr = cmd.Wait()
+		close(h.exited)
+	}()
+
+	return h, nil
+}
+
+func (h *harness) readStdout(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 1024), maxLineSize)
+	for scanner.Scan() {
+		data := scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+
+		// the data is reused by the scanner, handlers keep copies
+		line := append([]byte{}, data...)
+		err := plugin_entities.ParsePluginUniversalEvent(
+			line,
+			"",
+			h.onSession,
+			func() {
+				select {
+				case h.heartbeats <- time.Now():
+				default:
+				}
+			},
+			func(err string) {
+				h.lock.Lock()
+				h.pluginErrors = append(h.pluginErrors, err)
+				h.lock.Unlock()
+			},
+			func(sessionId string, event plugin_entities.PluginLogEvent) {},
+		)
+		if err != nil {
+			h.lock.Lock()
+			h.invalidLines = append(h.invalidLines, truncate(string(line), 256))
+			h.lock.Unlock()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		h.lock.Lock()
+		h.invalidLines = append(h.invalidLines, err.Error())
+		h.lock.Unlock()
+	}
+}
+
+func (h *harness) readStderr(stderr io.Reader) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := stderr.Read(buf)
+		if n > 0 {
+			h.lock.Lock()
+			h.stderr = append(h.stderr, buf[:n]...)
+			if len(h.stderr) > maxStderrSize {
+				h.stderr = h.stderr[len(h.stderr)-maxStderrSize:]
+			}
+			h.lock.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (h *harness) onSession(sessionId string, data []byte) {
+	message := plugin_entities.SessionMessage{}
+	if err := json.Unmarshal(data, &message); err != nil || message.Type == "" {
+		h.lock.Lock()
+		h.invalidLines = append(h.invalidLines, truncate(string(data), 256))
+		h.lock.Unlock()
+		return
+	}
+
+	// backwards invocations are refused, so plugins calling dify aren't stuck waiting
+	if message.Type == plugin_entities.SESSION_MESSAGE_TYPE_INVOKE {
+		request := struct {
+			BackwardsRequestId string `json:"backwards_request_id"`
+		}{}
+		json.Unmarshal(message.Data, &request)
+		h.write(sessionId, "backwards_response", backwards_invocation.NewErrorEvent(
+			request.BackwardsRequestId, "backwards invocations are not served by the conformance harness",
+		))
+		return
+	}
+
+	h.lock.Lock()
+	session, ok := h.sessions[sessionId]
+	h.lock.Unlock()
+	if !ok {
+		return
+	}
+	// sessions abandoned by callers are not read anymore
+	select {
+	case session <- message:
+	default:
+	}
+}
+
+// write writes a message of the session to stdin, as the daemon does
+func (h *harness) write(sessionId string, event string, data any) error {
+	line, err := json.Marshal(map[string]any{
+		"session_id":      sessionId,
+		"conversation_id": nil,
+		"message_id":      nil,
+		"app_id":          nil,
+		"endpoint_id":     nil,
+		"event":           event,
+		"data":            data,
+	})
+	if err != nil {
+		return err
+	}
+	return h.writeLine(line)
+}
+
+func (h *harness) writeLine(line []byte) error {
+	// the write is left blocked until stdin is closed if it times out
+	done := make(chan error, 1)
+	go func() {
+		h.writeLock.Lock()
+		defer h.writeLock.Unlock()
+		_, err := h.stdin.Write(append(line, '\n'))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(h.writeTimeout):
+		return ErrWriteTimeout
+	}
+}
+
+// open starts a session by a request, messages of it are received from the channel
+func (h *harness) open(request probe, extra map[string]any) (string, chan plugin_entities.SessionMessage, error) {
+	sessionId := uuid.NewString()
+	messages := make(chan plugin_entities.SessionMessage, 1024)
+	h.lock.Lock()
+	h.sessions[sessionId] = messages
+	h.lock.Unlock()
+
+	data := map[string]any{
+		"user_id": "conformance",
+		"type":    request.typ,
+		"action":  request.action,
+	}
+	for k, v := range request.data {
+		data[k] = v
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	if err := h.write(sessionId, "request", data); err != nil {
+		h.close(sessionId)
+		return "", nil, err
+	}
+	return sessionId, messages, nil
+}
+
+func (h *harness) close(sessionId string) {
+	h.lock.Lock()
+	delete(h.sessions, sessionId)
+	h.lock.Unlock()
+}
+
+// sessionResult is what a session ended with
+type sessionResult struct {
+	chunks int
+	// empty if the session ended normally
+	err string
+}
+
+// invoke sends the request and waits for the end of the session
+func (h *harness) invoke(request probe, extra map[string]any, timeout time.Duration) (*sessionResult, error) {
+	sessionId, messages, err := h.open(request, extra)
+	if err != nil {
+		return nil, err
+	}
+	defer h.close(sessionId)
+
+	result := &sessionResult{}
+	deadline := time.After(timeout)
+	for {
+		select {
+		case message := <-messages:
+			switch message.Type {
+			case plugin_entities.SESSION_MESSAGE_TYPE_STREAM:
+				if !json.Valid(message.Data) {
+					return nil, errors.New("stream chunk is not json")
+				}
+				result.chunks++
+			case plugin_entities.SESSION_MESSAGE_TYPE_END:
+				return result, nil
+			case plugin_entities.SESSION_MESSAGE_TYPE_ERROR:
+				result.err = string(message.Data)
+				if result.err == "" {
+					result.err = "error without data"
+				}
+				return result, nil
+			default:
+				return nil, fmt.Errorf("unknown session message type %s", message.Type)
+			}
+		case <-deadline:
+			return nil, ErrSessionTimeout
+		case <-h.exited:
+			return nil, ErrPluginExited
+		}
+	}
+}
+
+// waitHeartbeat waits for a heartbeat sent after the time
+func (h *harness) waitHeartbeat(after time.Time, timeout time.Duration) (time.Time, error) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case at := <-h.heartbeats:
+			if at.After(after) {
+				return at, nil
+			}
+		case <-deadline:
+			return time.Time{}, errors.New("no heartbeat in time")
+		case <-h.exited:
+			return time.Time{}, ErrPluginExited
+		}
+	}
+}
+
+func (h *harness) alive() bool {
+	select {
+	case <-h.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop closes stdin and kills the plugin if it doesn't exit within the timeout,
+// it returns true if the plugin exited by itself
+func (h *harness) stop(timeout time.Duration) bool {
+	h.stdin.Close()
+	select {
+	case <-h.exited:
+		return true
+	case <-time.After(timeout):
+		h.cmd.Process.Kill()
+		<-h.exited
+		return false
+	}
+}
+
+func (h *harness) snapshot() (pluginErrors []string, invalidLines []string, stderr string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]string{}, h.pluginErrors...), append([]string{}, h.invalidLines...), string(h.stderr)
+}
+
+// probe is a request the plugin should answer
+type probe struct {
+	typ    access_types.PluginAccessType
+	action access_types.PluginAccessAction
+	data   map[string]any
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,252 @@
+// Package credential_audit records every decryption of credentials, like endpoint settings, into an
+// append-only table, records are optionally exported to a SIEM collector as well.
+//
+// Records are written by a background writer in batches, batches failing to be written are kept and retried,
+// Record blocks once too many of them are kept and the buffer is full instead of dropping records, so
+// decryptions are never left unaudited. Written batches are exported by another background routine, so a slow
+// collector never holds up the writer.
+package credential_audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+type Reason string
+
+const (
+	REASON_INVOKE_ENDPOINT       Reason = "invoke_endpoint"
+	REASON_LIST_ENDPOINTS        Reason = "list_endpoints"
+	REASON_LIST_PLUGIN_ENDPOINTS Reason = "list_plugin_endpoints"
+	REASON_UPDATE_ENDPOINT       Reason = "update_endpoint"
+)
+
+const (
+	// CALLER_ENDPOINT is the caller of decryptions made for endpoint invocations from the outside
+	CALLER_ENDPOINT = "endpoint"
+
+	bufferSize     = 1024
+	maxBatchSize   = 128
+	maxErrorLength = 1024
+	// batches failing to be written are kept up to it, records are not taken from the buffer beyond it
+	maxPendingBatches = 64
+	// batches waiting to be exported, batches beyond it are only kept in the table
+	exportQueueSize   = 64
+	maxExportAttempts = 3
+)
+
+// Access is a decryption of credentials
+type Access struct {
+	TenantID  string
+	Namespace string
+	Identity  string
+	PluginID  string
+	UserID    string
+	SessionID string
+	Reason    Reason
+	// Caller is taken from the request if it's empty
+	Caller string
+}
+
+var (
+	records chan models.CredentialAccessLog
+	// batches written to the table waiting to be exported, nil if exporting is disabled
+	exports chan []models.CredentialAccessLog
+
+	exportURL    string
+	exportToken  string
+	exportClient = &http.Client{Timeout: 10 * time.Second}
+
+	flushInterval = time.Second
+	// delay before the first retry of an export, doubled on each retry
+	exportRetryInterval = time.Second
+)
+
+func Init(config *app.Config) {
+	if config.CredentialAuditEnabled == nil || !*config.CredentialAuditEnabled {
+		return
+	}
+
+	exportURL = config.CredentialAuditExportURL
+	exportToken = config.CredentialAuditExportToken
+
+	if exportURL != "" {
+		exports = make(chan []models.CredentialAccessLog, exportQueueSize)
+		go exportAll(exports)
+	}
+
+	records = make(chan models.CredentialAccessLog, bufferSize)
+	go write(records, storeBatch)
+}
+
+// Record records the access along with the caller, the request id and the client ip carried by ctx, err is the error
+// of the decryption if it failed
+func Record(ctx context.Context, access Access, err error) {
+	if records == nil {
+		return
+	}
+
+	logger := log.FromContext(ctx)
+	record := models.CredentialAccessLog{
+		TenantID:  access.TenantID,
+		Namespace: access.Namespace,
+		Identity:  access.Identity,
+		PluginID:  access.PluginID,
+		Caller:    access.Caller,
+		UserID:    access.UserID,
+		SessionID: access.SessionID,
+		RequestID: logger.Field(log.FIELD_REQUEST_ID),
+		ClientIP:  logger.Field(log.FIELD_CLIENT_IP),
+		Reason:    string(access.Reason),
+		Success:   err == nil,
+	}
+	if record.Caller == "" {
+		record.Caller = logger.Field(log.FIELD_CALLER)
+	}
+	if err != nil {
+		record.Error = err.Error()
+		if len(record.Error) > maxErrorLength {
+			record.Error = record.Error[:maxErrorLength]
+		}
+	}
+
+	records <- record
+}
+
+// write writes records in batches by store, batches failing to be written are retried on each tick before
+// the others, records are left in the buffer once too many batches are failing. It returns once records
+// are closed, batches still failing by then are dropped
+func write(records <-chan models.CredentialAccessLog, store func(batch []models.CredentialAccessLog) error) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]models.CredentialAccessLog, 0, maxBatchSize)
+	pending := [][]models.CredentialAccessLog{}
+
+	for {
+		input := records
+		if len(pending) >= maxPendingBatches {
+			input = nil
+		}
+
+		select {
+		case record, ok := <-input:
+			if !ok {
+				if len(batch) > 0 {
+					pending = append(pending, batch)
+				}
+				if pending = retry(pending, store); len(pending) > 0 {
+					log.Error("%d batches of credential access logs are dropped", len(pending))
+				}
+				return
+			}
+			record.CreatedAt = time.Now()
+			batch = append(batch, record)
+			if len(batch) < maxBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			pending = retry(pending, store)
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		// batches are written in order, the batch waits behind those failed
+		if len(pending) > 0 || !flush(batch, store) {
+			pending = append(pending, batch)
+		}
+		batch = make([]models.CredentialAccessLog, 0, maxBatchSize)
+	}
+}
+
+// retry writes the pending batches in order, it stops at the first one failing and returns the rest
+func retry(pending [][]models.CredentialAccessLog, store func(batch []models.CredentialAccessLog) error) [][]models.CredentialAccessLog {
+	for len(pending) > 0 {
+		if !flush(pending[0], store) {
+			return pending
+		}
+		pending = pending[1:]
+	}
+	return pending
+}
+
+func storeBatch(batch []models.CredentialAccessLog) error {
+	return db.Create(&batch)
+}
+
+// flush writes the batch and queues it to be exported, false is returned if it's not written
+func flush(batch []models.CredentialAccessLog, store func(batch []models.CredentialAccessLog) error) bool {
+	if err := store(batch); err != nil {
+		log.Error("failed to write %d credential access logs, retry later: %s", len(batch), err.Error())
+		return false
+	}
+
+	if exports != nil {
+		select {
+		case exports <- batch:
+		default:
+			log.Error("export queue of credential access logs is full, %d logs are only kept in the table", len(batch))
+		}
+	}
+	return true
+}
+
+// exportAll exports batches one by one, each one is retried with exponential backoff
+func exportAll(batches <-chan []models.CredentialAccessLog) {
+	for batch := range batches {
+		var err error
+		interval := exportRetryInterval
+		for attempt := 0; attempt < maxExportAttempts; attempt++ {
+			if attempt > 0 {
+				time.Sleep(interval)
+				interval *= 2
+			}
+			if err = export(batch); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Error("failed to export %d credential access logs: %s", len(batch), err.Error())
+		}
+	}
+}
+
+// export posts the records to the SIEM collector as newline delimited json
+func export(batch []models.CredentialAccessLog) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, record := range batch {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequest(http.MethodPost, exportURL, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	if exportToken != "" {
+		request.Header.Set("Authorization", "Bearer "+exportToken)
+	}
+
+	response, err := exportClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+	return nil
+}
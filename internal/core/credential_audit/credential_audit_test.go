@@ -0,0 +1,137 @@
+package credential_audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+func TestRecordAttributesCaller(t *testing.T) {
+	records = make(chan models.CredentialAccessLog, 2)
+	defer func() { records = nil }()
+
+	ctx := log.NewContext(context.Background(),
+		log.With(log.FIELD_REQUEST_ID, "request").With(log.FIELD_CALLER, "token:dpt_1234:dify"),
+	)
+	Record(ctx, Access{TenantID: "tenant", Identity: "endpoint", Reason: REASON_LIST_ENDPOINTS}, nil)
+	Record(ctx, Access{TenantID: "tenant", Caller: CALLER_ENDPOINT, Reason: REASON_INVOKE_ENDPOINT}, errors.New("failed"))
+
+	record := <-records
+	if record.Caller != "token:dpt_1234:dify" || record.RequestID != "request" || !record.Success {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	record = <-records
+	if record.Caller != CALLER_ENDPOINT || record.Success || record.Error != "failed" {
+		t.Fatalf("unexpected record %+v", record)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var received []models.CredentialAccessLog
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var record models.CredentialAccessLog
+			json.Unmarshal(scanner.Bytes(), &record)
+			received = append(received, record)
+		}
+	}))
+	defer server.Close()
+
+	exportURL, exportToken = server.URL, "token"
+	defer func() { exportURL, exportToken = "", "" }()
+
+	err := export([]models.CredentialAccessLog{
+		{TenantID: "a", Reason: string(REASON_LIST_ENDPOINTS)},
+		{TenantID: "b", Reason: string(REASON_UPDATE_ENDPOINT)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received[1].TenantID != "b" {
+		t.Fatalf("expected 2 records, got %+v", received)
+	}
+}
+
+func TestWriteRetriesFailedBatches(t *testing.T) {
+	flushInterval = 10 * time.Millisecond
+
+	var lock sync.Mutex
+	failures := 2
+	stored := []models.CredentialAccessLog{}
+	store := func(batch []models.CredentialAccessLog) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if failures > 0 {
+			failures--
+			return errors.New("database is down")
+		}
+		stored = append(stored, batch...)
+		return nil
+	}
+
+	defer func() { flushInterval = time.Second }()
+	input := make(chan models.CredentialAccessLog, 4)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		write(input, store)
+	}()
+	defer func() {
+		close(input)
+		<-done
+	}()
+
+	for _, tenant := range []string{"a", "b", "c"} {
+		input <- models.CredentialAccessLog{TenantID: tenant}
+		time.Sleep(15 * time.Millisecond)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		lock.Lock()
+		n := len(stored)
+		lock.Unlock()
+		if n == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(stored) != 3 || stored[0].TenantID != "a" || stored[2].TenantID != "c" {
+		t.Fatalf("expected failed batches to be written in order once the database recovers, got %+v", stored)
+	}
+}
+
+func TestFlushQueuesExports(t *testing.T) {
+	exports = make(chan []models.CredentialAccessLog, 1)
+	defer func() { exports = nil }()
+
+	batch := []models.CredentialAccessLog{{TenantID: "a"}}
+	store := func([]models.CredentialAccessLog) error { return nil }
+	if !flush(batch, store) || !flush(batch, store) {
+		t.Fatal("expected batches to be written even if the export queue is full")
+	}
+	if len(exports) != 1 {
+		t.Fatalf("expected 1 batch to be queued for exporting, got %d", len(exports))
+	}
+
+	if flush(batch, func([]models.CredentialAccessLog) error { return errors.New("failed") }) || len(exports) != 1 {
+		t.Fatal("expected batches failing to be written not to be exported")
+	}
+}
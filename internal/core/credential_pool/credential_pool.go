@@ -0,0 +1,236 @@
+// Package credential_pool takes credentials of model invocations from pools of keys configured by tenants,
+// invocations reference a pool of the provider by `{"__credential_pool__": "<name>"}` in their credentials,
+// the reference is replaced by credentials of a key of the pool picked by weights, other credentials of the
+// invocation are kept unless the key overrides them.
+//
+// A key reported rate limited by the plugin is cooled down across the cluster, invocations skip it until its
+// cooldown passes, keys are encrypted by the keyring.
+package credential_pool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
+)
+
+const (
+	// POOL_REFERENCE is the credential referencing a pool by its name
+	POOL_REFERENCE = "__credential_pool__"
+
+	defaultCooldown = time.Minute
+)
+
+var (
+	ErrExhausted = errors.New("all keys of the credential pool are cooling down")
+
+	// leases are keys taken by sessions, they're released once invocations of the sessions complete
+	leases sync.Map
+)
+
+// lease is a key taken by the session of an invocation
+type lease struct {
+	keyID    string
+	cooldown time.Duration
+}
+
+// Init makes keys re-encrypted on rotating keys, and cools keys down once invocations taking them
+// are rate limited
+func Init() {
+	keyring.RegisterStore(keyring.Store{
+		Name:      "credential_pool_keys",
+		Versions:  credentialsKeyVersions,
+		Reencrypt: reencryptCredentials,
+	})
+
+	events.Subscribe(func(event events.InvocationCompleted) {
+		value, ok := leases.LoadAndDelete(event.SessionID)
+		if !ok || !event.Failed || !RateLimited(event.Error) {
+			return
+		}
+		l := value.(lease)
+		if err := cache.Store(cooldownKey(l.keyID), time.Now().Add(l.cooldown).Unix(), l.cooldown); err != nil {
+			log.Warn("failed to cool down key %s of credential pool: %s", l.keyID, err.Error())
+		}
+	})
+}
+
+func cooldownKey(key_id string) string {
+	return "credential_pool:cooldown:" + key_id
+}
+
+// Resolve replaces the pool referenced by credentials of the invocation by credentials of a key of the pool,
+// credentials not referencing a pool are returned as they are
+func Resolve(
+	session_id string, tenant_id string, plugin_id string, provider string, credentials map[string]any,
+) (map[string]any, error) {
+	reference, ok := credentials[POOL_REFERENCE]
+	if !ok {
+		return credentials, nil
+	}
+	name, ok := reference.(string)
+	if !ok || name == "" {
+		return nil, errors.New("credential pool should be referenced by its name")
+	}
+
+	pool, err := db.GetOne[models.CredentialPool](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("plugin_id", plugin_id),
+		db.Equal("provider", provider),
+		db.Equal("name", name),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, fmt.Errorf("credential pool %s of provider %s not found", name, provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := db.GetAll[models.CredentialPoolKey](
+		db.Equal("pool_id", pool.ID),
+		db.Equal("disabled", false),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	coolingDown := map[string]bool{}
+	for _, key := range keys {
+		if _, ok := CoolingDownUntil(key.ID); ok {
+			coolingDown[key.ID] = true
+		}
+	}
+	key := pick(keys, coolingDown, rand.Float64())
+	if key == nil {
+		return nil, fmt.Errorf("%w: %s", ErrExhausted, name)
+	}
+
+	pooled, err := Open(key)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := map[string]any{}
+	for k, v := range credentials {
+		if k != POOL_REFERENCE {
+			resolved[k] = v
+		}
+	}
+	for k, v := range pooled {
+		resolved[k] = v
+	}
+
+	cooldown := time.Duration(key.CooldownSeconds) * time.Second
+	if cooldown <= 0 {
+		cooldown = defaultCooldown
+	}
+	leases.Store(session_id, lease{keyID: key.ID, cooldown: cooldown})
+	return resolved, nil
+}
+
+// Release releases the key taken by the session if the invocation didn't start
+func Release(session_id string) {
+	leases.Delete(session_id)
+}
+
+// pick picks a key by weights among keys not cooling down, r is a random number in [0, 1)
+func pick(keys []models.CredentialPoolKey, coolingDown map[string]bool, r float64) *models.CredentialPoolKey {
+	total := 0
+	for _, key := range keys {
+		if !coolingDown[key.ID] && key.Weight > 0 {
+			total += key.Weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	target := int(r * float64(total))
+	for i, key := range keys {
+		if coolingDown[key.ID] || key.Weight <= 0 {
+			continue
+		}
+		if target < key.Weight {
+			return &keys[i]
+		}
+		target -= key.Weight
+	}
+	return nil
+}
+
+// CoolingDownUntil returns when the cooldown of the key passes if it's cooling down
+func CoolingDownUntil(key_id string) (time.Time, bool) {
+	until, err := cache.Get[int64](cooldownKey(key_id))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(*until, 0), true
+}
+
+// ResetCooldown makes the key available again right away
+func ResetCooldown(key_id string) error {
+	return cache.Del(cooldownKey(key_id))
+}
+
+// RateLimited returns true if the error of an invocation is a rate limit error raised by the plugin,
+// errors of plugins are json like `{"error_type":"InvokeRateLimitError","message":"..."}`
+func RateLimited(message string) bool {
+	for i := 0; i < 2; i++ {
+		var e struct {
+			ErrorType string `json:"error_type"`
+			Message   string `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(message), &e); err != nil {
+			return false
+		}
+		if strings.Contains(e.ErrorType, "RateLimit") {
+			return true
+		}
+		// errors of plugins are wrapped once by the invocation
+		message = e.Message
+	}
+	return false
+}
+
+// Seal encrypts credentials into the key
+func Seal(key *models.CredentialPoolKey, credentials map[string]any) error {
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	key.Credentials, key.CredentialsKeyVersion, err = keyring.Encrypt(string(data))
+	return err
+}
+
+// Open decrypts credentials of the key
+func Open(key *models.CredentialPoolKey) (map[string]any, error) {
+	plain, err := keyring.Decrypt(key.Credentials, key.CredentialsKeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	credentials := map[string]any{}
+	if err := json.Unmarshal([]byte(plain), &credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// Mask returns names of credentials with their values redacted
+func Mask(credentials map[string]any) map[string]any {
+	masked := map[string]any{}
+	for name := range credentials {
+		masked[name] = redact.REDACTED
+	}
+	return masked
+}
@@ -0,0 +1,87 @@
+package credential_pool
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
+)
+
+func testKey(id string, weight int) models.CredentialPoolKey {
+	return models.CredentialPoolKey{Model: models.Model{ID: id}, Weight: weight}
+}
+
+func TestPick(t *testing.T) {
+	keys := []models.CredentialPoolKey{testKey("a", 1), testKey("b", 3), testKey("c", 0)}
+
+	cases := []struct {
+		coolingDown map[string]bool
+		r           float64
+		expected    string
+	}{
+		{nil, 0, "a"},
+		{nil, 0.24, "a"},
+		{nil, 0.25, "b"},
+		{nil, 0.99, "b"},
+		{map[string]bool{"b": true}, 0.99, "a"},
+		{map[string]bool{"a": true}, 0, "b"},
+		{map[string]bool{"a": true, "b": true}, 0, ""},
+	}
+	for i, c := range cases {
+		key := pick(keys, c.coolingDown, c.r)
+		id := ""
+		if key != nil {
+			id = key.ID
+		}
+		if id != c.expected {
+			t.Fatalf("case %d should pick %q, got %q", i, c.expected, id)
+		}
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	cases := map[string]bool{
+		`{"error_type":"InvokeRateLimitError","message":"429 too many requests"}`:                                    true,
+		`{"error_type":"PluginInvokeError","message":"{\"error_type\":\"InvokeRateLimitError\",\"message\":\"x\"}"}`: true,
+		`{"error_type":"InvokeAuthorizationError","message":"invalid api key"}`:                                      false,
+		`{"error_type":"PluginInvokeError","message":"rate limited"}`:                                                false,
+		`rate limited`: false,
+	}
+	for message, expected := range cases {
+		if RateLimited(message) != expected {
+			t.Fatalf("rate limited of %s should be %v", message, expected)
+		}
+	}
+}
+
+func TestResolveWithoutReference(t *testing.T) {
+	credentials := map[string]any{"api_key": "sk-123"}
+	resolved, err := Resolve("session", "tenant", "langgenius/openai", "openai", credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved["api_key"] != "sk-123" || len(resolved) != 1 {
+		t.Fatalf("credentials not referencing pools should be kept, got %v", resolved)
+	}
+
+	if _, err := Resolve("session", "tenant", "langgenius/openai", "openai", map[string]any{POOL_REFERENCE: 1}); err == nil {
+		t.Fatal("pools should be referenced by names")
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	key := models.CredentialPoolKey{}
+	if err := Seal(&key, map[string]any{"api_key": "sk-123", "endpoint": "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := Open(&key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials["api_key"] != "sk-123" {
+		t.Fatalf("unexpected credentials %v", credentials)
+	}
+	if masked := Mask(credentials); masked["api_key"] != redact.REDACTED || masked["endpoint"] != redact.REDACTED {
+		t.Fatalf("credentials should be masked, got %v", masked)
+	}
+}
@@ -0,0 +1,81 @@
+package credential_pool
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+func credentialsKeyVersions() (map[int]int64, error) {
+	type versionCount struct {
+		Version int
+		Count   int64
+	}
+
+	counts, err := db.GetAny[[]versionCount](
+		"SELECT credentials_key_version AS version, COUNT(*) AS count FROM credential_pool_keys GROUP BY credentials_key_version",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[int]int64{}
+	for _, count := range counts {
+		versions[count.Version] = count.Count
+	}
+	return versions, nil
+}
+
+// reencryptCredentials encrypts credentials not encrypted by the active key again, each key is locked and
+// re-encrypted in its own transaction
+func reencryptCredentials() (int, error) {
+	active := keyring.ActiveVersion()
+	keys, err := db.GetAll[models.CredentialPoolKey](
+		db.NotEqual("credentials_key_version", active),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	reencrypted := 0
+	var errs []error
+	for _, key := range keys {
+		err := db.WithTransaction(func(tx *gorm.DB) error {
+			record, err := db.GetOne[models.CredentialPoolKey](
+				db.WithTransactionContext(tx),
+				db.Equal("id", key.ID),
+				db.WLock(),
+			)
+			if err != nil {
+				return err
+			}
+			if record.CredentialsKeyVersion == active {
+				// re-encrypted by another node
+				return nil
+			}
+
+			credentials, err := keyring.Decrypt(record.Credentials, record.CredentialsKeyVersion)
+			if err != nil {
+				return err
+			}
+			record.Credentials, record.CredentialsKeyVersion, err = keyring.Encrypt(credentials)
+			if err != nil {
+				return err
+			}
+			if err := db.Update(&record, tx); err != nil {
+				return err
+			}
+
+			reencrypted++
+			return nil
+		})
+		if err != nil && !errors.Is(err, db.ErrDatabaseNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return reencrypted, errors.Join(errs...)
+}
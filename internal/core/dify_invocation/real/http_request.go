@@ -71,8 +71,10 @@ func StreamResponse[T any](i *RealBackwardsInvocation, method string, path strin
 	routine.Submit(map[string]string{
 		"module":   "dify_invocation",
 		"function": "StreamResponse",
+		"path":     path,
 	}, func() {
 		defer newResponse.Close()
+		defer routine.ReportPanic(newResponse.WriteError)
 		for response.Next() {
 			t, err := response.Read()
 			if err != nil {
@@ -99,11 +99,13 @@ const (
 	STORAGE_OPT_GET StorageOpt = "get"
 	STORAGE_OPT_SET StorageOpt = "set"
 	STORAGE_OPT_DEL StorageOpt = "del"
+	// STORAGE_OPT_APPEND appends the value, sizable values are written chunk by chunk
+	STORAGE_OPT_APPEND StorageOpt = "append"
 )
 
 func isStorageOpt(fl validator.FieldLevel) bool {
 	opt := StorageOpt(fl.Field().String())
-	return opt == STORAGE_OPT_GET || opt == STORAGE_OPT_SET || opt == STORAGE_OPT_DEL
+	return opt == STORAGE_OPT_GET || opt == STORAGE_OPT_SET || opt == STORAGE_OPT_DEL || opt == STORAGE_OPT_APPEND
 }
 
 func init() {
@@ -114,6 +116,10 @@ type InvokeStorageRequest struct {
 	Opt   StorageOpt `json:"opt" validate:"required,storage_opt"`
 	Key   string     `json:"key" validate:"required"`
 	Value string     `json:"value"` // encoded in hex, optional
+	// Offset and Length read a range of the value by `get`, sizable values are read chunk by chunk,
+	// the whole value is read if both are omitted
+	Offset *int64 `json:"offset" validate:"omitempty,min=0"`
+	Length *int64 `json:"length" validate:"omitempty,min=0"`
 }
 
 type InvokeAppRequest struct {
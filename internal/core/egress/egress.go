@@ -0,0 +1,357 @@
+// Package egress enforces the network permission declared by plugins, local plugins reach the outside
+// through a filtering proxy of the daemon which only lets connections to the declared domains through.
+//
+// Every plugin process authenticates to the proxy with its own credentials so that connections are
+// attributed to the plugin. Plugins ignoring HTTP_PROXY/HTTPS_PROXY are not covered, run the daemon in a
+// network namespace without direct egress to make the policy binding.
+package egress
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	proxyUser   = "plugin"
+	dialTimeout = 10 * time.Second
+)
+
+type policy struct {
+	pluginID   string
+	permission *plugin_entities.PluginPermissionRequirement
+}
+
+var (
+	listener net.Listener
+
+	// plugins declaring no network permission are only restricted if it's set
+	enforceUndeclared bool
+
+	// upstream proxies configured for the daemon
+	httpUpstream  *url.URL
+	httpsUpstream *url.URL
+
+	// token -> *policy
+	policies sync.Map
+
+	// replaced as a whole once allowlists are reloaded, connections are made concurrently
+	protection atomic.Pointer[ssrfProtection]
+)
+
+// ssrfProtection keeps plugins from reaching the network of the daemon if the guard is set
+type ssrfProtection struct {
+	guard  *network.SSRFGuard
+	dialer *net.Dialer
+}
+
+var unprotected = &ssrfProtection{dialer: &net.Dialer{Timeout: dialTimeout}}
+
+func currentProtection() *ssrfProtection {
+	if p := protection.Load(); p != nil {
+		return p
+	}
+	return unprotected
+}
+
+func Init(config *app.Config) {
+	if !config.PluginEgressPolicyEnabled {
+		return
+	}
+
+	var err error
+	if httpUpstream, err = parseUpstream(config.HttpProxy); err != nil {
+		log.Panic("invalid http proxy: %s", err.Error())
+	}
+	if httpsUpstream, err = parseUpstream(config.HttpsProxy); err != nil {
+		log.Panic("invalid https proxy: %s", err.Error())
+	}
+
+	enforceUndeclared = config.PluginEgressEnforceUndeclared
+
+	if err := Configure(config); err != nil {
+		log.Panic("init ssrf protection failed: %s", err.Error())
+	}
+
+	listener, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", config.PluginEgressProxyPort))
+	if err != nil {
+		log.Panic("start egress proxy failed: %s", err.Error())
+	}
+
+	log.Info("egress proxy of plugins listening on %s", listener.Addr().String())
+
+	go func() {
+		if err := http.Serve(listener, http.HandlerFunc(serve)); err != nil {
+			log.Error("egress proxy stopped: %s", err.Error())
+		}
+	}()
+}
+
+// Configure updates allowlists of the ssrf protection, the previous ones are kept if they're invalid
+func Configure(config *app.Config) error {
+	if !config.PluginEgressPolicyEnabled || config.SSRFProtectionEnabled == nil || !*config.SSRFProtectionEnabled {
+		return nil
+	}
+
+	g, err := network.NewSSRFGuard(config.SSRFAllowedCIDRs, config.SSRFAllowedSchemes)
+	if err != nil {
+		return err
+	}
+	protection.Store(&ssrfProtection{guard: g, dialer: g.Dialer(dialTimeout)})
+	return nil
+}
+
+func parseUpstream(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+
+	return url.Parse(proxy)
+}
+
+// Enabled reports whether outbound connections of plugins are filtered
+func Enabled() bool {
+	return listener != nil
+}
+
+// Register returns the proxy url the plugin has to use along with a function releasing it
+// once the plugin stops
+func Register(
+	pluginID string,
+	permission *plugin_entities.PluginPermissionRequirement,
+) (string, func()) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Panic("generate egress proxy token failed: %s", err.Error())
+	}
+	token := hex.EncodeToString(buf)
+
+	policies.Store(token, &policy{pluginID: pluginID, permission: permission})
+
+	proxy := fmt.Sprintf("http://%s:%s@%s", proxyUser, token, listener.Addr().String())
+	return proxy, func() {
+		policies.Delete(token)
+	}
+}
+
+func (p *policy) allow(host string) bool {
+	if p.permission == nil || p.permission.Network == nil {
+		return !enforceUndeclared
+	}
+
+	return p.permission.AllowDomain(host)
+}
+
+func authenticate(r *http.Request) *policy {
+	auth, ok := strings.CutPrefix(r.Header.Get("Proxy-Authorization"), "Basic ")
+	if !ok {
+		return nil
+	}
+
+	credentials, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return nil
+	}
+
+	user, token, ok := strings.Cut(string(credentials), ":")
+	if !ok || user != proxyUser {
+		return nil
+	}
+
+	p, ok := policies.Load(token)
+	if !ok {
+		return nil
+	}
+
+	return p.(*policy)
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	p := authenticate(r)
+	if p == nil {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="dify-plugin-daemon"`)
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+		return
+	}
+
+	var hostport string
+	if r.Method == http.MethodConnect {
+		hostport = r.Host
+	} else {
+		if r.URL.Host == "" {
+			http.Error(w, "absolute url required", http.StatusBadRequest)
+			return
+		}
+		hostport = r.URL.Host
+	}
+
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+
+	if !p.allow(host) {
+		log.Warn("egress of plugin %s to %s denied, the domain is not declared in its network permission", p.pluginID, host)
+		http.Error(w, fmt.Sprintf("egress to %s is not allowed by the plugin manifest", host), http.StatusForbidden)
+		return
+	}
+
+	// connections made by upstream proxies can't be checked on connecting, check the host beforehand
+	upstream := httpUpstream
+	if r.Method == http.MethodConnect {
+		upstream = httpsUpstream
+	}
+	if guard := currentProtection().guard; guard != nil && upstream != nil {
+		if err := guard.CheckHost(r.Context(), host); err != nil {
+			log.Warn("egress of plugin %s to %s denied: %s", p.pluginID, host, err.Error())
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	if r.Method == http.MethodConnect {
+		tunnel(w, hostport)
+	} else {
+		forward(w, r)
+	}
+}
+
+// tunnel relays a CONNECT request to hostport
+func tunnel(w http.ResponseWriter, hostport string) {
+	upstream, err := dial(hostport)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		conn.Close()
+		upstream.Close()
+		return
+	}
+
+	go func() {
+		defer upstream.Close()
+		// bytes sent along with the CONNECT request are already buffered
+		io.Copy(upstream, buffered)
+	}()
+
+	go func() {
+		defer conn.Close()
+		io.Copy(conn, upstream)
+	}()
+}
+
+// dial connects to hostport, through the upstream https proxy if there is one
+func dial(hostport string) (net.Conn, error) {
+	if httpsUpstream == nil {
+		return currentProtection().dialer.Dial("tcp", hostport)
+	}
+
+	address := httpsUpstream.Host
+	if httpsUpstream.Port() == "" {
+		address = net.JoinHostPort(httpsUpstream.Hostname(), "80")
+	}
+
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: hostport},
+		Host:   hostport,
+		Header: http.Header{},
+	}
+	if httpsUpstream.User != nil {
+		password, _ := httpsUpstream.User.Password()
+		request.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString(
+			[]byte(httpsUpstream.User.Username()+":"+password),
+		))
+	}
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, errors.New("upstream proxy refused the connection: " + response.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+var transport = &http.Transport{
+	Proxy: func(r *http.Request) (*url.URL, error) {
+		return httpUpstream, nil
+	},
+	DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return currentProtection().dialer.DialContext(ctx, network, address)
+	},
+	TLSHandshakeTimeout: dialTimeout,
+}
+
+// forward relays a plain http request
+func forward(w http.ResponseWriter, r *http.Request) {
+	request := r.Clone(r.Context())
+	request.RequestURI = ""
+	request.Header.Del("Proxy-Authorization")
+	request.Header.Del("Proxy-Connection")
+
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+
+	for key, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
@@ -0,0 +1,143 @@
+package egress
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func networkPermission(domains ...string) *plugin_entities.PluginPermissionRequirement {
+	return &plugin_entities.PluginPermissionRequirement{
+		Network: &plugin_entities.PluginPermissionNetworkRequirement{Enabled: true, Domains: domains},
+	}
+}
+
+func clientOf(t *testing.T, proxy string, transport *http.Transport) *http.Client {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: transport}
+}
+
+func TestEgressProxy(t *testing.T) {
+	Init(&app.Config{PluginEgressPolicyEnabled: true})
+	if !Enabled() {
+		t.Fatal("egress proxy should be enabled")
+	}
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer tlsBackend.Close()
+
+	allowed, release := Register("allowed", networkPermission("127.0.0.1"))
+	defer release()
+
+	denied, releaseDenied := Register("denied", networkPermission("example.com"))
+	defer releaseDenied()
+
+	// plain http is forwarded
+	response, err := clientOf(t, allowed, &http.Transport{}).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Fatalf("unexpected response %d %s", response.StatusCode, body)
+	}
+
+	// https is tunneled
+	transport := tlsBackend.Client().Transport.(*http.Transport).Clone()
+	response, err = clientOf(t, allowed, transport).Get(tlsBackend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", response.StatusCode)
+	}
+
+	// undeclared domains are denied
+	response, err = clientOf(t, denied, &http.Transport{}).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", response.StatusCode)
+	}
+
+	_, err = clientOf(t, denied, tlsBackend.Client().Transport.(*http.Transport).Clone()).Get(tlsBackend.URL)
+	if err == nil {
+		t.Fatal("tunnel to an undeclared domain should fail")
+	}
+
+	// released credentials are rejected
+	releaseDenied()
+	response, err = clientOf(t, denied, &http.Transport{}).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusProxyAuthRequired {
+		t.Fatalf("expected 407, got %d", response.StatusCode)
+	}
+}
+
+func TestPolicyUndeclared(t *testing.T) {
+	p := &policy{pluginID: "undeclared"}
+
+	enforceUndeclared = false
+	if !p.allow("example.com") {
+		t.Error("plugins without network permission should be unrestricted by default")
+	}
+
+	enforceUndeclared = true
+	defer func() { enforceUndeclared = false }()
+	if p.allow("example.com") {
+		t.Error("plugins without network permission should be denied once enforced")
+	}
+}
+
+func TestEgressProxySSRFGuard(t *testing.T) {
+	if !Enabled() {
+		Init(&app.Config{PluginEgressPolicyEnabled: true})
+	}
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	original := protection.Load()
+	guard, _ := network.NewSSRFGuard(nil, []string{"http", "https"})
+	protection.Store(&ssrfProtection{guard: guard, dialer: guard.Dialer(dialTimeout)})
+	defer protection.Store(original)
+
+	proxy, release := Register("loopback", networkPermission("127.0.0.1"))
+	defer release()
+
+	// declared domains still can't reach the network of the daemon
+	response, err := clientOf(t, proxy, &http.Transport{}).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", response.StatusCode)
+	}
+}
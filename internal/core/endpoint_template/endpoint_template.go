@@ -0,0 +1,246 @@
+// Package endpoint_template renders endpoints materialized from templates, names, settings, path prefixes
+// and header values of templates reference variables like `{{team}}` which are replaced by values given
+// for each endpoint.
+//
+// Templates hold no secrets, secret settings can only be set to secret variables, values of variables and
+// the path prefixes and headers rendered by them are encrypted by the keyring along with each endpoint.
+package endpoint_template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var (
+	placeholder  = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Rendered is an endpoint rendered from a template by values of its variables
+type Rendered struct {
+	Name     string
+	Settings map[string]any
+	Mapping  Mapping
+}
+
+// Mapping is applied to requests sent to the plugin by the endpoint
+type Mapping struct {
+	PathPrefix string            `json:"path_prefix,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+// instanceData is encrypted into data of instances
+type instanceData struct {
+	Values  map[string]string `json:"values"`
+	Mapping Mapping           `json:"mapping"`
+}
+
+// references returns variables referenced by the string
+func references(s string) []string {
+	names := []string{}
+	for _, match := range placeholder.FindAllStringSubmatch(s, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// Validate checks variables referenced by the template are declared, secret settings of the endpoint
+// declared by the plugin must be a single secret variable so that templates carry no secrets
+func Validate(template *models.EndpointTemplate, settings []plugin_entities.ProviderConfig) error {
+	variables := map[string]models.EndpointTemplateVariable{}
+	for _, variable := range template.Variables {
+		if !variableName.MatchString(variable.Name) {
+			return fmt.Errorf("invalid variable name %q", variable.Name)
+		}
+		if _, ok := variables[variable.Name]; ok {
+			return fmt.Errorf("variable %s is declared more than once", variable.Name)
+		}
+		variables[variable.Name] = variable
+	}
+
+	check := func(field string, s string) error {
+		for _, name := range references(s) {
+			if _, ok := variables[name]; !ok {
+				return fmt.Errorf("%s references undeclared variable %s", field, name)
+			}
+		}
+		return nil
+	}
+
+	if err := check("endpoint_name", template.EndpointName); err != nil {
+		return err
+	}
+	if template.PathPrefix != "" && !strings.HasPrefix(template.PathPrefix, "/") {
+		return errors.New("path_prefix should start with /")
+	}
+	if err := check("path_prefix", template.PathPrefix); err != nil {
+		return err
+	}
+	for name, value := range template.Headers {
+		canonical := http.CanonicalHeaderKey(name)
+		if canonical == "Host" || strings.HasPrefix(canonical, "Dify-") {
+			return fmt.Errorf("header %s can't be set by templates", name)
+		}
+		if err := check("header "+name, value); err != nil {
+			return err
+		}
+	}
+
+	secrets := map[string]bool{}
+	for _, config := range settings {
+		if config.Type == plugin_entities.CONFIG_TYPE_SECRET_INPUT {
+			secrets[config.Name] = true
+		}
+	}
+	for key, value := range template.Settings {
+		s, ok := value.(string)
+		if secrets[key] {
+			match := placeholder.FindStringSubmatch(s)
+			if !ok || match == nil || match[0] != s || !variables[match[1]].Secret {
+				return fmt.Errorf("secret setting %s should be a secret variable like {{%s}}", key, key)
+			}
+		}
+		if err := checkValue("setting "+key, value, check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkValue(field string, value any, check func(field string, s string) error) error {
+	switch v := value.(type) {
+	case string:
+		return check(field, v)
+	case map[string]any:
+		for key, item := range v {
+			if err := checkValue(field+"."+key, item, check); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := checkValue(field, item, check); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Render renders the endpoint by values of all the variables of the template
+func Render(template *models.EndpointTemplate, values map[string]string) (*Rendered, error) {
+	declared := map[string]bool{}
+	for _, variable := range template.Variables {
+		declared[variable.Name] = true
+		if _, ok := values[variable.Name]; !ok {
+			return nil, fmt.Errorf("value of variable %s is missing", variable.Name)
+		}
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("variable %s is not declared by the template", name)
+		}
+	}
+
+	interpolate := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(match string) string {
+			return values[placeholder.FindStringSubmatch(match)[1]]
+		})
+	}
+
+	rendered := &Rendered{
+		Name:     interpolate(template.EndpointName),
+		Settings: map[string]any{},
+		Mapping: Mapping{
+			PathPrefix: strings.TrimSuffix(interpolate(template.PathPrefix), "/"),
+		},
+	}
+	if rendered.Name == "" {
+		return nil, errors.New("name of the endpoint is empty")
+	}
+	for _, segment := range strings.Split(rendered.Mapping.PathPrefix, "/") {
+		if segment == ".." || segment == "." {
+			return nil, fmt.Errorf("invalid path prefix %s", rendered.Mapping.PathPrefix)
+		}
+	}
+	for key, value := range template.Settings {
+		rendered.Settings[key] = renderValue(value, interpolate)
+	}
+	if len(template.Headers) > 0 {
+		rendered.Mapping.Headers = map[string]string{}
+		for name, value := range template.Headers {
+			value = interpolate(value)
+			if strings.ContainsAny(value, "\r\n") {
+				return nil, fmt.Errorf("invalid value of header %s", name)
+			}
+			rendered.Mapping.Headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	return rendered, nil
+}
+
+func renderValue(value any, interpolate func(string) string) any {
+	switch v := value.(type) {
+	case string:
+		return interpolate(v)
+	case map[string]any:
+		rendered := map[string]any{}
+		for key, item := range v {
+			rendered[key] = renderValue(item, interpolate)
+		}
+		return rendered
+	case []any:
+		rendered := make([]any, len(v))
+		for i, item := range v {
+			rendered[i] = renderValue(item, interpolate)
+		}
+		return rendered
+	}
+	return value
+}
+
+// MaskValues returns values of the variables with the secret ones redacted
+func MaskValues(template *models.EndpointTemplate, values map[string]string) map[string]string {
+	masked := map[string]string{}
+	for name, value := range values {
+		masked[name] = value
+	}
+	for _, variable := range template.Variables {
+		if _, ok := masked[variable.Name]; ok && variable.Secret {
+			masked[variable.Name] = redact.REDACTED
+		}
+	}
+	return masked
+}
+
+// Seal encrypts values and the mapping into data of the instance
+func Seal(instance *models.EndpointTemplateInstance, values map[string]string, mapping Mapping) error {
+	data, err := json.Marshal(instanceData{Values: values, Mapping: mapping})
+	if err != nil {
+		return err
+	}
+	instance.Data, instance.DataKeyVersion, err = keyring.Encrypt(string(data))
+	return err
+}
+
+// Open decrypts values and the mapping of the instance
+func Open(instance *models.EndpointTemplateInstance) (map[string]string, Mapping, error) {
+	plain, err := keyring.Decrypt(instance.Data, instance.DataKeyVersion)
+	if err != nil {
+		return nil, Mapping{}, err
+	}
+	data := instanceData{}
+	if err := json.Unmarshal([]byte(plain), &data); err != nil {
+		return nil, Mapping{}, err
+	}
+	return data.Values, data.Mapping, nil
+}
@@ -0,0 +1,120 @@
+package endpoint_template
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func testTemplate() *models.EndpointTemplate {
+	return &models.EndpointTemplate{
+		EndpointName: "webhook-{{team}}",
+		Variables: []models.EndpointTemplateVariable{
+			{Name: "team"},
+			{Name: "token", Secret: true},
+		},
+		Settings: map[string]any{
+			"token":   "{{token}}",
+			"channel": "#{{ team }}-alerts",
+			"options": map[string]any{"labels": []any{"{{team}}", 1}},
+		},
+		PathPrefix: "/teams/{{team}}/",
+		Headers:    map[string]string{"x-team": "{{team}}"},
+	}
+}
+
+var testSettings = []plugin_entities.ProviderConfig{
+	{Name: "token", Type: plugin_entities.CONFIG_TYPE_SECRET_INPUT},
+	{Name: "channel", Type: plugin_entities.CONFIG_TYPE_TEXT_INPUT},
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(testTemplate(), testSettings); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []func(template *models.EndpointTemplate){
+		func(template *models.EndpointTemplate) {
+			template.Variables = append(template.Variables, models.EndpointTemplateVariable{Name: "team"})
+		},
+		func(template *models.EndpointTemplate) {
+			template.Variables = append(template.Variables, models.EndpointTemplateVariable{Name: "1st"})
+		},
+		func(template *models.EndpointTemplate) { template.EndpointName = "webhook-{{unknown}}" },
+		func(template *models.EndpointTemplate) { template.PathPrefix = "teams/{{team}}" },
+		func(template *models.EndpointTemplate) { template.Headers = map[string]string{"dify-hook-id": "x"} },
+		func(template *models.EndpointTemplate) { template.Headers = map[string]string{"host": "x"} },
+		// secrets can't be written into templates or mixed with other text
+		func(template *models.EndpointTemplate) { template.Settings["token"] = "sk-123" },
+		func(template *models.EndpointTemplate) { template.Settings["token"] = "Bearer {{token}}" },
+		func(template *models.EndpointTemplate) { template.Settings["token"] = "{{team}}" },
+		func(template *models.EndpointTemplate) {
+			template.Settings["options"] = map[string]any{"labels": []any{"{{unknown}}"}}
+		},
+	}
+	for i, modify := range invalid {
+		template := testTemplate()
+		modify(template)
+		if err := Validate(template, testSettings); err == nil {
+			t.Fatalf("template %d should be invalid", i)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	rendered, err := Render(testTemplate(), map[string]string{"team": "acme", "token": "sk-123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rendered.Name != "webhook-acme" {
+		t.Fatalf("unexpected name %s", rendered.Name)
+	}
+	if rendered.Settings["token"] != "sk-123" || rendered.Settings["channel"] != "#acme-alerts" {
+		t.Fatalf("unexpected settings %v", rendered.Settings)
+	}
+	labels := rendered.Settings["options"].(map[string]any)["labels"].([]any)
+	if labels[0] != "acme" || labels[1] != 1 {
+		t.Fatalf("nested settings should be rendered, got %v", labels)
+	}
+	if rendered.Mapping.PathPrefix != "/teams/acme" || rendered.Mapping.Headers["X-Team"] != "acme" {
+		t.Fatalf("unexpected mapping %+v", rendered.Mapping)
+	}
+
+	for _, values := range []map[string]string{
+		{"team": "acme"},
+		{"team": "acme", "token": "sk-123", "extra": "x"},
+		{"team": "../admin", "token": "sk-123"},
+		{"team": "acme\r\nX-Injected: 1", "token": "sk-123"},
+	} {
+		if _, err := Render(testTemplate(), values); err == nil {
+			t.Fatalf("values %v should be refused", values)
+		}
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	template := testTemplate()
+	values := map[string]string{"team": "acme", "token": "sk-123"}
+	mapping := Mapping{PathPrefix: "/teams/acme", Headers: map[string]string{"X-Team": "acme"}}
+
+	instance := models.EndpointTemplateInstance{}
+	if err := Seal(&instance, values, mapping); err != nil {
+		t.Fatal(err)
+	}
+	opened, openedMapping, err := Open(&instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened["token"] != "sk-123" || openedMapping.PathPrefix != mapping.PathPrefix ||
+		openedMapping.Headers["X-Team"] != "acme" {
+		t.Fatalf("unexpected values %v and mapping %+v", opened, openedMapping)
+	}
+
+	masked := MaskValues(template, opened)
+	if masked["token"] != redact.REDACTED || masked["team"] != "acme" || opened["token"] != "sk-123" {
+		t.Fatalf("only secret values should be masked, got %v", masked)
+	}
+}
@@ -0,0 +1,90 @@
+package endpoint_template
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// Init makes data of instances re-encrypted on rotating keys
+func Init() {
+	keyring.RegisterStore(keyring.Store{
+		Name:      "endpoint_template_instances",
+		Versions:  dataKeyVersions,
+		Reencrypt: reencryptData,
+	})
+}
+
+func dataKeyVersions() (map[int]int64, error) {
+	type versionCount struct {
+		Version int
+		Count   int64
+	}
+
+	counts, err := db.GetAny[[]versionCount](
+		"SELECT data_key_version AS version, COUNT(*) AS count FROM endpoint_template_instances GROUP BY data_key_version",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[int]int64{}
+	for _, count := range counts {
+		versions[count.Version] = count.Count
+	}
+	return versions, nil
+}
+
+// reencryptData encrypts data not encrypted by the active key again, each instance is locked and
+// re-encrypted in its own transaction
+func reencryptData() (int, error) {
+	active := keyring.ActiveVersion()
+	instances, err := db.GetAll[models.EndpointTemplateInstance](
+		db.NotEqual("data_key_version", active),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	reencrypted := 0
+	var errs []error
+	for _, instance := range instances {
+		err := db.WithTransaction(func(tx *gorm.DB) error {
+			record, err := db.GetOne[models.EndpointTemplateInstance](
+				db.WithTransactionContext(tx),
+				db.Equal("id", instance.ID),
+				db.WLock(),
+			)
+			if err != nil {
+				return err
+			}
+			if record.DataKeyVersion == active {
+				// re-encrypted by another node
+				return nil
+			}
+
+			data, err := keyring.Decrypt(record.Data, record.DataKeyVersion)
+			if err != nil {
+				return err
+			}
+			record.Data, record.DataKeyVersion, err = keyring.Encrypt(data)
+			if err != nil {
+				return err
+			}
+			if err := db.Update(&record, tx); err != nil {
+				return err
+			}
+
+			reencrypted++
+			return nil
+		})
+		if err != nil && !errors.Is(err, db.ErrDatabaseNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return reencrypted, errors.Join(errs...)
+}
@@ -0,0 +1,50 @@
+package error_report
+
+import (
+	"sync"
+	"time"
+)
+
+// crashLoopDetector tells a plugin is crash looping once it crashes threshold times within the window,
+// a crash loop is reported once per window so that a plugin crashing forever doesn't flood the backend
+type crashLoopDetector struct {
+	threshold int
+	window    time.Duration
+
+	lock       sync.Mutex
+	crashes    map[string][]time.Time
+	reportedAt map[string]time.Time
+}
+
+func newCrashLoopDetector(threshold int, window time.Duration) *crashLoopDetector {
+	return &crashLoopDetector{
+		threshold:  threshold,
+		window:     window,
+		crashes:    map[string][]time.Time{},
+		reportedAt: map[string]time.Time{},
+	}
+}
+
+// crashed records a crash of the plugin and returns true if the crash loop should be reported
+func (d *crashLoopDetector) crashed(plugin string, now time.Time) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	recent := []time.Time{}
+	for _, at := range d.crashes[plugin] {
+		if now.Sub(at) < d.window {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+	d.crashes[plugin] = recent
+
+	if len(recent) < d.threshold {
+		return false
+	}
+	if reportedAt, ok := d.reportedAt[plugin]; ok && now.Sub(reportedAt) < d.window {
+		return false
+	}
+	d.reportedAt[plugin] = now
+	return true
+}
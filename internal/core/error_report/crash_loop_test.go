@@ -0,0 +1,29 @@
+package error_report
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCrashLoopDetector(t *testing.T) {
+	d := newCrashLoopDetector(3, time.Minute)
+	now := time.Now()
+
+	if d.crashed("a", now) || d.crashed("a", now.Add(10*time.Second)) {
+		t.Fatal("crash loop reported before reaching the threshold")
+	}
+	if !d.crashed("a", now.Add(20*time.Second)) {
+		t.Fatal("crash loop not reported once reaching the threshold")
+	}
+	if d.crashed("a", now.Add(30*time.Second)) {
+		t.Fatal("crash loop reported twice within the window")
+	}
+	if d.crashed("b", now.Add(30*time.Second)) {
+		t.Fatal("crashes of another plugin are counted")
+	}
+
+	// crashes out of the window are forgotten
+	if d.crashed("a", now.Add(5*time.Minute)) {
+		t.Fatal("crash loop reported after crashes expired")
+	}
+}
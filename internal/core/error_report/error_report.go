@@ -0,0 +1,85 @@
+// Package error_report reports plugin crash loops and failed invocations to sentry along with the identity
+// of the plugin, panics recovered in routines are reported by the routine package.
+//
+// Sentry is initialized with the routine pool, events are dropped by the sdk if it's disabled.
+package error_report
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// stderr attached to crash reports is truncated to its tail
+const maxStderrLength = 4096
+
+var crashLoops *crashLoopDetector
+
+func Init(config *app.Config) {
+	if !config.SentryEnabled {
+		return
+	}
+
+	crashLoops = newCrashLoopDetector(
+		config.SentryCrashLoopThreshold,
+		time.Duration(config.SentryCrashLoopWindow)*time.Second,
+	)
+	events.Subscribe(func(event events.PluginCrashed) {
+		if crashLoops.crashed(event.PluginUniqueIdentifier.String(), time.Now()) {
+			reportCrashLoop(event)
+		}
+	})
+
+	if *config.SentryInvocationErrorsEnabled {
+		events.Subscribe(func(event events.InvocationCompleted) {
+			if event.Failed {
+				reportInvocationError(event)
+			}
+		})
+	}
+}
+
+func pluginTags(scope *sentry.Scope, identity plugin_entities.PluginUniqueIdentifier) {
+	scope.SetTag("plugin_id", identity.PluginID())
+	scope.SetTag("plugin_unique_identifier", identity.String())
+}
+
+func reportCrashLoop(event events.PluginCrashed) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		pluginTags(scope, event.PluginUniqueIdentifier)
+		scope.SetTag("runtime_type", string(event.RuntimeType))
+		scope.SetLevel(sentry.LevelError)
+
+		stderr := event.Stderr
+		if len(stderr) > maxStderrLength {
+			stderr = stderr[len(stderr)-maxStderrLength:]
+		}
+		scope.SetContext("plugin", sentry.Context{
+			"restarts": event.Restarts,
+			"stderr":   stderr,
+		})
+		// crashes of the same plugin are grouped into one issue
+		scope.SetFingerprint([]string{"plugin_crash_loop", event.PluginUniqueIdentifier.String()})
+
+		sentry.CaptureMessage(fmt.Sprintf("plugin %s is crash looping", event.PluginUniqueIdentifier))
+	})
+}
+
+func reportInvocationError(event events.InvocationCompleted) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		pluginTags(scope, event.PluginUniqueIdentifier)
+		scope.SetTag("tenant_id", event.TenantID)
+		scope.SetTag("action", string(event.Action))
+		scope.SetContext("session", sentry.Context{
+			"session_id": event.SessionID,
+			"latency":    event.Latency.Milliseconds(),
+		})
+		scope.SetFingerprint([]string{"plugin_invocation_error", event.PluginUniqueIdentifier.PluginID(), string(event.Action)})
+
+		sentry.CaptureException(fmt.Errorf("invoke plugin %s failed: %s", event.PluginUniqueIdentifier, event.Error))
+	})
+}
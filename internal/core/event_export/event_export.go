@@ -0,0 +1,279 @@
+// Package event_export publishes events of the bus to kafka or nats, so usage and lifecycle of plugins are fed
+// into data pipelines of deployments, payloads are versioned by SCHEMA_VERSION and published in json.
+package event_export
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	// SCHEMA_VERSION is bumped once fields of payloads are renamed or removed, added fields don't bump it
+	SCHEMA_VERSION = 1
+
+	TYPE_KAFKA = "kafka"
+	TYPE_NATS  = "nats"
+
+	// HEADER_SCHEMA_VERSION and HEADER_EVENT are sent as headers of messages, so consumers route
+	// messages without decoding them
+	HEADER_SCHEMA_VERSION = "dify-schema-version"
+	HEADER_EVENT          = "dify-event"
+
+	batchSize      = 100
+	flushInterval  = 100 * time.Millisecond
+	publishTimeout = 10 * time.Second
+)
+
+// Envelope is the payload of a message
+type Envelope struct {
+	SchemaVersion int          `json:"schema_version"`
+	ID            string       `json:"id"`
+	Event         events.Topic `json:"event"`
+	// Timestamp is in milliseconds
+	Timestamp int64 `json:"timestamp"`
+	// TenantID is empty for events not bound to a tenant, like crashes of plugins
+	TenantID string         `json:"tenant_id,omitempty"`
+	Data     map[string]any `json:"data"`
+}
+
+// Message is published to the topic, or the subject of nats
+type Message struct {
+	Topic string
+	// Key keeps messages of a plugin in order on partitions of kafka
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+// Publisher publishes messages to a message bus
+type Publisher interface {
+	Publish(ctx context.Context, messages []Message) error
+	Close() error
+}
+
+type exporter struct {
+	publisher Publisher
+	prefix    string
+	// events exported, all of them if it's empty
+	events  map[events.Topic]bool
+	queue   chan Message
+	dropped atomic.Int64
+}
+
+func Init(config *app.Config) {
+	if config.EventExportType == "" {
+		return
+	}
+
+	var publisher Publisher
+	var err error
+	switch config.EventExportType {
+	case TYPE_KAFKA:
+		publisher, err = newKafkaPublisher(config)
+	case TYPE_NATS:
+		publisher, err = newNATSPublisher(config)
+	}
+	if err != nil {
+		log.Panic("init event export failed: %s", err.Error())
+	}
+
+	e := newExporter(publisher, config.EventExportTopicPrefix, config.EventExportEvents, config.EventExportBufferSize)
+	e.subscribe()
+	go e.run()
+
+	log.Info("events are exported to %s", config.EventExportType)
+}
+
+func newExporter(publisher Publisher, prefix string, exported []string, bufferSize int) *exporter {
+	e := &exporter{
+		publisher: publisher,
+		prefix:    strings.TrimSuffix(prefix, "."),
+		events:    map[events.Topic]bool{},
+		queue:     make(chan Message, bufferSize),
+	}
+	for _, event := range exported {
+		if event = strings.TrimSpace(event); event != "" {
+			e.events[events.Topic(event)] = true
+		}
+	}
+	return e
+}
+
+// subscribe exports events as the bus publishes them, handlers only enqueue messages since they're called
+// by publishers synchronously
+func (e *exporter) subscribe() {
+	events.Subscribe(func(event events.PluginStarted) {
+		e.export(event, "", event.PluginUniqueIdentifier.PluginID(), map[string]any{
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"runtime_type":             event.RuntimeType,
+		})
+	})
+	events.Subscribe(func(event events.PluginStopped) {
+		e.export(event, "", event.PluginUniqueIdentifier.PluginID(), map[string]any{
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"runtime_type":             event.RuntimeType,
+		})
+	})
+	events.Subscribe(func(event events.PluginCrashed) {
+		// stderr may contain secrets printed by plugins, it's not exported
+		e.export(event, "", event.PluginUniqueIdentifier.PluginID(), map[string]any{
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"runtime_type":             event.RuntimeType,
+			"restarts":                 event.Restarts,
+		})
+	})
+	events.Subscribe(func(event events.SessionOpened) {
+		e.export(event, event.TenantID, event.PluginUniqueIdentifier.PluginID(), map[string]any{
+			"session_id":               event.SessionID,
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"action":                   event.Action,
+		})
+	})
+	events.Subscribe(func(event events.SessionClosed) {
+		e.export(event, event.TenantID, event.PluginUniqueIdentifier.PluginID(), map[string]any{
+			"session_id":               event.SessionID,
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"action":                   event.Action,
+			"duration":                 event.Duration.Milliseconds(),
+		})
+	})
+	events.Subscribe(func(event events.EndpointInvoked) {
+		e.export(event, event.TenantID, event.PluginUniqueIdentifier.PluginID(), map[string]any{
+			"endpoint_id":              event.EndpointID,
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"method":                   event.Method,
+			"status_code":              event.StatusCode,
+			"duration":                 event.Duration.Milliseconds(),
+		})
+	})
+	events.Subscribe(func(event events.InstallCompleted) {
+		e.export(event, event.TenantID, event.PluginUniqueIdentifier.PluginID(), map[string]any{
+			"task_id":                  event.TaskID,
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"succeeded":                event.Succeeded,
+			"message":                  event.Message,
+		})
+	})
+	events.Subscribe(func(event events.InvocationCompleted) {
+		e.export(event, event.TenantID, event.PluginUniqueIdentifier.PluginID(), map[string]any{
+			"session_id":               event.SessionID,
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"action":                   event.Action,
+			"latency":                  event.Latency.Milliseconds(),
+			"failed":                   event.Failed,
+			"error":                    event.Error,
+			"tokens":                   event.Tokens,
+		})
+	})
+	events.Subscribe(func(event events.PluginSLOBreached) {
+		e.export(event, "", event.PluginID, map[string]any{
+			"plugin_id":              event.PluginID,
+			"invocations":            event.Invocations,
+			"success_rate":           event.SuccessRate,
+			"error_budget_remaining": event.ErrorBudgetRemaining,
+			"latency_p99":            event.LatencyP99,
+			"reasons":                event.Reasons,
+		})
+	})
+}
+
+// export enqueues the event, events are dropped once the queue is full instead of blocking the publisher
+func (e *exporter) export(event events.Event, tenantID string, key string, data map[string]any) {
+	topic := event.Topic()
+	if len(e.events) > 0 && !e.events[topic] {
+		return
+	}
+
+	value, err := json.Marshal(Envelope{
+		SchemaVersion: SCHEMA_VERSION,
+		ID:            uuid.NewString(),
+		Event:         topic,
+		Timestamp:     time.Now().UnixMilli(),
+		TenantID:      tenantID,
+		Data:          data,
+	})
+	if err != nil {
+		log.Error("encode exported event %s failed: %s", topic, err.Error())
+		return
+	}
+
+	message := Message{
+		Topic: e.topic(topic),
+		Key:   key,
+		Value: value,
+		Headers: map[string]string{
+			HEADER_SCHEMA_VERSION: strconv.Itoa(SCHEMA_VERSION),
+			HEADER_EVENT:          string(topic),
+		},
+	}
+	select {
+	case e.queue <- message:
+	default:
+		// logged once in a while, the bus may publish thousands of events per second
+		if dropped := e.dropped.Add(1); dropped%1000 == 1 {
+			log.Warn("event export queue is full, %d events dropped so far", dropped)
+		}
+	}
+}
+
+// topic names the topic of an event, e.g. `dify.plugin.invocation.completed`
+func (e *exporter) topic(topic events.Topic) string {
+	if e.prefix == "" {
+		return string(topic)
+	}
+	return e.prefix + "." + string(topic)
+}
+
+// run publishes queued messages in batches
+func (e *exporter) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Message, 0, batchSize)
+	for {
+		select {
+		case message, ok := <-e.queue:
+			if !ok {
+				e.flush(batch)
+				return
+			}
+			batch = append(batch, message)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		e.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+func (e *exporter) flush(batch []Message) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := e.publisher.Publish(ctx, batch); err != nil {
+		log.Error("export %d events failed: %s", len(batch), err.Error())
+	}
+}
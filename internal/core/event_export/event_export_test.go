@@ -0,0 +1,107 @@
+package event_export
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+type fakePublisher struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, messages []Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func (p *fakePublisher) Close() error {
+	return nil
+}
+
+func (p *fakePublisher) published() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message{}, p.messages...)
+}
+
+func TestExportEventsOfBus(t *testing.T) {
+	identifier, err := plugin_entities.NewPluginUniqueIdentifier(
+		"langgenius/test:1.0.0@1234567890abcdef1234567890abcdef1234567890abcdef",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := &fakePublisher{}
+	e := newExporter(publisher, "dify.plugin.", []string{"invocation.completed", " plugin.crashed "}, 16)
+	e.subscribe()
+	go e.run()
+	defer close(e.queue)
+
+	events.Publish(events.InvocationCompleted{
+		SessionID:              "session",
+		TenantID:               "tenant",
+		PluginUniqueIdentifier: identifier,
+		Latency:                1500 * time.Millisecond,
+		Tokens:                 42,
+	})
+	events.Publish(events.PluginCrashed{PluginUniqueIdentifier: identifier, Restarts: 3, Stderr: "secret"})
+	// filtered out
+	events.Publish(events.PluginStarted{PluginUniqueIdentifier: identifier})
+
+	deadline := time.Now().Add(time.Second)
+	for len(publisher.published()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	messages := publisher.published()
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 exported events, got %d", len(messages))
+	}
+
+	invocation := messages[0]
+	if invocation.Topic != "dify.plugin.invocation.completed" || invocation.Key != "langgenius/test" {
+		t.Fatalf("unexpected topic %s and key %s", invocation.Topic, invocation.Key)
+	}
+	if invocation.Headers[HEADER_SCHEMA_VERSION] != "1" || invocation.Headers[HEADER_EVENT] != "invocation.completed" {
+		t.Fatalf("unexpected headers %v", invocation.Headers)
+	}
+	envelope := Envelope{}
+	if err := json.Unmarshal(invocation.Value, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.SchemaVersion != SCHEMA_VERSION || envelope.ID == "" || envelope.TenantID != "tenant" ||
+		envelope.Data["latency"] != float64(1500) || envelope.Data["tokens"] != float64(42) {
+		t.Fatalf("unexpected envelope %+v", envelope)
+	}
+
+	crashed := Envelope{}
+	if err := json.Unmarshal(messages[1].Value, &crashed); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := crashed.Data["stderr"]; ok || crashed.Data["restarts"] != float64(3) {
+		t.Fatalf("unexpected envelope %+v", crashed)
+	}
+}
+
+func TestExportDropsEventsOnceQueueIsFull(t *testing.T) {
+	e := newExporter(&fakePublisher{}, "", nil, 2)
+	for i := 0; i < 5; i++ {
+		e.export(events.PluginStopped{}, "", "", map[string]any{})
+	}
+
+	if len(e.queue) != 2 || e.dropped.Load() != 3 {
+		t.Fatalf("expected 2 queued and 3 dropped events, got %d and %d", len(e.queue), e.dropped.Load())
+	}
+	if message := <-e.queue; message.Topic != "plugin.stopped" {
+		t.Fatalf("topics should not be prefixed without a prefix, got %s", message.Topic)
+	}
+}
@@ -0,0 +1,61 @@
+package event_export
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// newKafkaPublisher publishes to the brokers, messages of a plugin are kept on a partition by their keys
+func newKafkaPublisher(config *app.Config) (*kafkaPublisher, error) {
+	transport := &kafka.Transport{}
+	if config.EventExportTLSEnabled {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if config.EventExportUsername != "" {
+		transport.SASL = plain.Mechanism{
+			Username: config.EventExportUsername,
+			Password: config.EventExportPassword,
+		}
+	}
+
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.EventExportBrokers...),
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+			BatchSize:    batchSize,
+			// batches are formed by the exporter already
+			BatchTimeout: flushInterval,
+			Transport:    transport,
+		},
+	}, nil
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, messages []Message) error {
+	batch := make([]kafka.Message, 0, len(messages))
+	for _, message := range messages {
+		headers := make([]kafka.Header, 0, len(message.Headers))
+		for key, value := range message.Headers {
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+		batch = append(batch, kafka.Message{
+			Topic:   message.Topic,
+			Key:     []byte(message.Key),
+			Value:   message.Value,
+			Headers: headers,
+		})
+	}
+	return p.writer.WriteMessages(ctx, batch...)
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,54 @@
+package event_export
+
+import (
+	"context"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/nats-io/nats.go"
+)
+
+type natsPublisher struct {
+	conn *nats.Conn
+}
+
+// newNATSPublisher connects to the servers, connections are re-established by the client once they're lost
+func newNATSPublisher(config *app.Config) (*natsPublisher, error) {
+	options := []nats.Option{
+		nats.Name("dify-plugin-daemon"),
+		nats.MaxReconnects(-1),
+	}
+	if config.EventExportTLSEnabled {
+		options = append(options, nats.Secure())
+	}
+	if config.EventExportUsername != "" {
+		options = append(options, nats.UserInfo(config.EventExportUsername, config.EventExportPassword))
+	} else if config.EventExportPassword != "" {
+		options = append(options, nats.Token(config.EventExportPassword))
+	}
+
+	conn, err := nats.Connect(strings.Join(config.EventExportBrokers, ","), options...)
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, messages []Message) error {
+	for _, message := range messages {
+		msg := nats.NewMsg(message.Topic)
+		msg.Data = message.Value
+		for key, value := range message.Headers {
+			msg.Header.Set(key, value)
+		}
+		if err := p.conn.PublishMsg(msg); err != nil {
+			return err
+		}
+	}
+	// published messages are buffered by the client until they're flushed
+	return p.conn.FlushWithContext(ctx)
+}
+
+func (p *natsPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,81 @@
+// Package events is the bus of lifecycle events published by subsystems of the daemon, subsystems
+// reacting to events of others, like metrics, webhooks and audits, subscribe to them instead of
+// being called by the publishers directly.
+package events
+
+import (
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+type Topic string
+
+// Event is published to subscribers of its topic
+type Event interface {
+	Topic() Topic
+}
+
+type subscriber struct {
+	id      uint64
+	handler func(Event)
+}
+
+var (
+	subscribers     = map[Topic][]subscriber{}
+	subscribersLock sync.RWMutex
+	nextId          uint64
+)
+
+// Subscribe calls handler with each event of type E published, handlers are called synchronously by
+// the publisher so that they should hand slow work like deliveries over to routines,
+// the returned function unsubscribes the handler
+func Subscribe[E Event](handler func(event E)) func() {
+	var zero E
+	topic := zero.Topic()
+
+	subscribersLock.Lock()
+	nextId++
+	id := nextId
+	subscribers[topic] = append(subscribers[topic], subscriber{
+		id: id,
+		handler: func(event Event) {
+			if e, ok := event.(E); ok {
+				handler(e)
+			}
+		},
+	})
+	subscribersLock.Unlock()
+
+	return func() {
+		subscribersLock.Lock()
+		defer subscribersLock.Unlock()
+
+		remaining := []subscriber{}
+		for _, s := range subscribers[topic] {
+			if s.id != id {
+				remaining = append(remaining, s)
+			}
+		}
+		subscribers[topic] = remaining
+	}
+}
+
+// Publish calls subscribers of the topic of the event, a panicking subscriber doesn't affect the others
+// nor the publisher
+func Publish(event Event) {
+	subscribersLock.RLock()
+	handlers := subscribers[event.Topic()]
+	subscribersLock.RUnlock()
+
+	for _, s := range handlers {
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					log.Error("subscriber of %s panicked: %v", event.Topic(), err)
+				}
+			}()
+			s.handler(event)
+		}()
+	}
+}
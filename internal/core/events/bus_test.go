@@ -0,0 +1,44 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestSubscribeByEventType(t *testing.T) {
+	crashed := 0
+	stopped := 0
+	unsubscribeCrashed := Subscribe(func(event PluginCrashed) {
+		crashed += event.Restarts
+	})
+	unsubscribeStopped := Subscribe(func(event PluginStopped) {
+		stopped++
+	})
+	defer unsubscribeStopped()
+
+	Publish(PluginCrashed{Restarts: 2})
+	Publish(PluginStopped{})
+	if crashed != 2 || stopped != 1 {
+		t.Fatalf("subscribers should receive events of their types only, got %d crashed, %d stopped", crashed, stopped)
+	}
+
+	unsubscribeCrashed()
+	Publish(PluginCrashed{Restarts: 2})
+	if crashed != 2 {
+		t.Fatal("unsubscribed handlers should not be called")
+	}
+}
+
+func TestPanickingSubscriber(t *testing.T) {
+	called := false
+	defer Subscribe(func(event SessionOpened) {
+		panic("broken subscriber")
+	})()
+	defer Subscribe(func(event SessionOpened) {
+		called = true
+	})()
+
+	Publish(SessionOpened{SessionID: "session"})
+	if !called {
+		t.Fatal("a panicking subscriber should not affect the others")
+	}
+}
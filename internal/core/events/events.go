@@ -0,0 +1,125 @@
+package events
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	TOPIC_PLUGIN_STARTED    Topic = "plugin.started"
+	TOPIC_PLUGIN_STOPPED    Topic = "plugin.stopped"
+	TOPIC_PLUGIN_CRASHED    Topic = "plugin.crashed"
+	TOPIC_SESSION_OPENED    Topic = "session.opened"
+	TOPIC_SESSION_CLOSED    Topic = "session.closed"
+	TOPIC_ENDPOINT_INVOKED  Topic = "endpoint.invoked"
+	TOPIC_INSTALL_COMPLETED Topic = "install.completed"
+
+	TOPIC_INVOCATION_COMPLETED Topic = "invocation.completed"
+	TOPIC_PLUGIN_SLO_BREACHED  Topic = "plugin.slo_breached"
+)
+
+// PluginStarted is published once a plugin runtime is registered on the current node
+type PluginStarted struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	RuntimeType            plugin_entities.PluginRuntimeType
+}
+
+func (PluginStarted) Topic() Topic { return TOPIC_PLUGIN_STARTED }
+
+// PluginStopped is published once a plugin runtime has reached the end of its lifetime
+type PluginStopped struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	RuntimeType            plugin_entities.PluginRuntimeType
+}
+
+func (PluginStopped) Topic() Topic { return TOPIC_PLUGIN_STOPPED }
+
+// PluginCrashed is published once a plugin exits without being stopped, it's restarted afterwards
+type PluginCrashed struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	RuntimeType            plugin_entities.PluginRuntimeType
+	// Restarts is the times the plugin has been restarted before
+	Restarts int
+	// Stderr is the error the plugin exited with along with its latest stderr
+	Stderr string
+}
+
+func (PluginCrashed) Topic() Topic { return TOPIC_PLUGIN_CRASHED }
+
+// SessionOpened is published once a session is created on the current node
+type SessionOpened struct {
+	SessionID              string
+	TenantID               string
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	Action                 access_types.PluginAccessAction
+}
+
+func (SessionOpened) Topic() Topic { return TOPIC_SESSION_OPENED }
+
+// SessionClosed is published once a session served by the current node is closed
+type SessionClosed struct {
+	SessionID              string
+	TenantID               string
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	Action                 access_types.PluginAccessAction
+	Duration               time.Duration
+}
+
+func (SessionClosed) Topic() Topic { return TOPIC_SESSION_CLOSED }
+
+// EndpointInvoked is published once an endpoint request is served
+type EndpointInvoked struct {
+	EndpointID             string
+	TenantID               string
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	Method                 string
+	StatusCode             int
+	Duration               time.Duration
+}
+
+func (EndpointInvoked) Topic() Topic { return TOPIC_ENDPOINT_INVOKED }
+
+// InstallCompleted is published once installing a plugin of an install task succeeds or fails
+type InstallCompleted struct {
+	TaskID                 string
+	TenantID               string
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	Succeeded              bool
+	Message                string
+}
+
+func (InstallCompleted) Topic() Topic { return TOPIC_INSTALL_COMPLETED }
+
+// InvocationCompleted is published once the response of an invocation of a plugin is closed
+type InvocationCompleted struct {
+	SessionID              string
+	TenantID               string
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	Action                 access_types.PluginAccessAction
+	// Latency is the time until the first chunk of the response
+	Latency time.Duration
+	Failed  bool
+	// Error is the last error written to the response if it failed
+	Error string
+	// Tokens is the tokens reported by the responses of models
+	Tokens int64
+}
+
+func (InvocationCompleted) Topic() Topic { return TOPIC_INVOCATION_COMPLETED }
+
+// PluginSLOBreached is published once a plugin burns its error budget or exceeds its latency objective
+// across the cluster, it's not published again until the plugin recovers
+type PluginSLOBreached struct {
+	PluginID    string
+	Invocations int64
+	SuccessRate float64
+	// ErrorBudgetRemaining is the fraction of the error budget left, negative once it's overspent
+	ErrorBudgetRemaining float64
+	// LatencyP99 is in milliseconds
+	LatencyP99 int64
+	Reasons    []string
+}
+
+func (PluginSLOBreached) Topic() Topic { return TOPIC_PLUGIN_SLO_BREACHED }
@@ -0,0 +1,192 @@
+// Package feature_flag gates new behaviors of the daemon for each tenant, flags are on for tenants listed by
+// them, or for a percentage of tenants picked by hashing their ids, so operators roll risky features out
+// gradually and switch them off at once if they go wrong.
+//
+// Flags are declared by the code they gate along with their defaults, which apply until operators configure
+// them. Configurations are stored in the database, cached in redis and kept on each node for a while, so
+// changes take effect on all the nodes within seconds.
+package feature_flag
+
+import (
+	"hash/fnv"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	TOOL_STREAM_TYPED     = "tool_stream_typed"
+	AGENT_STRATEGY_EVENTS = "agent_strategy_events"
+	MODEL_RESPONSE_CACHE  = "model_response_cache"
+)
+
+// Flag is a flag declared by the code it gates
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Default applies to all the tenants until the flag is configured
+	Default bool `json:"default"`
+}
+
+var (
+	declaredLock sync.RWMutex
+	declared     = map[string]Flag{}
+)
+
+// Declare declares the flag, flags declared again replace the previous ones
+func Declare(flag Flag) {
+	declaredLock.Lock()
+	defer declaredLock.Unlock()
+	declared[flag.Name] = flag
+}
+
+func init() {
+	Declare(Flag{
+		Name:        TOOL_STREAM_TYPED,
+		Description: "stream results of tools in typed chunks by /dispatch/tool/invoke/typed",
+		Default:     true,
+	})
+	Declare(Flag{
+		Name:        AGENT_STRATEGY_EVENTS,
+		Description: "stream traces of agent strategies as events by /dispatch/agent_strategy/invoke/events",
+		Default:     true,
+	})
+	Declare(Flag{
+		Name:        MODEL_RESPONSE_CACHE,
+		Description: "reply deterministic invocations of llms from cached responses if the cache is enabled",
+		Default:     true,
+	})
+}
+
+// Declared returns flags declared, ordered by their names
+func Declared() []Flag {
+	declaredLock.RLock()
+	defer declaredLock.RUnlock()
+
+	flags := make([]Flag, 0, len(declared))
+	for _, flag := range declared {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+	return flags
+}
+
+// Lookup returns the flag declared by the name
+func Lookup(name string) (Flag, bool) {
+	declaredLock.RLock()
+	defer declaredLock.RUnlock()
+	flag, ok := declared[name]
+	return flag, ok
+}
+
+// Enabled tells whether the flag is on for the tenant, flags neither declared nor configured are off
+func Enabled(name string, tenant_id string) bool {
+	if config, ok := configs()[name]; ok {
+		return Evaluate(config, tenant_id)
+	}
+	flag, _ := Lookup(name)
+	return flag.Default
+}
+
+// Evaluate tells whether the configured flag is on for the tenant
+func Evaluate(config models.FeatureFlag, tenant_id string) bool {
+	if !config.Enabled {
+		return false
+	}
+	if slices.Contains(config.BlockedTenants, tenant_id) {
+		return false
+	}
+	if slices.Contains(config.Tenants, tenant_id) {
+		return true
+	}
+	return Bucket(config.Name, tenant_id) < config.Percentage
+}
+
+// Bucket places the tenant in one of 100 buckets of the flag, tenants of a percentage stay in the rollout
+// as the percentage grows, and each flag picks its own tenants
+func Bucket(name string, tenant_id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + tenant_id))
+	return int(h.Sum32() % 100)
+}
+
+const (
+	CACHE_KEY = "feature_flags"
+	// configurations are kept on each node for localTTL, and in redis for cacheTTL
+	localTTL = 10 * time.Second
+	cacheTTL = 5 * time.Minute
+)
+
+var (
+	snapshotLock sync.RWMutex
+	snapshot     map[string]models.FeatureFlag
+	loadedAt     time.Time
+)
+
+// configs returns configurations of flags by their names
+func configs() map[string]models.FeatureFlag {
+	snapshotLock.RLock()
+	if snapshot != nil && time.Since(loadedAt) < localTTL {
+		defer snapshotLock.RUnlock()
+		return snapshot
+	}
+	snapshotLock.RUnlock()
+
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+	if snapshot != nil && time.Since(loadedAt) < localTTL {
+		return snapshot
+	}
+
+	flags, err := load()
+	// failures keep the previous configurations until the next try
+	loadedAt = time.Now()
+	if err != nil {
+		log.Warn("failed to load feature flags: %s", err.Error())
+		if snapshot == nil {
+			snapshot = map[string]models.FeatureFlag{}
+		}
+		return snapshot
+	}
+
+	snapshot = make(map[string]models.FeatureFlag, len(flags))
+	for _, flag := range flags {
+		snapshot[flag.Name] = flag
+	}
+	return snapshot
+}
+
+func load() ([]models.FeatureFlag, error) {
+	if cached, err := cache.Get[[]models.FeatureFlag](CACHE_KEY); err == nil {
+		return *cached, nil
+	}
+
+	flags, err := db.GetAll[models.FeatureFlag]()
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Store(CACHE_KEY, flags, cacheTTL); err != nil {
+		log.Warn("failed to cache feature flags: %s", err.Error())
+	}
+	return flags, nil
+}
+
+// Invalidate drops configurations cached, other nodes take changes once configurations kept by them expire
+func Invalidate() error {
+	snapshotLock.Lock()
+	snapshot = nil
+	snapshotLock.Unlock()
+
+	if err := cache.Del(CACHE_KEY); err != nil && err != cache.ErrNotFound {
+		return err
+	}
+	return nil
+}
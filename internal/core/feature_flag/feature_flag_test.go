@@ -0,0 +1,91 @@
+package feature_flag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func withConfigs(t *testing.T, flags ...models.FeatureFlag) {
+	snapshotLock.Lock()
+	snapshot = map[string]models.FeatureFlag{}
+	for _, flag := range flags {
+		snapshot[flag.Name] = flag
+	}
+	loadedAt = time.Now()
+	snapshotLock.Unlock()
+
+	t.Cleanup(func() {
+		snapshotLock.Lock()
+		snapshot = nil
+		snapshotLock.Unlock()
+	})
+}
+
+func TestEvaluate(t *testing.T) {
+	flag := models.FeatureFlag{
+		Name:           "test",
+		Enabled:        true,
+		Tenants:        []string{"allowed"},
+		BlockedTenants: []string{"blocked"},
+	}
+	if !Evaluate(flag, "allowed") || Evaluate(flag, "blocked") || Evaluate(flag, "other") {
+		t.Error("expected listed tenants to decide the flag")
+	}
+
+	flag.Percentage = 100
+	if !Evaluate(flag, "other") || Evaluate(flag, "blocked") {
+		t.Error("expected the flag to be on for all the tenants not blocked")
+	}
+
+	flag.Enabled = false
+	if Evaluate(flag, "allowed") {
+		t.Error("expected the flag to be off for all the tenants once it's disabled")
+	}
+}
+
+func TestPercentageRollout(t *testing.T) {
+	flag := models.FeatureFlag{Name: "rollout", Enabled: true, Percentage: 30}
+
+	on := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		tenant := time.Duration(i).String()
+		on[tenant] = Evaluate(flag, tenant)
+	}
+	count := 0
+	for _, enabled := range on {
+		if enabled {
+			count++
+		}
+	}
+	if count < 200 || count > 400 {
+		t.Errorf("expected about 30%% of tenants, got %d of 1000", count)
+	}
+
+	// tenants in the rollout stay in it as it grows
+	flag.Percentage = 60
+	for tenant, enabled := range on {
+		if enabled && !Evaluate(flag, tenant) {
+			t.Fatalf("tenant %s left the rollout", tenant)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	Declare(Flag{Name: "declared_on", Default: true})
+	Declare(Flag{Name: "declared_off"})
+
+	withConfigs(t, models.FeatureFlag{Name: "declared_on", Enabled: true, BlockedTenants: []string{"t1"}})
+
+	// no tenant is rolled out to by the configuration
+	if Enabled("declared_on", "t1") || Enabled("declared_on", "t2") {
+		t.Error("expected the configuration to replace the default")
+	}
+	if Enabled("declared_off", "t1") {
+		t.Error("expected the default to apply to flags not configured")
+	}
+	if Enabled("unknown", "t1") {
+		t.Error("expected unknown flags to be off")
+	}
+}
@@ -0,0 +1,293 @@
+// Package guardrail runs pipelines of hooks configured by tenants around tool invocations, hooks inspect
+// texts of tool parameters before the plugin is invoked and texts of chunks responded by the plugin, then
+// block the invocation, flag it, or redact what they matched. Outcomes are recorded as guardrail events.
+//
+// Hooks see texts one by one, texts of outputs are inspected as chunks arrive, so matches split across
+// chunks of a streamed text are not seen by them.
+package guardrail
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+const (
+	STAGE_INPUT  = "input"
+	STAGE_OUTPUT = "output"
+	STAGE_BOTH   = "both"
+
+	ACTION_BLOCK  = "block"
+	ACTION_FLAG   = "flag"
+	ACTION_REDACT = "redact"
+
+	TYPE_REGEX      = "regex"
+	TYPE_PII        = "pii"
+	TYPE_MODERATION = "moderation"
+
+	MAX_HOOKS          = 32
+	MAX_PATTERN_LENGTH = 1024
+)
+
+type matcher struct {
+	kind   string
+	regexp *regexp.Regexp
+	// valid filters false positives of the regexp, nil accepts all the matches
+	valid func(match string) bool
+}
+
+// piiMatchers are kinds of personal information matched by `pii` hooks
+var piiMatchers = map[string]matcher{
+	"email": {
+		kind:   "email",
+		regexp: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	},
+	"phone": {
+		kind:   "phone",
+		regexp: regexp.MustCompile(`(?:\+\d{1,3}[\s\-.]?)?\(?\b\d{3}\)?[\s\-.]?\d{3}[\s\-.]?\d{4}\b`),
+	},
+	"credit_card": {
+		kind:   "credit_card",
+		regexp: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		valid:  luhn,
+	},
+	"ssn": {
+		kind:   "ssn",
+		regexp: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	},
+	"ipv4": {
+		kind:   "ipv4",
+		regexp: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+	},
+}
+
+// luhn checks digits of the card number
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// Finding is an outcome of a hook matching a text
+type Finding struct {
+	Hook    string
+	Stage   string
+	Outcome string
+	// Detail tells what's matched without the matched text
+	Detail string
+}
+
+// BlockedError is returned once a hook blocks the invocation
+type BlockedError struct {
+	Finding Finding
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%s of the tool is blocked by guardrail %s: %s", e.Finding.Stage, e.Finding.Hook, e.Finding.Detail)
+}
+
+type hook struct {
+	models.GuardrailHook
+	matchers []matcher
+}
+
+func (h *hook) applies(stage string) bool {
+	return h.Stage == STAGE_BOTH || h.Stage == stage
+}
+
+// Pipeline is a compiled guardrail policy
+type Pipeline struct {
+	hooks []hook
+}
+
+// Compile validates the hooks and compiles them into a pipeline
+func Compile(hooks []models.GuardrailHook) (*Pipeline, error) {
+	if len(hooks) > MAX_HOOKS {
+		return nil, fmt.Errorf("too many hooks, at most %d", MAX_HOOKS)
+	}
+
+	pipeline := &Pipeline{}
+	names := map[string]bool{}
+	for _, h := range hooks {
+		if err := validators.GlobalEntitiesValidator.Struct(h); err != nil {
+			return nil, fmt.Errorf("invalid hook %q: %w", h.Name, err)
+		}
+		if names[h.Name] {
+			return nil, fmt.Errorf("hook %s is declared more than once", h.Name)
+		}
+		names[h.Name] = true
+
+		compiled := hook{GuardrailHook: h}
+		switch h.Type {
+		case TYPE_REGEX:
+			if h.Pattern == "" || len(h.Pattern) > MAX_PATTERN_LENGTH {
+				return nil, fmt.Errorf("pattern of hook %s should be 1 to %d characters", h.Name, MAX_PATTERN_LENGTH)
+			}
+			re, err := regexp.Compile(h.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern of hook %s: %w", h.Name, err)
+			}
+			compiled.matchers = []matcher{{kind: "pattern", regexp: re}}
+		case TYPE_PII:
+			kinds := h.PII
+			if len(kinds) == 0 {
+				for kind := range piiMatchers {
+					kinds = append(kinds, kind)
+				}
+				sort.Strings(kinds)
+			}
+			for _, kind := range kinds {
+				m, ok := piiMatchers[kind]
+				if !ok {
+					return nil, fmt.Errorf("unknown pii kind %s of hook %s", kind, h.Name)
+				}
+				compiled.matchers = append(compiled.matchers, m)
+			}
+		case TYPE_MODERATION:
+			if h.Action == ACTION_REDACT {
+				return nil, fmt.Errorf("moderation hook %s can only block or flag", h.Name)
+			}
+		}
+		pipeline.hooks = append(pipeline.hooks, compiled)
+	}
+	return pipeline, nil
+}
+
+// Inspect runs hooks of the stage on the text, returns the text redacted by them and their findings,
+// a BlockedError is returned once a hook blocks it
+func (p *Pipeline) Inspect(ctx context.Context, stage string, text string) (string, []Finding, error) {
+	if p == nil || strings.TrimSpace(text) == "" {
+		return text, nil, nil
+	}
+
+	findings := []Finding{}
+	for i := range p.hooks {
+		h := &p.hooks[i]
+		if !h.applies(stage) {
+			continue
+		}
+
+		finding := Finding{Hook: h.Name, Stage: stage, Outcome: h.Action}
+		if h.Type == TYPE_MODERATION {
+			flagged, categories, err := moderate(ctx, text)
+			switch {
+			case err != nil:
+				finding.Detail = "moderation failed: " + err.Error()
+			case flagged:
+				finding.Detail = "flagged by moderation"
+				if len(categories) > 0 {
+					finding.Detail += ": " + strings.Join(categories, ", ")
+				}
+			default:
+				continue
+			}
+		} else {
+			var kinds []string
+			text, kinds = h.match(text)
+			if len(kinds) == 0 {
+				continue
+			}
+			finding.Detail = "matched " + strings.Join(kinds, ", ")
+		}
+
+		if h.Action == ACTION_BLOCK {
+			return text, append(findings, finding), &BlockedError{Finding: finding}
+		}
+		findings = append(findings, finding)
+	}
+	return text, findings, nil
+}
+
+// match returns the text redacted if the action of the hook is redacting, and kinds matched in it
+func (h *hook) match(text string) (string, []string) {
+	replacement := h.Replacement
+	if replacement == "" {
+		replacement = redact.REDACTED
+	}
+
+	kinds := []string{}
+	for _, m := range h.matchers {
+		matched := false
+		redacted := m.regexp.ReplaceAllStringFunc(text, func(match string) string {
+			if m.valid != nil && !m.valid(match) {
+				return match
+			}
+			matched = true
+			return replacement
+		})
+		if !matched {
+			continue
+		}
+		kinds = append(kinds, m.kind)
+		if h.Action == ACTION_REDACT {
+			text = redacted
+		}
+	}
+	return text, kinds
+}
+
+// InspectValue inspects strings of the value recursively, maps and slices of it are copied
+func (p *Pipeline) InspectValue(ctx context.Context, stage string, value any) (any, []Finding, error) {
+	if p == nil {
+		return value, nil, nil
+	}
+
+	findings := []Finding{}
+	var inspect func(value any) (any, error)
+	inspect = func(value any) (any, error) {
+		switch v := value.(type) {
+		case string:
+			text, found, err := p.Inspect(ctx, stage, v)
+			findings = append(findings, found...)
+			return text, err
+		case map[string]any:
+			inspected := make(map[string]any, len(v))
+			for key, item := range v {
+				item, err := inspect(item)
+				if err != nil {
+					return nil, err
+				}
+				inspected[key] = item
+			}
+			return inspected, nil
+		case []any:
+			inspected := make([]any, len(v))
+			for i, item := range v {
+				item, err := inspect(item)
+				if err != nil {
+					return nil, err
+				}
+				inspected[i] = item
+			}
+			return inspected, nil
+		}
+		return value, nil
+	}
+
+	inspected, err := inspect(value)
+	if err != nil {
+		return value, findings, err
+	}
+	return inspected, findings, nil
+}
@@ -0,0 +1,190 @@
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+)
+
+func compile(t *testing.T, hooks ...models.GuardrailHook) *Pipeline {
+	pipeline, err := Compile(hooks)
+	if err != nil {
+		t.Fatalf("failed to compile hooks: %v", err)
+	}
+	return pipeline
+}
+
+func TestCompile(t *testing.T) {
+	cases := []models.GuardrailHook{
+		{Name: "a", Type: "unknown", Stage: STAGE_INPUT, Action: ACTION_BLOCK},
+		{Name: "a", Type: TYPE_REGEX, Stage: STAGE_INPUT, Action: ACTION_BLOCK},
+		{Name: "a", Type: TYPE_REGEX, Stage: STAGE_INPUT, Action: ACTION_BLOCK, Pattern: "("},
+		{Name: "a", Type: TYPE_PII, Stage: STAGE_INPUT, Action: ACTION_BLOCK, PII: []string{"passport"}},
+		{Name: "a", Type: TYPE_MODERATION, Stage: STAGE_INPUT, Action: ACTION_REDACT},
+	}
+	for _, hook := range cases {
+		if _, err := Compile([]models.GuardrailHook{hook}); err == nil {
+			t.Errorf("expected %+v to be rejected", hook)
+		}
+	}
+
+	hook := models.GuardrailHook{Name: "a", Type: TYPE_PII, Stage: STAGE_BOTH, Action: ACTION_FLAG}
+	if _, err := Compile([]models.GuardrailHook{hook, hook}); err == nil {
+		t.Error("expected duplicated hooks to be rejected")
+	}
+}
+
+func TestInspectRedactsPII(t *testing.T) {
+	pipeline := compile(t, models.GuardrailHook{
+		Name: "pii", Type: TYPE_PII, Stage: STAGE_BOTH, Action: ACTION_REDACT,
+	})
+
+	text, findings, err := pipeline.Inspect(
+		context.Background(), STAGE_OUTPUT,
+		"mail alice@example.com, card 4111 1111 1111 1111, order 1234567890123",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "mail [REDACTED], card [REDACTED], order 1234567890123" {
+		t.Errorf("unexpected text %q", text)
+	}
+	if len(findings) != 1 || findings[0].Outcome != ACTION_REDACT || findings[0].Detail != "matched credit_card, email" {
+		t.Errorf("unexpected findings %+v", findings)
+	}
+	if strings.Contains(findings[0].Detail, "alice") {
+		t.Error("detail should not carry the matched text")
+	}
+}
+
+func TestInspectBlocksAndFlags(t *testing.T) {
+	pipeline := compile(t,
+		models.GuardrailHook{Name: "flag", Type: TYPE_REGEX, Stage: STAGE_INPUT, Action: ACTION_FLAG, Pattern: `(?i)password`},
+		models.GuardrailHook{Name: "block", Type: TYPE_REGEX, Stage: STAGE_INPUT, Action: ACTION_BLOCK, Pattern: `(?i)drop\s+table`},
+	)
+
+	text, findings, err := pipeline.Inspect(context.Background(), STAGE_INPUT, "my Password")
+	if err != nil || text != "my Password" || len(findings) != 1 || findings[0].Outcome != ACTION_FLAG {
+		t.Fatalf("unexpected result %q %+v %v", text, findings, err)
+	}
+
+	_, findings, err = pipeline.Inspect(context.Background(), STAGE_INPUT, "password; DROP TABLE users")
+	if _, ok := err.(*BlockedError); !ok {
+		t.Fatalf("expected the input to be blocked, got %v", err)
+	}
+	if len(findings) != 2 || findings[1].Hook != "block" {
+		t.Errorf("unexpected findings %+v", findings)
+	}
+
+	// hooks of inputs don't see outputs
+	_, findings, err = pipeline.Inspect(context.Background(), STAGE_OUTPUT, "DROP TABLE users")
+	if err != nil || len(findings) != 0 {
+		t.Errorf("unexpected result %+v %v", findings, err)
+	}
+}
+
+func TestInspectValue(t *testing.T) {
+	pipeline := compile(t, models.GuardrailHook{
+		Name: "ssn", Type: TYPE_PII, Stage: STAGE_INPUT, Action: ACTION_REDACT, PII: []string{"ssn"}, Replacement: "***",
+	})
+
+	parameters := map[string]any{
+		"query": "ssn 123-45-6789",
+		"items": []any{"123-45-6789", 42},
+	}
+	inspected, findings, err := pipeline.InspectValue(context.Background(), STAGE_INPUT, parameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := inspected.(map[string]any)
+	if result["query"] != "ssn ***" || result["items"].([]any)[0] != "***" || result["items"].([]any)[1] != 42 {
+		t.Errorf("unexpected value %+v", result)
+	}
+	if len(findings) != 2 {
+		t.Errorf("expected 2 findings, got %+v", findings)
+	}
+	if parameters["query"] != "ssn 123-45-6789" {
+		t.Error("the value should not be modified in place")
+	}
+}
+
+func TestModeration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		request := moderationRequest{}
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(moderationResponse{
+			Flagged:    strings.Contains(request.Input, "hate"),
+			Categories: []string{"hate"},
+		})
+	}))
+	defer server.Close()
+
+	moderation.Store(&moderationAPI{url: server.URL, token: "token", client: server.Client()})
+	defer moderation.Store(nil)
+
+	pipeline := compile(t, models.GuardrailHook{
+		Name: "moderation", Type: TYPE_MODERATION, Stage: STAGE_OUTPUT, Action: ACTION_BLOCK,
+	})
+
+	if _, findings, err := pipeline.Inspect(context.Background(), STAGE_OUTPUT, "hello"); err != nil || len(findings) != 0 {
+		t.Fatalf("unexpected result %+v %v", findings, err)
+	}
+	_, findings, err := pipeline.Inspect(context.Background(), STAGE_OUTPUT, "hate speech")
+	if err == nil || len(findings) != 1 || findings[0].Detail != "flagged by moderation: hate" {
+		t.Fatalf("unexpected result %+v %v", findings, err)
+	}
+
+	// failures of the api block the text as it's not moderated
+	moderation.Store(&moderationAPI{url: server.URL, token: "wrong", client: server.Client()})
+	if _, _, err := pipeline.Inspect(context.Background(), STAGE_OUTPUT, "hello"); err == nil {
+		t.Error("expected the text to be blocked once the moderation fails")
+	}
+}
+
+func TestGuard(t *testing.T) {
+	routine.InitPool(8)
+
+	pipeline := compile(t, models.GuardrailHook{
+		Name: "block", Type: TYPE_REGEX, Stage: STAGE_OUTPUT, Action: ACTION_BLOCK, Pattern: "secret",
+	})
+
+	response := stream.NewStream[string](8)
+	response.Write("hello")
+	response.Write("secret")
+	response.Write("world")
+	response.Close()
+
+	guarded := Guard(response, func(chunk *string) error {
+		text, _, err := pipeline.Inspect(context.Background(), STAGE_OUTPUT, *chunk)
+		*chunk = strings.ToUpper(text)
+		return err
+	})
+
+	chunks := []string{}
+	var err error
+	for guarded.Next() {
+		var chunk string
+		chunk, err = guarded.Read()
+		if err != nil {
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || chunks[0] != "HELLO" {
+		t.Errorf("unexpected chunks %v", chunks)
+	}
+	if _, ok := err.(*BlockedError); !ok {
+		t.Errorf("expected the response to end with the block, got %v", err)
+	}
+}
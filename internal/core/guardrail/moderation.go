@@ -0,0 +1,91 @@
+package guardrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+// moderationAPI is replaced as a whole once settings are reloaded, requests read it concurrently
+type moderationAPI struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+var moderation atomic.Pointer[moderationAPI]
+
+func Init(config *app.Config) {
+	moderation.Store(&moderationAPI{
+		url:    config.GuardrailModerationURL,
+		token:  config.GuardrailModerationToken,
+		client: &http.Client{Timeout: time.Duration(config.GuardrailModerationTimeout) * time.Second},
+	})
+}
+
+// currentModeration returns the moderation api, an unconfigured one until it's initialized
+func currentModeration() *moderationAPI {
+	if api := moderation.Load(); api != nil {
+		return api
+	}
+	return &moderationAPI{}
+}
+
+// ModerationConfigured returns true if the moderation api is configured, tenants can't enable moderation otherwise
+func ModerationConfigured() bool {
+	return currentModeration().url != ""
+}
+
+type moderationRequest struct {
+	Input string `json:"input"`
+}
+
+type moderationResponse struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories"`
+}
+
+// moderate asks the moderation api whether the text is flagged
+func moderate(ctx context.Context, text string) (bool, []string, error) {
+	api := currentModeration()
+	if api.url == "" {
+		return false, nil, errors.New("moderation api is not configured")
+	}
+
+	body, err := json.Marshal(moderationRequest{Input: text})
+	if err != nil {
+		return false, nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, api.url, bytes.NewReader(body))
+	if err != nil {
+		return false, nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if api.token != "" {
+		request.Header.Set("Authorization", "Bearer "+api.token)
+	}
+
+	response, err := api.client.Do(request)
+	if err != nil {
+		return false, nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, nil, fmt.Errorf("moderation api responded %d", response.StatusCode)
+	}
+
+	result := moderationResponse{}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 64*1024)).Decode(&result); err != nil {
+		return false, nil, fmt.Errorf("invalid response of moderation api: %w", err)
+	}
+	return result.Flagged, result.Categories, nil
+}
@@ -0,0 +1,137 @@
+package guardrail
+
+import (
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+)
+
+// pipelines are cached on each node for a while, updates of policies take effect on other nodes after it
+const pipelineCacheDuration = 10 * time.Second
+
+type cachedPipeline struct {
+	pipeline  *Pipeline
+	expiresAt time.Time
+}
+
+var (
+	pipelinesLock sync.Mutex
+	pipelines     = map[string]cachedPipeline{}
+)
+
+// For returns the pipeline of the tenant, nil if the tenant has no enabled guardrail policy
+func For(tenant_id string) (*Pipeline, error) {
+	pipelinesLock.Lock()
+	cached, ok := pipelines[tenant_id]
+	pipelinesLock.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.pipeline, nil
+	}
+
+	var pipeline *Pipeline
+	policy, err := db.GetOne[models.GuardrailPolicy](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err != nil && err != db.ErrDatabaseNotFound {
+		return nil, err
+	}
+	if err == nil && policy.Enabled && len(policy.Hooks) > 0 {
+		pipeline, err = Compile(policy.Hooks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pipelinesLock.Lock()
+	pipelines[tenant_id] = cachedPipeline{
+		pipeline:  pipeline,
+		expiresAt: time.Now().Add(pipelineCacheDuration),
+	}
+	pipelinesLock.Unlock()
+	return pipeline, nil
+}
+
+// Invalidate drops the pipeline of the tenant cached on the current node
+func Invalidate(tenant_id string) {
+	pipelinesLock.Lock()
+	delete(pipelines, tenant_id)
+	pipelinesLock.Unlock()
+}
+
+// Subject is the invocation inspected by a pipeline
+type Subject struct {
+	TenantID  string
+	SessionID string
+	PluginID  string
+	Provider  string
+	Tool      string
+}
+
+// Record records findings of the invocation as guardrail events
+func Record(subject Subject, findings []Finding) {
+	if len(findings) == 0 {
+		return
+	}
+
+	events := make([]models.GuardrailEvent, 0, len(findings))
+	for _, finding := range findings {
+		detail := finding.Detail
+		if len(detail) > 1024 {
+			detail = detail[:1024]
+		}
+		events = append(events, models.GuardrailEvent{
+			TenantID:  subject.TenantID,
+			SessionID: subject.SessionID,
+			PluginID:  subject.PluginID,
+			Provider:  subject.Provider,
+			Tool:      subject.Tool,
+			Stage:     finding.Stage,
+			Hook:      finding.Hook,
+			Outcome:   finding.Outcome,
+			Detail:    detail,
+		})
+	}
+	if err := db.Create(&events); err != nil {
+		log.Error("failed to record %d guardrail events: %s", len(events), err.Error())
+	}
+}
+
+// Guard relays chunks of the response after they're inspected, chunks are transformed in place by inspect,
+// the response ends with the error once inspect fails
+func Guard[T any](response *stream.Stream[T], inspect func(chunk *T) error) *stream.Stream[T] {
+	guarded := stream.NewStream[T](128)
+	// the caller closes the stream if it's gone
+	guarded.OnClose(func() {
+		response.Close()
+	})
+
+	routine.Submit(map[string]string{
+		"module":   "guardrail",
+		"function": "Guard",
+	}, func() {
+		defer guarded.Close()
+		defer routine.ReportPanic(guarded.WriteError)
+
+		for response.Next() {
+			chunk, err := response.Read()
+			if err != nil {
+				guarded.WriteError(err)
+				return
+			}
+			if err := inspect(&chunk); err != nil {
+				guarded.WriteError(err)
+				return
+			}
+			if err := guarded.Write(chunk); err != nil {
+				return
+			}
+		}
+	})
+
+	return guarded
+}
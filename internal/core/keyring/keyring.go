@@ -0,0 +1,212 @@
+// Package keyring encrypts secrets the daemon stores by itself, like signing secrets of webhooks.
+//
+// Keys are versioned, each ciphertext is stored along with the version of the key encrypting it, so that
+// a new key is rolled out without downtime: the key is added to $ENCRYPTION_KEYS of every node and made
+// active by $ENCRYPTION_ACTIVE_KEY_VERSION, new secrets are encrypted by it while the others are still
+// decrypted by the keys they were encrypted with, until they're re-encrypted by the stores registered here.
+// Old keys are removed once no secret is encrypted by them.
+//
+// Version 0 means the secret is stored in plain text, it's the case if no key is configured.
+//
+// Ciphertexts are envelopes recording the cipher as well, $ENCRYPTION_CIPHER only applies to new secrets.
+package keyring
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/singleton_job"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// PLAIN_TEXT_VERSION is the version of secrets stored without encryption
+	PLAIN_TEXT_VERSION = 0
+)
+
+var (
+	ErrUnknownKeyVersion = errors.New("unknown encryption key version")
+)
+
+type keyring struct {
+	keys   map[int][]byte
+	active int
+	cipher string
+}
+
+var (
+	lock    sync.RWMutex
+	current = &keyring{keys: map[int][]byte{}, cipher: encryption.CIPHER_AES_GCM}
+)
+
+// parseKeys parses keys like `1:<base64 key>,2:<base64 key>`, keys are 16, 24 or 32 bytes for AES-128,
+// AES-192 or AES-256. The only key is active if active is 0, the active one has to be set once there are more,
+// otherwise a node getting a new key first would encrypt secrets other nodes can't decrypt yet
+func parseKeys(keys string, active int, cipher string) (*keyring, error) {
+	if _, err := encryption.GetCipher(cipher); err != nil {
+		return nil, err
+	}
+
+	parsed := &keyring{keys: map[int][]byte{}, cipher: cipher}
+	for _, entry := range strings.Split(keys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		version, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid encryption key %q, expected version:base64", entry)
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil || v <= PLAIN_TEXT_VERSION {
+			return nil, fmt.Errorf("invalid encryption key version %q, versions start from 1", version)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key of version %d: %s", v, err.Error())
+		}
+		if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			return nil, fmt.Errorf("invalid encryption key of version %d: length must be 16, 24 or 32 bytes", v)
+		}
+		if _, ok := parsed.keys[v]; ok {
+			return nil, fmt.Errorf("duplicated encryption key version %d", v)
+		}
+		parsed.keys[v] = key
+	}
+
+	if active == 0 {
+		if len(parsed.keys) > 1 {
+			return nil, errors.New("ENCRYPTION_ACTIVE_KEY_VERSION is required once more than one encryption key is configured")
+		}
+		for version := range parsed.keys {
+			active = version
+		}
+	} else if _, ok := parsed.keys[active]; !ok {
+		return nil, fmt.Errorf("active encryption key version %d is not configured", active)
+	}
+	parsed.active = active
+
+	if cipher == encryption.CIPHER_CHACHA20_POLY1305 && active != PLAIN_TEXT_VERSION && len(parsed.keys[active]) != 32 {
+		return nil, fmt.Errorf("cipher %s requires the active encryption key to be 32 bytes", cipher)
+	}
+
+	return parsed, nil
+}
+
+// Init loads the keys, secrets are stored in plain text if no key is configured, secrets not encrypted
+// by the active key are re-encrypted periodically by a singleton job
+func Init(config *app.Config) {
+	if err := load(config.EncryptionKeys, config.EncryptionActiveKeyVersion, config.EncryptionCipher); err != nil {
+		log.Panic("failed to load encryption keys: %s", err.Error())
+	}
+
+	singleton_job.Register(singleton_job.Job{
+		Name:     "secret_reencryption",
+		Interval: time.Duration(config.EncryptionReencryptInterval) * time.Second,
+		Run:      reencryptAll,
+	})
+}
+
+func load(keys string, active int, cipher string) error {
+	parsed, err := parseKeys(keys, active, cipher)
+	if err != nil {
+		return err
+	}
+
+	lock.Lock()
+	current = parsed
+	lock.Unlock()
+	return nil
+}
+
+// ActiveVersion returns the version of the key encrypting new secrets, 0 if they're not encrypted
+func ActiveVersion() int {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current.active
+}
+
+// Versions returns versions of the keys configured in ascending order
+func Versions() []int {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	versions := make([]int, 0, len(current.keys))
+	for version := range current.keys {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Encrypt encrypts the secret by the active key, it returns the ciphertext and the version of the key
+func Encrypt(secret string) (string, int, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if current.active == PLAIN_TEXT_VERSION {
+		return secret, PLAIN_TEXT_VERSION, nil
+	}
+
+	ciphertext, err := encryption.SealEnvelope(current.cipher, current.keys[current.active], []byte(secret))
+	if err != nil {
+		return "", 0, err
+	}
+	return ciphertext, current.active, nil
+}
+
+// Decrypt decrypts the ciphertext by the key of the version it was encrypted with
+func Decrypt(ciphertext string, version int) (string, error) {
+	if version == PLAIN_TEXT_VERSION {
+		return ciphertext, nil
+	}
+
+	lock.RLock()
+	key, ok := current.keys[version]
+	lock.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrUnknownKeyVersion, version)
+	}
+
+	secret, err := encryption.OpenEnvelope(key, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// Cipher returns the cipher new secrets are encrypted by
+func Cipher() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current.cipher
+}
+
+// DerivedKey derives a 32 bytes key for the scope from the key of the version by HKDF-SHA256, so that
+// a leaked derived key exposes nothing but the secrets of its scope
+func DerivedKey(version int, scope string) ([]byte, error) {
+	lock.RLock()
+	key, ok := current.keys[version]
+	lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, version)
+	}
+
+	derived := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(scope)), derived); err != nil {
+		return nil, err
+	}
+	return derived, nil
+}
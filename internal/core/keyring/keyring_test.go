@@ -0,0 +1,113 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestRotateKeys(t *testing.T) {
+	if err := load("1:"+testKey('a'), 0, encryption.CIPHER_AES_GCM); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, version, err := Encrypt("secret")
+	if err != nil || version != 1 || ciphertext == "secret" {
+		t.Fatalf("secret should be encrypted by key 1, got version %d: %v", version, err)
+	}
+
+	// the new key is active while the old one still decrypts
+	if err := load("1:"+testKey('a')+",2:"+testKey('b'), 2, encryption.CIPHER_AES_GCM); err != nil {
+		t.Fatal(err)
+	}
+	if ActiveVersion() != 2 {
+		t.Fatalf("the configured version should be active, got %d", ActiveVersion())
+	}
+	if secret, err := Decrypt(ciphertext, version); err != nil || secret != "secret" {
+		t.Fatalf("secret encrypted by the old key should be decrypted, got %q: %v", secret, err)
+	}
+
+	// once the old key is removed
+	if err := load("2:"+testKey('b'), 0, encryption.CIPHER_AES_GCM); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ciphertext, version); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Fatalf("expected unknown key version, got %v", err)
+	}
+}
+
+func TestPlainTextWithoutKeys(t *testing.T) {
+	if err := load("", 0, encryption.CIPHER_AES_GCM); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, version, err := Encrypt("secret")
+	if err != nil || version != PLAIN_TEXT_VERSION || ciphertext != "secret" {
+		t.Fatalf("secret should be kept in plain text, got %q version %d: %v", ciphertext, version, err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	for _, keys := range []string{
+		"1",
+		"0:" + testKey('a'),
+		"1:short",
+		"1:" + testKey('a') + ",1:" + testKey('b'),
+	} {
+		if _, err := parseKeys(keys, 0, encryption.CIPHER_AES_GCM); err == nil {
+			t.Errorf("%q should be rejected", keys)
+		}
+	}
+
+	if _, err := parseKeys("1:"+testKey('a'), 2, encryption.CIPHER_AES_GCM); err == nil {
+		t.Error("active version not configured should be rejected")
+	}
+	if _, err := parseKeys("1:"+testKey('a')+",2:"+testKey('b'), 0, encryption.CIPHER_AES_GCM); err == nil {
+		t.Error("multiple keys without the active version should be rejected")
+	}
+	if parsed, err := parseKeys("3:"+testKey('a'), 0, encryption.CIPHER_AES_GCM); err != nil || parsed.active != 3 {
+		t.Errorf("the only key should be active, got %v", err)
+	}
+}
+
+func TestSwitchCipher(t *testing.T) {
+	if err := load("1:"+testKey('a'), 0, encryption.CIPHER_AES_GCM); err != nil {
+		t.Fatal(err)
+	}
+	aesCiphertext, _, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := load("1:"+testKey('a'), 0, encryption.CIPHER_CHACHA20_POLY1305); err != nil {
+		t.Fatal(err)
+	}
+	chachaCiphertext, _, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(chachaCiphertext, encryption.CIPHER_CHACHA20_POLY1305+"$") {
+		t.Fatalf("expected the cipher to be recorded, got %s", chachaCiphertext)
+	}
+
+	// secrets keep being decrypted by the ciphers they were encrypted with
+	for _, ciphertext := range []string{aesCiphertext, chachaCiphertext} {
+		secret, err := Decrypt(ciphertext, 1)
+		if err != nil || secret != "secret" {
+			t.Fatalf("unexpected secret %q: %v", secret, err)
+		}
+	}
+
+	short := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 16)))
+	if err := load("1:"+short, 0, encryption.CIPHER_CHACHA20_POLY1305); err == nil {
+		t.Error("chacha20-poly1305 should reject keys shorter than 32 bytes")
+	}
+	if err := load("1:"+testKey('a'), 0, "rot13"); err == nil {
+		t.Error("unknown ciphers should be rejected")
+	}
+}
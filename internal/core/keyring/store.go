@@ -0,0 +1,96 @@
+package keyring
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// Store is a kind of secrets encrypted by the keyring, like signing secrets of webhooks
+type Store struct {
+	Name string
+	// Versions counts secrets by the versions of keys encrypting them
+	Versions func() (map[int]int64, error)
+	// Reencrypt encrypts secrets not encrypted by the active key again, it returns how many are re-encrypted
+	Reencrypt func() (int, error)
+}
+
+type StoreStatus struct {
+	Name string `json:"name"`
+	// Versions counts secrets by the versions of keys encrypting them, 0 is plain text
+	Versions map[int]int64 `json:"versions"`
+}
+
+type ReencryptionResult struct {
+	Name        string `json:"name"`
+	Reencrypted int    `json:"reencrypted"`
+	Error       string `json:"error,omitempty"`
+}
+
+var (
+	storesLock sync.Mutex
+	stores     []Store
+
+	// reencryptLock prevents re-encryptions from running at the same time on the node
+	reencryptLock sync.Mutex
+)
+
+// RegisterStore registers secrets to be re-encrypted on rotating keys
+func RegisterStore(store Store) {
+	storesLock.Lock()
+	defer storesLock.Unlock()
+
+	stores = append(stores, store)
+}
+
+func registeredStores() []Store {
+	storesLock.Lock()
+	defer storesLock.Unlock()
+
+	return append([]Store{}, stores...)
+}
+
+// Status counts secrets of each store by the versions of keys encrypting them, keys of versions
+// no secret is encrypted with are safe to be removed
+func Status() ([]StoreStatus, error) {
+	result := []StoreStatus{}
+	for _, store := range registeredStores() {
+		versions, err := store.Versions()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, StoreStatus{Name: store.Name, Versions: versions})
+	}
+	return result, nil
+}
+
+// Reencrypt re-encrypts secrets of all stores by the active key, a store failing doesn't stop the others
+func Reencrypt() []ReencryptionResult {
+	reencryptLock.Lock()
+	defer reencryptLock.Unlock()
+
+	results := []ReencryptionResult{}
+	for _, store := range registeredStores() {
+		n, err := store.Reencrypt()
+		result := ReencryptionResult{Name: store.Name, Reencrypted: n}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func reencryptAll() error {
+	var errs []error
+	for _, result := range Reencrypt() {
+		if result.Reencrypted > 0 {
+			log.Info("re-encrypted %d secrets of %s by key %d", result.Reencrypted, result.Name, ActiveVersion())
+		}
+		if result.Error != "" {
+			errs = append(errs, errors.New(result.Name+": "+result.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+// Package mcp serves tools of installed plugins to clients of the Model Context Protocol, messages
+// are json-rpc 2.0 requests exchanged over stdio or server sent events.
+package mcp
+
+import "encoding/json"
+
+const (
+	JSONRPC_VERSION  = "2.0"
+	PROTOCOL_VERSION = "2024-11-05"
+	SERVER_NAME      = "dify-plugin-daemon"
+)
+
+const (
+	ERROR_CODE_PARSE            = -32700
+	ERROR_CODE_INVALID_REQUEST  = -32600
+	ERROR_CODE_METHOD_NOT_FOUND = -32601
+	ERROR_CODE_INVALID_PARAMS   = -32602
+	ERROR_CODE_INTERNAL         = -32603
+)
+
+// Message is a request or a notification sent by clients, notifications have no id
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+func (m *Message) notification() bool {
+	return len(m.ID) == 0
+}
+
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type InitializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ServerInfo      Implementation `json:"serverInfo"`
+}
+
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type ListToolsResult struct {
+	Tools []Tool `json:"tools"`
+}
+
+type CallToolParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+const (
+	CONTENT_TYPE_TEXT  = "text"
+	CONTENT_TYPE_IMAGE = "image"
+)
+
+type Content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// CallToolResult is the result of a tool, failures of tools are results with IsError set
+// so that models are able to see them, unlike failures of the protocol
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+func TextResult(text string, isError bool) *CallToolResult {
+	return &CallToolResult{
+		Content: []Content{{Type: CONTENT_TYPE_TEXT, Text: text}},
+		IsError: isError,
+	}
+}
@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Backend lists and calls the tools served to a client
+type Backend interface {
+	ListTools(ctx context.Context) ([]Tool, error)
+	CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResult, error)
+}
+
+// ErrToolNotFound is returned by backends for tools they don't serve
+var ErrToolNotFound = errors.New("tool not found")
+
+type Server struct {
+	backend Backend
+	version string
+}
+
+func NewServer(backend Backend, version string) *Server {
+	return &Server{backend: backend, version: version}
+}
+
+// Handle serves a message of a client, nil is returned for notifications which have no response
+func (s *Server) Handle(ctx context.Context, data []byte) []byte {
+	message := Message{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return marshalResponse(Response{
+			JSONRPC: JSONRPC_VERSION,
+			ID:      json.RawMessage("null"),
+			Error:   &Error{Code: ERROR_CODE_PARSE, Message: err.Error()},
+		})
+	}
+	if message.JSONRPC != JSONRPC_VERSION || message.Method == "" {
+		if message.notification() {
+			return nil
+		}
+		return marshalResponse(Response{
+			JSONRPC: JSONRPC_VERSION,
+			ID:      message.ID,
+			Error:   &Error{Code: ERROR_CODE_INVALID_REQUEST, Message: "invalid json-rpc request"},
+		})
+	}
+
+	result, err := s.dispatch(ctx, &message)
+	// clients expect no response to notifications, e.g. `notifications/initialized`
+	if message.notification() {
+		return nil
+	}
+
+	response := Response{JSONRPC: JSONRPC_VERSION, ID: message.ID, Result: result}
+	if err != nil {
+		rpcError := &Error{}
+		if !errors.As(err, &rpcError) {
+			rpcError = &Error{Code: ERROR_CODE_INTERNAL, Message: err.Error()}
+		}
+		response.Result = nil
+		response.Error = rpcError
+	}
+	return marshalResponse(response)
+}
+
+func (s *Server) dispatch(ctx context.Context, message *Message) (any, error) {
+	switch message.Method {
+	case "initialize":
+		return InitializeResult{
+			ProtocolVersion: PROTOCOL_VERSION,
+			Capabilities:    map[string]any{"tools": map[string]any{"listChanged": false}},
+			ServerInfo:      Implementation{Name: SERVER_NAME, Version: s.version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools, err := s.backend.ListTools(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ListToolsResult{Tools: tools}, nil
+	case "tools/call":
+		params := CallToolParams{}
+		if err := json.Unmarshal(message.Params, &params); err != nil || params.Name == "" {
+			return nil, &Error{Code: ERROR_CODE_INVALID_PARAMS, Message: "name of the tool is required"}
+		}
+		result, err := s.backend.CallTool(ctx, params.Name, params.Arguments)
+		if errors.Is(err, ErrToolNotFound) {
+			return nil, &Error{Code: ERROR_CODE_INVALID_PARAMS, Message: fmt.Sprintf("unknown tool %s", params.Name)}
+		}
+		return result, err
+	}
+
+	if message.notification() {
+		return nil, nil
+	}
+	return nil, &Error{Code: ERROR_CODE_METHOD_NOT_FOUND, Message: fmt.Sprintf("method %s not found", message.Method)}
+}
+
+func marshalResponse(response Response) []byte {
+	data, err := json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(Response{
+			JSONRPC: JSONRPC_VERSION,
+			ID:      response.ID,
+			Error:   &Error{Code: ERROR_CODE_INTERNAL, Message: err.Error()},
+		})
+	}
+	return data
+}
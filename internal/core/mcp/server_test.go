@@ -0,0 +1,191 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+func newTestDaemon(t *testing.T) *httptest.Server {
+	providers := []map[string]any{
+		{
+			"plugin_id": "langgenius/search",
+			"provider":  "search",
+			"declaration": plugin_entities.ToolProviderDeclaration{
+				Tools: []plugin_entities.ToolDeclaration{{
+					Identity:    plugin_entities.ToolIdentity{Name: "web"},
+					Description: plugin_entities.ToolDescription{LLM: "search the web"},
+					Parameters: []plugin_entities.ToolParameter{
+						{Name: "query", Type: plugin_entities.TOOL_PARAMETER_TYPE_STRING, Required: true},
+						{Name: "limit", Type: plugin_entities.TOOL_PARAMETER_TYPE_NUMBER, Required: true, Default: 10},
+						{Name: "attachment", Type: plugin_entities.TOOL_PARAMETER_TYPE_FILE},
+					},
+				}},
+			},
+		},
+		{
+			"plugin_id": "someone/search",
+			"provider":  "search",
+			"declaration": plugin_entities.ToolProviderDeclaration{
+				Tools: []plugin_entities.ToolDeclaration{{Identity: plugin_entities.ToolIdentity{Name: "web"}}},
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(entities.NewDaemonErrorResponse(-401, "unauthorized"))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/plugin/tenant/management/tools":
+			json.NewEncoder(w).Encode(entities.NewSuccessResponse(providers))
+		case "/plugin/tenant/dispatch/tool/invoke":
+			request := plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]{}
+			json.NewDecoder(r.Body).Decode(&request)
+			if r.Header.Get("X-Plugin-ID") != request.PluginID || request.UserId != "user" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(entities.NewDaemonErrorResponse(-400, "unexpected request"))
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			if request.Data.ToolParameters["query"] == "fail" {
+				w.Write([]byte(`data: {"code":-500,"message":"search failed","data":null}` + "\n\n"))
+				return
+			}
+			w.Write([]byte(`data: {"code":0,"message":"success","data":{"type":"text","message":{"text":"found ` +
+				request.Data.ToolParameters["query"].(string) + ` by ` + request.PluginID + `"}}}` + "\n\n"))
+			w.Write([]byte(`data: {"code":0,"message":"success","data":{"type":"json","message":{"json_object":{"count":1}}}}` + "\n\n"))
+			w.Write([]byte(`data: {"code":0,"message":"success","data":{"type":"log","message":{}}}` + "\n\n"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestServer(daemon *httptest.Server, key string) *Server {
+	return NewServer(NewPluginTools(PluginToolsOptions{
+		Transport: http.DefaultTransport,
+		BaseURL:   daemon.URL,
+		Header:    http.Header{"X-Api-Key": {key}},
+		TenantID:  "tenant",
+		UserID:    "user",
+	}), "0.0.1")
+}
+
+func handle(t *testing.T, server *Server, message string, result any) *Error {
+	response := Response{}
+	if err := json.Unmarshal(server.Handle(context.Background(), []byte(message)), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	data, _ := json.Marshal(response.Result)
+	if err := json.Unmarshal(data, result); err != nil {
+		t.Fatal(err)
+	}
+	return nil
+}
+
+func TestServerServesPluginTools(t *testing.T) {
+	server := newTestServer(newTestDaemon(t), "key")
+
+	initialized := InitializeResult{}
+	if err := handle(t, server, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`, &initialized); err != nil {
+		t.Fatal(err)
+	}
+	if initialized.ProtocolVersion != PROTOCOL_VERSION || initialized.ServerInfo.Name != SERVER_NAME {
+		t.Fatalf("unexpected initialize result %+v", initialized)
+	}
+	if response := server.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); response != nil {
+		t.Fatalf("notifications should have no response, got %s", response)
+	}
+
+	listed := ListToolsResult{}
+	if err := handle(t, server, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`, &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Tools) != 2 || listed.Tools[0].Name != "search__web" || listed.Tools[1].Name != "someone_search__web" {
+		t.Fatalf("unexpected tools %+v", listed.Tools)
+	}
+	schema := listed.Tools[0].InputSchema
+	properties := schema["properties"].(map[string]any)
+	if len(properties) != 2 || properties["limit"].(map[string]any)["type"] != "number" {
+		t.Fatalf("unexpected schema %+v", schema)
+	}
+	if required := schema["required"].([]any); len(required) != 1 || required[0] != "query" {
+		t.Fatalf("parameters with defaults should not be required, got %v", required)
+	}
+
+	called := CallToolResult{}
+	if err := handle(t, server, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"someone_search__web","arguments":{"query":"dify"}}}`, &called); err != nil {
+		t.Fatal(err)
+	}
+	if called.IsError || len(called.Content) != 2 ||
+		called.Content[0].Text != "found dify by someone/search" || called.Content[1].Text != `{"count":1}` {
+		t.Fatalf("unexpected result %+v", called)
+	}
+
+	called = CallToolResult{}
+	if err := handle(t, server, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"search__web","arguments":{"query":"fail"}}}`, &called); err != nil {
+		t.Fatal(err)
+	}
+	if !called.IsError || called.Content[0].Text != "search failed" {
+		t.Fatalf("failures of tools should be results with isError, got %+v", called)
+	}
+
+	if err := handle(t, server, `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`, &called); err == nil || err.Code != ERROR_CODE_INVALID_PARAMS {
+		t.Fatalf("unknown tools should be invalid params, got %v", err)
+	}
+	if err := handle(t, server, `{"jsonrpc":"2.0","id":6,"method":"resources/list"}`, &called); err == nil || err.Code != ERROR_CODE_METHOD_NOT_FOUND {
+		t.Fatalf("unknown methods should not be found, got %v", err)
+	}
+
+	// rejected requests of the daemon are errors of the protocol
+	unauthorized := newTestServer(newTestDaemon(t), "wrong")
+	if err := handle(t, unauthorized, `{"jsonrpc":"2.0","id":7,"method":"tools/list"}`, &listed); err == nil || err.Message != "unauthorized" {
+		t.Fatalf("rejected listing should be an error, got %v", err)
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	server := newTestServer(newTestDaemon(t), "key")
+	in := strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"ping"}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`{"jsonrpc":"2.0","id":"2","method":"tools/list"}`,
+		`not json`,
+	}, "\n"))
+	out := bytes.Buffer{}
+
+	if err := ServeStdio(context.Background(), server, in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 responses, got %q", out.String())
+	}
+	if lines[0] != `{"jsonrpc":"2.0","id":1,"result":{}}` {
+		t.Fatalf("unexpected response to ping %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `{"jsonrpc":"2.0","id":"2","result":{"tools":[`) {
+		t.Fatalf("ids should be echoed as they are, got %s", lines[1])
+	}
+	if !strings.Contains(lines[2], `"code":-32700`) {
+		t.Fatalf("invalid json should be a parse error, got %s", lines[2])
+	}
+}
@@ -0,0 +1,357 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+// listPageSize is the max page size of the route listing tools
+const listPageSize = 256
+
+// PluginTools serves tools of plugins installed by a tenant, tools are listed and invoked by requests
+// to the routes of the daemon, so authorization and redirections across the cluster apply to them as well
+type PluginTools struct {
+	transport http.RoundTripper
+	baseURL   string
+	header    http.Header
+	tenantID  string
+	userID    string
+	// credentials of tool providers keyed by `plugin_id/provider`, the daemon doesn't keep them
+	credentials map[string]map[string]any
+
+	mu    sync.Mutex
+	tools map[string]pluginTool
+}
+
+type pluginTool struct {
+	pluginID string
+	provider string
+	tool     string
+}
+
+type PluginToolsOptions struct {
+	// Transport sends requests, e.g. to the handler of the daemon in the same process
+	Transport http.RoundTripper
+	// BaseURL of the daemon, empty if requests are served in the same process
+	BaseURL string
+	// Header is sent with every request, e.g. the api key
+	Header      http.Header
+	TenantID    string
+	UserID      string
+	Credentials map[string]map[string]any
+}
+
+func NewPluginTools(options PluginToolsOptions) *PluginTools {
+	return &PluginTools{
+		transport:   options.Transport,
+		baseURL:     strings.TrimSuffix(options.BaseURL, "/"),
+		header:      options.Header,
+		tenantID:    options.TenantID,
+		userID:      options.UserID,
+		credentials: options.Credentials,
+		tools:       map[string]pluginTool{},
+	}
+}
+
+type installedToolProvider struct {
+	PluginID    string                                   `json:"plugin_id"`
+	Provider    string                                   `json:"provider"`
+	Declaration *plugin_entities.ToolProviderDeclaration `json:"declaration"`
+}
+
+func (p *PluginTools) ListTools(ctx context.Context) ([]Tool, error) {
+	providers := []installedToolProvider{}
+	for page := 1; ; page++ {
+		query := url.Values{"page": {strconv.Itoa(page)}, "page_size": {strconv.Itoa(listPageSize)}}
+		response, err := p.request(ctx, http.MethodGet, p.tenantPath("/management/tools")+"?"+query.Encode(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		data := []installedToolProvider{}
+		err = decodeEnvelope(response, &data)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, data...)
+		if len(data) < listPageSize {
+			break
+		}
+	}
+
+	tools := []Tool{}
+	names := map[string]pluginTool{}
+	for _, provider := range providers {
+		if provider.Declaration == nil {
+			continue
+		}
+		for _, declaration := range provider.Declaration.Tools {
+			target := pluginTool{pluginID: provider.PluginID, provider: provider.Provider, tool: declaration.Identity.Name}
+			name := toolName(provider.Provider, declaration.Identity.Name)
+			// providers of different plugins are able to share names, they're told apart by authors
+			if _, ok := names[name]; ok {
+				author, _, _ := strings.Cut(provider.PluginID, "/")
+				name = toolName(author+"_"+provider.Provider, declaration.Identity.Name)
+			}
+			names[name] = target
+
+			tools = append(tools, Tool{
+				Name:        name,
+				Description: toolDescription(&declaration),
+				InputSchema: inputSchema(declaration.Parameters),
+			})
+		}
+	}
+
+	p.mu.Lock()
+	p.tools = names
+	p.mu.Unlock()
+	return tools, nil
+}
+
+func (p *PluginTools) CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResult, error) {
+	p.mu.Lock()
+	target, ok := p.tools[name]
+	p.mu.Unlock()
+	if !ok {
+		// clients may call tools listed by a previous session
+		if _, err := p.ListTools(ctx); err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		target, ok = p.tools[name]
+		p.mu.Unlock()
+		if !ok {
+			return nil, ErrToolNotFound
+		}
+	}
+
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	request := plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]{
+		InvokePluginUserIdentity: plugin_entities.InvokePluginUserIdentity{
+			TenantId: p.tenantID,
+			UserId:   p.userID,
+		},
+		BasePluginIdentifier: plugin_entities.BasePluginIdentifier{PluginID: target.pluginID},
+		Data: requests.RequestInvokeTool{
+			InvokeToolSchema: requests.InvokeToolSchema{
+				Provider:       target.provider,
+				Tool:           target.tool,
+				ToolParameters: arguments,
+			},
+			Credentials: requests.Credentials{
+				Credentials: p.credentials[target.pluginID+"/"+target.provider],
+			},
+		},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := p.request(ctx, http.MethodPost, p.tenantPath("/dispatch/tool/invoke"), body, http.Header{
+		"X-Plugin-ID": {target.pluginID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// requests rejected before the tool is invoked are replied by an envelope instead of events
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream") {
+		if err := decodeEnvelope(response, nil); err != nil {
+			return TextResult(err.Error(), true), nil
+		}
+		return &CallToolResult{Content: []Content{}}, nil
+	}
+	return readToolEvents(response.Body), nil
+}
+
+func (p *PluginTools) tenantPath(path string) string {
+	return "/plugin/" + url.PathEscape(p.tenantID) + path
+}
+
+func (p *PluginTools) request(
+	ctx context.Context,
+	method string,
+	path string,
+	body []byte,
+	header http.Header,
+) (*http.Response, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for _, h := range []http.Header{p.header, header} {
+		for key, values := range h {
+			for _, value := range values {
+				request.Header.Add(key, value)
+			}
+		}
+	}
+	return p.transport.RoundTrip(request)
+}
+
+// decodeEnvelope decodes the data of an envelope into data, failed envelopes are returned as errors
+func decodeEnvelope(response *http.Response, data any) error {
+	envelope := entities.GenericResponse[json.RawMessage]{}
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("unexpected response with status %d", response.StatusCode)
+	}
+	if envelope.Code != 0 || response.StatusCode != http.StatusOK {
+		return errors.New(envelope.Message)
+	}
+	if data == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, data)
+}
+
+// readToolEvents turns chunks of a tool into contents, a failed invocation is a result with IsError set
+func readToolEvents(body io.Reader) *CallToolResult {
+	result := &CallToolResult{Content: []Content{}}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data: "))
+		if !ok {
+			continue
+		}
+
+		envelope := entities.GenericResponse[tool_entities.ToolResponseChunk]{}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			continue
+		}
+		if envelope.Code != 0 {
+			result.Content = append(result.Content, Content{Type: CONTENT_TYPE_TEXT, Text: envelope.Message})
+			result.IsError = true
+			return result
+		}
+		if content, ok := chunkContent(&envelope.Data); ok {
+			result.Content = append(result.Content, content)
+		}
+	}
+	return result
+}
+
+// chunkContent turns a chunk into a content, chunks meaningful to Dify apps only, like variables and logs, are skipped
+func chunkContent(chunk *tool_entities.ToolResponseChunk) (Content, bool) {
+	switch chunk.Type {
+	case tool_entities.ToolResponseChunkTypeText,
+		tool_entities.ToolResponseChunkTypeLink,
+		tool_entities.ToolResponseChunkTypeImageLink,
+		tool_entities.ToolResponseChunkTypeImage:
+		text, _ := chunk.Message["text"].(string)
+		return Content{Type: CONTENT_TYPE_TEXT, Text: text}, text != ""
+	case tool_entities.ToolResponseChunkTypeJson:
+		data, err := json.Marshal(chunk.Message["json_object"])
+		if err != nil {
+			return Content{}, false
+		}
+		return Content{Type: CONTENT_TYPE_TEXT, Text: string(data)}, true
+	case tool_entities.ToolResponseChunkTypeBlob:
+		// blobs are bytes encoded in base64 by json
+		blob, _ := chunk.Message["blob"].(string)
+		mimeType, _ := chunk.Meta["mime_type"].(string)
+		if blob == "" {
+			return Content{}, false
+		}
+		if strings.HasPrefix(mimeType, "image/") {
+			return Content{Type: CONTENT_TYPE_IMAGE, Data: blob, MimeType: mimeType}, true
+		}
+		return Content{Type: CONTENT_TYPE_TEXT, Text: fmt.Sprintf("[blob of %s]", mimeType)}, true
+	}
+	return Content{}, false
+}
+
+var invalidToolNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// toolName names a tool as clients accept, which is `^[a-zA-Z0-9_-]{1,64}$`
+func toolName(provider string, tool string) string {
+	name := invalidToolNameCharacters.ReplaceAllString(provider+"__"+tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func toolDescription(declaration *plugin_entities.ToolDeclaration) string {
+	if declaration.Description.LLM != "" {
+		return declaration.Description.LLM
+	}
+	return declaration.Description.Human.EnUS
+}
+
+// inputSchema describes parameters of a tool in json schema, files are not able to be passed by clients
+func inputSchema(parameters []plugin_entities.ToolParameter) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	for _, parameter := range parameters {
+		property := map[string]any{}
+		switch parameter.Type {
+		case plugin_entities.TOOL_PARAMETER_TYPE_NUMBER:
+			property["type"] = "number"
+		case plugin_entities.TOOL_PARAMETER_TYPE_BOOLEAN:
+			property["type"] = "boolean"
+		case plugin_entities.TOOL_PARAMETER_TYPE_FILE, plugin_entities.TOOL_PARAMETER_TYPE_FILES:
+			continue
+		default:
+			property["type"] = "string"
+		}
+
+		description := parameter.LLMDescription
+		if description == "" {
+			description = parameter.HumanDescription.EnUS
+		}
+		if description != "" {
+			property["description"] = description
+		}
+		if len(parameter.Options) > 0 {
+			options := make([]string, 0, len(parameter.Options))
+			for _, option := range parameter.Options {
+				options = append(options, option.Value)
+			}
+			property["enum"] = options
+		}
+		if parameter.Default != nil {
+			property["default"] = parameter.Default
+		}
+
+		properties[parameter.Name] = property
+		if parameter.Required && parameter.Default == nil {
+			required = append(required, parameter.Name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
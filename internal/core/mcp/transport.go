@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+// ServeStdio serves messages delimited by newlines, as clients launching the server as a subprocess send them
+func ServeStdio(ctx context.Context, server *Server, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	// arguments of tools are able to be large
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		response := server.Handle(ctx, line)
+		if response == nil {
+			continue
+		}
+		if _, err := out.Write(append(response, '\n')); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// SSESessions are streams of server sent events opened by clients, responses to messages posted
+// by clients are sent as `message` events of the streams
+type SSESessions struct {
+	mu       sync.Mutex
+	sessions map[string]*sseSession
+}
+
+type sseSession struct {
+	// owner is who opened the session, e.g. the tenant, messages are only accepted from the owner
+	owner     string
+	ctx       context.Context
+	responses chan []byte
+}
+
+func NewSSESessions() *SSESessions {
+	return &SSESessions{sessions: map[string]*sseSession{}}
+}
+
+// Open streams responses of the session until the request is done, the first event tells the client
+// where to post messages
+func (s *SSESessions) Open(w http.ResponseWriter, r *http.Request, owner string, messageURL string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	id := uuid.NewString()
+	session := &sseSession{owner: owner, ctx: r.Context(), responses: make(chan []byte, 16)}
+	s.mu.Lock()
+	s.sessions[id] = session
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+	}()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	separator := "?"
+	if strings.Contains(messageURL, "?") {
+		separator = "&"
+	}
+	fmt.Fprintf(w, "event: endpoint\ndata: %s%ssession_id=%s\n\n", messageURL, separator, id)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case response := <-session.responses:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", response)
+			flusher.Flush()
+		}
+	}
+}
+
+// Post accepts a message posted to the session by its owner, it's served in the background as tools
+// may run for long, and the response is sent to the stream of the session
+func (s *SSESessions) Post(server *Server, id string, owner string, message []byte) error {
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	s.mu.Unlock()
+	if !ok || session.owner != owner {
+		return ErrSessionNotFound
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "mcp",
+		"function": "Post",
+	}, func() {
+		response := server.Handle(session.ctx, message)
+		if response == nil {
+			return
+		}
+		select {
+		case session.responses <- response:
+		case <-session.ctx.Done():
+		}
+	})
+	return nil
+}
+
+var ErrSessionNotFound = errors.New("session not found")
@@ -0,0 +1,264 @@
+// Package mock_marketplace serves plugins of a local directory by the api of the marketplace, dify pointed at it
+// downloads and upgrades plugins from fixtures, so install and upgrade flows are exercised end to end without the
+// real marketplace. Packages are the .difypkg files in the directory, packages dropped in are picked up at once.
+package mock_marketplace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+// paths of the api of the marketplace served
+const (
+	DOWNLOAD_PATH      = "/api/v1/plugins/download"
+	BATCH_PATH         = "/api/v1/plugins/batch"
+	INSTALL_COUNT_PATH = "/api/v1/stats/plugins/install_count"
+)
+
+type fixture struct {
+	modTime     time.Time
+	size        int64
+	identifier  plugin_entities.PluginUniqueIdentifier
+	declaration plugin_entities.PluginDeclaration
+}
+
+type Marketplace struct {
+	dir string
+
+	lock sync.Mutex
+	// fixtures by paths of packages, packages are decoded again once they're modified
+	fixtures map[string]*fixture
+	installs map[string]int
+}
+
+func New(dir string) (*Marketplace, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("fixtures of the marketplace should be a directory")
+	}
+	return &Marketplace{
+		dir:      dir,
+		fixtures: map[string]*fixture{},
+		installs: map[string]int{},
+	}, nil
+}
+
+// scan syncs fixtures with packages in the directory, packages failing to decode are skipped
+func (m *Marketplace) scan() []*fixture {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(m.dir, "*.difypkg"))
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	fixtures := []*fixture{}
+	for _, path := range paths {
+		seen[path] = true
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		cached, ok := m.fixtures[path]
+		if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			fixtures = append(fixtures, cached)
+			continue
+		}
+
+		f, err := decodeFixture(path)
+		if err != nil {
+			log.Warn("skip fixture %s of the mock marketplace: %s", path, err.Error())
+			delete(m.fixtures, path)
+			continue
+		}
+		f.modTime, f.size = info.ModTime(), info.Size()
+		m.fixtures[path] = f
+		fixtures = append(fixtures, f)
+	}
+	for path := range m.fixtures {
+		if !seen[path] {
+			delete(m.fixtures, path)
+		}
+	}
+	return fixtures
+}
+
+func decodeFixture(path string) (*fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	zipDecoder, err := decoder.NewZipPluginDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	identifier, err := zipDecoder.UniqueIdentity()
+	if err != nil {
+		return nil, err
+	}
+	declaration, err := zipDecoder.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return &fixture{identifier: identifier, declaration: declaration}, nil
+}
+
+func (m *Marketplace) pathOf(identifier string) (string, bool) {
+	m.scan()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for path, f := range m.fixtures {
+		if f.identifier.String() == identifier {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// latest returns the latest version of every plugin
+func (m *Marketplace) latest() map[string]*fixture {
+	latest := map[string]*fixture{}
+	for _, f := range m.scan() {
+		pluginId := f.identifier.PluginID()
+		if current, ok := latest[pluginId]; !ok || newer(f.identifier.Version().String(), current.identifier.Version().String()) {
+			latest[pluginId] = f
+		}
+	}
+	return latest
+}
+
+func newer(a string, b string) bool {
+	va, errA := version.NewVersion(a)
+	vb, errB := version.NewVersion(b)
+	if errA != nil || errB != nil {
+		return a > b
+	}
+	return va.GreaterThan(vb)
+}
+
+// Installs returns how many times installations of the plugin were reported, for assertions of tests
+func (m *Marketplace) Installs(identifier string) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.installs[identifier]
+}
+
+// Handler serves the api of the marketplace
+func (m *Marketplace) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+DOWNLOAD_PATH, m.download)
+	mux.HandleFunc("POST "+BATCH_PATH, m.batch)
+	mux.HandleFunc("POST "+INSTALL_COUNT_PATH, m.installCount)
+	// the root is probed by readiness checks
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, nil)
+	})
+	return mux
+}
+
+// response is the envelope of responses of the marketplace
+type response struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data any    `json:"data"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if status == http.StatusOK {
+		json.NewEncoder(w).Encode(response{Code: 0, Msg: "success", Data: data})
+	} else {
+		json.NewEncoder(w).Encode(response{Code: -1, Msg: http.StatusText(status), Data: data})
+	}
+}
+
+func (m *Marketplace) download(w http.ResponseWriter, r *http.Request) {
+	path, ok := m.pathOf(r.URL.Query().Get("unique_identifier"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(path))
+	http.ServeFile(w, r, path)
+}
+
+// plugin is a plugin listed by the marketplace, along with its latest version
+type plugin struct {
+	PluginID                string                         `json:"plugin_id"`
+	Org                     string                         `json:"org"`
+	Name                    string                         `json:"name"`
+	Icon                    string                         `json:"icon"`
+	Label                   plugin_entities.I18nObject     `json:"label"`
+	Brief                   plugin_entities.I18nObject     `json:"brief"`
+	Category                plugin_entities.PluginCategory `json:"category"`
+	LatestVersion           string                         `json:"latest_version"`
+	LatestPackageIdentifier string                         `json:"latest_package_identifier"`
+	Status                  string                         `json:"status"`
+	DeprecatedReason        string                         `json:"deprecated_reason"`
+	AlternativePluginID     string                         `json:"alternative_plugin_id"`
+}
+
+func (m *Marketplace) batch(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		PluginIDs []string `json:"plugin_ids"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, nil)
+		return
+	}
+
+	latest := m.latest()
+	plugins := []plugin{}
+	for _, pluginId := range request.PluginIDs {
+		f, ok := latest[pluginId]
+		if !ok {
+			continue
+		}
+		declaration := f.declaration
+		plugins = append(plugins, plugin{
+			PluginID:                pluginId,
+			Org:                     f.identifier.Author(),
+			Name:                    declaration.Name,
+			Icon:                    declaration.Icon,
+			Label:                   declaration.Label,
+			Brief:                   declaration.Description,
+			Category:                declaration.Category(),
+			LatestVersion:           f.identifier.Version().String(),
+			LatestPackageIdentifier: f.identifier.String(),
+			Status:                  "active",
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"plugins": plugins})
+}
+
+func (m *Marketplace) installCount(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		UniqueIdentifier string `json:"unique_identifier"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || strings.TrimSpace(request.UniqueIdentifier) == "" {
+		writeJSON(w, http.StatusBadRequest, nil)
+		return
+	}
+	m.lock.Lock()
+	m.installs[request.UniqueIdentifier]++
+	m.lock.Unlock()
+	writeJSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,164 @@
+package mock_marketplace
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
+)
+
+const testManifest = `version: {version}
+type: plugin
+author: "langgenius"
+name: "neko"
+icon: test.svg
+description:
+  en_US: "test"
+label:
+  en_US: "Neko"
+created_at: "2024-07-12T08:03:44.658609186Z"
+resource:
+  memory: 1048576
+plugins:
+  endpoints:
+    - "neko.yaml"
+meta:
+  version: 0.0.1
+  arch:
+    - "amd64"
+  runner:
+    language: "python"
+    version: "3.12"
+    entrypoint: "main"
+`
+
+const testEndpointGroup = `settings: []
+endpoints:
+  - neko.yaml
+`
+
+// pack packs the test plugin of the version into the directory of fixtures
+func pack(t *testing.T, dir string, version string) string {
+	source := t.TempDir()
+	files := map[string]string{
+		"manifest.yaml":    strings.ReplaceAll(testManifest, "{version}", version),
+		"neko.yaml":        testEndpointGroup,
+		"_assets/test.svg": "<svg></svg>",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(source, name)), 0o755)
+		if err := os.WriteFile(filepath.Join(source, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fsDecoder, err := decoder.NewFSPluginDecoder(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := packager.NewPackager(fsDecoder).Pack(52428800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipDecoder, err := decoder.NewZipPluginDecoder(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identifier, err := zipDecoder.UniqueIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "neko-"+version+".difypkg"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return identifier.String()
+}
+
+func batch(t *testing.T, server *httptest.Server) []plugin {
+	body, _ := json.Marshal(map[string]any{"plugin_ids": []string{"langgenius/neko", "langgenius/unknown"}})
+	resp, err := http.Post(server.URL+BATCH_PATH, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	result := struct {
+		Code int `json:"code"`
+		Data struct {
+			Plugins []plugin `json:"plugins"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != 0 {
+		t.Fatalf("unexpected code %d", result.Code)
+	}
+	return result.Data.Plugins
+}
+
+func TestMockMarketplace(t *testing.T) {
+	dir := t.TempDir()
+	v1 := pack(t, dir, "0.0.1")
+
+	marketplace, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(marketplace.Handler())
+	defer server.Close()
+
+	plugins := batch(t, server)
+	if len(plugins) != 1 || plugins[0].LatestPackageIdentifier != v1 || plugins[0].Org != "langgenius" {
+		t.Fatalf("unexpected plugins %+v", plugins)
+	}
+
+	resp, err := http.Get(server.URL + DOWNLOAD_PATH + "?unique_identifier=" + v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := new(bytes.Buffer)
+	data.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	expected, _ := os.ReadFile(filepath.Join(dir, "neko-0.0.1.difypkg"))
+	if !bytes.Equal(data.Bytes(), expected) {
+		t.Fatal("downloaded package differs from the fixture")
+	}
+
+	// packages dropped in are upgrades of plugins
+	v2 := pack(t, dir, "0.0.10")
+	if plugins := batch(t, server); len(plugins) != 1 || plugins[0].LatestPackageIdentifier != v2 ||
+		plugins[0].LatestVersion != "0.0.10" {
+		t.Fatalf("the latest version should be listed, got %+v", plugins)
+	}
+
+	resp, err = http.Get(server.URL + DOWNLOAD_PATH + "?unique_identifier=langgenius/neko:9.9.9@unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown packages should not be found, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+INSTALL_COUNT_PATH, "application/json",
+		strings.NewReader(`{"unique_identifier":"`+v2+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if marketplace.Installs(v2) != 1 {
+		t.Fatalf("installation should be counted, got %d", marketplace.Installs(v2))
+	}
+}
@@ -0,0 +1,173 @@
+// Package model_cache replies repeated deterministic invocations of models, invocations of llms with a
+// temperature of 0, from responses cached for each tenant instead of invoking the plugin again, which
+// cuts the cost of validations and tests repeating the same prompts.
+//
+// Responses are keyed by the tenant, the plugin, the model, credentials, prompts and parameters, credentials
+// are only hashed into keys, responses failing or larger than the limit are not cached.
+package model_cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+const (
+	// STATUS_* are replied in the X-Model-Cache header, callers send `bypass` to skip the cache
+	STATUS_HIT    = "hit"
+	STATUS_MISS   = "miss"
+	STATUS_BYPASS = "bypass"
+)
+
+var (
+	enabled  bool
+	ttl      time.Duration
+	maxBytes int
+)
+
+func Init(config *app.Config) {
+	enabled = config.ModelResponseCacheEnabled
+	ttl = time.Duration(config.ModelResponseCacheTTL) * time.Second
+	maxBytes = config.ModelResponseCacheMaxBytes
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+// Cacheable returns true if the invocation is deterministic, which is a temperature of 0
+func Cacheable(request *requests.RequestInvokeLLM) bool {
+	switch temperature := request.ModelParameters["temperature"].(type) {
+	case float64:
+		return temperature == 0
+	case int:
+		return temperature == 0
+	case json.Number:
+		value, err := temperature.Float64()
+		return err == nil && value == 0
+	}
+	return false
+}
+
+func generationKey(tenant_id string) string {
+	return "model_cache:generation:" + tenant_id
+}
+
+// Key returns the key of the invocation, keys of a tenant change once its cache is purged
+func Key(tenant_id string, plugin_unique_identifier string, request *requests.RequestInvokeLLM) (string, error) {
+	generation, err := cache.GetString(generationKey(tenant_id))
+	if err == cache.ErrNotFound {
+		generation = "0"
+	} else if err != nil {
+		return "", err
+	}
+
+	// maps are marshaled with sorted keys, so that equal invocations are marshaled the same
+	data, err := json.Marshal(struct {
+		Plugin  string                     `json:"plugin"`
+		Request *requests.RequestInvokeLLM `json:"request"`
+	}{plugin_unique_identifier, request})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("model_cache:%s:%s:%s", tenant_id, generation, hex.EncodeToString(sum[:])), nil
+}
+
+// Lookup returns chunks of the response cached by the key
+func Lookup[T any](key string) ([]T, bool) {
+	data, err := cache.GetString(key)
+	if err != nil {
+		if err != cache.ErrNotFound {
+			log.Warn("failed to look up model response cache: %s", err.Error())
+		}
+		return nil, false
+	}
+
+	chunks := []T{}
+	if err := json.Unmarshal([]byte(data), &chunks); err != nil {
+		return nil, false
+	}
+	return chunks, true
+}
+
+// Replay streams chunks of a cached response
+func Replay[T any](chunks []T) *stream.Stream[T] {
+	response := stream.NewStream[T](len(chunks) + 1)
+	for _, chunk := range chunks {
+		response.Write(chunk)
+	}
+	response.Close()
+	return response
+}
+
+// Record passes chunks of the response through, the response is cached by the key once it completes
+// without errors
+func Record[T any](key string, response *stream.Stream[T]) *stream.Stream[T] {
+	recorded := stream.NewStream[T](128)
+	// the caller closes the stream if it's gone
+	recorded.OnClose(func() {
+		response.Close()
+	})
+
+	routine.Submit(map[string]string{
+		"module":   "model_cache",
+		"function": "Record",
+	}, func() {
+		defer recorded.Close()
+		defer routine.ReportPanic(recorded.WriteError)
+
+		chunks := []T{}
+		size := 0
+		for response.Next() {
+			chunk, err := response.Read()
+			if err != nil {
+				recorded.WriteError(err)
+				return
+			}
+			if err := recorded.Write(chunk); err != nil {
+				return
+			}
+
+			if chunks == nil {
+				continue
+			}
+			data, err := json.Marshal(chunk)
+			if err != nil || size+len(data) > maxBytes {
+				// too large to be cached
+				chunks = nil
+				continue
+			}
+			size += len(data)
+			chunks = append(chunks, chunk)
+		}
+
+		if chunks == nil {
+			return
+		}
+		data, err := json.Marshal(chunks)
+		if err != nil {
+			return
+		}
+		if err := cache.Store(key, string(data), ttl); err != nil {
+			log.Warn("failed to cache model response: %s", err.Error())
+		}
+	})
+
+	return recorded
+}
+
+// Purge drops responses cached for the tenant, they're left to expire by their ttl
+func Purge(tenant_id string) error {
+	_, err := cache.Increase(generationKey(tenant_id))
+	return err
+}
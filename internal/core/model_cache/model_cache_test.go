@@ -0,0 +1,72 @@
+package model_cache
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+func TestCacheable(t *testing.T) {
+	cases := []struct {
+		parameters map[string]any
+		expected   bool
+	}{
+		{map[string]any{"temperature": float64(0)}, true},
+		{map[string]any{"temperature": 0}, true},
+		{map[string]any{"temperature": json.Number("0.0")}, true},
+		{map[string]any{"temperature": 0.7}, false},
+		{map[string]any{"temperature": "0"}, false},
+		{map[string]any{}, false},
+	}
+	for i, c := range cases {
+		request := &requests.RequestInvokeLLM{}
+		request.ModelParameters = c.parameters
+		if Cacheable(request) != c.expected {
+			t.Fatalf("case %d should be %v", i, c.expected)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	response := Replay([]string{"a", "b"})
+	chunks := []string{}
+	for response.Next() {
+		chunk, err := response.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 || chunks[0] != "a" || chunks[1] != "b" {
+		t.Fatalf("unexpected chunks %v", chunks)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	routine.InitPool(8)
+	maxBytes = 1024
+
+	response := stream.NewStream[string](8)
+	response.Write("a")
+	response.WriteError(errors.New("rate limited"))
+	response.Close()
+
+	recorded := Record("key", response)
+	chunks := []string{}
+	var failure error
+	for recorded.Next() {
+		chunk, err := recorded.Read()
+		if err != nil {
+			failure = err
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || chunks[0] != "a" || failure == nil || failure.Error() != "rate limited" {
+		t.Fatalf("chunks and errors should be passed through, got %v and %v", chunks, failure)
+	}
+}
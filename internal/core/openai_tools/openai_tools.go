@@ -0,0 +1,163 @@
+// Package openai_tools runs tool calls in the format of OpenAI chat completions by tools of installed plugins,
+// so agents built on OpenAI function calling are able to call plugins without changing their payloads.
+package openai_tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/mcp"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	TOOL_TYPE_FUNCTION = "function"
+	ROLE_TOOL          = "tool"
+)
+
+type FunctionCall struct {
+	Name string `json:"name" validate:"required"`
+	// Arguments is a json object encoded as a string, as models generate it
+	Arguments string `json:"arguments"`
+}
+
+type ToolCall struct {
+	ID       string       `json:"id" validate:"required"`
+	Type     string       `json:"type" validate:"omitempty,eq=function"`
+	Function FunctionCall `json:"function" validate:"required"`
+}
+
+type FunctionDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// FunctionTool is a tool passed to chat completions
+type FunctionTool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// ToolMessage is the result of a tool call, it's appended to the messages of the conversation as it is
+type ToolMessage struct {
+	Role       string `json:"role"`
+	ToolCallID string `json:"tool_call_id"`
+	Content    string `json:"content"`
+	// IsError tells failed calls apart, it's ignored by chat completions
+	IsError bool `json:"is_error,omitempty"`
+}
+
+// FunctionTools describes tools for chat completions, tools are named as they're called
+func FunctionTools(tools []mcp.Tool) []FunctionTool {
+	functions := make([]FunctionTool, 0, len(tools))
+	for _, tool := range tools {
+		functions = append(functions, FunctionTool{
+			Type: TOOL_TYPE_FUNCTION,
+			Function: FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+	return functions
+}
+
+// Run calls the tools in parallel, onMessage is called once a call is done, calls failing to be mapped onto
+// tools or to be validated by the schemas of the tools are replied as errors for models to correct them
+func Run(ctx context.Context, backend mcp.Backend, calls []ToolCall, onMessage func(ToolMessage)) error {
+	tools, err := backend.ListTools(ctx)
+	if err != nil {
+		return err
+	}
+	schemas := map[string]map[string]any{}
+	for _, tool := range tools {
+		schemas[tool.Name] = tool.InputSchema
+	}
+
+	// messages are passed one by one, callers stream them without synchronizing
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, call := range calls {
+		wg.Add(1)
+		go func(call ToolCall) {
+			defer wg.Done()
+			message := runCall(ctx, backend, schemas, call)
+			mu.Lock()
+			defer mu.Unlock()
+			onMessage(message)
+		}(call)
+	}
+	wg.Wait()
+	return nil
+}
+
+func runCall(ctx context.Context, backend mcp.Backend, schemas map[string]map[string]any, call ToolCall) ToolMessage {
+	failed := func(format string, args ...any) ToolMessage {
+		return ToolMessage{
+			Role:       ROLE_TOOL,
+			ToolCallID: call.ID,
+			Content:    "error: " + fmt.Sprintf(format, args...),
+			IsError:    true,
+		}
+	}
+
+	schema, ok := schemas[call.Function.Name]
+	if !ok {
+		return failed("unknown tool %s", call.Function.Name)
+	}
+
+	arguments, err := ParseArguments(call.Function.Arguments, schema)
+	if err != nil {
+		return failed("%s", err.Error())
+	}
+
+	result, err := backend.CallTool(ctx, call.Function.Name, arguments)
+	if err != nil {
+		return failed("%s", err.Error())
+	}
+
+	contents := []string{}
+	for _, content := range result.Content {
+		switch content.Type {
+		case mcp.CONTENT_TYPE_TEXT:
+			contents = append(contents, content.Text)
+		case mcp.CONTENT_TYPE_IMAGE:
+			// chat completions take no images from tools
+			contents = append(contents, fmt.Sprintf("[image of %s]", content.MimeType))
+		}
+	}
+	message := ToolMessage{Role: ROLE_TOOL, ToolCallID: call.ID, Content: strings.Join(contents, "\n")}
+	if result.IsError {
+		message.Content = "error: " + message.Content
+		message.IsError = true
+	}
+	return message
+}
+
+// ParseArguments decodes the arguments of a call and validates them by the json schema of the tool
+func ParseArguments(arguments string, schema map[string]any) (map[string]any, error) {
+	parsed := map[string]any{}
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &parsed); err != nil {
+			return nil, fmt.Errorf("arguments should be a json object: %s", err.Error())
+		}
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(parsed))
+	if err != nil {
+		return nil, err
+	}
+	if !result.Valid() {
+		problems := []string{}
+		for _, problem := range result.Errors() {
+			problems = append(problems, problem.String())
+		}
+		return nil, fmt.Errorf("invalid arguments: %s", strings.Join(problems, "; "))
+	}
+	return parsed, nil
+}
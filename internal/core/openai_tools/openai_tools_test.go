@@ -0,0 +1,96 @@
+package openai_tools
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/mcp"
+)
+
+type testBackend struct{}
+
+func (testBackend) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{
+		Name:        "search__web",
+		Description: "search the web",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query":  map[string]any{"type": "string"},
+				"limit":  map[string]any{"type": "number"},
+				"engine": map[string]any{"type": "string", "enum": []string{"google", "bing"}},
+			},
+			"required": []string{"query"},
+		},
+	}}, nil
+}
+
+func (testBackend) CallTool(ctx context.Context, name string, arguments map[string]any) (*mcp.CallToolResult, error) {
+	switch arguments["query"] {
+	case "fail":
+		return mcp.TextResult("search failed", true), nil
+	case "broken":
+		return nil, errors.New("plugin is not running")
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{
+		{Type: mcp.CONTENT_TYPE_TEXT, Text: "found " + arguments["query"].(string)},
+		{Type: mcp.CONTENT_TYPE_IMAGE, Data: "aGk=", MimeType: "image/png"},
+	}}, nil
+}
+
+func TestRun(t *testing.T) {
+	call := func(id string, name string, arguments string) ToolCall {
+		return ToolCall{ID: id, Type: TOOL_TYPE_FUNCTION, Function: FunctionCall{Name: name, Arguments: arguments}}
+	}
+	calls := []ToolCall{
+		call("1", "search__web", `{"query":"dify","limit":3}`),
+		call("2", "search__web", `{"query":"fail"}`),
+		call("3", "search__web", `{"query":"broken"}`),
+		call("4", "search__web", `{"limit":"3"}`),
+		call("5", "search__web", `{"query":"dify","engine":"yahoo"}`),
+		call("6", "search__web", `not json`),
+		call("7", "missing", `{}`),
+	}
+
+	messages := []ToolMessage{}
+	err := Run(context.Background(), testBackend{}, calls, func(message ToolMessage) {
+		messages = append(messages, message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != len(calls) {
+		t.Fatalf("expected a message per call, got %+v", messages)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ToolCallID < messages[j].ToolCallID })
+
+	if messages[0].IsError || messages[0].Role != ROLE_TOOL || messages[0].Content != "found dify\n[image of image/png]" {
+		t.Fatalf("unexpected message %+v", messages[0])
+	}
+	expected := map[int]string{
+		1: "error: search failed",
+		2: "error: plugin is not running",
+		3: "error: invalid arguments: (root): query is required; limit: Invalid type. Expected: number, given: string",
+		5: "error: arguments should be a json object: invalid character 'o' in literal null (expecting 'u')",
+		6: "error: unknown tool missing",
+	}
+	for i, content := range expected {
+		if !messages[i].IsError || messages[i].Content != content {
+			t.Fatalf("unexpected message of call %s: %+v", messages[i].ToolCallID, messages[i])
+		}
+	}
+	if !messages[4].IsError {
+		t.Fatalf("values out of the enum should be rejected, got %+v", messages[4])
+	}
+}
+
+func TestFunctionTools(t *testing.T) {
+	tools, _ := testBackend{}.ListTools(context.Background())
+	functions := FunctionTools(tools)
+	if len(functions) != 1 || functions[0].Type != TOOL_TYPE_FUNCTION || functions[0].Function.Name != "search__web" ||
+		functions[0].Function.Parameters["type"] != "object" {
+		t.Fatalf("unexpected functions %+v", functions)
+	}
+}
@@ -0,0 +1,818 @@
+// Package openapi_converter generates tool plugins from OpenAPI 3 specs, every operation of a spec becomes a tool
+// and security schemes become credentials of the provider, so REST apis are wrapped as tools without plugin code.
+package openapi_converter
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed templates/main.py
+var mainTemplate []byte
+
+//go:embed templates/requirements.txt
+var requirementsTemplate []byte
+
+//go:embed templates/openapi_client.py
+var clientTemplate []byte
+
+//go:embed templates/icon.svg
+var iconTemplate []byte
+
+//go:embed templates/provider.py
+var providerTemplate string
+
+//go:embed templates/tool.py
+var toolTemplate string
+
+const (
+	// CREDENTIAL_BASE_URL overrides the server of the spec, e.g. to reach an api deployed internally
+	CREDENTIAL_BASE_URL = "base_url"
+
+	// maxOperations limits tools of a plugin, clients list all tools of a provider at once
+	maxOperations = 256
+	// maxDescriptionLength is the max length of i18n texts of declarations
+	maxDescriptionLength = 1023
+)
+
+type Options struct {
+	// Author of the plugin, required
+	Author string
+	// Name of the plugin, defaults to the title of the spec
+	Name string
+	// Version of the plugin, defaults to the version of the spec if it's a valid version, or 0.0.1
+	Version string
+	// ServerURL overrides the servers of the spec
+	ServerURL string
+}
+
+// Result is the source of a generated plugin
+type Result struct {
+	Author  string
+	Name    string
+	Version string
+	// Tools are names of the generated tools in the order of the spec
+	Tools []string
+	// Files of the source keyed by slash separated paths
+	Files map[string][]byte
+}
+
+// Archive packs the source into a zip archive as the packing of plugins takes it
+func (r *Result) Archive() ([]byte, error) {
+	paths := make([]string, 0, len(r.Files))
+	for path := range r.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	buffer := bytes.NewBuffer(nil)
+	writer := zip.NewWriter(buffer)
+	for _, path := range paths {
+		w, err := writer.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(r.Files[path]); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// operationsFile is read by the generated client to send requests of operations
+type operationsFile struct {
+	Server          string                    `json:"server"`
+	SecuritySchemes map[string]securityScheme `json:"security_schemes"`
+	Operations      map[string]operation      `json:"operations"`
+}
+
+const (
+	SECURITY_TYPE_API_KEY = "api_key"
+	SECURITY_TYPE_BEARER  = "bearer"
+	SECURITY_TYPE_BASIC   = "basic"
+)
+
+type securityScheme struct {
+	Type string `json:"type"`
+	// In and Name locate api keys, e.g. the header `X-API-Key`
+	In   string `json:"in,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Credentials are names of the credentials of the provider, username and password for basic
+	Credentials []string `json:"credentials"`
+}
+
+type operation struct {
+	Method     string               `json:"method"`
+	Path       string               `json:"path"`
+	Parameters []operationParameter `json:"parameters"`
+	// BodyContentType is set once the operation takes a body
+	BodyContentType string `json:"body_content_type,omitempty"`
+	// Security are alternatives of schemes, the first one with all credentials set is applied
+	Security [][]string `json:"security"`
+}
+
+const (
+	PARAMETER_IN_PATH   = "path"
+	PARAMETER_IN_QUERY  = "query"
+	PARAMETER_IN_HEADER = "header"
+	PARAMETER_IN_COOKIE = "cookie"
+	// PARAMETER_IN_BODY is a property of a json object body
+	PARAMETER_IN_BODY = "body"
+	// PARAMETER_IN_RAW_BODY is the whole body
+	PARAMETER_IN_RAW_BODY = "raw_body"
+)
+
+type operationParameter struct {
+	// Name of the parameter in requests
+	Name string `json:"name"`
+	// Parameter is the name of the parameter of the tool
+	Parameter string `json:"parameter"`
+	In        string `json:"in"`
+	// JSON values are encoded as strings by models, they're decoded before they're sent
+	JSON bool `json:"json,omitempty"`
+}
+
+type pythonExtra struct {
+	Python struct {
+		Source string `yaml:"source"`
+	} `yaml:"python"`
+}
+
+func newPythonExtra(source string) pythonExtra {
+	extra := pythonExtra{}
+	extra.Python.Source = source
+	return extra
+}
+
+type providerFile struct {
+	Identity          plugin_entities.ToolProviderIdentity `yaml:"identity"`
+	CredentialsSchema []plugin_entities.ProviderConfig     `yaml:"credentials_schema"`
+	Tools             []string                             `yaml:"tools"`
+	Extra             pythonExtra                          `yaml:"extra"`
+}
+
+type toolFile struct {
+	plugin_entities.ToolDeclaration `yaml:",inline"`
+	Extra                           pythonExtra `yaml:"extra"`
+}
+
+// Convert generates the source of a tool plugin from an OpenAPI 3 spec in yaml or json
+func Convert(data []byte, options Options) (*Result, error) {
+	spec, err := parseSpec(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !plugin_entities.AuthorRegex.MatchString(options.Author) {
+		return nil, fmt.Errorf("invalid author %q, it should match %s", options.Author, plugin_entities.AuthorRegex)
+	}
+
+	name := options.Name
+	if name == "" {
+		name = identifier(spec.info("title"))
+	}
+	if !plugin_entities.PluginNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid plugin name %q, it should match %s", name, plugin_entities.PluginNameRegex)
+	}
+
+	version := options.Version
+	if version == "" {
+		version = "0.0.1"
+		if manifest_entities.PluginDeclarationVersionRegex.MatchString(spec.info("version")) {
+			version = spec.info("version")
+		}
+	}
+	if !manifest_entities.PluginDeclarationVersionRegex.MatchString(version) {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+
+	servers := spec.serverURLs()
+	server := strings.TrimSuffix(options.ServerURL, "/")
+	if server != "" {
+		parsed, err := url.Parse(server)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("invalid server url %q", options.ServerURL)
+		}
+		servers = append([]string{server}, servers...)
+	} else if len(servers) > 0 {
+		server = servers[0]
+	}
+
+	operations := operationsFile{
+		Server:          server,
+		SecuritySchemes: map[string]securityScheme{},
+		Operations:      map[string]operation{},
+	}
+	credentials := []plugin_entities.ProviderConfig{{
+		Name:     CREDENTIAL_BASE_URL,
+		Type:     plugin_entities.CONFIG_TYPE_TEXT_INPUT,
+		Required: server == "",
+		Label:    plugin_entities.NewI18nObject("Base URL"),
+		Help:     parser.ToPtr(plugin_entities.NewI18nObject("Overrides the server of the api, e.g. " + orDefault(server, "https://api.example.com"))),
+	}}
+	if server != "" {
+		credentials[0].Default = server
+	}
+	credentials = append(credentials, convertSecuritySchemes(spec, operations.SecuritySchemes)...)
+
+	result := &Result{
+		Author:  options.Author,
+		Name:    name,
+		Version: version,
+		Files:   map[string][]byte{},
+	}
+	toolFiles := []string{}
+
+	paths, _ := spec.root["paths"].(map[string]any)
+	sortedPaths := make([]string, 0, len(paths))
+	for path := range paths {
+		sortedPaths = append(sortedPaths, path)
+	}
+	sort.Strings(sortedPaths)
+
+	for _, path := range sortedPaths {
+		item, err := spec.resolve(paths[path])
+		if err != nil {
+			return nil, errors.Join(ErrInvalidSpec, err)
+		}
+		for _, method := range operationMethods {
+			node, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			if len(result.Tools) >= maxOperations {
+				return nil, fmt.Errorf("too many operations, at most %d operations are able to be converted", maxOperations)
+			}
+
+			toolName := uniqueName(operationName(node, method, path), operations.Operations)
+			declaration, op, err := convertOperation(spec, item, node, method, path, toolName, options.Author)
+			if err != nil {
+				return nil, errors.Join(ErrInvalidSpec, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err))
+			}
+			op.Security = supportedRequirements(op.Security, operations.SecuritySchemes)
+			operations.Operations[toolName] = *op
+
+			source, err := render(toolTemplate, map[string]string{
+				"Class":     className(toolName) + "Tool",
+				"Operation": toolName,
+			})
+			if err != nil {
+				return nil, err
+			}
+			yamlFile := "tools/" + toolName + ".yaml"
+			pythonFile := "tools/" + toolName + ".py"
+			if result.Files[yamlFile], err = marshalYaml(toolFile{
+				ToolDeclaration: *declaration,
+				Extra:           newPythonExtra(pythonFile),
+			}); err != nil {
+				return nil, err
+			}
+			result.Files[pythonFile] = source
+			result.Tools = append(result.Tools, toolName)
+			toolFiles = append(toolFiles, yamlFile)
+		}
+	}
+	if len(result.Tools) == 0 {
+		return nil, errors.Join(ErrInvalidSpec, errors.New("the spec has no operations"))
+	}
+
+	title := truncate(orDefault(spec.info("title"), name))
+	description := truncate(orDefault(spec.info("description"), title))
+
+	provider, err := render(providerTemplate, map[string]string{"Class": className(name) + "Provider"})
+	if err != nil {
+		return nil, err
+	}
+	result.Files["provider/"+name+".py"] = provider
+	if result.Files["provider/"+name+".yaml"], err = marshalYaml(providerFile{
+		Identity: plugin_entities.ToolProviderIdentity{
+			Author:      options.Author,
+			Name:        name,
+			Label:       plugin_entities.NewI18nObject(title),
+			Description: plugin_entities.NewI18nObject(description),
+			Icon:        "icon.svg",
+			Tags:        []manifest_entities.PluginTag{},
+		},
+		CredentialsSchema: credentials,
+		Tools:             toolFiles,
+		Extra:             newPythonExtra("provider/" + name + ".py"),
+	}); err != nil {
+		return nil, err
+	}
+
+	if result.Files["operations.json"], err = json.MarshalIndent(operations, "", "  "); err != nil {
+		return nil, err
+	}
+
+	// requests are limited to the hosts of the servers once egress of plugins is enforced
+	domains := []string{}
+	for _, server := range servers {
+		parsed, _ := url.Parse(server)
+		if !contains(domains, parsed.Hostname()) {
+			domains = append(domains, parsed.Hostname())
+		}
+	}
+	manifest := plugin_entities.PluginDeclaration{
+		PluginDeclarationWithoutAdvancedFields: plugin_entities.PluginDeclarationWithoutAdvancedFields{
+			Version:     manifest_entities.Version(version),
+			Type:        manifest_entities.PluginType,
+			Author:      options.Author,
+			Name:        name,
+			Label:       plugin_entities.NewI18nObject(title),
+			Description: plugin_entities.NewI18nObject(description),
+			Icon:        "icon.svg",
+			CreatedAt:   time.Now(),
+			Resource: plugin_entities.PluginResourceRequirement{
+				Memory: 1024 * 1024 * 256, // 256MB
+				Permission: &plugin_entities.PluginPermissionRequirement{
+					Network: &plugin_entities.PluginPermissionNetworkRequirement{
+						Enabled: true,
+						Domains: domains,
+					},
+				},
+			},
+			Plugins: plugin_entities.PluginExtensions{
+				Tools: []string{"provider/" + name + ".yaml"},
+			},
+			Meta: plugin_entities.PluginMeta{
+				Version: "0.0.1",
+				Arch:    []constants.Arch{constants.AMD64, constants.ARM64},
+				Runner: plugin_entities.PluginRunner{
+					Language:   constants.Python,
+					Version:    "3.12",
+					Entrypoint: "main",
+				},
+			},
+		},
+	}
+	if len(domains) == 0 {
+		// the server is only known once the base url is set, it's not able to be declared
+		manifest.Resource.Permission = nil
+	}
+	if result.Files["manifest.yaml"], err = marshalYaml(manifest); err != nil {
+		return nil, err
+	}
+
+	result.Files["main.py"] = mainTemplate
+	result.Files["requirements.txt"] = requirementsTemplate
+	result.Files["openapi_client.py"] = clientTemplate
+	result.Files["_assets/icon.svg"] = iconTemplate
+	return result, nil
+}
+
+// convertSecuritySchemes turns schemes into credentials of the provider, schemes required by every
+// operation are required credentials
+func convertSecuritySchemes(spec *spec, schemes map[string]securityScheme) []plugin_entities.ProviderConfig {
+	required := map[string]bool{}
+	if requirements, ok := securityRequirements(spec.root["security"]); ok && len(requirements) == 1 {
+		for _, name := range requirements[0] {
+			required[name] = true
+		}
+	}
+
+	declared := spec.securitySchemes()
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	credentials := []plugin_entities.ProviderConfig{}
+	secret := func(name string, label string, help string) plugin_entities.ProviderConfig {
+		return plugin_entities.ProviderConfig{
+			Name:     name,
+			Type:     plugin_entities.CONFIG_TYPE_SECRET_INPUT,
+			Label:    plugin_entities.NewI18nObject(label),
+			Help:     parser.ToPtr(plugin_entities.NewI18nObject(truncate(help))),
+			Required: false,
+		}
+	}
+
+	for _, name := range names {
+		scheme := declared[name]
+		typ, _ := scheme["type"].(string)
+		in, _ := scheme["in"].(string)
+		parameterName, _ := scheme["name"].(string)
+		httpScheme, _ := scheme["scheme"].(string)
+		description, _ := scheme["description"].(string)
+		credential := identifier(name)
+
+		var converted securityScheme
+		var configs []plugin_entities.ProviderConfig
+		switch {
+		case typ == "apiKey" && parameterName != "" &&
+			(in == PARAMETER_IN_HEADER || in == PARAMETER_IN_QUERY || in == PARAMETER_IN_COOKIE):
+			converted = securityScheme{Type: SECURITY_TYPE_API_KEY, In: in, Name: parameterName, Credentials: []string{credential}}
+			configs = []plugin_entities.ProviderConfig{
+				secret(credential, name, orDefault(description, fmt.Sprintf("API key sent as the %s %s", in, parameterName))),
+			}
+		case typ == "http" && strings.EqualFold(httpScheme, "basic"):
+			converted = securityScheme{
+				Type:        SECURITY_TYPE_BASIC,
+				Credentials: []string{credential + "_username", credential + "_password"},
+			}
+			username := secret(credential+"_username", name+" username", orDefault(description, "Username of the basic authentication"))
+			username.Type = plugin_entities.CONFIG_TYPE_TEXT_INPUT
+			configs = []plugin_entities.ProviderConfig{
+				username,
+				secret(credential+"_password", name+" password", orDefault(description, "Password of the basic authentication")),
+			}
+		case typ == "http" && strings.EqualFold(httpScheme, "bearer"), typ == "oauth2", typ == "openIdConnect":
+			// oauth flows are not run by tools, access tokens are taken as they are
+			converted = securityScheme{Type: SECURITY_TYPE_BEARER, Credentials: []string{credential}}
+			configs = []plugin_entities.ProviderConfig{
+				secret(credential, name, orDefault(description, "Token sent as the bearer of the Authorization header")),
+			}
+		default:
+			continue
+		}
+
+		for i := range configs {
+			configs[i].Required = required[name]
+		}
+		schemes[name] = converted
+		credentials = append(credentials, configs...)
+	}
+	return credentials
+}
+
+func convertOperation(
+	spec *spec,
+	item map[string]any,
+	node map[string]any,
+	method string,
+	path string,
+	toolName string,
+	author string,
+) (*plugin_entities.ToolDeclaration, *operation, error) {
+	op := &operation{
+		Method:     strings.ToUpper(method),
+		Path:       path,
+		Parameters: []operationParameter{},
+		Security:   [][]string{},
+	}
+	if requirements, ok := securityRequirements(node["security"]); ok {
+		op.Security = requirements
+	} else if requirements, ok := securityRequirements(spec.root["security"]); ok {
+		op.Security = requirements
+	}
+
+	summary, _ := node["summary"].(string)
+	description, _ := node["description"].(string)
+	label := truncate(orDefault(strings.TrimSpace(summary), toolName))
+	llmDescription := truncate(orDefault(strings.TrimSpace(description), fmt.Sprintf("%s (%s %s)", label, op.Method, path)))
+
+	declaration := &plugin_entities.ToolDeclaration{
+		Identity: plugin_entities.ToolIdentity{
+			Author: author,
+			Name:   toolName,
+			Label:  plugin_entities.NewI18nObject(label),
+		},
+		Description: plugin_entities.ToolDescription{
+			Human: plugin_entities.NewI18nObject(label),
+			LLM:   llmDescription,
+		},
+		Parameters: []plugin_entities.ToolParameter{},
+	}
+
+	taken := map[string]bool{}
+	add := func(name string, in string, schema map[string]any, description string, required bool) {
+		parameterName := identifier(name)
+		// parameters in different locations are able to share names
+		if taken[parameterName] {
+			parameterName = identifier(in + "_" + name)
+		}
+		for i := 2; taken[parameterName]; i++ {
+			parameterName = fmt.Sprintf("%s_%d", identifier(in+"_"+name), i)
+		}
+		taken[parameterName] = true
+
+		parameter, isJSON := toolParameter(parameterName, schema, description, required)
+		declaration.Parameters = append(declaration.Parameters, parameter)
+		op.Parameters = append(op.Parameters, operationParameter{Name: name, Parameter: parameterName, In: in, JSON: isJSON})
+	}
+
+	// parameters of the operation override parameters of the path with the same name and location
+	parameters := map[string]map[string]any{}
+	order := []string{}
+	for _, list := range []any{item["parameters"], node["parameters"]} {
+		list, _ := list.([]any)
+		for _, parameter := range list {
+			parameter, err := spec.resolve(parameter)
+			if err != nil {
+				return nil, nil, err
+			}
+			name, _ := parameter["name"].(string)
+			in, _ := parameter["in"].(string)
+			if name == "" {
+				continue
+			}
+			key := in + ":" + name
+			if _, ok := parameters[key]; !ok {
+				order = append(order, key)
+			}
+			parameters[key] = parameter
+		}
+	}
+	for _, key := range order {
+		parameter := parameters[key]
+		name, _ := parameter["name"].(string)
+		in, _ := parameter["in"].(string)
+		switch in {
+		case PARAMETER_IN_PATH, PARAMETER_IN_QUERY, PARAMETER_IN_HEADER, PARAMETER_IN_COOKIE:
+		default:
+			continue
+		}
+		schema, err := spec.resolve(parameter["schema"])
+		if err != nil {
+			return nil, nil, err
+		}
+		description, _ := parameter["description"].(string)
+		required, _ := parameter["required"].(bool)
+		add(name, in, schema, description, required || in == PARAMETER_IN_PATH)
+	}
+
+	requestBody, err := spec.resolve(node["requestBody"])
+	if err != nil {
+		return nil, nil, err
+	}
+	if requestBody != nil {
+		content, _ := requestBody["content"].(map[string]any)
+		contentType, media := bodyMedia(content)
+		if contentType != "" {
+			op.BodyContentType = contentType
+			bodyRequired, _ := requestBody["required"].(bool)
+			bodyDescription, _ := requestBody["description"].(string)
+
+			schema, err := spec.resolve(media["schema"])
+			if err != nil {
+				return nil, nil, err
+			}
+			properties, _ := schema["properties"].(map[string]any)
+			if isJSONMedia(contentType) && len(properties) > 0 {
+				// properties of json objects are parameters of their own, models fill them more accurately
+				requiredProperties := map[string]bool{}
+				if list, ok := schema["required"].([]any); ok {
+					for _, name := range list {
+						if name, ok := name.(string); ok {
+							requiredProperties[name] = true
+						}
+					}
+				}
+				names := make([]string, 0, len(properties))
+				for name := range properties {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					property, err := spec.resolve(properties[name])
+					if err != nil {
+						return nil, nil, err
+					}
+					propertyDescription, _ := property["description"].(string)
+					add(name, PARAMETER_IN_BODY, property, propertyDescription, bodyRequired && requiredProperties[name])
+				}
+			} else {
+				add("body", PARAMETER_IN_RAW_BODY, schema, orDefault(bodyDescription, "Body of the request in "+contentType), bodyRequired)
+			}
+		}
+	}
+
+	return declaration, op, nil
+}
+
+// supportedRequirements drops alternatives needing schemes which are not converted, e.g. mutual tls
+func supportedRequirements(requirements [][]string, schemes map[string]securityScheme) [][]string {
+	supported := [][]string{}
+	for _, requirement := range requirements {
+		ok := true
+		for _, name := range requirement {
+			if _, exists := schemes[name]; !exists {
+				ok = false
+			}
+		}
+		if ok {
+			supported = append(supported, requirement)
+		}
+	}
+	return supported
+}
+
+// bodyMedia picks the media type of the body, json is preferred as models generate it best
+func bodyMedia(content map[string]any) (string, map[string]any) {
+	types := make([]string, 0, len(content))
+	for contentType := range content {
+		types = append(types, contentType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if isJSONMedia(types[i]) != isJSONMedia(types[j]) {
+			return isJSONMedia(types[i])
+		}
+		return types[i] < types[j]
+	})
+	for _, contentType := range types {
+		// files are not able to be passed by models
+		if strings.HasPrefix(contentType, "multipart/") || contentType == "application/octet-stream" {
+			continue
+		}
+		media, _ := content[contentType].(map[string]any)
+		return contentType, media
+	}
+	return "", nil
+}
+
+func isJSONMedia(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// toolParameter declares a parameter by its schema, objects and arrays are taken as json strings
+func toolParameter(
+	name string,
+	schema map[string]any,
+	description string,
+	required bool,
+) (plugin_entities.ToolParameter, bool) {
+	if description == "" {
+		description, _ = schema["description"].(string)
+	}
+	description = strings.TrimSpace(description)
+
+	parameter := plugin_entities.ToolParameter{
+		Name:     name,
+		Label:    plugin_entities.NewI18nObject(name),
+		Type:     plugin_entities.TOOL_PARAMETER_TYPE_STRING,
+		Form:     plugin_entities.TOOL_PARAMETER_FORM_LLM,
+		Required: required,
+	}
+
+	typ, _ := schema["type"].(string)
+	isJSON := false
+	switch typ {
+	case "integer", "number":
+		parameter.Type = plugin_entities.TOOL_PARAMETER_TYPE_NUMBER
+	case "boolean":
+		parameter.Type = plugin_entities.TOOL_PARAMETER_TYPE_BOOLEAN
+	case "object", "array":
+		isJSON = true
+		encoded, err := json.Marshal(schema)
+		if err == nil && len(encoded) < 512 {
+			description = strings.TrimSpace(description + " JSON encoded value of the schema " + string(encoded))
+		} else {
+			description = strings.TrimSpace(description + " JSON encoded " + typ)
+		}
+	}
+
+	if values, ok := schema["enum"].([]any); ok && typ != "object" && typ != "array" {
+		options := []plugin_entities.ToolParameterOption{}
+		for _, value := range values {
+			if value == nil {
+				continue
+			}
+			text := fmt.Sprint(value)
+			options = append(options, plugin_entities.ToolParameterOption{
+				Value: text,
+				Label: plugin_entities.NewI18nObject(text),
+			})
+		}
+		if len(options) > 0 {
+			parameter.Type = plugin_entities.TOOL_PARAMETER_TYPE_SELECT
+			parameter.Options = options
+		}
+	}
+
+	switch value := schema["default"].(type) {
+	case string, bool, int, float64:
+		parameter.Default = value
+	}
+
+	description = truncate(orDefault(description, name))
+	parameter.HumanDescription = plugin_entities.NewI18nObject(description)
+	parameter.LLMDescription = description
+	return parameter, isJSON
+}
+
+var invalidIdentifierCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// identifier turns text into a name of plugins, tools or parameters
+func identifier(text string) string {
+	// camel cases are split into words, e.g. `listPets` into `list_pets`
+	words := strings.Builder{}
+	for i, r := range text {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			previous, _ := utf8.DecodeLastRuneInString(text[:i])
+			if previous >= 'a' && previous <= 'z' || previous >= '0' && previous <= '9' {
+				words.WriteRune('_')
+			}
+		}
+		words.WriteRune(r)
+	}
+	name := strings.Trim(invalidIdentifierCharacters.ReplaceAllString(strings.ToLower(words.String()), "_"), "_")
+	if len(name) > 64 {
+		name = strings.TrimRight(name[:64], "_")
+	}
+	if name == "" {
+		name = "openapi"
+	}
+	return name
+}
+
+func operationName(node map[string]any, method string, path string) string {
+	if operationID, ok := node["operationId"].(string); ok && strings.TrimSpace(operationID) != "" {
+		return identifier(operationID)
+	}
+	return identifier(method + "_" + path)
+}
+
+func uniqueName(name string, taken map[string]operation) string {
+	unique := name
+	for i := 2; ; i++ {
+		if _, ok := taken[unique]; !ok {
+			return unique
+		}
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+}
+
+// className names a python class, e.g. `list_pets` as `ListPets`
+func className(name string) string {
+	words := strings.Split(name, "_")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	class := strings.Join(words, "")
+	if class == "" || (class[0] >= '0' && class[0] <= '9') {
+		class = "Operation" + class
+	}
+	return class
+}
+
+func truncate(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= maxDescriptionLength {
+		return text
+	}
+	return string([]rune(text)[:maxDescriptionLength])
+}
+
+func orDefault(value string, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func render(source string, data any) ([]byte, error) {
+	tmpl, err := template.New("source").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	buffer := bytes.NewBuffer(nil)
+	if err := tmpl.Execute(buffer, data); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func marshalYaml(v any) ([]byte, error) {
+	buffer := bytes.NewBuffer(nil)
+	encoder := yaml.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,222 @@
+package openapi_converter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
+)
+
+const petstore = `
+openapi: 3.0.3
+info:
+  title: Pet Store
+  version: 1.2.0
+  description: Manage pets of the store
+servers:
+  - url: https://{region}.pets.example.com/v1
+    variables:
+      region:
+        default: us
+security:
+  - api_key: []
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List pets
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            default: 20
+        - name: status
+          in: query
+          schema:
+            type: string
+            enum: [available, sold]
+    post:
+      operationId: createPet
+      summary: Create a pet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+  /pets/{id}:
+    parameters:
+      - $ref: '#/components/parameters/PetID'
+    get:
+      summary: Get a pet
+      security:
+        - bearer: []
+        - {}
+    put:
+      operationId: upload_photo
+      requestBody:
+        content:
+          multipart/form-data:
+            schema:
+              type: object
+components:
+  parameters:
+    PetID:
+      name: id
+      in: path
+      description: ID of the pet
+      schema:
+        type: string
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+        id:
+          type: integer
+        tags:
+          type: array
+          items:
+            type: string
+  securitySchemes:
+    api_key:
+      type: apiKey
+      in: header
+      name: X-API-Key
+    bearer:
+      type: http
+      scheme: bearer
+    mtls:
+      type: mutualTLS
+`
+
+func TestConvert(t *testing.T) {
+	result, err := Convert([]byte(petstore), Options{Author: "langgenius"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Name != "pet_store" || result.Version != "1.2.0" {
+		t.Fatalf("unexpected name and version %s %s", result.Name, result.Version)
+	}
+	expectedTools := []string{"list_pets", "create_pet", "get_pets_id", "upload_photo"}
+	if len(result.Tools) != len(expectedTools) {
+		t.Fatalf("expected tools %v, got %v", expectedTools, result.Tools)
+	}
+	for i, tool := range expectedTools {
+		if result.Tools[i] != tool {
+			t.Fatalf("expected tools %v, got %v", expectedTools, result.Tools)
+		}
+	}
+
+	operations := operationsFile{}
+	if err := json.Unmarshal(result.Files["operations.json"], &operations); err != nil {
+		t.Fatal(err)
+	}
+	if operations.Server != "https://us.pets.example.com/v1" {
+		t.Fatalf("server variables should be replaced by defaults, got %s", operations.Server)
+	}
+	if _, ok := operations.SecuritySchemes["mtls"]; ok {
+		t.Fatal("unsupported schemes should be skipped")
+	}
+	getPet := operations.Operations["get_pets_id"]
+	if getPet.Method != "GET" || getPet.Path != "/pets/{id}" ||
+		len(getPet.Parameters) != 1 || getPet.Parameters[0].In != PARAMETER_IN_PATH {
+		t.Fatalf("parameters of paths should apply to operations, got %+v", getPet)
+	}
+	if len(getPet.Security) != 2 || getPet.Security[0][0] != "bearer" || len(getPet.Security[1]) != 0 {
+		t.Fatalf("security of operations should override the security of the spec, got %v", getPet.Security)
+	}
+	createPet := operations.Operations["create_pet"]
+	if createPet.BodyContentType != "application/json" || len(createPet.Parameters) != 3 ||
+		createPet.Parameters[0].Name != "id" || !createPet.Parameters[2].JSON {
+		t.Fatalf("properties of json bodies should be parameters, got %+v", createPet)
+	}
+	if uploadPhoto := operations.Operations["upload_photo"]; len(uploadPhoto.Parameters) != 1 || uploadPhoto.BodyContentType != "" {
+		t.Fatal("multipart bodies should be skipped")
+	}
+
+	// the generated source is a valid plugin
+	source := t.TempDir()
+	for path, content := range result.Files {
+		target := filepath.Join(source, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsDecoder, err := decoder.NewFSPluginDecoder(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := packager.NewPackager(fsDecoder).Pack(52428800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipDecoder, err := decoder.NewZipPluginDecoder(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declaration, err := zipDecoder.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if declaration.Author != "langgenius" || declaration.Tool == nil || len(declaration.Tool.Tools) != 4 {
+		t.Fatalf("unexpected declaration %+v", declaration)
+	}
+	if domains := declaration.Resource.Permission.Network.Domains; len(domains) != 1 || domains[0] != "us.pets.example.com" {
+		t.Fatalf("hosts of servers should be declared, got %v", domains)
+	}
+
+	credentials := map[string]plugin_entities.ProviderConfig{}
+	for _, credential := range declaration.Tool.CredentialsSchema {
+		credentials[credential.Name] = credential
+	}
+	if len(credentials) != 3 || credentials[CREDENTIAL_BASE_URL].Default != "https://us.pets.example.com/v1" {
+		t.Fatalf("unexpected credentials %+v", declaration.Tool.CredentialsSchema)
+	}
+	if !credentials["api_key"].Required || credentials["bearer"].Required ||
+		credentials["api_key"].Type != plugin_entities.CONFIG_TYPE_SECRET_INPUT {
+		t.Fatalf("schemes required by the spec should be required credentials, got %+v", credentials)
+	}
+
+	listPets := declaration.Tool.Tools[0]
+	if listPets.Identity.Name != "list_pets" || len(listPets.Parameters) != 2 {
+		t.Fatalf("unexpected tool %+v", listPets)
+	}
+	if limit := listPets.Parameters[0]; limit.Type != plugin_entities.TOOL_PARAMETER_TYPE_NUMBER || limit.Default != 20 {
+		t.Fatalf("unexpected parameter %+v", limit)
+	}
+	if status := listPets.Parameters[1]; status.Type != plugin_entities.TOOL_PARAMETER_TYPE_SELECT || len(status.Options) != 2 {
+		t.Fatalf("enums should be selects, got %+v", status)
+	}
+}
+
+func TestConvertRejectsInvalidSpecs(t *testing.T) {
+	cases := map[string]string{
+		"swagger 2":     "swagger: '2.0'\npaths: {}",
+		"no operations": "openapi: 3.1.0\ninfo: {title: empty}\npaths: {}",
+		"remote ref":    "openapi: 3.1.0\ninfo: {title: remote}\npaths:\n  /a:\n    get:\n      parameters:\n        - $ref: 'https://example.com/p.yaml'",
+		"not yaml":      "{",
+	}
+	for name, spec := range cases {
+		if _, err := Convert([]byte(spec), Options{Author: "langgenius"}); !errors.Is(err, ErrInvalidSpec) {
+			t.Fatalf("%s: expected invalid spec, got %v", name, err)
+		}
+	}
+
+	if _, err := Convert([]byte(petstore), Options{Author: "Not Valid"}); err == nil {
+		t.Fatal("invalid authors should be rejected")
+	}
+}
@@ -0,0 +1,134 @@
+package openapi_converter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// operation methods in the order they're converted
+var operationMethods = []string{"get", "put", "post", "delete", "patch", "head", "options", "trace"}
+
+var (
+	ErrInvalidSpec = errors.New("invalid openapi spec")
+)
+
+// spec is an OpenAPI 3 document decoded as it is, only the fields the converter needs are read,
+// so vendor extensions and newer fields never fail the conversion
+type spec struct {
+	root map[string]any
+}
+
+// parseSpec decodes an OpenAPI 3 document in yaml or json, json is a subset of yaml
+func parseSpec(data []byte) (*spec, error) {
+	root := map[string]any{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, errors.Join(ErrInvalidSpec, err)
+	}
+
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, errors.Join(ErrInvalidSpec, errors.New("only openapi 3 specs are supported"))
+	}
+	if _, ok := root["paths"].(map[string]any); !ok {
+		return nil, errors.Join(ErrInvalidSpec, errors.New("paths are required"))
+	}
+
+	return &spec{root: root}, nil
+}
+
+func (s *spec) info(field string) string {
+	info, _ := s.root["info"].(map[string]any)
+	value, _ := info[field].(string)
+	return strings.TrimSpace(value)
+}
+
+// serverURLs returns absolute urls of the servers, variables are replaced by their defaults
+func (s *spec) serverURLs() []string {
+	servers, _ := s.root["servers"].([]any)
+	urls := []string{}
+	for _, server := range servers {
+		server, _ := server.(map[string]any)
+		serverURL, _ := server["url"].(string)
+		variables, _ := server["variables"].(map[string]any)
+		for name, variable := range variables {
+			variable, _ := variable.(map[string]any)
+			serverURL = strings.ReplaceAll(serverURL, "{"+name+"}", fmt.Sprint(variable["default"]))
+		}
+
+		parsed, err := url.Parse(serverURL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			continue
+		}
+		urls = append(urls, strings.TrimSuffix(serverURL, "/"))
+	}
+	return urls
+}
+
+// resolve follows local references like `#/components/schemas/Pet`, remote references are not followed
+func (s *spec) resolve(node any) (map[string]any, error) {
+	object, _ := node.(map[string]any)
+	// references may point to references, cycles are cut
+	for depth := 0; object != nil; depth++ {
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object, nil
+		}
+		if depth >= 16 {
+			return nil, fmt.Errorf("reference %s is too deep", ref)
+		}
+
+		pointer, ok := strings.CutPrefix(ref, "#/")
+		if !ok {
+			return nil, fmt.Errorf("reference %s is not local", ref)
+		}
+		var current any = s.root
+		for _, token := range strings.Split(pointer, "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			parent, _ := current.(map[string]any)
+			current, ok = parent[token]
+			if !ok {
+				return nil, fmt.Errorf("reference %s is not found", ref)
+			}
+		}
+		object, _ = current.(map[string]any)
+	}
+	return nil, nil
+}
+
+// securitySchemes returns the schemes declared in components
+func (s *spec) securitySchemes() map[string]map[string]any {
+	components, _ := s.root["components"].(map[string]any)
+	schemes, _ := components["securitySchemes"].(map[string]any)
+	result := map[string]map[string]any{}
+	for name, scheme := range schemes {
+		if resolved, err := s.resolve(scheme); err == nil && resolved != nil {
+			result[name] = resolved
+		}
+	}
+	return result
+}
+
+// securityRequirements decodes security requirements, alternatives of schemes, e.g. `[{api_key: []}, {}]`
+func securityRequirements(node any) ([][]string, bool) {
+	requirements, ok := node.([]any)
+	if !ok {
+		return nil, false
+	}
+
+	result := [][]string{}
+	for _, requirement := range requirements {
+		requirement, _ := requirement.(map[string]any)
+		names := []string{}
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		result = append(result, names)
+	}
+	return result, true
+}
@@ -0,0 +1,106 @@
+# Generated from an OpenAPI spec, requests of operations are described by operations.json.
+import json
+import os
+from collections.abc import Generator
+from typing import Any
+from urllib.parse import quote, urlparse
+
+import requests
+from dify_plugin import Tool
+from dify_plugin.entities.tool import ToolInvokeMessage
+
+with open(os.path.join(os.path.dirname(os.path.abspath(__file__)), "operations.json"), encoding="utf-8") as f:
+    SPEC = json.load(f)
+
+TIMEOUT = 60
+
+
+def base_url(credentials: dict[str, Any]) -> str:
+    url = (credentials.get("base_url") or SPEC["server"] or "").rstrip("/")
+    parsed = urlparse(url)
+    if parsed.scheme not in ("http", "https") or not parsed.netloc:
+        raise ValueError("base url should be an http or https url")
+    return url
+
+
+def validate_credentials(credentials: dict[str, Any]) -> None:
+    base_url(credentials)
+
+
+def apply_security(operation: dict, credentials: dict[str, Any], headers: dict, query: dict, cookies: dict):
+    for requirement in operation["security"]:
+        schemes = [SPEC["security_schemes"][name] for name in requirement]
+        if not all(credentials.get(name) for scheme in schemes for name in scheme["credentials"]):
+            continue
+        for scheme in schemes:
+            values = [credentials[name] for name in scheme["credentials"]]
+            if scheme["type"] == "api_key":
+                {"header": headers, "query": query, "cookie": cookies}[scheme["in"]][scheme["name"]] = values[0]
+            elif scheme["type"] == "bearer":
+                headers["Authorization"] = f"Bearer {values[0]}"
+            elif scheme["type"] == "basic":
+                headers["Authorization"] = requests.auth._basic_auth_str(values[0], values[1])
+        return
+
+
+def decode(value: Any, is_json: bool) -> Any:
+    if is_json and isinstance(value, str):
+        try:
+            return json.loads(value)
+        except ValueError:
+            return value
+    return value
+
+
+def invoke_operation(tool: Tool, name: str, parameters: dict[str, Any]) -> Generator[ToolInvokeMessage, None, None]:
+    operation = SPEC["operations"][name]
+    credentials = tool.runtime.credentials or {}
+
+    path = operation["path"]
+    query, headers, cookies = {}, {}, {}
+    body = None
+    for parameter in operation["parameters"]:
+        value = parameters.get(parameter["parameter"])
+        if value is None or value == "":
+            continue
+        value = decode(value, parameter.get("json", False))
+        location = parameter["in"]
+        if location == "path":
+            path = path.replace("{" + parameter["name"] + "}", quote(str(value), safe=""))
+        elif location == "query":
+            query[parameter["name"]] = value
+        elif location == "header":
+            headers[parameter["name"]] = str(value)
+        elif location == "cookie":
+            cookies[parameter["name"]] = str(value)
+        elif location == "body":
+            body = body or {}
+            body[parameter["name"]] = value
+        elif location == "raw_body":
+            body = value
+
+    apply_security(operation, credentials, headers, query, cookies)
+
+    kwargs: dict[str, Any] = {"params": query, "headers": headers, "cookies": cookies, "timeout": TIMEOUT}
+    content_type = operation.get("body_content_type")
+    if body is not None and content_type:
+        if content_type == "application/json" or content_type.endswith("+json"):
+            kwargs["json"] = body
+        else:
+            headers["Content-Type"] = content_type
+            kwargs["data"] = body if isinstance(body, (str, bytes, dict)) else json.dumps(body)
+
+    response = requests.request(operation["method"], base_url(credentials) + path, **kwargs)
+    if response.status_code >= 400:
+        raise Exception(f"request failed with status {response.status_code}: {response.text[:1024]}")
+
+    try:
+        data = response.json()
+    except ValueError:
+        yield tool.create_text_message(response.text)
+        return
+
+    if isinstance(data, dict):
+        yield tool.create_json_message(data)
+    else:
+        yield tool.create_json_message({"data": data})
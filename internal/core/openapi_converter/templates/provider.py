@@ -0,0 +1,14 @@
+from typing import Any
+
+from dify_plugin import ToolProvider
+from dify_plugin.errors.tool import ToolProviderCredentialValidationError
+
+from openapi_client import validate_credentials
+
+
+class {{ .Class }}(ToolProvider):
+    def _validate_credentials(self, credentials: dict[str, Any]) -> None:
+        try:
+            validate_credentials(credentials)
+        except Exception as e:
+            raise ToolProviderCredentialValidationError(str(e))
@@ -0,0 +1,12 @@
+from collections.abc import Generator
+from typing import Any
+
+from dify_plugin import Tool
+from dify_plugin.entities.tool import ToolInvokeMessage
+
+from openapi_client import invoke_operation
+
+
+class {{ .Class }}(Tool):
+    def _invoke(self, tool_parameters: dict[str, Any]) -> Generator[ToolInvokeMessage]:
+        yield from invoke_operation(self, "{{ .Operation }}", tool_parameters)
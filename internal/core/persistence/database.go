@@ -0,0 +1,106 @@
+package persistence
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// databaseStorage keeps values in the database of the daemon, it suits small values of deployments
+// without object storages, sizable values are better kept by the other types
+type databaseStorage struct{}
+
+func NewDatabaseStorage() *databaseStorage {
+	return &databaseStorage{}
+}
+
+func (s *databaseStorage) get(tenant_id string, plugin_checksum string, key string, query ...db.GenericQuery) (
+	models.PersistenceValue, error,
+) {
+	// transactions are the first queries of the chain
+	return db.GetOne[models.PersistenceValue](append(query,
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("plugin_id", plugin_checksum),
+		db.Equal("key", key),
+	)...)
+}
+
+func (s *databaseStorage) Save(tenant_id string, plugin_checksum string, key string, data []byte) error {
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		value, err := s.get(tenant_id, plugin_checksum, key, db.WithTransactionContext(tx), db.WLock())
+		if errors.Is(err, db.ErrDatabaseNotFound) {
+			return db.Create(&models.PersistenceValue{
+				TenantID: tenant_id,
+				PluginID: plugin_checksum,
+				Key:      key,
+				Value:    data,
+			}, tx)
+		}
+		if err != nil {
+			return err
+		}
+
+		value.Value = data
+		return db.Update(&value, tx)
+	})
+}
+
+func (s *databaseStorage) Load(tenant_id string, plugin_checksum string, key string) ([]byte, error) {
+	value, err := s.get(tenant_id, plugin_checksum, key)
+	if err != nil {
+		return nil, err
+	}
+	return value.Value, nil
+}
+
+func (s *databaseStorage) Delete(tenant_id string, plugin_checksum string, key string) error {
+	return db.DeleteByCondition(models.PersistenceValue{
+		TenantID: tenant_id,
+		PluginID: plugin_checksum,
+		Key:      key,
+	})
+}
+
+func (s *databaseStorage) StateSize(tenant_id string, plugin_checksum string, key string) (int64, error) {
+	value, err := s.get(tenant_id, plugin_checksum, key)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(value.Value)), nil
+}
+
+func (s *databaseStorage) Append(tenant_id string, plugin_checksum string, key string, data []byte) error {
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		value, err := s.get(tenant_id, plugin_checksum, key, db.WithTransactionContext(tx), db.WLock())
+		if errors.Is(err, db.ErrDatabaseNotFound) {
+			return db.Create(&models.PersistenceValue{
+				TenantID: tenant_id,
+				PluginID: plugin_checksum,
+				Key:      key,
+				Value:    data,
+			}, tx)
+		}
+		if err != nil {
+			return err
+		}
+
+		value.Value = append(value.Value, data...)
+		return db.Update(&value, tx)
+	})
+}
+
+func (s *databaseStorage) LoadRange(
+	tenant_id string, plugin_checksum string, key string, offset int64, length int64,
+) ([]byte, error) {
+	if offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+
+	data, err := s.Load(tenant_id, plugin_checksum, key)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, offset, length), nil
+}
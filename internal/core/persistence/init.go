@@ -2,21 +2,60 @@ package persistence
 
 import (
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss/local"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss/s3"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 )
 
+const (
+	defaultMaxCacheSize = 64 * 1024
+)
+
 var (
 	persistence *Persistence
 )
 
 func InitPersistence(oss oss.OSS, config *app.Config) {
+	maxCacheSize := config.PersistenceCacheMaxSize
+	if maxCacheSize == 0 {
+		maxCacheSize = defaultMaxCacheSize
+	}
+
 	persistence = &Persistence{
-		storage:        NewWrapper(oss, config.PersistenceStoragePath),
+		storage:        newPersistenceStorage(oss, config),
 		maxStorageSize: config.PersistenceStorageMaxSize,
+		maxCacheSize:   maxCacheSize,
+	}
+
+	log.Info("Persistence initialized, storage type: %s", config.PersistenceStorageType)
+}
+
+// newPersistenceStorage creates the storage selected by the deployment, the storage of plugin
+// packages is shared by default
+func newPersistenceStorage(storage oss.OSS, config *app.Config) PersistenceStorage {
+	switch config.PersistenceStorageType {
+	case PERSISTENCE_STORAGE_TYPE_DATABASE:
+		return NewDatabaseStorage()
+	case PERSISTENCE_STORAGE_TYPE_LOCAL:
+		return NewWrapper(local.NewLocalStorage(config.PersistenceStorageLocalRoot), config.PersistenceStoragePath)
+	case PERSISTENCE_STORAGE_TYPE_S3:
+		s3Storage, err := s3.NewS3Storage(
+			config.S3UseAwsManagedIam,
+			config.S3Endpoint,
+			config.S3UsePathStyle,
+			config.AWSAccessKey,
+			config.AWSSecretKey,
+			config.PersistenceStorageOSSBucket,
+			config.AWSRegion,
+		)
+		if err != nil {
+			log.Panic("Failed to create persistence storage: %s", err)
+		}
+		return NewWrapper(s3Storage, config.PersistenceStoragePath)
 	}
 
-	log.Info("Persistence initialized")
+	return NewWrapper(storage, config.PersistenceStoragePath)
 }
 
 func GetPersistence() *Persistence {
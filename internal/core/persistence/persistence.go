@@ -12,6 +12,8 @@ import (
 
 type Persistence struct {
 	maxStorageSize int64
+	// values larger than maxCacheSize are not cached, they're loaded from the storage every time
+	maxCacheSize int64
 
 	storage PersistenceStorage
 }
@@ -37,43 +39,8 @@ func (c *Persistence) Save(tenantId string, pluginId string, maxSize int64, key
 		return err
 	}
 
-	allocatedSize := int64(len(data))
-
-	storage, err := db.GetOne[models.TenantStorage](
-		db.Equal("tenant_id", tenantId),
-		db.Equal("plugin_id", pluginId),
-	)
-	if err != nil {
-		if allocatedSize > c.maxStorageSize || allocatedSize > maxSize {
-			return fmt.Errorf("allocated size is greater than max storage size")
-		}
-
-		if err == db.ErrDatabaseNotFound {
-			storage = models.TenantStorage{
-				TenantID: tenantId,
-				PluginID: pluginId,
-				Size:     allocatedSize,
-			}
-			if err := db.Create(&storage); err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
-	} else {
-		if allocatedSize+storage.Size > maxSize || allocatedSize+storage.Size > c.maxStorageSize {
-			return fmt.Errorf("allocated size is greater than max storage size")
-		}
-
-		err = db.Run(
-			db.Model(&models.TenantStorage{}),
-			db.Equal("tenant_id", tenantId),
-			db.Equal("plugin_id", pluginId),
-			db.Inc(map[string]int64{"size": allocatedSize}),
-		)
-		if err != nil {
-			return err
-		}
+	if err := c.allocate(tenantId, pluginId, maxSize, int64(len(data))); err != nil {
+		return err
 	}
 
 	// delete from cache
@@ -98,6 +65,9 @@ func (c *Persistence) Load(tenantId string, pluginId string, key string) ([]byte
 	}
 
 	// add to cache
+	if int64(len(data)) > c.maxCacheSize {
+		return data, nil
+	}
 	cache.Store(c.getCacheKey(tenantId, pluginId, key), hex.EncodeToString(data), time.Minute*5)
 
 	return data, nil
@@ -134,3 +104,77 @@ func (c *Persistence) Delete(tenantId string, pluginId string, key string) error
 
 	return nil
 }
+
+// Append appends data to the value of key, so sizable values are written chunk by chunk
+func (c *Persistence) Append(tenantId string, pluginId string, maxSize int64, key string, data []byte) error {
+	if len(key) > 256 {
+		return fmt.Errorf("key length must be less than 256 characters")
+	}
+
+	if maxSize == -1 {
+		maxSize = c.maxStorageSize
+	}
+
+	// quota is allocated first, appended data is not able to be taken back on failures
+	if err := c.allocate(tenantId, pluginId, maxSize, int64(len(data))); err != nil {
+		return err
+	}
+
+	if err := c.storage.Append(tenantId, pluginId, key, data); err != nil {
+		return err
+	}
+
+	return cache.Del(c.getCacheKey(tenantId, pluginId, key))
+}
+
+// LoadRange loads length bytes of the value of key from offset, to the end if length is negative,
+// so sizable values are read chunk by chunk, the size of the whole value is returned as well
+func (c *Persistence) LoadRange(tenantId string, pluginId string, key string, offset int64, length int64) (
+	[]byte, int64, error,
+) {
+	size, err := c.storage.StateSize(tenantId, pluginId, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data, err := c.storage.LoadRange(tenantId, pluginId, key, offset, length)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return data, size, nil
+}
+
+// allocate accounts size to the storage of the plugin, it fails once the storage exceeds maxSize
+func (c *Persistence) allocate(tenantId string, pluginId string, maxSize int64, allocatedSize int64) error {
+	storage, err := db.GetOne[models.TenantStorage](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+	)
+	if err != nil {
+		if allocatedSize > c.maxStorageSize || allocatedSize > maxSize {
+			return fmt.Errorf("allocated size is greater than max storage size")
+		}
+
+		if err == db.ErrDatabaseNotFound {
+			storage = models.TenantStorage{
+				TenantID: tenantId,
+				PluginID: pluginId,
+				Size:     allocatedSize,
+			}
+			return db.Create(&storage)
+		}
+		return err
+	}
+
+	if allocatedSize+storage.Size > maxSize || allocatedSize+storage.Size > c.maxStorageSize {
+		return fmt.Errorf("allocated size is greater than max storage size")
+	}
+
+	return db.Run(
+		db.Model(&models.TenantStorage{}),
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+		db.Inc(map[string]int64{"size": allocatedSize}),
+	)
+}
@@ -5,4 +5,17 @@ type PersistenceStorage interface {
 	Load(tenant_id string, plugin_checksum string, key string) ([]byte, error)
 	Delete(tenant_id string, plugin_checksum string, key string) error
 	StateSize(tenant_id string, plugin_checksum string, key string) (int64, error)
+	// Append appends data to the value of key, the value is created if it doesn't exist
+	Append(tenant_id string, plugin_checksum string, key string, data []byte) error
+	// LoadRange loads length bytes of the value of key from offset, to the end if length is negative
+	LoadRange(tenant_id string, plugin_checksum string, key string, offset int64, length int64) ([]byte, error)
 }
+
+const (
+	// PERSISTENCE_STORAGE_TYPE_PLUGIN_STORAGE keeps values in the storage of plugin packages
+	PERSISTENCE_STORAGE_TYPE_PLUGIN_STORAGE = "plugin_storage"
+	// PERSISTENCE_STORAGE_TYPE_DATABASE keeps values in the database of the daemon
+	PERSISTENCE_STORAGE_TYPE_DATABASE = "database"
+	PERSISTENCE_STORAGE_TYPE_LOCAL    = "local"
+	PERSISTENCE_STORAGE_TYPE_S3       = "aws_s3"
+)
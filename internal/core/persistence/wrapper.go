@@ -1,6 +1,7 @@
 package persistence
 
 import (
+	"errors"
 	"path"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
@@ -48,3 +49,56 @@ func (s *wrapper) StateSize(tenant_id string, plugin_checksum string, key string
 
 	return state.Size, nil
 }
+
+func (s *wrapper) Append(tenant_id string, plugin_checksum string, key string, data []byte) error {
+	filePath := s.getFilePath(tenant_id, plugin_checksum, key)
+	if appender, ok := s.oss.(oss.Appender); ok {
+		return appender.Append(filePath, data)
+	}
+
+	// object storages have no appending, the value is rewritten as a whole
+	exists, err := s.oss.Exists(filePath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return s.oss.Save(filePath, data)
+	}
+	original, err := s.oss.Load(filePath)
+	if err != nil {
+		return err
+	}
+	return s.oss.Save(filePath, append(original, data...))
+}
+
+func (s *wrapper) LoadRange(
+	tenant_id string, plugin_checksum string, key string, offset int64, length int64,
+) ([]byte, error) {
+	if offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+
+	filePath := s.getFilePath(tenant_id, plugin_checksum, key)
+	if loader, ok := s.oss.(oss.RangeLoader); ok {
+		return loader.LoadRange(filePath, offset, length)
+	}
+
+	data, err := s.oss.Load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, offset, length), nil
+}
+
+// sliceRange slices length bytes of data from offset, ranges beyond data are cut
+func sliceRange(data []byte, offset int64, length int64) []byte {
+	size := int64(len(data))
+	if offset >= size {
+		return []byte{}
+	}
+	end := size
+	if length >= 0 && offset+length < size {
+		end = offset + length
+	}
+	return data[offset:end]
+}
@@ -0,0 +1,62 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss/local"
+)
+
+// wholeObjectStorage hides appending and range loading of a storage, as object storages lacking them do
+type wholeObjectStorage struct {
+	oss.OSS
+}
+
+func TestWrapperAppendAndLoadRange(t *testing.T) {
+	storages := map[string]oss.OSS{
+		"local":        local.NewLocalStorage(t.TempDir()),
+		"whole_object": wholeObjectStorage{local.NewLocalStorage(t.TempDir())},
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			wrapper := NewWrapper(storage, "persistence")
+
+			for _, chunk := range []string{"hello", " ", "world"} {
+				if err := wrapper.Append("tenant", "plugin", "key", []byte(chunk)); err != nil {
+					t.Fatalf("append failed: %v", err)
+				}
+			}
+
+			size, err := wrapper.StateSize("tenant", "plugin", "key")
+			if err != nil || size != 11 {
+				t.Fatalf("expected size 11, got %d, %v", size, err)
+			}
+
+			ranges := []struct {
+				offset int64
+				length int64
+				data   string
+			}{
+				{0, 5, "hello"},
+				{6, -1, "world"},
+				{6, 100, "world"},
+				{100, 5, ""},
+				{0, 0, ""},
+			}
+			for _, r := range ranges {
+				data, err := wrapper.LoadRange("tenant", "plugin", "key", r.offset, r.length)
+				if err != nil {
+					t.Fatalf("load range %d+%d failed: %v", r.offset, r.length, err)
+				}
+				if string(data) != r.data {
+					t.Fatalf("expected %q of range %d+%d, got %q", r.data, r.offset, r.length, data)
+				}
+			}
+
+			if _, err := wrapper.LoadRange("tenant", "plugin", "key", -1, 5); err == nil {
+				t.Fatal("negative offsets should be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package plugin_daemon
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/agent_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+// labels of logs reported by strategies built on the sdk, which are converted into steps, thoughts and tool calls
+// for strategies not reporting them by agent chunks
+const (
+	agentLogRoundPrefix   = "ROUND "
+	agentLogCallPrefix    = "CALL "
+	agentLogThoughtSuffix = "Thought"
+)
+
+// InvokeAgentStrategyEvents invokes the agent strategy and responds its trace as events in order, thoughts and
+// tool calls always follow the step they belong to, steps are finished before the next one starts, and a finish
+// event ends the stream once the strategy ends
+func InvokeAgentStrategyEvents(
+	session *session_manager.Session,
+	r *requests.RequestInvokeAgentStrategy,
+) (*stream.Stream[agent_entities.AgentEvent], error) {
+	response, err := invokeAgentStrategy(session, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	events := stream.NewStream[agent_entities.AgentEvent](128)
+	// the caller closes the stream if it's gone
+	events.OnClose(func() {
+		response.Close()
+	})
+
+	routine.Submit(map[string]string{
+		"module":                  "plugin_daemon",
+		"function":                "InvokeAgentStrategyEvents",
+		"agent_strategy_name":     r.AgentStrategy,
+		"agent_strategy_provider": r.AgentStrategyProvider,
+		"session_id":              session.ID,
+		"plugin_id":               session.PluginUniqueIdentifier.PluginID(),
+	}, func() {
+		defer events.Close()
+		defer routine.ReportPanic(events.WriteError)
+
+		converter := newAgentEventConverter()
+		for response.Next() {
+			item, err := response.Read()
+			if err != nil {
+				events.WriteError(err)
+				return
+			}
+
+			for _, event := range converter.convert(item.ToolResponseChunk) {
+				if err := events.Write(event); err != nil {
+					return
+				}
+			}
+		}
+
+		for _, event := range converter.end() {
+			events.Write(event)
+		}
+	})
+
+	return events, nil
+}
+
+// agentEventConverter converts chunks of agent strategies into events in order
+type agentEventConverter struct {
+	seq     int
+	started time.Time
+
+	// step is the step open, nil if there is none
+	step      *agent_entities.AgentStep
+	steps     int
+	toolCalls int
+	// calls are tool calls of the open step which are not finished yet
+	calls map[string]*agent_entities.AgentToolCall
+}
+
+func newAgentEventConverter() *agentEventConverter {
+	return &agentEventConverter{
+		started: time.Now(),
+		calls:   map[string]*agent_entities.AgentToolCall{},
+	}
+}
+
+func (c *agentEventConverter) next(event agent_entities.AgentEvent) agent_entities.AgentEvent {
+	event.Seq = c.seq
+	c.seq++
+	return event
+}
+
+// convert returns events of the chunk, events finishing or opening steps are prepended to keep them in order
+func (c *agentEventConverter) convert(chunk tool_entities.ToolResponseChunk) []agent_entities.AgentEvent {
+	switch chunk.Type {
+	case agent_entities.AgentStrategyResponseChunkTypeStep:
+		if step, err := parser.MapToStruct[agent_entities.AgentStep](chunk.Message); err == nil {
+			return c.stepEvent(*step)
+		}
+	case agent_entities.AgentStrategyResponseChunkTypeThought:
+		if thought, err := parser.MapToStruct[agent_entities.AgentThought](chunk.Message); err == nil {
+			return c.thoughtEvent(*thought)
+		}
+	case agent_entities.AgentStrategyResponseChunkTypeToolCall:
+		if call, err := parser.MapToStruct[agent_entities.AgentToolCall](chunk.Message); err == nil {
+			return c.toolCallEvent(*call)
+		}
+	case tool_entities.ToolResponseChunkTypeLog:
+		if events := c.logEvents(chunk); events != nil {
+			return events
+		}
+	}
+
+	return []agent_entities.AgentEvent{c.next(agent_entities.AgentEvent{
+		Type:   agent_entities.AgentEventTypeOutput,
+		Output: &chunk,
+	})}
+}
+
+func (c *agentEventConverter) stepEvent(step agent_entities.AgentStep) []agent_entities.AgentEvent {
+	events := []agent_entities.AgentEvent{}
+
+	if step.Status == agent_entities.AgentStepStatusStarted {
+		if c.step != nil && c.step.ID == step.ID {
+			// started already
+			return events
+		}
+		events = append(events, c.finishStep()...)
+		c.steps++
+		if step.Index == 0 {
+			step.Index = c.steps
+		}
+		if step.ID == "" {
+			step.ID = fmt.Sprintf("step-%d", step.Index)
+		}
+		c.step = &step
+		return append(events, c.next(agent_entities.AgentEvent{Type: agent_entities.AgentEventTypeStep, Step: &step}))
+	}
+
+	if c.step == nil || (step.ID != "" && step.ID != c.step.ID) {
+		// steps finishing without being started are started first
+		started := step
+		started.Status = agent_entities.AgentStepStatusStarted
+		started.Error = ""
+		events = append(events, c.stepEvent(started)...)
+	}
+
+	step.ID, step.Index = c.step.ID, c.step.Index
+	events = append(events, c.closeCalls(step.Status)...)
+	c.step = nil
+	return append(events, c.next(agent_entities.AgentEvent{Type: agent_entities.AgentEventTypeStep, Step: &step}))
+}
+
+// openStep starts a step for thoughts and tool calls reported out of steps
+func (c *agentEventConverter) openStep(step_id string) []agent_entities.AgentEvent {
+	if c.step != nil && (step_id == "" || step_id == c.step.ID) {
+		return nil
+	}
+	return c.stepEvent(agent_entities.AgentStep{ID: step_id, Status: agent_entities.AgentStepStatusStarted})
+}
+
+func (c *agentEventConverter) thoughtEvent(thought agent_entities.AgentThought) []agent_entities.AgentEvent {
+	events := c.openStep(thought.StepID)
+	thought.StepID = c.step.ID
+	return append(events, c.next(agent_entities.AgentEvent{Type: agent_entities.AgentEventTypeThought, Thought: &thought}))
+}
+
+func (c *agentEventConverter) toolCallEvent(call agent_entities.AgentToolCall) []agent_entities.AgentEvent {
+	events := c.openStep(call.StepID)
+	call.StepID = c.step.ID
+	if call.ID == "" {
+		call.ID = fmt.Sprintf("%s-call-%d", call.StepID, c.toolCalls+1)
+	}
+
+	if _, ok := c.calls[call.ID]; !ok {
+		c.toolCalls++
+	}
+	if call.Status == agent_entities.AgentToolCallStatusStarted {
+		c.calls[call.ID] = &call
+	} else {
+		delete(c.calls, call.ID)
+	}
+	return append(events, c.next(agent_entities.AgentEvent{Type: agent_entities.AgentEventTypeToolCall, ToolCall: &call}))
+}
+
+// closeCalls fails tool calls of the step which are not finished once the step ends
+func (c *agentEventConverter) closeCalls(status agent_entities.AgentStepStatus) []agent_entities.AgentEvent {
+	ids := make([]string, 0, len(c.calls))
+	for id := range c.calls {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	events := []agent_entities.AgentEvent{}
+	for _, id := range ids {
+		call := c.calls[id]
+		failed := *call
+		failed.Status = agent_entities.AgentToolCallStatusFailed
+		failed.Error = fmt.Sprintf("step %s is %s before the call finished", call.StepID, status)
+		events = append(events, c.next(agent_entities.AgentEvent{Type: agent_entities.AgentEventTypeToolCall, ToolCall: &failed}))
+		delete(c.calls, id)
+	}
+	return events
+}
+
+// finishStep finishes the open step
+func (c *agentEventConverter) finishStep() []agent_entities.AgentEvent {
+	if c.step == nil {
+		return nil
+	}
+	return c.stepEvent(agent_entities.AgentStep{ID: c.step.ID, Status: agent_entities.AgentStepStatusFinished})
+}
+
+// logEvents converts logs of strategies built on the sdk, nil if the log is not a part of the trace
+func (c *agentEventConverter) logEvents(chunk tool_entities.ToolResponseChunk) []agent_entities.AgentEvent {
+	id, _ := chunk.Message["id"].(string)
+	parentID, _ := chunk.Message["parent_id"].(string)
+	label, _ := chunk.Message["label"].(string)
+	status, _ := chunk.Message["status"].(string)
+	logError, _ := chunk.Message["error"].(string)
+	data, _ := chunk.Message["data"].(map[string]any)
+	metadata, _ := chunk.Message["metadata"].(map[string]any)
+
+	switch {
+	case strings.HasPrefix(label, agentLogRoundPrefix):
+		index, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(label, agentLogRoundPrefix)))
+		step := agent_entities.AgentStep{ID: id, Index: index, Error: logError, Metadata: metadata}
+		switch status {
+		case "start":
+			step.Status = agent_entities.AgentStepStatusStarted
+		case "error":
+			step.Status = agent_entities.AgentStepStatusFailed
+		default:
+			step.Status = agent_entities.AgentStepStatusFinished
+		}
+		return c.stepEvent(step)
+	case strings.HasPrefix(label, agentLogCallPrefix):
+		call := agent_entities.AgentToolCall{ID: id, StepID: parentID, Error: logError}
+		call.ToolName, _ = data["tool_call_name"].(string)
+		if call.ToolName == "" {
+			call.ToolName = strings.TrimSpace(strings.TrimPrefix(label, agentLogCallPrefix))
+		}
+		call.Input, _ = data["tool_call_input"].(map[string]any)
+		if call.Input == nil {
+			call.Input, _ = data["tool_call_args"].(map[string]any)
+		}
+		call.Output = data["output"]
+		switch status {
+		case "start":
+			call.Status = agent_entities.AgentToolCallStatusStarted
+		case "error":
+			call.Status = agent_entities.AgentToolCallStatusFailed
+		default:
+			call.Status = agent_entities.AgentToolCallStatusSucceeded
+		}
+		return c.toolCallEvent(call)
+	case strings.HasSuffix(label, agentLogThoughtSuffix):
+		if status == "start" {
+			// thoughts are reported once they're complete
+			return []agent_entities.AgentEvent{}
+		}
+		text, _ := data["output"].(string)
+		return c.thoughtEvent(agent_entities.AgentThought{StepID: parentID, Text: text})
+	}
+	return nil
+}
+
+// end finishes the open step and returns the finish event ending the stream
+func (c *agentEventConverter) end() []agent_entities.AgentEvent {
+	events := c.finishStep()
+	return append(events, c.next(agent_entities.AgentEvent{
+		Type: agent_entities.AgentEventTypeFinish,
+		Finish: &agent_entities.AgentFinish{
+			Steps:     c.steps,
+			ToolCalls: c.toolCalls,
+			ElapsedMs: time.Since(c.started).Milliseconds(),
+		},
+	}))
+}
+
+// agentTraceAsLog converts steps, thoughts and tool calls into logs of the legacy stream, other chunks are kept
+func agentTraceAsLog(chunk agent_entities.AgentStrategyResponseChunk) agent_entities.AgentStrategyResponseChunk {
+	logChunk := func(message map[string]any) agent_entities.AgentStrategyResponseChunk {
+		return agent_entities.AgentStrategyResponseChunk{
+			ToolResponseChunk: tool_entities.ToolResponseChunk{
+				Type:    tool_entities.ToolResponseChunkTypeLog,
+				Message: message,
+				Meta:    chunk.Meta,
+			},
+		}
+	}
+
+	switch chunk.Type {
+	case agent_entities.AgentStrategyResponseChunkTypeStep:
+		step, err := parser.MapToStruct[agent_entities.AgentStep](chunk.Message)
+		if err != nil {
+			return chunk
+		}
+		return logChunk(map[string]any{
+			"id":       step.ID,
+			"label":    fmt.Sprintf("%s%d", agentLogRoundPrefix, step.Index),
+			"status":   agentLogStatus(step.Status == agent_entities.AgentStepStatusStarted, step.Status == agent_entities.AgentStepStatusFailed),
+			"error":    step.Error,
+			"data":     map[string]any{},
+			"metadata": step.Metadata,
+		})
+	case agent_entities.AgentStrategyResponseChunkTypeThought:
+		thought, err := parser.MapToStruct[agent_entities.AgentThought](chunk.Message)
+		if err != nil {
+			return chunk
+		}
+		return logChunk(map[string]any{
+			"id":        uuid.New().String(),
+			"parent_id": thought.StepID,
+			"label":     agentLogThoughtSuffix,
+			"status":    "success",
+			"data":      map[string]any{"output": thought.Text},
+			"metadata":  map[string]any{},
+		})
+	case agent_entities.AgentStrategyResponseChunkTypeToolCall:
+		call, err := parser.MapToStruct[agent_entities.AgentToolCall](chunk.Message)
+		if err != nil {
+			return chunk
+		}
+		return logChunk(map[string]any{
+			"id":        call.ID,
+			"parent_id": call.StepID,
+			"label":     agentLogCallPrefix + call.ToolName,
+			"status":    agentLogStatus(call.Status == agent_entities.AgentToolCallStatusStarted, call.Status == agent_entities.AgentToolCallStatusFailed),
+			"error":     call.Error,
+			"data": map[string]any{
+				"tool_call_name":  call.ToolName,
+				"tool_call_input": call.Input,
+				"output":          call.Output,
+			},
+			"metadata": map[string]any{},
+		})
+	}
+	return chunk
+}
+
+// agentLogStatus is the status of logs of the legacy stream
+func agentLogStatus(started bool, failed bool) string {
+	switch {
+	case started:
+		return "start"
+	case failed:
+		return "error"
+	}
+	return "success"
+}
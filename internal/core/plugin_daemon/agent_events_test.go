@@ -0,0 +1,150 @@
+package plugin_daemon
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/agent_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+func agentChunk(t tool_entities.ToolResponseChunkType, message map[string]any) tool_entities.ToolResponseChunk {
+	return tool_entities.ToolResponseChunk{Type: t, Message: message}
+}
+
+func convertAll(chunks ...tool_entities.ToolResponseChunk) []agent_entities.AgentEvent {
+	converter := newAgentEventConverter()
+	events := []agent_entities.AgentEvent{}
+	for _, chunk := range chunks {
+		events = append(events, converter.convert(chunk)...)
+	}
+	return append(events, converter.end()...)
+}
+
+func TestAgentEventsOrdering(t *testing.T) {
+	events := convertAll(
+		// a thought out of steps opens a step
+		agentChunk(agent_entities.AgentStrategyResponseChunkTypeThought, map[string]any{"text": "search first"}),
+		agentChunk(agent_entities.AgentStrategyResponseChunkTypeToolCall, map[string]any{
+			"id": "call-1", "tool_name": "search", "status": "started", "input": map[string]any{"q": "go"},
+		}),
+		// the next step finishes the previous one along with the call not finished
+		agentChunk(agent_entities.AgentStrategyResponseChunkTypeStep, map[string]any{"id": "round-2", "status": "started"}),
+		agentChunk(tool_entities.ToolResponseChunkTypeText, map[string]any{"text": "done"}),
+	)
+
+	expected := []struct {
+		t      agent_entities.AgentEventType
+		status string
+	}{
+		{agent_entities.AgentEventTypeStep, "started"},
+		{agent_entities.AgentEventTypeThought, ""},
+		{agent_entities.AgentEventTypeToolCall, "started"},
+		{agent_entities.AgentEventTypeToolCall, "failed"},
+		{agent_entities.AgentEventTypeStep, "finished"},
+		{agent_entities.AgentEventTypeStep, "started"},
+		{agent_entities.AgentEventTypeOutput, ""},
+		{agent_entities.AgentEventTypeStep, "finished"},
+		{agent_entities.AgentEventTypeFinish, ""},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), events)
+	}
+	for i, event := range events {
+		if event.Seq != i || event.Type != expected[i].t {
+			t.Fatalf("unexpected event %d: %+v", i, event)
+		}
+		status := ""
+		if event.Step != nil {
+			status = string(event.Step.Status)
+		}
+		if event.ToolCall != nil {
+			status = string(event.ToolCall.Status)
+		}
+		if status != expected[i].status {
+			t.Errorf("expected event %d to be %s, got %s", i, expected[i].status, status)
+		}
+	}
+
+	if events[0].Step.ID != "step-1" || events[1].Thought.StepID != "step-1" || events[2].ToolCall.StepID != "step-1" {
+		t.Errorf("expected the implicit step to hold the thought and the call, got %+v", events[:3])
+	}
+	if events[5].Step.ID != "round-2" || events[5].Step.Index != 2 {
+		t.Errorf("unexpected step %+v", events[5].Step)
+	}
+	if finish := events[8].Finish; finish.Steps != 2 || finish.ToolCalls != 1 {
+		t.Errorf("unexpected finish %+v", finish)
+	}
+}
+
+func TestAgentEventsFromSDKLogs(t *testing.T) {
+	events := convertAll(
+		agentChunk(tool_entities.ToolResponseChunkTypeLog, map[string]any{"id": "r1", "label": "ROUND 1", "status": "start"}),
+		agentChunk(tool_entities.ToolResponseChunkTypeLog, map[string]any{
+			"id": "th", "parent_id": "r1", "label": "gpt-4o Thought", "status": "start",
+		}),
+		agentChunk(tool_entities.ToolResponseChunkTypeLog, map[string]any{
+			"id": "th", "parent_id": "r1", "label": "gpt-4o Thought", "status": "success",
+			"data": map[string]any{"output": "call the weather tool"},
+		}),
+		agentChunk(tool_entities.ToolResponseChunkTypeLog, map[string]any{
+			"id": "c1", "parent_id": "r1", "label": "CALL weather", "status": "success",
+			"data": map[string]any{"tool_call_name": "weather", "tool_call_args": map[string]any{"city": "Paris"}, "output": "sunny"},
+		}),
+		agentChunk(tool_entities.ToolResponseChunkTypeLog, map[string]any{"id": "r1", "label": "ROUND 1", "status": "success"}),
+		agentChunk(tool_entities.ToolResponseChunkTypeLog, map[string]any{"id": "other", "label": "Retrieval", "status": "success"}),
+	)
+
+	types := []agent_entities.AgentEventType{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	expected := []agent_entities.AgentEventType{
+		agent_entities.AgentEventTypeStep,
+		agent_entities.AgentEventTypeThought,
+		agent_entities.AgentEventTypeToolCall,
+		agent_entities.AgentEventTypeStep,
+		agent_entities.AgentEventTypeOutput,
+		agent_entities.AgentEventTypeFinish,
+	}
+	if len(types) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, types)
+		}
+	}
+
+	if events[1].Thought.Text != "call the weather tool" {
+		t.Errorf("unexpected thought %+v", events[1].Thought)
+	}
+	call := events[2].ToolCall
+	if call.ToolName != "weather" || call.Input["city"] != "Paris" || call.Output != "sunny" || call.Status != "succeeded" {
+		t.Errorf("unexpected tool call %+v", call)
+	}
+	if events[3].Step.ID != "r1" || events[3].Step.Status != agent_entities.AgentStepStatusFinished {
+		t.Errorf("unexpected step %+v", events[3].Step)
+	}
+}
+
+func TestAgentTraceAsLog(t *testing.T) {
+	chunk := agentTraceAsLog(agent_entities.AgentStrategyResponseChunk{
+		ToolResponseChunk: agentChunk(agent_entities.AgentStrategyResponseChunkTypeToolCall, map[string]any{
+			"id": "c1", "step_id": "r1", "tool_name": "weather", "status": "failed", "error": "timeout",
+		}),
+	})
+	if chunk.Type != tool_entities.ToolResponseChunkTypeLog {
+		t.Fatalf("expected a log, got %+v", chunk)
+	}
+	if chunk.Message["label"] != "CALL weather" || chunk.Message["parent_id"] != "r1" ||
+		chunk.Message["status"] != "error" || chunk.Message["error"] != "timeout" {
+		t.Errorf("unexpected log %+v", chunk.Message)
+	}
+
+	text := agent_entities.AgentStrategyResponseChunk{
+		ToolResponseChunk: agentChunk(tool_entities.ToolResponseChunkTypeText, map[string]any{"text": "hi"}),
+	}
+	if converted := agentTraceAsLog(text); converted.Type != tool_entities.ToolResponseChunkTypeText {
+		t.Errorf("expected other chunks to be kept, got %+v", converted)
+	}
+}
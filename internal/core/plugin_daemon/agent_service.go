@@ -15,9 +15,21 @@ import (
 	"github.com/xeipuuv/gojsonschema"
 )
 
+// InvokeAgentStrategy invokes the agent strategy, steps, thoughts and tool calls reported by it are converted
+// into logs which callers of the legacy stream render
 func InvokeAgentStrategy(
 	session *session_manager.Session,
 	r *requests.RequestInvokeAgentStrategy,
+) (*stream.Stream[agent_entities.AgentStrategyResponseChunk], error) {
+	return invokeAgentStrategy(session, r, agentTraceAsLog)
+}
+
+// invokeAgentStrategy relays chunks of the strategy with blobs reassembled, convert rewrites other chunks
+// if it's not nil
+func invokeAgentStrategy(
+	session *session_manager.Session,
+	r *requests.RequestInvokeAgentStrategy,
+	convert func(agent_entities.AgentStrategyResponseChunk) agent_entities.AgentStrategyResponseChunk,
 ) (*stream.Stream[agent_entities.AgentStrategyResponseChunk], error) {
 	runtime := session.Runtime()
 	if runtime == nil {
@@ -54,9 +66,12 @@ func InvokeAgentStrategy(
 		"function":                "InvokeAgentStrategy",
 		"agent_strategy_name":     r.AgentStrategy,
 		"agent_strategy_provider": r.AgentStrategyProvider,
+		"session_id":              session.ID,
+		"plugin_id":               session.PluginUniqueIdentifier.PluginID(),
 	}, func() {
 		files := make(map[string]*bytes.Buffer)
 		defer newResponse.Close()
+		defer routine.ReportPanic(newResponse.WriteError)
 
 		for response.Next() {
 			item, err := response.Read()
@@ -125,6 +140,9 @@ func InvokeAgentStrategy(
 					}
 				}
 			} else {
+				if convert != nil {
+					item = convert(item)
+				}
 				newResponse.Write(item)
 			}
 		}
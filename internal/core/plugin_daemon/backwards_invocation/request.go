@@ -5,6 +5,9 @@ import (
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type BackwardsInvocationType = dify_invocation.InvokeType
@@ -33,6 +36,9 @@ type BackwardsInvocation struct {
 
 	// backwardsInvocation is the backwards invocation that is used to invoke dify
 	backwardsInvocation dify_invocation.BackwardsInvocation
+
+	// span traces the invocation once it's dispatched, nil before
+	span trace.Span
 }
 
 func NewBackwardsInvocation(
@@ -56,7 +62,19 @@ func (bi *BackwardsInvocation) GetID() string {
 	return bi.id
 }
 
+// logger returns the logger of the backwards invocation component writing fields of the session
+func (bi *BackwardsInvocation) logger() *log.Logger {
+	if bi.session == nil {
+		return log.Component(log.COMPONENT_BACKWARDS_INVOCATION)
+	}
+	return bi.session.Logger().With(log.FIELD_COMPONENT, log.COMPONENT_BACKWARDS_INVOCATION)
+}
+
 func (bi *BackwardsInvocation) WriteError(err error) {
+	if bi.span != nil {
+		tracing.Fail(bi.span, err)
+	}
+	bi.logger().Debug("backwards invocation %s of type %s failed: %s", bi.id, bi.typ, err.Error())
 	bi.writer.Write(
 		session_manager.PLUGIN_IN_STREAM_EVENT_RESPONSE,
 		NewErrorEvent(bi.id, err.Error()),
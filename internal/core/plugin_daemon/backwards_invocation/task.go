@@ -4,15 +4,19 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/persistence"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // returns error only if payload is not correct
@@ -58,11 +62,32 @@ func InvokeDify(
 
 	// dispatch invocation task
 	routine.Submit(map[string]string{
-		"module":   "plugin_daemon",
-		"function": "InvokeDify",
+		"module":     "plugin_daemon",
+		"function":   "InvokeDify",
+		"session_id": session.ID,
+		"plugin_id":  session.PluginUniqueIdentifier.PluginID(),
 	}, func() {
-		dispatchDifyInvocationTask(requestHandle)
+		startedAt := time.Now()
+		_, span := tracing.Start(session.Context(), "backwards_invocation."+string(requestHandle.Type()),
+			trace.SpanKindClient,
+			attribute.String("backwards_invocation.type", string(requestHandle.Type())),
+			attribute.String("session.id", session.ID),
+		)
+		requestHandle.span = span
+		defer span.End()
+
+		requestHandle.logger().Debug(
+			"dispatching backwards invocation %s of type %s", requestHandle.GetID(), requestHandle.Type(),
+		)
+		// the plugin waits for the end of the response, it ends with the error once dispatching panics
 		defer requestHandle.EndResponse()
+		defer routine.ReportPanic(requestHandle.WriteError)
+
+		dispatchDifyInvocationTask(requestHandle)
+		metrics.BackwardsInvocationDuration.WithLabelValues(string(requestHandle.Type())).Observe(
+			time.Since(startedAt).Seconds(),
+		)
+		session.Timing().AddBackwardsInvocation(time.Since(startedAt))
 	})
 
 	return nil
@@ -480,9 +505,32 @@ func executeDifyInvocationStorageTask(
 	pluginId := handle.session.PluginUniqueIdentifier
 
 	if request.Opt == dify_invocation.STORAGE_OPT_GET {
+		if request.Offset != nil || request.Length != nil {
+			offset, length := int64(0), int64(-1)
+			if request.Offset != nil {
+				offset = *request.Offset
+			}
+			if request.Length != nil {
+				length = *request.Length
+			}
+
+			data, size, err := persistence.LoadRange(tenantId, pluginId.PluginID(), request.Key, offset, length)
+			if err != nil {
+				handle.logger().Error("load data failed: %s", err.Error())
+				handle.WriteError(errors.New("load data failed, please check if the key is correct or you have not set it"))
+				return
+			}
+
+			handle.WriteResponse("struct", map[string]any{
+				"data": hex.EncodeToString(data),
+				"size": size,
+			})
+			return
+		}
+
 		data, err := persistence.Load(tenantId, pluginId.PluginID(), request.Key)
 		if err != nil {
-			log.Error("load data failed: %s", err.Error())
+			handle.logger().Error("load data failed: %s", err.Error())
 			handle.WriteError(errors.New("load data failed, please check if the key is correct or you have not set it"))
 			return
 		}
@@ -490,7 +538,7 @@ func executeDifyInvocationStorageTask(
 		handle.WriteResponse("struct", map[string]any{
 			"data": hex.EncodeToString(data),
 		})
-	} else if request.Opt == dify_invocation.STORAGE_OPT_SET {
+	} else if request.Opt == dify_invocation.STORAGE_OPT_SET || request.Opt == dify_invocation.STORAGE_OPT_APPEND {
 		data, err := hex.DecodeString(request.Value)
 		if err != nil {
 			handle.WriteError(fmt.Errorf("decode data failed: %s", err.Error()))
@@ -522,7 +570,12 @@ func executeDifyInvocationStorageTask(
 			maxStorageSize = int64(storage.Size)
 		}
 
-		if err := persistence.Save(tenantId, pluginId.PluginID(), maxStorageSize, request.Key, data); err != nil {
+		if request.Opt == dify_invocation.STORAGE_OPT_APPEND {
+			if err := persistence.Append(tenantId, pluginId.PluginID(), maxStorageSize, request.Key, data); err != nil {
+				handle.WriteError(fmt.Errorf("append data failed: %s", err.Error()))
+				return
+			}
+		} else if err := persistence.Save(tenantId, pluginId.PluginID(), maxStorageSize, request.Key, data); err != nil {
 			handle.WriteError(fmt.Errorf("save data failed: %s", err.Error()))
 			return
 		}
@@ -87,7 +87,7 @@ func (h *AWSTransactionHandler) Handle(
 				ID: session_id,
 			})
 			if session == nil {
-				log.Error("session not found: %s", session_id)
+				log.With(log.FIELD_SESSION_ID, session_id).Error("session not found")
 				ctx.Writer.WriteHeader(http.StatusInternalServerError)
 				ctx.Writer.Write([]byte("session not found"))
 				writer.Close()
@@ -116,7 +116,7 @@ func (h *AWSTransactionHandler) Handle(
 		func(err string) {
 			log.Warn("invoke dify failed, received errors: %s", err)
 		},
-		func(message string) {}, //log
+		func(sessionId string, event plugin_entities.PluginLogEvent) {}, //log
 	)
 
 	select {
@@ -61,11 +61,14 @@ func InvokeEndpoint(
 
 			response.Write(dehexed)
 			routine.Submit(map[string]string{
-				"module":   "plugin_daemon",
-				"function": "InvokeEndpoint",
-				"type":     "body_write",
+				"module":     "plugin_daemon",
+				"function":   "InvokeEndpoint",
+				"type":       "body_write",
+				"session_id": session.ID,
+				"plugin_id":  session.PluginUniqueIdentifier.PluginID(),
 			}, func() {
 				defer response.Close()
+				defer routine.ReportPanic(response.WriteError)
 				for resp.Next() {
 					chunk, err := resp.Read()
 					if err != nil {
@@ -3,13 +3,20 @@ package plugin_daemon
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
+	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func GenericInvokePlugin[Req any, Rsp any](
@@ -22,27 +29,53 @@ func GenericInvokePlugin[Req any, Rsp any](
 		return nil, errors.New("plugin runtime not found")
 	}
 
+	payload, err := getInvokePluginMap(session, request)
+	if err != nil {
+		return nil, err
+	}
+
 	response := stream.NewStream[Rsp](response_buffer_size)
 
+	// the span lasts until the response is closed, either by the plugin or by the caller
+	_, span := tracing.Start(session.Context(), "plugin_daemon.invoke", trace.SpanKindInternal,
+		attribute.String("plugin.unique_identifier", session.PluginUniqueIdentifier.String()),
+		attribute.String("plugin.runtime_type", string(runtime.Type())),
+		attribute.String("plugin.action", string(session.Action)),
+		attribute.String("session.id", session.ID),
+	)
+
+	// the last error is kept in the slow log
+	failure := atomic.Value{}
+	// tokens reported by responses of models
+	tokens := atomic.Int64{}
+	fail := func(err error) {
+		failure.Store(err.Error())
+		response.WriteError(err)
+	}
+
 	listener := runtime.Listen(session.ID)
 	listener.Listen(func(chunk plugin_entities.SessionMessage) {
 		switch chunk.Type {
 		case plugin_entities.SESSION_MESSAGE_TYPE_STREAM:
 			chunk, err := parser.UnmarshalJsonBytes[Rsp](chunk.Data)
 			if err != nil {
-				response.WriteError(errors.New(parser.MarshalJson(map[string]string{
+				fail(errors.New(parser.MarshalJson(map[string]string{
 					"error_type": "unmarshal_error",
 					"message":    fmt.Sprintf("unmarshal json failed: %s", err.Error()),
 				})))
 				response.Close()
 				return
 			} else {
+				session.Timing().ChunkReceived()
+				if usage, ok := any(chunk).(interface{ Tokens() int64 }); ok {
+					tokens.Add(usage.Tokens())
+				}
 				response.Write(chunk)
 			}
 		case plugin_entities.SESSION_MESSAGE_TYPE_INVOKE:
 			// check if the request contains a aws_event_id
 			if runtime.Type() == plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS {
-				response.WriteError(errors.New(parser.MarshalJson(map[string]string{
+				fail(errors.New(parser.MarshalJson(map[string]string{
 					"error_type": "aws_event_not_supported",
 					"message":    "aws event is not supported by full duplex",
 				})))
@@ -56,7 +89,7 @@ func GenericInvokePlugin[Req any, Rsp any](
 				transaction.NewFullDuplexEventWriter(session),
 				chunk.Data,
 			); err != nil {
-				response.WriteError(errors.New(parser.MarshalJson(map[string]string{
+				fail(errors.New(parser.MarshalJson(map[string]string{
 					"error_type": "invoke_dify_error",
 					"message":    fmt.Sprintf("invoke dify failed: %s", err.Error()),
 				})))
@@ -70,10 +103,13 @@ func GenericInvokePlugin[Req any, Rsp any](
 			if err != nil {
 				break
 			}
-			response.WriteError(errors.New(e.Error()))
+			// plugins may echo credentials in errors, they're masked before being returned
+			message := errors.New(redact.Session(session.ID, e.Error()))
+			fail(message)
+			tracing.Fail(span, message)
 			response.Close()
 		default:
-			response.WriteError(errors.New(parser.MarshalJson(map[string]string{
+			fail(errors.New(parser.MarshalJson(map[string]string{
 				"error_type": "unknown_stream_message_type",
 				"message":    "unknown stream message type: " + string(chunk.Type),
 			})))
@@ -81,19 +117,44 @@ func GenericInvokePlugin[Req any, Rsp any](
 		}
 	})
 
+	// operators may kill the session of an invocation which is stuck
+	session.OnKill(func() {
+		fail(errors.New(parser.MarshalJson(map[string]string{
+			"error_type": "session_killed",
+			"message":    "the session is killed",
+		})))
+		response.Close()
+	})
+
+	redact.RegisterSession(session.ID, redact.SecretValues(payload))
+
 	// close the listener if stream outside is closed due to close of connection
 	response.OnClose(func() {
 		listener.Close()
+		span.End()
+		redact.UnregisterSession(session.ID)
+
+		message, _ := failure.Load().(string)
+		breakdown := session.Timing().Breakdown(time.Now())
+		events.Publish(events.InvocationCompleted{
+			SessionID:              session.ID,
+			TenantID:               session.TenantID,
+			PluginUniqueIdentifier: session.PluginUniqueIdentifier,
+			Action:                 session.Action,
+			Latency:                time.Duration(breakdown.Total-breakdown.Streaming) * time.Millisecond,
+			Failed:                 message != "",
+			Error:                  message,
+			Tokens:                 tokens.Load(),
+		})
+		recordSlowInvocation(session, payload, breakdown, message)
 	})
 
 	session.Write(
 		session_manager.PLUGIN_IN_STREAM_EVENT_REQUEST,
 		session.Action,
-		getInvokePluginMap(
-			session,
-			request,
-		),
+		payload,
 	)
+	session.Timing().RequestWritten()
 
 	return response, nil
 }
@@ -101,14 +162,18 @@ func GenericInvokePlugin[Req any, Rsp any](
 func getInvokePluginMap(
 	session *session_manager.Session,
 	request any,
-) map[string]any {
+) (map[string]any, error) {
 	req := getBasicPluginAccessMap(
 		session.UserID,
 		session.InvokeFrom,
 		session.Action,
 	)
-	for k, v := range parser.StructToMap(request) {
+	fields, err := parser.TryStructToMap(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the request: %w", err)
+	}
+	for k, v := range fields {
 		req[k] = v
 	}
-	return req
+	return req, nil
 }
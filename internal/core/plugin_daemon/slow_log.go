@@ -0,0 +1,36 @@
+package plugin_daemon
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/slow_log"
+)
+
+// recordSlowInvocation records the invocation served by the session if it's slower than the threshold,
+// the payload is redacted before being kept
+func recordSlowInvocation(
+	session *session_manager.Session,
+	payload map[string]any,
+	breakdown slow_log.Breakdown,
+	failure string,
+) {
+	if !slow_log.Slow(time.Duration(breakdown.Total) * time.Millisecond) {
+		return
+	}
+
+	entry := slow_log.Entry{
+		Timestamp:              time.Now().Unix(),
+		SessionID:              session.ID,
+		RequestID:              session.RequestID,
+		TenantID:               session.TenantID,
+		PluginUniqueIdentifier: session.PluginUniqueIdentifier.String(),
+		Action:                 string(session.Action),
+		Timing:                 breakdown,
+		Error:                  failure,
+	}
+	if slow_log.SamplePayload() {
+		entry.Payload = slow_log.Redact(payload)
+	}
+	slow_log.Record(entry)
+}
@@ -0,0 +1,73 @@
+package plugin_daemon
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	TOOL_OUTPUT_SCHEMA_ENFORCE = "enforce"
+	TOOL_OUTPUT_SCHEMA_WARN    = "warn"
+	TOOL_OUTPUT_SCHEMA_OFF     = "off"
+)
+
+// replaced once settings are reloaded, TOOL_OUTPUT_SCHEMA_ENFORCE until it's initialized
+var toolOutputSchemaMode atomic.Pointer[string]
+
+// InitToolOutputSchema sets how violations of output schemas declared by tools are handled
+func InitToolOutputSchema(config *app.Config) {
+	if config.ToolOutputSchemaMode != "" {
+		mode := config.ToolOutputSchemaMode
+		toolOutputSchemaMode.Store(&mode)
+	}
+}
+
+func currentToolOutputSchemaMode() string {
+	if mode := toolOutputSchemaMode.Load(); mode != nil {
+		return *mode
+	}
+	return TOOL_OUTPUT_SCHEMA_ENFORCE
+}
+
+// toolOutputSchemaViolations converts errors of the validation into violations
+func toolOutputSchemaViolations(result *gojsonschema.Result) []tool_entities.ToolOutputSchemaViolation {
+	violations := make([]tool_entities.ToolOutputSchemaViolation, 0, len(result.Errors()))
+	for _, err := range result.Errors() {
+		violations = append(violations, tool_entities.ToolOutputSchemaViolation{
+			Field:       err.Field(),
+			Rule:        err.Type(),
+			Description: err.Description(),
+		})
+	}
+	return violations
+}
+
+// reportToolOutputSchemaViolations counts the violations, returns the error failing the invocation if
+// they're enforced, nil if they're only warned
+func reportToolOutputSchemaViolations(
+	plugin_id string,
+	tool string,
+	violations []tool_entities.ToolOutputSchemaViolation,
+) error {
+	mode := currentToolOutputSchemaMode()
+	metrics.ToolOutputSchemaViolations.WithLabelValues(plugin_id, tool, mode).Inc()
+
+	err := exception.ToolOutputSchemaError(tool, violations)
+	if mode == TOOL_OUTPUT_SCHEMA_ENFORCE {
+		return err
+	}
+
+	fields := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		fields = append(fields, violation.Field)
+	}
+	log.Warn("output of tool %s of plugin %s violates its output schema at %s", tool, plugin_id, strings.Join(fields, ", "))
+	return nil
+}
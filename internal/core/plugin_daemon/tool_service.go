@@ -14,6 +14,13 @@ import (
 	"github.com/xeipuuv/gojsonschema"
 )
 
+const (
+	// MAX_TOOL_FILE_SIZE limits blobs responded by tools
+	MAX_TOOL_FILE_SIZE = 15 * 1024 * 1024
+	// MAX_TOOL_FILE_CHUNK_SIZE limits each chunk of blobs responded in chunks
+	MAX_TOOL_FILE_CHUNK_SIZE = 8192
+)
+
 func InvokeTool(
 	session *session_manager.Session,
 	request *requests.RequestInvokeTool,
@@ -42,12 +49,13 @@ func InvokeTool(
 		return nil, errors.New("tool declaration not found")
 	}
 
-	var toolOutputSchema plugin_entities.ToolOutputSchema
-	for _, v := range toolDeclaration.Tools {
-		if v.Identity.Name == request.Tool {
-			toolOutputSchema = v.OutputSchema
-		}
-	}
+	// bind json schema validator
+	validate := bindToolValidator(
+		response,
+		session.PluginUniqueIdentifier.PluginID(),
+		request.Tool,
+		toolOutputSchema(toolDeclaration, request.Tool),
+	)
 
 	newResponse := stream.NewStream[tool_entities.ToolResponseChunk](128)
 	routine.Submit(map[string]string{
@@ -55,9 +63,12 @@ func InvokeTool(
 		"function":      "InvokeTool",
 		"tool_name":     request.Tool,
 		"tool_provider": request.Provider,
+		"session_id":    session.ID,
+		"plugin_id":     session.PluginUniqueIdentifier.PluginID(),
 	}, func() {
 		files := make(map[string]*bytes.Buffer)
 		defer newResponse.Close()
+		defer routine.ReportPanic(newResponse.WriteError)
 
 		for response.Next() {
 			item, err := response.Read()
@@ -103,7 +114,7 @@ func InvokeTool(
 						Meta: item.Meta,
 					})
 				} else {
-					if files[id].Len() > 15*1024*1024 {
+					if files[id].Len() > MAX_TOOL_FILE_SIZE {
 						// delete the file if it is too large
 						delete(files, id)
 						newResponse.WriteError(errors.New("file is too large"))
@@ -115,7 +126,7 @@ func InvokeTool(
 							newResponse.WriteError(err)
 							return
 						}
-						if len(decoded) > 8192 {
+						if len(decoded) > MAX_TOOL_FILE_CHUNK_SIZE {
 							// single chunk is too large, raises error
 							newResponse.WriteError(errors.New("single file chunk is too large"))
 							return
@@ -127,18 +138,40 @@ func InvokeTool(
 				newResponse.Write(item)
 			}
 		}
-	})
 
-	// bind json schema validator
-	bindToolValidator(response, toolOutputSchema)
+		if err := validate(); err != nil {
+			newResponse.WriteError(err)
+		}
+	})
 
 	return newResponse, nil
 }
 
+func toolOutputSchema(
+	toolDeclaration *plugin_entities.ToolProviderDeclaration, tool string,
+) plugin_entities.ToolOutputSchema {
+	var schema plugin_entities.ToolOutputSchema
+	for _, v := range toolDeclaration.Tools {
+		if v.Identity.Name == tool {
+			schema = v.OutputSchema
+		}
+	}
+	return schema
+}
+
+// bindToolValidator collects variables emitted by the tool as the response is read, the returned function
+// validates them against the output schema declared by the tool once the whole response is read, tools
+// declaring no output schema are not validated
 func bindToolValidator(
 	response *stream.Stream[tool_entities.ToolResponseChunk],
+	plugin_id string,
+	tool string,
 	toolOutputSchema plugin_entities.ToolOutputSchema,
-) {
+) func() error {
+	if len(toolOutputSchema) == 0 || currentToolOutputSchemaMode() == TOOL_OUTPUT_SCHEMA_OFF {
+		return func() error { return nil }
+	}
+
 	// check if the tool_output_schema is valid
 	variables := make(map[string]any)
 
@@ -180,26 +213,23 @@ func bindToolValidator(
 		return nil
 	})
 
-	response.BeforeClose(func() {
-		// validate the variables
+	return func() error {
 		schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(toolOutputSchema))
 		if err != nil {
-			response.WriteError(err)
-			return
+			return err
 		}
 
 		// validate the variables
 		result, err := schema.Validate(gojsonschema.NewGoLoader(variables))
 		if err != nil {
-			response.WriteError(err)
-			return
+			return err
 		}
 
 		if !result.Valid() {
-			response.WriteError(errors.New("tool output schema is not valid"))
-			return
+			return reportToolOutputSchemaViolations(plugin_id, tool, toolOutputSchemaViolations(result))
 		}
-	})
+		return nil
+	}
 }
 
 func ValidateToolCredentials(
@@ -1,8 +1,12 @@
 package plugin_daemon
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
 )
@@ -10,7 +14,7 @@ import (
 func TestToolInvokeJSONSchemaValidator(t *testing.T) {
 	response := stream.NewStream[tool_entities.ToolResponseChunk](128)
 
-	bindToolValidator(response, map[string]any{
+	bindToolValidator(response, "langgenius/test", "test", map[string]any{
 		"output_schema": map[string]any{
 			"type": "object",
 			"properties": map[string]any{
@@ -44,7 +48,7 @@ func TestToolInvokeJSONSchemaValidator(t *testing.T) {
 func TestToolInvokeJSONSchemaValidatorWithInvalidSchema(t *testing.T) {
 	response := stream.NewStream[tool_entities.ToolResponseChunk](128)
 
-	bindToolValidator(response, map[string]any{
+	bindToolValidator(response, "langgenius/test", "test", map[string]any{
 		"output_schema": map[string]any{
 			"type": "object",
 			"properties": map[string]any{
@@ -76,3 +80,73 @@ func TestToolInvokeJSONSchemaValidatorWithInvalidSchema(t *testing.T) {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+func writeVariable(response *stream.Stream[tool_entities.ToolResponseChunk], name string, value any) {
+	response.Write(tool_entities.ToolResponseChunk{
+		Type: tool_entities.ToolResponseChunkTypeVariable,
+		Message: map[string]any{
+			"variable_name":  name,
+			"variable_value": value,
+			"stream":         false,
+		},
+	})
+}
+
+func readAll(response *stream.Stream[tool_entities.ToolResponseChunk], validate func() error) error {
+	for response.Next() {
+		if _, err := response.Read(); err != nil {
+			return err
+		}
+	}
+	return validate()
+}
+
+var countSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"count": map[string]any{"type": "integer"},
+	},
+	"required": []any{"count"},
+}
+
+func TestToolOutputSchemaViolations(t *testing.T) {
+	response := stream.NewStream[tool_entities.ToolResponseChunk](128)
+	validate := bindToolValidator(response, "langgenius/test", "counter", countSchema)
+	writeVariable(response, "count", "many")
+	response.Close()
+
+	err := readAll(response, validate)
+	if err == nil {
+		t.Fatal("expected the output to be rejected")
+	}
+
+	body := parser.MarshalJson(exception.InvokePluginError(err).ToResponse())
+	if !strings.Contains(body, exception.PluginToolOutputSchemaError) || !strings.Contains(body, `\"field\":\"count\"`) {
+		t.Errorf("expected violations to be described, got %s", body)
+	}
+}
+
+func TestToolOutputSchemaWarn(t *testing.T) {
+	InitToolOutputSchema(&app.Config{ToolOutputSchemaMode: TOOL_OUTPUT_SCHEMA_WARN})
+	defer toolOutputSchemaMode.Store(nil)
+
+	response := stream.NewStream[tool_entities.ToolResponseChunk](128)
+	validate := bindToolValidator(response, "langgenius/test", "counter", countSchema)
+	writeVariable(response, "total", 1)
+	response.Close()
+
+	if err := readAll(response, validate); err != nil {
+		t.Fatalf("expected violations to be warned only, got %v", err)
+	}
+}
+
+func TestToolOutputSchemaNotDeclared(t *testing.T) {
+	response := stream.NewStream[tool_entities.ToolResponseChunk](128)
+	validate := bindToolValidator(response, "langgenius/test", "counter", nil)
+	writeVariable(response, "count", "many")
+	response.Close()
+
+	if err := readAll(response, validate); err != nil {
+		t.Fatalf("expected tools declaring no output schema to pass, got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package plugin_daemon
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+// InvokeToolTyped invokes the tool like InvokeTool but responds typed chunks, parts of blobs are passed
+// through as they're received instead of being buffered into the whole blob, a usage chunk ends the stream
+func InvokeToolTyped(
+	session *session_manager.Session,
+	request *requests.RequestInvokeTool,
+) (
+	*stream.Stream[tool_entities.ToolStreamChunk], error,
+) {
+	runtime := session.Runtime()
+	if runtime == nil {
+		return nil, errors.New("plugin not found")
+	}
+
+	toolDeclaration := runtime.Configuration().Tool
+	if toolDeclaration == nil {
+		return nil, errors.New("tool declaration not found")
+	}
+
+	response, err := GenericInvokePlugin[
+		requests.RequestInvokeTool, tool_entities.ToolResponseChunk,
+	](
+		session,
+		request,
+		128,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// bind json schema validator
+	validate := bindToolValidator(
+		response,
+		session.PluginUniqueIdentifier.PluginID(),
+		request.Tool,
+		toolOutputSchema(toolDeclaration, request.Tool),
+	)
+
+	typed := stream.NewStream[tool_entities.ToolStreamChunk](128)
+	routine.Submit(map[string]string{
+		"module":        "plugin_daemon",
+		"function":      "InvokeToolTyped",
+		"tool_name":     request.Tool,
+		"tool_provider": request.Provider,
+		"session_id":    session.ID,
+		"plugin_id":     session.PluginUniqueIdentifier.PluginID(),
+	}, func() {
+		defer typed.Close()
+		defer routine.ReportPanic(typed.WriteError)
+
+		converter := newToolStreamConverter()
+		for response.Next() {
+			item, err := response.Read()
+			if err != nil {
+				typed.WriteError(err)
+				return
+			}
+
+			chunk, err := converter.convert(item)
+			if err != nil {
+				typed.WriteError(err)
+				return
+			}
+			if chunk != nil {
+				typed.Write(*chunk)
+			}
+		}
+
+		if err := validate(); err != nil {
+			typed.WriteError(err)
+			return
+		}
+		typed.Write(converter.end())
+	})
+
+	return typed, nil
+}
+
+// toolStreamConverter converts chunks responded by plugins into typed chunks in order
+type toolStreamConverter struct {
+	seq     int
+	started time.Time
+	usage   tool_entities.ToolStreamUsage
+	// received is bytes of blobs received by their ids
+	received map[string]int
+}
+
+func newToolStreamConverter() *toolStreamConverter {
+	return &toolStreamConverter{
+		started:  time.Now(),
+		received: map[string]int{},
+	}
+}
+
+func (c *toolStreamConverter) next(chunk tool_entities.ToolStreamChunk) *tool_entities.ToolStreamChunk {
+	chunk.Seq = c.seq
+	c.seq++
+	c.usage.Chunks++
+	return &chunk
+}
+
+// convert returns the typed chunk of the chunk, or nil if it's not meaningful to callers
+func (c *toolStreamConverter) convert(item tool_entities.ToolResponseChunk) (*tool_entities.ToolStreamChunk, error) {
+	text, _ := item.Message["text"].(string)
+
+	switch item.Type {
+	case tool_entities.ToolResponseChunkTypeText:
+		return c.next(tool_entities.ToolStreamChunk{
+			Type: tool_entities.ToolStreamChunkTypeText,
+			Text: &tool_entities.ToolStreamText{Text: text},
+			Meta: item.Meta,
+		}), nil
+	case tool_entities.ToolResponseChunkTypeJson:
+		return c.next(tool_entities.ToolStreamChunk{
+			Type: tool_entities.ToolStreamChunkTypeJson,
+			Json: &tool_entities.ToolStreamJson{Value: item.Message["json_object"]},
+			Meta: item.Meta,
+		}), nil
+	case tool_entities.ToolResponseChunkTypeVariable:
+		name, _ := item.Message["variable_name"].(string)
+		if streamed, _ := item.Message["stream"].(bool); streamed {
+			value, _ := item.Message["variable_value"].(string)
+			return c.next(tool_entities.ToolStreamChunk{
+				Type: tool_entities.ToolStreamChunkTypeText,
+				Text: &tool_entities.ToolStreamText{Text: value, Variable: name},
+				Meta: item.Meta,
+			}), nil
+		}
+		return c.next(tool_entities.ToolStreamChunk{
+			Type: tool_entities.ToolStreamChunkTypeJson,
+			Json: &tool_entities.ToolStreamJson{Value: item.Message["variable_value"], Variable: name},
+			Meta: item.Meta,
+		}), nil
+	case tool_entities.ToolResponseChunkTypeLink:
+		return c.reference(item, tool_entities.ToolStreamFileKindLink, text), nil
+	case tool_entities.ToolResponseChunkTypeImage, tool_entities.ToolResponseChunkTypeImageLink:
+		return c.reference(item, tool_entities.ToolStreamFileKindImage, text), nil
+	case tool_entities.ToolResponseChunkTypeFile:
+		return c.reference(item, tool_entities.ToolStreamFileKindReference, ""), nil
+	case tool_entities.ToolResponseChunkTypeBlob:
+		return c.blob(item)
+	case tool_entities.ToolResponseChunkTypeBlobChunk:
+		return c.blobChunk(item)
+	case tool_entities.ToolResponseChunkTypeLog:
+		return c.log(item), nil
+	}
+	return nil, nil
+}
+
+func (c *toolStreamConverter) reference(
+	item tool_entities.ToolResponseChunk, kind tool_entities.ToolStreamFileKind, url string,
+) *tool_entities.ToolStreamChunk {
+	file := c.file(item, fmt.Sprintf("file-%d", c.seq), kind)
+	file.URL = url
+	file.End = true
+	return c.next(tool_entities.ToolStreamChunk{
+		Type: tool_entities.ToolStreamChunkTypeFile,
+		File: file,
+		Meta: item.Meta,
+	})
+}
+
+func (c *toolStreamConverter) file(
+	item tool_entities.ToolResponseChunk, id string, kind tool_entities.ToolStreamFileKind,
+) *tool_entities.ToolStreamFile {
+	mimeType, _ := item.Meta["mime_type"].(string)
+	filename, _ := item.Meta["filename"].(string)
+	return &tool_entities.ToolStreamFile{ID: id, Kind: kind, MimeType: mimeType, Filename: filename}
+}
+
+func (c *toolStreamConverter) blob(item tool_entities.ToolResponseChunk) (*tool_entities.ToolStreamChunk, error) {
+	encoded, _ := item.Message["blob"].(string)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MAX_TOOL_FILE_SIZE {
+		return nil, errors.New("file is too large")
+	}
+	c.usage.FileBytes += len(data)
+
+	file := c.file(item, fmt.Sprintf("file-%d", c.seq), tool_entities.ToolStreamFileKindBlob)
+	file.Data = data
+	file.TotalLength = len(data)
+	file.End = true
+	return c.next(tool_entities.ToolStreamChunk{
+		Type: tool_entities.ToolStreamChunkTypeFile,
+		File: file,
+		Meta: item.Meta,
+	}), nil
+}
+
+func (c *toolStreamConverter) blobChunk(item tool_entities.ToolResponseChunk) (*tool_entities.ToolStreamChunk, error) {
+	id, ok := item.Message["id"].(string)
+	if !ok {
+		return nil, nil
+	}
+	totalLength, _ := item.Message["total_length"].(float64)
+	encoded, _ := item.Message["blob"].(string)
+	end, _ := item.Message["end"].(bool)
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MAX_TOOL_FILE_CHUNK_SIZE {
+		return nil, errors.New("single file chunk is too large")
+	}
+	offset := c.received[id]
+	if offset+len(data) > MAX_TOOL_FILE_SIZE {
+		return nil, errors.New("file is too large")
+	}
+	if end {
+		delete(c.received, id)
+	} else {
+		c.received[id] = offset + len(data)
+	}
+	c.usage.FileBytes += len(data)
+
+	file := c.file(item, id, tool_entities.ToolStreamFileKindBlob)
+	file.Offset = offset
+	file.Data = data
+	file.TotalLength = int(totalLength)
+	file.End = end
+	return c.next(tool_entities.ToolStreamChunk{
+		Type: tool_entities.ToolStreamChunkTypeFile,
+		File: file,
+		Meta: item.Meta,
+	}), nil
+}
+
+func (c *toolStreamConverter) log(item tool_entities.ToolResponseChunk) *tool_entities.ToolStreamChunk {
+	log := &tool_entities.ToolStreamLog{}
+	log.ID, _ = item.Message["id"].(string)
+	log.ParentID, _ = item.Message["parent_id"].(string)
+	log.Label, _ = item.Message["label"].(string)
+	log.Status, _ = item.Message["status"].(string)
+	log.Error, _ = item.Message["error"].(string)
+	log.Data, _ = item.Message["data"].(map[string]any)
+	log.Metadata, _ = item.Message["metadata"].(map[string]any)
+
+	// usage is reported by logs of finished steps
+	if log.Status == "success" {
+		if tokens, ok := log.Metadata["total_tokens"].(float64); ok {
+			c.usage.TotalTokens += int(tokens)
+		}
+		if price, ok := log.Metadata["total_price"].(float64); ok {
+			c.usage.TotalPrice += price
+		}
+		if currency, ok := log.Metadata["currency"].(string); ok {
+			c.usage.Currency = currency
+		}
+	}
+
+	return c.next(tool_entities.ToolStreamChunk{
+		Type: tool_entities.ToolStreamChunkTypeLog,
+		Log:  log,
+		Meta: item.Meta,
+	})
+}
+
+// end returns the usage chunk ending the stream
+func (c *toolStreamConverter) end() tool_entities.ToolStreamChunk {
+	usage := c.usage
+	usage.ElapsedMs = time.Since(c.started).Milliseconds()
+	return tool_entities.ToolStreamChunk{
+		Type:  tool_entities.ToolStreamChunkTypeUsage,
+		Seq:   c.seq,
+		Usage: &usage,
+	}
+}
@@ -0,0 +1,93 @@
+package plugin_daemon
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+func TestToolStreamConverter(t *testing.T) {
+	converter := newToolStreamConverter()
+
+	convert := func(item tool_entities.ToolResponseChunk) *tool_entities.ToolStreamChunk {
+		chunk, err := converter.convert(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chunk
+	}
+
+	text := convert(tool_entities.ToolResponseChunk{
+		Type:    tool_entities.ToolResponseChunkTypeText,
+		Message: map[string]any{"text": "hello"},
+	})
+	if text.Type != tool_entities.ToolStreamChunkTypeText || text.Text.Text != "hello" || text.Seq != 0 {
+		t.Fatalf("unexpected text chunk %+v", text)
+	}
+
+	variable := convert(tool_entities.ToolResponseChunk{
+		Type:    tool_entities.ToolResponseChunkTypeVariable,
+		Message: map[string]any{"variable_name": "answer", "variable_value": "par", "stream": true},
+	})
+	if variable.Type != tool_entities.ToolStreamChunkTypeText || variable.Text.Variable != "answer" || variable.Seq != 1 {
+		t.Fatalf("streamed variables should be text of the variable, got %+v", variable)
+	}
+
+	image := convert(tool_entities.ToolResponseChunk{
+		Type:    tool_entities.ToolResponseChunkTypeImageLink,
+		Message: map[string]any{"text": "https://example.com/a.png"},
+	})
+	if image.File.Kind != tool_entities.ToolStreamFileKindImage || image.File.URL != "https://example.com/a.png" || !image.File.End {
+		t.Fatalf("unexpected image chunk %+v", image.File)
+	}
+
+	// parts of blobs are passed through as they arrive
+	parts := []string{"abc", "de"}
+	for i, part := range parts {
+		chunk := convert(tool_entities.ToolResponseChunk{
+			Type: tool_entities.ToolResponseChunkTypeBlobChunk,
+			Message: map[string]any{
+				"id":           "f",
+				"total_length": float64(5),
+				"blob":         base64.StdEncoding.EncodeToString([]byte(part)),
+				"end":          i == len(parts)-1,
+			},
+			Meta: map[string]any{"mime_type": "text/plain"},
+		})
+		if string(chunk.File.Data) != part || chunk.File.Offset != i*3 || chunk.File.End != (i == 1) ||
+			chunk.File.MimeType != "text/plain" || chunk.File.TotalLength != 5 {
+			t.Fatalf("unexpected part %d %+v", i, chunk.File)
+		}
+	}
+
+	convert(tool_entities.ToolResponseChunk{
+		Type: tool_entities.ToolResponseChunkTypeLog,
+		Message: map[string]any{
+			"id": "step", "label": "llm", "status": "success",
+			"metadata": map[string]any{"total_tokens": float64(12), "total_price": 0.5, "currency": "USD"},
+		},
+	})
+
+	usage := converter.end()
+	if usage.Type != tool_entities.ToolStreamChunkTypeUsage || usage.Seq != 6 || usage.Usage.Chunks != 6 ||
+		usage.Usage.FileBytes != 5 || usage.Usage.TotalTokens != 12 || usage.Usage.Currency != "USD" {
+		t.Fatalf("unexpected usage %+v", usage.Usage)
+	}
+}
+
+func TestToolStreamConverterLimits(t *testing.T) {
+	converter := newToolStreamConverter()
+	_, err := converter.convert(tool_entities.ToolResponseChunk{
+		Type: tool_entities.ToolResponseChunkTypeBlobChunk,
+		Message: map[string]any{
+			"id":   "f",
+			"blob": base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", MAX_TOOL_FILE_CHUNK_SIZE+1))),
+			"end":  false,
+		},
+	})
+	if err == nil {
+		t.Fatal("chunks larger than the limit should be refused")
+	}
+}
@@ -0,0 +1,69 @@
+package plugin_manager
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var (
+	ErrNodeAtCapacity = errors.New("node at capacity, no more plugins are launched")
+)
+
+// localPluginUsage returns the number of local plugins running on the node and the memory they declare
+func (p *PluginManager) localPluginUsage() (int, int64) {
+	plugins := 0
+	memory := int64(0)
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		if _, ok := value.(*local_runtime.LocalPluginRuntime); ok {
+			plugins++
+			memory += value.Configuration().Resource.Memory
+		}
+		return true
+	})
+	return plugins, memory
+}
+
+// admitLocalPlugin returns ErrNodeAtCapacity if launching the plugin exceeds the capacity of the node
+func (p *PluginManager) admitLocalPlugin(declaration *plugin_entities.PluginDeclaration) error {
+	plugins, memory := p.localPluginUsage()
+	if p.maxPlugins > 0 && plugins >= p.maxPlugins {
+		return ErrNodeAtCapacity
+	}
+	if p.maxMemory > 0 && memory+declaration.Resource.Memory > p.maxMemory {
+		return ErrNodeAtCapacity
+	}
+	return nil
+}
+
+// RuntimeStatuses returns the number of plugins running on the node by their runtime status
+func (p *PluginManager) RuntimeStatuses() map[string]int {
+	statuses := map[string]int{}
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		statuses[value.RuntimeState().Status]++
+		return true
+	})
+	return statuses
+}
+
+// RuntimeInfo is a plugin running on the node along with its runtime state
+type RuntimeInfo struct {
+	PluginUniqueIdentifier string                             `json:"plugin_unique_identifier"`
+	RuntimeType            plugin_entities.PluginRuntimeType  `json:"runtime_type"`
+	State                  plugin_entities.PluginRuntimeState `json:"state"`
+}
+
+// Runtimes returns plugins running on the node along with their runtime states
+func (p *PluginManager) Runtimes() []RuntimeInfo {
+	runtimes := []RuntimeInfo{}
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		runtimes = append(runtimes, RuntimeInfo{
+			PluginUniqueIdentifier: key,
+			RuntimeType:            value.Type(),
+			State:                  value.RuntimeState(),
+		})
+		return true
+	})
+	return runtimes
+}
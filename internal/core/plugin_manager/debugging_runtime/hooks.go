@@ -340,7 +340,7 @@ func (s *DifyServer) onMessage(runtime *RemotePluginRuntime, message []byte) {
 			}
 
 			// process handle shake if not completed
-			declaration, err := parser.UnmarshalJsonBytes[plugin_entities.PluginDeclaration](registerPayload.Data)
+			declaration, err := plugin_entities.UnmarshalManifestFromJSON(registerPayload.Data)
 			if err != nil {
 				// close connection if handshake failed
 				closeConn([]byte(fmt.Sprintf("handshake failed, invalid plugin declaration: %v\n", err)))
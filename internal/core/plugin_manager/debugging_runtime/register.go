@@ -3,6 +3,17 @@ package debugging_runtime
 import "github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
 
 func (plugin *RemotePluginRuntime) Register() error {
+	identity, err := plugin.Identity()
+	if err != nil {
+		return err
+	}
+	// plugins being debugged are installed by connecting to the daemon rather than by install apis
+	if err := install_service.CheckPluginPolicy(
+		plugin.tenantId, identity.PluginID(), identity.Author(), plugin.Configuration().Verified,
+	); err != nil {
+		return err
+	}
+
 	_, installation, err := install_service.InstallPlugin(
 		plugin.tenantId, "", plugin, "remote", map[string]any{},
 	)
@@ -4,7 +4,9 @@ import (
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
@@ -80,10 +82,13 @@ func (r *RemotePluginRuntime) StartPlugin() error {
 				r.lastActiveAt = time.Now()
 			},
 			func(err string) {
-				log.Error("plugin %s: %s", r.Configuration().Identity(), err)
+				log.With(log.FIELD_PLUGIN_ID, identity.PluginID()).
+					Error("plugin %s: %s", r.Configuration().Identity(), redact.Any(err))
 			},
-			func(message string) {
-				log.Info("plugin %s: %s", r.Configuration().Identity(), message)
+			func(sessionId string, event plugin_entities.PluginLogEvent) {
+				session_manager.LoggerOf(sessionId).
+					With(log.FIELD_PLUGIN_ID, identity.PluginID()).
+					Log(event.Level, "plugin %s: %s", r.Configuration().Identity(), redact.Session(sessionId, event.Message))
 			},
 		)
 	})
@@ -0,0 +1,474 @@
+package plugin_manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
+)
+
+var (
+	ErrDevPluginNotFound = errors.New("dev plugin not found")
+)
+
+// directories never synchronized from the source directory of a dev plugin
+var devIgnoredDirs = map[string]bool{
+	".venv":       true,
+	".git":        true,
+	"__pycache__": true,
+}
+
+type DevPluginState struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	SourcePath             string                                 `json:"source_path"`
+	Reloads                int                                    `json:"reloads"`
+	ReloadedAt             time.Time                              `json:"reloaded_at"`
+	// LastError is the error of the last reload, the previous version keeps running if it's not empty
+	LastError string `json:"last_error"`
+}
+
+type devPlugin struct {
+	sync.Mutex
+
+	state        DevPluginState
+	workingPath  string
+	snapshot     string
+	requirements string
+	stop         chan bool
+}
+
+// WatchDevPlugin runs the plugin from a local source directory and restarts it once the source changes,
+// the unique identifier is derived from the manifest and the source path, it stays the same across changes
+// so that installations, endpoints and provider settings are kept
+func (p *PluginManager) WatchDevPlugin(sourcePath string) (
+	plugin_entities.PluginUniqueIdentifier, *plugin_entities.PluginDeclaration, error,
+) {
+	sourcePath, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	sourceDecoder, declaration, err := loadDevPluginSource(sourcePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	identifier, err := devPluginIdentifier(declaration, sourcePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, ok := p.devPlugins.Load(identifier.String()); ok {
+		return identifier, declaration, nil
+	}
+
+	if _, ok := p.m.Load(identifier.String()); ok {
+		return "", nil, fmt.Errorf("plugin %s is already running", identifier)
+	}
+
+	if err := p.saveDevPluginDeclaration(identifier, sourceDecoder, declaration); err != nil {
+		return "", nil, err
+	}
+
+	identity := strings.ReplaceAll(declaration.Identity(), ":", "-")
+	dev := &devPlugin{
+		state: DevPluginState{
+			PluginUniqueIdentifier: identifier,
+			SourcePath:             sourcePath,
+			ReloadedAt:             time.Now(),
+		},
+		workingPath: path.Join(p.workingDirectory, "dev", fmt.Sprintf("%s@%s", identity, identifier.Checksum())),
+		stop:        make(chan bool),
+	}
+
+	dev.snapshot, err = devPluginSnapshot(sourcePath)
+	if err != nil {
+		return "", nil, err
+	}
+	dev.requirements = devPluginRequirements(sourcePath)
+
+	if err := syncDevPluginWorkingDirectory(sourceDecoder, dev.workingPath); err != nil {
+		return "", nil, errors.Join(err, errors.New("failed to copy plugin source to working directory"))
+	}
+
+	if _, loaded := p.devPlugins.LoadOrStore(identifier.String(), dev); loaded {
+		return identifier, declaration, nil
+	}
+
+	if err := p.launchDevPlugin(identifier, sourceDecoder, declaration, dev.workingPath); err != nil {
+		p.devPlugins.Delete(identifier.String())
+		return "", nil, err
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"function": "WatchDevPlugin",
+	}, func() {
+		p.watchDevPlugin(dev)
+	})
+
+	return identifier, declaration, nil
+}
+
+// UnwatchDevPlugin stops the dev plugin and removes its working directory
+func (p *PluginManager) UnwatchDevPlugin(identifier plugin_entities.PluginUniqueIdentifier) error {
+	dev, ok := p.devPlugins.LoadAndDelete(identifier.String())
+	if !ok {
+		return ErrDevPluginNotFound
+	}
+
+	close(dev.stop)
+
+	dev.Lock()
+	defer dev.Unlock()
+
+	p.stopDevPlugin(identifier)
+	return os.RemoveAll(dev.workingPath)
+}
+
+// ListDevPlugins returns the states of all dev plugins
+func (p *PluginManager) ListDevPlugins() []DevPluginState {
+	states := []DevPluginState{}
+	p.devPlugins.Range(func(key string, dev *devPlugin) bool {
+		dev.Lock()
+		states = append(states, dev.state)
+		dev.Unlock()
+		return true
+	})
+	return states
+}
+
+func (p *PluginManager) isDevPlugin(identifier string) bool {
+	return p.devPlugins.Exists(identifier)
+}
+
+func (p *PluginManager) watchDevPlugin(dev *devPlugin) {
+	ticker := time.NewTicker(p.devWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dev.stop:
+			return
+		case <-ticker.C:
+		}
+
+		snapshot, err := devPluginSnapshot(dev.state.SourcePath)
+		if err != nil {
+			log.Error("failed to check changes of dev plugin %s: %s", dev.state.PluginUniqueIdentifier, err.Error())
+			continue
+		}
+
+		if snapshot == dev.snapshot {
+			continue
+		}
+
+		dev.Lock()
+		select {
+		case <-dev.stop:
+			// unwatched while checking changes
+			dev.Unlock()
+			return
+		default:
+		}
+
+		dev.snapshot = snapshot
+		err = p.reloadDevPlugin(dev)
+		dev.state.ReloadedAt = time.Now()
+		dev.state.Reloads++
+		if err != nil {
+			dev.state.LastError = err.Error()
+			log.Error("failed to reload dev plugin %s: %s", dev.state.PluginUniqueIdentifier, err.Error())
+		} else {
+			dev.state.LastError = ""
+			log.Info("dev plugin %s reloaded", dev.state.PluginUniqueIdentifier)
+		}
+		dev.Unlock()
+	}
+}
+
+// reloadDevPlugin validates the changed source and restarts the plugin with it,
+// the running plugin is kept if the source is invalid
+func (p *PluginManager) reloadDevPlugin(dev *devPlugin) error {
+	identifier := dev.state.PluginUniqueIdentifier
+
+	sourceDecoder, declaration, err := loadDevPluginSource(dev.state.SourcePath)
+	if err != nil {
+		return err
+	}
+
+	if newIdentifier, err := devPluginIdentifier(declaration, dev.state.SourcePath); err != nil {
+		return err
+	} else if newIdentifier != identifier {
+		return fmt.Errorf(
+			"plugin identity changed from %s to %s, restart dev mode to apply it", identifier, newIdentifier,
+		)
+	}
+
+	if err := p.saveDevPluginDeclaration(identifier, sourceDecoder, declaration); err != nil {
+		return err
+	}
+
+	p.stopDevPlugin(identifier)
+
+	if err := syncDevPluginWorkingDirectory(sourceDecoder, dev.workingPath); err != nil {
+		return err
+	}
+
+	// dependencies changed, the virtual environment is rebuilt on launching
+	if requirements := devPluginRequirements(dev.state.SourcePath); requirements != dev.requirements {
+		if err := os.RemoveAll(path.Join(dev.workingPath, ".venv")); err != nil {
+			return err
+		}
+		dev.requirements = requirements
+	}
+
+	return p.launchDevPlugin(identifier, sourceDecoder, declaration, dev.workingPath)
+}
+
+// stopDevPlugin stops the running runtime and waits for its lifecycle to end
+func (p *PluginManager) stopDevPlugin(identifier plugin_entities.PluginUniqueIdentifier) {
+	lifetime, ok := p.m.Load(identifier.String())
+	if !ok {
+		return
+	}
+
+	lifetime.Stop()
+	for p.m.Exists(identifier.String()) {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (p *PluginManager) launchDevPlugin(
+	identifier plugin_entities.PluginUniqueIdentifier,
+	sourceDecoder *decoder.FSPluginDecoder,
+	declaration *plugin_entities.PluginDeclaration,
+	workingPath string,
+) error {
+	p.localPluginLaunchingLock.Lock(identifier.String())
+	defer p.localPluginLaunchingLock.Unlock(identifier.String())
+
+	assets, err := sourceDecoder.Assets()
+	if err != nil {
+		return err
+	}
+
+	localPluginRuntime := p.newLocalPluginRuntime()
+	localPluginRuntime.PluginRuntime = plugin_entities.PluginRuntime{
+		Config: *declaration,
+		State: plugin_entities.PluginRuntimeState{
+			Status:      plugin_entities.PLUGIN_RUNTIME_STATUS_PENDING,
+			WorkingPath: workingPath,
+		},
+	}
+	// the working directory is left empty to keep it and its virtual environment while restarting,
+	// it's removed by UnwatchDevPlugin
+	localPluginRuntime.BasicChecksum = basic_runtime.BasicChecksum{
+		MediaTransport: basic_runtime.NewMediaTransport(p.mediaBucket),
+		InnerChecksum:  identifier.Checksum(),
+		Decoder:        sourceDecoder,
+	}
+
+	if err := localPluginRuntime.RemapAssets(&localPluginRuntime.Config, assets); err != nil {
+		return errors.Join(err, fmt.Errorf("remap plugin assets error"))
+	}
+
+	launchedChan, errChan := p.runLocalPluginRuntime(identifier, localPluginRuntime)
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"function": "launchDevPlugin",
+	}, func() {
+		for err := range errChan {
+			log.Error("dev plugin %s launch error: %s", identifier, err.Error())
+		}
+		<-launchedChan
+	})
+
+	return nil
+}
+
+func (p *PluginManager) saveDevPluginDeclaration(
+	identifier plugin_entities.PluginUniqueIdentifier,
+	sourceDecoder *decoder.FSPluginDecoder,
+	declaration *plugin_entities.PluginDeclaration,
+) error {
+	assets, err := sourceDecoder.Assets()
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.mediaBucket.RemapAssets(declaration, assets); err != nil {
+		return errors.Join(err, fmt.Errorf("failed to remap assets"))
+	}
+
+	record, err := db.GetOne[models.PluginDeclaration](
+		db.Equal("plugin_unique_identifier", identifier.String()),
+	)
+	if err == db.ErrDatabaseNotFound {
+		err = db.Create(&models.PluginDeclaration{
+			PluginUniqueIdentifier: identifier.String(),
+			PluginID:               identifier.PluginID(),
+			Declaration:            *declaration,
+		})
+	} else if err == nil {
+		record.Declaration = *declaration
+		err = db.Update(&record)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the declaration changes with the source, the cached one is outdated
+	if err := helper.DeletePluginDeclarationCache(identifier, plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL); err != nil {
+		log.Warn("failed to delete declaration cache of dev plugin %s: %s", identifier, err.Error())
+	}
+
+	return nil
+}
+
+// loadDevPluginSource loads and validates the plugin source like packaging does
+func loadDevPluginSource(sourcePath string) (*decoder.FSPluginDecoder, *plugin_entities.PluginDeclaration, error) {
+	sourceDecoder, err := decoder.NewFSPluginDecoder(sourcePath)
+	if err != nil {
+		return nil, nil, errors.Join(err, errors.New("failed to load plugin source"))
+	}
+
+	if err := packager.NewPackager(sourceDecoder).Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	declaration, err := sourceDecoder.Manifest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sourceDecoder, &declaration, nil
+}
+
+func devPluginIdentifier(
+	declaration *plugin_entities.PluginDeclaration,
+	sourcePath string,
+) (plugin_entities.PluginUniqueIdentifier, error) {
+	hash := sha256.Sum256([]byte(sourcePath))
+	return plugin_entities.NewPluginUniqueIdentifier(
+		fmt.Sprintf("%s@%s", declaration.Identity(), hex.EncodeToString(hash[:])),
+	)
+}
+
+// devPluginSnapshot summarizes paths, sizes and modification times of the source files,
+// it changes once any file is created, modified or removed
+func devPluginSnapshot(sourcePath string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(sourcePath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if devIgnoredDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(hash, "%s:%d:%d\n", filePath, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func devPluginRequirements(sourcePath string) string {
+	content, err := os.ReadFile(path.Join(sourcePath, "requirements.txt"))
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// syncDevPluginWorkingDirectory makes the working directory identical to the source,
+// ignored directories in the working directory like the virtual environment are kept
+func syncDevPluginWorkingDirectory(sourceDecoder *decoder.FSPluginDecoder, workingPath string) error {
+	files := map[string]bool{}
+	err := sourceDecoder.Walk(func(filename, dir string) error {
+		for _, part := range strings.Split(dir, string(filepath.Separator)) {
+			if devIgnoredDirs[part] {
+				return nil
+			}
+		}
+
+		relPath := filepath.Join(dir, filename)
+		files[relPath] = true
+
+		content, err := sourceDecoder.ReadFile(relPath)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(workingPath, relPath)
+		if existing, err := os.ReadFile(target); err == nil && bytes.Equal(existing, content) {
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, content, 0644)
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove files deleted from the source
+	return filepath.WalkDir(workingPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if devIgnoredDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(workingPath, filePath)
+		if err != nil {
+			return err
+		}
+
+		if !files[relPath] {
+			return os.Remove(filePath)
+		}
+
+		return nil
+	})
+}
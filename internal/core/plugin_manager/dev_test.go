@@ -0,0 +1,107 @@
+package plugin_manager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func prepareDevPluginSource(t *testing.T) string {
+	source := t.TempDir()
+	for _, file := range []string{"manifest.yaml", "neko.yaml", "_assets/test.svg"} {
+		content, err := os.ReadFile(filepath.Join("../../../pkg/plugin_packager", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// unique identifiers are lowercase
+		content = bytes.ReplaceAll(content, []byte("Yeuoly"), []byte("yeuoly"))
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(source, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(source, file), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return source
+}
+
+func TestDevPluginSnapshot(t *testing.T) {
+	source := prepareDevPluginSource(t)
+
+	snapshot, err := devPluginSnapshot(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changes of the virtual environment should be ignored
+	if err := os.MkdirAll(filepath.Join(source, ".venv"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, ".venv", "lib.py"), []byte("pass"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := devPluginSnapshot(source); err != nil || current != snapshot {
+		t.Fatal("snapshot should not change with the virtual environment")
+	}
+
+	if err := os.WriteFile(filepath.Join(source, "main.py"), []byte("print('neko')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := devPluginSnapshot(source); err != nil || current == snapshot {
+		t.Fatal("snapshot should change once a file is created")
+	}
+}
+
+func TestSyncDevPluginWorkingDirectory(t *testing.T) {
+	source := prepareDevPluginSource(t)
+	workingPath := filepath.Join(t.TempDir(), "working")
+
+	if err := os.WriteFile(filepath.Join(source, "main.py"), []byte("print('neko')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sourceDecoder, declaration, err := loadDevPluginSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syncDevPluginWorkingDirectory(sourceDecoder, workingPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(workingPath, "main.py")); err != nil {
+		t.Fatal("main.py should be copied to the working directory")
+	}
+
+	// the virtual environment in the working directory should be kept, removed files should be deleted
+	if err := os.MkdirAll(filepath.Join(workingPath, ".venv"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(source, "main.py")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncDevPluginWorkingDirectory(sourceDecoder, workingPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(workingPath, "main.py")); !os.IsNotExist(err) {
+		t.Fatal("main.py should be removed from the working directory")
+	}
+	if _, err := os.Stat(filepath.Join(workingPath, ".venv")); err != nil {
+		t.Fatal("virtual environment should be kept")
+	}
+
+	// the identifier is stable for the same source directory
+	first, err := devPluginIdentifier(declaration, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := devPluginIdentifier(declaration, source)
+	if err != nil || first != second {
+		t.Fatal("identifier should be stable")
+	}
+	if other, _ := devPluginIdentifier(declaration, workingPath); other == first {
+		t.Fatal("identifier should differ between source directories")
+	}
+}
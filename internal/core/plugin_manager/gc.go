@@ -0,0 +1,384 @@
+package plugin_manager
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/singleton_job"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var (
+	ErrGarbageCollectionRunning = errors.New("garbage collection is already running")
+)
+
+type GarbageKind string
+
+const (
+	GarbageKindPackage          GarbageKind = "package"
+	GarbageKindInstalled        GarbageKind = "installed"
+	GarbageKindWorkingDirectory GarbageKind = "working_directory"
+	GarbageKindMedia            GarbageKind = "media"
+)
+
+// garbageScope is where garbage is collected, the storage is shared by all nodes
+// while working directories are local to each node
+type garbageScope int
+
+const (
+	garbageScopeStorage garbageScope = 1 << iota
+	garbageScopeLocal
+
+	garbageScopeAll = garbageScopeStorage | garbageScopeLocal
+)
+
+type Garbage struct {
+	Kind         GarbageKind `json:"kind"`
+	Name         string      `json:"name"`
+	Size         int64       `json:"size"`
+	LastModified time.Time   `json:"last_modified"`
+}
+
+type GarbageCollectionReport struct {
+	DryRun  bool      `json:"dry_run"`
+	Garbage []Garbage `json:"garbage"`
+	// ReclaimableSize is the total size of the garbage in bytes
+	ReclaimableSize int64 `json:"reclaimable_size"`
+	// ReclaimedSize is the size actually deleted, always 0 in dry run mode
+	ReclaimedSize int64    `json:"reclaimed_size"`
+	Errors        []string `json:"errors"`
+}
+
+func (r *GarbageCollectionReport) add(garbage Garbage) {
+	r.Garbage = append(r.Garbage, garbage)
+	r.ReclaimableSize += garbage.Size
+}
+
+// CollectGarbage finds plugin packages, installed packages, working directories and media files
+// which are no longer referenced by any plugin and deletes them unless dryRun is set,
+// files modified within gracePeriod are kept to avoid racing with ongoing uploads and installations
+func (p *PluginManager) CollectGarbage(dryRun bool, gracePeriod time.Duration) (*GarbageCollectionReport, error) {
+	return p.collectGarbage(dryRun, gracePeriod, garbageScopeAll)
+}
+
+func (p *PluginManager) collectGarbage(
+	dryRun bool,
+	gracePeriod time.Duration,
+	scope garbageScope,
+) (*GarbageCollectionReport, error) {
+	if !p.gcLock.TryLock() {
+		return nil, ErrGarbageCollectionRunning
+	}
+	defer p.gcLock.Unlock()
+
+	report := &GarbageCollectionReport{
+		DryRun:  dryRun,
+		Garbage: []Garbage{},
+		Errors:  []string{},
+	}
+	deadline := time.Now().Add(-gracePeriod)
+
+	plugins, err := db.GetAll[models.Plugin]()
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]bool{}
+	referencedMedia := map[string]bool{}
+	for _, plugin := range plugins {
+		installed[plugin.PluginUniqueIdentifier] = true
+		for _, id := range media_transport.DeclarationAssets(&plugin.RemoteDeclaration) {
+			referencedMedia[id] = true
+		}
+	}
+
+	// running plugins remap their assets on launching, keep them even if the declaration is gone
+	workingPaths := map[string]bool{}
+	p.m.Range(func(key string, lifetime plugin_entities.PluginLifetime) bool {
+		for _, id := range media_transport.DeclarationAssets(lifetime.Configuration()) {
+			referencedMedia[id] = true
+		}
+		if workingPath := lifetime.RuntimeState().WorkingPath; workingPath != "" {
+			workingPaths[filepath.Clean(workingPath)] = true
+		}
+		return true
+	})
+
+	if scope&garbageScopeStorage != 0 {
+		if err := p.findOrphanedStorage(installed, referencedMedia, deadline, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if scope&garbageScopeLocal != 0 {
+		// working directories of plugins which are neither installed nor running
+		for identifier := range installed {
+			workingPaths[p.localWorkingPath(plugin_entities.PluginUniqueIdentifier(identifier))] = true
+		}
+		if err := p.findOrphanedWorkingDirectories(workingPaths, deadline, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	for _, garbage := range report.Garbage {
+		if err := p.deleteGarbage(garbage); err != nil {
+			log.Error("failed to delete %s %s: %s", garbage.Kind, garbage.Name, err.Error())
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.ReclaimedSize += garbage.Size
+	}
+
+	return report, nil
+}
+
+// findOrphanedStorage finds packages, installed packages and media files in the storage shared by nodes
+func (p *PluginManager) findOrphanedStorage(
+	installed map[string]bool,
+	referencedMedia map[string]bool,
+	deadline time.Time,
+	report *GarbageCollectionReport,
+) error {
+	// packages uploaded but not installed by any tenant
+	orphanedPackages := map[string]bool{}
+	packages, err := p.packageBucket.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range packages {
+		if installed[name] {
+			continue
+		}
+
+		state, err := p.packageBucket.State(name)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		if state.LastModified.After(deadline) {
+			continue
+		}
+
+		orphanedPackages[name] = true
+		report.add(Garbage{Kind: GarbageKindPackage, Name: name, Size: state.Size, LastModified: state.LastModified})
+	}
+
+	// installed packages left behind by uninstalled plugins
+	installedPackages, err := p.installedBucket.List()
+	if err != nil {
+		return err
+	}
+	for _, identifier := range installedPackages {
+		if installed[identifier.String()] {
+			continue
+		}
+
+		state, err := p.installedBucket.State(identifier)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		if state.LastModified.After(deadline) {
+			continue
+		}
+
+		report.add(Garbage{
+			Kind: GarbageKindInstalled, Name: identifier.String(), Size: state.Size, LastModified: state.LastModified,
+		})
+	}
+
+	// media files are referenced by declarations, the declarations of orphaned packages are removed along with them
+	declarations, err := db.GetAll[models.PluginDeclaration]()
+	if err != nil {
+		return err
+	}
+	for _, declaration := range declarations {
+		if orphanedPackages[declaration.PluginUniqueIdentifier] {
+			continue
+		}
+		for _, id := range media_transport.DeclarationAssets(&declaration.Declaration) {
+			referencedMedia[id] = true
+		}
+	}
+
+	media, err := p.mediaBucket.List()
+	if err != nil {
+		return err
+	}
+	for _, id := range media {
+		if referencedMedia[id] {
+			continue
+		}
+
+		state, err := p.mediaBucket.State(id)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		if state.LastModified.After(deadline) {
+			continue
+		}
+
+		report.add(Garbage{Kind: GarbageKindMedia, Name: id, Size: state.Size, LastModified: state.LastModified})
+	}
+
+	return nil
+}
+
+// findOrphanedWorkingDirectories walks working directories laid out as [author/]name-version@checksum,
+// the directory of dev plugins is managed by dev mode and skipped
+func (p *PluginManager) findOrphanedWorkingDirectories(
+	workingPaths map[string]bool,
+	deadline time.Time,
+	report *GarbageCollectionReport,
+) error {
+	entries, err := os.ReadDir(p.workingDirectory)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	check := func(dir string) {
+		if workingPaths[filepath.Clean(dir)] {
+			return
+		}
+
+		size, lastModified, err := directoryState(dir)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return
+		}
+		if lastModified.After(deadline) {
+			return
+		}
+
+		name, _ := filepath.Rel(p.workingDirectory, dir)
+		report.add(Garbage{Kind: GarbageKindWorkingDirectory, Name: name, Size: size, LastModified: lastModified})
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "dev" {
+			continue
+		}
+
+		dir := filepath.Join(p.workingDirectory, entry.Name())
+		if strings.Contains(entry.Name(), "@") {
+			check(dir)
+			continue
+		}
+
+		// author directory
+		children, err := os.ReadDir(dir)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		for _, child := range children {
+			if child.IsDir() {
+				check(filepath.Join(dir, child.Name()))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *PluginManager) deleteGarbage(garbage Garbage) error {
+	switch garbage.Kind {
+	case GarbageKindPackage:
+		if err := p.packageBucket.Delete(garbage.Name); err != nil {
+			return err
+		}
+		return db.DeleteByCondition(models.PluginDeclaration{PluginUniqueIdentifier: garbage.Name})
+	case GarbageKindInstalled:
+		return p.installedBucket.Delete(plugin_entities.PluginUniqueIdentifier(garbage.Name))
+	case GarbageKindMedia:
+		return p.mediaBucket.Delete(garbage.Name)
+	case GarbageKindWorkingDirectory:
+		dir := filepath.Join(p.workingDirectory, garbage.Name)
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		// remove the author directory once it's empty
+		if parent := filepath.Dir(dir); parent != filepath.Clean(p.workingDirectory) {
+			if entries, err := os.ReadDir(parent); err == nil && len(entries) == 0 {
+				os.Remove(parent)
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// directoryState returns the total size and the latest modification time of files in a directory
+func directoryState(dir string) (int64, time.Time, error) {
+	size := int64(0)
+	lastModified := time.Time{}
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			size += info.Size()
+		}
+		if info.ModTime().After(lastModified) {
+			lastModified = info.ModTime()
+		}
+		return nil
+	})
+	return size, lastModified, err
+}
+
+// startGarbageCollector collects garbage periodically, working directories are collected by each node
+// while the storage shared by nodes is collected by one of them
+func (p *PluginManager) startGarbageCollector(interval time.Duration, gracePeriod time.Duration) {
+	collect := func(scope garbageScope) error {
+		report, err := p.collectGarbage(false, gracePeriod, scope)
+		if err != nil {
+			return err
+		}
+
+		if len(report.Garbage) > 0 {
+			log.Info(
+				"garbage collection found %d orphaned files, %d bytes reclaimed",
+				len(report.Garbage), report.ReclaimedSize,
+			)
+		}
+		return nil
+	}
+
+	singleton_job.Register(singleton_job.Job{
+		Name:     "plugin_storage_garbage_collection",
+		Interval: interval,
+		Run: func() error {
+			return collect(garbageScopeStorage)
+		},
+	})
+
+	go func() {
+		for range time.NewTicker(interval).C {
+			if err := collect(garbageScopeLocal); err != nil {
+				log.Error("garbage collection failed: %s", err.Error())
+			}
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package plugin_manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindOrphanedWorkingDirectories(t *testing.T) {
+	workingDirectory := t.TempDir()
+	p := &PluginManager{workingDirectory: workingDirectory}
+
+	for _, dir := range []string{
+		"langgenius/neko-0.0.1@aaaa",
+		"langgenius/neko-0.0.2@bbbb",
+		"standalone-0.0.1@cccc",
+		"dev/langgenius/neko-0.0.1@dddd",
+	} {
+		if err := os.MkdirAll(filepath.Join(workingDirectory, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(workingDirectory, dir, "main.py"), []byte("pass"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := &GarbageCollectionReport{}
+	err := p.findOrphanedWorkingDirectories(map[string]bool{
+		filepath.Join(workingDirectory, "langgenius/neko-0.0.2@bbbb"): true,
+	}, time.Now().Add(time.Minute), report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]bool{}
+	for _, garbage := range report.Garbage {
+		found[garbage.Name] = true
+	}
+	if len(found) != 2 || !found["langgenius/neko-0.0.1@aaaa"] || !found["standalone-0.0.1@cccc"] {
+		t.Fatalf("unexpected garbage: %v", report.Garbage)
+	}
+	if report.ReclaimableSize != 8 {
+		t.Fatalf("unexpected reclaimable size: %d", report.ReclaimableSize)
+	}
+
+	// recently modified directories are kept
+	report = &GarbageCollectionReport{}
+	if err := p.findOrphanedWorkingDirectories(map[string]bool{}, time.Now().Add(-time.Minute), report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Garbage) != 0 {
+		t.Fatalf("recently modified directories should be kept: %v", report.Garbage)
+	}
+}
@@ -8,7 +8,18 @@ const (
 	PluginInstallEventError PluginInstallEvent = "error"
 )
 
+// PluginInstallStage is the stage of deploying a serverless plugin, it's reported along with info events
+type PluginInstallStage string
+
+const (
+	PluginInstallStageUpload    PluginInstallStage = "upload"
+	PluginInstallStageBuild     PluginInstallStage = "build"
+	PluginInstallStageProvision PluginInstallStage = "provision"
+	PluginInstallStageReady     PluginInstallStage = "ready"
+)
+
 type PluginInstallResponse struct {
 	Event PluginInstallEvent `json:"event"`
+	Stage PluginInstallStage `json:"stage,omitempty"`
 	Data  string             `json:"data"`
 }
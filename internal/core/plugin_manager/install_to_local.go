@@ -25,6 +25,18 @@ func (p *PluginManager) InstallToLocal(
 	if err != nil {
 		return nil, err
 	}
+	p.notifyPluginInstalled(plugin_unique_identifier)
+
+	// runners the plugin is placed on launch it once they're notified
+	if !p.runsPlugins() || !p.placedOnCurrentNode(plugin_unique_identifier) {
+		response := stream.NewStream[PluginInstallResponse](1)
+		response.Write(PluginInstallResponse{
+			Event: PluginInstallEventDone,
+			Data:  "Installed, launching on runner nodes",
+		})
+		response.Close()
+		return response, nil
+	}
 
 	runtime, launchedChan, errChan, err := p.launchLocal(plugin_unique_identifier)
 	if err != nil {
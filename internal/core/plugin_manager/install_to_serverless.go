@@ -6,12 +6,16 @@ import (
 	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"gorm.io/gorm"
 )
 
-// InstallToAWSFromPkg installs a plugin to AWS Lambda
+// InstallToAWSFromPkg installs a plugin to the configured serverless provider, AWS Lambda by default
 func (p *PluginManager) InstallToAWSFromPkg(
 	originalPackager []byte,
 	decoder decoder.PluginDecoder,
@@ -25,7 +29,7 @@ func (p *PluginManager) InstallToAWSFromPkg(
 		return nil, err
 	}
 	// check valid manifest
-	_, err = decoder.Manifest()
+	manifest, err := decoder.Manifest()
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +38,14 @@ func (p *PluginManager) InstallToAWSFromPkg(
 		return nil, err
 	}
 
-	response, err := serverless.LaunchPlugin(originalPackager, decoder)
+	resources, err := serverlessFunctionResources(uniqueIdentity.PluginID(), manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first region is launched before returning so that errors of the deployment are returned at once
+	regions := serverless.Regions()
+	response, err := serverless.LaunchPlugin(originalPackager, decoder, resources, regions[0])
 	if err != nil {
 		return nil, err
 	}
@@ -51,71 +62,243 @@ func (p *PluginManager) InstallToAWSFromPkg(
 			newResponse.Close()
 		}()
 
-		functionUrl := ""
-		functionName := ""
-
-		response.Async(func(r serverless.LaunchFunctionResponse) {
-			if r.Event == serverless.Info {
-				newResponse.Write(PluginInstallResponse{
-					Event: PluginInstallEventInfo,
-					Data:  "Installing...",
-				})
-			} else if r.Event == serverless.Done {
-				if functionUrl == "" || functionName == "" {
-					newResponse.Write(PluginInstallResponse{
-						Event: PluginInstallEventError,
-						Data:  "Internal server error, failed to get lambda url or function name",
-					})
-					return
-				}
-				// check if the plugin is already installed
-				_, err := db.GetOne[models.ServerlessRuntime](
-					db.Equal("checksum", checksum),
-					db.Equal("type", string(models.SERVERLESS_RUNTIME_TYPE_SERVERLESS)),
-				)
-				if err == db.ErrDatabaseNotFound {
-					// create a new serverless runtime
-					serverlessModel := &models.ServerlessRuntime{
-						Checksum:               checksum,
-						Type:                   models.SERVERLESS_RUNTIME_TYPE_SERVERLESS,
-						FunctionURL:            functionUrl,
-						FunctionName:           functionName,
-						PluginUniqueIdentifier: uniqueIdentity.String(),
-					}
-					err = db.Create(serverlessModel)
-					if err != nil {
-						newResponse.Write(PluginInstallResponse{
-							Event: PluginInstallEventError,
-							Data:  "Failed to create serverless runtime",
-						})
-						return
-					}
-				} else if err != nil {
+		functions := []models.ServerlessRegionalFunction{}
+		for i, region := range regions {
+			if i > 0 {
+				response, err = serverless.LaunchPlugin(originalPackager, decoder, resources, region)
+				if err != nil {
 					newResponse.Write(PluginInstallResponse{
 						Event: PluginInstallEventError,
-						Data:  "Failed to check if the plugin is already installed",
+						Data:  fmt.Sprintf("Failed to launch plugin in region %s: %s", region, err.Error()),
 					})
 					return
 				}
+			}
+
+			function, ok := forwardServerlessLaunch(response, region, newResponse)
+			if !ok {
+				return
+			}
+			function.PluginUniqueIdentifier = uniqueIdentity.String()
+			functions = append(functions, function)
+		}
 
+		// check if the plugin is already installed
+		serverlessModel, err := db.GetOne[models.ServerlessRuntime](
+			db.Equal("checksum", checksum),
+			db.Equal("type", string(models.SERVERLESS_RUNTIME_TYPE_SERVERLESS)),
+		)
+		if err == nil && serverlessModel.FunctionURL != functions[0].FunctionURL {
+			// redeployed with other resources, invocations are switched to the new function
+			serverlessModel.FunctionURL = functions[0].FunctionURL
+			serverlessModel.FunctionName = functions[0].FunctionName
+			serverlessModel.MemoryMB = resources.MemoryMB
+			if err := db.Update(&serverlessModel); err != nil {
 				newResponse.Write(PluginInstallResponse{
-					Event: PluginInstallEventDone,
-					Data:  "Installed",
+					Event: PluginInstallEventError,
+					Data:  "Failed to update serverless runtime",
 				})
-			} else if r.Event == serverless.Error {
+				return
+			}
+			if err := p.dropServerlessRuntimeCache(uniqueIdentity); err != nil {
+				newResponse.Write(PluginInstallResponse{
+					Event: PluginInstallEventError,
+					Data:  "Failed to drop cached serverless runtime",
+				})
+				return
+			}
+		} else if err == db.ErrDatabaseNotFound {
+			// create a new serverless runtime
+			serverlessModel := &models.ServerlessRuntime{
+				Checksum:               checksum,
+				Type:                   models.SERVERLESS_RUNTIME_TYPE_SERVERLESS,
+				FunctionURL:            functions[0].FunctionURL,
+				FunctionName:           functions[0].FunctionName,
+				PluginUniqueIdentifier: uniqueIdentity.String(),
+				MemoryMB:               resources.MemoryMB,
+			}
+			err = db.Create(serverlessModel)
+			if err != nil {
 				newResponse.Write(PluginInstallResponse{
 					Event: PluginInstallEventError,
-					Data:  "Internal server error",
+					Data:  "Failed to create serverless runtime",
 				})
-			} else if r.Event == serverless.FunctionUrl {
-				functionUrl = r.Message
-			} else if r.Event == serverless.Function {
-				functionName = r.Message
-			} else {
-				newResponse.WriteError(fmt.Errorf("unknown event: %s, with message: %s", r.Event, r.Message))
+				return
 			}
+		} else if err != nil {
+			newResponse.Write(PluginInstallResponse{
+				Event: PluginInstallEventError,
+				Data:  "Failed to check if the plugin is already installed",
+			})
+			return
+		}
+
+		if functions[0].Region != "" {
+			if err := saveServerlessRegionalFunctions(uniqueIdentity, functions); err != nil {
+				newResponse.Write(PluginInstallResponse{
+					Event: PluginInstallEventError,
+					Data:  "Failed to save regional functions",
+				})
+				return
+			}
+		}
+
+		newResponse.Write(PluginInstallResponse{
+			Event: PluginInstallEventDone,
+			Data:  "Installed",
 		})
 	})
 
 	return newResponse, nil
 }
+
+// RedeployServerlessPlugin deploys the installed versions of the plugin again with the resources resolved
+// at the moment, deployments run in background and each version is served by its old function until
+// its new one is ready
+func (p *PluginManager) RedeployServerlessPlugin(pluginID string) error {
+	runtimes, err := listServerlessRuntimes(pluginID)
+	if err != nil {
+		return err
+	}
+
+	for _, runtime := range runtimes {
+		identity, err := plugin_entities.NewPluginUniqueIdentifier(runtime.PluginUniqueIdentifier)
+		if err != nil {
+			return err
+		}
+
+		pkgFile, err := p.GetPackage(identity)
+		if err != nil {
+			return err
+		}
+
+		zipDecoder, err := decoder.NewZipPluginDecoder(pkgFile)
+		if err != nil {
+			return err
+		}
+
+		response, err := p.InstallToAWSFromPkg(pkgFile, zipDecoder, "redeploy", nil)
+		if err != nil {
+			return err
+		}
+
+		routine.Submit(map[string]string{
+			"module":          "plugin_manager",
+			"function":        "RedeployServerlessPlugin",
+			"unique_identity": identity.String(),
+		}, func() {
+			response.Async(func(r PluginInstallResponse) {
+				if r.Event == PluginInstallEventError {
+					log.Error("failed to redeploy serverless plugin %s: %s", identity.String(), r.Data)
+				}
+			})
+		})
+	}
+
+	return nil
+}
+
+// forwardServerlessLaunch forwards progress of launching the function in the region,
+// it returns the launched function or false if it failed, the error has been forwarded already
+func forwardServerlessLaunch(
+	response *stream.Stream[serverless.LaunchFunctionResponse],
+	region string,
+	newResponse *stream.Stream[PluginInstallResponse],
+) (models.ServerlessRegionalFunction, bool) {
+	function := models.ServerlessRegionalFunction{Region: region}
+	done := false
+	failed := false
+
+	response.Async(func(r serverless.LaunchFunctionResponse) {
+		if failed {
+			return
+		}
+
+		if r.Event == serverless.Info {
+			message := r.Message
+			if message == "" {
+				message = "Installing..."
+			}
+			if region != "" {
+				message = fmt.Sprintf("[%s] %s", region, message)
+			}
+			newResponse.Write(PluginInstallResponse{
+				Event: PluginInstallEventInfo,
+				Stage: PluginInstallStage(r.Stage),
+				Data:  message,
+			})
+		} else if r.Event == serverless.Done {
+			if function.FunctionURL == "" || function.FunctionName == "" {
+				failed = true
+				newResponse.Write(PluginInstallResponse{
+					Event: PluginInstallEventError,
+					Data:  "Internal server error, failed to get lambda url or function name",
+				})
+				return
+			}
+			done = true
+		} else if r.Event == serverless.Error {
+			failed = true
+			newResponse.Write(PluginInstallResponse{
+				Event: PluginInstallEventError,
+				Data:  "Internal server error",
+			})
+		} else if r.Event == serverless.FunctionUrl {
+			function.FunctionURL = r.Message
+		} else if r.Event == serverless.Function {
+			function.FunctionName = r.Message
+		} else {
+			failed = true
+			newResponse.WriteError(fmt.Errorf("unknown event: %s, with message: %s", r.Event, r.Message))
+		}
+	})
+
+	return function, done && !failed
+}
+
+// saveServerlessRegionalFunctions replaces the regional functions of the plugin
+func saveServerlessRegionalFunctions(
+	identity plugin_entities.PluginUniqueIdentifier,
+	functions []models.ServerlessRegionalFunction,
+) error {
+	if err := db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.DeleteByCondition(models.ServerlessRegionalFunction{
+			PluginUniqueIdentifier: identity.String(),
+		}, tx); err != nil {
+			return err
+		}
+		for i := range functions {
+			if err := db.Create(&functions[i], tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return cache.Del(getServerlessRegionsCacheKey(identity))
+}
+
+// serverlessFunctionResources resolves resources of the function, the override of the operator takes precedence
+func serverlessFunctionResources(
+	pluginID string,
+	manifest plugin_entities.PluginDeclaration,
+) (serverless.FunctionResources, error) {
+	var override *serverless.FunctionResources
+	record, err := db.GetOne[models.ServerlessResourceOverride](
+		db.Equal("plugin_id", pluginID),
+	)
+	if err == nil {
+		override = &serverless.FunctionResources{
+			MemoryMB:           record.MemoryMB,
+			TimeoutSeconds:     record.TimeoutSeconds,
+			EphemeralStorageMB: record.EphemeralStorageMB,
+			Concurrency:        record.Concurrency,
+		}
+	} else if err != db.ErrDatabaseNotFound {
+		return serverless.FunctionResources{}, err
+	}
+
+	return serverless.ResolveFunctionResources(manifest, override)
+}
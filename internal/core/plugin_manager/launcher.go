@@ -102,6 +102,10 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 		return lifetime, c, errChan, nil
 	}
 
+	if err := p.admitLocalPlugin(&plugin.runtime.Config); err != nil {
+		return nil, nil, nil, err
+	}
+
 	// extract plugin
 	decoder, ok := plugin.decoder.(*decoder.ZipPluginDecoder)
 	if !ok {
@@ -129,16 +133,7 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 		return nil, nil, nil, failed(err.Error())
 	}
 
-	localPluginRuntime := local_runtime.NewLocalPluginRuntime(local_runtime.LocalPluginRuntimeConfig{
-		PythonInterpreterPath:     p.pythonInterpreterPath,
-		PythonEnvInitTimeout:      p.pythonEnvInitTimeout,
-		PythonCompileAllExtraArgs: p.pythonCompileAllExtraArgs,
-		HttpProxy:                 p.HttpProxy,
-		HttpsProxy:                p.HttpsProxy,
-		PipMirrorUrl:              p.pipMirrorUrl,
-		PipPreferBinary:           p.pipPreferBinary,
-		PipExtraArgs:              p.pipExtraArgs,
-	})
+	localPluginRuntime := p.newLocalPluginRuntime()
 	localPluginRuntime.PluginRuntime = plugin.runtime
 	localPluginRuntime.BasicChecksum = basic_runtime.BasicChecksum{
 		MediaTransport: basic_runtime.NewMediaTransport(p.mediaBucket),
@@ -155,6 +150,30 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 
 	success = true
 
+	launchedChan, errChan := p.runLocalPluginRuntime(identity, localPluginRuntime)
+	return localPluginRuntime, launchedChan, errChan, nil
+}
+
+func (p *PluginManager) newLocalPluginRuntime() *local_runtime.LocalPluginRuntime {
+	return local_runtime.NewLocalPluginRuntime(local_runtime.LocalPluginRuntimeConfig{
+		PythonInterpreterPath:     p.pythonInterpreterPath,
+		PythonEnvInitTimeout:      p.pythonEnvInitTimeout,
+		PythonCompileAllExtraArgs: p.pythonCompileAllExtraArgs,
+		HttpProxy:                 p.HttpProxy,
+		HttpsProxy:                p.HttpsProxy,
+		PipMirrorUrl:              p.pipMirrorUrl,
+		PipPreferBinary:           p.pipPreferBinary,
+		PipExtraArgs:              p.pipExtraArgs,
+		UserIsolation:             p.userIsolation,
+	})
+}
+
+// runLocalPluginRuntime registers the runtime and runs its lifecycle asynchronously,
+// the runtime is unregistered once its lifecycle ends
+func (p *PluginManager) runLocalPluginRuntime(
+	identity plugin_entities.PluginUniqueIdentifier,
+	localPluginRuntime *local_runtime.LocalPluginRuntime,
+) (<-chan bool, <-chan error) {
 	p.m.Store(identity.String(), localPluginRuntime)
 
 	// NOTE: you should always keep the size of the channel to 0
@@ -189,5 +208,5 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 		p.fullDuplexLifecycle(localPluginRuntime, launchedChan, errChan)
 	})
 
-	return localPluginRuntime, launchedChan, errChan, nil
+	return launchedChan, errChan
 }
@@ -2,6 +2,7 @@ package local_runtime
 
 import (
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/fault"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
@@ -27,5 +28,13 @@ func (r *LocalPluginRuntime) Listen(session_id string) *entities.Broadcast[plugi
 }
 
 func (r *LocalPluginRuntime) Write(session_id string, action access_types.PluginAccessAction, data []byte) {
+	if fault.Inject(fault.POINT_PLUGIN_WRITE, r.Config.Identity()) != nil {
+		// the process is killed once its stdio is stopped, it's restarted as if it crashed
+		log.Warn("plugin %s is crashed by fault injection", r.Config.Identity())
+		if stdio := getStdioHandler(r.ioIdentity); stdio != nil {
+			stdio.Stop()
+		}
+		return
+	}
 	writeToStdioHandler(r.ioIdentity, append(data, '\n'))
 }
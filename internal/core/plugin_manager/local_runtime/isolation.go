@@ -0,0 +1,147 @@
+package local_runtime
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// UserIsolation runs plugin processes as dedicated unprivileged users, every plugin gets its own
+// uid and gid from [base, base+count) and exclusively owns its working directory
+type UserIsolation struct {
+	base  int
+	count int
+
+	lock sync.Mutex
+	// plugin id -> uid
+	uids map[string]int
+	// uid -> number of running processes of the plugin
+	refs map[int]int
+}
+
+func NewUserIsolation(base int, count int) *UserIsolation {
+	return &UserIsolation{
+		base:  base,
+		count: count,
+		uids:  map[string]int{},
+		refs:  map[int]int{},
+	}
+}
+
+// Acquire returns the uid of the plugin, versions of a plugin share the uid
+func (u *UserIsolation) Acquire(pluginID string) (int, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if uid, ok := u.uids[pluginID]; ok {
+		u.refs[uid]++
+		return uid, nil
+	}
+
+	// start from the hash of the plugin id so that plugins mostly keep their uids across restarts
+	h := fnv.New32a()
+	h.Write([]byte(pluginID))
+	offset := int(h.Sum32() % uint32(u.count))
+
+	for i := 0; i < u.count; i++ {
+		uid := u.base + (offset+i)%u.count
+		if _, used := u.refs[uid]; !used {
+			u.uids[pluginID] = uid
+			u.refs[uid] = 1
+			return uid, nil
+		}
+	}
+
+	return 0, errors.New("no uid left for plugins, increase PLUGIN_RUN_AS_USER_UID_COUNT")
+}
+
+// Release gives the uid of the plugin back once no process of the plugin is running
+func (u *UserIsolation) Release(pluginID string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	uid, ok := u.uids[pluginID]
+	if !ok {
+		return
+	}
+
+	u.refs[uid]--
+	if u.refs[uid] <= 0 {
+		delete(u.refs, uid)
+		delete(u.uids, pluginID)
+	}
+}
+
+// variables passed to isolated plugins, the others inherited from the daemon like the server key or
+// the database password are kept from them
+var isolatedEnvironment = []string{
+	"INSTALL_METHOD", "PATH", "LANG", "LC_ALL", "LC_CTYPE", "TZ",
+	"SSL_CERT_FILE", "SSL_CERT_DIR", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+}
+
+// filterEnvironment keeps the variables allowed for isolated plugins
+func filterEnvironment(environ []string) []string {
+	filtered := []string{}
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		for _, allowed := range isolatedEnvironment {
+			if key == allowed {
+				filtered = append(filtered, kv)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// isolate makes the working directory private to uid and sets cmd up to run as uid
+func isolate(cmd *exec.Cmd, workingPath string, uid int) error {
+	workingPath, err := filepath.Abs(workingPath)
+	if err != nil {
+		return err
+	}
+
+	// working directories of other plugins can be entered but not listed
+	if err := os.Chmod(path.Dir(workingPath), 0o711); err != nil {
+		return fmt.Errorf("protect plugin working directory failed: %s", err.Error())
+	}
+
+	tmp := path.Join(workingPath, ".tmp")
+	if err := os.MkdirAll(tmp, 0o700); err != nil {
+		return fmt.Errorf("create plugin temp directory failed: %s", err.Error())
+	}
+
+	// the working directory is prepared by the daemon, hand it over to the plugin
+	if err := filepath.WalkDir(workingPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(p, uid, uid)
+	}); err != nil {
+		return fmt.Errorf("chown plugin working directory failed: %s", err.Error())
+	}
+
+	if err := os.Chmod(workingPath, 0o700); err != nil {
+		return fmt.Errorf("protect plugin working directory failed: %s", err.Error())
+	}
+
+	cmd.Env = append(filterEnvironment(cmd.Env), "HOME="+workingPath, "TMPDIR="+tmp)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid:    uint32(uid),
+			Gid:    uint32(uid),
+			Groups: []uint32{},
+		},
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package local_runtime
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+)
+
+func TestUserIsolationAcquire(t *testing.T) {
+	isolation := NewUserIsolation(200000, 2)
+
+	a, err := isolation.Acquire("langgenius/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// versions of a plugin share the uid
+	again, err := isolation.Acquire("langgenius/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != a {
+		t.Fatalf("expected uid %d, got %d", a, again)
+	}
+
+	b, err := isolation.Acquire("langgenius/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == a || b < 200000 || b >= 200002 {
+		t.Fatalf("unexpected uid %d", b)
+	}
+
+	if _, err := isolation.Acquire("langgenius/c"); err == nil {
+		t.Fatal("uids should be exhausted")
+	}
+
+	// the uid is kept until every process of the plugin stopped
+	isolation.Release("langgenius/a")
+	if _, err := isolation.Acquire("langgenius/c"); err == nil {
+		t.Fatal("uid of a running plugin should not be reused")
+	}
+
+	isolation.Release("langgenius/a")
+	c, err := isolation.Acquire("langgenius/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != a {
+		t.Fatalf("expected released uid %d, got %d", a, c)
+	}
+}
+
+func TestIsolate(t *testing.T) {
+	workingPath := path.Join(t.TempDir(), "plugins", "langgenius-a@checksum")
+	if err := os.MkdirAll(workingPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("true")
+	cmd.Env = []string{"PATH=/usr/bin", "SERVER_KEY=secret", "DB_PASSWORD=secret", "INSTALL_METHOD=local"}
+
+	uid := os.Getuid()
+	if err := isolate(cmd, workingPath, uid); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kv := range cmd.Env {
+		if kv == "SERVER_KEY=secret" || kv == "DB_PASSWORD=secret" {
+			t.Errorf("%s should not be passed to the plugin", kv)
+		}
+	}
+	if len(cmd.Env) != 4 || cmd.Env[0] != "PATH=/usr/bin" || cmd.Env[1] != "INSTALL_METHOD=local" {
+		t.Errorf("unexpected environment %v", cmd.Env)
+	}
+
+	if cmd.SysProcAttr == nil || cmd.SysProcAttr.Credential == nil || cmd.SysProcAttr.Credential.Uid != uint32(uid) {
+		t.Fatal("the plugin should run as the isolated user")
+	}
+
+	info, err := os.Stat(workingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o700 {
+		t.Errorf("expected the working directory to be private, got %s", info.Mode().Perm())
+	}
+
+	info, err = os.Stat(path.Dir(workingPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o711 {
+		t.Errorf("expected working directories not to be listable, got %s", info.Mode().Perm())
+	}
+
+	if _, err := os.Stat(path.Join(workingPath, ".tmp")); err != nil {
+		t.Errorf("expected a private temp directory: %s", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"sync"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/egress"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
@@ -84,6 +85,35 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 	// add env INSTALL_METHOD=local
 	e.Env = append(e.Environ(), "INSTALL_METHOD=local", "PATH="+os.Getenv("PATH"))
 
+	// outbound connections go through the egress proxy enforcing the network permission,
+	// replacing the proxy settings of the daemon which are used by the egress proxy instead
+	if egress.Enabled() {
+		proxy, release := egress.Register(r.Config.Identity(), r.Config.Resource.Permission)
+		defer release()
+		e.Env = append(e.Env,
+			"HTTP_PROXY="+proxy, "HTTPS_PROXY="+proxy, "http_proxy="+proxy, "https_proxy="+proxy,
+			"NO_PROXY=", "no_proxy=",
+		)
+	}
+
+	// the plugin runs as its own user owning nothing but its working directory
+	if r.userIsolation != nil {
+		pluginID := r.Config.Identity()
+		if identity, err := r.Identity(); err == nil {
+			pluginID = identity.PluginID()
+		}
+
+		uid, err := r.userIsolation.Acquire(pluginID)
+		if err != nil {
+			return err
+		}
+		defer r.userIsolation.Release(pluginID)
+
+		if err := isolate(e, r.State.WorkingPath, uid); err != nil {
+			return err
+		}
+	}
+
 	// get writer
 	stdin, err := e.StdinPipe()
 	if err != nil {
@@ -150,25 +180,31 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 	wg := sync.WaitGroup{}
 	wg.Add(2)
 
-	// listen to plugin stdout
-	routine.Submit(map[string]string{
-		"module":   "plugin_manager",
-		"type":     "local",
-		"function": "StartStdout",
+	// listen to plugin stdout, readers run on their own pool, the plugin is stopped once it's full
+	if err := routine.SubmitTo(routine.POOL_STDIO_READER, map[string]string{
+		"module":    "plugin_manager",
+		"type":      "local",
+		"function":  "StartStdout",
+		"plugin_id": r.Config.Identity(),
 	}, func() {
 		defer wg.Done()
 		stdio.StartStdout(func() {})
-	})
+	}); err != nil {
+		return fmt.Errorf("listen to stdout of plugin failed: %w", err)
+	}
 
 	// listen to plugin stderr
-	routine.Submit(map[string]string{
-		"module":   "plugin_manager",
-		"type":     "local",
-		"function": "StartStderr",
+	if err := routine.SubmitTo(routine.POOL_STDIO_READER, map[string]string{
+		"module":    "plugin_manager",
+		"type":      "local",
+		"function":  "StartStderr",
+		"plugin_id": r.Config.Identity(),
 	}, func() {
 		defer wg.Done()
 		stdio.StartStderr()
-	})
+	}); err != nil {
+		return fmt.Errorf("listen to stderr of plugin failed: %w", err)
+	}
 
 	// send started event
 	r.waitChanLock.Lock()
@@ -9,7 +9,11 @@ import (
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/fault"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -79,6 +83,8 @@ func (s *stdioHolder) StartStdout(notify_heartbeat func()) {
 	defer s.Stop()
 
 	scanner := bufio.NewScanner(s.reader)
+	pluginId := plugin_entities.PluginUniqueIdentifier(s.pluginUniqueIdentifier).PluginID()
+	logger := s.logger()
 
 	// TODO: set a reasonable buffer size or use a reader, this is a temporary solution
 	scanner.Buffer(make([]byte, 1024), 5*1024*1024)
@@ -93,10 +99,14 @@ func (s *stdioHolder) StartStdout(notify_heartbeat func()) {
 		// update the last active time on each time the plugin sends data
 		s.lastActiveAt = time.Now()
 
-		plugin_entities.ParsePluginUniversalEvent(
+		err := plugin_entities.ParsePluginUniversalEvent(
 			data,
 			"",
 			func(session_id string, data []byte) {
+				// events dropped by fault injection never reach their sessions
+				if fault.Inject(fault.POINT_PLUGIN_EVENT, s.pluginUniqueIdentifier) != nil {
+					return
+				}
 				for _, listener := range listeners {
 					listener(s.id, data)
 				}
@@ -118,27 +128,43 @@ func (s *stdioHolder) StartStdout(notify_heartbeat func()) {
 				}
 			},
 			func() {
+				logger.Debug("plugin %s: heartbeat", s.pluginUniqueIdentifier)
 				// notify launched
 				notify_heartbeat()
 			},
 			func(err string) {
-				log.Error("plugin %s: %s", s.pluginUniqueIdentifier, err)
+				logger.Error("plugin %s: %s", s.pluginUniqueIdentifier, redact.Any(err))
 			},
-			func(message string) {
-				log.Info("plugin %s: %s", s.pluginUniqueIdentifier, message)
+			func(sessionId string, event plugin_entities.PluginLogEvent) {
+				session_manager.LoggerOf(sessionId).
+					With(log.FIELD_COMPONENT, log.COMPONENT_STDIO).
+					With(log.FIELD_PLUGIN_ID, pluginId).
+					Log(event.Level, "plugin %s: %s", s.pluginUniqueIdentifier, redact.Session(sessionId, event.Message))
 			},
 		)
+		if err != nil {
+			metrics.StdioParseErrors.WithLabelValues(pluginId).Inc()
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Error("plugin %s has an error on stdout: %s", s.pluginUniqueIdentifier, err)
+		logger.Error("plugin %s has an error on stdout: %s", s.pluginUniqueIdentifier, err)
 	}
 }
 
+// logger returns the logger of the stdio component writing the plugin id
+func (s *stdioHolder) logger() *log.Logger {
+	return log.Component(log.COMPONENT_STDIO).With(
+		log.FIELD_PLUGIN_ID, plugin_entities.PluginUniqueIdentifier(s.pluginUniqueIdentifier).PluginID(),
+	)
+}
+
 // WriteError writes the error message to the stdio holder
 // it will keep the last 1024 bytes of the error message
+// secrets printed by the plugin are masked as the message is logged and reported
 func (s *stdioHolder) WriteError(msg string) {
 	const MAX_ERR_MSG_LEN = 1024
+	msg = redact.Any(msg)
 	reduce := len(msg) + len(s.errMessage) - MAX_ERR_MSG_LEN
 	if reduce > 0 {
 		if reduce > len(s.errMessage) {
@@ -155,8 +181,9 @@ func (s *stdioHolder) WriteError(msg string) {
 // StartStderr starts to read the stderr of the plugin
 // it will write the error message to the stdio holder
 func (s *stdioHolder) StartStderr() {
+	// WriteError copies what's read, so the buffer is reused by reads
+	buf := make([]byte, 1024)
 	for {
-		buf := make([]byte, 1024)
 		n, err := s.errReader.Read(buf)
 		if err != nil && err != io.EOF {
 			break
@@ -197,14 +224,14 @@ func (s *stdioHolder) Wait() error {
 		case <-ticker.C:
 			// check heartbeat
 			if time.Since(s.lastActiveAt) > 120*time.Second {
-				log.Error(
+				s.logger().Error(
 					"plugin %s is not active for 120 seconds, it may be dead, killing and restarting it",
 					s.pluginUniqueIdentifier,
 				)
 				return plugin_errors.ErrPluginNotActive
 			}
 			if time.Since(s.lastActiveAt) > 60*time.Second {
-				log.Warn(
+				s.logger().Warn(
 					"plugin %s is not active for %f seconds, it may be dead",
 					s.pluginUniqueIdentifier,
 					time.Since(s.lastActiveAt).Seconds(),
@@ -36,6 +36,9 @@ type LocalPluginRuntime struct {
 	HttpProxy  string
 	HttpsProxy string
 
+	// runs the plugin as a dedicated unprivileged user if it's set
+	userIsolation *UserIsolation
+
 	waitChanLock    sync.Mutex
 	waitStartedChan []chan bool
 	waitStoppedChan []chan bool
@@ -53,6 +56,7 @@ type LocalPluginRuntimeConfig struct {
 	PipPreferBinary           bool
 	PipVerbose                bool
 	PipExtraArgs              string
+	UserIsolation             *UserIsolation
 }
 
 func NewLocalPluginRuntime(config LocalPluginRuntimeConfig) *LocalPluginRuntime {
@@ -66,5 +70,6 @@ func NewLocalPluginRuntime(config LocalPluginRuntimeConfig) *LocalPluginRuntime
 		pipPreferBinary:              config.PipPreferBinary,
 		pipVerbose:                   config.PipVerbose,
 		pipExtraArgs:                 config.PipExtraArgs,
+		userIsolation:                config.UserIsolation,
 	}
 }
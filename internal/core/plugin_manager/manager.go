@@ -4,12 +4,18 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation/real"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
 	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_encryption"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
@@ -63,6 +69,9 @@ type PluginManager struct {
 	HttpProxy  string
 	HttpsProxy string
 
+	// local plugins run as dedicated unprivileged users if it's set
+	userIsolation *local_runtime.UserIsolation
+
 	// pip mirror url
 	pipMirrorUrl string
 
@@ -86,6 +95,41 @@ type PluginManager struct {
 
 	// platform, local or serverless
 	platform app.PlatformType
+
+	// nodeRole decides if plugins are run by this node, see app.NodeRole
+	nodeRole app.NodeRole
+
+	// plugins running from local source directories in dev mode
+	devPlugins mapping.Map[string, *devPlugin]
+
+	// interval to check changes of dev plugins
+	devWatchInterval time.Duration
+
+	// disk usage of plugins, see PluginStorageUsage
+	storageUsageCache mapping.Map[string, cachedStorageUsage]
+
+	// gcLock prevents garbage collections from running at the same time
+	gcLock sync.Mutex
+
+	// serverlessFailover routes serverless plugins to the local runtime on provider outages, nil if disabled
+	serverlessFailover *serverlessFailover
+
+	// serverlessRegions routes serverless invocations to the nearest healthy region, nil if regions are not set
+	serverlessRegions *serverlessRegionRouter
+
+	// placement decides which nodes run local plugins, every node runs all plugins if it's nil
+	placement     PluginPlacement
+	rebalanceLock sync.Mutex
+
+	// reconcileLock prevents reconciliations from running at the same time
+	reconcileLock sync.Mutex
+
+	// localPluginsLaunched is set once local plugins installed before the node started are launched
+	localPluginsLaunched atomic.Bool
+
+	// capacity of local plugins, 0 means unlimited, memory is in bytes
+	maxPlugins int
+	maxMemory  int64
 }
 
 var (
@@ -116,12 +160,28 @@ func InitGlobalManager(oss oss.OSS, configuration *app.Config) *PluginManager {
 		pythonEnvInitTimeout:      configuration.PythonEnvInitTimeout,
 		pythonCompileAllExtraArgs: configuration.PythonCompileAllExtraArgs,
 		platform:                  configuration.Platform,
+		nodeRole:                  configuration.NodeRole,
 		HttpProxy:                 configuration.HttpProxy,
 		HttpsProxy:                configuration.HttpsProxy,
 		pipMirrorUrl:              configuration.PipMirrorUrl,
 		pipPreferBinary:           *configuration.PipPreferBinary,
 		pipVerbose:                *configuration.PipVerbose,
 		pipExtraArgs:              configuration.PipExtraArgs,
+		devWatchInterval:          time.Duration(configuration.PluginDevWatchInterval) * time.Second,
+		maxPlugins:                configuration.NodeMaxPlugins,
+		maxMemory:                 configuration.NodeMaxMemoryMB * 1024 * 1024,
+	}
+
+	manager.mediaBucket.SetMaxAssetSize(configuration.PluginAssetMaxSize)
+
+	if configuration.PluginRunAsUserEnabled {
+		if os.Geteuid() != 0 {
+			log.Panic("running plugins as unprivileged users requires the daemon to run as root")
+		}
+		manager.userIsolation = local_runtime.NewUserIsolation(
+			configuration.PluginRunAsUserUIDBase,
+			configuration.PluginRunAsUserUIDCount,
+		)
 	}
 
 	return manager
@@ -141,6 +201,11 @@ func (p *PluginManager) Get(
 		}
 		return nil, errors.New("plugin not found")
 	} else {
+		// traffic is served by the local runtime while the provider is down
+		if lifetime, ok := p.serverlessFailoverLifetime(identity); ok {
+			return lifetime, nil
+		}
+
 		// otherwise, use serverless runtime instead
 		pluginSessionInterface, err := p.getServerlessPluginRuntime(identity)
 		if err != nil {
@@ -173,20 +238,87 @@ func (p *PluginManager) Launch(configuration *app.Config) {
 	if err != nil {
 		log.Panic("init dify invocation daemon failed: %s", err.Error())
 	}
-	p.backwardsInvocation = invocation
+	p.backwardsInvocation = tenant_encryption.Wrap(invocation)
 
-	// start local watcher
-	if configuration.Platform == app.PLATFORM_LOCAL {
+	// start local watcher, gateways forward invocations to runners so that they run no plugin
+	if configuration.Platform == app.PLATFORM_LOCAL && p.runsPlugins() {
 		p.startLocalWatcher()
+		p.watchInstalledPlugins()
 	}
 
 	// launch serverless connector
 	if configuration.Platform == app.PLATFORM_SERVERLESS {
 		serverless.Init(configuration)
+
+		serverless_runtime.InitTransport(serverless_runtime.TransportConfig{
+			MaxIdleConnsPerFunction: configuration.ServerlessMaxIdleConnsPerFunction,
+			MaxConnsPerFunction:     configuration.ServerlessMaxConnsPerFunction,
+			IdleConnTimeout:         time.Duration(configuration.ServerlessIdleConnTimeout) * time.Second,
+			KeepAlive:               time.Duration(configuration.ServerlessKeepAlive) * time.Second,
+			DialTimeout:             time.Duration(configuration.ServerlessDialTimeout) * time.Second,
+			ResponseIdleTimeout:     time.Duration(configuration.ServerlessResponseIdleTimeout) * time.Second,
+			RetryBudget:             float64(*configuration.ServerlessRetryBudgetPercent) / 100,
+			MaxRetries:              *configuration.ServerlessMaxRetries,
+		})
+
+		if err := serverless_runtime.InitAuth(serverless_runtime.AuthConfig{
+			ClientCertFile: configuration.ServerlessTLSClientCertFile,
+			ClientKeyFile:  configuration.ServerlessTLSClientKeyFile,
+			CAFile:         configuration.ServerlessTLSCAFile,
+			SigningKeyFile: configuration.ServerlessRequestSigningKeyFile,
+			ReloadInterval: time.Duration(configuration.ServerlessCredentialsReloadInterval) * time.Second,
+		}); err != nil {
+			log.Panic("init serverless authentication failed: %s", err.Error())
+		}
+
+		if configuration.ServerlessTelemetryEnabled != nil && *configuration.ServerlessTelemetryEnabled {
+			p.startServerlessTelemetry(
+				time.Duration(configuration.ServerlessTelemetryFlushInterval)*time.Second,
+				ServerlessPricing{
+					CostPerGBSecond:        configuration.ServerlessCostPerGBSecond,
+					CostPerMillionRequests: configuration.ServerlessCostPerMillionRequests,
+				},
+			)
+		}
+
+		if len(configuration.ServerlessRegions) > 0 {
+			p.serverlessRegions = newServerlessRegionRouter(ServerlessRegionConfig{
+				Regions:           configuration.ServerlessRegions,
+				FailureThreshold:  configuration.ServerlessRegionFailureThreshold,
+				UnhealthyCooldown: time.Duration(configuration.ServerlessRegionUnhealthyCooldown) * time.Second,
+			})
+		}
+
+		if configuration.ServerlessFailoverEnabled != nil && *configuration.ServerlessFailoverEnabled {
+			p.serverlessFailover = newServerlessFailover(ServerlessFailoverConfig{
+				Threshold:        configuration.ServerlessFailoverThreshold,
+				Window:           time.Duration(configuration.ServerlessFailoverWindow) * time.Second,
+				RecoveryInterval: time.Duration(configuration.ServerlessFailoverRecoveryInterval) * time.Second,
+				DrainTimeout:     time.Duration(configuration.ServerlessFailoverDrainTimeout) * time.Second,
+			})
+			p.startServerlessFailoverRecovery()
+		}
+
+		if configuration.ServerlessPrewarmEnabled != nil && *configuration.ServerlessPrewarmEnabled {
+			serverless_runtime.StartPrewarming(
+				time.Duration(configuration.ServerlessPrewarmInterval)*time.Second,
+				time.Duration(configuration.ServerlessPrewarmWarmWindow)*time.Second,
+			)
+		}
+	}
+
+	// start remote watcher, debugging plugins connect to runners only
+	if p.runsPlugins() {
+		p.startRemoteWatcher(configuration)
 	}
 
-	// start remote watcher
-	p.startRemoteWatcher(configuration)
+	// start garbage collector
+	if configuration.PluginGCEnabled != nil && *configuration.PluginGCEnabled {
+		p.startGarbageCollector(
+			time.Duration(configuration.PluginGCInterval)*time.Second,
+			time.Duration(configuration.PluginGCGracePeriod)*time.Second,
+		)
+	}
 }
 
 func (p *PluginManager) BackwardsInvocation() dify_invocation.BackwardsInvocation {
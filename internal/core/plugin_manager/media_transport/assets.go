@@ -122,3 +122,36 @@ func (m *MediaBucket) RemapAssets(declaration *plugin_entities.PluginDeclaration
 
 	return assetsIds, nil
 }
+
+// DeclarationAssets returns ids of the media files referenced by a remapped declaration
+func DeclarationAssets(declaration *plugin_entities.PluginDeclaration) []string {
+	ids := []string{}
+	add := func(id string) {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	if declaration.Model != nil {
+		for _, icon := range []*plugin_entities.I18nObject{declaration.Model.IconSmall, declaration.Model.IconLarge} {
+			if icon != nil {
+				add(icon.EnUS)
+				add(icon.ZhHans)
+				add(icon.JaJp)
+				add(icon.PtBr)
+			}
+		}
+	}
+
+	if declaration.Tool != nil {
+		add(declaration.Tool.Identity.Icon)
+	}
+
+	if declaration.AgentStrategy != nil {
+		add(declaration.AgentStrategy.Identity.Icon)
+	}
+
+	add(declaration.Icon)
+
+	return ids
+}
@@ -3,17 +3,30 @@ package media_transport
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"path"
 	"path/filepath"
+	"regexp"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 )
 
+var (
+	ErrInvalidAssetID = errors.New("invalid asset id")
+
+	// ids are sha256 checksums of the content followed by the extension of the file
+	assetIDPattern = regexp.MustCompile(`^[0-9a-f]{64}(\.[^/\\]*)?$`)
+)
+
 type MediaBucket struct {
 	oss       oss.OSS
 	cache     *lru.Cache[string, []byte]
 	mediaPath string
+
+	// assets larger than it are rejected, 0 means unlimited
+	maxAssetSize int64
 }
 
 func NewAssetsBucket(oss oss.OSS, media_path string, cache_size uint16) *MediaBucket {
@@ -23,8 +36,22 @@ func NewAssetsBucket(oss oss.OSS, media_path string, cache_size uint16) *MediaBu
 	return &MediaBucket{oss: oss, cache: cache, mediaPath: media_path}
 }
 
+// SetMaxAssetSize rejects assets larger than size on uploading, 0 means unlimited
+func (m *MediaBucket) SetMaxAssetSize(size int64) {
+	m.maxAssetSize = size
+}
+
+// ValidAssetID reports whether id is an id returned by Upload
+func ValidAssetID(id string) bool {
+	return assetIDPattern.MatchString(id)
+}
+
 // Upload uploads a file to the media manager and returns an identifier
 func (m *MediaBucket) Upload(name string, file []byte) (string, error) {
+	if m.maxAssetSize > 0 && int64(len(file)) > m.maxAssetSize {
+		return "", fmt.Errorf("asset %s exceeds the maximum size of %d bytes", name, m.maxAssetSize)
+	}
+
 	// calculate checksum
 	checksum := sha256.Sum256(append(file, []byte(name)...))
 
@@ -46,6 +73,10 @@ func (m *MediaBucket) Upload(name string, file []byte) (string, error) {
 }
 
 func (m *MediaBucket) Get(id string) ([]byte, error) {
+	if !ValidAssetID(id) {
+		return nil, ErrInvalidAssetID
+	}
+
 	// check if id is in cache
 	data, ok := m.cache.Get(id)
 	if ok {
@@ -73,3 +104,13 @@ func (m *MediaBucket) Delete(id string) error {
 	filePath := path.Join(m.mediaPath, id)
 	return m.oss.Delete(filePath)
 }
+
+// List lists ids of all the files in the media bucket
+func (m *MediaBucket) List() ([]string, error) {
+	return listBucket(m.oss, m.mediaPath)
+}
+
+// State returns the size and modification time of a file
+func (m *MediaBucket) State(id string) (oss.OSSState, error) {
+	return m.oss.State(path.Join(m.mediaPath, id))
+}
@@ -46,6 +46,13 @@ func (b *InstalledBucket) Get(
 	return b.oss.Load(filepath.Join(b.installedPath, plugin_unique_identifier.String()))
 }
 
+// State returns the size and modification time of the installed plugin
+func (b *InstalledBucket) State(
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+) (oss.OSSState, error) {
+	return b.oss.State(filepath.Join(b.installedPath, plugin_unique_identifier.String()))
+}
+
 // List lists all the plugins in the installed bucket
 func (b *InstalledBucket) List() ([]plugin_entities.PluginUniqueIdentifier, error) {
 	paths, err := b.oss.List(b.installedPath)
@@ -2,6 +2,7 @@ package media_transport
 
 import (
 	"path"
+	"strings"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 )
@@ -30,3 +31,30 @@ func (m *PackageBucket) Delete(name string) error {
 	// delete from storage
 	return m.oss.Delete(path.Join(m.packagePath, name))
 }
+
+// List lists names of all the packages in the package bucket
+func (m *PackageBucket) List() ([]string, error) {
+	return listBucket(m.oss, m.packagePath)
+}
+
+// State returns the size and modification time of a package
+func (m *PackageBucket) State(name string) (oss.OSSState, error) {
+	return m.oss.State(path.Join(m.packagePath, name))
+}
+
+// listBucket lists relative paths of all the files under the prefix
+func listBucket(storage oss.OSS, prefix string) ([]string, error) {
+	paths, err := storage.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, p := range paths {
+		if p.IsDir {
+			continue
+		}
+		names = append(names, strings.TrimPrefix(strings.TrimPrefix(p.Path, prefix), "/"))
+	}
+	return names, nil
+}
@@ -0,0 +1,64 @@
+package plugin_manager
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// PLUGIN_INSTALLED_CHANNEL notifies nodes running plugins to launch a plugin installed by another node,
+	// otherwise it's launched on the next scan of installed plugins
+	PLUGIN_INSTALLED_CHANNEL = "plugin-installed-channel"
+)
+
+type pluginInstalledEvent struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+}
+
+// runsPlugins returns false if the node is a gateway which forwards all invocations to runners
+func (p *PluginManager) runsPlugins() bool {
+	return p.nodeRole != app.NODE_ROLE_GATEWAY
+}
+
+// notifyPluginInstalled notifies other nodes to launch the plugin
+func (p *PluginManager) notifyPluginInstalled(identity plugin_entities.PluginUniqueIdentifier) {
+	if err := cache.Publish(PLUGIN_INSTALLED_CHANNEL, pluginInstalledEvent{
+		PluginUniqueIdentifier: identity,
+	}); err != nil {
+		log.Error("failed to notify plugin %s installed: %s", identity.String(), err.Error())
+	}
+}
+
+// watchInstalledPlugins launches plugins placed on the current node once they're installed by any node
+func (p *PluginManager) watchInstalledPlugins() {
+	events, _ := cache.Subscribe[pluginInstalledEvent](PLUGIN_INSTALLED_CHANNEL)
+	go func() {
+		for event := range events {
+			identity := event.PluginUniqueIdentifier
+			if !p.placedOnCurrentNode(identity) {
+				continue
+			}
+
+			routine.Submit(map[string]string{
+				"module":    "plugin_manager",
+				"function":  "watchInstalledPlugins",
+				"plugin_id": identity.String(),
+			}, func() {
+				_, launchedChan, errChan, err := p.launchLocal(identity)
+				if err != nil {
+					log.Error("launch installed plugin %s failed: %s", identity.String(), err.Error())
+					return
+				}
+
+				// consume error, avoid deadlock
+				for err := range errChan {
+					log.Error("plugin launch error: %s", err.Error())
+				}
+				<-launchedChan
+			})
+		}
+	}()
+}
@@ -0,0 +1,80 @@
+package plugin_manager
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// PluginPlacement decides which nodes run local plugins, it's implemented by the cluster
+type PluginPlacement interface {
+	// IsPluginPlacedOnCurrentNode returns true if the current node should run the plugin
+	IsPluginPlacedOnCurrentNode(identity plugin_entities.PluginUniqueIdentifier) bool
+	// IsPluginRunningOnPlacedNodes returns true if any other node the plugin is placed on is running it
+	IsPluginRunningOnPlacedNodes(identity plugin_entities.PluginUniqueIdentifier) (bool, error)
+}
+
+// SetPluginPlacement runs local plugins only on the nodes they're placed on, every node runs all plugins if unset
+func (p *PluginManager) SetPluginPlacement(placement PluginPlacement) {
+	p.placement = placement
+}
+
+func (p *PluginManager) placedOnCurrentNode(identity plugin_entities.PluginUniqueIdentifier) bool {
+	return p.placement == nil || p.placement.IsPluginPlacedOnCurrentNode(identity)
+}
+
+// RebalanceLocalPlugins launches plugins placed on the current node and stops the ones placed on other nodes,
+// it's called once nodes join or leave the cluster
+func (p *PluginManager) RebalanceLocalPlugins() {
+	if p.platform != app.PLATFORM_LOCAL || !p.runsPlugins() {
+		return
+	}
+
+	if !p.rebalanceLock.TryLock() {
+		return
+	}
+	defer p.rebalanceLock.Unlock()
+
+	p.handleNewLocalPlugins()
+	p.removeMisplacedLocalPlugins()
+}
+
+// removeMisplacedLocalPlugins stops local plugins placed on other nodes, a plugin keeps running until
+// one of the nodes it's placed on is running it so that it's always available during rebalancing
+func (p *PluginManager) removeMisplacedLocalPlugins() {
+	if p.placement == nil {
+		return
+	}
+
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		runtime, ok := value.(*local_runtime.LocalPluginRuntime)
+		if !ok {
+			return true
+		}
+
+		identity, err := runtime.Identity()
+		if err != nil {
+			log.Error("get plugin identity failed: %s", err.Error())
+			return true
+		}
+
+		// dev plugins run on the node they're developed on
+		if p.isDevPlugin(identity.String()) || p.placement.IsPluginPlacedOnCurrentNode(identity) {
+			return true
+		}
+
+		running, err := p.placement.IsPluginRunningOnPlacedNodes(identity)
+		if err != nil {
+			log.Error("check nodes running plugin %s failed: %s", identity.String(), err.Error())
+			return true
+		}
+
+		if running {
+			log.Info("stopping plugin %s which has moved to other nodes", identity.String())
+			runtime.Stop()
+		}
+
+		return true
+	})
+}
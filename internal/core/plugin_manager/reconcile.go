@@ -0,0 +1,158 @@
+package plugin_manager
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// plugins running but not installed in the database yet are kept for a while,
+	// the installation is recorded after the plugin is launched
+	reconcileOrphanGracePeriod = 10 * time.Minute
+)
+
+var (
+	ErrReconciliationRunning = errors.New("reconciliation is already running")
+)
+
+// ReconciliationReport is the drift between plugins installed in the database and the ones running on a node
+type ReconciliationReport struct {
+	NodeID       string    `json:"node_id"`
+	DryRun       bool      `json:"dry_run"`
+	ReconciledAt time.Time `json:"reconciled_at"`
+	// Missing are installed plugins placed on the node but not running on it
+	Missing []string `json:"missing"`
+	// Orphaned are plugins running on the node but no longer installed
+	Orphaned []string `json:"orphaned"`
+	// Launched and Removed are the plugins reconciled, always empty in dry run mode
+	Launched []string `json:"launched"`
+	Removed  []string `json:"removed"`
+	Errors   []string `json:"errors"`
+}
+
+// Drifted returns true if the node is not running exactly the plugins placed on it
+func (r *ReconciliationReport) Drifted() bool {
+	return len(r.Missing) > 0 || len(r.Orphaned) > 0
+}
+
+// ReconcileLocalPlugins compares local plugins running on the node against the installations in the database,
+// plugins missing are launched and restored from their packages if needed, orphaned ones are stopped
+func (p *PluginManager) ReconcileLocalPlugins(dryRun bool) (*ReconciliationReport, error) {
+	report := &ReconciliationReport{
+		DryRun:       dryRun,
+		ReconciledAt: time.Now(),
+		Missing:      []string{},
+		Orphaned:     []string{},
+		Launched:     []string{},
+		Removed:      []string{},
+		Errors:       []string{},
+	}
+	if p.platform != app.PLATFORM_LOCAL || !p.runsPlugins() {
+		return report, nil
+	}
+
+	if !p.reconcileLock.TryLock() {
+		return nil, ErrReconciliationRunning
+	}
+	defer p.reconcileLock.Unlock()
+
+	plugins, err := db.GetAll[models.Plugin](
+		db.Equal("install_type", string(plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]bool{}
+	for _, plugin := range plugins {
+		installed[plugin.PluginUniqueIdentifier] = true
+	}
+
+	running := map[string]*local_runtime.LocalPluginRuntime{}
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		if runtime, ok := value.(*local_runtime.LocalPluginRuntime); ok && !p.isDevPlugin(key) {
+			running[key] = runtime
+		}
+		return true
+	})
+
+	for identifier := range installed {
+		identity := plugin_entities.PluginUniqueIdentifier(identifier)
+		if _, ok := running[identifier]; ok || !p.placedOnCurrentNode(identity) {
+			continue
+		}
+
+		report.Missing = append(report.Missing, identifier)
+		if dryRun {
+			continue
+		}
+
+		if err := p.launchMissingPlugin(identity); err != nil {
+			log.Error("failed to launch missing plugin %s: %s", identifier, err.Error())
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.Launched = append(report.Launched, identifier)
+	}
+
+	for identifier, runtime := range running {
+		if installed[identifier] {
+			continue
+		}
+
+		state, err := p.installedBucket.State(plugin_entities.PluginUniqueIdentifier(identifier))
+		if err == nil && time.Since(state.LastModified) < reconcileOrphanGracePeriod {
+			continue
+		}
+
+		report.Orphaned = append(report.Orphaned, identifier)
+		if dryRun {
+			continue
+		}
+
+		log.Info("stopping plugin %s which is no longer installed", identifier)
+		runtime.Stop()
+		report.Removed = append(report.Removed, identifier)
+	}
+
+	slices.Sort(report.Missing)
+	slices.Sort(report.Orphaned)
+	return report, nil
+}
+
+// launchMissingPlugin restores the installed package from the uploaded one if it's lost, then launches the plugin
+func (p *PluginManager) launchMissingPlugin(identity plugin_entities.PluginUniqueIdentifier) error {
+	exists, err := p.installedBucket.Exists(identity)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		pkg, err := p.packageBucket.Get(identity.String())
+		if err != nil {
+			return errors.Join(err, errors.New("failed to read plugin package"))
+		}
+		if err := p.installedBucket.Save(identity, pkg); err != nil {
+			return err
+		}
+	}
+
+	_, launchedChan, errChan, err := p.launchLocal(identity)
+	if err != nil {
+		return err
+	}
+
+	var launchErr error
+	for err := range errChan {
+		launchErr = errors.Join(launchErr, err)
+	}
+	<-launchedChan
+	return launchErr
+}
@@ -0,0 +1,248 @@
+package plugin_manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+type PluginRepairStatus string
+
+const (
+	PluginRepairStatusOK     PluginRepairStatus = "ok"
+	PluginRepairStatusFixed  PluginRepairStatus = "fixed"
+	PluginRepairStatusFailed PluginRepairStatus = "failed"
+)
+
+type PluginRepairCheck struct {
+	Name    string             `json:"name"`
+	Status  PluginRepairStatus `json:"status"`
+	Message string             `json:"message"`
+}
+
+type PluginRepairReport struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	Checks                 []PluginRepairCheck                    `json:"checks"`
+	// Restarted is true if the running runtime was stopped to pick up the repaired files,
+	// the local watcher launches it again
+	Restarted bool `json:"restarted"`
+}
+
+func (r *PluginRepairReport) add(name string, status PluginRepairStatus, format string, args ...any) {
+	r.Checks = append(r.Checks, PluginRepairCheck{
+		Name:    name,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Fixed returns true if anything was fixed
+func (r *PluginRepairReport) Fixed() bool {
+	for _, check := range r.Checks {
+		if check.Status == PluginRepairStatusFixed {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns true if any check failed and could not be fixed
+func (r *PluginRepairReport) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == PluginRepairStatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// RepairLocalPlugin verifies the uploaded package, the installed package, the extracted working directory
+// and the python virtual environment of a local plugin, broken parts are restored from the uploaded package
+func (p *PluginManager) RepairLocalPlugin(
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+) (*PluginRepairReport, error) {
+	report := &PluginRepairReport{
+		PluginUniqueIdentifier: plugin_unique_identifier,
+		Checks:                 []PluginRepairCheck{},
+	}
+
+	// the uploaded package is the source of truth, nothing could be repaired without it
+	pkg, err := p.GetPackage(plugin_unique_identifier)
+	if err != nil {
+		report.add("package", PluginRepairStatusFailed, "%s", err.Error())
+		return report, nil
+	}
+
+	zipDecoder, err := decoder.NewZipPluginDecoder(pkg)
+	if err != nil {
+		report.add("package", PluginRepairStatusFailed, "failed to decode package: %s", err.Error())
+		return report, nil
+	}
+
+	checksum, err := zipDecoder.Checksum()
+	if err != nil {
+		return nil, errors.Join(err, errors.New("failed to calculate checksum"))
+	}
+
+	if checksum != plugin_unique_identifier.Checksum() {
+		report.add(
+			"package", PluginRepairStatusFailed,
+			"checksum mismatched, expected %s, got %s, please upload the package again",
+			plugin_unique_identifier.Checksum(), checksum,
+		)
+		return report, nil
+	}
+	report.add("package", PluginRepairStatusOK, "checksum verified")
+
+	// installed package
+	p.repairInstalledPackage(report, plugin_unique_identifier, pkg)
+
+	runtime, err := p.getLocalPluginRuntime(plugin_unique_identifier)
+	if err != nil {
+		report.add("working_directory", PluginRepairStatusFailed, "%s", err.Error())
+		return report, nil
+	}
+
+	workingPath := runtime.runtime.State.WorkingPath
+
+	p.localPluginLaunchingLock.Lock(plugin_unique_identifier.String())
+	workingDirectoryFixed := p.repairWorkingDirectory(report, zipDecoder, workingPath)
+	venvFixed := p.repairPythonEnvironment(report, runtime.runtime.Config, workingPath)
+	p.localPluginLaunchingLock.Unlock(plugin_unique_identifier.String())
+
+	if workingDirectoryFixed || venvFixed {
+		// stop the running runtime, the local watcher will launch it again with the repaired files
+		if lifetime, ok := p.m.Load(plugin_unique_identifier.String()); ok {
+			lifetime.Stop()
+			report.Restarted = true
+		}
+	}
+
+	return report, nil
+}
+
+func (p *PluginManager) repairInstalledPackage(
+	report *PluginRepairReport,
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	pkg []byte,
+) {
+	installed, err := p.installedBucket.Get(plugin_unique_identifier)
+	if err == nil && bytes.Equal(installed, pkg) {
+		report.add("installed_package", PluginRepairStatusOK, "installed package matches the uploaded package")
+		return
+	}
+
+	reason := "installed package is corrupted"
+	if err != nil {
+		reason = "installed package is missing"
+	}
+
+	if err := p.installedBucket.Save(plugin_unique_identifier, pkg); err != nil {
+		report.add("installed_package", PluginRepairStatusFailed, "%s, failed to restore: %s", reason, err.Error())
+		return
+	}
+
+	report.add("installed_package", PluginRepairStatusFixed, "%s, restored from the uploaded package", reason)
+}
+
+// repairWorkingDirectory rewrites missing or modified files in the working directory,
+// returns true if anything was rewritten
+func (p *PluginManager) repairWorkingDirectory(
+	report *PluginRepairReport,
+	zipDecoder *decoder.ZipPluginDecoder,
+	workingPath string,
+) bool {
+	if _, err := os.Stat(workingPath); os.IsNotExist(err) {
+		// extracted on next launch
+		report.add("working_directory", PluginRepairStatusOK, "not extracted yet, it will be extracted on next launch")
+		return false
+	}
+
+	repaired := []string{}
+	err := zipDecoder.Walk(func(filename, dir string) error {
+		if filename == "" {
+			return nil
+		}
+
+		expected, err := zipDecoder.ReadFile(filepath.Join(dir, filename))
+		if err != nil {
+			return err
+		}
+
+		target := path.Join(workingPath, dir, filename)
+		actual, err := os.ReadFile(target)
+		if err == nil && bytes.Equal(actual, expected) {
+			return nil
+		}
+
+		if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(target, expected, 0644); err != nil {
+			return err
+		}
+
+		repaired = append(repaired, path.Join(dir, filename))
+		return nil
+	})
+
+	if err != nil {
+		report.add("working_directory", PluginRepairStatusFailed, "failed to repair working directory: %s", err.Error())
+		return len(repaired) > 0
+	}
+
+	if len(repaired) == 0 {
+		report.add("working_directory", PluginRepairStatusOK, "all files are intact")
+		return false
+	}
+
+	log.Info("repaired %d files in %s", len(repaired), workingPath)
+	report.add("working_directory", PluginRepairStatusFixed, "restored %d missing or modified files: %v", len(repaired), repaired)
+	return true
+}
+
+// repairPythonEnvironment removes a broken virtual environment, it's rebuilt on next launch
+func (p *PluginManager) repairPythonEnvironment(
+	report *PluginRepairReport,
+	declaration plugin_entities.PluginDeclaration,
+	workingPath string,
+) bool {
+	if declaration.Meta.Runner.Language != constants.Python {
+		return false
+	}
+
+	venvPath := path.Join(workingPath, ".venv")
+	if _, err := os.Stat(venvPath); os.IsNotExist(err) {
+		report.add("python_environment", PluginRepairStatusOK, "not created yet, it will be created on next launch")
+		return false
+	}
+
+	broken := ""
+	if _, err := os.Stat(path.Join(venvPath, "dify/plugin.json")); err != nil {
+		broken = "virtual environment was not initialized completely"
+	} else if _, err := os.Stat(path.Join(venvPath, "bin/python")); err != nil {
+		broken = "python interpreter is missing"
+	}
+
+	if broken == "" {
+		report.add("python_environment", PluginRepairStatusOK, "virtual environment is intact")
+		return false
+	}
+
+	if err := os.RemoveAll(venvPath); err != nil {
+		report.add("python_environment", PluginRepairStatusFailed, "%s, failed to remove it: %s", broken, err.Error())
+		return false
+	}
+
+	report.add("python_environment", PluginRepairStatusFixed, "%s, it will be rebuilt on next launch", broken)
+	return true
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
@@ -38,8 +39,14 @@ func (p *PluginManager) fullDuplexLifecycle(
 
 	configuration := r.Configuration()
 	log.Info("new plugin logged in: %s", configuration.Identity())
+	publishLifecycle(r, func(identity plugin_entities.PluginUniqueIdentifier) events.Event {
+		return events.PluginStarted{PluginUniqueIdentifier: identity, RuntimeType: r.Type()}
+	})
 	defer func() {
 		log.Info("plugin %s has exited", configuration.Identity())
+		publishLifecycle(r, func(identity plugin_entities.PluginUniqueIdentifier) events.Event {
+			return events.PluginStopped{PluginUniqueIdentifier: identity, RuntimeType: r.Type()}
+		})
 	}()
 
 	// try to init environment until succeed
@@ -96,7 +103,8 @@ func (p *PluginManager) fullDuplexLifecycle(
 	// once succeed, we consider the plugin is installed successfully
 	for !r.Stopped() {
 		// start plugin
-		if err := r.StartPlugin(); err != nil {
+		exitErr := r.StartPlugin()
+		if exitErr != nil {
 			if r.Stopped() {
 				// plugin has been stopped, exit
 				break
@@ -109,6 +117,22 @@ func (p *PluginManager) fullDuplexLifecycle(
 			<-c
 		}
 
+		if !r.Stopped() && r.Type() == plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL {
+			// the plugin process exited without being stopped
+			stderr := ""
+			if exitErr != nil {
+				stderr = exitErr.Error()
+			}
+			publishLifecycle(r, func(identity plugin_entities.PluginUniqueIdentifier) events.Event {
+				return events.PluginCrashed{
+					PluginUniqueIdentifier: identity,
+					RuntimeType:            r.Type(),
+					Restarts:               r.RuntimeState().Restarts,
+					Stderr:                 stderr,
+				}
+			})
+		}
+
 		// restart plugin in 5s
 		time.Sleep(5 * time.Second)
 
@@ -116,3 +140,16 @@ func (p *PluginManager) fullDuplexLifecycle(
 		r.AddRestarts()
 	}
 }
+
+// publishLifecycle publishes the event built from the identity of the plugin, runtimes failing to
+// tell their identity publish nothing
+func publishLifecycle(
+	r plugin_entities.PluginFullDuplexLifetime,
+	build func(identity plugin_entities.PluginUniqueIdentifier) events.Event,
+) {
+	identity, err := r.Identity()
+	if err != nil {
+		return
+	}
+	events.Publish(build(identity))
+}
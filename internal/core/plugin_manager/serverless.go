@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
+	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
@@ -31,12 +32,25 @@ func (p *PluginManager) getServerlessPluginRuntime(
 		return nil, err
 	}
 
+	region, err := p.routeServerlessRegion(model)
+	if err != nil {
+		return nil, err
+	}
+
 	// FIXME: get declaration
 	declaration, err := helper.CombinedGetPluginDeclaration(identity, plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS)
 	if err != nil {
 		return nil, err
 	}
 
+	memoryMB := model.MemoryMB
+	if memoryMB == 0 {
+		// functions deployed before their memory was recorded are estimated by the declaration,
+		// resources beyond the limits are still returned and good enough for the estimation
+		resources, _ := serverless.ResolveFunctionResources(*declaration, nil)
+		memoryMB = resources.MemoryMB
+	}
+
 	// init runtime entity
 	runtimeEntity := plugin_entities.PluginRuntime{
 		Config: *declaration,
@@ -52,6 +66,17 @@ func (p *PluginManager) getServerlessPluginRuntime(
 		PluginRuntime: runtimeEntity,
 		LambdaURL:     model.FunctionURL,
 		LambdaName:    model.FunctionName,
+		MemoryMB:      memoryMB,
+	}
+
+	if p.serverlessFailover != nil || region != "" {
+		functionURL := model.FunctionURL
+		pluginRuntime.OnInvoked = func(providerFailure bool, duration time.Duration) {
+			if region != "" {
+				p.serverlessRegions.record(region, providerFailure, duration, time.Now())
+			}
+			p.onServerlessInvoked(identity, functionURL, providerFailure)
+		}
 	}
 
 	if err := pluginRuntime.InitEnvironment(); err != nil {
@@ -85,6 +110,10 @@ func (p *PluginManager) getServerlessPluginRuntimeModel(
 			return nil, fmt.Errorf("failed to load serverless runtime from db: %v", err)
 		}
 
+		if err := p.applyServerlessRuntimePin(&runtimeModel); err != nil {
+			return nil, err
+		}
+
 		cache.Store(p.getServerlessRuntimeCacheKey(identity), runtimeModel, time.Minute*30)
 		runtime = &runtimeModel
 	} else if err != nil {
@@ -3,27 +3,51 @@ package serverless
 import (
 	"net"
 	"net/http"
-	"net/url"
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 )
 
-var (
-	SERVERLESS_CONNECTOR_API_KEY string
-	baseurl                      *url.URL
-	client                       *http.Client
-)
-
 func Init(config *app.Config) {
 	var err error
-	baseurl, err = url.Parse(*config.DifyPluginServerlessConnectorURL)
+	switch config.ServerlessProvider {
+	case app.SERVERLESS_PROVIDER_HTTP:
+		provider, err = NewHTTPFunctionProvider(config.ServerlessHTTPDeployerURL, config.ServerlessHTTPDeployerAPIKey)
+	default:
+		provider, err = NewConnectorProvider(
+			*config.DifyPluginServerlessConnectorURL,
+			*config.DifyPluginServerlessConnectorAPIKey,
+		)
+	}
 	if err != nil {
-		log.Panic("Failed to parse serverless connector url", err)
+		log.Panic("Failed to init serverless provider %s: %s", config.ServerlessProvider, err.Error())
+	}
+
+	resourceLimits = ResourceLimits{
+		Default: FunctionResources{
+			TimeoutSeconds:     config.ServerlessDefaultTimeout,
+			EphemeralStorageMB: config.ServerlessDefaultEphemeralStorage,
+		},
+		Max: FunctionResources{
+			MemoryMB:           config.ServerlessMaxMemory,
+			TimeoutSeconds:     config.ServerlessMaxTimeout,
+			EphemeralStorageMB: config.ServerlessMaxEphemeralStorage,
+			Concurrency:        config.ServerlessMaxConcurrency,
+		},
+	}
+
+	regions = config.ServerlessRegions
+
+	if err := Ping(); err != nil {
+		log.Panic("Failed to ping serverless provider %s: %s", config.ServerlessProvider, err.Error())
 	}
 
-	client = &http.Client{
+	log.Info("Serverless provider %s initialized", config.ServerlessProvider)
+}
+
+func newClient() *http.Client {
+	return &http.Client{
 		Transport: &http.Transport{
 			Dial: (&net.Dialer{
 				Timeout:   5 * time.Second,   // how long a http connection can be alive before it's closed
@@ -32,12 +56,4 @@ func Init(config *app.Config) {
 			IdleConnTimeout: 120 * time.Second,
 		},
 	}
-
-	SERVERLESS_CONNECTOR_API_KEY = *config.DifyPluginServerlessConnectorAPIKey
-
-	if err := Ping(); err != nil {
-		log.Panic("Failed to ping serverless connector", err)
-	}
-
-	log.Info("Serverless connector initialized")
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/http_requests"
@@ -19,17 +20,37 @@ type ServerlessFunction struct {
 	FunctionURL  string `json:"function_url" validate:"required"`
 }
 
+// connectorProvider deploys plugins to AWS Lambda through the dify serverless connector
+type connectorProvider struct {
+	baseurl *url.URL
+	apiKey  string
+	client  *http.Client
+}
+
+func NewConnectorProvider(connectorURL string, apiKey string) (Provider, error) {
+	baseurl, err := url.Parse(connectorURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connectorProvider{
+		baseurl: baseurl,
+		apiKey:  apiKey,
+		client:  newClient(),
+	}, nil
+}
+
 // Ping the serverless connector, return error if failed
-func Ping() error {
-	url, err := url.JoinPath(baseurl.String(), "/ping")
+func (c *connectorProvider) Ping() error {
+	url, err := url.JoinPath(c.baseurl.String(), "/ping")
 	if err != nil {
 		return err
 	}
 	response, err := http_requests.PostAndParse[string](
-		client,
+		c.client,
 		url,
 		http_requests.HttpHeader(map[string]string{
-			"Authorization": SERVERLESS_CONNECTOR_API_KEY,
+			"Authorization": c.apiKey,
 		}),
 	)
 	if err != nil {
@@ -48,23 +69,27 @@ var (
 )
 
 // Fetch the function from serverless connector, return error if failed
-func FetchFunction(manifest plugin_entities.PluginDeclaration, checksum string) (*ServerlessFunction, error) {
+func (c *connectorProvider) FetchFunction(
+	manifest plugin_entities.PluginDeclaration,
+	checksum string,
+	region string,
+) (*ServerlessFunction, error) {
 	filename := getFunctionFilename(manifest, checksum)
 
-	url, err := url.JoinPath(baseurl.String(), "/v1/runner/instances")
+	url, err := url.JoinPath(c.baseurl.String(), "/v1/runner/instances")
 	if err != nil {
 		return nil, err
 	}
 
 	response, err := http_requests.GetAndParse[RunnerInstances](
-		client,
+		c.client,
 		url,
 		http_requests.HttpHeader(map[string]string{
-			"Authorization": SERVERLESS_CONNECTOR_API_KEY,
+			"Authorization": c.apiKey,
 		}),
-		http_requests.HttpParams(map[string]string{
+		http_requests.HttpParams(regionFields(region, map[string]string{
 			"filename": filename,
-		}),
+		})),
 	)
 
 	if err != nil {
@@ -96,42 +121,55 @@ const (
 	Done        LaunchFunctionEvent = "done"
 )
 
+// LaunchFunctionStage is the stage of deploying a function, it's reported along with info events
+type LaunchFunctionStage string
+
+const (
+	StageUpload    LaunchFunctionStage = "upload"
+	StageBuild     LaunchFunctionStage = "build"
+	StageProvision LaunchFunctionStage = "provision"
+	StageReady     LaunchFunctionStage = "ready"
+)
+
 type LaunchFunctionResponse struct {
 	Event   LaunchFunctionEvent `json:"event"`
+	Stage   LaunchFunctionStage `json:"stage,omitempty"`
 	Message string              `json:"message"`
 }
 
 // Setup the function from serverless connector, it will receive the context as the input
 // and build it a docker image, then run it on serverless platform like AWS Lambda
 // it returns a event stream, the caller should consider it as a async operation
-func SetupFunction(
+func (c *connectorProvider) SetupFunction(
 	manifest plugin_entities.PluginDeclaration,
 	checksum string,
+	resources FunctionResources,
+	region string,
 	context io.Reader,
 ) (*stream.Stream[LaunchFunctionResponse], error) {
-	url, err := url.JoinPath(baseurl.String(), "/v1/launch")
+	url, err := url.JoinPath(c.baseurl.String(), "/v1/launch")
 	if err != nil {
 		return nil, err
 	}
 
+	fields := regionFields(region, resources.fields())
+	if manifest.Verified {
+		fields["verified"] = "true"
+	} else {
+		fields["verified"] = "false"
+	}
+
 	// join a filename
 	serverless_connector_response, err := http_requests.PostAndParseStream[LaunchFunctionResponseChunk](
-		client,
+		c.client,
 		url,
 		http_requests.HttpHeader(map[string]string{
-			"Authorization": SERVERLESS_CONNECTOR_API_KEY,
+			"Authorization": c.apiKey,
 		}),
 		http_requests.HttpReadTimeout(240000),
 		http_requests.HttpWriteTimeout(240000),
 		http_requests.HttpPayloadMultipart(
-			map[string]string{
-				"verified": func() string {
-					if manifest.Verified {
-						return "true"
-					}
-					return "false"
-				}(),
-			},
+			fields,
 			map[string]http_requests.HttpPayloadMultipartFile{
 				"context": {
 					Filename: getFunctionFilename(manifest, checksum),
@@ -161,9 +199,16 @@ func SetupFunction(
 			}
 
 			switch chunk.Stage {
-			case LAUNCH_STAGE_START, LAUNCH_STAGE_BUILD:
+			case LAUNCH_STAGE_START:
 				response.Write(LaunchFunctionResponse{
 					Event:   Info,
+					Stage:   StageUpload,
+					Message: "Uploading plugin...",
+				})
+			case LAUNCH_STAGE_BUILD:
+				response.Write(LaunchFunctionResponse{
+					Event:   Info,
+					Stage:   StageBuild,
 					Message: "Building plugin...",
 				})
 			case LAUNCH_STAGE_RUN:
@@ -188,10 +233,16 @@ func SetupFunction(
 				} else {
 					response.Write(LaunchFunctionResponse{
 						Event:   Info,
+						Stage:   StageProvision,
 						Message: "Launching plugin...",
 					})
 				}
 			case LAUNCH_STAGE_END:
+				response.Write(LaunchFunctionResponse{
+					Event:   Info,
+					Stage:   StageReady,
+					Message: "Plugin launched",
+				})
 				response.Write(LaunchFunctionResponse{
 					Event:   Done,
 					Message: "Plugin launched",
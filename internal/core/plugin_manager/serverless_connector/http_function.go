@@ -0,0 +1,247 @@
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// building and provisioning a function takes minutes
+	httpFunctionSetupTimeout = 600 * time.Second
+	httpFunctionFetchTimeout = 30 * time.Second
+)
+
+// httpFunctionProvider deploys plugins through a deployer of HTTP functions, it fits platforms like Knative
+// or Cloud Run where a function is a container serving HTTP, the deployer implements:
+//
+//	GET  /ping                     responds 200 once it's available
+//	GET  /functions?filename=...   responds {"name": "...", "url": "..."}, or 404 if it's not deployed
+//	POST /functions                multipart with a file `context` and a field `verified`, builds and deploys
+//	                               the package, responds {"name": "...", "url": "..."} once the function is ready,
+//	                               resources are sent as optional fields `memory` and `ephemeral_storage` in MB,
+//	                               `timeout` in seconds and `concurrency`
+//
+// both functions APIs take an optional `region`, the deployer decides the default region if it's not sent
+//
+// the deployed function serves the same invoke API as the AWS Lambda runner
+type httpFunctionProvider struct {
+	baseurl *url.URL
+	apiKey  string
+	client  *http.Client
+}
+
+type httpFunction struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+func NewHTTPFunctionProvider(deployerURL string, apiKey string) (Provider, error) {
+	baseurl, err := url.Parse(deployerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if baseurl.Scheme != "http" && baseurl.Scheme != "https" {
+		return nil, fmt.Errorf("invalid deployer url: %s", deployerURL)
+	}
+
+	return &httpFunctionProvider{
+		baseurl: baseurl,
+		apiKey:  apiKey,
+		client:  newClient(),
+	}, nil
+}
+
+func (h *httpFunctionProvider) request(
+	ctx context.Context,
+	method string,
+	path string,
+	query url.Values,
+	contentType string,
+	body io.Reader,
+) (*http.Response, error) {
+	u := h.baseurl.JoinPath(path)
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	return h.client.Do(req)
+}
+
+// parseFunction parses the function from the response of the deployer
+func parseFunction(response *http.Response) (*ServerlessFunction, error) {
+	defer response.Body.Close()
+
+	var function httpFunction
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1024*1024)).Decode(&function); err != nil {
+		return nil, fmt.Errorf("unexpected response from deployer with status %d: %v", response.StatusCode, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("deployer responded with status %d: %s", response.StatusCode, function.Error)
+	}
+
+	if function.Name == "" || function.URL == "" {
+		return nil, fmt.Errorf("deployer responded without function name or url")
+	}
+
+	return &ServerlessFunction{
+		FunctionName: function.Name,
+		FunctionDRN:  function.Name,
+		FunctionURL:  function.URL,
+	}, nil
+}
+
+func (h *httpFunctionProvider) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpFunctionFetchTimeout)
+	defer cancel()
+
+	response, err := h.request(ctx, http.MethodGet, "/ping", nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from deployer: %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+func (h *httpFunctionProvider) FetchFunction(
+	manifest plugin_entities.PluginDeclaration,
+	checksum string,
+	region string,
+) (*ServerlessFunction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpFunctionFetchTimeout)
+	defer cancel()
+
+	query := url.Values{}
+	for name, value := range regionFields(region, map[string]string{
+		"filename": getFunctionFilename(manifest, checksum),
+	}) {
+		query.Set(name, value)
+	}
+
+	response, err := h.request(ctx, http.MethodGet, "/functions", query, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrFunctionNotFound
+	}
+
+	return parseFunction(response)
+}
+
+func (h *httpFunctionProvider) SetupFunction(
+	manifest plugin_entities.PluginDeclaration,
+	checksum string,
+	resources FunctionResources,
+	region string,
+	pkg io.Reader,
+) (*stream.Stream[LaunchFunctionResponse], error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	fields := regionFields(region, resources.fields())
+	fields["verified"] = strconv.FormatBool(manifest.Verified)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := writer.CreateFormFile("context", getFunctionFilename(manifest, checksum))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, pkg); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	response := stream.NewStream[LaunchFunctionResponse](8)
+	response.Write(LaunchFunctionResponse{
+		Event:   Info,
+		Stage:   StageUpload,
+		Message: "Uploading plugin...",
+	})
+
+	routine.Submit(map[string]string{
+		"module": "serverless_connector",
+		"func":   "SetupFunction",
+	}, func() {
+		defer response.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), httpFunctionSetupTimeout)
+		defer cancel()
+
+		// the deployer builds and provisions the function within the request
+		resp, err := h.request(ctx, http.MethodPost, "/functions", nil, writer.FormDataContentType(), body)
+		if err == nil {
+			response.Write(LaunchFunctionResponse{
+				Event:   Info,
+				Stage:   StageProvision,
+				Message: "Launching plugin...",
+			})
+			var function *ServerlessFunction
+			function, err = parseFunction(resp)
+			if err == nil {
+				response.Write(LaunchFunctionResponse{
+					Event:   Function,
+					Message: function.FunctionName,
+				})
+				response.Write(LaunchFunctionResponse{
+					Event:   FunctionUrl,
+					Message: function.FunctionURL,
+				})
+				response.Write(LaunchFunctionResponse{
+					Event:   Info,
+					Stage:   StageReady,
+					Message: "Plugin launched",
+				})
+				response.Write(LaunchFunctionResponse{
+					Event:   Done,
+					Message: "Plugin launched",
+				})
+				return
+			}
+		}
+
+		response.Write(LaunchFunctionResponse{
+			Event:   Error,
+			Message: err.Error(),
+		})
+	})
+
+	return response, nil
+}
@@ -0,0 +1,140 @@
+package serverless
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func newTestDeployer(t *testing.T) *httptest.Server {
+	deployed := map[string]bool{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/ping":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/functions" && r.Method == http.MethodGet:
+			filename := r.URL.Query().Get("filename")
+			if !deployed[filename+"@"+r.URL.Query().Get("region")] {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"error": "not found"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"name": "neko", "url": "http://neko.functions"})
+		case r.URL.Path == "/functions" && r.Method == http.MethodPost:
+			file, header, err := r.FormFile("context")
+			if err != nil {
+				t.Errorf("missing package: %v", err)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			if string(content) != "package" || r.FormValue("verified") != "false" {
+				t.Errorf("unexpected deployment, content: %s, verified: %s", content, r.FormValue("verified"))
+			}
+			if r.FormValue("memory") != "512" || r.FormValue("timeout") != "60" || r.FormValue("concurrency") != "" {
+				t.Errorf("unexpected resources: %v", r.MultipartForm.Value)
+			}
+			deployed[header.Filename+"@"+r.FormValue("region")] = true
+			json.NewEncoder(w).Encode(map[string]string{"name": "neko", "url": "http://neko.functions"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestHTTPFunctionProvider(t *testing.T) {
+	routine.InitPool(1024)
+
+	server := newTestDeployer(t)
+	defer server.Close()
+
+	provider, err := NewHTTPFunctionProvider(server.URL, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := plugin_entities.PluginDeclaration{
+		PluginDeclarationWithoutAdvancedFields: plugin_entities.PluginDeclarationWithoutAdvancedFields{
+			Author:  "langgenius",
+			Name:    "neko",
+			Version: manifest_entities.Version("0.0.1"),
+		},
+	}
+
+	if _, err := provider.FetchFunction(manifest, "checksum", "eu-west-1"); err != ErrFunctionNotFound {
+		t.Fatalf("expected function not found, got %v", err)
+	}
+
+	response, err := provider.SetupFunction(
+		manifest, "checksum", FunctionResources{MemoryMB: 512, TimeoutSeconds: 60}, "eu-west-1", strings.NewReader("package"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []LaunchFunctionEvent{}
+	stages := []LaunchFunctionStage{}
+	functionURL := ""
+	response.Async(func(r LaunchFunctionResponse) {
+		events = append(events, r.Event)
+		if r.Stage != "" {
+			stages = append(stages, r.Stage)
+		}
+		if r.Event == FunctionUrl {
+			functionURL = r.Message
+		}
+		if r.Event == Error {
+			t.Errorf("unexpected error: %s", r.Message)
+		}
+	})
+
+	if len(events) == 0 || events[len(events)-1] != Done || functionURL != "http://neko.functions" {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	if !reflect.DeepEqual(stages, []LaunchFunctionStage{StageUpload, StageProvision, StageReady}) {
+		t.Fatalf("unexpected stages: %v", stages)
+	}
+
+	function, err := provider.FetchFunction(manifest, "checksum", "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if function.FunctionName != "neko" || function.FunctionURL != "http://neko.functions" {
+		t.Fatalf("unexpected function: %+v", function)
+	}
+
+	// functions are deployed per region
+	if _, err := provider.FetchFunction(manifest, "checksum", ""); err != ErrFunctionNotFound {
+		t.Fatalf("expected function not found in the default region, got %v", err)
+	}
+}
+
+func TestHTTPFunctionProviderUnauthorized(t *testing.T) {
+	server := newTestDeployer(t)
+	defer server.Close()
+
+	provider, err := NewHTTPFunctionProvider(server.URL, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.Ping(); err == nil {
+		t.Fatal("ping should fail with a wrong api key")
+	}
+}
@@ -13,33 +13,49 @@ var (
 	AWS_LAUNCH_LOCK_PREFIX = "aws_launch_lock_"
 )
 
-// LaunchPlugin uploads the plugin to specific serverless connector
+// LaunchPlugin uploads the plugin to specific serverless connector, an empty region means the default one
 // return the function url and name
-func LaunchPlugin(originPackage []byte, decoder decoder.PluginDecoder) (*stream.Stream[LaunchFunctionResponse], error) {
+func LaunchPlugin(
+	originPackage []byte,
+	decoder decoder.PluginDecoder,
+	resources FunctionResources,
+	region string,
+) (*stream.Stream[LaunchFunctionResponse], error) {
 	checksum, err := decoder.Checksum()
 	if err != nil {
 		return nil, err
 	}
 
 	// check if the plugin has already been initialized, at most 300s
-	if err := cache.Lock(AWS_LAUNCH_LOCK_PREFIX+checksum, 300*time.Second, 300*time.Second); err != nil {
+	lockKey := AWS_LAUNCH_LOCK_PREFIX + checksum
+	if region != "" {
+		lockKey += "_" + region
+	}
+	if err := cache.Lock(lockKey, 300*time.Second, 300*time.Second); err != nil {
 		return nil, err
 	}
-	defer cache.Unlock(AWS_LAUNCH_LOCK_PREFIX + checksum)
+	defer cache.Unlock(lockKey)
 
 	manifest, err := decoder.Manifest()
 	if err != nil {
 		return nil, err
 	}
 
-	function, err := FetchFunction(manifest, checksum)
+	// the lock is kept on the package, deployments of it with different resources are serialized
+	checksum = functionChecksum(checksum, resources)
+	function, err := FetchFunction(manifest, checksum, region)
 	if err != nil {
 		if err != ErrFunctionNotFound {
 			return nil, err
 		}
 	} else {
 		// found, return directly
-		response := stream.NewStream[LaunchFunctionResponse](3)
+		response := stream.NewStream[LaunchFunctionResponse](4)
+		response.Write(LaunchFunctionResponse{
+			Event:   Info,
+			Stage:   StageReady,
+			Message: "Plugin already launched",
+		})
 		response.Write(LaunchFunctionResponse{
 			Event:   FunctionUrl,
 			Message: function.FunctionURL,
@@ -56,7 +72,7 @@ func LaunchPlugin(originPackage []byte, decoder decoder.PluginDecoder) (*stream.
 		return response, nil
 	}
 
-	response, err := SetupFunction(manifest, checksum, bytes.NewReader(originPackage))
+	response, err := SetupFunction(manifest, checksum, resources, region, bytes.NewReader(originPackage))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,52 @@
+package serverless
+
+import (
+	"io"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// Provider deploys plugin packages as serverless functions, the deployed functions are invoked over HTTP
+// by serverless_runtime, so a provider only takes care of deploying and looking up functions
+type Provider interface {
+	// Ping checks if the provider is available
+	Ping() error
+	// FetchFunction returns the deployed function of the plugin, ErrFunctionNotFound if it's not deployed yet,
+	// an empty region means the default region of the provider
+	FetchFunction(manifest plugin_entities.PluginDeclaration, checksum string, region string) (*ServerlessFunction, error)
+	// SetupFunction deploys the plugin package as a function with the resources to the region, it returns
+	// an event stream which ends with a Done event once the function is ready
+	SetupFunction(
+		manifest plugin_entities.PluginDeclaration,
+		checksum string,
+		resources FunctionResources,
+		region string,
+		context io.Reader,
+	) (*stream.Stream[LaunchFunctionResponse], error)
+}
+
+var (
+	provider Provider
+)
+
+// Ping the serverless provider, return error if failed
+func Ping() error {
+	return provider.Ping()
+}
+
+// Fetch the function from serverless provider, return error if failed
+func FetchFunction(manifest plugin_entities.PluginDeclaration, checksum string, region string) (*ServerlessFunction, error) {
+	return provider.FetchFunction(manifest, checksum, region)
+}
+
+// Setup the function on serverless provider, it's an async operation, the caller should consume the event stream
+func SetupFunction(
+	manifest plugin_entities.PluginDeclaration,
+	checksum string,
+	resources FunctionResources,
+	region string,
+	context io.Reader,
+) (*stream.Stream[LaunchFunctionResponse], error) {
+	return provider.SetupFunction(manifest, checksum, resources, region, context)
+}
@@ -0,0 +1,24 @@
+package serverless
+
+var (
+	// regions functions are deployed to, the nearest one of the node comes first, empty if functions are
+	// deployed to the default region of the provider only
+	regions []string
+)
+
+// Regions returns the regions functions are deployed to, ordered by preference of this node,
+// a single empty region is returned if regional deployment is disabled
+func Regions() []string {
+	if len(regions) == 0 {
+		return []string{""}
+	}
+	return regions
+}
+
+// regionFields adds the region to the fields sent to the provider if it's set
+func regionFields(region string, fields map[string]string) map[string]string {
+	if region != "" {
+		fields["region"] = region
+	}
+	return fields
+}
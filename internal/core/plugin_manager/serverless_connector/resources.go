@@ -0,0 +1,143 @@
+package serverless
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// the smallest memory serverless platforms accept, plugins requiring less are raised to it
+	minFunctionMemoryMB = 128
+)
+
+var (
+	ErrFunctionResourcesExceeded = errors.New("serverless resources exceed the limits")
+)
+
+// FunctionResources are the resources of a serverless function, 0 means the default of the provider
+type FunctionResources struct {
+	MemoryMB           int64 `json:"memory_mb"`
+	TimeoutSeconds     int   `json:"timeout_seconds"`
+	EphemeralStorageMB int64 `json:"ephemeral_storage_mb"`
+	// Concurrency is the max concurrent executions of the function, 0 means unlimited
+	Concurrency int `json:"concurrency"`
+}
+
+// ResourceLimits are defined by the operator, Default is used if neither the manifest nor the override
+// specifies a resource, resources beyond Max are refused, a Max of 0 means no limit
+type ResourceLimits struct {
+	Default FunctionResources `json:"default"`
+	Max     FunctionResources `json:"max"`
+}
+
+var (
+	resourceLimits ResourceLimits
+)
+
+// GetResourceLimits returns the limits of serverless resources set by the operator
+func GetResourceLimits() ResourceLimits {
+	return resourceLimits
+}
+
+// fields are sent along with the package on deploying, empty ones are left to the provider
+func (r FunctionResources) fields() map[string]string {
+	fields := map[string]string{}
+	if r.MemoryMB > 0 {
+		fields["memory"] = strconv.FormatInt(r.MemoryMB, 10)
+	}
+	if r.TimeoutSeconds > 0 {
+		fields["timeout"] = strconv.Itoa(r.TimeoutSeconds)
+	}
+	if r.EphemeralStorageMB > 0 {
+		fields["ephemeral_storage"] = strconv.FormatInt(r.EphemeralStorageMB, 10)
+	}
+	if r.Concurrency > 0 {
+		fields["concurrency"] = strconv.Itoa(r.Concurrency)
+	}
+	return fields
+}
+
+// functionChecksum identifies the function of the package deployed with the resources, the same package
+// deployed with other resources is another function, so changed resources are never served by the old one
+func functionChecksum(checksum string, resources FunctionResources) string {
+	if resources == (FunctionResources{}) {
+		return checksum
+	}
+
+	digest := sha256.Sum256([]byte(fmt.Sprintf(
+		"%d:%d:%d:%d",
+		resources.MemoryMB, resources.TimeoutSeconds, resources.EphemeralStorageMB, resources.Concurrency,
+	)))
+	return checksum + "-" + hex.EncodeToString(digest[:4])
+}
+
+// ResolveFunctionResources merges the resources required by the manifest with the override of the operator,
+// the override takes precedence, the result is validated against the limits
+func ResolveFunctionResources(
+	manifest plugin_entities.PluginDeclaration,
+	override *FunctionResources,
+) (FunctionResources, error) {
+	resources := resourceLimits.Default
+
+	if manifest.Resource.Memory > 0 {
+		resources.MemoryMB = (manifest.Resource.Memory + 1024*1024 - 1) / 1024 / 1024
+	}
+	if requirement := manifest.Resource.Serverless; requirement != nil {
+		if requirement.Timeout > 0 {
+			resources.TimeoutSeconds = requirement.Timeout
+		}
+		if requirement.EphemeralStorage > 0 {
+			resources.EphemeralStorageMB = (requirement.EphemeralStorage + 1024*1024 - 1) / 1024 / 1024
+		}
+		if requirement.Concurrency > 0 {
+			resources.Concurrency = requirement.Concurrency
+		}
+	}
+
+	if override != nil {
+		if override.MemoryMB > 0 {
+			resources.MemoryMB = override.MemoryMB
+		}
+		if override.TimeoutSeconds > 0 {
+			resources.TimeoutSeconds = override.TimeoutSeconds
+		}
+		if override.EphemeralStorageMB > 0 {
+			resources.EphemeralStorageMB = override.EphemeralStorageMB
+		}
+		if override.Concurrency > 0 {
+			resources.Concurrency = override.Concurrency
+		}
+	}
+
+	if resources.MemoryMB > 0 && resources.MemoryMB < minFunctionMemoryMB {
+		resources.MemoryMB = minFunctionMemoryMB
+	}
+	// unlimited concurrency is not allowed once the operator limits it
+	if resources.Concurrency == 0 {
+		resources.Concurrency = resourceLimits.Max.Concurrency
+	}
+
+	return resources, resources.Validate(resourceLimits.Max)
+}
+
+// Validate returns ErrFunctionResourcesExceeded if any resource is beyond max
+func (r FunctionResources) Validate(max FunctionResources) error {
+	exceeded := func(name string, value int64, limit int64) error {
+		if limit > 0 && value > limit {
+			return errors.Join(ErrFunctionResourcesExceeded, fmt.Errorf("%s %d exceeds the limit %d", name, value, limit))
+		}
+		return nil
+	}
+
+	return errors.Join(
+		exceeded("memory", r.MemoryMB, max.MemoryMB),
+		exceeded("timeout", int64(r.TimeoutSeconds), int64(max.TimeoutSeconds)),
+		exceeded("ephemeral storage", r.EphemeralStorageMB, max.EphemeralStorageMB),
+		exceeded("concurrency", int64(r.Concurrency), int64(max.Concurrency)),
+	)
+}
@@ -0,0 +1,61 @@
+package serverless
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestResolveFunctionResources(t *testing.T) {
+	resourceLimits = ResourceLimits{
+		Default: FunctionResources{TimeoutSeconds: 300, EphemeralStorageMB: 512},
+		Max:     FunctionResources{MemoryMB: 2048, TimeoutSeconds: 900, EphemeralStorageMB: 4096, Concurrency: 10},
+	}
+	defer func() { resourceLimits = ResourceLimits{} }()
+
+	manifest := plugin_entities.PluginDeclaration{}
+	manifest.Resource.Memory = 1048576
+	manifest.Resource.Serverless = &plugin_entities.PluginServerlessRequirement{
+		Timeout:          600,
+		EphemeralStorage: 1024 * 1024 * 1024,
+	}
+
+	resources, err := ResolveFunctionResources(manifest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := FunctionResources{MemoryMB: 128, TimeoutSeconds: 600, EphemeralStorageMB: 1024, Concurrency: 10}
+	if resources != expected {
+		t.Fatalf("unexpected resources: %+v", resources)
+	}
+
+	resources, err = ResolveFunctionResources(manifest, &FunctionResources{MemoryMB: 1024, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = FunctionResources{MemoryMB: 1024, TimeoutSeconds: 600, EphemeralStorageMB: 1024, Concurrency: 2}
+	if resources != expected {
+		t.Fatalf("unexpected resources: %+v", resources)
+	}
+
+	manifest.Resource.Memory = 4096 * 1024 * 1024
+	if _, err := ResolveFunctionResources(manifest, nil); !errors.Is(err, ErrFunctionResourcesExceeded) {
+		t.Fatalf("expected resources to exceed the limits, got %v", err)
+	}
+}
+
+func TestFunctionChecksum(t *testing.T) {
+	if checksum := functionChecksum("checksum", FunctionResources{}); checksum != "checksum" {
+		t.Fatalf("functions without resources should be identified by the package, got %s", checksum)
+	}
+
+	small := functionChecksum("checksum", FunctionResources{MemoryMB: 128})
+	large := functionChecksum("checksum", FunctionResources{MemoryMB: 1024})
+	if small == large {
+		t.Fatal("functions with different resources should be identified differently")
+	}
+	if small != functionChecksum("checksum", FunctionResources{MemoryMB: 128}) {
+		t.Fatal("functions with the same resources should be identified the same")
+	}
+}
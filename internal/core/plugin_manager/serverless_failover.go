@@ -0,0 +1,317 @@
+package plugin_manager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	serverlessFailoverLaunchTimeout = 240 * time.Second
+	serverlessFailoverProbeTimeout  = 10 * time.Second
+)
+
+// ServerlessFailoverConfig decides when traffic of a serverless plugin is routed to the local runtime,
+// it fails over once Threshold provider failures happen within Window, and flips back once the provider
+// recovers, the local runtime is stopped after DrainTimeout to let ongoing sessions finish
+type ServerlessFailoverConfig struct {
+	Threshold        int
+	Window           time.Duration
+	RecoveryInterval time.Duration
+	DrainTimeout     time.Duration
+}
+
+type ServerlessFailoverStatus struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	// Active is set once traffic is routed to the local runtime
+	Active         bool      `json:"active"`
+	Launching      bool      `json:"launching"`
+	RecentFailures int       `json:"recent_failures"`
+	Since          time.Time `json:"since"`
+	LastError      string    `json:"last_error"`
+}
+
+type serverlessFailoverState struct {
+	failures    []time.Time
+	functionURL string
+	active      bool
+	launching   bool
+	since       time.Time
+	lastError   string
+}
+
+type serverlessFailover struct {
+	mu      sync.Mutex
+	config  ServerlessFailoverConfig
+	plugins map[plugin_entities.PluginUniqueIdentifier]*serverlessFailoverState
+}
+
+func newServerlessFailover(config ServerlessFailoverConfig) *serverlessFailover {
+	return &serverlessFailover{
+		config:  config,
+		plugins: map[plugin_entities.PluginUniqueIdentifier]*serverlessFailoverState{},
+	}
+}
+
+// record tracks the result of an invocation, it returns true if the plugin should fail over now
+func (f *serverlessFailover) record(
+	identity plugin_entities.PluginUniqueIdentifier,
+	functionURL string,
+	providerFailure bool,
+	now time.Time,
+) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, ok := f.plugins[identity]
+	if !providerFailure {
+		if ok && !state.active && !state.launching {
+			delete(f.plugins, identity)
+		}
+		return false
+	}
+
+	if !ok {
+		state = &serverlessFailoverState{}
+		f.plugins[identity] = state
+	}
+	state.functionURL = functionURL
+
+	// drop failures out of the window
+	state.failures = append(state.failures, now)
+	for len(state.failures) > 0 && now.Sub(state.failures[0]) > f.config.Window {
+		state.failures = state.failures[1:]
+	}
+
+	if state.active || state.launching || len(state.failures) < f.config.Threshold {
+		return false
+	}
+
+	state.launching = true
+	return true
+}
+
+func (f *serverlessFailover) launched(identity plugin_entities.PluginUniqueIdentifier, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, ok := f.plugins[identity]
+	if !ok {
+		return
+	}
+
+	state.launching = false
+	state.failures = nil
+	if err != nil {
+		state.lastError = err.Error()
+		return
+	}
+	state.active = true
+	state.since = time.Now()
+	state.lastError = ""
+}
+
+func (f *serverlessFailover) active(identity plugin_entities.PluginUniqueIdentifier) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, ok := f.plugins[identity]
+	return ok && state.active
+}
+
+// activePlugins returns plugins routed to the local runtime along with their function urls
+func (f *serverlessFailover) activePlugins() map[plugin_entities.PluginUniqueIdentifier]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := map[plugin_entities.PluginUniqueIdentifier]string{}
+	for identity, state := range f.plugins {
+		if state.active {
+			result[identity] = state.functionURL
+		}
+	}
+	return result
+}
+
+func (f *serverlessFailover) recovered(identity plugin_entities.PluginUniqueIdentifier) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.plugins, identity)
+}
+
+func (f *serverlessFailover) status() []ServerlessFailoverStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := []ServerlessFailoverStatus{}
+	for identity, state := range f.plugins {
+		result = append(result, ServerlessFailoverStatus{
+			PluginUniqueIdentifier: identity,
+			Active:                 state.active,
+			Launching:              state.launching,
+			RecentFailures:         len(state.failures),
+			Since:                  state.since,
+			LastError:              state.lastError,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PluginUniqueIdentifier < result[j].PluginUniqueIdentifier
+	})
+	return result
+}
+
+// probe checks if the function is served again, any response below 500 means the platform is able to run it
+func (f *serverlessFailover) probe(functionURL string) error {
+	if err := serverless.Ping(); err != nil {
+		return err
+	}
+
+	statusCode, err := serverless_runtime.ProbeFunction(functionURL, serverlessFailoverProbeTimeout)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("function responded with status %d", statusCode)
+	}
+	return nil
+}
+
+// supportsLocalRuntime checks if the plugin is able to run on this node
+func supportsLocalRuntime(declaration *plugin_entities.PluginDeclaration) bool {
+	return declaration.Meta.Runner.Language == constants.Python &&
+		slices.Contains(declaration.Meta.Arch, constants.Arch(runtime.GOARCH))
+}
+
+// onServerlessInvoked is called once an invocation of a serverless plugin ends
+func (p *PluginManager) onServerlessInvoked(
+	identity plugin_entities.PluginUniqueIdentifier,
+	functionURL string,
+	providerFailure bool,
+) {
+	if p.serverlessFailover == nil {
+		return
+	}
+
+	if p.serverlessFailover.record(identity, functionURL, providerFailure, time.Now()) {
+		log.Warn("serverless plugin %s keeps failing, failing over to local runtime", identity.String())
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"function": "FailoverToLocal",
+		}, func() {
+			err := p.failoverToLocal(identity)
+			if err != nil {
+				log.Error("failed to fail over plugin %s to local runtime: %s", identity.String(), err.Error())
+			}
+			p.serverlessFailover.launched(identity, err)
+		})
+	}
+}
+
+// failoverToLocal launches the serverless plugin in the local runtime
+func (p *PluginManager) failoverToLocal(identity plugin_entities.PluginUniqueIdentifier) error {
+	// serverless plugins are not installed on this node, so put the package in place first
+	exists, err := p.installedBucket.Exists(identity)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		pkg, err := p.GetPackage(identity)
+		if err != nil {
+			return errors.Join(err, errors.New("failed to read plugin package"))
+		}
+		if err := p.installedBucket.Save(identity, pkg); err != nil {
+			return err
+		}
+	}
+
+	plugin, err := p.getLocalPluginRuntime(identity)
+	if err != nil {
+		return err
+	}
+	if !supportsLocalRuntime(&plugin.runtime.Config) {
+		return fmt.Errorf("plugin does not support running on %s", runtime.GOARCH)
+	}
+
+	lifetime, launchedChan, errChan, err := p.launchLocal(identity)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(serverlessFailoverLaunchTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-launchedChan:
+			return nil
+		case err := <-errChan:
+			if err != nil {
+				lifetime.Stop()
+				return err
+			}
+		case <-timer.C:
+			lifetime.Stop()
+			return errors.New("timeout launching plugin in local runtime")
+		}
+	}
+}
+
+// serverlessFailoverLifetime returns the local runtime of the plugin if its traffic has been failed over
+func (p *PluginManager) serverlessFailoverLifetime(
+	identity plugin_entities.PluginUniqueIdentifier,
+) (plugin_entities.PluginLifetime, bool) {
+	if p.serverlessFailover == nil || !p.serverlessFailover.active(identity) {
+		return nil, false
+	}
+
+	return p.m.Load(identity.String())
+}
+
+// startServerlessFailoverRecovery probes failed over plugins periodically, traffic is routed back to
+// the serverless function once it's served again
+func (p *PluginManager) startServerlessFailoverRecovery() {
+	go func() {
+		for range time.NewTicker(p.serverlessFailover.config.RecoveryInterval).C {
+			for identity, functionURL := range p.serverlessFailover.activePlugins() {
+				if err := p.serverlessFailover.probe(functionURL); err != nil {
+					continue
+				}
+
+				log.Info("serverless plugin %s recovered, routing traffic back", identity.String())
+				p.serverlessFailover.recovered(identity)
+
+				identity := identity
+				time.AfterFunc(p.serverlessFailover.config.DrainTimeout, func() {
+					// the plugin may fail over again before draining
+					if p.serverlessFailover.active(identity) {
+						return
+					}
+					if lifetime, ok := p.m.Load(identity.String()); ok {
+						lifetime.Stop()
+					}
+				})
+			}
+		}
+	}()
+}
+
+// ServerlessFailoverStatus returns plugins failing or failed over to the local runtime on this node
+func (p *PluginManager) ServerlessFailoverStatus() []ServerlessFailoverStatus {
+	if p.serverlessFailover == nil {
+		return []ServerlessFailoverStatus{}
+	}
+	return p.serverlessFailover.status()
+}
@@ -0,0 +1,81 @@
+package plugin_manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestServerlessFailoverThreshold(t *testing.T) {
+	identity := plugin_entities.PluginUniqueIdentifier(
+		"langgenius/openai:0.0.1@1234567890123456789012345678901234567890123456789012345678901234",
+	)
+	failover := newServerlessFailover(ServerlessFailoverConfig{
+		Threshold: 3,
+		Window:    time.Minute,
+	})
+
+	now := time.Now()
+	// failures out of the window are not counted
+	failover.record(identity, "http://function", true, now.Add(-2*time.Minute))
+	if failover.record(identity, "http://function", true, now) {
+		t.Fatal("failed over before reaching the threshold")
+	}
+
+	// a success resets failures
+	failover.record(identity, "http://function", false, now)
+	if len(failover.status()) != 0 {
+		t.Fatal("failures are not reset by a success")
+	}
+
+	failover.record(identity, "http://function", true, now)
+	failover.record(identity, "http://function", true, now)
+	if !failover.record(identity, "http://function", true, now) {
+		t.Fatal("not failed over after reaching the threshold")
+	}
+	if failover.record(identity, "http://function", true, now) {
+		t.Fatal("failed over again while launching")
+	}
+
+	failover.launched(identity, nil)
+	if !failover.active(identity) {
+		t.Fatal("not active after launching")
+	}
+	// successes of ongoing serverless invocations don't flip it back
+	failover.record(identity, "http://function", false, now)
+	if urls := failover.activePlugins(); urls[identity] != "http://function" {
+		t.Fatalf("unexpected active plugins: %v", urls)
+	}
+
+	failover.recovered(identity)
+	if failover.active(identity) {
+		t.Fatal("still active after recovering")
+	}
+}
+
+func TestServerlessFailoverLaunchFailed(t *testing.T) {
+	identity := plugin_entities.PluginUniqueIdentifier(
+		"langgenius/openai:0.0.1@1234567890123456789012345678901234567890123456789012345678901234",
+	)
+	failover := newServerlessFailover(ServerlessFailoverConfig{
+		Threshold: 1,
+		Window:    time.Minute,
+	})
+
+	if !failover.record(identity, "http://function", true, time.Now()) {
+		t.Fatal("not failed over after reaching the threshold")
+	}
+	failover.launched(identity, errors.New("unsupported"))
+
+	status := failover.status()
+	if len(status) != 1 || status[0].Active || status[0].LastError != "unsupported" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	// it retries once the threshold is reached again
+	if !failover.record(identity, "http://function", true, time.Now()) {
+		t.Fatal("not retried after a failed launch")
+	}
+}
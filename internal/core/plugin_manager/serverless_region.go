@@ -0,0 +1,223 @@
+package plugin_manager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	PLUGIN_SERVERLESS_REGIONS_CACHE_KEY = "serverless:regions:%s"
+
+	// weight of the latest invocation in the average latency of a region
+	serverlessRegionLatencyWeight = 0.2
+)
+
+func getServerlessRegionsCacheKey(identity plugin_entities.PluginUniqueIdentifier) string {
+	return fmt.Sprintf(PLUGIN_SERVERLESS_REGIONS_CACHE_KEY, identity.String())
+}
+
+// ServerlessRegionConfig decides which region invocations of this node are routed to, Regions are
+// ordered by preference, a region is skipped for UnhealthyCooldown once FailureThreshold invocations
+// to it failed in a row, after that it's tried again
+type ServerlessRegionConfig struct {
+	Regions           []string
+	FailureThreshold  int
+	UnhealthyCooldown time.Duration
+}
+
+type ServerlessRegionStatus struct {
+	Region              string    `json:"region"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	UnhealthyUntil      time.Time `json:"unhealthy_until"`
+	Invocations         int64     `json:"invocations"`
+	Failures            int64     `json:"failures"`
+	// AverageLatencyMs is a moving average of invocations succeeded
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+}
+
+type serverlessRegionHealth struct {
+	consecutiveFailures int
+	unhealthyUntil      time.Time
+	invocations         int64
+	failures            int64
+	averageLatencyMs    float64
+}
+
+type serverlessRegionRouter struct {
+	mu     sync.Mutex
+	config ServerlessRegionConfig
+	health map[string]*serverlessRegionHealth
+}
+
+// regionalFunctions is cached as a struct since the cache stores objects
+type regionalFunctions struct {
+	Functions []models.ServerlessRegionalFunction `json:"functions"`
+}
+
+func newServerlessRegionRouter(config ServerlessRegionConfig) *serverlessRegionRouter {
+	health := map[string]*serverlessRegionHealth{}
+	for _, region := range config.Regions {
+		health[region] = &serverlessRegionHealth{}
+	}
+	return &serverlessRegionRouter{config: config, health: health}
+}
+
+// pick returns the function of the most preferred healthy region, if all regions are unhealthy
+// the one recovering first is returned, false is returned if the plugin is not deployed to any region
+func (r *serverlessRegionRouter) pick(
+	functions []models.ServerlessRegionalFunction,
+	now time.Time,
+) (models.ServerlessRegionalFunction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byRegion := map[string]models.ServerlessRegionalFunction{}
+	for _, function := range functions {
+		byRegion[function.Region] = function
+	}
+
+	var fallback *models.ServerlessRegionalFunction
+	var fallbackUntil time.Time
+	for _, region := range r.config.Regions {
+		function, ok := byRegion[region]
+		if !ok {
+			continue
+		}
+
+		health := r.health[region]
+		if !now.Before(health.unhealthyUntil) {
+			return function, true
+		}
+		if fallback == nil || health.unhealthyUntil.Before(fallbackUntil) {
+			fallback = &function
+			fallbackUntil = health.unhealthyUntil
+		}
+	}
+
+	if fallback == nil {
+		return models.ServerlessRegionalFunction{}, false
+	}
+	return *fallback, true
+}
+
+// record tracks the result of an invocation to the region
+func (r *serverlessRegionRouter) record(region string, providerFailure bool, duration time.Duration, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	health, ok := r.health[region]
+	if !ok {
+		return
+	}
+
+	health.invocations++
+	if !providerFailure {
+		health.consecutiveFailures = 0
+		health.unhealthyUntil = time.Time{}
+		latency := float64(duration.Milliseconds())
+		if health.averageLatencyMs == 0 {
+			health.averageLatencyMs = latency
+		} else {
+			health.averageLatencyMs += (latency - health.averageLatencyMs) * serverlessRegionLatencyWeight
+		}
+		return
+	}
+
+	health.failures++
+	health.consecutiveFailures++
+	if health.consecutiveFailures >= r.config.FailureThreshold {
+		health.unhealthyUntil = now.Add(r.config.UnhealthyCooldown)
+	}
+}
+
+func (r *serverlessRegionRouter) status(now time.Time) []ServerlessRegionStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []ServerlessRegionStatus{}
+	for _, region := range r.config.Regions {
+		health := r.health[region]
+		result = append(result, ServerlessRegionStatus{
+			Region:              region,
+			Healthy:             !now.Before(health.unhealthyUntil),
+			ConsecutiveFailures: health.consecutiveFailures,
+			UnhealthyUntil:      health.unhealthyUntil,
+			Invocations:         health.invocations,
+			Failures:            health.failures,
+			AverageLatencyMs:    health.averageLatencyMs,
+		})
+	}
+	return result
+}
+
+// getServerlessRegionalFunctions returns functions of the plugin in all regions,
+// functions of the pinned version are returned if the plugin is pinned
+func (p *PluginManager) getServerlessRegionalFunctions(
+	identity plugin_entities.PluginUniqueIdentifier,
+) ([]models.ServerlessRegionalFunction, error) {
+	cached, err := cache.Get[regionalFunctions](getServerlessRegionsCacheKey(identity))
+	if err == nil {
+		return cached.Functions, nil
+	} else if err != cache.ErrNotFound {
+		return nil, fmt.Errorf("unexpected error occurred during fetch serverless regions cache: %v", err)
+	}
+
+	target := identity.String()
+	pin, err := db.GetOne[models.ServerlessRuntimePin](
+		db.Equal("plugin_unique_identifier", identity.String()),
+	)
+	if err == nil {
+		target = pin.TargetPluginUniqueIdentifier
+	} else if err != db.ErrDatabaseNotFound {
+		return nil, fmt.Errorf("failed to load serverless runtime pin from db: %v", err)
+	}
+
+	functions, err := db.GetAll[models.ServerlessRegionalFunction](
+		db.Equal("plugin_unique_identifier", target),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load serverless regional functions from db: %v", err)
+	}
+
+	cache.Store(getServerlessRegionsCacheKey(identity), regionalFunctions{Functions: functions}, time.Minute*30)
+	return functions, nil
+}
+
+// routeServerlessRegion replaces the function of the runtime with the one in the region picked for this node,
+// it returns the picked region, the runtime is untouched if the plugin is not deployed to any region
+func (p *PluginManager) routeServerlessRegion(runtime *models.ServerlessRuntime) (string, error) {
+	if p.serverlessRegions == nil {
+		return "", nil
+	}
+
+	functions, err := p.getServerlessRegionalFunctions(
+		plugin_entities.PluginUniqueIdentifier(runtime.PluginUniqueIdentifier),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	function, ok := p.serverlessRegions.pick(functions, time.Now())
+	if !ok {
+		return "", nil
+	}
+
+	runtime.FunctionURL = function.FunctionURL
+	runtime.FunctionName = function.FunctionName
+	return function.Region, nil
+}
+
+// ServerlessRegionStatus returns health of the regions seen by this node
+func (p *PluginManager) ServerlessRegionStatus() []ServerlessRegionStatus {
+	if p.serverlessRegions == nil {
+		return []ServerlessRegionStatus{}
+	}
+	return p.serverlessRegions.status(time.Now())
+}
@@ -0,0 +1,58 @@
+package plugin_manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func TestServerlessRegionRouter(t *testing.T) {
+	router := newServerlessRegionRouter(ServerlessRegionConfig{
+		Regions:           []string{"eu-west-1", "us-east-1", "ap-northeast-1"},
+		FailureThreshold:  2,
+		UnhealthyCooldown: time.Minute,
+	})
+
+	functions := []models.ServerlessRegionalFunction{
+		{Region: "us-east-1", FunctionURL: "http://us"},
+		{Region: "eu-west-1", FunctionURL: "http://eu"},
+	}
+
+	now := time.Now()
+	if function, _ := router.pick(functions, now); function.Region != "eu-west-1" {
+		t.Fatalf("expected the preferred region, got %s", function.Region)
+	}
+
+	// a single failure doesn't make the region unhealthy
+	router.record("eu-west-1", true, time.Second, now)
+	if function, _ := router.pick(functions, now); function.Region != "eu-west-1" {
+		t.Fatalf("expected the preferred region, got %s", function.Region)
+	}
+
+	router.record("eu-west-1", true, time.Second, now)
+	if function, _ := router.pick(functions, now); function.Region != "us-east-1" {
+		t.Fatalf("expected failing over to the next region, got %s", function.Region)
+	}
+
+	// the first recovering region is used if all regions are unhealthy
+	router.record("us-east-1", true, time.Second, now.Add(time.Second))
+	router.record("us-east-1", true, time.Second, now.Add(time.Second))
+	if function, _ := router.pick(functions, now.Add(time.Second)); function.Region != "eu-west-1" {
+		t.Fatalf("expected the first recovering region, got %s", function.Region)
+	}
+
+	// it's tried again after the cooldown
+	if function, _ := router.pick(functions, now.Add(2*time.Minute)); function.Region != "eu-west-1" {
+		t.Fatalf("expected the preferred region after cooldown, got %s", function.Region)
+	}
+	router.record("us-east-1", false, 100*time.Millisecond, now)
+	status := router.status(now.Add(time.Second))
+	if !status[1].Healthy || status[1].AverageLatencyMs != 100 || status[1].Failures != 2 {
+		t.Fatalf("unexpected status: %+v", status[1])
+	}
+
+	if _, ok := router.pick([]models.ServerlessRegionalFunction{{Region: "sa-east-1"}}, now); ok {
+		t.Fatal("functions out of configured regions should not be picked")
+	}
+}
@@ -0,0 +1,247 @@
+package serverless_runtime
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	HEADER_SIGNATURE_KEY_ID    = "Dify-Plugin-Key-ID"
+	HEADER_SIGNATURE_TIMESTAMP = "Dify-Plugin-Timestamp"
+	HEADER_SIGNATURE           = "Dify-Plugin-Signature"
+)
+
+// AuthConfig authenticates the daemon to serverless functions so that they're not able to be invoked
+// by anyone else even if their urls leak, either or both of mTLS and signed requests can be enabled.
+// Files are reloaded once they change, certificates and keys are rotated without restarting the daemon
+type AuthConfig struct {
+	// a client certificate is presented to functions if both files are set
+	ClientCertFile string
+	ClientKeyFile  string
+	// CAFile verifies certificates of functions, system roots are used if it's empty
+	CAFile string
+	// SigningKeyFile has a key per line formatted as `id:secret`, requests are signed by the first one,
+	// the others are kept so that functions are able to be switched to a new key before the old one is dropped
+	SigningKeyFile string
+	// ReloadInterval is how often files are checked for changes
+	ReloadInterval time.Duration
+}
+
+type signingKey struct {
+	id     string
+	secret []byte
+}
+
+// credentials are loaded from the files of AuthConfig
+type credentials struct {
+	tlsConfig  *tls.Config
+	signingKey *signingKey
+	// modTimes of the loaded files, used to detect changes
+	modTimes map[string]time.Time
+}
+
+func (c AuthConfig) files() []string {
+	files := []string{}
+	for _, file := range []string{c.ClientCertFile, c.ClientKeyFile, c.CAFile, c.SigningKeyFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func (c AuthConfig) load() (*credentials, error) {
+	result := &credentials{modTimes: map[string]time.Time{}}
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		result.modTimes[file] = info.ModTime()
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" || c.CAFile != "" {
+		result.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		if c.ClientCertFile == "" || c.ClientKeyFile == "" {
+			return nil, errors.New("both client certificate and key are required for mTLS")
+		}
+		certificate, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, errors.Join(err, errors.New("failed to load client certificate"))
+		}
+		result.tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if c.CAFile != "" {
+		ca, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CAFile)
+		}
+		result.tlsConfig.RootCAs = pool
+	}
+
+	if c.SigningKeyFile != "" {
+		key, err := loadSigningKey(c.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		result.signingKey = key
+	}
+
+	return result, nil
+}
+
+// loadSigningKey returns the first key of the file, empty lines and lines starting with # are ignored
+func loadSigningKey(file string) (*signingKey, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(line, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("invalid signing key in %s, expected `id:secret`", file)
+		}
+		return &signingKey{id: id, secret: []byte(secret)}, nil
+	}
+
+	return nil, fmt.Errorf("no signing key found in %s", file)
+}
+
+// changed returns true if any file is modified since the credentials were loaded
+func (c *credentials) changed(config AuthConfig) bool {
+	for _, file := range config.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// keep the loaded credentials while a file is being replaced
+			continue
+		}
+		if !info.ModTime().Equal(c.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// signRequest signs the timestamp, session and body of the request, functions are expected to
+// verify the signature and refuse requests with a timestamp too far from their clock
+func signRequest(req *http.Request, key *signingKey, sessionId string, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(HEADER_SIGNATURE_KEY_ID, key.id)
+	req.Header.Set(HEADER_SIGNATURE_TIMESTAMP, timestamp)
+	req.Header.Set(HEADER_SIGNATURE, requestSignature(key.secret, timestamp, sessionId, body))
+}
+
+// requestSignature is hex(hmac_sha256(secret, timestamp + "\n" + session_id + "\n" + hex(sha256(body))))
+func requestSignature(secret []byte, timestamp string, sessionId string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "\n" + sessionId + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type authenticator struct {
+	mu          sync.RWMutex
+	config      AuthConfig
+	credentials *credentials
+	// onRotated is called once credentials are reloaded, connections made by old ones should be dropped
+	onRotated func()
+}
+
+func (a *authenticator) current() *credentials {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.credentials
+}
+
+func (a *authenticator) reload() error {
+	a.mu.RLock()
+	config := a.config
+	current := a.credentials
+	a.mu.RUnlock()
+
+	if current != nil && !current.changed(config) {
+		return nil
+	}
+
+	loaded, err := config.load()
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.credentials = loaded
+	a.mu.Unlock()
+
+	if current != nil {
+		log.Info("serverless credentials rotated")
+		if a.onRotated != nil {
+			a.onRotated()
+		}
+	}
+	return nil
+}
+
+func (a *authenticator) watch() {
+	go func() {
+		for range time.NewTicker(a.config.ReloadInterval).C {
+			if err := a.reload(); err != nil {
+				log.Error("failed to reload serverless credentials, the current ones are kept: %s", err.Error())
+			}
+		}
+	}()
+}
+
+// InitAuth loads the credentials used to authenticate to serverless functions and watches them for rotation
+func InitAuth(config AuthConfig) error {
+	if len(config.files()) == 0 {
+		return nil
+	}
+
+	auth := &authenticator{
+		config:    config,
+		onRotated: functionTransports.reset,
+	}
+	if err := auth.reload(); err != nil {
+		return err
+	}
+
+	functionTransports.mu.Lock()
+	functionTransports.auth = auth
+	functionTransports.mu.Unlock()
+	functionTransports.reset()
+
+	if config.ReloadInterval > 0 {
+		auth.watch()
+	}
+	return nil
+}
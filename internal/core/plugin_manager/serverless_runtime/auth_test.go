@@ -0,0 +1,100 @@
+package serverless_runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSignRequest(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(keyFile, []byte("# rotated on 2024-01-01\nkey-2:new-secret\nkey-1:old-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := newTestTransportPool(0.1)
+	pool.auth = &authenticator{config: AuthConfig{SigningKeyFile: keyFile}}
+	if err := pool.auth.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"event":"invoke"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HEADER_SIGNATURE_KEY_ID) != "key-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		expected := requestSignature([]byte("new-secret"), r.Header.Get(HEADER_SIGNATURE_TIMESTAMP), "session", body)
+		if r.Header.Get(HEADER_SIGNATURE) != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.sign(req, "session", body)
+
+	response, err := pool.client(server.URL).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("signature refused with status %d", response.StatusCode)
+	}
+}
+
+func TestAuthRotation(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(keyFile, []byte("key-1:old-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := 0
+	auth := &authenticator{
+		config:    AuthConfig{SigningKeyFile: keyFile},
+		onRotated: func() { rotated++ },
+	}
+	if err := auth.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// unchanged files are not reloaded
+	if err := auth.reload(); err != nil || rotated != 0 {
+		t.Fatalf("unexpected reload: rotated %d, err %v", rotated, err)
+	}
+
+	if err := os.WriteFile(keyFile, []byte("key-2:new-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(keyFile, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if rotated != 1 || auth.current().signingKey.id != "key-2" {
+		t.Fatalf("key not rotated: rotated %d, key %s", rotated, auth.current().signingKey.id)
+	}
+
+	// invalid keys are refused and the current ones are kept
+	if err := os.WriteFile(keyFile, []byte("invalid\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(keyFile, time.Now(), time.Now().Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.reload(); err == nil {
+		t.Fatal("invalid key accepted")
+	}
+	if auth.current().signingKey.id != "key-2" {
+		t.Fatal("current key dropped after a failed reload")
+	}
+}
@@ -2,24 +2,13 @@ package serverless_runtime
 
 import (
 	"fmt"
-	"net"
-	"net/http"
-	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
 func (r *AWSPluginRuntime) InitEnvironment() error {
-	// init http client
-	r.client = &http.Client{
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout:   5 * time.Second,
-				KeepAlive: 120 * time.Second,
-			}).Dial,
-			IdleConnTimeout: 120 * time.Second,
-		},
-	}
+	// init http client, it's shared by all runtimes of the function
+	r.client = functionTransports.client(r.LambdaURL)
 
 	return nil
 }
@@ -0,0 +1,95 @@
+package serverless_runtime
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+const (
+	// a single event carries a whole chunk of a tool output, it's way larger than a chunk of LLM tokens
+	maxEventSize = 64 * 1024 * 1024
+)
+
+var (
+	ErrEventTooLarge = errors.New("event from serverless function is too large")
+)
+
+// eventReader reads events from a chunked response of a serverless function as soon as they arrive,
+// events are separated by new lines, both plain JSON lines and SSE `data:` lines are accepted
+type eventReader struct {
+	reader *bufio.Reader
+	// onRead is called once any bytes arrived, it's used to extend the idle timeout
+	onRead func()
+}
+
+func newEventReader(r io.Reader, onRead func()) *eventReader {
+	return &eventReader{
+		reader: bufio.NewReaderSize(r, 64*1024),
+		onRead: onRead,
+	}
+}
+
+// Next returns the next non-empty event, io.EOF is returned once the response ends
+func (e *eventReader) Next() ([]byte, error) {
+	for {
+		line, err := e.readLine()
+		if len(line) > 0 {
+			if event := parseEventLine(line); len(event) > 0 {
+				return event, nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLine reads a whole line without the limit of bufio.Scanner, partial chunks are joined until a new line
+func (e *eventReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := e.reader.ReadSlice('\n')
+		if len(chunk) > 0 && e.onRead != nil {
+			e.onRead()
+		}
+
+		if len(line)+len(chunk) > maxEventSize {
+			return nil, ErrEventTooLarge
+		}
+
+		if err == bufio.ErrBufferFull {
+			line = append(line, chunk...)
+			continue
+		}
+
+		if line == nil {
+			// ReadSlice reuses the buffer, copy it before the next read
+			line = append([]byte{}, chunk...)
+		} else {
+			line = append(line, chunk...)
+		}
+		return line, err
+	}
+}
+
+// parseEventLine trims the line and strips SSE framing, comments and other SSE fields are dropped
+func parseEventLine(line []byte) []byte {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] == ':' {
+		return nil
+	}
+
+	if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+		return bytes.TrimSpace(data)
+	}
+
+	for _, field := range [][]byte{[]byte("event:"), []byte("id:"), []byte("retry:")} {
+		if bytes.HasPrefix(line, field) {
+			return nil
+		}
+	}
+
+	return line
+}
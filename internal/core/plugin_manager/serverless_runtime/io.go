@@ -1,10 +1,10 @@
 package serverless_runtime
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"time"
@@ -48,26 +48,46 @@ func (r *AWSPluginRuntime) Write(sessionId string, action access_types.PluginAcc
 
 	url += "?action=" + string(action)
 
-	connectTime := 240 * time.Second
+	functionPrewarmer.recordInvocation(r.LambdaURL, r.LambdaName, time.Now())
 
-	// create a new http request
-	ctx, cancel := context.WithTimeout(context.Background(), connectTime)
-	time.AfterFunc(connectTime, cancel)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
-	if err != nil {
-		r.Error(fmt.Sprintf("Error creating request: %v", err))
-		return
+	// the request is canceled once the function stays silent for idleTimeout,
+	// long streams like LLM tokens are kept alive as long as chunks keep coming
+	idleTimeout := functionTransports.responseIdleTimeout()
+
+	// create a new http request, it's created again on retrying
+	ctx, cancel := context.WithCancel(context.Background())
+	idleTimer := time.AfterFunc(idleTimeout, cancel)
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Dify-Plugin-Session-ID", sessionId)
+		functionTransports.sign(req, sessionId, data)
+		return req, nil
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "text/event-stream")
-	req.Header.Set("Dify-Plugin-Session-ID", sessionId)
 
 	routine.Submit(map[string]string{
 		"module":     "serverless_runtime",
 		"function":   "Write",
 		"session_id": sessionId,
+		"plugin_id":  r.Config.Identity(),
 		"lambda_url": r.LambdaURL,
 	}, func() {
+		startedAt := time.Now()
+		failed := false
+		providerFailure := false
+		defer func() {
+			duration := time.Since(startedAt)
+			r.recordTelemetry(startedAt, duration, failed)
+			if r.OnInvoked != nil {
+				r.OnInvoked(providerFailure, duration)
+			}
+		}()
+		defer cancel()
+		defer idleTimer.Stop()
 		// remove the session from listeners
 		defer r.listeners.Delete(sessionId)
 		defer l.Close()
@@ -75,9 +95,22 @@ func (r *AWSPluginRuntime) Write(sessionId string, action access_types.PluginAcc
 			Type: plugin_entities.SESSION_MESSAGE_TYPE_END,
 			Data: []byte(""),
 		})
+		// the session fails instead of ending as if it succeeded
+		defer routine.ReportPanic(func(err error) {
+			failed = true
+			l.Send(plugin_entities.SessionMessage{
+				Type: plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
+				Data: parser.MarshalJsonBytes(plugin_entities.ErrorResponse{
+					ErrorType: "PluginDaemonInnerError",
+					Message:   err.Error(),
+				}),
+			})
+		})
 
-		response, err := r.client.Do(req)
+		response, err := functionTransports.do(r.client, newRequest)
 		if err != nil {
+			failed = true
+			providerFailure = true
 			l.Send(plugin_entities.SessionMessage{
 				Type: plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
 				Data: parser.MarshalJsonBytes(plugin_entities.ErrorResponse{
@@ -89,19 +122,24 @@ func (r *AWSPluginRuntime) Write(sessionId string, action access_types.PluginAcc
 			return
 		}
 
-		// write to data stream
-		scanner := bufio.NewScanner(response.Body)
-		defer response.Body.Close()
+		// the platform responds 5xx or 429 if it's unable to run the function
+		if response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests {
+			providerFailure = true
+		}
 
-		// TODO: set a reasonable buffer size or use a reader, this is a temporary solution
-		scanner.Buffer(make([]byte, 1024), 5*1024*1024)
+		// write to data stream, events are forwarded as soon as they arrive
+		defer response.Body.Close()
+		reader := newEventReader(response.Body, func() {
+			idleTimer.Reset(idleTimeout)
+		})
 
 		sessionAlive := true
-		for scanner.Scan() && sessionAlive {
-			bytes := scanner.Bytes()
-
-			if len(bytes) == 0 {
-				continue
+		var readErr error
+		for sessionAlive {
+			bytes, err := reader.Next()
+			if err != nil {
+				readErr = err
+				break
 			}
 
 			plugin_entities.ParsePluginUniversalEvent(
@@ -110,6 +148,7 @@ func (r *AWSPluginRuntime) Write(sessionId string, action access_types.PluginAcc
 				func(session_id string, data []byte) {
 					sessionMessage, err := parser.UnmarshalJsonBytes[plugin_entities.SessionMessage](data)
 					if err != nil {
+						failed = true
 						l.Send(plugin_entities.SessionMessage{
 							Type: plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
 							Data: parser.MarshalJsonBytes(plugin_entities.ErrorResponse{
@@ -123,6 +162,7 @@ func (r *AWSPluginRuntime) Write(sessionId string, action access_types.PluginAcc
 				},
 				func() {},
 				func(err string) {
+					failed = true
 					l.Send(plugin_entities.SessionMessage{
 						Type: plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
 						Data: parser.MarshalJsonBytes(plugin_entities.ErrorResponse{
@@ -131,18 +171,28 @@ func (r *AWSPluginRuntime) Write(sessionId string, action access_types.PluginAcc
 						}),
 					})
 				},
-				func(message string) {},
+				func(sessionId string, event plugin_entities.PluginLogEvent) {},
 			)
 		}
 
-		if err := scanner.Err(); err != nil {
+		if readErr != nil && readErr != io.EOF {
+			failed = true
 			l.Send(plugin_entities.SessionMessage{
 				Type: plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
 				Data: parser.MarshalJsonBytes(plugin_entities.ErrorResponse{
 					ErrorType: "PluginDaemonInnerError",
-					Message:   fmt.Sprintf("failed to read response body: %v", err),
+					Message:   fmt.Sprintf("failed to read response body: %v", readErr),
 				}),
 			})
 		}
 	})
 }
+
+// recordTelemetry tracks the invocation for cost reporting, the memory the function is deployed with is billed
+func (r *AWSPluginRuntime) recordTelemetry(startedAt time.Time, duration time.Duration, failed bool) {
+	identity, err := r.Identity()
+	if err != nil {
+		return
+	}
+	invocationTelemetry.record(identity, r.LambdaName, r.MemoryMB, startedAt, duration, failed)
+}
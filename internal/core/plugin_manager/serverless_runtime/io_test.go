@@ -0,0 +1,128 @@
+package serverless_runtime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func sessionEvent(sessionId string, data string) string {
+	return parser.MarshalJson(plugin_entities.PluginUniversalEvent{
+		SessionId: sessionId,
+		Event:     plugin_entities.PLUGIN_EVENT_SESSION,
+		Data: parser.MarshalJsonBytes(plugin_entities.SessionMessage{
+			Type: plugin_entities.SESSION_MESSAGE_TYPE_STREAM,
+			Data: parser.MarshalJsonBytes(data),
+		}),
+	})
+}
+
+func TestWriteStreamsChunks(t *testing.T) {
+	routine.InitPool(64)
+
+	received := make(chan string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionId := r.Header.Get("Dify-Plugin-Session-ID")
+		flusher := w.(http.Flusher)
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: %s\n\n", sessionEvent(sessionId, fmt.Sprintf("token-%d", i)))
+			flusher.Flush()
+
+			// the next chunk is only sent once the previous one is received by the daemon
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	runtime := &AWSPluginRuntime{LambdaURL: server.URL, LambdaName: "neko"}
+	if err := runtime.InitEnvironment(); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := []string{}
+	ended := make(chan bool)
+	listener := runtime.Listen("session")
+	listener.Listen(func(message plugin_entities.SessionMessage) {
+		switch message.Type {
+		case plugin_entities.SESSION_MESSAGE_TYPE_STREAM:
+			chunk, err := parser.UnmarshalJsonBytes[string](message.Data)
+			if err != nil {
+				t.Error(err)
+			}
+			chunks = append(chunks, chunk)
+			received <- chunk
+		case plugin_entities.SESSION_MESSAGE_TYPE_ERROR:
+			t.Errorf("unexpected error: %s", message.Data)
+		case plugin_entities.SESSION_MESSAGE_TYPE_END:
+			close(ended)
+		}
+	})
+
+	runtime.Write("session", access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL, []byte("{}"))
+
+	select {
+	case <-ended:
+	case <-time.After(10 * time.Second):
+		t.Fatal("session did not end")
+	}
+
+	if strings.Join(chunks, ",") != "token-0,token-1,token-2" {
+		t.Fatalf("unexpected chunks: %v", chunks)
+	}
+}
+
+func TestEventReader(t *testing.T) {
+	large := strings.Repeat("a", 1024*1024)
+	body := strings.Join([]string{
+		`{"event":"heartbeat"}`,
+		"",
+		": comment",
+		"event: message",
+		"data: " + large,
+		"",
+		`{"event":"session"}`,
+	}, "\n")
+
+	reads := 0
+	reader := newEventReader(strings.NewReader(body), func() { reads++ })
+
+	expected := []string{`{"event":"heartbeat"}`, large, `{"event":"session"}`}
+	for _, e := range expected {
+		event, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(event, []byte(e)) {
+			t.Fatalf("unexpected event with %d bytes", len(event))
+		}
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if reads == 0 {
+		t.Fatal("onRead was not called")
+	}
+}
+
+func TestEventReaderTooLarge(t *testing.T) {
+	reader := newEventReader(strings.NewReader(strings.Repeat("a", maxEventSize+1)), nil)
+	if _, err := reader.Next(); !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("expected ErrEventTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package serverless_runtime
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	// invocations of the same hour of day are halved every week
+	prewarmHourlyHalfLife = 7 * 24 * time.Hour
+	// a function is expected to be used again if it was invoked recently
+	prewarmRecentWindow = time.Hour
+	// a function is expected to be used in an hour of day if it was invoked about once per day in that hour
+	prewarmHourlyThreshold = 1.0
+	// functions not invoked for a long time are forgotten
+	prewarmForgetAfter = 14 * 24 * time.Hour
+	prewarmPingTimeout = 10 * time.Second
+)
+
+// FunctionWarmStats describes the invocation pattern of a serverless function, an invocation is a warm hit
+// if the function was invoked or pinged within the warm window before, otherwise it's likely a cold start
+type FunctionWarmStats struct {
+	FunctionName  string    `json:"function_name"`
+	FunctionURL   string    `json:"function_url"`
+	Invocations   int64     `json:"invocations"`
+	WarmHits      int64     `json:"warm_hits"`
+	ColdHits      int64     `json:"cold_hits"`
+	WarmRatio     float64   `json:"warm_ratio"`
+	Pings         int64     `json:"pings"`
+	LastInvokedAt time.Time `json:"last_invoked_at"`
+	LastActiveAt  time.Time `json:"last_active_at"`
+	Predicted     bool      `json:"predicted"`
+}
+
+type PrewarmStats struct {
+	Enabled   bool                `json:"enabled"`
+	WarmHits  int64               `json:"warm_hits"`
+	ColdHits  int64               `json:"cold_hits"`
+	WarmRatio float64             `json:"warm_ratio"`
+	Pings     int64               `json:"pings"`
+	Functions []FunctionWarmStats `json:"functions"`
+}
+
+type functionActivity struct {
+	stats FunctionWarmStats
+	// decayed count of invocations by hour of day
+	hourly    [24]float64
+	decayedAt time.Time
+}
+
+// decay scales down the hourly counts by the time elapsed since the last decay
+func (a *functionActivity) decay(now time.Time) {
+	if !a.decayedAt.IsZero() {
+		factor := math.Pow(0.5, float64(now.Sub(a.decayedAt))/float64(prewarmHourlyHalfLife))
+		for i := range a.hourly {
+			a.hourly[i] *= factor
+		}
+	}
+	a.decayedAt = now
+}
+
+// predicted returns true if the function is expected to be invoked within the horizon
+func (a *functionActivity) predicted(now time.Time, horizon time.Duration) bool {
+	if now.Sub(a.stats.LastInvokedAt) < prewarmRecentWindow {
+		return true
+	}
+
+	for t := now; t.Before(now.Add(horizon)); t = t.Add(time.Hour) {
+		if a.hourly[t.Hour()] >= prewarmHourlyThreshold {
+			return true
+		}
+	}
+	return a.hourly[now.Add(horizon).Hour()] >= prewarmHourlyThreshold
+}
+
+type prewarmer struct {
+	mu        sync.Mutex
+	enabled   bool
+	interval  time.Duration
+	window    time.Duration
+	functions map[string]*functionActivity
+}
+
+var (
+	functionPrewarmer = &prewarmer{
+		interval:  time.Minute,
+		window:    10 * time.Minute,
+		functions: map[string]*functionActivity{},
+	}
+)
+
+// recordInvocation tracks an invocation of the function and whether it hit a warm instance
+func (p *prewarmer) recordInvocation(url string, name string, now time.Time) {
+	if url == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	activity, ok := p.functions[url]
+	if !ok {
+		activity = &functionActivity{stats: FunctionWarmStats{FunctionURL: url}}
+		p.functions[url] = activity
+	}
+
+	activity.stats.FunctionName = name
+	activity.stats.Invocations++
+	if !activity.stats.LastActiveAt.IsZero() && now.Sub(activity.stats.LastActiveAt) < p.window {
+		activity.stats.WarmHits++
+	} else {
+		activity.stats.ColdHits++
+	}
+
+	activity.decay(now)
+	activity.hourly[now.Hour()]++
+	activity.stats.LastInvokedAt = now
+	activity.stats.LastActiveAt = now
+}
+
+// due returns urls of the functions predicted to be used soon but going cold before the next tick
+func (p *prewarmer) due(now time.Time, interval time.Duration) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	urls := []string{}
+	for url, activity := range p.functions {
+		if now.Sub(activity.stats.LastInvokedAt) > prewarmForgetAfter {
+			delete(p.functions, url)
+			continue
+		}
+
+		activity.decay(now)
+		if !activity.predicted(now, interval+p.window) {
+			continue
+		}
+
+		// ping before the instance is recycled
+		if now.Sub(activity.stats.LastActiveAt)+interval >= p.window {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func (p *prewarmer) markPinged(url string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if activity, ok := p.functions[url]; ok {
+		activity.stats.Pings++
+		activity.stats.LastActiveAt = now
+	}
+}
+
+// ping sends a request to the function to keep an instance alive, any response means it's running
+func (p *prewarmer) ping(url string) error {
+	_, err := ProbeFunction(url, prewarmPingTimeout)
+	return err
+}
+
+func (p *prewarmer) stats(now time.Time) PrewarmStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := PrewarmStats{
+		Enabled:   p.enabled,
+		Functions: []FunctionWarmStats{},
+	}
+	for _, activity := range p.functions {
+		stats := activity.stats
+		stats.WarmRatio = warmRatio(stats.WarmHits, stats.ColdHits)
+		stats.Predicted = activity.predicted(now, p.interval+p.window)
+
+		result.WarmHits += stats.WarmHits
+		result.ColdHits += stats.ColdHits
+		result.Pings += stats.Pings
+		result.Functions = append(result.Functions, stats)
+	}
+	result.WarmRatio = warmRatio(result.WarmHits, result.ColdHits)
+
+	sort.Slice(result.Functions, func(i, j int) bool {
+		return result.Functions[i].Invocations > result.Functions[j].Invocations
+	})
+	return result
+}
+
+func warmRatio(warm int64, cold int64) float64 {
+	if warm+cold == 0 {
+		return 0
+	}
+	return float64(warm) / float64(warm+cold)
+}
+
+// StartPrewarming pings serverless functions predicted to be invoked soon every interval,
+// a function is considered warm for window after it's invoked or pinged
+func StartPrewarming(interval time.Duration, window time.Duration) {
+	functionPrewarmer.mu.Lock()
+	functionPrewarmer.enabled = true
+	functionPrewarmer.interval = interval
+	functionPrewarmer.window = window
+	functionPrewarmer.mu.Unlock()
+
+	go func() {
+		for range time.NewTicker(interval).C {
+			for _, url := range functionPrewarmer.due(time.Now(), interval) {
+				url := url
+				routine.Submit(map[string]string{
+					"module":   "serverless_runtime",
+					"function": "Prewarm",
+				}, func() {
+					if err := functionPrewarmer.ping(url); err != nil {
+						log.Warn("failed to prewarm serverless function %s: %s", url, err.Error())
+						return
+					}
+					functionPrewarmer.markPinged(url, time.Now())
+				})
+			}
+		}
+	}()
+}
+
+// GetPrewarmStats returns warm and cold hits of serverless functions invoked by this node
+func GetPrewarmStats() PrewarmStats {
+	return functionPrewarmer.stats(time.Now())
+}
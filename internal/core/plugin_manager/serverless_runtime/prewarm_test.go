@@ -0,0 +1,68 @@
+package serverless_runtime
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestPrewarmer() *prewarmer {
+	return &prewarmer{
+		interval:  time.Minute,
+		window:    10 * time.Minute,
+		functions: map[string]*functionActivity{},
+	}
+}
+
+func TestPrewarmerWarmHits(t *testing.T) {
+	p := newTestPrewarmer()
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	p.recordInvocation("http://neko", "neko", now)
+	p.recordInvocation("http://neko", "neko", now.Add(time.Minute))
+	p.recordInvocation("http://neko", "neko", now.Add(time.Hour))
+
+	stats := p.stats(now.Add(time.Hour))
+	if stats.WarmHits != 1 || stats.ColdHits != 2 {
+		t.Fatalf("unexpected hits, warm: %d, cold: %d", stats.WarmHits, stats.ColdHits)
+	}
+	if len(stats.Functions) != 1 || stats.Functions[0].Invocations != 3 {
+		t.Fatalf("unexpected functions: %+v", stats.Functions)
+	}
+
+	// pinged functions are warm
+	p.markPinged("http://neko", now.Add(2*time.Hour))
+	p.recordInvocation("http://neko", "neko", now.Add(2*time.Hour+time.Minute))
+	if stats := p.stats(now.Add(2 * time.Hour)); stats.WarmHits != 2 || stats.Pings != 1 {
+		t.Fatalf("unexpected stats after ping: %+v", stats)
+	}
+}
+
+func TestPrewarmerDue(t *testing.T) {
+	p := newTestPrewarmer()
+	day := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	// invoked every morning for a week
+	for i := 0; i < 7; i++ {
+		p.recordInvocation("http://daily", "daily", day.Add(time.Duration(i)*24*time.Hour))
+	}
+	// invoked once a long time ago in the evening
+	p.recordInvocation("http://rare", "rare", day.Add(10*time.Hour))
+
+	// the morning after, the daily function is expected and about to be cold
+	morning := day.Add(7*24*time.Hour - 5*time.Minute)
+	due := p.due(morning, time.Minute)
+	if len(due) != 1 || due[0] != "http://daily" {
+		t.Fatalf("unexpected due functions: %v", due)
+	}
+
+	// recently pinged functions are still warm
+	p.markPinged("http://daily", morning)
+	if due := p.due(morning.Add(time.Minute), time.Minute); len(due) != 0 {
+		t.Fatalf("warm functions should not be pinged: %v", due)
+	}
+
+	// in the afternoon nothing is expected
+	if due := p.due(day.Add(6*24*time.Hour+15*time.Hour), time.Minute); len(due) != 0 {
+		t.Fatalf("unexpected due functions: %v", due)
+	}
+}
@@ -0,0 +1,110 @@
+package serverless_runtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// InvocationTelemetry aggregates invocations of a plugin within an hour
+type InvocationTelemetry struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	FunctionName           string
+	// Hour is the start of the hour the invocations began in
+	Hour        time.Time
+	Invocations int64
+	Errors      int64
+	Duration    time.Duration
+	// MemoryMB is the memory the function is deployed with
+	MemoryMB int64
+}
+
+type telemetryKey struct {
+	identifier plugin_entities.PluginUniqueIdentifier
+	hour       time.Time
+}
+
+type telemetryCollector struct {
+	mu      sync.Mutex
+	records map[telemetryKey]*InvocationTelemetry
+}
+
+var (
+	invocationTelemetry = &telemetryCollector{
+		records: map[telemetryKey]*InvocationTelemetry{},
+	}
+)
+
+func (c *telemetryCollector) record(
+	identifier plugin_entities.PluginUniqueIdentifier,
+	functionName string,
+	memoryMB int64,
+	startedAt time.Time,
+	duration time.Duration,
+	failed bool,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := telemetryKey{identifier: identifier, hour: startedAt.UTC().Truncate(time.Hour)}
+	record, ok := c.records[key]
+	if !ok {
+		record = &InvocationTelemetry{
+			PluginUniqueIdentifier: identifier,
+			Hour:                   key.hour,
+		}
+		c.records[key] = record
+	}
+
+	record.FunctionName = functionName
+	record.MemoryMB = memoryMB
+	record.Invocations++
+	record.Duration += duration
+	if failed {
+		record.Errors++
+	}
+}
+
+func (c *telemetryCollector) drain() []InvocationTelemetry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]InvocationTelemetry, 0, len(c.records))
+	for _, record := range c.records {
+		result = append(result, *record)
+	}
+	c.records = map[telemetryKey]*InvocationTelemetry{}
+	return result
+}
+
+// requeue puts records back, they're merged into the ones collected since they were drained
+func (c *telemetryCollector) requeue(records []InvocationTelemetry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, record := range records {
+		key := telemetryKey{identifier: record.PluginUniqueIdentifier, hour: record.Hour}
+		existing, ok := c.records[key]
+		if !ok {
+			record := record
+			c.records[key] = &record
+			continue
+		}
+
+		existing.Invocations += record.Invocations
+		existing.Errors += record.Errors
+		existing.Duration += record.Duration
+	}
+}
+
+// DrainInvocationTelemetry returns invocations collected by this node since the last drain
+func DrainInvocationTelemetry() []InvocationTelemetry {
+	return invocationTelemetry.drain()
+}
+
+// RequeueInvocationTelemetry puts back drained records which failed to be reported, so that they're
+// reported along with the next drain
+func RequeueInvocationTelemetry(records []InvocationTelemetry) {
+	invocationTelemetry.requeue(records)
+}
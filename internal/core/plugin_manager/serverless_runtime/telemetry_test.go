@@ -0,0 +1,63 @@
+package serverless_runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestTelemetryCollector(t *testing.T) {
+	collector := &telemetryCollector{records: map[telemetryKey]*InvocationTelemetry{}}
+	identifier := plugin_entities.PluginUniqueIdentifier("langgenius/openai:0.0.1@checksum")
+	hour := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	collector.record(identifier, "neko", 256, hour.Add(time.Minute), time.Second, false)
+	collector.record(identifier, "neko", 256, hour.Add(59*time.Minute), 2*time.Second, true)
+	collector.record(identifier, "neko", 256, hour.Add(61*time.Minute), time.Second, false)
+
+	records := collector.drain()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 hourly records, got %d", len(records))
+	}
+
+	for _, record := range records {
+		switch record.Hour {
+		case hour:
+			if record.Invocations != 2 || record.Errors != 1 || record.Duration != 3*time.Second {
+				t.Fatalf("unexpected record: %+v", record)
+			}
+		case hour.Add(time.Hour):
+			if record.Invocations != 1 || record.Errors != 0 {
+				t.Fatalf("unexpected record: %+v", record)
+			}
+		default:
+			t.Fatalf("unexpected hour: %s", record.Hour)
+		}
+	}
+
+	if len(collector.drain()) != 0 {
+		t.Fatal("records should be cleared after draining")
+	}
+}
+
+func TestTelemetryCollectorRequeue(t *testing.T) {
+	collector := &telemetryCollector{records: map[telemetryKey]*InvocationTelemetry{}}
+	identifier := plugin_entities.PluginUniqueIdentifier("langgenius/openai:0.0.1@checksum")
+	hour := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	collector.record(identifier, "neko", 256, hour, time.Second, true)
+	failed := collector.drain()
+
+	// invocations collected after the drain are merged with the requeued ones
+	collector.record(identifier, "neko", 256, hour.Add(time.Minute), 2*time.Second, false)
+	collector.requeue(failed)
+
+	records := collector.drain()
+	if len(records) != 1 {
+		t.Fatalf("expected 1 hourly record, got %d", len(records))
+	}
+	if records[0].Invocations != 2 || records[0].Errors != 1 || records[0].Duration != 3*time.Second {
+		t.Fatalf("unexpected record: %+v", records[0])
+	}
+}
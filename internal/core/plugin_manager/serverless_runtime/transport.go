@@ -0,0 +1,287 @@
+package serverless_runtime
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TransportConfig tunes the connections between the daemon and serverless functions,
+// connections are pooled per function so that keep-alive connections are shared by all sessions
+type TransportConfig struct {
+	MaxIdleConnsPerFunction int
+	// MaxConnsPerFunction limits active connections to a function, 0 means unlimited
+	MaxConnsPerFunction int
+	IdleConnTimeout     time.Duration
+	KeepAlive           time.Duration
+	DialTimeout         time.Duration
+	// ResponseIdleTimeout cancels an invocation once the function stays silent for it
+	ResponseIdleTimeout time.Duration
+	// RetryBudget is the ratio of retries to invocations, retries beyond the budget are dropped
+	// to avoid overloading functions which are already failing, 0 disables retries
+	RetryBudget float64
+	// MaxRetries is the max retries of a single invocation, 0 disables retries
+	MaxRetries int
+}
+
+type TransportStats struct {
+	Invocations int64 `json:"invocations"`
+	// NewConns and ReusedConns count connections got by invocations, a reused one skips the handshake
+	NewConns         int64   `json:"new_conns"`
+	ReusedConns      int64   `json:"reused_conns"`
+	ReuseRatio       float64 `json:"reuse_ratio"`
+	Retries          int64   `json:"retries"`
+	RetriesExhausted int64   `json:"retries_exhausted"`
+	// Functions are urls of functions which have a connection pool
+	Functions []string `json:"functions"`
+}
+
+type transportMetrics struct {
+	invocations      atomic.Int64
+	newConns         atomic.Int64
+	reusedConns      atomic.Int64
+	retries          atomic.Int64
+	retriesExhausted atomic.Int64
+}
+
+// retryBudget allows a retry for every 1/ratio invocations, a small reserve allows retries right after startup
+type retryBudget struct {
+	mu      sync.Mutex
+	ratio   float64
+	tokens  float64
+	reserve float64
+}
+
+func newRetryBudget(ratio float64) *retryBudget {
+	if ratio <= 0 {
+		// no reserve either, otherwise the first retries are still allowed
+		return &retryBudget{}
+	}
+	return &retryBudget{ratio: ratio, tokens: 10, reserve: 10}
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.reserve)
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type transportPool struct {
+	mu      sync.Mutex
+	config  TransportConfig
+	clients map[string]*http.Client
+	budget  *retryBudget
+	metrics transportMetrics
+	// auth is nil if functions are invoked without authentication
+	auth *authenticator
+}
+
+var (
+	functionTransports = newTransportPool(TransportConfig{
+		MaxIdleConnsPerFunction: 100,
+		IdleConnTimeout:         120 * time.Second,
+		KeepAlive:               120 * time.Second,
+		DialTimeout:             5 * time.Second,
+		ResponseIdleTimeout:     240 * time.Second,
+		RetryBudget:             0.1,
+		MaxRetries:              2,
+	})
+)
+
+func newTransportPool(config TransportConfig) *transportPool {
+	return &transportPool{
+		config:  config,
+		clients: map[string]*http.Client{},
+		budget:  newRetryBudget(config.RetryBudget),
+	}
+}
+
+// InitTransport applies the config to the connection pools, pools created before are dropped
+func InitTransport(config TransportConfig) {
+	functionTransports.mu.Lock()
+	defer functionTransports.mu.Unlock()
+
+	for _, client := range functionTransports.clients {
+		client.CloseIdleConnections()
+	}
+	functionTransports.config = config
+	functionTransports.clients = map[string]*http.Client{}
+	functionTransports.budget = newRetryBudget(config.RetryBudget)
+}
+
+// reset drops all the pools, connections in use are kept until their invocations end
+func (p *transportPool) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, client := range p.clients {
+		client.CloseIdleConnections()
+	}
+	p.clients = map[string]*http.Client{}
+}
+
+// sign adds the signature of the request if signing is enabled
+func (p *transportPool) sign(req *http.Request, sessionId string, body []byte) {
+	p.mu.Lock()
+	auth := p.auth
+	p.mu.Unlock()
+
+	if auth == nil {
+		return
+	}
+	if credentials := auth.current(); credentials.signingKey != nil {
+		signRequest(req, credentials.signingKey, sessionId, body, time.Now())
+	}
+}
+
+// client returns the shared client of the function, runtimes are created for every session
+// so the client must not be owned by a runtime, otherwise connections are never reused
+func (p *transportPool) client(functionURL string) *http.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.clients[functionURL]; ok {
+		return client
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   p.config.DialTimeout,
+			KeepAlive: p.config.KeepAlive,
+		}).DialContext,
+		MaxIdleConns:        p.config.MaxIdleConnsPerFunction,
+		MaxIdleConnsPerHost: p.config.MaxIdleConnsPerFunction,
+		MaxConnsPerHost:     p.config.MaxConnsPerFunction,
+		IdleConnTimeout:     p.config.IdleConnTimeout,
+		// responses are streamed, HTTP/2 is used if the function supports it and
+		// compression is disabled to avoid buffering events until a gzip block is flushed
+		ForceAttemptHTTP2:  true,
+		DisableCompression: true,
+	}
+	if p.auth != nil {
+		if credentials := p.auth.current(); credentials.tlsConfig != nil {
+			transport.TLSClientConfig = credentials.tlsConfig.Clone()
+		}
+	}
+
+	client := &http.Client{Transport: transport}
+	p.clients[functionURL] = client
+	return client
+}
+
+func (p *transportPool) responseIdleTimeout() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config.ResponseIdleTimeout
+}
+
+// do sends the request built by newRequest, it's retried only if the connection failed to be established,
+// which means the function has not received the request and it's safe to be sent again
+func (p *transportPool) do(client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	p.mu.Lock()
+	maxRetries := p.config.MaxRetries
+	budget := p.budget
+	p.mu.Unlock()
+
+	p.metrics.invocations.Add(1)
+	budget.deposit()
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				p.metrics.reusedConns.Add(1)
+			} else {
+				p.metrics.newConns.Add(1)
+			}
+		},
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+		response, err := client.Do(req)
+		if err == nil || !isDialError(err) || req.Context().Err() != nil || attempt >= maxRetries {
+			return response, err
+		}
+
+		if !budget.withdraw() {
+			p.metrics.retriesExhausted.Add(1)
+			return nil, err
+		}
+		p.metrics.retries.Add(1)
+	}
+}
+
+func (p *transportPool) stats() TransportStats {
+	p.mu.Lock()
+	functions := make([]string, 0, len(p.clients))
+	for url := range p.clients {
+		functions = append(functions, url)
+	}
+	p.mu.Unlock()
+	sort.Strings(functions)
+
+	stats := TransportStats{
+		Invocations:      p.metrics.invocations.Load(),
+		NewConns:         p.metrics.newConns.Load(),
+		ReusedConns:      p.metrics.reusedConns.Load(),
+		Retries:          p.metrics.retries.Load(),
+		RetriesExhausted: p.metrics.retriesExhausted.Load(),
+		Functions:        functions,
+	}
+	if total := stats.NewConns + stats.ReusedConns; total > 0 {
+		stats.ReuseRatio = float64(stats.ReusedConns) / float64(total)
+	}
+	return stats
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// ProbeFunction sends an authenticated GET to the function, the status code is returned if it responded
+func ProbeFunction(url string, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// probes are authenticated as invocations, otherwise they're refused before reaching an instance
+	functionTransports.sign(req, "", nil)
+	response, err := functionTransports.client(url).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	return response.StatusCode, nil
+}
+
+// GetTransportStats returns connection reuse and retries of invocations sent by this node
+func GetTransportStats() TransportStats {
+	return functionTransports.stats()
+}
@@ -0,0 +1,115 @@
+package serverless_runtime
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestTransportPool(retryBudget float64) *transportPool {
+	return newTransportPool(TransportConfig{
+		MaxIdleConnsPerFunction: 10,
+		IdleConnTimeout:         time.Minute,
+		KeepAlive:               time.Minute,
+		DialTimeout:             time.Second,
+		ResponseIdleTimeout:     time.Minute,
+		RetryBudget:             retryBudget,
+		MaxRetries:              2,
+	})
+}
+
+func TestTransportReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	pool := newTestTransportPool(0.1)
+	if pool.client(server.URL) != pool.client(server.URL) {
+		t.Fatal("clients of the same function should be shared")
+	}
+
+	for i := 0; i < 5; i++ {
+		response, err := pool.do(pool.client(server.URL), func() (*http.Request, error) {
+			return http.NewRequest("POST", server.URL, nil)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+
+	stats := pool.stats()
+	if stats.Invocations != 5 || stats.NewConns != 1 || stats.ReusedConns != 4 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if len(stats.Functions) != 1 || stats.Functions[0] != server.URL {
+		t.Fatalf("unexpected functions: %v", stats.Functions)
+	}
+}
+
+func TestTransportRetriesWithinBudget(t *testing.T) {
+	// a closed listener refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String()
+	listener.Close()
+
+	pool := newTestTransportPool(0)
+	pool.budget = &retryBudget{ratio: 0, tokens: 3, reserve: 3}
+
+	attempts := 0
+	newRequest := func() (*http.Request, error) {
+		attempts++
+		return http.NewRequest("POST", url, nil)
+	}
+
+	if _, err := pool.do(pool.client(url), newRequest); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+
+	// only one token is left in the budget
+	attempts = 0
+	if _, err := pool.do(pool.client(url), newRequest); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+
+	stats := pool.stats()
+	if stats.Retries != 3 || stats.RetriesExhausted != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestTransportRetriesDisabled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String()
+	listener.Close()
+
+	pool := newTestTransportPool(0)
+
+	attempts := 0
+	if _, err := pool.do(pool.client(url), func() (*http.Request, error) {
+		attempts++
+		return http.NewRequest("POST", url, nil)
+	}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d", attempts)
+	}
+}
@@ -1,6 +1,8 @@
 package serverless_runtime
 
 import (
+	"time"
+
 	"net/http"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
@@ -16,9 +18,15 @@ type AWSPluginRuntime struct {
 	// access url for the lambda function
 	LambdaURL  string
 	LambdaName string
+	// MemoryMB is the memory the function is deployed with, 0 if it's unknown
+	MemoryMB int64
 
 	// listeners mapping session id to the listener
 	listeners mapping.Map[string, *entities.Broadcast[plugin_entities.SessionMessage]]
 
 	client *http.Client
+
+	// OnInvoked is called once an invocation ends, providerFailure is set if the function was unreachable
+	// or the platform failed to serve it, errors responded by the plugin itself are not provider failures
+	OnInvoked func(providerFailure bool, duration time.Duration)
 }
@@ -0,0 +1,74 @@
+package plugin_manager
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// ServerlessPricing is used to estimate the cost of invocations, duration is billed by the configured memory
+type ServerlessPricing struct {
+	CostPerGBSecond        float64
+	CostPerMillionRequests float64
+}
+
+func (s ServerlessPricing) estimate(stat *models.ServerlessInvocationStat) {
+	stat.GBSeconds = float64(stat.DurationMs) / 1000 * float64(stat.MemoryMB) / 1024
+	stat.EstimatedCost = stat.GBSeconds*s.CostPerGBSecond + float64(stat.Invocations)/1e6*s.CostPerMillionRequests
+}
+
+// flushServerlessTelemetry adds invocations collected by this node to the hourly stats, records failed
+// to be written are put back and retried with the next flush
+func flushServerlessTelemetry(pricing ServerlessPricing) {
+	failed := []serverless_runtime.InvocationTelemetry{}
+	for _, record := range serverless_runtime.DrainInvocationTelemetry() {
+		if err := addServerlessInvocationStat(record, pricing); err != nil {
+			log.Error(
+				"failed to report serverless telemetry of %s: %s",
+				record.PluginUniqueIdentifier.String(), err.Error(),
+			)
+			failed = append(failed, record)
+		}
+	}
+	serverless_runtime.RequeueInvocationTelemetry(failed)
+}
+
+// addServerlessInvocationStat accumulates the record into the hourly stat in a single statement,
+// stats are shared by all nodes, so the first one creates it and the others add to it on conflict
+func addServerlessInvocationStat(record serverless_runtime.InvocationTelemetry, pricing ServerlessPricing) error {
+	stat := models.ServerlessInvocationStat{
+		PluginUniqueIdentifier: record.PluginUniqueIdentifier.String(),
+		PluginID:               record.PluginUniqueIdentifier.PluginID(),
+		Hour:                   record.Hour,
+		FunctionName:           record.FunctionName,
+		Invocations:            record.Invocations,
+		Errors:                 record.Errors,
+		DurationMs:             record.Duration.Milliseconds(),
+		MemoryMB:               record.MemoryMB,
+	}
+	// costs are linear, so the cost of the record is added to the one of the stat
+	pricing.estimate(&stat)
+
+	return db.Upsert(&stat, []string{"plugin_unique_identifier", "hour"}, map[string]any{
+		"function_name":  stat.FunctionName,
+		"memory_mb":      stat.MemoryMB,
+		"invocations":    db.Increment("invocations", stat.Invocations),
+		"errors":         db.Increment("errors", stat.Errors),
+		"duration_ms":    db.Increment("duration_ms", stat.DurationMs),
+		"gb_seconds":     db.Increment("gb_seconds", stat.GBSeconds),
+		"estimated_cost": db.Increment("estimated_cost", stat.EstimatedCost),
+		"updated_at":     time.Now(),
+	})
+}
+
+// startServerlessTelemetry reports invocations periodically
+func (p *PluginManager) startServerlessTelemetry(interval time.Duration, pricing ServerlessPricing) {
+	go func() {
+		for range time.NewTicker(interval).C {
+			flushServerlessTelemetry(pricing)
+		}
+	}()
+}
@@ -0,0 +1,220 @@
+package plugin_manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	version "github.com/hashicorp/go-version"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrServerlessVersionNotFound   = errors.New("serverless function of the plugin is not deployed")
+	ErrNoPreviousServerlessVersion = errors.New("no previous version of the plugin is deployed")
+)
+
+// ServerlessFunctionVersion is a deployed function of a plugin version, functions are kept after
+// upgrading so that traffic is able to be switched back to them
+type ServerlessFunctionVersion struct {
+	PluginUniqueIdentifier string    `json:"plugin_unique_identifier"`
+	Version                string    `json:"version"`
+	FunctionName           string    `json:"function_name"`
+	FunctionURL            string    `json:"function_url"`
+	DeployedAt             time.Time `json:"deployed_at"`
+	// PinnedTo is the plugin whose function serves invocations of this version, empty if it serves itself
+	PinnedTo string `json:"pinned_to"`
+}
+
+// listServerlessRuntimes returns deployed functions of the plugin, the newest version comes first
+func listServerlessRuntimes(pluginID string) ([]models.ServerlessRuntime, error) {
+	runtimes, err := db.GetAll[models.ServerlessRuntime](
+		db.Like("plugin_unique_identifier", pluginID+":"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.ServerlessRuntime{}
+	for _, runtime := range runtimes {
+		identifier, err := plugin_entities.NewPluginUniqueIdentifier(runtime.PluginUniqueIdentifier)
+		if err != nil || identifier.PluginID() != pluginID {
+			continue
+		}
+		result = append(result, runtime)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		c := compareServerlessVersions(
+			plugin_entities.PluginUniqueIdentifier(result[i].PluginUniqueIdentifier),
+			plugin_entities.PluginUniqueIdentifier(result[j].PluginUniqueIdentifier),
+		)
+		if c == 0 {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return c > 0
+	})
+
+	return result, nil
+}
+
+// compareServerlessVersions compares versions of two identifiers of the same plugin
+func compareServerlessVersions(a, b plugin_entities.PluginUniqueIdentifier) int {
+	va, errA := version.NewVersion(a.Version().String())
+	vb, errB := version.NewVersion(b.Version().String())
+	if errA != nil || errB != nil {
+		return strings.Compare(a.Version().String(), b.Version().String())
+	}
+	return va.Compare(vb)
+}
+
+// ListServerlessVersions returns deployed functions of the plugin along with their pins
+func (p *PluginManager) ListServerlessVersions(pluginID string) ([]ServerlessFunctionVersion, error) {
+	runtimes, err := listServerlessRuntimes(pluginID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []ServerlessFunctionVersion{}
+	for _, runtime := range runtimes {
+		identifier := plugin_entities.PluginUniqueIdentifier(runtime.PluginUniqueIdentifier)
+		functionVersion := ServerlessFunctionVersion{
+			PluginUniqueIdentifier: runtime.PluginUniqueIdentifier,
+			Version:                identifier.Version().String(),
+			FunctionName:           runtime.FunctionName,
+			FunctionURL:            runtime.FunctionURL,
+			DeployedAt:             runtime.CreatedAt,
+		}
+
+		pin, err := db.GetOne[models.ServerlessRuntimePin](
+			db.Equal("plugin_unique_identifier", runtime.PluginUniqueIdentifier),
+		)
+		if err == nil {
+			functionVersion.PinnedTo = pin.TargetPluginUniqueIdentifier
+		} else if err != db.ErrDatabaseNotFound {
+			return nil, err
+		}
+
+		versions = append(versions, functionVersion)
+	}
+
+	return versions, nil
+}
+
+// PinServerlessVersion routes invocations of the plugin to the function deployed for target,
+// it takes effect on all nodes at once as the cached runtime is dropped
+func (p *PluginManager) PinServerlessVersion(
+	identity plugin_entities.PluginUniqueIdentifier,
+	target plugin_entities.PluginUniqueIdentifier,
+) error {
+	if identity.PluginID() != target.PluginID() {
+		return fmt.Errorf("%s is not a version of %s", target.String(), identity.PluginID())
+	}
+
+	for _, id := range []plugin_entities.PluginUniqueIdentifier{identity, target} {
+		if _, err := db.GetOne[models.ServerlessRuntime](
+			db.Equal("plugin_unique_identifier", id.String()),
+		); err == db.ErrDatabaseNotFound {
+			return errors.Join(ErrServerlessVersionNotFound, fmt.Errorf("plugin: %s", id.String()))
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if identity == target {
+		return p.UnpinServerlessVersion(identity)
+	}
+
+	if err := db.WithTransaction(func(tx *gorm.DB) error {
+		pin, err := db.GetOne[models.ServerlessRuntimePin](
+			db.WithTransactionContext(tx),
+			db.Equal("plugin_unique_identifier", identity.String()),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			return db.Create(&models.ServerlessRuntimePin{
+				PluginUniqueIdentifier:       identity.String(),
+				TargetPluginUniqueIdentifier: target.String(),
+			}, tx)
+		} else if err != nil {
+			return err
+		}
+
+		pin.TargetPluginUniqueIdentifier = target.String()
+		return db.Update(&pin, tx)
+	}); err != nil {
+		return err
+	}
+
+	return p.dropServerlessRuntimeCache(identity)
+}
+
+// RollbackServerlessVersion pins the plugin to the newest deployed version older than it
+func (p *PluginManager) RollbackServerlessVersion(
+	identity plugin_entities.PluginUniqueIdentifier,
+) (plugin_entities.PluginUniqueIdentifier, error) {
+	runtimes, err := listServerlessRuntimes(identity.PluginID())
+	if err != nil {
+		return "", err
+	}
+
+	for _, runtime := range runtimes {
+		candidate := plugin_entities.PluginUniqueIdentifier(runtime.PluginUniqueIdentifier)
+		if compareServerlessVersions(candidate, identity) < 0 {
+			return candidate, p.PinServerlessVersion(identity, candidate)
+		}
+	}
+
+	return "", ErrNoPreviousServerlessVersion
+}
+
+// UnpinServerlessVersion routes invocations of the plugin back to its own function
+func (p *PluginManager) UnpinServerlessVersion(identity plugin_entities.PluginUniqueIdentifier) error {
+	if err := db.DeleteByCondition(models.ServerlessRuntimePin{
+		PluginUniqueIdentifier: identity.String(),
+	}); err != nil {
+		return err
+	}
+
+	return p.dropServerlessRuntimeCache(identity)
+}
+
+// applyServerlessRuntimePin replaces the function of the runtime if it's pinned to another version,
+// the checksum is kept so that the runtime is still identified as the requested plugin
+func (p *PluginManager) applyServerlessRuntimePin(runtime *models.ServerlessRuntime) error {
+	pin, err := db.GetOne[models.ServerlessRuntimePin](
+		db.Equal("plugin_unique_identifier", runtime.PluginUniqueIdentifier),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load serverless runtime pin from db: %v", err)
+	}
+
+	target, err := db.GetOne[models.ServerlessRuntime](
+		db.Equal("plugin_unique_identifier", pin.TargetPluginUniqueIdentifier),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load pinned serverless runtime %s: %v", pin.TargetPluginUniqueIdentifier, err)
+	}
+
+	runtime.FunctionURL = target.FunctionURL
+	runtime.FunctionName = target.FunctionName
+	runtime.MemoryMB = target.MemoryMB
+	return nil
+}
+
+// dropServerlessRuntimeCache drops the cached runtime and regional functions of the plugin
+func (p *PluginManager) dropServerlessRuntimeCache(identity plugin_entities.PluginUniqueIdentifier) error {
+	if err := cache.Del(p.getServerlessRuntimeCacheKey(identity)); err != nil {
+		return err
+	}
+	return cache.Del(getServerlessRegionsCacheKey(identity))
+}
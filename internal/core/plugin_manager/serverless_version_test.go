@@ -0,0 +1,31 @@
+package plugin_manager
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestCompareServerlessVersions(t *testing.T) {
+	identifier := func(version string) plugin_entities.PluginUniqueIdentifier {
+		return plugin_entities.PluginUniqueIdentifier(
+			"langgenius/openai:" + version + "@1234567890123456789012345678901234567890123456789012345678901234",
+		)
+	}
+
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"0.0.10", "0.0.9", 1},
+		{"0.1.0", "0.1.0", 0},
+		{"1.0.0-beta", "1.0.0", -1},
+		{"0.9.9", "1.0.0", -1},
+	}
+
+	for _, c := range cases {
+		if result := compareServerlessVersions(identifier(c.a), identifier(c.b)); result != c.expected {
+			t.Errorf("compare %s with %s: expected %d, got %d", c.a, c.b, c.expected, result)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package plugin_manager
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// walking runtime environments is expensive, the sizes are reused for a while
+	storageUsageCacheTTL = 10 * time.Minute
+)
+
+type PluginStorageUsage struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	// PackageSize is the size of the uploaded package in bytes
+	PackageSize int64 `json:"package_size"`
+	// RuntimeSize is the size of the extracted working directory including the virtual environment in bytes
+	RuntimeSize int64 `json:"runtime_size"`
+}
+
+func (u PluginStorageUsage) Total() int64 {
+	return u.PackageSize + u.RuntimeSize
+}
+
+type cachedStorageUsage struct {
+	usage     PluginStorageUsage
+	expiresAt time.Time
+}
+
+// localWorkingPath returns the working directory a local plugin is extracted to
+func (p *PluginManager) localWorkingPath(identifier plugin_entities.PluginUniqueIdentifier) string {
+	return filepath.Join(p.workingDirectory, strings.ReplaceAll(identifier.String(), ":", "-"))
+}
+
+// PluginStorageUsage returns the disk usage of a plugin, missing files are counted as 0
+func (p *PluginManager) PluginStorageUsage(identifier plugin_entities.PluginUniqueIdentifier) PluginStorageUsage {
+	if cached, ok := p.storageUsageCache.Load(identifier.String()); ok && time.Now().Before(cached.expiresAt) {
+		return cached.usage
+	}
+
+	usage := PluginStorageUsage{PluginUniqueIdentifier: identifier}
+
+	if state, err := p.packageBucket.State(identifier.String()); err == nil {
+		usage.PackageSize = state.Size
+	} else if state, err := p.installedBucket.State(identifier); err == nil {
+		usage.PackageSize = state.Size
+	}
+
+	if p.platform == app.PLATFORM_LOCAL {
+		if size, _, err := directoryState(p.localWorkingPath(identifier)); err == nil {
+			usage.RuntimeSize = size
+		}
+	}
+
+	p.storageUsageCache.Store(identifier.String(), cachedStorageUsage{
+		usage:     usage,
+		expiresAt: time.Now().Add(storageUsageCacheTTL),
+	})
+
+	return usage
+}
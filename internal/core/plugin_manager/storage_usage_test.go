@@ -0,0 +1,50 @@
+package plugin_manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss/local"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestPluginStorageUsage(t *testing.T) {
+	root := t.TempDir()
+	storage := local.NewLocalStorage(root)
+	p := &PluginManager{
+		packageBucket:    media_transport.NewPackageBucket(storage, "packages"),
+		installedBucket:  media_transport.NewInstalledBucket(storage, "installed"),
+		workingDirectory: filepath.Join(root, "working"),
+		platform:         app.PLATFORM_LOCAL,
+	}
+
+	identifier := plugin_entities.PluginUniqueIdentifier("langgenius/neko:0.0.1@" + "0123456789abcdef0123456789abcdef")
+	if err := p.packageBucket.Save(identifier.String(), make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	workingPath := p.localWorkingPath(identifier)
+	if err := os.MkdirAll(filepath.Join(workingPath, ".venv"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workingPath, "main.py"), make([]byte, 20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workingPath, ".venv", "lib.py"), make([]byte, 30), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	usage := p.PluginStorageUsage(identifier)
+	if usage.PackageSize != 100 || usage.RuntimeSize != 50 || usage.Total() != 150 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	// missing plugins take no space
+	missing := plugin_entities.PluginUniqueIdentifier("langgenius/missing:0.0.1@" + "0123456789abcdef0123456789abcdef")
+	if usage := p.PluginStorageUsage(missing); usage.Total() != 0 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+}
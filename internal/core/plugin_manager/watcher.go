@@ -15,9 +15,11 @@ func (p *PluginManager) startLocalWatcher() {
 	go func() {
 		log.Info("start to handle new plugins in path: %s", p.pluginStoragePath)
 		p.handleNewLocalPlugins()
+		p.localPluginsLaunched.Store(true)
 		for range time.NewTicker(time.Second * 30).C {
 			p.handleNewLocalPlugins()
 			p.removeUninstalledLocalPlugins()
+			p.removeMisplacedLocalPlugins()
 		}
 	}()
 }
@@ -66,6 +68,26 @@ func (p *PluginManager) startRemoteWatcher(config *app.Config) {
 	}
 }
 
+// LocalPluginsLaunched returns true once local plugins installed before the node started are launched,
+// always true if the node runs no local plugin
+func (p *PluginManager) LocalPluginsLaunched() bool {
+	if p.platform != app.PLATFORM_LOCAL || !p.runsPlugins() {
+		return true
+	}
+	return p.localPluginsLaunched.Load()
+}
+
+// DisconnectDebuggingPlugins closes connections of all debugging plugins, they're expected to reconnect
+func (p *PluginManager) DisconnectDebuggingPlugins() {
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		if runtime, ok := value.(*debugging_runtime.RemotePluginRuntime); ok {
+			log.Info("disconnecting debugging plugin %s", key)
+			runtime.Stop()
+		}
+		return true
+	})
+}
+
 func (p *PluginManager) handleNewLocalPlugins() {
 	// walk through all plugins
 	plugins, err := p.installedBucket.List()
@@ -75,9 +97,17 @@ func (p *PluginManager) handleNewLocalPlugins() {
 	}
 
 	for _, plugin := range plugins {
+		if !p.placedOnCurrentNode(plugin) {
+			continue
+		}
+
 		_, launchedChan, errChan, err := p.launchLocal(plugin)
-		if err != nil {
+		if err == ErrNodeAtCapacity {
+			log.Warn("skip launching local plugin %s: %s", plugin.String(), err.Error())
+			continue
+		} else if err != nil {
 			log.Error("launch local plugin failed: %s", err.Error())
+			continue
 		}
 
 		// consume error, avoid deadlock
@@ -106,6 +136,11 @@ func (p *PluginManager) removeUninstalledLocalPlugins() {
 			return true
 		}
 
+		// dev plugins are not installed from packages
+		if p.isDevPlugin(pluginUniqueIdentifier.String()) {
+			return true
+		}
+
 		// check if plugin is deleted, stop it if so
 		exists, err := p.installedBucket.Exists(pluginUniqueIdentifier)
 		if err != nil {
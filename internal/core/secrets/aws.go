@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+// awsProvider reads secrets of AWS Secrets Manager, refs are like `dify/daemon` or `dify/daemon#server_key`
+// to pick a key of a json secret, credentials are loaded by the default chain of the aws sdk
+type awsProvider struct {
+	region string
+	client *http.Client
+}
+
+func newAWSProvider(config *app.Config) *awsProvider {
+	return &awsProvider{
+		region: config.SecretsAWSRegion,
+		client: &http.Client{},
+	}
+}
+
+func (p *awsProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	id, key, _ := strings.Cut(ref, "#")
+
+	var options []func(*awsconfig.LoadOptions) error
+	if p.region != "" {
+		options = append(options, awsconfig.WithRegion(p.region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Region == "" {
+		return "", fmt.Errorf("region of aws secrets manager is not set")
+	}
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(map[string]string{"SecretId": id})
+	if err != nil {
+		return "", err
+	}
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", cfg.Region),
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	hash := sha256.Sum256(payload)
+	if err := v4.NewSigner().SignHTTP(
+		ctx, credentials, request, hex.EncodeToString(hash[:]), "secretsmanager", cfg.Region, time.Now(),
+	); err != nil {
+		return "", err
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return "", fmt.Errorf("unexpected status code: %d, %s", response.StatusCode, string(message))
+	}
+
+	var body struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	secret := aws.ToString(body.SecretString)
+
+	if key == "" {
+		return secret, nil
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(secret), &data); err != nil {
+		return "", fmt.Errorf("secret is not json: %w", err)
+	}
+	return lookupKey(data, key)
+}
@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1/"
+	gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// gcpProvider reads secrets of GCP Secret Manager, refs are like `projects/dify/secrets/server-key`
+// or `projects/dify/secrets/server-key/versions/3`, the latest version is read if it's not specified,
+// the daemon is authenticated by the service account of the instance through the metadata server
+type gcpProvider struct {
+	client *http.Client
+}
+
+func newGCPProvider() *gcpProvider {
+	return &gcpProvider{client: &http.Client{}}
+}
+
+func (p *gcpProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	name := strings.Trim(ref, "/")
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpSecretManagerURL+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	var body struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := p.do(request, &body); err != nil {
+		return "", err
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(body.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+func (p *gcpProvider) accessToken(ctx context.Context) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := p.do(request, &body); err != nil {
+		return "", fmt.Errorf("failed to get access token from metadata server: %w", err)
+	}
+	return body.AccessToken, nil
+}
+
+func (p *gcpProvider) do(request *http.Request, body any) error {
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(body)
+}
@@ -0,0 +1,210 @@
+// Package secrets resolves daemon config secrets stored in secret managers instead of environment variables.
+//
+// A config value referencing a secret like `vault://secret/data/dify#db_password`,
+// `aws-sm://dify/daemon#server_key` or `gcp-sm://projects/dify/secrets/server-key` is replaced by the secret
+// on startup, secrets are fetched again periodically, watched ones like server keys take effect right away
+// once they're rotated while the others take effect after a restart.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// Provider fetches secrets from a secret manager
+type Provider interface {
+	// Fetch returns the secret referenced by ref, ref is the part after `<scheme>://`
+	Fetch(ctx context.Context, ref string) (string, error)
+}
+
+const (
+	fetchTimeout = 10 * time.Second
+)
+
+// binding is a config value resolved from a secret manager
+type binding struct {
+	env      string
+	scheme   string
+	ref      string
+	provider Provider
+	value    string
+}
+
+// Value is a config secret which may be rotated while the daemon is running
+type Value struct {
+	value atomic.Pointer[string]
+}
+
+func (v *Value) Get() string {
+	return *v.value.Load()
+}
+
+func (v *Value) set(value string) {
+	v.value.Store(&value)
+}
+
+var (
+	lock     sync.Mutex
+	bindings []*binding
+	watched  = map[string]*Value{}
+)
+
+// newProviders creates providers of secret managers by their schemes
+var newProviders = func(config *app.Config) map[string]Provider {
+	return map[string]Provider{
+		"vault":  newVaultProvider(config),
+		"aws-sm": newAWSProvider(config),
+		"gcp-sm": newGCPProvider(),
+	}
+}
+
+// Resolve replaces config values referencing secrets by the secrets, it's called before the config
+// is validated as required values may be references
+func Resolve(config *app.Config) error {
+	providers := newProviders(config)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		env := value.Type().Field(i).Tag.Get("envconfig")
+		if env == "" {
+			continue
+		}
+
+		// typed values like PLATFORM are never secrets
+		var target *string
+		switch v := field.Addr().Interface().(type) {
+		case *string:
+			target = v
+		case **string:
+			if *v == nil {
+				continue
+			}
+			target = *v
+		default:
+			continue
+		}
+
+		scheme, ref, ok := strings.Cut(*target, "://")
+		if !ok {
+			continue
+		}
+		provider, ok := providers[scheme]
+		if !ok {
+			// urls like DB_HOST or MARKETPLACE_URL
+			continue
+		}
+
+		secret, err := provider.Fetch(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("failed to fetch secret of %s from %s: %w", env, scheme, err)
+		}
+
+		*target = secret
+		bindings = append(bindings, &binding{
+			env:      env,
+			scheme:   scheme,
+			ref:      ref,
+			provider: provider,
+			value:    secret,
+		})
+	}
+
+	return nil
+}
+
+// ResolveReference returns the secret the value references, values referencing no secret manager
+// are returned as they are, it's used for values other than configs like settings of declared endpoints
+func ResolveReference(ctx context.Context, config *app.Config, value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	provider, ok := newProviders(config)[scheme]
+	if !ok {
+		return value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	secret, err := provider.Fetch(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch secret from %s: %w", scheme, err)
+	}
+	return secret, nil
+}
+
+// Watch returns the config secret of the environment variable, it's updated once the secret is rotated
+// in the secret manager if it's resolved from one, current is the value resolved on startup
+func Watch(env string, current string) *Value {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if value, ok := watched[env]; ok {
+		return value
+	}
+
+	value := &Value{}
+	value.set(current)
+	watched[env] = value
+	return value
+}
+
+// Launch refreshes secrets resolved from secret managers periodically
+func Launch(config *app.Config) {
+	lock.Lock()
+	resolved := len(bindings)
+	lock.Unlock()
+	if resolved == 0 {
+		return
+	}
+
+	go func() {
+		for range time.NewTicker(time.Duration(config.SecretsRefreshInterval) * time.Second).C {
+			refresh()
+		}
+	}()
+}
+
+func refresh() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	for _, b := range bindings {
+		secret, err := b.provider.Fetch(ctx, b.ref)
+		if err != nil {
+			// keep using the last secret fetched
+			log.Warn("failed to refresh secret of %s from %s: %s", b.env, b.scheme, err.Error())
+			continue
+		}
+		if secret == b.value {
+			continue
+		}
+
+		b.value = secret
+		if value, ok := watched[b.env]; ok {
+			value.set(secret)
+			log.Info("secret of %s is rotated", b.env)
+		} else {
+			log.Warn("secret of %s is rotated, it takes effect after a restart", b.env)
+		}
+	}
+}
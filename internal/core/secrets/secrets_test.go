@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+)
+
+type fakeProvider map[string]string
+
+func (p fakeProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	return p[ref], nil
+}
+
+func TestResolveAndRefresh(t *testing.T) {
+	provider := fakeProvider{"server-key": "key-1", "db-password": "password-1"}
+	newProviders = func(config *app.Config) map[string]Provider {
+		return map[string]Provider{"fake": provider}
+	}
+	bindings, watched = nil, map[string]*Value{}
+
+	config := &app.Config{
+		ServerKey:                           "fake://server-key",
+		DBPassword:                          "fake://db-password",
+		DBHost:                              "postgres://localhost",
+		MarketplaceURL:                      "https://marketplace.dify.ai",
+		DifyInnerApiKey:                     "plain",
+		DifyPluginServerlessConnectorAPIKey: parser.ToPtr("fake://server-key"),
+	}
+	if err := Resolve(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.ServerKey != "key-1" || config.DBPassword != "password-1" ||
+		*config.DifyPluginServerlessConnectorAPIKey != "key-1" {
+		t.Fatalf("references should be resolved, got %+v", config)
+	}
+	if config.DBHost != "postgres://localhost" || config.DifyInnerApiKey != "plain" {
+		t.Fatal("values not referencing secrets should be kept")
+	}
+
+	serverKey := Watch("SERVER_KEY", config.ServerKey)
+	provider["server-key"] = "key-2"
+	refresh()
+	if serverKey.Get() != "key-2" {
+		t.Fatalf("watched secret should be rotated, got %s", serverKey.Get())
+	}
+}
+
+func TestVaultKV2(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" || r.URL.Path != "/v1/secret/data/dify" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"db_password": "password"},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider := newVaultProvider(&app.Config{VaultAddr: server.URL, VaultToken: "token"})
+	secret, err := provider.Fetch(context.Background(), "secret/data/dify#db_password")
+	if err != nil || secret != "password" {
+		t.Fatalf("expected password, got %q: %v", secret, err)
+	}
+
+	if _, err := provider.Fetch(context.Background(), "secret/data/dify#missing"); err == nil {
+		t.Fatal("missing key should fail")
+	}
+}
@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+// vaultProvider reads secrets of HashiCorp Vault, refs are like `secret/data/dify#db_password`,
+// both kv v1 and v2 engines are supported
+type vaultProvider struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+func newVaultProvider(config *app.Config) *vaultProvider {
+	return &vaultProvider{
+		addr:      strings.TrimSuffix(config.VaultAddr, "/"),
+		token:     config.VaultToken,
+		namespace: config.VaultNamespace,
+		client:    &http.Client{},
+	}
+}
+
+func (p *vaultProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	if p.addr == "" {
+		return "", errors.New("VAULT_ADDR is not set")
+	}
+
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" {
+		return "", fmt.Errorf("invalid vault secret %q, expected path#key", ref)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		request.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	data := body.Data
+	// kv v2 nests secrets in data.data along with their metadata
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	return lookupKey(data, key)
+}
+
+func lookupKey(data map[string]any, key string) (string, error) {
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found", key)
+	}
+	secret, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("key %q is not a string", key)
+	}
+	return secret, nil
+}
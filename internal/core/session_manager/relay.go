@@ -0,0 +1,227 @@
+package session_manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+)
+
+// Sessions are served by the node which created them, the session info is cached so that other nodes
+// are able to find it, but only the owning node is able to write to the plugin runtime.
+//
+// Events written to a session owned by another node, like backwards invocation responses arriving on
+// the wrong node, are appended to the redis stream of the owning node and written to the runtime once
+// the owning node consumes them.
+//
+// Delivery is at least once, an event is acknowledged after it's written to the runtime and events
+// not acknowledged are delivered again, duplicated deliveries are dropped by the id of the event.
+//
+// During rolling upgrades, events are only relayed to nodes consuming them, and each event carries
+// the version of its schema so that nodes drop events they don't understand instead of misreading them.
+
+const (
+	SESSION_RELAY_STREAM_PREFIX = "session-relay-stream"
+	SESSION_RELAY_GROUP         = "session-relay"
+	// SESSION_RELAY_EVENT_VERSION is bumped once fields of relayEvent change incompatibly
+	SESSION_RELAY_EVENT_VERSION = 1
+
+	// streams of stopped nodes are never consumed, they expire after the last event
+	SESSION_RELAY_STREAM_EXPIRE  = time.Hour
+	SESSION_RELAY_STREAM_MAX_LEN = 10000
+	// events are dropped if the session is not bound to a runtime within the timeout
+	SESSION_RELAY_DELIVERY_TIMEOUT = time.Minute
+	SESSION_RELAY_READ_BLOCK       = time.Second * 5
+	SESSION_RELAY_READ_COUNT       = 64
+	// ids of delivered events are kept to drop duplicated deliveries
+	SESSION_RELAY_DEDUP_WINDOW = time.Minute * 10
+)
+
+var (
+	ErrSessionRelayNotLaunched  = errors.New("session relay is not launched")
+	ErrSessionRelayNotSupported = errors.New("the node serving the session doesn't support session relay")
+
+	relayNodeId string
+	// relaySupported returns true if the node consumes relayed events
+	relaySupported func(nodeId string) bool
+	relayDedup     = newRelayDeduplicator(SESSION_RELAY_DEDUP_WINDOW)
+)
+
+type relayEvent struct {
+	Version   int                             `json:"version"`
+	ID        string                          `json:"id"`
+	SessionID string                          `json:"session_id"`
+	Event     PLUGIN_IN_STREAM_EVENT          `json:"event"`
+	Action    access_types.PluginAccessAction `json:"action"`
+	Data      json.RawMessage                 `json:"data"`
+	CreatedAt int64                           `json:"created_at"`
+}
+
+func relayStream(nodeId string) string {
+	return fmt.Sprintf("%s:%s", SESSION_RELAY_STREAM_PREFIX, nodeId)
+}
+
+// LaunchRelay starts consuming events relayed to sessions owned by the node,
+// events are only relayed to nodes which supported returns true for
+func LaunchRelay(nodeId string, supported func(nodeId string) bool) error {
+	if err := cache.XGroupCreate(relayStream(nodeId), SESSION_RELAY_GROUP); err != nil {
+		return err
+	}
+
+	relayNodeId = nodeId
+	relaySupported = supported
+	go consumeRelayedEvents(nodeId)
+	return nil
+}
+
+// relay appends the event to the stream of the node owning the session
+func (s *Session) relay(event PLUGIN_IN_STREAM_EVENT, action access_types.PluginAccessAction, data any) error {
+	if relayNodeId == "" {
+		return ErrSessionRelayNotLaunched
+	}
+	if !relaySupported(s.ClusterID) {
+		return ErrSessionRelayNotSupported
+	}
+
+	payload := parser.MarshalJson(relayEvent{
+		Version:   SESSION_RELAY_EVENT_VERSION,
+		ID:        uuid.New().String(),
+		SessionID: s.ID,
+		Event:     event,
+		Action:    action,
+		Data:      parser.MarshalJsonBytes(data),
+		CreatedAt: time.Now().Unix(),
+	})
+
+	stream := relayStream(s.ClusterID)
+	if _, err := cache.XAdd(stream, map[string]any{"event": payload}, SESSION_RELAY_STREAM_MAX_LEN); err != nil {
+		return err
+	}
+	if _, err := cache.Expire(stream, SESSION_RELAY_STREAM_EXPIRE); err != nil {
+		log.Warn("failed to refresh expire time of session relay stream %s: %s", stream, err.Error())
+	}
+	return nil
+}
+
+func consumeRelayedEvents(nodeId string) {
+	stream := relayStream(nodeId)
+	for {
+		// events delivered before but not acknowledged are retried before reading new ones
+		for _, id := range []string{"0", ">"} {
+			messages, err := cache.XReadGroup(
+				stream, SESSION_RELAY_GROUP, nodeId, id, SESSION_RELAY_READ_COUNT, SESSION_RELAY_READ_BLOCK,
+			)
+			if err != nil {
+				log.Error("failed to read session relay stream: %s", err.Error())
+				time.Sleep(SESSION_RELAY_READ_BLOCK)
+				// the group is removed along with the stream once it expires
+				if err := cache.XGroupCreate(stream, SESSION_RELAY_GROUP); err != nil {
+					log.Error("failed to create session relay group: %s", err.Error())
+				}
+				continue
+			}
+
+			acked := []string{}
+			for _, message := range messages {
+				if deliverRelayedEvent(message.Values["event"]) {
+					acked = append(acked, message.ID)
+				}
+			}
+
+			if len(acked) > 0 {
+				if err := cache.XAck(stream, SESSION_RELAY_GROUP, acked); err != nil {
+					log.Error("failed to acknowledge relayed session events: %s", err.Error())
+				}
+			}
+		}
+	}
+}
+
+// deliverRelayedEvent writes the event to the runtime of the session,
+// returns false if the event should be delivered again later
+func deliverRelayedEvent(value any) bool {
+	payload, ok := value.(string)
+	if !ok {
+		return true
+	}
+
+	event, err := parser.UnmarshalJson[relayEvent](payload)
+	if err != nil {
+		log.Error("failed to unmarshal relayed session event: %s", err.Error())
+		return true
+	}
+
+	if event.Version > SESSION_RELAY_EVENT_VERSION {
+		log.Warn("dropping relayed event of session %s, unsupported version %d", event.SessionID, event.Version)
+		return true
+	}
+
+	if relayDedup.delivered(event.ID) {
+		return true
+	}
+
+	session_lock.RLock()
+	session := sessions[event.SessionID]
+	session_lock.RUnlock()
+
+	if session == nil || session.runtime == nil {
+		// the session may not be bound to its runtime yet
+		if time.Since(time.Unix(event.CreatedAt, 0)) < SESSION_RELAY_DELIVERY_TIMEOUT {
+			return false
+		}
+
+		log.Warn("dropping relayed event of session %s, the session is not found", event.SessionID)
+		return true
+	}
+
+	session.runtime.Write(session.ID, event.Action, session.Message(event.Event, event.Data))
+	relayDedup.markDelivered(event.ID, time.Now())
+	return true
+}
+
+type relayDeduplicator struct {
+	mu     sync.Mutex
+	window time.Duration
+
+	ids          map[string]time.Time
+	lastPrunedAt time.Time
+}
+
+func newRelayDeduplicator(window time.Duration) *relayDeduplicator {
+	return &relayDeduplicator{
+		window: window,
+		ids:    map[string]time.Time{},
+	}
+}
+
+func (d *relayDeduplicator) delivered(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.ids[id]
+	return ok
+}
+
+func (d *relayDeduplicator) markDelivered(id string, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.ids[id] = now
+
+	if now.Sub(d.lastPrunedAt) < d.window {
+		return
+	}
+	d.lastPrunedAt = now
+	for id, deliveredAt := range d.ids {
+		if now.Sub(deliveredAt) >= d.window {
+			delete(d.ids, id)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package session_manager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRelayDeduplicator(t *testing.T) {
+	dedup := newRelayDeduplicator(time.Minute)
+	now := time.Unix(1000, 0)
+
+	if dedup.delivered("a") {
+		t.Fatal("expected event not delivered yet")
+	}
+
+	dedup.markDelivered("a", now)
+	if !dedup.delivered("a") {
+		t.Fatal("expected event to be delivered")
+	}
+
+	// ids older than the window are pruned
+	dedup.markDelivered("b", now.Add(2*time.Minute))
+	if dedup.delivered("a") {
+		t.Error("expected delivered id to be pruned after the window")
+	}
+	if !dedup.delivered("b") {
+		t.Error("expected recent id to be kept")
+	}
+}
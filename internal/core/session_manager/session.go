@@ -1,17 +1,23 @@
 package session_manager
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"runtime/pprof"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/slow_log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/profiling"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -25,6 +31,16 @@ type Session struct {
 	ID                  string                              `json:"id"`
 	runtime             plugin_entities.PluginLifetime      `json:"-"`
 	backwardsInvocation dify_invocation.BackwardsInvocation `json:"-"`
+	// ctx carries the trace of the request creating the session, it's not canceled along with the request
+	// as the session may outlive it
+	ctx context.Context `json:"-"`
+	// createdAt is when the session is created on the current node
+	createdAt time.Time `json:"-"`
+	// timing records phases of the invocation served by the session, nil if it's created by other nodes
+	timing *slow_log.Timing `json:"-"`
+	// onKill stops the invocation served by the session once it's killed
+	onKill     func()     `json:"-"`
+	onKillLock sync.Mutex `json:"-"`
 
 	TenantID               string                                 `json:"tenant_id"`
 	UserID                 string                                 `json:"user_id"`
@@ -39,6 +55,8 @@ type Session struct {
 	MessageID      *string `json:"message_id"`
 	AppID          *string `json:"app_id"`
 	EndpointID     *string `json:"endpoint_id"`
+	// RequestID is the id of the request creating the session, carried to logs of the session
+	RequestID string `json:"request_id"`
 }
 
 func sessionKey(id string) string {
@@ -59,6 +77,9 @@ type NewSessionPayload struct {
 	MessageID              *string                                `json:"message_id"`
 	AppID                  *string                                `json:"app_id"`
 	EndpointID             *string                                `json:"endpoint_id"`
+	// Context is the context of the request creating the session, background if nil,
+	// the request id is taken from the logger it carries
+	Context context.Context `json:"-"`
 }
 
 func NewSession(payload NewSessionPayload) *Session {
@@ -76,6 +97,12 @@ func NewSession(payload NewSessionPayload) *Session {
 		MessageID:              payload.MessageID,
 		AppID:                  payload.AppID,
 		EndpointID:             payload.EndpointID,
+		RequestID:              log.FromContext(payload.Context).Field(log.FIELD_REQUEST_ID),
+		createdAt:              time.Now(),
+		timing:                 slow_log.NewTiming(),
+	}
+	if payload.Context != nil {
+		s.ctx = context.WithoutCancel(payload.Context)
 	}
 
 	session_lock.Lock()
@@ -83,11 +110,18 @@ func NewSession(payload NewSessionPayload) *Session {
 	session_lock.Unlock()
 
 	if !payload.IgnoreCache {
-		if err := cache.Store(sessionKey(s.ID), s, time.Minute*30); err != nil {
-			log.Error("set session info to cache failed, %s", err)
+		if err := cache.Store(sessionKey(s.ID), s, time.Minute*30, cache.WithContext(s.Context())); err != nil {
+			s.Logger().Error("set session info to cache failed, %s", err)
 		}
 	}
 
+	events.Publish(events.SessionOpened{
+		SessionID:              s.ID,
+		TenantID:               s.TenantID,
+		PluginUniqueIdentifier: s.PluginUniqueIdentifier,
+		Action:                 s.Action,
+	})
+
 	return s
 }
 
@@ -114,6 +148,70 @@ func GetSession(payload GetSessionPayload) *Session {
 	return session
 }
 
+// Count returns the number of sessions being served by this node
+func Count() int {
+	session_lock.RLock()
+	defer session_lock.RUnlock()
+	return len(sessions)
+}
+
+// SessionSummary describes a session being served by this node
+type SessionSummary struct {
+	ID                     string                                 `json:"id"`
+	TenantID               string                                 `json:"tenant_id"`
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	Action                 access_types.PluginAccessAction        `json:"action"`
+	RequestID              string                                 `json:"request_id"`
+	CreatedAt              time.Time                              `json:"created_at"`
+}
+
+// List returns the sessions being served by this node, the oldest first
+func List() []SessionSummary {
+	session_lock.RLock()
+	summaries := make([]SessionSummary, 0, len(sessions))
+	for _, s := range sessions {
+		summaries = append(summaries, SessionSummary{
+			ID:                     s.ID,
+			TenantID:               s.TenantID,
+			PluginUniqueIdentifier: s.PluginUniqueIdentifier,
+			Action:                 s.Action,
+			RequestID:              s.RequestID,
+			CreatedAt:              s.createdAt,
+		})
+	}
+	session_lock.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].CreatedAt.Before(summaries[j].CreatedAt)
+	})
+	return summaries
+}
+
+// Kill stops the invocation served by the session, it returns false if the session is not served by this node
+func Kill(id string) bool {
+	session_lock.RLock()
+	s := sessions[id]
+	session_lock.RUnlock()
+	if s == nil {
+		return false
+	}
+
+	s.onKillLock.Lock()
+	onKill := s.onKill
+	s.onKillLock.Unlock()
+	if onKill != nil {
+		onKill()
+	}
+	return true
+}
+
+// OnKill sets the function stopping the invocation served by the session
+func (s *Session) OnKill(f func()) {
+	s.onKillLock.Lock()
+	defer s.onKillLock.Unlock()
+	s.onKill = f
+}
+
 type DeleteSessionPayload struct {
 	ID          string `json:"id"`
 	IgnoreCache bool   `json:"ignore_cache"`
@@ -121,9 +219,20 @@ type DeleteSessionPayload struct {
 
 func DeleteSession(payload DeleteSessionPayload) {
 	session_lock.Lock()
+	s, ok := sessions[payload.ID]
 	delete(sessions, payload.ID)
 	session_lock.Unlock()
 
+	if ok {
+		events.Publish(events.SessionClosed{
+			SessionID:              s.ID,
+			TenantID:               s.TenantID,
+			PluginUniqueIdentifier: s.PluginUniqueIdentifier,
+			Action:                 s.Action,
+			Duration:               time.Since(s.createdAt),
+		})
+	}
+
 	if !payload.IgnoreCache {
 		if err := cache.Del(sessionKey(payload.ID)); err != nil {
 			log.Error("delete session info from cache failed, %s", err)
@@ -142,6 +251,42 @@ func (s *Session) Close(payload CloseSessionPayload) {
 	})
 }
 
+// Context returns the context carrying the trace of the request creating the session,
+// sessions fetched from cache are created by other nodes so their context is background
+func (s *Session) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// Timing returns the timing of the invocation served by the session
+func (s *Session) Timing() *slow_log.Timing {
+	return s.timing
+}
+
+// Logger returns the logger writing fields of the session
+func (s *Session) Logger() *log.Logger {
+	return log.FromContext(s.Context()).
+		With(log.FIELD_REQUEST_ID, s.RequestID).
+		With(log.FIELD_TENANT_ID, s.TenantID).
+		With(log.FIELD_PLUGIN_ID, s.PluginUniqueIdentifier.PluginID()).
+		With(log.FIELD_SESSION_ID, s.ID)
+}
+
+// LoggerOf returns the logger of the session served by the current node,
+// the fields of the session are left out if it's not served by the current node
+func LoggerOf(sessionId string) *log.Logger {
+	session_lock.RLock()
+	session := sessions[sessionId]
+	session_lock.RUnlock()
+
+	if session == nil {
+		return log.With(log.FIELD_SESSION_ID, sessionId)
+	}
+	return session.Logger()
+}
+
 func (s *Session) BindRuntime(runtime plugin_entities.PluginLifetime) {
 	s.runtime = runtime
 }
@@ -179,8 +324,18 @@ func (s *Session) Message(event PLUGIN_IN_STREAM_EVENT, data any) []byte {
 
 func (s *Session) Write(event PLUGIN_IN_STREAM_EVENT, action access_types.PluginAccessAction, data any) error {
 	if s.runtime == nil {
+		// the session is fetched from cache, relay the event to the node serving it
+		if s.ClusterID != "" && s.ClusterID != relayNodeId {
+			return s.relay(event, action, data)
+		}
 		return errors.New("runtime not bound")
 	}
-	s.runtime.Write(s.ID, action, s.Message(event, data))
+	// label the goroutine so that writes stuck on the runtime are found by the session in goroutine dumps
+	pprof.Do(context.Background(), pprof.Labels(
+		profiling.LABEL_PLUGIN_ID, s.PluginUniqueIdentifier.String(),
+		profiling.LABEL_SESSION_ID, s.ID,
+	), func(ctx context.Context) {
+		s.runtime.Write(s.ID, action, s.Message(event, data))
+	})
 	return nil
 }
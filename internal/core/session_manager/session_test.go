@@ -0,0 +1,28 @@
+package session_manager
+
+import "testing"
+
+func TestListAndKillSessions(t *testing.T) {
+	first := NewSession(NewSessionPayload{TenantID: "tenant", IgnoreCache: true})
+	defer first.Close(CloseSessionPayload{IgnoreCache: true})
+	second := NewSession(NewSessionPayload{TenantID: "tenant", IgnoreCache: true})
+	defer second.Close(CloseSessionPayload{IgnoreCache: true})
+
+	summaries := List()
+	if len(summaries) != 2 || summaries[0].ID != first.ID || summaries[1].ID != second.ID {
+		t.Fatalf("sessions should be listed in order of creation, got %+v", summaries)
+	}
+
+	killed := false
+	first.OnKill(func() { killed = true })
+	if !Kill(first.ID) || !killed {
+		t.Fatal("session should be killed")
+	}
+	// sessions without an invocation are killed as well
+	if !Kill(second.ID) {
+		t.Fatal("session should be found")
+	}
+	if Kill("unknown") {
+		t.Fatal("unknown session should not be killed")
+	}
+}
@@ -0,0 +1,283 @@
+package singleton_job
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+// Singleton jobs are periodic background tasks which should run once across the cluster,
+// like collecting garbage of the storage shared by nodes
+//
+// Each job has its own lease in redis, the node holding the lease runs the job every interval
+// and renews the lease every third of its duration, once the node is gone the lease expires
+// and another node takes the job over.
+//
+// The last run of each job is stored in redis so that the node taking over continues the schedule
+// instead of running the job right away. A job may still run twice if the leader stalls longer
+// than the lease while running it, so jobs should be idempotent.
+//
+// State:
+//	- singleton-job-lease:name: node_id
+//	- hashmap[singleton-job-runs]
+//		- name:
+//			- started_at: int64
+//			- finished_at: int64
+//			- node_id: string
+//			- error: string
+
+const (
+	SINGLETON_JOB_LEASE_KEY_PREFIX  = "singleton-job-lease"
+	SINGLETON_JOB_RUNS_HASH_MAP_KEY = "singleton-job-runs-hash-map"
+)
+
+type Job struct {
+	// Name identifies the job across the cluster, it should be stable between versions
+	Name     string
+	Interval time.Duration
+	Run      func() error
+}
+
+type jobRun struct {
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at"`
+	NodeID     string `json:"node_id"`
+	Error      string `json:"error"`
+}
+
+type JobStatus struct {
+	Name string `json:"name"`
+	// Interval is in seconds
+	Interval int64 `json:"interval"`
+	// Leader is the node holding the lease, empty if no node holds it
+	Leader        string     `json:"leader"`
+	LastStartedAt *time.Time `json:"last_started_at"`
+	LastRunBy     string     `json:"last_run_by"`
+	// LastError is empty if the last run succeeded
+	LastError string `json:"last_error"`
+}
+
+type job struct {
+	Job
+
+	leading atomic.Bool
+	running atomic.Bool
+}
+
+type scheduler struct {
+	mu sync.Mutex
+
+	nodeId        string
+	leaseDuration time.Duration
+	launched      bool
+	// fenced returns true if the node is partitioned from the cluster, no job is run meanwhile
+	fenced func() bool
+
+	jobs map[string]*job
+}
+
+var (
+	jobScheduler = &scheduler{
+		jobs: map[string]*job{},
+	}
+)
+
+// Launch starts scheduling jobs registered so far and the ones registered later,
+// nodeId is stored within leases so it should be unique across the cluster,
+// leases are given up while fenced returns true
+func Launch(nodeId string, leaseDuration time.Duration, fenced func() bool) {
+	jobScheduler.mu.Lock()
+	defer jobScheduler.mu.Unlock()
+
+	jobScheduler.nodeId = nodeId
+	jobScheduler.leaseDuration = leaseDuration
+	jobScheduler.fenced = fenced
+	jobScheduler.launched = true
+
+	for _, j := range jobScheduler.jobs {
+		jobScheduler.schedule(j)
+	}
+}
+
+// Register adds a job which runs every interval on one node of the cluster
+func Register(j Job) {
+	jobScheduler.mu.Lock()
+	defer jobScheduler.mu.Unlock()
+
+	if _, ok := jobScheduler.jobs[j.Name]; ok {
+		log.Panic("singleton job %s is already registered", j.Name)
+	}
+
+	registered := &job{Job: j}
+	jobScheduler.jobs[j.Name] = registered
+
+	if jobScheduler.launched {
+		jobScheduler.schedule(registered)
+	}
+}
+
+// ListJobs returns status of the jobs registered on the current node
+func ListJobs() ([]JobStatus, error) {
+	jobScheduler.mu.Lock()
+	jobs := make([]*job, 0, len(jobScheduler.jobs))
+	for _, j := range jobScheduler.jobs {
+		jobs = append(jobs, j)
+	}
+	jobScheduler.mu.Unlock()
+
+	runs, err := cache.GetMap[jobRun](SINGLETON_JOB_RUNS_HASH_MAP_KEY)
+	if err != nil && err != cache.ErrNotFound {
+		return nil, err
+	}
+
+	result := make([]JobStatus, 0, len(jobs))
+	for _, j := range jobs {
+		status := JobStatus{
+			Name:     j.Name,
+			Interval: int64(j.Interval.Seconds()),
+		}
+
+		leader, err := cache.Get[string](leaseKey(j.Name))
+		if err == nil {
+			status.Leader = *leader
+		} else if err != cache.ErrNotFound {
+			return nil, err
+		}
+
+		if run, ok := runs[j.Name]; ok {
+			startedAt := time.Unix(run.StartedAt, 0)
+			status.LastStartedAt = &startedAt
+			status.LastRunBy = run.NodeID
+			status.LastError = run.Error
+		}
+
+		result = append(result, status)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func leaseKey(name string) string {
+	return SINGLETON_JOB_LEASE_KEY_PREFIX + ":" + name
+}
+
+// due returns true if the job should run, a job never run before is due right away
+func due(lastStartedAt time.Time, interval time.Duration, now time.Time) bool {
+	return !now.Before(lastStartedAt.Add(interval))
+}
+
+func (s *scheduler) schedule(j *job) {
+	// renew the lease before it expires, a third of its duration tolerates a missed renewal
+	interval := s.leaseDuration / 3
+	go func() {
+		s.tick(j)
+		for range time.NewTicker(interval).C {
+			s.tick(j)
+		}
+	}()
+}
+
+func (s *scheduler) tick(j *job) {
+	if !s.acquire(j) {
+		return
+	}
+
+	lastStartedAt := time.Time{}
+	run, err := cache.GetMapField[jobRun](SINGLETON_JOB_RUNS_HASH_MAP_KEY, j.Name)
+	if err == nil {
+		lastStartedAt = time.Unix(run.StartedAt, 0)
+	} else if err != cache.ErrNotFound {
+		log.Error("failed to fetch the last run of singleton job %s: %s", j.Name, err.Error())
+		return
+	}
+
+	if !due(lastStartedAt, j.Interval, time.Now()) {
+		return
+	}
+
+	// keep renewing the lease while running, a run longer than the interval delays the next one
+	if !j.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "singleton_job",
+		"function": "run",
+		"job":      j.Name,
+	}, func() {
+		defer j.running.Store(false)
+		s.run(j)
+	})
+}
+
+// acquire renews the lease of the job if the current node holds it, otherwise tries to take it
+func (s *scheduler) acquire(j *job) bool {
+	key := leaseKey(j.Name)
+
+	// the lease is left to expire, another node takes the job over meanwhile
+	if s.fenced != nil && s.fenced() {
+		if j.leading.CompareAndSwap(true, false) {
+			log.Warn("current node has given up the lease of singleton job %s as it's fenced", j.Name)
+		}
+		return false
+	}
+
+	if j.leading.Load() {
+		renewed, err := cache.ExpireIfEqual(key, s.nodeId, s.leaseDuration)
+		if err != nil {
+			// the lease may expire before redis recovers, stop running the job until it's reacquired
+			log.Error("failed to renew the lease of singleton job %s: %s", j.Name, err.Error())
+			j.leading.Store(false)
+			return false
+		}
+		if renewed {
+			return true
+		}
+
+		j.leading.Store(false)
+		log.Info("current node has lost the lease of singleton job %s", j.Name)
+	}
+
+	success, err := cache.SetNX(key, s.nodeId, s.leaseDuration)
+	if err != nil {
+		log.Error("failed to acquire the lease of singleton job %s: %s", j.Name, err.Error())
+		return false
+	}
+	if !success {
+		return false
+	}
+
+	j.leading.Store(true)
+	log.Info("current node has become the leader of singleton job %s", j.Name)
+	return true
+}
+
+func (s *scheduler) run(j *job) {
+	// record the start before running, the node taking over during the run won't run it again
+	run := jobRun{
+		StartedAt: time.Now().Unix(),
+		NodeID:    s.nodeId,
+	}
+	if err := cache.SetMapOneField(SINGLETON_JOB_RUNS_HASH_MAP_KEY, j.Name, run); err != nil {
+		log.Error("failed to record the run of singleton job %s: %s", j.Name, err.Error())
+		return
+	}
+
+	if err := j.Run(); err != nil {
+		log.Error("singleton job %s failed: %s", j.Name, err.Error())
+		run.Error = err.Error()
+	}
+
+	run.FinishedAt = time.Now().Unix()
+	if err := cache.SetMapOneField(SINGLETON_JOB_RUNS_HASH_MAP_KEY, j.Name, run); err != nil {
+		log.Error("failed to record the run of singleton job %s: %s", j.Name, err.Error())
+	}
+}
@@ -0,0 +1,20 @@
+package singleton_job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDue(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	if !due(time.Time{}, time.Minute, now) {
+		t.Error("expected a job never run to be due")
+	}
+	if due(now.Add(-30*time.Second), time.Minute, now) {
+		t.Error("expected a job run within the interval not to be due")
+	}
+	if !due(now.Add(-time.Minute), time.Minute, now) {
+		t.Error("expected a job run an interval ago to be due")
+	}
+}
@@ -0,0 +1,334 @@
+// Package slo tracks success rate and latency of each plugin over a sliding window and reports plugins
+// burning their error budget.
+//
+// Invocations served by each node are collected into buckets of a minute and flushed to redis, so that
+// the window of a plugin covers invocations served by all nodes. The objectives are checked by a
+// singleton job, which publishes events.PluginSLOBreached once a plugin breaches its objectives.
+package slo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/singleton_job"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// State:
+//	- hashmap[slo-bucket:plugin_id:minute]
+//		- invocations: int64
+//		- failures: int64
+//		- latency_i: int64, invocations falling into the i-th latency bucket
+//	- hashmap[slo-plugins]
+//		- plugin_id: the last minute the plugin is invoked
+//	- hashmap[slo-breaches]
+//		- plugin_id: when the breach is reported
+
+const (
+	SLO_BUCKET_KEY_PREFIX     = "slo-bucket"
+	SLO_PLUGINS_HASH_MAP_KEY  = "slo-plugins"
+	SLO_BREACHES_HASH_MAP_KEY = "slo-breaches"
+
+	// MAX_WINDOW is the longest window in minutes, buckets expire after it
+	MAX_WINDOW = 1440
+
+	flushInterval      = 10 * time.Second
+	evaluationInterval = time.Minute
+	// plugins invoked fewer times within the window are never breached, a few failures of a rarely used
+	// plugin shouldn't exhaust its budget
+	minInvocations = 10
+)
+
+// Objective is the targets a plugin is checked against within the window
+type Objective struct {
+	SuccessRate float64 `json:"success_rate"`
+	// LatencyP99 is in milliseconds, 0 disables it
+	LatencyP99 int64 `json:"latency_p99"`
+	// Window is in minutes
+	Window int `json:"window"`
+}
+
+type Report struct {
+	PluginID    string    `json:"plugin_id"`
+	Objective   Objective `json:"objective"`
+	Invocations int64     `json:"invocations"`
+	Failures    int64     `json:"failures"`
+	SuccessRate float64   `json:"success_rate"`
+	// latencies are in milliseconds up to the first chunk of the responses
+	LatencyP50 int64 `json:"latency_p50"`
+	LatencyP95 int64 `json:"latency_p95"`
+	LatencyP99 int64 `json:"latency_p99"`
+	// ErrorBudgetRemaining is the fraction of failures allowed by the objective left, negative once overspent
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRate is how fast the budget is burnt, the budget is used up by the end of the window at 1
+	BurnRate float64  `json:"burn_rate"`
+	Breached bool     `json:"breached"`
+	Reasons  []string `json:"reasons"`
+}
+
+var (
+	enabled          bool
+	defaultObjective Objective
+
+	invocations = newCollector()
+)
+
+func Init(config *app.Config) {
+	enabled = config.SLOEnabled
+	if !enabled {
+		return
+	}
+
+	defaultObjective = Objective{
+		SuccessRate: config.SLOSuccessRate,
+		LatencyP99:  config.SLOLatencyP99,
+		Window:      config.SLOWindow,
+	}
+
+	events.Subscribe(func(event events.InvocationCompleted) {
+		invocations.add(event.PluginUniqueIdentifier.PluginID(), time.Now(), event.Latency, event.Failed)
+	})
+
+	go func() {
+		for range time.NewTicker(flushInterval).C {
+			flush()
+		}
+	}()
+
+	singleton_job.Register(singleton_job.Job{
+		Name:     "plugin_slo_evaluation",
+		Interval: evaluationInterval,
+		Run:      evaluate,
+	})
+}
+
+func bucketKey(pluginId string, minute int64) string {
+	return strings.Join([]string{SLO_BUCKET_KEY_PREFIX, pluginId, strconv.FormatInt(minute, 10)}, ":")
+}
+
+// flush adds buckets collected by this node to the ones in redis
+func flush() {
+	for pluginId, minutes := range invocations.drain() {
+		latest := int64(0)
+		for minute, b := range minutes {
+			latest = max(latest, minute)
+			key := bucketKey(pluginId, minute)
+			if err := cache.Transaction(func(p redis.Pipeliner) error {
+				for field, n := range b.fields() {
+					if _, err := cache.IncreaseMapField(key, field, n, p); err != nil {
+						return err
+					}
+				}
+				return cache.SetExpire(key, (MAX_WINDOW+1)*time.Minute, p)
+			}); err != nil {
+				log.Error("failed to flush invocations of plugin %s: %s", pluginId, err.Error())
+			}
+		}
+
+		if err := cache.SetMapOneField(SLO_PLUGINS_HASH_MAP_KEY, pluginId, latest); err != nil {
+			log.Error("failed to mark plugin %s invoked: %s", pluginId, err.Error())
+		}
+	}
+}
+
+// window returns invocations of the plugin within the last minutes
+func window(pluginId string, minutes int, now time.Time) (*bucket, error) {
+	current := now.Unix() / 60
+	keys := make([]string, 0, minutes)
+	for i := 0; i < minutes; i++ {
+		keys = append(keys, bucketKey(pluginId, current-int64(i)))
+	}
+
+	buckets, err := cache.GetMaps[int64](keys)
+	if err != nil {
+		return nil, err
+	}
+
+	total := newBucket()
+	for _, fields := range buckets {
+		total.merge(bucketFromFields(fields))
+	}
+	return total, nil
+}
+
+// summarize checks invocations within the window against the objective
+func summarize(pluginId string, objective Objective, b *bucket) Report {
+	report := Report{
+		PluginID:             pluginId,
+		Objective:            objective,
+		Invocations:          b.Invocations,
+		Failures:             b.Failures,
+		SuccessRate:          1,
+		LatencyP50:           b.percentile(0.5),
+		LatencyP95:           b.percentile(0.95),
+		LatencyP99:           b.percentile(0.99),
+		ErrorBudgetRemaining: 1,
+		Reasons:              []string{},
+	}
+	if b.Invocations == 0 {
+		return report
+	}
+
+	failureRate := float64(b.Failures) / float64(b.Invocations)
+	report.SuccessRate = 1 - failureRate
+
+	allowedFailureRate := 1 - objective.SuccessRate
+	if allowedFailureRate > 0 {
+		report.BurnRate = failureRate / allowedFailureRate
+		report.ErrorBudgetRemaining = 1 - report.BurnRate
+	} else if b.Failures > 0 {
+		// no failure is allowed
+		report.BurnRate = 1
+		report.ErrorBudgetRemaining = -1
+	}
+
+	if b.Invocations < minInvocations {
+		return report
+	}
+
+	if report.ErrorBudgetRemaining <= 0 {
+		report.Reasons = append(report.Reasons, fmt.Sprintf(
+			"success rate %.4f is below the objective %.4f", report.SuccessRate, objective.SuccessRate,
+		))
+	}
+	if objective.LatencyP99 > 0 && report.LatencyP99 > objective.LatencyP99 {
+		report.Reasons = append(report.Reasons, fmt.Sprintf(
+			"p99 latency %dms exceeds the objective %dms", report.LatencyP99, objective.LatencyP99,
+		))
+	}
+	report.Breached = len(report.Reasons) > 0
+	return report
+}
+
+// objectives returns objectives set by operators keyed by plugin ids
+func objectives() (map[string]Objective, error) {
+	slos, err := db.GetAll[models.PluginSLO]()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Objective, len(slos))
+	for _, slo := range slos {
+		result[slo.PluginID] = objectiveOf(&slo)
+	}
+	return result, nil
+}
+
+func objectiveOf(slo *models.PluginSLO) Objective {
+	objective := Objective{
+		SuccessRate: slo.SuccessRate,
+		LatencyP99:  slo.LatencyP99,
+		Window:      slo.Window,
+	}
+	if objective.Window == 0 {
+		objective.Window = defaultObjective.Window
+	}
+	return objective
+}
+
+// Reports returns reports of plugins invoked within the longest window, or the plugin if it's given
+func Reports(pluginId string) ([]Report, error) {
+	if !enabled {
+		return nil, fmt.Errorf("slo tracking is disabled")
+	}
+
+	objectives, err := objectives()
+	if err != nil {
+		return nil, err
+	}
+
+	plugins, err := invokedPlugins(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if pluginId != "" {
+		plugins = []string{pluginId}
+	}
+
+	reports := make([]Report, 0, len(plugins))
+	for _, plugin := range plugins {
+		objective, ok := objectives[plugin]
+		if !ok {
+			objective = defaultObjective
+		}
+
+		b, err := window(plugin, objective.Window, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, summarize(plugin, objective, b))
+	}
+	return reports, nil
+}
+
+// invokedPlugins returns plugins invoked within the longest window, the others are forgotten
+func invokedPlugins(now time.Time) ([]string, error) {
+	plugins, err := cache.GetMap[int64](SLO_PLUGINS_HASH_MAP_KEY)
+	if err != nil && err != cache.ErrNotFound {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(plugins))
+	for plugin, minute := range plugins {
+		if now.Unix()/60-minute > MAX_WINDOW {
+			cache.DelMapField(SLO_PLUGINS_HASH_MAP_KEY, plugin)
+			continue
+		}
+		result = append(result, plugin)
+	}
+	return result, nil
+}
+
+// evaluate reports plugins breaching their objectives, each breach is reported once until the plugin recovers
+func evaluate() error {
+	reports, err := Reports("")
+	if err != nil {
+		return err
+	}
+
+	breaches, err := cache.GetMap[int64](SLO_BREACHES_HASH_MAP_KEY)
+	if err != nil && err != cache.ErrNotFound {
+		return err
+	}
+
+	for _, report := range reports {
+		_, reported := breaches[report.PluginID]
+		delete(breaches, report.PluginID)
+		switch {
+		case report.Breached && !reported:
+			log.Warn("plugin %s breached its objectives: %s", report.PluginID, strings.Join(report.Reasons, ", "))
+			if err := cache.SetMapOneField(SLO_BREACHES_HASH_MAP_KEY, report.PluginID, time.Now().Unix()); err != nil {
+				return err
+			}
+			events.Publish(events.PluginSLOBreached{
+				PluginID:             report.PluginID,
+				Invocations:          report.Invocations,
+				SuccessRate:          report.SuccessRate,
+				ErrorBudgetRemaining: report.ErrorBudgetRemaining,
+				LatencyP99:           report.LatencyP99,
+				Reasons:              report.Reasons,
+			})
+		case !report.Breached && reported:
+			log.Info("plugin %s recovered to its objectives", report.PluginID)
+			if err := cache.DelMapField(SLO_BREACHES_HASH_MAP_KEY, report.PluginID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// plugins not invoked any more are forgotten
+	for plugin := range breaches {
+		if err := cache.DelMapField(SLO_BREACHES_HASH_MAP_KEY, plugin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/histogram"
+)
+
+func TestSummarizeBurnsErrorBudget(t *testing.T) {
+	b := newBucket()
+	for i := 0; i < 98; i++ {
+		b.add(80*time.Millisecond, false)
+	}
+	b.add(3*time.Second, true)
+	b.add(3*time.Second, true)
+
+	report := summarize("langgenius/openai", Objective{SuccessRate: 0.99, Window: 60}, b)
+	if report.SuccessRate != 0.98 || report.BurnRate < 1.99 || report.BurnRate > 2.01 {
+		t.Fatalf("unexpected success and burn rate: %+v", report)
+	}
+	if !report.Breached || report.ErrorBudgetRemaining >= 0 {
+		t.Fatalf("the plugin should have overspent its budget: %+v", report)
+	}
+	if report.LatencyP50 != 100 || report.LatencyP99 != 5000 {
+		t.Fatalf("unexpected percentiles: p50 %d, p99 %d", report.LatencyP50, report.LatencyP99)
+	}
+
+	report = summarize("langgenius/openai", Objective{SuccessRate: 0.95, LatencyP99: 1000, Window: 60}, b)
+	if report.ErrorBudgetRemaining <= 0 || len(report.Reasons) != 1 {
+		t.Fatalf("only the latency objective should be breached: %+v", report)
+	}
+}
+
+func TestSummarizeIgnoresRarelyInvokedPlugins(t *testing.T) {
+	b := newBucket()
+	b.add(time.Millisecond, true)
+
+	report := summarize("langgenius/openai", Objective{SuccessRate: 0.99, Window: 60}, b)
+	if report.Breached {
+		t.Fatal("plugins invoked fewer times than the minimum should not be breached")
+	}
+}
+
+func TestBucketRoundTrip(t *testing.T) {
+	c := newCollector()
+	at := time.Unix(600, 0)
+	c.add("langgenius/openai", at, 200*time.Millisecond, false)
+	c.add("langgenius/openai", at.Add(10*time.Second), 2*time.Minute, true)
+
+	buckets := c.drain()
+	b := buckets["langgenius/openai"][10]
+	if b == nil || b.Invocations != 2 || b.Failures != 1 {
+		t.Fatalf("invocations within a minute should share a bucket, got %+v", buckets)
+	}
+	if len(c.drain()) != 0 {
+		t.Fatal("drained buckets should not be returned again")
+	}
+
+	restored := bucketFromFields(b.fields())
+	if restored.Invocations != 2 || restored.Latencies[2] != 1 || restored.Latencies[len(histogram.Bounds)] != 1 {
+		t.Fatalf("bucket should be restored from its fields, got %+v", restored)
+	}
+}
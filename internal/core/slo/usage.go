@@ -0,0 +1,83 @@
+package slo
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Usage is invocations of plugins within the last minutes, it's read from the buckets tracked for
+// the objectives so it's only available while slo tracking is enabled
+type Usage struct {
+	// Window is in minutes
+	Window      int   `json:"window"`
+	Invocations int64 `json:"invocations"`
+	Failures    int64 `json:"failures"`
+	// Throughput is invocations per minute
+	Throughput float64       `json:"throughput"`
+	ErrorRate  float64       `json:"error_rate"`
+	Plugins    []PluginUsage `json:"plugins"`
+}
+
+type PluginUsage struct {
+	PluginID    string  `json:"plugin_id"`
+	Invocations int64   `json:"invocations"`
+	Failures    int64   `json:"failures"`
+	ErrorRate   float64 `json:"error_rate"`
+	// LatencyP99 is in milliseconds
+	LatencyP99 int64 `json:"latency_p99"`
+}
+
+// TopUsage returns invocations within the last minutes, plugins are sorted by invocations and at most
+// limit of them are returned
+func TopUsage(minutes int, limit int) (*Usage, error) {
+	if !enabled {
+		return nil, fmt.Errorf("slo tracking is disabled")
+	}
+	if minutes <= 0 || minutes > MAX_WINDOW {
+		return nil, fmt.Errorf("window must be between 1 and %d minutes", MAX_WINDOW)
+	}
+
+	now := time.Now()
+	plugins, err := invokedPlugins(now)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{Window: minutes, Plugins: make([]PluginUsage, 0, len(plugins))}
+	for _, plugin := range plugins {
+		b, err := window(plugin, minutes, now)
+		if err != nil {
+			return nil, err
+		}
+		if b.Invocations == 0 {
+			continue
+		}
+
+		usage.Invocations += b.Invocations
+		usage.Failures += b.Failures
+		usage.Plugins = append(usage.Plugins, PluginUsage{
+			PluginID:    plugin,
+			Invocations: b.Invocations,
+			Failures:    b.Failures,
+			ErrorRate:   float64(b.Failures) / float64(b.Invocations),
+			LatencyP99:  b.percentile(0.99),
+		})
+	}
+
+	usage.Throughput = float64(usage.Invocations) / float64(minutes)
+	if usage.Invocations > 0 {
+		usage.ErrorRate = float64(usage.Failures) / float64(usage.Invocations)
+	}
+
+	sort.Slice(usage.Plugins, func(i, j int) bool {
+		if usage.Plugins[i].Invocations != usage.Plugins[j].Invocations {
+			return usage.Plugins[i].Invocations > usage.Plugins[j].Invocations
+		}
+		return usage.Plugins[i].PluginID < usage.Plugins[j].PluginID
+	})
+	if limit > 0 && len(usage.Plugins) > limit {
+		usage.Plugins = usage.Plugins[:limit]
+	}
+	return usage, nil
+}
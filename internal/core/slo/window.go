@@ -0,0 +1,109 @@
+package slo
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/histogram"
+)
+
+const (
+	FIELD_INVOCATIONS    = "invocations"
+	FIELD_FAILURES       = "failures"
+	FIELD_LATENCY_PREFIX = "latency_"
+)
+
+// bucket is invocations of a plugin within a minute
+type bucket struct {
+	Invocations int64
+	Failures    int64
+	// Latencies is the histogram of latencies, see histogram.Bounds
+	Latencies []int64
+}
+
+func newBucket() *bucket {
+	return &bucket{Latencies: histogram.New()}
+}
+
+func (b *bucket) add(latency time.Duration, failed bool) {
+	b.Invocations++
+	if failed {
+		b.Failures++
+	}
+	histogram.Observe(b.Latencies, latency)
+}
+
+func (b *bucket) merge(other *bucket) {
+	b.Invocations += other.Invocations
+	b.Failures += other.Failures
+	histogram.Merge(b.Latencies, other.Latencies)
+}
+
+// fields returns the bucket as fields of the hash map stored in redis
+func (b *bucket) fields() map[string]int64 {
+	fields := map[string]int64{
+		FIELD_INVOCATIONS: b.Invocations,
+		FIELD_FAILURES:    b.Failures,
+	}
+	for i, n := range b.Latencies {
+		if n > 0 {
+			fields[FIELD_LATENCY_PREFIX+strconv.Itoa(i)] = n
+		}
+	}
+	return fields
+}
+
+func bucketFromFields(fields map[string]int64) *bucket {
+	b := newBucket()
+	b.Invocations = fields[FIELD_INVOCATIONS]
+	b.Failures = fields[FIELD_FAILURES]
+	for i := range b.Latencies {
+		b.Latencies[i] = fields[FIELD_LATENCY_PREFIX+strconv.Itoa(i)]
+	}
+	return b
+}
+
+func (b *bucket) percentile(p float64) int64 {
+	return histogram.Percentile(b.Latencies, p)
+}
+
+// collector accumulates invocations served by this node until they're flushed to redis
+type collector struct {
+	lock sync.Mutex
+	// buckets are keyed by plugin ids and then unix minutes
+	buckets map[string]map[int64]*bucket
+}
+
+func newCollector() *collector {
+	return &collector{buckets: map[string]map[int64]*bucket{}}
+}
+
+func (c *collector) add(pluginId string, at time.Time, latency time.Duration, failed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	minutes, ok := c.buckets[pluginId]
+	if !ok {
+		minutes = map[int64]*bucket{}
+		c.buckets[pluginId] = minutes
+	}
+
+	minute := at.Unix() / 60
+	b, ok := minutes[minute]
+	if !ok {
+		b = newBucket()
+		minutes[minute] = b
+	}
+	b.add(latency, failed)
+}
+
+// drain returns the buckets collected since the last drain
+func (c *collector) drain() map[string]map[int64]*bucket {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	buckets := c.buckets
+	c.buckets = map[string]map[int64]*bucket{}
+	return buckets
+}
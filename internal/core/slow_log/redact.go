@@ -0,0 +1,53 @@
+package slow_log
+
+import (
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
+)
+
+const (
+	REDACTED = redact.REDACTED
+
+	maxPayloadStringLength = 1024
+)
+
+// Redact returns a copy of the payload with values of sensitive keys replaced and long strings truncated,
+// nested structs are walked as their json forms
+func Redact(payload map[string]any) map[string]any {
+	normalized, err := parser.UnmarshalJsonBytes2Map(parser.MarshalJsonBytes(payload))
+	if err != nil {
+		return nil
+	}
+	redacted, _ := redactValue(normalized).(map[string]any)
+	return redacted
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for key, value := range v {
+			if redact.SensitiveKey(key) {
+				redacted[key] = REDACTED
+			} else {
+				redacted[key] = redactValue(value)
+			}
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, value := range v {
+			redacted[i] = redactValue(value)
+		}
+		return redacted
+	case string:
+		if len(v) > maxPayloadStringLength {
+			return fmt.Sprintf("%s...(%d bytes truncated)", v[:maxPayloadStringLength], len(v)-maxPayloadStringLength)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,151 @@
+// Package slow_log keeps invocations of plugins taking longer than a threshold, each entry carries the time
+// spent by each phase of the invocation, the latest entries are kept in a ring buffer of each node.
+package slow_log
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+type Entry struct {
+	Timestamp              int64          `json:"timestamp"`
+	SessionID              string         `json:"session_id"`
+	RequestID              string         `json:"request_id,omitempty"`
+	TenantID               string         `json:"tenant_id"`
+	PluginUniqueIdentifier string         `json:"plugin_unique_identifier"`
+	Action                 string         `json:"action"`
+	Timing                 Breakdown      `json:"timing"`
+	Error                  string         `json:"error,omitempty"`
+	Payload                map[string]any `json:"payload,omitempty"`
+}
+
+var (
+	enabled           bool
+	threshold         time.Duration
+	payloadSampleRate float64
+
+	lock    sync.RWMutex
+	entries []Entry
+	// next is where the next entry is written, entries are full once it wraps around
+	next int
+	full bool
+)
+
+func Init(config *app.Config) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	configure(config)
+	entries = make([]Entry, config.SlowLogCapacity)
+	next = 0
+	full = false
+}
+
+// Configure updates the threshold and the sample rate of payloads, recorded entries are kept
+func Configure(config *app.Config) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	configure(config)
+}
+
+func configure(config *app.Config) {
+	enabled = config.SlowLogEnabled
+	threshold = time.Duration(config.SlowLogThreshold) * time.Millisecond
+	payloadSampleRate = config.SlowLogPayloadSampleRate
+}
+
+// Slow returns true if an invocation lasting for the duration should be recorded
+func Slow(duration time.Duration) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return enabled && duration >= threshold
+}
+
+// SamplePayload returns true if the payload should be attached to the entry
+func SamplePayload() bool {
+	lock.RLock()
+	rate := payloadSampleRate
+	lock.RUnlock()
+
+	return rate > 0 && rand.Float64() < rate
+}
+
+// Record logs the entry and keeps it in the ring buffer, the oldest entry is dropped once it's full
+func Record(entry Entry) {
+	lock.RLock()
+	on := enabled
+	lock.RUnlock()
+	if !on {
+		return
+	}
+
+	log.With(log.FIELD_REQUEST_ID, entry.RequestID).
+		With(log.FIELD_TENANT_ID, entry.TenantID).
+		With(log.FIELD_SESSION_ID, entry.SessionID).
+		Warn(
+			"slow invocation %s of plugin %s took %dms: queue %dms, compute %dms, backwards invocations %dms, streaming %dms",
+			entry.Action, entry.PluginUniqueIdentifier, entry.Timing.Total, entry.Timing.Queue,
+			entry.Timing.Compute, entry.Timing.BackwardsInvocations, entry.Timing.Streaming,
+		)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+	entries[next] = entry
+	next = (next + 1) % len(entries)
+	if next == 0 {
+		full = true
+	}
+}
+
+// Filter selects entries, empty fields match any entry
+type Filter struct {
+	// Plugin matches both plugin ids and unique identifiers of the plugin
+	Plugin   string
+	TenantID string
+	Limit    int
+}
+
+func (f Filter) match(entry Entry) bool {
+	if f.Plugin != "" && entry.PluginUniqueIdentifier != f.Plugin &&
+		!strings.HasPrefix(entry.PluginUniqueIdentifier, f.Plugin+":") {
+		return false
+	}
+	if f.TenantID != "" && entry.TenantID != f.TenantID {
+		return false
+	}
+	return true
+}
+
+// List returns entries matching the filter, the latest come first
+func List(filter Filter) []Entry {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	result := []Entry{}
+	count := next
+	if full {
+		count = len(entries)
+	}
+	for i := 1; i <= count; i++ {
+		entry := entries[(next-i+len(entries))%len(entries)]
+		if !filter.match(entry) {
+			continue
+		}
+		result = append(result, entry)
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package slow_log
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func TestRingBufferKeepsLatestEntries(t *testing.T) {
+	Init(&app.Config{SlowLogEnabled: true, SlowLogThreshold: 100, SlowLogCapacity: 2})
+
+	Record(Entry{SessionID: "1", PluginUniqueIdentifier: "langgenius/openai:0.0.1@abc"})
+	Record(Entry{SessionID: "2", PluginUniqueIdentifier: "langgenius/anthropic:0.0.1@abc"})
+	Record(Entry{SessionID: "3", PluginUniqueIdentifier: "langgenius/openai:0.0.2@abc"})
+
+	entries := List(Filter{})
+	if len(entries) != 2 || entries[0].SessionID != "3" || entries[1].SessionID != "2" {
+		t.Fatalf("the latest entries should be kept and listed first, got %+v", entries)
+	}
+
+	entries = List(Filter{Plugin: "langgenius/openai"})
+	if len(entries) != 1 || entries[0].SessionID != "3" {
+		t.Fatalf("entries should be filtered by plugin, got %+v", entries)
+	}
+
+	if Slow(99*time.Millisecond) || !Slow(100*time.Millisecond) {
+		t.Fatal("invocations reaching the threshold should be slow")
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	startedAt := time.Now()
+	timing := &Timing{
+		startedAt:    startedAt,
+		writtenAt:    startedAt.Add(10 * time.Millisecond),
+		firstChunkAt: startedAt.Add(510 * time.Millisecond),
+		backwards:    200 * time.Millisecond,
+	}
+
+	breakdown := timing.Breakdown(startedAt.Add(600 * time.Millisecond))
+	if breakdown != (Breakdown{Total: 600, Queue: 10, Compute: 300, BackwardsInvocations: 200, Streaming: 90}) {
+		t.Fatalf("unexpected breakdown: %+v", breakdown)
+	}
+
+	if (*Timing)(nil).Breakdown(time.Now()) != (Breakdown{}) {
+		t.Fatal("nil timings should break down to zero")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	redacted := Redact(map[string]any{
+		"credentials": map[string]any{"api_key": "sk-xxx"},
+		"tool_parameters": map[string]any{
+			"query":            "weather",
+			"Authorization":    "Bearer xxx",
+			"raw_http_request": strings.Repeat("a", maxPayloadStringLength+10),
+		},
+	})
+
+	if redacted["credentials"] != REDACTED {
+		t.Fatalf("credentials should be redacted, got %v", redacted["credentials"])
+	}
+	parameters := redacted["tool_parameters"].(map[string]any)
+	if parameters["query"] != "weather" || parameters["Authorization"] != REDACTED {
+		t.Fatalf("only sensitive keys should be redacted, got %v", parameters)
+	}
+	if !strings.HasSuffix(parameters["raw_http_request"].(string), "(10 bytes truncated)") {
+		t.Fatal("long strings should be truncated")
+	}
+}
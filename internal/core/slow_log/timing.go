@@ -0,0 +1,96 @@
+package slow_log
+
+import (
+	"sync"
+	"time"
+)
+
+// Timing records the phases of an invocation, it's safe to be used by the routines serving the session
+// and nil timings record nothing
+type Timing struct {
+	lock sync.Mutex
+
+	startedAt    time.Time
+	writtenAt    time.Time
+	firstChunkAt time.Time
+	backwards    time.Duration
+}
+
+func NewTiming() *Timing {
+	return &Timing{startedAt: time.Now()}
+}
+
+// RequestWritten marks the request has been handed over to the plugin
+func (t *Timing) RequestWritten() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.writtenAt.IsZero() {
+		t.writtenAt = time.Now()
+	}
+}
+
+// ChunkReceived marks a response chunk has been received from the plugin, only the first one counts
+func (t *Timing) ChunkReceived() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.firstChunkAt.IsZero() {
+		t.firstChunkAt = time.Now()
+	}
+}
+
+// AddBackwardsInvocation adds the time spent by a backwards invocation made by the plugin
+func (t *Timing) AddBackwardsInvocation(duration time.Duration) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.backwards += duration
+}
+
+// Breakdown is the time spent by each phase of an invocation in milliseconds
+//   - queue: from the session being created to the request written to the plugin
+//   - compute: from the request written to the first chunk, backwards invocations excluded
+//   - backwards_invocations: invocations from the plugin back to dify
+//   - streaming: from the first chunk to the end of the response
+type Breakdown struct {
+	Total                int64 `json:"total"`
+	Queue                int64 `json:"queue"`
+	Compute              int64 `json:"compute"`
+	BackwardsInvocations int64 `json:"backwards_invocations"`
+	Streaming            int64 `json:"streaming"`
+}
+
+// Breakdown returns the phases of the invocation ended at the end, phases never reached are zero
+func (t *Timing) Breakdown(end time.Time) Breakdown {
+	if t == nil {
+		return Breakdown{}
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	breakdown := Breakdown{
+		Total:                end.Sub(t.startedAt).Milliseconds(),
+		BackwardsInvocations: t.backwards.Milliseconds(),
+	}
+	if t.writtenAt.IsZero() {
+		breakdown.Queue = breakdown.Total
+		return breakdown
+	}
+	breakdown.Queue = t.writtenAt.Sub(t.startedAt).Milliseconds()
+
+	computeEnd := t.firstChunkAt
+	if computeEnd.IsZero() {
+		computeEnd = end
+	} else {
+		breakdown.Streaming = end.Sub(t.firstChunkAt).Milliseconds()
+	}
+	breakdown.Compute = max(computeEnd.Sub(t.writtenAt).Milliseconds()-breakdown.BackwardsInvocations, 0)
+	return breakdown
+}
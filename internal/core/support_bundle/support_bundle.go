@@ -0,0 +1,207 @@
+// Package support_bundle writes a zip archive of what's needed to diagnose the daemon, the configuration,
+// versions, plugins running on the node, recent logs, goroutines and metrics, to attach to bug reports.
+// Secrets are redacted, values of sensitive configurations are masked and logs are redacted as outputs of
+// plugins are.
+package support_bundle
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/manifest"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
+)
+
+// sensitiveSuffixes are suffixes of names of configurations holding secrets, besides keys redact.SensitiveKey
+// matches, names like SERVER_KEY or SENTRY_DSN
+var sensitiveSuffixes = []string{"_KEY", "_KEYS", "_DSN", "_SECRET_ID"}
+
+func sensitiveConfig(name string) bool {
+	if redact.SensitiveKey(name) {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactConfig returns configurations by their environment variables, strings of sensitive ones are masked
+// and passwords of urls are removed
+func RedactConfig(config *app.Config) map[string]any {
+	redacted := map[string]any{}
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Tag.Get("envconfig")
+		if name == "" {
+			continue
+		}
+		redacted[name] = redactValue(name, value.Field(i))
+	}
+	return redacted
+}
+
+func redactValue(name string, value reflect.Value) any {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return nil
+		}
+		return redactValue(name, value.Elem())
+	case reflect.String:
+		if value.String() == "" {
+			return ""
+		}
+		if sensitiveConfig(name) {
+			return redact.REDACTED
+		}
+		return redactURL(value.String())
+	case reflect.Slice, reflect.Array:
+		items := []any{}
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, redactValue(name, value.Index(i)))
+		}
+		return items
+	case reflect.Map:
+		if value.Len() == 0 {
+			return map[string]any{}
+		}
+		if sensitiveConfig(name) {
+			return redact.REDACTED
+		}
+		items := map[string]any{}
+		iter := value.MapRange()
+		for iter.Next() {
+			items[fmt.Sprint(iter.Key().Interface())] = redactValue(name, iter.Value())
+		}
+		return items
+	}
+	return value.Interface()
+}
+
+// redactURL removes passwords of urls, like ones of databases or proxies
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return redact.String(value)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redact.REDACTED)
+	}
+	return u.String()
+}
+
+type Version struct {
+	Version     string    `json:"version"`
+	BuildTime   string    `json:"build_time"`
+	GoVersion   string    `json:"go_version"`
+	OS          string    `json:"os"`
+	Arch        string    `json:"arch"`
+	Hostname    string    `json:"hostname"`
+	Goroutines  int       `json:"goroutines"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// Extra is a file added to the bundle by the server, like states of the cluster
+type Extra struct {
+	Name    string
+	Collect func() (any, error)
+}
+
+// Write writes the bundle, failures of collecting files are written to errors.txt instead of failing the bundle
+func Write(w io.Writer, config *app.Config, extras ...Extra) error {
+	archive := zip.NewWriter(w)
+	errs := []string{}
+
+	file := func(name string, write func(w io.Writer) error) {
+		f, err := archive.Create(name)
+		if err == nil {
+			err = write(f)
+		}
+		if err != nil {
+			errs = append(errs, name+": "+err.Error())
+		}
+	}
+	jsonFile := func(name string, collect func() (any, error)) {
+		file(name, func(w io.Writer) error {
+			data, err := collect()
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(data)
+		})
+	}
+
+	jsonFile("version.json", func() (any, error) {
+		hostname, _ := os.Hostname()
+		return Version{
+			Version:     manifest.VersionX,
+			BuildTime:   manifest.BuildTimeX,
+			GoVersion:   runtime.Version(),
+			OS:          runtime.GOOS,
+			Arch:        runtime.GOARCH,
+			Hostname:    hostname,
+			Goroutines:  runtime.NumGoroutine(),
+			GeneratedAt: time.Now().UTC(),
+		}, nil
+	})
+	jsonFile("config.json", func() (any, error) {
+		return RedactConfig(config), nil
+	})
+	jsonFile("plugins.json", func() (any, error) {
+		manager := plugin_manager.Manager()
+		if manager == nil {
+			return []plugin_manager.RuntimeInfo{}, nil
+		}
+		runtimes := manager.Runtimes()
+		for i := range runtimes {
+			for j, line := range runtimes[i].State.Logs {
+				runtimes[i].State.Logs[j] = redact.Any(line)
+			}
+		}
+		return runtimes, nil
+	})
+	for _, extra := range extras {
+		jsonFile(extra.Name, extra.Collect)
+	}
+	file("logs.txt", func(w io.Writer) error {
+		for _, line := range log.Recent() {
+			if _, err := io.WriteString(w, redact.Any(line)+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	file("goroutines.txt", func(w io.Writer) error {
+		return pprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	file("metrics.txt", metrics.Write)
+
+	if len(errs) > 0 {
+		f, err := archive.Create("errors.txt")
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, strings.Join(errs, "\n")+"\n"); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,138 @@
+package tenant_encryption
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// registerSettingsStore makes secrets of endpoint settings encrypted by tenant keys re-encrypted on rotating
+// keys, so that keys still encrypting settings of idle endpoints are not reported as removable
+func registerSettingsStore() {
+	keyring.RegisterStore(keyring.Store{
+		Name:      "endpoint_settings",
+		Versions:  settingsKeyVersions,
+		Reencrypt: reencryptSettings,
+	})
+}
+
+// encryptedEndpoints returns endpoints whose settings may contain secrets encrypted by tenant keys,
+// settings are serialized as json so such secrets are values starting with the prefix
+func encryptedEndpoints() ([]models.Endpoint, error) {
+	return db.GetAll[models.Endpoint](
+		db.Like("settings", `:"`+prefix),
+	)
+}
+
+func settingsKeyVersions() (map[int]int64, error) {
+	endpoints, err := encryptedEndpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[int]int64{}
+	for _, endpoint := range endpoints {
+		for _, value := range endpoint.Settings {
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if version, _, ok := parseValue(text); ok {
+				versions[version]++
+			}
+		}
+	}
+	return versions, nil
+}
+
+// reencryptSettings encrypts secrets of endpoint settings not encrypted by the active tenant key again,
+// each endpoint is locked and re-encrypted in its own transaction
+func reencryptSettings() (int, error) {
+	active := keyring.ActiveVersion()
+	endpoints, err := encryptedEndpoints()
+	if err != nil {
+		return 0, err
+	}
+
+	reencrypted := 0
+	var errs []error
+	for _, endpoint := range endpoints {
+		if !staleValues(endpoint.Settings, active) {
+			continue
+		}
+
+		err := db.WithTransaction(func(tx *gorm.DB) error {
+			record, err := db.GetOne[models.Endpoint](
+				db.WithTransactionContext(tx),
+				db.Equal("id", endpoint.ID),
+				db.WLock(),
+			)
+			if err != nil {
+				return err
+			}
+			if !staleValues(record.Settings, active) {
+				// updated or re-encrypted by another node
+				return nil
+			}
+
+			settings, err := reencryptValues(record.TenantID, record.Settings, active)
+			if err != nil {
+				return err
+			}
+			record.Settings = settings
+			if err := db.Update(&record, tx); err != nil {
+				return err
+			}
+
+			reencrypted++
+			return nil
+		})
+		if err != nil && !errors.Is(err, db.ErrDatabaseNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return reencrypted, errors.Join(errs...)
+}
+
+// staleValues reports whether any value of the settings is encrypted by a tenant key other than the active one
+func staleValues(settings map[string]any, active int) bool {
+	for _, value := range settings {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if version, _, ok := parseValue(text); ok && version != active {
+			return true
+		}
+	}
+	return false
+}
+
+// reencryptValues returns the settings with values encrypted by stale tenant keys encrypted by the active one
+func reencryptValues(tenantID string, settings map[string]any, active int) (map[string]any, error) {
+	result := make(map[string]any, len(settings))
+	for key, value := range settings {
+		result[key] = value
+
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if version, _, ok := parseValue(text); !ok || version == active {
+			continue
+		}
+
+		plaintext, err := decryptValue(tenantID, text)
+		if err != nil {
+			return nil, err
+		}
+		if result[key], err = encryptValue(tenantID, plaintext); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,229 @@
+// Package tenant_encryption encrypts secret settings passed through the encrypt invocation by keys derived
+// for each tenant from the keyring, instead of forwarding them to dify which encrypts them by a single key,
+// so that a leaked key exposes the credentials of one workspace only.
+//
+// Secrets encrypted by a tenant key look like `tk<key version>:<envelope>`, the others were encrypted by
+// dify and are still decrypted by it. Both of them, and secrets encrypted by keys no longer active, are
+// stale and re-encrypted by the owner of the settings once they are accessed. Secrets of endpoint settings
+// encrypted by keys no longer active are re-encrypted by the keyring as well, idle endpoints included.
+package tenant_encryption
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	prefix = "tk"
+)
+
+var enabled = false
+
+func Init(config *app.Config) {
+	// secrets encrypted before tenant keys were disabled still depend on the keyring
+	registerSettingsStore()
+
+	if !config.TenantEncryptionEnabled {
+		return
+	}
+
+	if keyring.ActiveVersion() == keyring.PLAIN_TEXT_VERSION {
+		log.Panic("tenant scoped encryption requires ENCRYPTION_KEYS to be configured")
+	}
+
+	enabled = true
+}
+
+// Enabled reports whether secret settings are encrypted by tenant keys
+func Enabled() bool {
+	return enabled
+}
+
+// Wrap makes the encrypt invocation of invocation use tenant keys if they're enabled
+func Wrap(invocation dify_invocation.BackwardsInvocation) dify_invocation.BackwardsInvocation {
+	if !enabled {
+		return invocation
+	}
+
+	return &tenantScopedInvocation{BackwardsInvocation: invocation}
+}
+
+type tenantScopedInvocation struct {
+	dify_invocation.BackwardsInvocation
+}
+
+func (i *tenantScopedInvocation) InvokeEncrypt(payload *dify_invocation.InvokeEncryptRequest) (map[string]any, error) {
+	switch payload.Opt {
+	case dify_invocation.ENCRYPT_OPT_ENCRYPT:
+		return encryptSecrets(payload.TenantId, payload.Data, payload.Config)
+	case dify_invocation.ENCRYPT_OPT_DECRYPT:
+		return i.decryptSecrets(payload)
+	default:
+		// dify keeps caches of secrets decrypted by it
+		return i.BackwardsInvocation.InvokeEncrypt(payload)
+	}
+}
+
+func secretNames(config []plugin_entities.ProviderConfig) []string {
+	names := []string{}
+	for _, c := range config {
+		if c.Type == plugin_entities.CONFIG_TYPE_SECRET_INPUT {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+func encryptSecrets(
+	tenantID string, data map[string]any, config []plugin_entities.ProviderConfig,
+) (map[string]any, error) {
+	encrypted := make(map[string]any, len(data))
+	for key, value := range data {
+		encrypted[key] = value
+	}
+
+	for _, name := range secretNames(config) {
+		value, ok := data[name].(string)
+		if !ok || value == "" {
+			continue
+		}
+
+		ciphertext, err := encryptValue(tenantID, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %s", name, err.Error())
+		}
+		encrypted[name] = ciphertext
+	}
+
+	return encrypted, nil
+}
+
+func (i *tenantScopedInvocation) decryptSecrets(payload *dify_invocation.InvokeEncryptRequest) (map[string]any, error) {
+	// secrets encrypted by dify are decrypted by it, the others are kept from it
+	decrypted := map[string]any{}
+	remote := make(map[string]any, len(payload.Data))
+	for key, value := range payload.Data {
+		remote[key] = value
+	}
+
+	forward := false
+	for _, name := range secretNames(payload.Config) {
+		value, ok := payload.Data[name].(string)
+		if !ok || value == "" {
+			continue
+		}
+
+		if _, _, ok := parseValue(value); !ok {
+			forward = true
+			continue
+		}
+
+		plaintext, err := decryptValue(payload.TenantId, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %s", name, err.Error())
+		}
+		decrypted[name] = plaintext
+		delete(remote, name)
+	}
+
+	result := remote
+	if forward {
+		request := *payload
+		request.Data = remote
+
+		var err error
+		if result, err = i.BackwardsInvocation.InvokeEncrypt(&request); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range decrypted {
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+func encryptValue(tenantID string, value string) (string, error) {
+	version := keyring.ActiveVersion()
+	key, err := keyring.DerivedKey(version, "tenant:"+tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	envelope, err := encryption.SealEnvelope(keyring.Cipher(), key, []byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%d:%s", prefix, version, envelope), nil
+}
+
+func decryptValue(tenantID string, value string) (string, error) {
+	version, envelope, ok := parseValue(value)
+	if !ok {
+		return "", fmt.Errorf("not encrypted by a tenant key")
+	}
+
+	key, err := keyring.DerivedKey(version, "tenant:"+tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := encryption.OpenEnvelope(key, envelope)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// parseValue parses `tk<version>:<envelope>`, ciphertexts of dify are base64 and never contain `:`
+func parseValue(value string) (int, string, bool) {
+	head, envelope, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, "", false
+	}
+
+	versionText, ok := strings.CutPrefix(head, prefix)
+	if !ok {
+		return 0, "", false
+	}
+
+	version, err := strconv.Atoi(versionText)
+	if err != nil || version <= keyring.PLAIN_TEXT_VERSION {
+		return 0, "", false
+	}
+
+	return version, envelope, true
+}
+
+// Stale reports whether any secret of the encrypted settings is not encrypted by the active tenant key,
+// the settings should be encrypted again and saved after being decrypted
+func Stale(data map[string]any, config []plugin_entities.ProviderConfig) bool {
+	if !enabled {
+		return false
+	}
+
+	active := keyring.ActiveVersion()
+	for _, name := range secretNames(config) {
+		value, ok := data[name].(string)
+		if !ok || value == "" {
+			continue
+		}
+
+		if version, _, ok := parseValue(value); !ok || version != active {
+			return true
+		}
+	}
+
+	return false
+}
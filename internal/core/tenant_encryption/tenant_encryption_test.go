@@ -0,0 +1,166 @@
+package tenant_encryption
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// difyInvocation decrypts values prefixed by `dify:` like dify would do
+type difyInvocation struct {
+	dify_invocation.BackwardsInvocation
+	forwarded []map[string]any
+}
+
+func (d *difyInvocation) InvokeEncrypt(payload *dify_invocation.InvokeEncryptRequest) (map[string]any, error) {
+	d.forwarded = append(d.forwarded, payload.Data)
+	result := map[string]any{}
+	for key, value := range payload.Data {
+		if text, ok := value.(string); ok {
+			value = strings.TrimPrefix(text, "dify:")
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+var config = []plugin_entities.ProviderConfig{
+	{Name: "api_key", Type: plugin_entities.CONFIG_TYPE_SECRET_INPUT},
+	{Name: "region", Type: plugin_entities.CONFIG_TYPE_TEXT_INPUT},
+}
+
+// initKeyring configures keys of versions 1 and 2, the latter is active
+var initKeyring = sync.OnceFunc(func() {
+	key := func(c string) string { return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(c, 32))) }
+	keyring.Init(&app.Config{
+		EncryptionKeys:              "1:" + key("k") + ",2:" + key("n"),
+		EncryptionActiveKeyVersion:  2,
+		EncryptionCipher:            "aes-gcm",
+		EncryptionReencryptInterval: 3600,
+	})
+})
+
+func request(tenantID string, opt dify_invocation.EncryptOpt, data map[string]any) *dify_invocation.InvokeEncryptRequest {
+	return &dify_invocation.InvokeEncryptRequest{
+		BaseInvokeDifyRequest: dify_invocation.BaseInvokeDifyRequest{
+			TenantId: tenantID,
+			Type:     dify_invocation.INVOKE_TYPE_ENCRYPT,
+		},
+		InvokeEncryptSchema: dify_invocation.InvokeEncryptSchema{
+			Opt:       opt,
+			Namespace: dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT,
+			Identity:  "endpoint",
+			Data:      data,
+			Config:    config,
+		},
+	}
+}
+
+func TestTenantScopedEncryption(t *testing.T) {
+	initKeyring()
+	Init(&app.Config{TenantEncryptionEnabled: true})
+
+	dify := &difyInvocation{}
+	invocation := Wrap(dify)
+
+	encrypted, err := invocation.InvokeEncrypt(request("tenant-a", dify_invocation.ENCRYPT_OPT_ENCRYPT, map[string]any{
+		"api_key": "sk-secret",
+		"region":  "us",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dify.forwarded) != 0 {
+		t.Fatal("secrets should not be sent to dify for encryption")
+	}
+	if !strings.HasPrefix(encrypted["api_key"].(string), "tk2:") || encrypted["region"] != "us" {
+		t.Fatalf("unexpected encrypted settings %v", encrypted)
+	}
+	if Stale(encrypted, config) {
+		t.Error("settings encrypted by the active tenant key are not stale")
+	}
+
+	decrypted, err := invocation.InvokeEncrypt(request("tenant-a", dify_invocation.ENCRYPT_OPT_DECRYPT, encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted["api_key"] != "sk-secret" || decrypted["region"] != "us" {
+		t.Fatalf("unexpected decrypted settings %v", decrypted)
+	}
+	if len(dify.forwarded) != 0 {
+		t.Fatal("secrets encrypted by tenant keys should not be sent to dify for decryption")
+	}
+
+	// keys of other tenants can't decrypt the settings
+	if _, err := invocation.InvokeEncrypt(request("tenant-b", dify_invocation.ENCRYPT_OPT_DECRYPT, encrypted)); err == nil {
+		t.Fatal("settings of a tenant should not be decrypted by the key of another one")
+	}
+
+	// settings encrypted by dify are decrypted by it and stale
+	legacy := map[string]any{"api_key": "dify:sk-legacy", "region": "eu"}
+	if !Stale(legacy, config) {
+		t.Error("settings encrypted by dify should be stale")
+	}
+	decrypted, err = invocation.InvokeEncrypt(request("tenant-a", dify_invocation.ENCRYPT_OPT_DECRYPT, legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted["api_key"] != "sk-legacy" || len(dify.forwarded) != 1 {
+		t.Fatalf("unexpected decrypted settings %v", decrypted)
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"tk1:aes-gcm$abc":  true,
+		"tk12:aes-gcm$abc": true,
+		"tk0:aes-gcm$abc":  false,
+		"tkx:aes-gcm$abc":  false,
+		"c2stc2VjcmV0":     false,
+		"":                 false,
+	} {
+		if _, _, ok := parseValue(value); ok != expected {
+			t.Errorf("expected %q to be parsed=%v", value, expected)
+		}
+	}
+}
+
+func TestReencryptValues(t *testing.T) {
+	initKeyring()
+
+	// encrypted by the key of version 1 before version 2 became active
+	key, err := keyring.DerivedKey(1, "tenant:tenant-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := encryption.SealEnvelope(keyring.Cipher(), key, []byte("sk-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := map[string]any{"api_key": "tk1:" + envelope, "region": "us", "legacy": "c2stc2VjcmV0", "retries": 3}
+	if !staleValues(settings, 2) {
+		t.Fatal("values encrypted by the retired key should be stale")
+	}
+
+	reencrypted, err := reencryptValues("tenant-a", settings, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(reencrypted["api_key"].(string), "tk2:") || staleValues(reencrypted, 2) {
+		t.Fatalf("expected the secret to be encrypted by the active key, got %v", reencrypted)
+	}
+	if reencrypted["region"] != "us" || reencrypted["legacy"] != "c2stc2VjcmV0" || reencrypted["retries"] != 3 {
+		t.Fatalf("expected other values to be kept, got %v", reencrypted)
+	}
+	if plaintext, err := decryptValue("tenant-a", reencrypted["api_key"].(string)); err != nil || plaintext != "sk-secret" {
+		t.Fatalf("unexpected plaintext %q %v", plaintext, err)
+	}
+}
@@ -0,0 +1,54 @@
+package tenant_token
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	claims := Claims{
+		TenantID:  "tenant",
+		UserID:    "user",
+		Scopes:    []string{"plugins:invoke"},
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+	}
+	token, err := Sign("secret", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := Verify("secret", token, 5*time.Minute, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.TenantID != "tenant" || verified.UserID != "user" || len(verified.Scopes) != 1 {
+		t.Fatalf("unexpected claims %+v", verified)
+	}
+
+	if _, err := Verify("another", token, 5*time.Minute, now); err != ErrInvalidToken {
+		t.Fatalf("token signed by another secret should be rejected, got %v", err)
+	}
+	if _, err := Verify("", token, 5*time.Minute, now); err != ErrInvalidToken {
+		t.Fatalf("tokens should be rejected without a secret, got %v", err)
+	}
+	if _, err := Verify("secret", token, 5*time.Minute, now.Add(2*time.Minute)); err != ErrExpiredToken {
+		t.Fatalf("expired token should be rejected, got %v", err)
+	}
+	if _, err := Verify("secret", token, 30*time.Second, now); err != ErrInvalidToken {
+		t.Fatalf("token living longer than the max ttl should be rejected, got %v", err)
+	}
+
+	// the tenant can't be changed without breaking the signature
+	payload, signature, _ := strings.Cut(strings.TrimPrefix(token, TOKEN_PREFIX), ".")
+	forged, _ := Sign("another", Claims{TenantID: "victim", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()})
+	forgedPayload, _, _ := strings.Cut(strings.TrimPrefix(forged, TOKEN_PREFIX), ".")
+	if _, err := Verify("secret", TOKEN_PREFIX+forgedPayload+"."+signature, 5*time.Minute, now); err != ErrInvalidToken {
+		t.Fatalf("forged token should be rejected, got %v", err)
+	}
+	if _, err := Verify("secret", payload+"."+signature, 5*time.Minute, now); err != ErrInvalidToken {
+		t.Fatalf("token without the prefix should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+// Package usage_analytics rolls up invocations of plugins into daily usage of each tenant and plugin,
+// so that usage dashboards read a row per day instead of scanning every invocation.
+//
+// Invocations served by each node are collected by the hour and added to models.PluginUsageHourly
+// periodically by a single upsert of each row, a singleton job then rolls up the hourly rows of recent days into models.PluginUsageDaily
+// and removes hourly rows older than the retention.
+package usage_analytics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/singleton_job"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/histogram"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"gorm.io/gorm"
+)
+
+const (
+	flushInterval  = time.Minute
+	rollupInterval = time.Hour
+	// days rolled up by each run, the previous day is rolled up again to take rows flushed late
+	rollupDays = 2
+)
+
+type usageKey struct {
+	tenantId string
+	pluginId string
+	hour     time.Time
+}
+
+type usage struct {
+	invocations int64
+	errors      int64
+	tokens      int64
+	latencies   []int64
+}
+
+// collector accumulates invocations served by this node until they're flushed to the database
+type collector struct {
+	lock  sync.Mutex
+	usage map[usageKey]*usage
+}
+
+func newCollector() *collector {
+	return &collector{usage: map[usageKey]*usage{}}
+}
+
+func (c *collector) add(event events.InvocationCompleted, at time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := usageKey{
+		tenantId: event.TenantID,
+		pluginId: event.PluginUniqueIdentifier.PluginID(),
+		hour:     at.UTC().Truncate(time.Hour),
+	}
+	u, ok := c.usage[key]
+	if !ok {
+		u = &usage{latencies: histogram.New()}
+		c.usage[key] = u
+	}
+
+	u.invocations++
+	if event.Failed {
+		u.errors++
+	}
+	u.tokens += event.Tokens
+	histogram.Observe(u.latencies, event.Latency)
+}
+
+// requeue puts usage failed to be flushed back, it's merged into usage collected since it was drained
+func (c *collector) requeue(key usageKey, failed *usage) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	u, ok := c.usage[key]
+	if !ok {
+		c.usage[key] = failed
+		return
+	}
+	u.invocations += failed.invocations
+	u.errors += failed.errors
+	u.tokens += failed.tokens
+	histogram.Merge(u.latencies, failed.latencies)
+}
+
+func (c *collector) drain() map[usageKey]*usage {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	drained := c.usage
+	c.usage = map[usageKey]*usage{}
+	return drained
+}
+
+var (
+	invocations = newCollector()
+	retention   time.Duration
+)
+
+func Init(config *app.Config) {
+	if !config.UsageAnalyticsEnabled {
+		return
+	}
+
+	retention = time.Duration(config.UsageAnalyticsHourlyRetention) * 24 * time.Hour
+
+	events.Subscribe(func(event events.InvocationCompleted) {
+		invocations.add(event, time.Now())
+	})
+
+	go func() {
+		for range time.NewTicker(flushInterval).C {
+			flush()
+		}
+	}()
+
+	singleton_job.Register(singleton_job.Job{
+		Name:     "plugin_usage_rollup",
+		Interval: rollupInterval,
+		Run:      rollup,
+	})
+}
+
+// latencyColumns are columns of models.LatencyBuckets in the order of histogram.Bounds
+var latencyColumns = func() []string {
+	columns := make([]string, 0, len(histogram.Bounds)+1)
+	for _, bound := range histogram.Bounds {
+		columns = append(columns, "latency_le_"+strconv.FormatInt(bound, 10))
+	}
+	return append(columns, "latency_over")
+}()
+
+// flush adds usage collected by this node to the hourly rows, usage failed to be written is put back
+// and retried with the next flush
+func flush() {
+	for key, u := range invocations.drain() {
+		if err := addHourlyUsage(key, u); err != nil {
+			log.Error("failed to flush usage of plugin %s: %s", key.pluginId, err.Error())
+			invocations.requeue(key, u)
+		}
+	}
+}
+
+// addHourlyUsage accumulates the usage into the hourly row in a single statement, rows are shared by
+// all nodes, so the first one creates it and the others add to it on conflict
+func addHourlyUsage(key usageKey, u *usage) error {
+	hourly := models.PluginUsageHourly{
+		TenantID:    key.tenantId,
+		PluginID:    key.pluginId,
+		Hour:        key.hour,
+		Invocations: u.invocations,
+		Errors:      u.errors,
+		Tokens:      u.tokens,
+	}
+	updates := map[string]any{
+		"invocations": db.Increment("invocations", u.invocations),
+		"errors":      db.Increment("errors", u.errors),
+		"tokens":      db.Increment("tokens", u.tokens),
+		"updated_at":  time.Now(),
+	}
+	for i, bucket := range hourly.Latencies.Buckets() {
+		*bucket = u.latencies[i]
+		updates[latencyColumns[i]] = db.Increment(latencyColumns[i], u.latencies[i])
+	}
+
+	return db.Upsert(&hourly, []string{"tenant_id", "plugin_id", "hour"}, updates)
+}
+
+// summarize rolls up hourly rows of a day into a daily row of each tenant and plugin
+func summarize(day time.Time, rows []models.PluginUsageHourly) []models.PluginUsageDaily {
+	type key struct{ tenantId, pluginId string }
+
+	merged := map[key]*usage{}
+	order := []key{}
+	for _, row := range rows {
+		k := key{row.TenantID, row.PluginID}
+		u, ok := merged[k]
+		if !ok {
+			u = &usage{latencies: histogram.New()}
+			merged[k] = u
+			order = append(order, k)
+		}
+		u.invocations += row.Invocations
+		u.errors += row.Errors
+		u.tokens += row.Tokens
+		for i, bucket := range row.Latencies.Buckets() {
+			u.latencies[i] += *bucket
+		}
+	}
+
+	daily := make([]models.PluginUsageDaily, 0, len(order))
+	for _, k := range order {
+		u := merged[k]
+		daily = append(daily, models.PluginUsageDaily{
+			TenantID:    k.tenantId,
+			PluginID:    k.pluginId,
+			Day:         day,
+			Invocations: u.invocations,
+			Errors:      u.errors,
+			Tokens:      u.tokens,
+			LatencyP50:  histogram.Percentile(u.latencies, 0.5),
+			LatencyP95:  histogram.Percentile(u.latencies, 0.95),
+			LatencyP99:  histogram.Percentile(u.latencies, 0.99),
+		})
+	}
+	return daily
+}
+
+// rollupDay recomputes daily rows of the day from its hourly rows, so it's safe to run repeatedly
+func rollupDay(day time.Time) error {
+	rows, err := db.GetAll[models.PluginUsageHourly](
+		db.WhereSQL("hour >= ? AND hour < ?", day, day.Add(24*time.Hour)),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, summary := range summarize(day, rows) {
+		summary := summary
+		if err := db.WithTransaction(func(tx *gorm.DB) error {
+			daily, err := db.GetOne[models.PluginUsageDaily](
+				db.WithTransactionContext(tx),
+				db.Equal("tenant_id", summary.TenantID),
+				db.Equal("plugin_id", summary.PluginID),
+				db.WhereSQL("day = ?", day),
+				db.WLock(),
+			)
+			if err == db.ErrDatabaseNotFound {
+				return db.Create(&summary, tx)
+			} else if err != nil {
+				return err
+			}
+
+			summary.Model = daily.Model
+			return db.Update(&summary, tx)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollup rolls up recent days and removes hourly rows out of the retention
+func rollup() error {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for i := rollupDays - 1; i >= 0; i-- {
+		if err := rollupDay(today.AddDate(0, 0, -i)); err != nil {
+			return err
+		}
+	}
+
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		return tx.Where("hour < ?", today.Add(-retention)).Delete(&models.PluginUsageHourly{}).Error
+	})
+}
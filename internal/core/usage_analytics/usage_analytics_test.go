@@ -0,0 +1,95 @@
+package usage_analytics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"gorm.io/gorm/schema"
+)
+
+func TestCollectorGroupsByTenantPluginAndHour(t *testing.T) {
+	c := newCollector()
+	identity := plugin_entities.PluginUniqueIdentifier("langgenius/openai:0.0.1@abc")
+	at := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	c.add(events.InvocationCompleted{TenantID: "t1", PluginUniqueIdentifier: identity, Latency: 80 * time.Millisecond, Tokens: 10}, at)
+	c.add(events.InvocationCompleted{TenantID: "t1", PluginUniqueIdentifier: identity, Failed: true, Tokens: 5}, at.Add(10*time.Minute))
+	c.add(events.InvocationCompleted{TenantID: "t2", PluginUniqueIdentifier: identity}, at)
+	c.add(events.InvocationCompleted{TenantID: "t1", PluginUniqueIdentifier: identity}, at.Add(time.Hour))
+
+	drained := c.drain()
+	if len(drained) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(drained))
+	}
+
+	u := drained[usageKey{tenantId: "t1", pluginId: "langgenius/openai", hour: at.Truncate(time.Hour)}]
+	if u == nil || u.invocations != 2 || u.errors != 1 || u.tokens != 15 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	if len(c.drain()) != 0 {
+		t.Fatal("collector should be empty once drained")
+	}
+}
+
+func TestCollectorRequeue(t *testing.T) {
+	c := newCollector()
+	identity := plugin_entities.PluginUniqueIdentifier("langgenius/openai:0.0.1@abc")
+	at := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	key := usageKey{tenantId: "t1", pluginId: "langgenius/openai", hour: at.Truncate(time.Hour)}
+
+	c.add(events.InvocationCompleted{TenantID: "t1", PluginUniqueIdentifier: identity, Tokens: 10}, at)
+	failed := c.drain()
+	c.add(events.InvocationCompleted{TenantID: "t1", PluginUniqueIdentifier: identity, Tokens: 5}, at)
+	c.requeue(key, failed[key])
+
+	u := c.drain()[key]
+	if u == nil || u.invocations != 2 || u.tokens != 15 || u.latencies[0] != 2 {
+		t.Fatalf("requeued usage should be merged, got %+v", u)
+	}
+}
+
+func TestLatencyColumns(t *testing.T) {
+	parsed, err := schema.Parse(&models.PluginUsageHourly{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buckets models.LatencyBuckets
+	if len(latencyColumns) != len(buckets.Buckets()) {
+		t.Fatalf("expected a column per bucket, got %v", latencyColumns)
+	}
+	for _, column := range latencyColumns {
+		if parsed.LookUpField(column) == nil {
+			t.Errorf("column %s is not a field of hourly usage", column)
+		}
+	}
+}
+
+func TestSummarizeRollsUpHours(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fast := models.LatencyBuckets{Le50: 98}
+	slow := models.LatencyBuckets{Le5000: 2}
+
+	daily := summarize(day, []models.PluginUsageHourly{
+		{TenantID: "t1", PluginID: "p", Hour: day, Invocations: 98, Errors: 1, Tokens: 100, Latencies: fast},
+		{TenantID: "t1", PluginID: "p", Hour: day.Add(time.Hour), Invocations: 2, Errors: 1, Tokens: 50, Latencies: slow},
+		{TenantID: "t2", PluginID: "p", Hour: day, Invocations: 1},
+	})
+
+	if len(daily) != 2 {
+		t.Fatalf("expected 2 daily rows, got %d", len(daily))
+	}
+	if daily[0].Invocations != 100 || daily[0].Errors != 2 || daily[0].Tokens != 150 {
+		t.Fatalf("unexpected daily usage: %+v", daily[0])
+	}
+	if daily[0].LatencyP50 != 50 || daily[0].LatencyP99 != 5000 {
+		t.Fatalf("unexpected latency percentiles: p50 %d p99 %d", daily[0].LatencyP50, daily[0].LatencyP99)
+	}
+	if !daily[0].Day.Equal(day) || daily[1].TenantID != "t2" {
+		t.Fatalf("unexpected daily rows: %+v", daily)
+	}
+}
@@ -0,0 +1,170 @@
+// Package watchdog checks subsystems of the daemon for being stuck, like the cluster lifetime loop not
+// progressing, redis subscriptions gone dead or connections of the database pool held by stuck queries.
+//
+// A subsystem failing $WATCHDOG_FAILURE_THRESHOLD checks in a row is recovered by its probe, like
+// reconnecting or restarting it. Once it can't be recovered, or keeps failing after $WATCHDOG_MAX_RECOVERIES
+// recoveries, diagnostics are logged, the process exits as well if $WATCHDOG_EXIT_ENABLED is set so that
+// it's restarted by the supervisor.
+//
+// Outages of dependencies are not checked here, they're reported by the readiness probe instead, exiting
+// every node at once on a database outage helps nothing.
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	checkTimeout = 5 * time.Second
+)
+
+type Probe struct {
+	// Name identifies the subsystem in logs and diagnostics
+	Name string
+	// Check returns an error if the subsystem is stuck
+	Check func(ctx context.Context) error
+	// Recover tries to bring the subsystem back, the failure is escalated at once if it's nil
+	Recover func() error
+}
+
+type probeState struct {
+	Probe
+
+	failures   int
+	recoveries int
+	lastError  string
+}
+
+type watchdog struct {
+	failureThreshold int
+	maxRecoveries    int
+	exitEnabled      bool
+
+	lock   sync.Mutex
+	probes []*probeState
+
+	// exit is replaced in tests
+	exit func(reason string)
+}
+
+var (
+	instance = &watchdog{}
+	logger   = log.Component(log.COMPONENT_WATCHDOG)
+)
+
+// Register adds a probe checked by the watchdog, probes registered before Launch are all checked
+func Register(probe Probe) {
+	instance.lock.Lock()
+	defer instance.lock.Unlock()
+
+	instance.probes = append(instance.probes, &probeState{Probe: probe})
+}
+
+// Launch checks registered probes periodically, beforeExit is called before the process exits
+func Launch(config *app.Config, beforeExit func()) {
+	if !config.WatchdogEnabled {
+		return
+	}
+
+	instance.lock.Lock()
+	instance.failureThreshold = config.WatchdogFailureThreshold
+	instance.maxRecoveries = config.WatchdogMaxRecoveries
+	instance.exitEnabled = config.WatchdogExitEnabled
+	instance.exit = func(reason string) {
+		logger.Error("exiting: %s", reason)
+		if beforeExit != nil {
+			beforeExit()
+		}
+		os.Exit(1)
+	}
+	instance.lock.Unlock()
+
+	go func() {
+		for range time.NewTicker(time.Duration(config.WatchdogInterval) * time.Second).C {
+			instance.check()
+		}
+	}()
+}
+
+// check runs the probes, they're run without holding the lock since checks and recoveries may block, states
+// of probes are only touched by the goroutine checking them
+func (w *watchdog) check() {
+	w.lock.Lock()
+	probes := append([]*probeState{}, w.probes...)
+	w.lock.Unlock()
+
+	for _, probe := range probes {
+		ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		err := probe.Check(ctx)
+		cancel()
+
+		if err == nil {
+			if probe.failures > 0 || probe.recoveries > 0 {
+				logger.Info("%s has recovered", probe.Name)
+			}
+			probe.failures = 0
+			probe.recoveries = 0
+			probe.lastError = ""
+			continue
+		}
+
+		probe.failures++
+		probe.lastError = err.Error()
+		logger.Warn("%s failed the check %d times in a row: %s", probe.Name, probe.failures, err.Error())
+		if probe.failures < w.failureThreshold {
+			continue
+		}
+
+		if probe.Recover != nil && probe.recoveries < w.maxRecoveries {
+			probe.failures = 0
+			probe.recoveries++
+			logger.Warn("recovering %s, attempt %d of %d", probe.Name, probe.recoveries, w.maxRecoveries)
+			if err := probe.Recover(); err != nil {
+				logger.Error("failed to recover %s: %s", probe.Name, err.Error())
+			}
+			continue
+		}
+
+		w.escalate(probe, probes)
+		return
+	}
+}
+
+// escalate exits the process with diagnostics, it's logged only if exiting is disabled
+func (w *watchdog) escalate(stuck *probeState, probes []*probeState) {
+	logger.Error("%s is stuck and can't be recovered, last error: %s", stuck.Name, stuck.lastError)
+	logger.Error("diagnostics:\n%s", diagnostics(probes))
+
+	if !w.exitEnabled {
+		// checked again from scratch so that the failure is not escalated on every check
+		stuck.failures = 0
+		stuck.recoveries = 0
+		return
+	}
+
+	w.exit(fmt.Sprintf("%s is stuck", stuck.Name))
+}
+
+// diagnostics returns states of the probes and stacks of all goroutines
+func diagnostics(probes []*probeState) string {
+	buffer := &bytes.Buffer{}
+	for _, probe := range probes {
+		fmt.Fprintf(buffer, "probe %s: failures=%d recoveries=%d last_error=%q\n",
+			probe.Name, probe.failures, probe.recoveries, probe.lastError)
+	}
+
+	buffer.WriteString("\n")
+	if err := pprof.Lookup("goroutine").WriteTo(buffer, 2); err != nil {
+		fmt.Fprintf(buffer, "failed to dump goroutines: %s\n", err.Error())
+	}
+	return buffer.String()
+}
@@ -0,0 +1,110 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestWatchdog(exitEnabled bool) (*watchdog, *[]string) {
+	exits := []string{}
+	return &watchdog{
+		failureThreshold: 2,
+		maxRecoveries:    1,
+		exitEnabled:      exitEnabled,
+		exit:             func(reason string) { exits = append(exits, reason) },
+	}, &exits
+}
+
+func TestRecoverBeforeEscalating(t *testing.T) {
+	w, exits := newTestWatchdog(true)
+	recoveries := 0
+	w.probes = []*probeState{{Probe: Probe{
+		Name:    "stuck",
+		Check:   func(ctx context.Context) error { return errors.New("stuck") },
+		Recover: func() error { recoveries++; return nil },
+	}}}
+
+	// recovered once reaching the threshold
+	w.check()
+	w.check()
+	if recoveries != 1 || len(*exits) != 0 {
+		t.Fatalf("expected a recovery without exiting, got %d recoveries and %d exits", recoveries, len(*exits))
+	}
+
+	// still failing after the recovery
+	w.check()
+	w.check()
+	if recoveries != 1 || len(*exits) != 1 || (*exits)[0] != "stuck is stuck" {
+		t.Fatalf("expected to exit once recoveries are used up, got %d recoveries and exits %v", recoveries, *exits)
+	}
+}
+
+func TestPassingCheckResetsFailures(t *testing.T) {
+	w, exits := newTestWatchdog(true)
+	fail := true
+	w.probes = []*probeState{{Probe: Probe{
+		Name: "flaky",
+		Check: func(ctx context.Context) error {
+			if fail {
+				return errors.New("flaky")
+			}
+			return nil
+		},
+	}}}
+
+	w.check()
+	fail = false
+	w.check()
+	fail = true
+	w.check()
+	if len(*exits) != 0 {
+		t.Fatal("failures should be counted in a row")
+	}
+
+	w.check()
+	if len(*exits) != 1 {
+		t.Fatal("probes without recovery should be escalated once reaching the threshold")
+	}
+}
+
+func TestEscalationWithoutExit(t *testing.T) {
+	w, exits := newTestWatchdog(false)
+	w.probes = []*probeState{{Probe: Probe{
+		Name:  "stuck",
+		Check: func(ctx context.Context) error { return errors.New("stuck") },
+	}}}
+
+	for i := 0; i < 4; i++ {
+		w.check()
+	}
+	if len(*exits) != 0 {
+		t.Fatal("process should not exit if exiting is disabled")
+	}
+}
+
+func TestProbesRunWithoutTheLock(t *testing.T) {
+	w, _ := newTestWatchdog(false)
+	done := make(chan struct{})
+	w.probes = []*probeState{{Probe: Probe{
+		Name: "blocking",
+		// registering probes is not held up by a probe taking long
+		Check: func(ctx context.Context) error {
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			return nil
+		},
+	}}}
+
+	go func() {
+		defer close(done)
+		w.check()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected probes to be checked without holding the lock")
+	}
+}
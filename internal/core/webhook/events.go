@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+)
+
+// subscribeLifecycleEvents delivers lifecycle events published by other subsystems to webhooks
+func subscribeLifecycleEvents() {
+	events.Subscribe(func(event events.PluginCrashed) {
+		Dispatch("", EVENT_PLUGIN_CRASHED, map[string]any{
+			"plugin_id":                event.PluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": event.PluginUniqueIdentifier.String(),
+			"runtime_type":             event.RuntimeType,
+			"restarts":                 event.Restarts,
+		})
+	})
+
+	events.Subscribe(func(event events.PluginSLOBreached) {
+		Dispatch("", EVENT_PLUGIN_SLO_BREACHED, map[string]any{
+			"plugin_id":              event.PluginID,
+			"invocations":            event.Invocations,
+			"success_rate":           event.SuccessRate,
+			"error_budget_remaining": event.ErrorBudgetRemaining,
+			"latency_p99":            event.LatencyP99,
+			"reasons":                event.Reasons,
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// registerSecretStore makes signing secrets of webhooks re-encrypted on rotating keys
+func registerSecretStore() {
+	keyring.RegisterStore(keyring.Store{
+		Name:      "webhook_secrets",
+		Versions:  secretKeyVersions,
+		Reencrypt: reencryptSecrets,
+	})
+}
+
+func secretKeyVersions() (map[int]int64, error) {
+	type versionCount struct {
+		Version int
+		Count   int64
+	}
+
+	counts, err := db.GetAny[[]versionCount](
+		"SELECT secret_key_version AS version, COUNT(*) AS count FROM webhooks GROUP BY secret_key_version",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[int]int64{}
+	for _, count := range counts {
+		versions[count.Version] = count.Count
+	}
+	return versions, nil
+}
+
+// reencryptSecrets encrypts secrets not encrypted by the active key again, each webhook is
+// locked and re-encrypted in its own transaction, deliveries keep working during the rotation
+// as secrets are always decrypted by the key version stored along with them
+func reencryptSecrets() (int, error) {
+	active := keyring.ActiveVersion()
+	webhooks, err := db.GetAll[models.Webhook](
+		db.NotEqual("secret_key_version", active),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	reencrypted := 0
+	var errs []error
+	for _, webhook := range webhooks {
+		err := db.WithTransaction(func(tx *gorm.DB) error {
+			record, err := db.GetOne[models.Webhook](
+				db.WithTransactionContext(tx),
+				db.Equal("id", webhook.ID),
+				db.WLock(),
+			)
+			if err != nil {
+				return err
+			}
+			if record.SecretKeyVersion == active {
+				// re-encrypted by another node
+				return nil
+			}
+
+			secret, err := keyring.Decrypt(record.Secret, record.SecretKeyVersion)
+			if err != nil {
+				return err
+			}
+			record.Secret, record.SecretKeyVersion, err = keyring.Encrypt(secret)
+			if err != nil {
+				return err
+			}
+			if err := db.Update(&record, tx); err != nil {
+				return err
+			}
+
+			reencrypted++
+			return nil
+		})
+		if err != nil && !errors.Is(err, db.ErrDatabaseNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return reencrypted, errors.Join(errs...)
+}
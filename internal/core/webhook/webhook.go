@@ -0,0 +1,250 @@
+// Package webhook delivers plugin lifecycle events to external systems
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+type EventType string
+
+const (
+	EVENT_PLUGIN_INSTALLED    EventType = "plugin.installed"
+	EVENT_PLUGIN_UPGRADED     EventType = "plugin.upgraded"
+	EVENT_PLUGIN_UNINSTALLED  EventType = "plugin.uninstalled"
+	EVENT_PLUGIN_CRASHED      EventType = "plugin.crashed"
+	EVENT_ENDPOINT_CREATED    EventType = "endpoint.created"
+	EVENT_ENDPOINT_DISABLED   EventType = "endpoint.disabled"
+	EVENT_PLUGIN_SLO_BREACHED EventType = "plugin.slo_breached"
+)
+
+var EventTypes = []EventType{
+	EVENT_PLUGIN_INSTALLED,
+	EVENT_PLUGIN_UPGRADED,
+	EVENT_PLUGIN_UNINSTALLED,
+	EVENT_PLUGIN_CRASHED,
+	EVENT_ENDPOINT_CREATED,
+	EVENT_ENDPOINT_DISABLED,
+	EVENT_PLUGIN_SLO_BREACHED,
+}
+
+const (
+	HEADER_EVENT     = "X-Dify-Event"
+	HEADER_DELIVERY  = "X-Dify-Delivery"
+	HEADER_TIMESTAMP = "X-Dify-Timestamp"
+	HEADER_SIGNATURE = "X-Dify-Signature"
+)
+
+type Event struct {
+	ID        string         `json:"id"`
+	Type      EventType      `json:"type"`
+	TenantID  string         `json:"tenant_id,omitempty"` // empty for events not bound to a tenant
+	Timestamp int64          `json:"timestamp"`
+	Data      map[string]any `json:"data"`
+}
+
+// delivery holds settings of deliveries, it's replaced as a whole once settings are reloaded
+type delivery struct {
+	client *http.Client
+	// keeps webhooks from reaching the network of the daemon if it's set
+	guard      *network.SSRFGuard
+	maxRetries int
+}
+
+var (
+	// nil until it's initialized
+	settings atomic.Pointer[delivery]
+
+	// delay before the first retry, doubled on each retry
+	retryInterval = time.Second
+)
+
+func Init(config *app.Config) {
+	if err := Configure(config); err != nil {
+		log.Panic("init ssrf protection failed: %s", err.Error())
+	}
+
+	subscribeLifecycleEvents()
+	registerSecretStore()
+}
+
+// Configure updates the timeout, retries and allowlists of deliveries, the previous ones are kept if
+// the allowlists are invalid
+func Configure(config *app.Config) error {
+	timeout := time.Duration(config.WebhookTimeout) * time.Second
+	next := &delivery{maxRetries: config.WebhookMaxRetries}
+	if config.SSRFProtectionEnabled != nil && *config.SSRFProtectionEnabled {
+		g, err := network.NewSSRFGuard(config.SSRFAllowedCIDRs, config.SSRFAllowedSchemes)
+		if err != nil {
+			return err
+		}
+		next.guard = g
+		next.client = g.Client(timeout)
+	} else {
+		next.client = &http.Client{
+			Timeout: timeout,
+		}
+	}
+	settings.Store(next)
+	return nil
+}
+
+// CheckURL checks whether webhooks can be delivered to the url, addresses are checked again on delivering
+// since hostnames may resolve to other addresses by then
+func CheckURL(url string) error {
+	current := settings.Load()
+	if current == nil || current.guard == nil {
+		return nil
+	}
+
+	return current.guard.CheckURL(url)
+}
+
+// Dispatch delivers the event to all enabled webhooks subscribed to it asynchronously,
+// webhooks of the tenant and global webhooks are notified
+func Dispatch(tenant_id string, eventType EventType, data map[string]any) {
+	current := settings.Load()
+	if current == nil {
+		return
+	}
+
+	event := Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		TenantID:  tenant_id,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "webhook",
+		"function": "Dispatch",
+		"event":    string(eventType),
+	}, func() {
+		tenants := []any{models.WEBHOOK_GLOBAL_TENANT}
+		if tenant_id != "" {
+			tenants = append(tenants, tenant_id)
+		}
+
+		webhooks, err := db.GetAll[models.Webhook](
+			db.InArray("tenant_id", tenants),
+			db.Equal("enabled", true),
+		)
+		if err != nil {
+			log.Error("failed to fetch webhooks of event %s: %s", eventType, err.Error())
+			return
+		}
+
+		payload := parser.MarshalJsonBytes(event)
+		for _, webhook := range webhooks {
+			if !Subscribed(&webhook, eventType) {
+				continue
+			}
+
+			webhook := webhook
+			routine.Submit(map[string]string{
+				"module":   "webhook",
+				"function": "deliver",
+				"event":    string(eventType),
+			}, func() {
+				if err := deliver(current, &webhook, &event, payload); err != nil {
+					log.Error("failed to deliver event %s to webhook %s: %s", eventType, webhook.ID, err.Error())
+				}
+			})
+		}
+	})
+}
+
+// Subscribed tells whether the webhook receives the event, webhooks without events receive all of them
+func Subscribed(webhook *models.Webhook, eventType EventType) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+
+	for _, event := range webhook.Events {
+		if event == string(eventType) || event == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign returns the signature of the payload, receivers should compute
+// hex(hmac_sha256(secret, timestamp + "." + payload)) and compare it with the signature header
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts the payload to the webhook, it retries with exponential backoff
+// until the receiver responds a 2xx status code or retries are exhausted
+func deliver(settings *delivery, webhook *models.Webhook, event *Event, payload []byte) error {
+	var err error
+	interval := retryInterval
+
+	for attempt := 0; attempt <= settings.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(interval)
+			interval *= 2
+		}
+
+		if err = post(settings.client, webhook, event, payload); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func post(client *http.Client, webhook *models.Webhook, event *Event, payload []byte) error {
+	secret, err := keyring.Decrypt(webhook.Secret, webhook.SecretKeyVersion)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	// signed at sending time, receivers are able to reject replayed requests by the timestamp
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HEADER_EVENT, string(event.Type))
+	request.Header.Set(HEADER_DELIVERY, event.ID)
+	request.Header.Set(HEADER_TIMESTAMP, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HEADER_SIGNATURE, Sign(secret, timestamp, payload))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func TestSubscribed(t *testing.T) {
+	if !Subscribed(&models.Webhook{}, EVENT_PLUGIN_INSTALLED) {
+		t.Fatal("webhook without events should receive all events")
+	}
+
+	webhook := &models.Webhook{Events: []string{string(EVENT_PLUGIN_CRASHED)}}
+	if !Subscribed(webhook, EVENT_PLUGIN_CRASHED) || Subscribed(webhook, EVENT_PLUGIN_INSTALLED) {
+		t.Fatal("webhook should only receive subscribed events")
+	}
+}
+
+func TestDeliverSignsAndRetries(t *testing.T) {
+	retryInterval = time.Millisecond
+
+	attempts := int32(0)
+	payload := []byte(`{"type":"plugin.installed"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(HEADER_TIMESTAMP), 10, 64)
+		if err != nil || r.Header.Get(HEADER_SIGNATURE) != Sign("secret", timestamp, body) {
+			t.Errorf("invalid signature: %s", r.Header.Get(HEADER_SIGNATURE))
+		}
+
+		if r.Header.Get(HEADER_EVENT) != string(EVENT_PLUGIN_INSTALLED) {
+			t.Errorf("unexpected event header: %s", r.Header.Get(HEADER_EVENT))
+		}
+
+		// fail the first attempt
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	err := deliver(
+		&delivery{client: server.Client(), maxRetries: 2},
+		&models.Webhook{URL: server.URL, Secret: "secret"},
+		&Event{ID: "1", Type: EVENT_PLUGIN_INSTALLED},
+		payload,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	retryInterval = time.Millisecond
+
+	attempts := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := deliver(
+		&delivery{client: server.Client(), maxRetries: 2},
+		&models.Webhook{URL: server.URL, Secret: "secret"},
+		&Event{ID: "1", Type: EVENT_PLUGIN_CRASHED},
+		[]byte(`{}`),
+	)
+	if err == nil {
+		t.Fatal("delivery should fail")
+	}
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
@@ -25,6 +26,25 @@ func Create(data any, ctx ...*gorm.DB) error {
 	return DifyPluginDB.Create(data).Error
 }
 
+// Upsert creates data, the row conflicting with it on the columns is updated with updates instead,
+// updates may be built by Increment to accumulate values in a single statement
+func Upsert(data any, columns []string, updates map[string]any, ctx ...*gorm.DB) error {
+	conflict := clause.OnConflict{DoUpdates: clause.Assignments(updates)}
+	for _, column := range columns {
+		conflict.Columns = append(conflict.Columns, clause.Column{Name: column})
+	}
+
+	if len(ctx) > 0 {
+		return ctx[0].Clauses(conflict).Create(data).Error
+	}
+	return DifyPluginDB.Clauses(conflict).Create(data).Error
+}
+
+// Increment adds value to the column of the existing row, it's used along with Upsert
+func Increment(column string, value any) clause.Expr {
+	return gorm.Expr("? + ?", clause.Column{Table: clause.CurrentTable, Name: column}, value)
+}
+
 func Update(data any, ctx ...*gorm.DB) error {
 	if len(ctx) > 0 {
 		return ctx[0].Save(data).Error
@@ -228,6 +248,13 @@ func WithTransactionContext(tx *gorm.DB) GenericQuery {
 	}
 }
 
+// WithContext runs the query within ctx, queries are traced under the span of ctx
+func WithContext(ctx context.Context) GenericQuery {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.WithContext(ctx)
+	}
+}
+
 func InArray(field string, value []interface{}) GenericQuery {
 	return func(tx *gorm.DB) *gorm.DB {
 		return tx.Where(fmt.Sprintf("%s IN ?", field), value)
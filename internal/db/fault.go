@@ -0,0 +1,31 @@
+package db
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/fault"
+	"gorm.io/gorm"
+)
+
+// EnableFaultInjection injects faults of fault.POINT_DB into statements before they're run
+func EnableFaultInjection() error {
+	callback := DifyPluginDB.Callback()
+	for _, err := range []error{
+		callback.Create().Before("gorm:create").Register("fault:create", injectFault),
+		callback.Query().Before("gorm:query").Register("fault:query", injectFault),
+		callback.Update().Before("gorm:update").Register("fault:update", injectFault),
+		callback.Delete().Before("gorm:delete").Register("fault:delete", injectFault),
+		callback.Row().Before("gorm:row").Register("fault:row", injectFault),
+		callback.Raw().Before("gorm:raw").Register("fault:raw", injectFault),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func injectFault(tx *gorm.DB) {
+	if f := fault.Inject(fault.POINT_DB, tx.Statement.Table); f != nil {
+		// statements of a failed transaction are skipped
+		tx.AddError(f)
+	}
+}
@@ -1,6 +1,9 @@
 package db
 
 import (
+	"context"
+	"errors"
+
 	"github.com/langgenius/dify-plugin-daemon/internal/db/mysql"
 	"github.com/langgenius/dify-plugin-daemon/internal/db/pg"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
@@ -15,11 +18,33 @@ func autoMigrate() error {
 		models.PluginDeclaration{},
 		models.Endpoint{},
 		models.ServerlessRuntime{},
+		models.ServerlessRuntimePin{},
+		models.ServerlessRegionalFunction{},
+		models.ServerlessResourceOverride{},
+		models.ServerlessInvocationStat{},
 		models.ToolInstallation{},
 		models.AIModelInstallation{},
 		models.InstallTask{},
 		models.TenantStorage{},
 		models.AgentStrategyInstallation{},
+		models.PluginScanReport{},
+		models.TenantPluginPolicy{},
+		models.Webhook{},
+		models.APIToken{},
+		models.CredentialAccessLog{},
+		models.TenantStorageQuota{},
+		models.PersistenceValue{},
+		models.PluginSLO{},
+		models.PluginUsageHourly{},
+		models.PluginUsageDaily{},
+		models.DeclaredEndpoint{},
+		models.EndpointTemplate{},
+		models.EndpointTemplateInstance{},
+		models.CredentialPool{},
+		models.CredentialPoolKey{},
+		models.GuardrailPolicy{},
+		models.GuardrailEvent{},
+		models.FeatureFlag{},
 	)
 
 	if err != nil {
@@ -85,6 +110,14 @@ func Init(config *app.Config) {
 		log.Panic("failed to init dify plugin db: %v", err)
 	}
 
+	if config.DBMaxOpenConns > 0 {
+		sqlDB, err := DifyPluginDB.DB()
+		if err != nil {
+			log.Panic("failed to init dify plugin db: %v", err)
+		}
+		sqlDB.SetMaxOpenConns(config.DBMaxOpenConns)
+	}
+
 	err = autoMigrate()
 	if err != nil {
 		log.Panic("failed to auto migrate: %v", err)
@@ -108,3 +141,17 @@ func Close() {
 
 	log.Info("dify plugin db closed")
 }
+
+// Ping checks the connection to the database
+func Ping(ctx context.Context) error {
+	if DifyPluginDB == nil {
+		return errors.New("dify plugin db is not initialized")
+	}
+
+	db, err := DifyPluginDB.DB()
+	if err != nil {
+		return err
+	}
+
+	return db.PingContext(ctx)
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CheckPool returns an error if no connection of the pool is taken before ctx is done while all of them are
+// in use, they're held by stuck queries then. A busy pool still hands out connections in time, and failures
+// of reaching the database are outages rather than the pool being stuck, they're left to the readiness probe
+func CheckPool(ctx context.Context) error {
+	if DifyPluginDB == nil {
+		return errors.New("dify plugin db is not initialized")
+	}
+
+	sqlDB, err := DifyPluginDB.DB()
+	if err != nil {
+		return err
+	}
+
+	if err := sqlDB.PingContext(ctx); err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+
+	stats := sqlDB.Stats()
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		return fmt.Errorf(
+			"no connection of the pool is released in time, %d of %d connections in use",
+			stats.InUse, stats.MaxOpenConnections,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	tracingSpanKey = "tracing:span"
+)
+
+// EnableTracing traces statements run within a traced context, see WithContext,
+// statements and their variables are left out of spans as they may carry tenant data
+func EnableTracing() error {
+	callback := DifyPluginDB.Callback()
+	for _, err := range []error{
+		callback.Create().Before("gorm:create").Register("tracing:before_create", startSpan("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", endSpan),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", startSpan("query")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", endSpan),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", startSpan("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", endSpan),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", startSpan("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", endSpan),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", startSpan("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", endSpan),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startSpan(operation string) func(tx *gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, span, ok := tracing.StartChild(tx.Statement.Context, "db."+operation, trace.SpanKindClient,
+			attribute.String("db.system", tx.Dialector.Name()),
+			attribute.String("db.operation", operation),
+		)
+		if !ok {
+			return
+		}
+
+		tx.Statement.Context = ctx
+		tx.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+func endSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("db.sql.table", tx.Statement.Table),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		tracing.Fail(span, tx.Error)
+	}
+}
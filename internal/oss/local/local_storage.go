@@ -1,6 +1,7 @@
 package local
 
 import (
+	"io"
 	"io/fs"
 	"log"
 	"os"
@@ -29,7 +30,36 @@ func (l *LocalStorage) Save(key string, data []byte) error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0o644)
+	// written to a temporary file and renamed, readers never see a partial file, e.g. of a package
+	// being saved when the node crashes, or read by another node sharing the root on a network filesystem
+	file, err := os.CreateTemp(filePath, "."+filepath.Base(path)+tempSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// tempSuffix marks files being saved, they're not listed
+const tempSuffix = ".tmp-*"
+
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, strings.TrimSuffix(tempSuffix, "*"))
 }
 
 func (l *LocalStorage) Load(key string) ([]byte, error) {
@@ -38,6 +68,39 @@ func (l *LocalStorage) Load(key string) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
+func (l *LocalStorage) LoadRange(key string, offset int64, length int64) ([]byte, error) {
+	file, err := os.Open(filepath.Join(l.root, key))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return io.ReadAll(file)
+	}
+	return io.ReadAll(io.LimitReader(file, length))
+}
+
+func (l *LocalStorage) Append(key string, data []byte) error {
+	path := filepath.Join(l.root, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 func (l *LocalStorage) Exists(key string) (bool, error) {
 	path := filepath.Join(l.root, key)
 
@@ -72,6 +135,9 @@ func (l *LocalStorage) List(prefix string) ([]oss.OSSPath, error) {
 		if err != nil {
 			return err
 		}
+		if !d.IsDir() && isTempFile(d.Name()) {
+			return nil
+		}
 		// remove prefix
 		path = strings.TrimPrefix(path, prefix)
 		if path == "" {
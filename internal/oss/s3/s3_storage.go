@@ -3,7 +3,10 @@ package s3
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"time"
 
@@ -11,6 +14,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 )
@@ -106,6 +111,43 @@ func (s *S3Storage) Load(key string) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
+func (s *S3Storage) LoadRange(key string, offset int64, length int64) ([]byte, error) {
+	// ranges of http are inclusive
+	ranges := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		if length == 0 {
+			return []byte{}, nil
+		}
+		ranges = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	resp, err := s.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(ranges),
+	})
+	if err != nil {
+		// ranges starting at or past the end, including any range of an empty object, are not satisfiable
+		if isInvalidRange(err) {
+			return []byte{}, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+func isInvalidRange(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		return true
+	}
+
+	var responseErr *smithyhttp.ResponseError
+	return errors.As(err, &responseErr) && responseErr.HTTPStatusCode() == http.StatusRequestedRangeNotSatisfiable
+}
+
 func (s *S3Storage) Exists(key string) (bool, error) {
 	_, err := s.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -0,0 +1,80 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serveObject serves the object of every key like s3 does, unsatisfiable ranges are responded 416
+func serveObject(object []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		start, end := int64(0), int64(len(object))-1
+		if ranges := r.Header.Get("Range"); ranges != "" {
+			first, last, _ := strings.Cut(strings.TrimPrefix(ranges, "bytes="), "-")
+			start, _ = strconv.ParseInt(first, 10, 64)
+			if last != "" {
+				end, _ = strconv.ParseInt(last, 10, 64)
+			}
+			end = min(end, int64(len(object))-1)
+		}
+
+		if start >= int64(len(object)) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>InvalidRange</Code>`+
+				`<Message>The requested range is not satisfiable</Message></Error>`)
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(object[start : end+1])
+	}
+}
+
+func TestLoadRangePastTheEnd(t *testing.T) {
+	for name, object := range map[string][]byte{
+		"object": []byte("hello"),
+		"empty":  {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(serveObject(object))
+			defer server.Close()
+
+			created, err := NewS3Storage(false, server.URL, true, "ak", "sk", "bucket", "us-east-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			storage := created.(*S3Storage)
+
+			for _, r := range []struct{ offset, length int64 }{
+				{int64(len(object)), -1},
+				{int64(len(object)) + 3, -1},
+				{int64(len(object)), 4},
+			} {
+				data, err := storage.LoadRange("key", r.offset, r.length)
+				if err != nil {
+					t.Fatalf("range %+v: %v", r, err)
+				}
+				if data == nil || len(data) != 0 {
+					t.Fatalf("range %+v: expected no data, got %q", r, data)
+				}
+			}
+
+			if len(object) > 0 {
+				data, err := storage.LoadRange("key", 1, -1)
+				if err != nil || string(data) != string(object[1:]) {
+					t.Fatalf("expected the rest of the object, got %q %v", data, err)
+				}
+			}
+		})
+	}
+}
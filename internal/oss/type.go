@@ -38,3 +38,15 @@ type OSS interface {
 	// For example: local, aws_s3, tencent_cos
 	Type() string
 }
+
+// RangeLoader is implemented by storages able to load a part of the data without loading all of it
+type RangeLoader interface {
+	// LoadRange loads length bytes of the data in the path key from offset, to the end if length is negative
+	LoadRange(key string, offset int64, length int64) ([]byte, error)
+}
+
+// Appender is implemented by storages able to append to the data in place
+type Appender interface {
+	// Append appends data to the data in the path key, which is created if it doesn't exist
+	Append(key string, data []byte) error
+}
@@ -3,6 +3,9 @@ package server
 import (
 	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/secrets"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
 )
 
 type App struct {
@@ -17,4 +20,27 @@ type App struct {
 	// aws transaction handler
 	// accept aws transaction request and forward to the plugin daemon
 	awsTransactionHandler *transaction.AWSTransactionHandler
+
+	// certificate of the server port, nil if tls is disabled
+	tls *network.CertReloader
+
+	// keys checked by apis, updated once they're rotated in secret managers
+	serverKey *secrets.Value
+	adminKey  *secrets.Value
+	// secret verifying tokens signed by the Dify API for tenants
+	tenantTokenSecret *secrets.Value
+	// secret signing urls of assets and packages
+	assetSigningSecret *secrets.Value
+}
+
+func (app *App) watchKeys(config *app.Config) {
+	app.serverKey = secrets.Watch("SERVER_KEY", config.ServerKey)
+	if config.AdminKey == config.ServerKey {
+		// ADMIN_KEY defaults to SERVER_KEY, it follows the rotation of SERVER_KEY then
+		app.adminKey = app.serverKey
+	} else {
+		app.adminKey = secrets.Watch("ADMIN_KEY", config.AdminKey)
+	}
+	app.tenantTokenSecret = secrets.Watch("TENANT_TOKEN_SECRET", config.TenantTokenSecret)
+	app.assetSigningSecret = secrets.Watch("ASSET_SIGNING_SECRET", config.AssetSigningSecret)
 }
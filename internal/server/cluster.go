@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/singleton_job"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// ListClusterNodes lists nodes of the cluster with their health and load
+func (app *App) ListClusterNodes(c *gin.Context) {
+	nodes, err := app.cluster.ListNodes()
+	if err != nil {
+		c.JSON(200, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	c.JSON(200, entities.NewSuccessResponse(nodes))
+}
+
+// DrainClusterNode stops the node from taking new sessions before maintenance
+func (app *App) DrainClusterNode(c *gin.Context) {
+	err := app.cluster.DrainNode(c.Param("id"))
+	if errors.Is(err, cluster.ErrNodeNotFound) {
+		c.JSON(404, exception.NotFoundError(err).ToResponse())
+		return
+	} else if err != nil {
+		c.JSON(200, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	c.JSON(200, entities.NewSuccessResponse(true))
+}
+
+// ListSingletonJobs lists jobs running on one node of the cluster with their leaders and last runs
+func (app *App) ListSingletonJobs(c *gin.Context) {
+	jobs, err := singleton_job.ListJobs()
+	if err != nil {
+		c.JSON(200, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	c.JSON(200, entities.NewSuccessResponse(jobs))
+}
+
+// ListPluginReconciliations lists the drift between installed plugins and the ones running on each node
+func (app *App) ListPluginReconciliations(c *gin.Context) {
+	reports, err := app.cluster.ListReconciliationReports()
+	if err != nil {
+		c.JSON(200, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	c.JSON(200, entities.NewSuccessResponse(reports))
+}
+
+// ListLogLevels lists log levels overridden at runtime across the cluster
+func (app *App) ListLogLevels(c *gin.Context) {
+	levels, err := app.cluster.ListLogLevels()
+	if err != nil {
+		c.JSON(200, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	c.JSON(200, entities.NewSuccessResponse(levels))
+}
+
+// UpdateLogLevel overrides the log level of a component or the global one across the cluster,
+// an empty level removes the override
+func (app *App) UpdateLogLevel(c *gin.Context) {
+	controllers.BindRequest(c, func(request struct {
+		Component string `json:"component"`
+		Level     string `json:"level"`
+	}) {
+		err := app.cluster.SetLogLevel(request.Component, request.Level)
+		if errors.Is(err, cluster.ErrInvalidLogLevel) || errors.Is(err, cluster.ErrUnknownLogComponent) {
+			c.JSON(400, exception.BadRequestError(err).ToResponse())
+			return
+		} else if err != nil {
+			c.JSON(200, exception.InternalServerError(err).ToResponse())
+			return
+		}
+
+		c.JSON(200, entities.NewSuccessResponse(true))
+	})
+}
@@ -0,0 +1,235 @@
+// Package compression compresses responses by gzip or zstd as negotiated by the Accept-Encoding of requests.
+// Responses are buffered until they're large enough to be worth compressing, small ones, streams like
+// server sent events and responses already encoded, e.g. redirected from another node, are written as they are.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	ENCODING_GZIP = "gzip"
+	ENCODING_ZSTD = "zstd"
+)
+
+// encodings are preferred in the order
+var encodings = []string{ENCODING_ZSTD, ENCODING_GZIP}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var pools = map[string]*sync.Pool{
+	ENCODING_GZIP: {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+	ENCODING_ZSTD: {New: func() any {
+		encoder, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return encoder
+	}},
+}
+
+// incompressible content types are either streams which are flushed event by event or already compressed
+var incompressible = []string{
+	"text/event-stream",
+	"application/octet-stream",
+	"application/zip",
+	"application/gzip",
+	"image/",
+	"video/",
+	"audio/",
+}
+
+// Negotiate returns the encoding preferred by the Accept-Encoding header, it's empty if none is accepted
+func Negotiate(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				continue
+			}
+		}
+		accepted[name] = true
+	}
+
+	for _, encoding := range encodings {
+		if accepted[encoding] || accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// NewHandler compresses responses of the handler larger than minSize bytes
+func NewHandler(handler http.Handler, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+		defer writer.finish()
+		handler.ServeHTTP(writer, r)
+	})
+}
+
+type compressMode int
+
+const (
+	// the response is buffered until it's known whether it's worth compressing
+	compressModePending compressMode = iota
+	compressModeCompressed
+	compressModeRaw
+)
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	mode        compressMode
+	status      int
+	wroteHeader bool
+	buffer      bytes.Buffer
+	encoder     encoder
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = statusCode
+
+	if !w.compressible() {
+		w.mode = compressModeRaw
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+// compressible is decided once the status and the headers of the response are set
+func (w *compressWriter) compressible() bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, prefix := range incompressible {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	switch w.mode {
+	case compressModeRaw:
+		return w.ResponseWriter.Write(data)
+	case compressModeCompressed:
+		return w.encoder.Write(data)
+	}
+
+	w.buffer.Write(data)
+	if w.buffer.Len() >= w.minSize {
+		if err := w.startCompressing(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) startCompressing() error {
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Add("Vary", "Accept-Encoding")
+	// the length changes once the body is compressed
+	header.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.mode = compressModeCompressed
+	w.encoder = pools[w.encoding].Get().(encoder)
+	w.encoder.Reset(w.ResponseWriter)
+
+	_, err := w.encoder.Write(w.buffer.Bytes())
+	w.buffer.Reset()
+	return err
+}
+
+// writeRaw writes what's buffered as it is
+func (w *compressWriter) writeRaw() {
+	w.mode = compressModeRaw
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buffer.Len() > 0 {
+		w.ResponseWriter.Write(w.buffer.Bytes())
+		w.buffer.Reset()
+	}
+}
+
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	switch w.mode {
+	case compressModePending:
+		// routes flushing responses before they're large enough stream them, e.g. chunks of invocations
+		w.writeRaw()
+	case compressModeCompressed:
+		w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish writes what's buffered once the handler returns
+func (w *compressWriter) finish() {
+	switch w.mode {
+	case compressModePending:
+		if w.wroteHeader {
+			w.writeRaw()
+		}
+	case compressModeCompressed:
+		w.encoder.Close()
+		w.encoder.Reset(io.Discard)
+		pools[w.encoding].Put(w.encoder)
+		w.encoder = nil
+	}
+}
@@ -0,0 +1,103 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		"gzip":                   ENCODING_GZIP,
+		"gzip, deflate, br":      ENCODING_GZIP,
+		"gzip, zstd":             ENCODING_ZSTD,
+		"zstd;q=0, gzip;q=0.5":   ENCODING_GZIP,
+		"identity":               "",
+		"*":                      ENCODING_ZSTD,
+		"GZIP;q=1.0, ZSTD;q=0.0": ENCODING_GZIP,
+	}
+	for acceptEncoding, expected := range cases {
+		if encoding := Negotiate(acceptEncoding); encoding != expected {
+			t.Errorf("expected %q for %q, got %q", expected, acceptEncoding, encoding)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	large := `{"data":"` + strings.Repeat("declaration", 200) + `"}`
+	handler := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(large[:100]))
+			w.Write([]byte(large[100:]))
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"code":0}`))
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: " + large + "\n\n"))
+		case "/flushed":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"chunk":1}`))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(large))
+		}
+	}), 1024)
+
+	serve := func(path string, acceptEncoding string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := serve("/large", "gzip")
+	if recorder.Header().Get("Content-Encoding") != ENCODING_GZIP {
+		t.Fatalf("large response should be compressed by gzip, got %q", recorder.Header().Get("Content-Encoding"))
+	}
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(reader); string(body) != large {
+		t.Fatalf("unexpected decompressed body %s", body)
+	}
+
+	recorder = serve("/large", "gzip, zstd")
+	if recorder.Header().Get("Content-Encoding") != ENCODING_ZSTD {
+		t.Fatalf("zstd should be preferred, got %q", recorder.Header().Get("Content-Encoding"))
+	}
+	decoder, err := zstd.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+	if body, _ := io.ReadAll(decoder); string(body) != large {
+		t.Fatalf("unexpected decompressed body %s", body)
+	}
+
+	for _, path := range []string{"/small", "/events"} {
+		recorder := serve(path, "gzip")
+		if recorder.Header().Get("Content-Encoding") != "" || recorder.Body.Len() == 0 {
+			t.Fatalf("%s should be written as it is, got %q", path, recorder.Header().Get("Content-Encoding"))
+		}
+	}
+
+	recorder = serve("/flushed", "gzip")
+	if recorder.Header().Get("Content-Encoding") != "" || recorder.Body.String() != `{"chunk":1}`+large {
+		t.Fatalf("flushed response should be streamed as it is, got %q", recorder.Header().Get("Content-Encoding"))
+	}
+
+	recorder = serve("/large", "")
+	if recorder.Header().Get("Content-Encoding") != "" || recorder.Body.String() != large {
+		t.Fatal("response should be written as it is if no encoding is accepted")
+	}
+}
@@ -3,8 +3,32 @@ package constants
 const (
 	X_PLUGIN_ID = "X-Plugin-ID"
 	X_API_KEY   = "X-Api-Key"
+	// X_REQUEST_ID correlates logs of a request, it's generated if the caller doesn't set it
+	X_REQUEST_ID = "X-Request-Id"
+	// X_PLUGIN_REDIRECTED_FROM is set to the id of the node which redirected the request
+	X_PLUGIN_REDIRECTED_FROM = "X-Plugin-Redirected-From"
+	// X_PLUGIN_NODE_AT_CAPACITY is set to the id of the node which rejected the request as it's at capacity
+	X_PLUGIN_NODE_AT_CAPACITY = "X-Plugin-Node-At-Capacity"
+	// X_API_VERSION is the version of the api a request is served as, it's taken from the /v1 or /v2 prefix of the path
+	X_API_VERSION = "X-Api-Version"
+	// IDEMPOTENCY_KEY is set by callers retrying a mutating request, IDEMPOTENT_REPLAYED marks replayed results
+	IDEMPOTENCY_KEY     = "Idempotency-Key"
+	IDEMPOTENT_REPLAYED = "Idempotent-Replayed"
+	// X_MODEL_CACHE is sent as `bypass` to skip the model response cache, replies carry `hit`, `miss` or `bypass`
+	X_MODEL_CACHE = "X-Model-Cache"
+	// X_TENANT_TOKEN carries the token signed by the Dify API for the tenant and the user of the request
+	X_TENANT_TOKEN = "X-Tenant-Token"
+	// AUTHORIZATION carries the credentials of operators signed in by oidc or ldap
+	AUTHORIZATION = "Authorization"
 
 	CONTEXT_KEY_PLUGIN_INSTALLATION      = "plugin_installation"
 	CONTEXT_KEY_PLUGIN_UNIQUE_IDENTIFIER = "plugin_unique_identifier"
 	CONTEXT_KEY_CLUSTER_ID               = "cluster_id"
+	CONTEXT_KEY_REQUEST_ID               = "request_id"
+	CONTEXT_KEY_CALLER                   = "caller"
+	// CONTEXT_KEY_CALLER_TENANT_ID is set if the caller is bound to a tenant, e.g. by its api token
+	CONTEXT_KEY_CALLER_TENANT_ID = "caller_tenant_id"
+	CONTEXT_KEY_TENANT_TOKEN     = "tenant_token"
+	// CONTEXT_KEY_OPERATOR is set if the caller is an operator signed in by oidc or ldap
+	CONTEXT_KEY_OPERATOR = "operator"
 )
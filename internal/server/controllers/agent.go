@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -17,7 +18,20 @@ func InvokeAgentStrategy(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.InvokeAgentStrategy(&itr, c, config.PluginMaxExecutionTimeout)
+				service.InvokeAgentStrategy(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
+			},
+		)
+	}
+}
+
+func InvokeAgentStrategyEvents(config *app.Config) gin.HandlerFunc {
+	type request = plugin_entities.InvokePluginRequest[requests.RequestInvokeAgentStrategy]
+
+	return func(c *gin.Context) {
+		BindPluginDispatchRequest(
+			c,
+			func(itr request) {
+				service.InvokeAgentStrategyEvents(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -29,7 +43,7 @@ func ListAgentStrategies(c *gin.Context) {
 		Page     int    `form:"page" validate:"required,min=1"`
 		PageSize int    `form:"page_size" validate:"required,min=1,max=256"`
 	}) {
-		c.JSON(http.StatusOK, service.ListAgentStrategies(request.TenantID, request.Page, request.PageSize))
+		respondLocalized(c, service.ListAgentStrategies(request.TenantID, request.Page, request.PageSize))
 	})
 }
 
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func ListAPITokens(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListAPITokens())
+}
+
+func CreateAPIToken(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Name      string     `json:"name" validate:"required,max=127"`
+		TenantID  string     `json:"tenant_id" validate:"omitempty,max=255"`
+		Scopes    []string   `json:"scopes" validate:"required,min=1,max=16"`
+		RateLimit int        `json:"rate_limit" validate:"min=0"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}) {
+		c.JSON(http.StatusOK, service.CreateAPIToken(
+			request.Name, request.TenantID, request.Scopes, request.RateLimit, request.ExpiresAt,
+		))
+	})
+}
+
+func RevokeAPIToken(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TokenID string `json:"token_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.RevokeAPIToken(request.TokenID))
+	})
+}
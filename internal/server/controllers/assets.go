@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/asset_url"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/secrets"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// content types of assets served inline, the others are served as attachments
+var assetContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".avif": "image/avif",
+	".bmp":  "image/bmp",
+	".ico":  "image/x-icon",
+	".svg":  "image/svg+xml",
+}
+
+// assets come from plugin packages, an svg opened by the console must not run scripts in its origin,
+// the sandbox treats the asset as a document of a unique origin even if it's opened directly
+const assetContentSecurityPolicy = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; sandbox"
+
+// etagMatches checks If-None-Match, which lists etags, weak ones included, or `*`
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAsset serves assets to callers of the server key or api tokens, shared caches must not serve them to others
+func GetAsset(c *gin.Context) {
+	serveAsset(c, c.Param("id"), "private, max-age=31536000, immutable")
+}
+
+// GetSignedAsset serves assets of signed urls, they're cached publicly until the urls expire
+func GetSignedAsset(secret *secrets.Value) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		expiresAt, err := asset_url.Verify(secret.Get(), asset_url.AssetResource(id), c.Request.URL.Query(), time.Now())
+		if err != nil {
+			c.JSON(http.StatusForbidden, exception.PermissionDeniedError(err.Error()).ToResponse())
+			return
+		}
+		serveAsset(c, id, signedCacheControl(expiresAt))
+	}
+}
+
+// signedCacheControl caches responses of signed urls until they expire, contents of them never change
+func signedCacheControl(expiresAt time.Time) string {
+	return fmt.Sprintf("public, max-age=%d, immutable", int64(time.Until(expiresAt).Seconds()))
+}
+
+func serveAsset(c *gin.Context, id string, cacheControl string) {
+	if !media_transport.ValidAssetID(id) {
+		c.JSON(http.StatusNotFound, exception.NotFoundError(errors.New("asset not found")).ToResponse())
+		return
+	}
+
+	// ids are checksums of the content, assets never change
+	etag := `"` + strings.TrimSuffix(id, filepath.Ext(id)) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", assetContentSecurityPolicy)
+	c.Header("Referrer-Policy", "no-referrer")
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	asset, err := plugin_manager.Manager().GetAsset(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	contentType, ok := assetContentTypes[strings.ToLower(filepath.Ext(id))]
+	if !ok {
+		contentType = "application/octet-stream"
+		c.Header("Content-Disposition", "attachment")
+	}
+
+	c.Data(http.StatusOK, contentType, asset)
+}
+
+// GetSignedPluginPackage serves packages of signed urls, identifiers carry checksums so packages never change
+func GetSignedPluginPackage(secret *secrets.Value) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		identifier, err := plugin_entities.NewPluginUniqueIdentifier(query.Get(asset_url.QUERY_PLUGIN_UNIQUE_IDENTIFIER))
+		if err != nil {
+			c.JSON(http.StatusNotFound, exception.NotFoundError(errors.New("package not found")).ToResponse())
+			return
+		}
+		expiresAt, err := asset_url.Verify(secret.Get(), asset_url.PackageResource(identifier.String()), query, time.Now())
+		if err != nil {
+			c.JSON(http.StatusForbidden, exception.PermissionDeniedError(err.Error()).ToResponse())
+			return
+		}
+
+		etag := `"` + identifier.Checksum() + `"`
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", signedCacheControl(expiresAt))
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		pkg, err := service.FetchPluginPackage(identifier)
+		if err != nil {
+			c.JSON(http.StatusNotFound, exception.ErrPluginNotFound().ToResponse())
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(packageFilename(identifier)))
+		c.Data(http.StatusOK, "application/octet-stream", pkg)
+	}
+}
+
+// SignAssetURLs signs urls of assets and packages for browsers and cdns fetching them without the server key
+func SignAssetURLs(secret *secrets.Value, config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			AssetIDs                []string                                 `json:"asset_ids" validate:"max=1000"`
+			PluginUniqueIdentifiers []plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifiers" validate:"max=1000,dive,plugin_unique_identifier"`
+		}) {
+			expires := asset_url.Expiry(time.Now(), time.Duration(config.AssetSignedURLTTL)*time.Second)
+
+			assets := map[string]string{}
+			for _, id := range request.AssetIDs {
+				if !media_transport.ValidAssetID(id) {
+					c.JSON(http.StatusOK, exception.BadRequestError(fmt.Errorf("invalid asset id %s", id)).ToResponse())
+					return
+				}
+				assets[id] = asset_url.AssetURL(config.AssetBaseURL, secret.Get(), id, expires)
+			}
+			packages := map[string]string{}
+			for _, identifier := range request.PluginUniqueIdentifiers {
+				packages[identifier.String()] = asset_url.PackageURL(config.AssetBaseURL, secret.Get(), identifier.String(), expires)
+			}
+
+			c.JSON(http.StatusOK, entities.NewSuccessResponse(map[string]any{
+				"assets":     assets,
+				"packages":   packages,
+				"expires_at": expires.Unix(),
+			}))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/asset_url"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/secrets"
+)
+
+func TestGetAssetRejectsInvalidIDs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/asset/:id", GetAsset)
+
+	for _, id := range []string{"..", "abc.svg", strings.Repeat("g", 64) + ".png"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/asset/"+id, nil))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %d", id, recorder.Code)
+		}
+	}
+}
+
+func TestGetAssetNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/asset/:id", GetAsset)
+
+	checksum := strings.Repeat("a", 64)
+	request := httptest.NewRequest(http.MethodGet, "/asset/"+checksum+".svg", nil)
+	request.Header.Set("If-None-Match", `"`+checksum+`"`)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", recorder.Code)
+	}
+	if recorder.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("expected sniffing to be disabled")
+	}
+	if !strings.Contains(recorder.Header().Get("Content-Security-Policy"), "sandbox") {
+		t.Error("expected assets to be sandboxed")
+	}
+}
+
+func TestGetSignedAsset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/assets/:id", GetSignedAsset(secrets.Watch("ASSET_SIGNING_SECRET", "secret")))
+
+	checksum := strings.Repeat("a", 64)
+	signed, _ := url.Parse(asset_url.AssetURL("", "secret", checksum+".svg", time.Now().Add(time.Hour)))
+
+	request := httptest.NewRequest(http.MethodGet, signed.String(), nil)
+	request.Header.Set("If-None-Match", `"other", W/"`+checksum+`"`)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", recorder.Code)
+	}
+	cacheControl := recorder.Header().Get("Cache-Control")
+	if !strings.HasPrefix(cacheControl, "public, max-age=") || strings.Contains(cacheControl, "max-age=31536000") {
+		t.Fatalf("responses of signed urls should be cached publicly until they expire, got %s", cacheControl)
+	}
+
+	unsigned := httptest.NewRecorder()
+	router.ServeHTTP(unsigned, httptest.NewRequest(http.MethodGet, "/assets/"+checksum+".svg", nil))
+	if unsigned.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without a signature, got %d", unsigned.Code)
+	}
+}
@@ -1,11 +1,19 @@
 package controllers
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
 
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/versioning"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
 )
@@ -13,37 +21,69 @@ import (
 func BindRequest[T any](r *gin.Context, success func(T)) {
 	var request T
 
+	var err error
 	if r.Request.Header.Get("Content-Type") == "application/json" {
-		r.ShouldBindJSON(&request)
+		err = r.ShouldBindJSON(&request)
 	} else {
-		r.ShouldBind(&request)
+		err = r.ShouldBind(&request)
+	}
+	// requests without a body are validated by the rules of their fields
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondValidationError(r, bindingFieldErrors(err, reflect.TypeOf(request))...)
+		return
 	}
 
 	// bind uri
-	r.ShouldBindUri(&request)
+	if err := r.ShouldBindUri(&request); err != nil {
+		respondValidationError(r, bindingFieldErrors(err, reflect.TypeOf(request))...)
+		return
+	}
 
 	// validate, we have customized some validators which are not supported by gin binding
 	if err := validators.GlobalEntitiesValidator.Struct(request); err != nil {
-		r.JSON(400, exception.BadRequestError(err).ToResponse())
+		respondValidationError(r, bindingFieldErrors(err, reflect.TypeOf(request))...)
 		return
 	}
 
 	success(request)
 }
 
+// respondValidationError replies the fields failing validation with 422
+func respondValidationError(r *gin.Context, fields ...validators.FieldError) {
+	r.JSON(http.StatusUnprocessableEntity, exception.ValidationError(fields).ToResponse())
+}
+
+// bindingFieldErrors describes the failure of binding or validating a request of type t
+func bindingFieldErrors(err error, t reflect.Type) []validators.FieldError {
+	if fields := validators.FieldErrors(err, t); fields != nil {
+		return fields
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return []validators.FieldError{{
+			Field:   typeError.Field,
+			Rule:    "type",
+			Param:   typeError.Type.String(),
+			Message: fmt.Sprintf("must be of type %s, got %s", typeError.Type.String(), typeError.Value),
+		}}
+	}
+
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return []validators.FieldError{{Rule: "json", Message: "malformed json: " + err.Error()}}
+	}
+
+	// values of forms and uris which can't be parsed, e.g. `page=abc`
+	return []validators.FieldError{{Rule: "binding", Message: err.Error()}}
+}
+
 func BindPluginDispatchRequest[T any](r *gin.Context, success func(
 	plugin_entities.InvokePluginRequest[T],
 )) {
 	BindRequest(r, func(req plugin_entities.InvokePluginRequest[T]) {
-		pluginUniqueIdentifierAny, exists := r.Get(constants.CONTEXT_KEY_PLUGIN_UNIQUE_IDENTIFIER)
-		if !exists {
-			r.JSON(400, exception.UniqueIdentifierError(errors.New("Plugin unique identifier is required")).ToResponse())
-			return
-		}
-
-		pluginUniqueIdentifier, ok := pluginUniqueIdentifierAny.(plugin_entities.PluginUniqueIdentifier)
+		pluginUniqueIdentifier, ok := boundPluginUniqueIdentifier(r)
 		if !ok {
-			r.JSON(400, exception.UniqueIdentifierError(errors.New("Plugin unique identifier is not valid")).ToResponse())
 			return
 		}
 
@@ -53,3 +93,88 @@ func BindPluginDispatchRequest[T any](r *gin.Context, success func(
 		success(req)
 	})
 }
+
+// boundPluginUniqueIdentifier returns the identifier of the plugin installation fetched by the middlewares,
+// the request is replied if it's missing
+func boundPluginUniqueIdentifier(r *gin.Context) (plugin_entities.PluginUniqueIdentifier, bool) {
+	pluginUniqueIdentifierAny, exists := r.Get(constants.CONTEXT_KEY_PLUGIN_UNIQUE_IDENTIFIER)
+	if !exists {
+		r.JSON(400, exception.UniqueIdentifierError(errors.New("Plugin unique identifier is required")).ToResponse())
+		return "", false
+	}
+
+	pluginUniqueIdentifier, ok := pluginUniqueIdentifierAny.(plugin_entities.PluginUniqueIdentifier)
+	if !ok {
+		r.JSON(400, exception.UniqueIdentifierError(errors.New("Plugin unique identifier is not valid")).ToResponse())
+		return "", false
+	}
+
+	return pluginUniqueIdentifier, true
+}
+
+// listRequest is the pagination and the filters of list apis, the page is taken from the cursor
+// replied by the previous page if it's set
+type listRequest struct {
+	Page     int    `form:"page" validate:"required_without=Cursor,omitempty,min=1"`
+	Cursor   string `form:"cursor" validate:"omitempty,max=64"`
+	PluginID string `form:"plugin_id" validate:"omitempty,max=255"`
+	Search   string `form:"search" validate:"omitempty,max=127"`
+	Enabled  *bool  `form:"enabled"`
+}
+
+// bindListOptions returns the options of the list request, the request is replied if the cursor is invalid
+func bindListOptions(r *gin.Context, request listRequest, pageSize int) (service.ListOptions, bool) {
+	options, err := service.NewListOptions(request.Page, pageSize, request.Cursor)
+	if err != nil {
+		respondValidationError(r, validators.FieldError{
+			Field: "cursor", Rule: "cursor", Message: "must be a cursor replied by the previous page",
+		})
+		return options, false
+	}
+
+	options.PluginID = request.PluginID
+	options.Search = request.Search
+	options.Enabled = request.Enabled
+	return options, true
+}
+
+// respondList replies a list in the envelope of lists, callers of v1 get the items only as they used to
+func respondList(r *gin.Context, response *entities.Response) {
+	if list, ok := response.Data.(entities.Lister); ok && versioning.FromRequest(r.Request) == versioning.V1 {
+		response.Data = list.ListItems()
+	}
+	respondLocalized(r, response)
+}
+
+// requestLocales returns the locales requested by the locale parameter, or the Accept-Language header
+// if it's absent, ok is false if neither of them is set
+func requestLocales(r *gin.Context) ([]string, bool) {
+	locale := r.Query("locale")
+	if locale == "" {
+		locale = r.GetHeader("Accept-Language")
+	}
+	if locale == "" {
+		return nil, false
+	}
+	return plugin_entities.ParseLocales(locale), true
+}
+
+// respondLocalized replies the response with labels and descriptions of declarations in it resolved to
+// the strings of the requested locales, all the translations are replied if no locale is requested
+func respondLocalized(r *gin.Context, response *entities.Response) {
+	r.Writer.Header().Add("Vary", "Accept-Language")
+
+	locales, ok := requestLocales(r)
+	if !ok || response.Code != 0 {
+		r.JSON(http.StatusOK, response)
+		return
+	}
+
+	localized, err := plugin_entities.LocalizeI18n(response.Data, locales)
+	if err != nil {
+		r.JSON(http.StatusOK, exception.InternalServerError(err).ToResponse())
+		return
+	}
+	response.Data = localized
+	r.JSON(http.StatusOK, response)
+}
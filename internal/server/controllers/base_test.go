@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+func TestListRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	var bound service.ListOptions
+	router.GET("/plugin/:tenant_id/list", func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			listRequest
+			TenantID string `uri:"tenant_id" validate:"required"`
+			PageSize int    `form:"page_size" validate:"required,max=100"`
+		}) {
+			options, ok := bindListOptions(c, request.listRequest, request.PageSize)
+			if !ok {
+				return
+			}
+			bound = options
+			respondList(c, entities.NewSuccessResponse(entities.List[string]{Items: []string{"a"}, Total: 1}))
+		})
+	})
+
+	serve := func(query string, version string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/plugin/tenant/list?"+query, nil)
+		if version != "" {
+			request.Header.Set(constants.X_API_VERSION, version)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := serve("page=2&page_size=10&plugin_id=author/name&search=hook&enabled=true", "")
+	if recorder.Body.String() != `{"code":0,"message":"success","data":["a"]}` {
+		t.Fatalf("v1 should reply the items only, got %s", recorder.Body.String())
+	}
+	if bound.Page != 2 || bound.PageSize != 10 || bound.PluginID != "author/name" ||
+		bound.Search != "hook" || bound.Enabled == nil || !*bound.Enabled {
+		t.Fatalf("unexpected options %+v", bound)
+	}
+
+	recorder = serve("page=1&page_size=10", "v2")
+	if recorder.Body.String() != `{"code":0,"message":"success","data":{"items":["a"],"total":1,"next_cursor":""}}` {
+		t.Fatalf("v2 should reply the list envelope, got %s", recorder.Body.String())
+	}
+
+	if recorder := serve("page_size=10", ""); recorder.Code != 422 {
+		t.Fatalf("page or cursor is required, got %d", recorder.Code)
+	}
+	if recorder := serve("cursor=invalid&page_size=10", ""); recorder.Code != 422 {
+		t.Fatalf("invalid cursor should be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestBindRequestValidationErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	type setting struct {
+		Name string `json:"name" validate:"required"`
+	}
+	router.POST("/plugin/:tenant_id/setup", func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			listRequest
+			TenantID               string                                 `uri:"tenant_id" validate:"required"`
+			PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+			Settings               []setting                              `json:"settings" validate:"max=2,dive"`
+		}) {
+			c.JSON(200, entities.NewSuccessResponse(true))
+		})
+	})
+
+	serve := func(body string) (*httptest.ResponseRecorder, []validators.FieldError) {
+		request := httptest.NewRequest(http.MethodPost, "/plugin/tenant/setup", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		response := entities.Response{}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		var message struct {
+			ErrorType string `json:"error_type"`
+			Args      struct {
+				Fields []validators.FieldError `json:"fields"`
+			} `json:"args"`
+		}
+		json.Unmarshal([]byte(response.Message), &message)
+		if recorder.Code == 422 && (response.Code != -422 || message.ErrorType != exception.PluginDaemonValidationError) {
+			t.Fatalf("unexpected validation error %s", recorder.Body.String())
+		}
+		return recorder, message.Args.Fields
+	}
+
+	recorder, fields := serve(`{"plugin_unique_identifier":"invalid","settings":[{"name":"a"},{}]}`)
+	if recorder.Code != 422 {
+		t.Fatalf("expected 422, got %d", recorder.Code)
+	}
+	expected := []validators.FieldError{
+		{Field: "page", Rule: "required_without", Param: "Cursor", Message: "is required"},
+		{Field: "plugin_unique_identifier", Rule: "plugin_unique_identifier", Message: "must be a valid plugin_unique_identifier"},
+		{Field: "settings[1].name", Rule: "required", Message: "is required"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("unexpected fields %+v", fields)
+	}
+
+	_, fields = serve(`{"page":1,"plugin_unique_identifier":1}`)
+	if len(fields) != 1 || fields[0].Field != "plugin_unique_identifier" || fields[0].Rule != "type" {
+		t.Fatalf("mistyped fields should be reported, got %+v", fields)
+	}
+
+	_, fields = serve(`{"page":1,"plugin_unique_identifier":`)
+	if len(fields) != 1 || fields[0].Rule != "json" {
+		t.Fatalf("malformed json should be reported, got %+v", fields)
+	}
+
+	identifier := "langgenius/openai:0.0.1@" + strings.Repeat("a", 64)
+	if recorder, _ := serve(`{"page":1,"plugin_unique_identifier":"` + identifier + `"}`); recorder.Code != 200 {
+		t.Fatalf("valid request should be served, got %s", recorder.Body.String())
+	}
+}
+
+func TestRespondLocalized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	router.GET("/plugin/:tenant_id/tools", func(c *gin.Context) {
+		respondLocalized(c, entities.NewSuccessResponse([]map[string]any{{
+			"provider": "search",
+			"label":    plugin_entities.I18nObject{EnUS: "Search", ZhHans: "搜索"},
+		}}))
+	})
+
+	serve := func(query string, acceptLanguage string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/plugin/tenant/tools?"+query, nil)
+		if acceptLanguage != "" {
+			request.Header.Set("Accept-Language", acceptLanguage)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := serve("", "")
+	if recorder.Body.String() != `{"code":0,"message":"success","data":[{"label":{"en_US":"Search","zh_Hans":"搜索"},"provider":"search"}]}` {
+		t.Fatalf("all the translations should be replied without a locale, got %s", recorder.Body.String())
+	}
+	if recorder.Header().Get("Vary") != "Accept-Language" {
+		t.Fatalf("expected the response to vary by Accept-Language, got %q", recorder.Header().Get("Vary"))
+	}
+
+	recorder = serve("", "zh-CN,zh;q=0.9,en;q=0.8")
+	if recorder.Body.String() != `{"code":0,"message":"success","data":[{"label":"搜索","provider":"search"}]}` {
+		t.Fatalf("labels should be resolved by Accept-Language, got %s", recorder.Body.String())
+	}
+
+	recorder = serve("locale=ja_JP", "zh-CN")
+	if recorder.Body.String() != `{"code":0,"message":"success","data":[{"label":"Search","provider":"search"}]}` {
+		t.Fatalf("the locale parameter should take precedence and fall back to en_US, got %s", recorder.Body.String())
+	}
+}
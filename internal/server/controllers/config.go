@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func GetConfigReloadStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, service.GetConfigReloadStatus())
+}
+
+func ReloadConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ReloadConfig())
+}
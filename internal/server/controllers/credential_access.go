@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func ListCredentialAccessLogs(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `form:"tenant_id" validate:"omitempty,max=64"`
+		Identity string `form:"identity" validate:"omitempty,max=64"`
+		Caller   string `form:"caller" validate:"omitempty,max=255"`
+		Page     int    `form:"page" validate:"required,min=1"`
+		PageSize int    `form:"page_size" validate:"required,max=100"`
+	}) {
+		c.JSON(http.StatusOK, service.ListCredentialAccessLogs(
+			request.TenantID, request.Identity, request.Caller, request.Page, request.PageSize,
+		))
+	})
+}
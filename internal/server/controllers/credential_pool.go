@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func ListCredentialPools(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.ListCredentialPools(request.TenantID))
+	})
+}
+
+func CreateCredentialPool(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		PluginID string `json:"plugin_id" validate:"required"`
+		Provider string `json:"provider" validate:"required,max=127"`
+		Name     string `json:"name" validate:"required,max=127"`
+	}) {
+		ctx.JSON(200, service.CreateCredentialPool(models.CredentialPool{
+			TenantID: request.TenantID,
+			PluginID: request.PluginID,
+			Provider: request.Provider,
+			Name:     request.Name,
+		}))
+	})
+}
+
+func DeleteCredentialPool(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		PoolID   string `json:"pool_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.DeleteCredentialPool(request.TenantID, request.PoolID))
+	})
+}
+
+func AddCredentialPoolKey(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID        string         `uri:"tenant_id" validate:"required"`
+		PoolID          string         `json:"pool_id" validate:"required"`
+		Label           string         `json:"label" validate:"max=127"`
+		Credentials     map[string]any `json:"credentials" validate:"required"`
+		Weight          int            `json:"weight" validate:"omitempty,min=1,max=1000"`
+		CooldownSeconds int            `json:"cooldown_seconds" validate:"omitempty,min=1,max=86400"`
+	}) {
+		ctx.JSON(200, service.AddCredentialPoolKey(request.TenantID, request.PoolID, models.CredentialPoolKey{
+			Label:           request.Label,
+			Weight:          request.Weight,
+			CooldownSeconds: request.CooldownSeconds,
+		}, request.Credentials))
+	})
+}
+
+func UpdateCredentialPoolKey(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID        string         `uri:"tenant_id" validate:"required"`
+		KeyID           string         `json:"key_id" validate:"required"`
+		Label           string         `json:"label" validate:"max=127"`
+		Credentials     map[string]any `json:"credentials"`
+		Weight          int            `json:"weight" validate:"required,min=1,max=1000"`
+		CooldownSeconds int            `json:"cooldown_seconds" validate:"required,min=1,max=86400"`
+		Disabled        bool           `json:"disabled"`
+	}) {
+		ctx.JSON(200, service.UpdateCredentialPoolKey(request.TenantID, request.KeyID, models.CredentialPoolKey{
+			Label:           request.Label,
+			Weight:          request.Weight,
+			CooldownSeconds: request.CooldownSeconds,
+			Disabled:        request.Disabled,
+		}, request.Credentials))
+	})
+}
+
+func RemoveCredentialPoolKey(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		KeyID    string `json:"key_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.RemoveCredentialPoolKey(request.TenantID, request.KeyID))
+	})
+}
+
+func ResetCredentialPoolKeyCooldown(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		KeyID    string `json:"key_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.ResetCredentialPoolKeyCooldown(request.TenantID, request.KeyID))
+	})
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func PlanDeclaredState(c *gin.Context) {
+	BindRequest(c, func(request service.DeclaredState) {
+		c.JSON(http.StatusOK, service.PlanDeclaredState(&request))
+	})
+}
+
+func ApplyDeclaredState(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request service.DeclaredState) {
+			c.JSON(http.StatusOK, service.ApplyDeclaredState(c.Request.Context(), config, &request))
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func StartDevPlugin(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID   string `uri:"tenant_id" validate:"required"`
+			SourcePath string `json:"source_path" validate:"required"`
+		}) {
+			c.JSON(http.StatusOK, service.StartDevPlugin(config, request.TenantID, request.SourcePath))
+		})
+	}
+}
+
+func StopDevPlugin(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+		}) {
+			c.JSON(http.StatusOK, service.StopDevPlugin(config, request.PluginUniqueIdentifier))
+		})
+	}
+}
+
+func ListDevPlugins(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, service.ListDevPlugins(config))
+	}
+}
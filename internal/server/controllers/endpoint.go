@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
 )
 
 func SetupEndpoint(ctx *gin.Context) {
@@ -30,31 +31,48 @@ func SetupEndpoint(ctx *gin.Context) {
 
 func ListEndpoints(ctx *gin.Context) {
 	BindRequest(ctx, func(request struct {
+		listRequest
 		TenantID string `uri:"tenant_id" validate:"required"`
-		Page     int    `form:"page" validate:"required"`
 		PageSize int    `form:"page_size" validate:"required,max=100"`
+		Fields   string `form:"fields"`
+		Reveal   string `form:"reveal"`
 	}) {
-		tenantId := request.TenantID
-		page := request.Page
-		pageSize := request.PageSize
+		options, ok := bindListOptions(ctx, request.listRequest, request.PageSize)
+		if !ok {
+			return
+		}
+		projection, err := service.ParseEndpointProjection(request.Fields, request.Reveal)
+		if err != nil {
+			respondValidationError(ctx, validators.FieldError{Rule: "projection", Message: err.Error()})
+			return
+		}
 
-		ctx.JSON(200, service.ListEndpoints(tenantId, page, pageSize))
+		respondList(ctx, service.ListEndpoints(ctx.Request.Context(), request.TenantID, options, projection))
 	})
 }
 
 func ListPluginEndpoints(ctx *gin.Context) {
 	BindRequest(ctx, func(request struct {
+		listRequest
 		TenantID string `uri:"tenant_id" validate:"required"`
 		PluginID string `form:"plugin_id" validate:"required"`
-		Page     int    `form:"page" validate:"required"`
 		PageSize int    `form:"page_size" validate:"required,max=100"`
+		Fields   string `form:"fields"`
+		Reveal   string `form:"reveal"`
 	}) {
-		tenantId := request.TenantID
-		pluginId := request.PluginID
-		page := request.Page
-		pageSize := request.PageSize
+		options, ok := bindListOptions(ctx, request.listRequest, request.PageSize)
+		if !ok {
+			return
+		}
+		projection, err := service.ParseEndpointProjection(request.Fields, request.Reveal)
+		if err != nil {
+			respondValidationError(ctx, validators.FieldError{Rule: "projection", Message: err.Error()})
+			return
+		}
 
-		ctx.JSON(200, service.ListPluginEndpoints(tenantId, pluginId, page, pageSize))
+		respondList(ctx, service.ListPluginEndpoints(
+			ctx.Request.Context(), request.TenantID, request.PluginID, options, projection,
+		))
 	})
 }
 
@@ -84,7 +102,7 @@ func UpdateEndpoint(ctx *gin.Context) {
 		settings := request.Settings
 		name := request.Name
 
-		ctx.JSON(200, service.UpdateEndpoint(endpointId, tenantId, userId, name, settings))
+		ctx.JSON(200, service.UpdateEndpoint(ctx.Request.Context(), endpointId, tenantId, userId, name, settings))
 	})
 }
 
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func ListEndpointTemplates(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.ListEndpointTemplates(request.TenantID))
+	})
+}
+
+func CreateEndpointTemplate(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID     string                            `uri:"tenant_id" validate:"required"`
+		Name         string                            `json:"name" validate:"required,max=127"`
+		PluginID     string                            `json:"plugin_id" validate:"required"`
+		EndpointName string                            `json:"endpoint_name" validate:"required"`
+		Variables    []models.EndpointTemplateVariable `json:"variables" validate:"omitempty,dive"`
+		Settings     map[string]any                    `json:"settings" validate:"omitempty"`
+		PathPrefix   string                            `json:"path_prefix" validate:"omitempty"`
+		Headers      map[string]string                 `json:"headers" validate:"omitempty"`
+	}) {
+		ctx.JSON(200, service.CreateEndpointTemplate(models.EndpointTemplate{
+			TenantID:     request.TenantID,
+			Name:         request.Name,
+			PluginID:     request.PluginID,
+			EndpointName: request.EndpointName,
+			Variables:    request.Variables,
+			Settings:     request.Settings,
+			PathPrefix:   request.PathPrefix,
+			Headers:      request.Headers,
+		}))
+	})
+}
+
+func UpdateEndpointTemplate(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID     string                            `uri:"tenant_id" validate:"required"`
+		TemplateID   string                            `json:"template_id" validate:"required"`
+		EndpointName string                            `json:"endpoint_name" validate:"required"`
+		Variables    []models.EndpointTemplateVariable `json:"variables" validate:"omitempty,dive"`
+		Settings     map[string]any                    `json:"settings" validate:"omitempty"`
+		PathPrefix   string                            `json:"path_prefix" validate:"omitempty"`
+		Headers      map[string]string                 `json:"headers" validate:"omitempty"`
+	}) {
+		ctx.JSON(200, service.UpdateEndpointTemplate(request.TenantID, request.TemplateID, models.EndpointTemplate{
+			EndpointName: request.EndpointName,
+			Variables:    request.Variables,
+			Settings:     request.Settings,
+			PathPrefix:   request.PathPrefix,
+			Headers:      request.Headers,
+		}))
+	})
+}
+
+func DeleteEndpointTemplate(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID   string `uri:"tenant_id" validate:"required"`
+		TemplateID string `json:"template_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.DeleteEndpointTemplate(request.TenantID, request.TemplateID))
+	})
+}
+
+func MaterializeEndpointTemplate(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID   string              `uri:"tenant_id" validate:"required"`
+		UserID     string              `json:"user_id" validate:"required"`
+		TemplateID string              `json:"template_id" validate:"required"`
+		Instances  []map[string]string `json:"instances" validate:"required,min=1,max=100"`
+	}) {
+		ctx.JSON(200, service.MaterializeEndpointTemplate(
+			request.TenantID, request.UserID, request.TemplateID, request.Instances,
+		))
+	})
+}
+
+func ListEndpointTemplateInstances(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID   string `uri:"tenant_id" validate:"required"`
+		TemplateID string `form:"template_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.ListEndpointTemplateInstances(request.TenantID, request.TemplateID))
+	})
+}
+
+func ManageEndpointTemplateInstances(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID    string   `uri:"tenant_id" validate:"required"`
+		UserID      string   `json:"user_id"`
+		TemplateID  string   `json:"template_id" validate:"required"`
+		Action      string   `json:"action" validate:"required,oneof=sync enable disable remove"`
+		EndpointIDs []string `json:"endpoint_ids" validate:"omitempty,max=256"`
+	}) {
+		ctx.JSON(200, service.ManageEndpointTemplateInstances(
+			ctx.Request.Context(), request.TenantID, request.UserID, request.TemplateID, request.Action, request.EndpointIDs,
+		))
+	})
+}
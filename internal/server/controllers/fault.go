@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/fault"
+)
+
+func ListFaults(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListFaults())
+}
+
+func SetFaults(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Rules []fault.Rule `json:"rules" validate:"max=64,dive"`
+	}) {
+		c.JSON(http.StatusOK, service.SetFaults(request.Rules))
+	})
+}
+
+func ClearFaults(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ClearFaults())
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func ListFeatureFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListFeatureFlags())
+}
+
+func UpdateFeatureFlag(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Name           string   `json:"name" validate:"required,max=127"`
+		Enabled        bool     `json:"enabled"`
+		Percentage     int      `json:"percentage" validate:"min=0,max=100"`
+		Tenants        []string `json:"tenants" validate:"omitempty,max=1024,dive,max=64"`
+		BlockedTenants []string `json:"blocked_tenants" validate:"omitempty,max=1024,dive,max=64"`
+	}) {
+		c.JSON(http.StatusOK, service.UpdateFeatureFlag(
+			request.Name,
+			request.Enabled,
+			request.Percentage,
+			request.Tenants,
+			request.BlockedTenants,
+		))
+	})
+}
+
+func DeleteFeatureFlag(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Name string `json:"name" validate:"required,max=127"`
+	}) {
+		c.JSON(http.StatusOK, service.DeleteFeatureFlag(request.Name))
+	})
+}
+
+func EvaluateFeatureFlags(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `form:"tenant_id" validate:"required,max=64"`
+	}) {
+		c.JSON(http.StatusOK, service.EvaluateFeatureFlags(request.TenantID))
+	})
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func GetGuardrailPolicy(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.GetGuardrailPolicy(request.TenantID))
+	})
+}
+
+func UpdateGuardrailPolicy(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string                 `uri:"tenant_id" validate:"required"`
+		Enabled  bool                   `json:"enabled"`
+		Hooks    []models.GuardrailHook `json:"hooks" validate:"omitempty,max=32,dive"`
+	}) {
+		c.JSON(http.StatusOK, service.UpdateGuardrailPolicy(request.TenantID, request.Enabled, request.Hooks))
+	})
+}
+
+func ListGuardrailEvents(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID  string `uri:"tenant_id" validate:"required"`
+		Outcome   string `form:"outcome" validate:"omitempty,oneof=block flag redact"`
+		SessionID string `form:"session_id" validate:"omitempty,max=64"`
+		Page      int    `form:"page" validate:"required,min=1"`
+		PageSize  int    `form:"page_size" validate:"required,max=100"`
+	}) {
+		c.JSON(http.StatusOK, service.ListGuardrailEvents(
+			request.TenantID, request.Outcome, request.SessionID, request.Page, request.PageSize,
+		))
+	})
+}
@@ -12,6 +12,7 @@ func HealthCheck(app *app.Config) gin.HandlerFunc {
 		c.JSON(200, gin.H{
 			"status":      "ok",
 			"pool_status": routine.FetchRoutineStatus(),
+			"pools":       routine.FetchPoolStats(),
 			"version":     manifest.VersionX,
 			"build_time":  manifest.BuildTimeX,
 			"platform":    app.Platform,
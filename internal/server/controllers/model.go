@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -17,7 +18,7 @@ func InvokeLLM(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.InvokeLLM(&itr, c, config.PluginMaxExecutionTimeout)
+				service.InvokeLLM(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -30,7 +31,7 @@ func InvokeTextEmbedding(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.InvokeTextEmbedding(&itr, c, config.PluginMaxExecutionTimeout)
+				service.InvokeTextEmbedding(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -43,7 +44,7 @@ func InvokeRerank(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.InvokeRerank(&itr, c, config.PluginMaxExecutionTimeout)
+				service.InvokeRerank(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -56,7 +57,7 @@ func InvokeTTS(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.InvokeTTS(&itr, c, config.PluginMaxExecutionTimeout)
+				service.InvokeTTS(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -69,7 +70,7 @@ func InvokeSpeech2Text(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.InvokeSpeech2Text(&itr, c, config.PluginMaxExecutionTimeout)
+				service.InvokeSpeech2Text(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -82,7 +83,7 @@ func InvokeModeration(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.InvokeModeration(&itr, c, config.PluginMaxExecutionTimeout)
+				service.InvokeModeration(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -95,7 +96,7 @@ func ValidateProviderCredentials(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.ValidateProviderCredentials(&itr, c, config.PluginMaxExecutionTimeout)
+				service.ValidateProviderCredentials(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -108,7 +109,7 @@ func ValidateModelCredentials(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.ValidateModelCredentials(&itr, c, config.PluginMaxExecutionTimeout)
+				service.ValidateModelCredentials(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -121,7 +122,7 @@ func GetTTSModelVoices(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.GetTTSModelVoices(&itr, c, config.PluginMaxExecutionTimeout)
+				service.GetTTSModelVoices(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -134,7 +135,7 @@ func GetTextEmbeddingNumTokens(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.GetTextEmbeddingNumTokens(&itr, c, config.PluginMaxExecutionTimeout)
+				service.GetTextEmbeddingNumTokens(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -147,7 +148,7 @@ func GetLLMNumTokens(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.GetLLMNumTokens(&itr, c, config.PluginMaxExecutionTimeout)
+				service.GetLLMNumTokens(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -160,7 +161,7 @@ func GetAIModelSchema(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.GetAIModelSchema(&itr, c, config.PluginMaxExecutionTimeout)
+				service.GetAIModelSchema(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -172,6 +173,14 @@ func ListModels(c *gin.Context) {
 		Page     int    `form:"page" validate:"required,min=1"`
 		PageSize int    `form:"page_size" validate:"required,min=1,max=256"`
 	}) {
-		c.JSON(http.StatusOK, service.ListModels(request.TenantID, request.Page, request.PageSize))
+		respondLocalized(c, service.ListModels(request.TenantID, request.Page, request.PageSize))
+	})
+}
+
+func PurgeModelCache(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.PurgeModelCache(request.TenantID))
 	})
 }
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// WhoAmI returns the caller of the request, along with the role if it's an operator signed in by oidc or ldap
+func WhoAmI(c *gin.Context) {
+	operator, _ := c.Get(constants.CONTEXT_KEY_OPERATOR)
+	c.JSON(http.StatusOK, entities.NewSuccessResponse(map[string]any{
+		"caller":   c.GetString(constants.CONTEXT_KEY_CALLER),
+		"operator": operator,
+	}))
+}
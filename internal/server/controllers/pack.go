@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/openapi_converter"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func PackPlugin(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID      string                `uri:"tenant_id" validate:"required"`
+			Source        *multipart.FileHeader `form:"source"`
+			GitRepository string                `form:"git_repository" validate:"required_without=Source"`
+			GitRef        string                `form:"git_ref"`
+			Install       bool                  `form:"install"`
+		}) {
+			source := service.PackPluginSource{
+				GitRepository: request.GitRepository,
+				GitRef:        request.GitRef,
+			}
+
+			if request.Source != nil {
+				if request.Source.Size > app.MaxPluginPackageSize {
+					c.JSON(http.StatusOK, exception.BadRequestError(errors.New("File size exceeds the maximum limit")).ToResponse())
+					return
+				}
+
+				sourceFile, err := request.Source.Open()
+				if err != nil {
+					c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+					return
+				}
+				defer sourceFile.Close()
+
+				source.Archive, err = io.ReadAll(sourceFile)
+				if err != nil {
+					c.JSON(http.StatusOK, exception.InternalServerError(err).ToResponse())
+					return
+				}
+			}
+
+			c.JSON(http.StatusOK, service.PackPlugin(app, request.TenantID, source, request.Install))
+		})
+	}
+}
+
+func ImportOpenAPISpec(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID  string                `uri:"tenant_id" validate:"required"`
+			Spec      *multipart.FileHeader `form:"spec" validate:"required"`
+			Author    string                `form:"author" validate:"required"`
+			Name      string                `form:"name"`
+			Version   string                `form:"version"`
+			ServerURL string                `form:"server_url" validate:"omitempty,url"`
+			Install   bool                  `form:"install"`
+		}) {
+			if request.Spec.Size > app.MaxPluginPackageSize {
+				c.JSON(http.StatusOK, exception.BadRequestError(errors.New("File size exceeds the maximum limit")).ToResponse())
+				return
+			}
+
+			specFile, err := request.Spec.Open()
+			if err != nil {
+				c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+				return
+			}
+			defer specFile.Close()
+
+			spec, err := io.ReadAll(specFile)
+			if err != nil {
+				c.JSON(http.StatusOK, exception.InternalServerError(err).ToResponse())
+				return
+			}
+
+			c.JSON(http.StatusOK, service.ImportOpenAPISpec(app, request.TenantID, spec, openapi_converter.Options{
+				Author:    request.Author,
+				Name:      request.Name,
+				Version:   request.Version,
+				ServerURL: request.ServerURL,
+			}, request.Install))
+		})
+	}
+}
+
+func packageFilename(identifier plugin_entities.PluginUniqueIdentifier) string {
+	return fmt.Sprintf("%s-%s.difypkg", strings.ReplaceAll(identifier.PluginID(), "/", "-"), identifier.Version())
+}
+
+func DownloadPluginPackage(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `form:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+	}) {
+		pkg, err := service.FetchPluginPackage(request.PluginUniqueIdentifier)
+		if err != nil {
+			c.JSON(http.StatusOK, exception.ErrPluginNotFound().ToResponse())
+			return
+		}
+
+		c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(packageFilename(request.PluginUniqueIdentifier)))
+		c.Data(http.StatusOK, "application/octet-stream", pkg)
+	})
+}
@@ -2,90 +2,67 @@ package controllers
 
 import (
 	"errors"
+	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
+	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
 )
 
-func GetAsset(c *gin.Context) {
-	pluginManager := plugin_manager.Manager()
-	asset, err := pluginManager.GetAsset(c.Param("id"))
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, exception.InternalServerError(err).ToResponse())
-		return
-	}
-
-	c.Data(http.StatusOK, "application/octet-stream", asset)
-}
-
 func UploadPlugin(app *app.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		difyPkgFileHeader, err := c.FormFile("dify_pkg")
-		if err != nil {
-			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
-			return
-		}
-
-		tenantId := c.Param("tenant_id")
-		if tenantId == "" {
-			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("Tenant ID is required")).ToResponse())
-			return
-		}
-
-		if difyPkgFileHeader.Size > app.MaxPluginPackageSize {
-			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("File size exceeds the maximum limit")).ToResponse())
-			return
-		}
-
-		verifySignature := c.PostForm("verify_signature") == "true"
+		BindRequest(c, func(request struct {
+			TenantID        string                `uri:"tenant_id" validate:"required"`
+			DifyPkg         *multipart.FileHeader `form:"dify_pkg" validate:"required"`
+			VerifySignature bool                  `form:"verify_signature"`
+		}) {
+			if request.DifyPkg.Size > app.MaxPluginPackageSize {
+				c.JSON(http.StatusOK, exception.BadRequestError(errors.New("File size exceeds the maximum limit")).ToResponse())
+				return
+			}
 
-		difyPkgFile, err := difyPkgFileHeader.Open()
-		if err != nil {
-			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
-			return
-		}
-		defer difyPkgFile.Close()
+			difyPkgFile, err := request.DifyPkg.Open()
+			if err != nil {
+				c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+				return
+			}
+			defer difyPkgFile.Close()
 
-		c.JSON(http.StatusOK, service.UploadPluginPkg(app, c, tenantId, difyPkgFile, verifySignature))
+			c.JSON(http.StatusOK, service.UploadPluginPkg(app, c, request.TenantID, difyPkgFile, request.VerifySignature))
+		})
 	}
 }
 
 func UploadBundle(app *app.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		difyBundleFileHeader, err := c.FormFile("dify_bundle")
-		if err != nil {
-			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
-			return
-		}
-
-		tenantId := c.Param("tenant_id")
-		if tenantId == "" {
-			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("Tenant ID is required")).ToResponse())
-			return
-		}
-
-		if difyBundleFileHeader.Size > app.MaxBundlePackageSize {
-			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("File size exceeds the maximum limit")).ToResponse())
-			return
-		}
-
-		verifySignature := c.PostForm("verify_signature") == "true"
+		BindRequest(c, func(request struct {
+			TenantID        string                `uri:"tenant_id" validate:"required"`
+			DifyBundle      *multipart.FileHeader `form:"dify_bundle" validate:"required"`
+			VerifySignature bool                  `form:"verify_signature"`
+		}) {
+			if request.DifyBundle.Size > app.MaxBundlePackageSize {
+				c.JSON(http.StatusOK, exception.BadRequestError(errors.New("File size exceeds the maximum limit")).ToResponse())
+				return
+			}
 
-		difyBundleFile, err := difyBundleFileHeader.Open()
-		if err != nil {
-			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
-			return
-		}
-		defer difyBundleFile.Close()
+			difyBundleFile, err := request.DifyBundle.Open()
+			if err != nil {
+				c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+				return
+			}
+			defer difyBundleFile.Close()
 
-		c.JSON(http.StatusOK, service.UploadPluginBundle(app, c, tenantId, difyBundleFile, verifySignature))
+			c.JSON(http.StatusOK, service.UploadPluginBundle(app, c, request.TenantID, difyBundleFile, request.VerifySignature))
+		})
 	}
 }
 
@@ -123,7 +100,12 @@ func InstallPluginFromIdentifiers(app *app.Config) gin.HandlerFunc {
 			}
 
 			if len(request.Metas) != len(request.PluginUniqueIdentifiers) {
-				c.JSON(http.StatusOK, exception.BadRequestError(errors.New("the number of metas must be equal to the number of plugin unique identifiers")).ToResponse())
+				respondValidationError(c, validators.FieldError{
+					Field:   "metas",
+					Rule:    "len",
+					Param:   strconv.Itoa(len(request.PluginUniqueIdentifiers)),
+					Message: "must contain as many items as plugin_unique_identifiers",
+				})
 				return
 			}
 
@@ -140,13 +122,54 @@ func InstallPluginFromIdentifiers(app *app.Config) gin.HandlerFunc {
 	}
 }
 
+func BatchInstallPlugins(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID                string                                   `uri:"tenant_id" validate:"required"`
+			PluginUniqueIdentifiers []plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifiers" validate:"required,min=1,max=128,dive,plugin_unique_identifier"`
+			Source                  string                                   `json:"source" validate:"required"`
+			Metas                   []map[string]any                         `json:"metas" validate:"omitempty"`
+		}) {
+			if request.Metas == nil {
+				request.Metas = make([]map[string]any, len(request.PluginUniqueIdentifiers))
+			}
+
+			if len(request.Metas) != len(request.PluginUniqueIdentifiers) {
+				respondValidationError(c, validators.FieldError{
+					Field:   "metas",
+					Rule:    "len",
+					Param:   strconv.Itoa(len(request.PluginUniqueIdentifiers)),
+					Message: "must contain as many items as plugin_unique_identifiers",
+				})
+				return
+			}
+
+			for i := range request.Metas {
+				if request.Metas[i] == nil {
+					request.Metas[i] = map[string]any{}
+				}
+			}
+
+			c.JSON(http.StatusOK, service.BatchInstallPlugins(
+				app, request.TenantID, request.PluginUniqueIdentifiers, request.Source, request.Metas,
+			))
+		})
+	}
+}
+
 func FetchPluginInstallationTasks(c *gin.Context) {
 	BindRequest(c, func(request struct {
-		TenantID string `uri:"tenant_id" validate:"required"`
-		Page     int    `form:"page" validate:"required,min=1"`
-		PageSize int    `form:"page_size" validate:"required,min=1,max=256"`
+		listRequest
+		TenantID string                   `uri:"tenant_id" validate:"required"`
+		PageSize int                      `form:"page_size" validate:"required,min=1,max=256"`
+		Status   models.InstallTaskStatus `form:"status" validate:"omitempty,oneof=pending running success failed"`
 	}) {
-		c.JSON(http.StatusOK, service.FetchPluginInstallationTasks(request.TenantID, request.Page, request.PageSize))
+		options, ok := bindListOptions(c, request.listRequest, request.PageSize)
+		if !ok {
+			return
+		}
+
+		respondList(c, service.FetchPluginInstallationTasks(request.TenantID, request.Status, options))
 	})
 }
 
@@ -159,6 +182,17 @@ func FetchPluginInstallationTask(c *gin.Context) {
 	})
 }
 
+func WatchPluginInstallationTask(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID string `uri:"tenant_id" validate:"required"`
+			TaskID   string `uri:"id" validate:"required"`
+		}) {
+			service.WatchPluginInstallationTask(c, request.TenantID, request.TaskID, config_loader.Live(config).PluginMaxExecutionTimeout)
+		})
+	}
+}
+
 func DeletePluginInstallationTask(c *gin.Context) {
 	BindRequest(c, func(request struct {
 		TenantID string `uri:"tenant_id" validate:"required"`
@@ -185,7 +219,9 @@ func DeletePluginInstallationItemFromTask(c *gin.Context) {
 		identifierString := strings.TrimLeft(request.Identifier, "/")
 		identifier, err := plugin_entities.NewPluginUniqueIdentifier(identifierString)
 		if err != nil {
-			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+			respondValidationError(c, validators.FieldError{
+				Field: "identifier", Rule: "plugin_unique_identifier", Message: "must be a valid plugin_unique_identifier",
+			})
 			return
 		}
 
@@ -202,6 +238,27 @@ func FetchPluginManifest(c *gin.Context) {
 	})
 }
 
+func DiffPluginDeclarations(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID                       string                                 `uri:"tenant_id" validate:"required"`
+		PluginUniqueIdentifier         plugin_entities.PluginUniqueIdentifier `form:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+		OriginalPluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `form:"original_plugin_unique_identifier" validate:"omitempty,plugin_unique_identifier"`
+	}) {
+		c.JSON(http.StatusOK, service.DiffPluginDeclarations(
+			request.TenantID, request.OriginalPluginUniqueIdentifier, request.PluginUniqueIdentifier,
+		))
+	})
+}
+
+func ValidatePluginDeclaration(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID               string                                 `uri:"tenant_id" validate:"required"`
+		PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `form:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+	}) {
+		c.JSON(http.StatusOK, service.ValidatePluginDeclaration(request.PluginUniqueIdentifier))
+	})
+}
+
 func UninstallPlugin(c *gin.Context) {
 	BindRequest(c, func(request struct {
 		TenantID             string `uri:"tenant_id" validate:"required"`
@@ -211,6 +268,150 @@ func UninstallPlugin(c *gin.Context) {
 	})
 }
 
+func BatchUninstallPlugins(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID                string                                   `uri:"tenant_id" validate:"required"`
+			PluginUniqueIdentifiers []plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifiers" validate:"required,min=1,max=128,dive,plugin_unique_identifier"`
+		}) {
+			c.JSON(http.StatusOK, service.BatchUninstallPlugins(app, request.TenantID, request.PluginUniqueIdentifiers))
+		})
+	}
+}
+
+func RepairPlugin(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID             string `uri:"tenant_id" validate:"required"`
+		PluginInstallationID string `json:"plugin_installation_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.RepairPlugin(request.TenantID, request.PluginInstallationID))
+	})
+}
+
+func CollectPluginGarbage(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			// nothing is deleted unless dry_run is explicitly set to false
+			DryRun *bool `json:"dry_run"`
+		}) {
+			c.JSON(http.StatusOK, service.CollectPluginGarbage(app, request.DryRun == nil || *request.DryRun))
+		})
+	}
+}
+
+func GetServerlessPrewarmStats(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, service.GetServerlessPrewarmStats(app))
+	}
+}
+
+func GetServerlessTransportStats(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, service.GetServerlessTransportStats(app))
+	}
+}
+
+func GetServerlessFailoverStatus(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, service.GetServerlessFailoverStatus(app))
+	}
+}
+
+func GetServerlessRegionStatus(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, service.GetServerlessRegionStatus(app))
+	}
+}
+
+func ListServerlessVersions(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginID string `form:"plugin_id" validate:"required"`
+		}) {
+			c.JSON(http.StatusOK, service.ListServerlessVersions(app, request.PluginID))
+		})
+	}
+}
+
+func RollbackServerlessVersion(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginUniqueIdentifier       plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+			TargetPluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"target_plugin_unique_identifier" validate:"omitempty,plugin_unique_identifier"`
+		}) {
+			c.JSON(http.StatusOK, service.RollbackServerlessVersion(
+				app, request.PluginUniqueIdentifier, request.TargetPluginUniqueIdentifier,
+			))
+		})
+	}
+}
+
+func CancelServerlessRollback(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+		}) {
+			c.JSON(http.StatusOK, service.CancelServerlessRollback(app, request.PluginUniqueIdentifier))
+		})
+	}
+}
+
+func ListServerlessPluginCosts(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			Start int64 `form:"start" validate:"omitempty,min=0"`
+			End   int64 `form:"end" validate:"omitempty,min=0"`
+		}) {
+			c.JSON(http.StatusOK, service.ListServerlessPluginCosts(app, request.Start, request.End))
+		})
+	}
+}
+
+func ListServerlessInvocationStats(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginID string `form:"plugin_id" validate:"required"`
+			Start    int64  `form:"start" validate:"omitempty,min=0"`
+			End      int64  `form:"end" validate:"omitempty,min=0"`
+		}) {
+			c.JSON(http.StatusOK, service.ListServerlessInvocationStats(
+				app, request.PluginID, request.Start, request.End,
+			))
+		})
+	}
+}
+
+func GetServerlessResources(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginID string `form:"plugin_id" validate:"required"`
+		}) {
+			c.JSON(http.StatusOK, service.GetServerlessResources(app, request.PluginID))
+		})
+	}
+}
+
+func UpdateServerlessResources(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginID  string                       `json:"plugin_id" validate:"required"`
+			Resources serverless.FunctionResources `json:"resources"`
+		}) {
+			c.JSON(http.StatusOK, service.UpdateServerlessResources(app, request.PluginID, request.Resources))
+		})
+	}
+}
+
+func DeleteServerlessResources(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginID string `json:"plugin_id" validate:"required"`
+		}) {
+			c.JSON(http.StatusOK, service.DeleteServerlessResources(app, request.PluginID))
+		})
+	}
+}
+
 func FetchPluginFromIdentifier(c *gin.Context) {
 	BindRequest(c, func(request struct {
 		PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `form:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
@@ -221,11 +422,16 @@ func FetchPluginFromIdentifier(c *gin.Context) {
 
 func ListPlugins(c *gin.Context) {
 	BindRequest(c, func(request struct {
+		listRequest
 		TenantID string `uri:"tenant_id" validate:"required"`
-		Page     int    `form:"page" validate:"required,min=1"`
 		PageSize int    `form:"page_size" validate:"required,min=1,max=256"`
 	}) {
-		c.JSON(http.StatusOK, service.ListPlugins(request.TenantID, request.Page, request.PageSize))
+		options, ok := bindListOptions(c, request.listRequest, request.PageSize)
+		if !ok {
+			return
+		}
+
+		respondList(c, service.ListPlugins(request.TenantID, options))
 	})
 }
 
@@ -246,3 +452,18 @@ func FetchMissingPluginInstallations(c *gin.Context) {
 		c.JSON(http.StatusOK, service.FetchMissingPluginInstallations(request.TenantID, request.PluginUniqueIdentifiers))
 	})
 }
+
+func ListPluginDailyUsage(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID string `uri:"tenant_id" validate:"required"`
+			PluginID string `form:"plugin_id" validate:"omitempty,max=255"`
+			Start    int64  `form:"start" validate:"omitempty,min=0"`
+			End      int64  `form:"end" validate:"omitempty,min=0"`
+		}) {
+			c.JSON(http.StatusOK, service.ListPluginDailyUsage(
+				app, request.TenantID, request.PluginID, request.Start, request.End,
+			))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func GetPluginPolicy(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.GetPluginPolicy(request.TenantID))
+	})
+}
+
+// pluginPolicyRequest is the policy written by operators
+type pluginPolicyRequest struct {
+	AllowedPlugins []string `json:"allowed_plugins" validate:"omitempty,max=1024,dive,max=255"`
+	BlockedPlugins []string `json:"blocked_plugins" validate:"omitempty,max=1024,dive,max=255"`
+	AllowedAuthors []string `json:"allowed_authors" validate:"omitempty,max=1024,dive,max=64"`
+	BlockedAuthors []string `json:"blocked_authors" validate:"omitempty,max=1024,dive,max=64"`
+	VerifiedOnly   bool     `json:"verified_only"`
+}
+
+func updatePluginPolicy(c *gin.Context, tenant_id string, request pluginPolicyRequest) {
+	c.JSON(http.StatusOK, service.UpdatePluginPolicy(
+		tenant_id,
+		request.AllowedPlugins,
+		request.BlockedPlugins,
+		request.AllowedAuthors,
+		request.BlockedAuthors,
+		request.VerifiedOnly,
+	))
+}
+
+// rejectGlobalPolicy replies 403 if the tenant is the global one, the global policy applies to all
+// the tenants, so it's written by operators through the admin api only
+func rejectGlobalPolicy(c *gin.Context, tenant_id string) bool {
+	if tenant_id != models.PLUGIN_POLICY_GLOBAL_TENANT {
+		return false
+	}
+	c.JSON(http.StatusForbidden, exception.PermissionDeniedError(
+		"the global policy is managed through /admin/policy",
+	).ToResponse())
+	return true
+}
+
+// UpdatePluginPolicy writes the policy of a tenant, it's an admin route as the policy restricts the tenant
+func UpdatePluginPolicy(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		pluginPolicyRequest
+		TenantID string `json:"tenant_id" validate:"required"`
+	}) {
+		if rejectGlobalPolicy(c, request.TenantID) {
+			return
+		}
+		updatePluginPolicy(c, request.TenantID, request.pluginPolicyRequest)
+	})
+}
+
+func DeletePluginPolicy(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `json:"tenant_id" validate:"required"`
+	}) {
+		if rejectGlobalPolicy(c, request.TenantID) {
+			return
+		}
+		c.JSON(http.StatusOK, service.DeletePluginPolicy(request.TenantID))
+	})
+}
+
+func GetGlobalPluginPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, service.GetPluginPolicy(models.PLUGIN_POLICY_GLOBAL_TENANT))
+}
+
+func UpdateGlobalPluginPolicy(c *gin.Context) {
+	BindRequest(c, func(request pluginPolicyRequest) {
+		updatePluginPolicy(c, models.PLUGIN_POLICY_GLOBAL_TENANT, request)
+	})
+}
+
+func DeleteGlobalPluginPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, service.DeletePluginPolicy(models.PLUGIN_POLICY_GLOBAL_TENANT))
+}
+
+func CheckPluginPolicy(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID                string                                   `uri:"tenant_id" validate:"required"`
+		PluginUniqueIdentifiers []plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifiers" validate:"required,max=256,dive,plugin_unique_identifier"`
+	}) {
+		c.JSON(http.StatusOK, service.CheckPluginPolicy(request.TenantID, request.PluginUniqueIdentifiers))
+	})
+}
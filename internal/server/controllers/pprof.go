@@ -4,6 +4,10 @@ import (
 	"net/http/pprof"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/slow_log"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/profiling"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 )
 
 func PprofIndex(c *gin.Context) {
@@ -49,3 +53,33 @@ func PprofMutex(c *gin.Context) {
 func PprofThreadcreate(c *gin.Context) {
 	pprof.Handler("threadcreate").ServeHTTP(c.Writer, c.Request)
 }
+
+// DumpGoroutines groups goroutines by the plugins and sessions they serve, filtered by ?plugin= and ?session=
+func DumpGoroutines(c *gin.Context) {
+	dump, err := profiling.DumpGoroutines(profiling.GoroutineFilter{
+		Plugin:  c.Query("plugin"),
+		Session: c.Query("session"),
+	})
+	if err != nil {
+		c.JSON(200, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	c.JSON(200, entities.NewSuccessResponse(dump))
+}
+
+// ListSlowInvocations lists the latest slow invocations served by this node,
+// filtered by ?plugin= and ?tenant_id=, at most ?limit= entries
+func ListSlowInvocations(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Plugin   string `form:"plugin"`
+		TenantID string `form:"tenant_id"`
+		Limit    int    `form:"limit" validate:"omitempty,min=1"`
+	}) {
+		c.JSON(200, entities.NewSuccessResponse(slow_log.List(slow_log.Filter{
+			Plugin:   request.Plugin,
+			TenantID: request.TenantID,
+			Limit:    request.Limit,
+		})))
+	})
+}
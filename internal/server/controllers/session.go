@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func ListSessions(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListSessions())
+}
+
+func KillSession(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		SessionID string `json:"session_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.KillSession(request.SessionID))
+	})
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func ListPluginSLOReports(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		PluginID string `form:"plugin_id" validate:"omitempty,max=255"`
+	}) {
+		c.JSON(http.StatusOK, service.ListPluginSLOReports(request.PluginID))
+	})
+}
+
+func ListPluginSLOs(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListPluginSLOs())
+}
+
+func UpdatePluginSLO(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		PluginID    string  `json:"plugin_id" validate:"required,max=255"`
+		SuccessRate float64 `json:"success_rate" validate:"min=0,max=1"`
+		LatencyP99  int64   `json:"latency_p99" validate:"min=0"`
+		Window      int     `json:"window" validate:"omitempty,min=1,max=1440"`
+	}) {
+		c.JSON(http.StatusOK, service.UpdatePluginSLO(
+			request.PluginID, request.SuccessRate, request.LatencyP99, request.Window,
+		))
+	})
+}
+
+func DeletePluginSLO(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		PluginID string `json:"plugin_id" validate:"required,max=255"`
+	}) {
+		c.JSON(http.StatusOK, service.DeletePluginSLO(request.PluginID))
+	})
+}
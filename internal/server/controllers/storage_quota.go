@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func GetTenantStorageUsage(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID string `uri:"tenant_id" validate:"required"`
+		}) {
+			c.JSON(http.StatusOK, service.GetTenantStorageUsage(config, request.TenantID))
+		})
+	}
+}
+
+// UpdateTenantStorageQuota is an admin route, the quota caps the tenant so it's never lifted by the tenant
+func UpdateTenantStorageQuota(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `json:"tenant_id" validate:"required"`
+		Quota    int64  `json:"quota" validate:"min=0"`
+	}) {
+		c.JSON(http.StatusOK, service.UpdateTenantStorageQuota(request.TenantID, request.Quota))
+	})
+}
+
+func DeleteTenantStorageQuota(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `json:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.DeleteTenantStorageQuota(request.TenantID))
+	})
+}
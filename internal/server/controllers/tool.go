@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -17,7 +18,20 @@ func InvokeTool(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.InvokeTool(&itr, c, config.PluginMaxExecutionTimeout)
+				service.InvokeTool(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
+			},
+		)
+	}
+}
+
+func InvokeToolTyped(config *app.Config) gin.HandlerFunc {
+	type request = plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]
+
+	return func(c *gin.Context) {
+		BindPluginDispatchRequest(
+			c,
+			func(itr request) {
+				service.InvokeToolTyped(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -30,7 +44,7 @@ func ValidateToolCredentials(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.ValidateToolCredentials(&itr, c, config.PluginMaxExecutionTimeout)
+				service.ValidateToolCredentials(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
@@ -43,19 +57,46 @@ func GetToolRuntimeParameters(config *app.Config) gin.HandlerFunc {
 		BindPluginDispatchRequest(
 			c,
 			func(itr request) {
-				service.GetToolRuntimeParameters(&itr, c, config.PluginMaxExecutionTimeout)
+				service.GetToolRuntimeParameters(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
 			},
 		)
 	}
 }
 
+// InvokeToolDirectly invokes a tool of the plugin installation fetched by the middlewares,
+// the request carries the parameters of the tool instead of the ones of a Dify app
+func InvokeToolDirectly(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID string `uri:"tenant_id" validate:"required"`
+			requests.RequestDirectInvokeTool
+		}) {
+			pluginUniqueIdentifier, ok := boundPluginUniqueIdentifier(c)
+			if !ok {
+				return
+			}
+
+			itr := plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]{
+				InvokePluginUserIdentity: plugin_entities.InvokePluginUserIdentity{
+					TenantId: request.TenantID,
+					UserId:   request.UserID,
+				},
+				BasePluginIdentifier: plugin_entities.BasePluginIdentifier{PluginID: request.PluginID},
+				UniqueIdentifier:     pluginUniqueIdentifier,
+				Data:                 request.RequestInvokeTool,
+			}
+			service.InvokeTool(&itr, c, config_loader.Live(config).PluginMaxExecutionTimeout)
+		})
+	}
+}
+
 func ListTools(c *gin.Context) {
 	BindRequest(c, func(request struct {
 		TenantID string `uri:"tenant_id" validate:"required"`
 		Page     int    `form:"page" validate:"required,min=1"`
 		PageSize int    `form:"page_size" validate:"required,min=1,max=256"`
 	}) {
-		c.JSON(http.StatusOK, service.ListTools(request.TenantID, request.Page, request.PageSize))
+		respondLocalized(c, service.ListTools(request.TenantID, request.Page, request.PageSize))
 	})
 }
 
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+type createWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,max=1024"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=255"`
+	Events []string `json:"events" validate:"omitempty,max=64"`
+}
+
+type updateWebhookRequest struct {
+	WebhookID string   `json:"webhook_id" validate:"required"`
+	URL       string   `json:"url" validate:"required,max=1024"`
+	Events    []string `json:"events" validate:"omitempty,max=64"`
+	Enabled   bool     `json:"enabled"`
+}
+
+type deleteWebhookRequest struct {
+	WebhookID string `json:"webhook_id" validate:"required"`
+}
+
+// rejectGlobalWebhooks replies 403 if the tenant is the global one, global webhooks receive events of
+// all the tenants, so they're managed by operators through the admin api only
+func rejectGlobalWebhooks(c *gin.Context, tenant_id string) bool {
+	if tenant_id != models.WEBHOOK_GLOBAL_TENANT {
+		return false
+	}
+	c.JSON(http.StatusForbidden, exception.PermissionDeniedError(
+		"global webhooks are managed through /admin/webhooks",
+	).ToResponse())
+	return true
+}
+
+func ListWebhooks(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		if rejectGlobalWebhooks(c, request.TenantID) {
+			return
+		}
+		c.JSON(http.StatusOK, service.ListWebhooks(request.TenantID))
+	})
+}
+
+func CreateWebhook(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		createWebhookRequest
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		if rejectGlobalWebhooks(c, request.TenantID) {
+			return
+		}
+		c.JSON(http.StatusOK, service.CreateWebhook(request.TenantID, request.URL, request.Secret, request.Events))
+	})
+}
+
+func UpdateWebhook(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		updateWebhookRequest
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		if rejectGlobalWebhooks(c, request.TenantID) {
+			return
+		}
+		c.JSON(http.StatusOK, service.UpdateWebhook(
+			request.TenantID, request.WebhookID, request.URL, request.Events, request.Enabled,
+		))
+	})
+}
+
+func DeleteWebhook(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		deleteWebhookRequest
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		if rejectGlobalWebhooks(c, request.TenantID) {
+			return
+		}
+		c.JSON(http.StatusOK, service.DeleteWebhook(request.TenantID, request.WebhookID))
+	})
+}
+
+func ListGlobalWebhooks(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListWebhooks(models.WEBHOOK_GLOBAL_TENANT))
+}
+
+func CreateGlobalWebhook(c *gin.Context) {
+	BindRequest(c, func(request createWebhookRequest) {
+		c.JSON(http.StatusOK, service.CreateWebhook(
+			models.WEBHOOK_GLOBAL_TENANT, request.URL, request.Secret, request.Events,
+		))
+	})
+}
+
+func UpdateGlobalWebhook(c *gin.Context) {
+	BindRequest(c, func(request updateWebhookRequest) {
+		c.JSON(http.StatusOK, service.UpdateWebhook(
+			models.WEBHOOK_GLOBAL_TENANT, request.WebhookID, request.URL, request.Events, request.Enabled,
+		))
+	})
+}
+
+func DeleteGlobalWebhook(c *gin.Context) {
+	BindRequest(c, func(request deleteWebhookRequest) {
+		c.JSON(http.StatusOK, service.DeleteWebhook(models.WEBHOOK_GLOBAL_TENANT, request.WebhookID))
+	})
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+)
+
+// corsPolicy allows browsers on the origins, e.g. admin consoles, to call the admin routes
+type corsPolicy struct {
+	// origins are matched exactly, `*` allows all origins
+	origins []string
+	headers string
+	maxAge  int
+}
+
+// exposedHeaders are readable by scripts of the allowed origins
+var exposedHeaders = strings.Join([]string{
+	constants.X_REQUEST_ID, constants.X_API_VERSION, "Deprecation", "Sunset",
+}, ", ")
+
+func newCORSPolicy(origins string, headers string, maxAge int) corsPolicy {
+	policy := corsPolicy{headers: headers, maxAge: maxAge}
+	for _, origin := range strings.Split(origins, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			policy.origins = append(policy.origins, origin)
+		}
+	}
+	return policy
+}
+
+func (p corsPolicy) enabled() bool {
+	return len(p.origins) > 0
+}
+
+func (p corsPolicy) allows(origin string) bool {
+	return slices.Contains(p.origins, "*") || slices.Contains(p.origins, origin)
+}
+
+// AllowingCORS replies CORS headers to requests from the allowed origins, preflight requests are replied
+// here before they're authorized as browsers send them without the api key
+func AllowingCORS(policy corsPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		c.Writer.Header().Add("Vary", "Origin")
+		if !policy.allows(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// browsers block responses without the allowed origin
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		if !preflight {
+			header.Set("Access-Control-Expose-Headers", exposedHeaders)
+			c.Next()
+			return
+		}
+
+		header.Set("Access-Control-Allow-Methods", "GET, POST")
+		header.Set("Access-Control-Allow-Headers", policy.headers)
+		header.Set("Access-Control-Max-Age", strconv.Itoa(policy.maxAge))
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// allowCORS routes preflight requests of the group, which are replied by AllowingCORS
+func allowCORS(group *gin.RouterGroup, policy corsPolicy) {
+	if !policy.enabled() {
+		return
+	}
+	group.Use(AllowingCORS(policy))
+	group.OPTIONS("/*path", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// parseTrustedProxies parses ips and cidrs of proxies like `10.0.0.0/8,192.168.1.1`
+func parseTrustedProxies(proxies string) ([]string, error) {
+	result := []string{}
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, expected an ip or a cidr", proxy)
+		}
+		result = append(result, proxy)
+	}
+	return result, nil
+}
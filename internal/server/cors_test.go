@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func TestAllowingCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	group := engine.Group("/admin")
+	allowCORS(group, newCORSPolicy("https://console.example.com/, https://ops.example.com", "Content-Type,X-Api-Key", 600))
+	group.Use(func(c *gin.Context) {
+		// stands for Authorizing, preflight requests carry no api key
+		if c.GetHeader("X-Api-Key") != "key" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	})
+	group.GET("/overview", func(c *gin.Context) {
+		c.String(http.StatusOK, "overview")
+	})
+
+	serve := func(method string, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/admin/overview", nil)
+		if origin != "" {
+			request.Header.Set("Origin", origin)
+		}
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	preflight := serve(http.MethodOptions, "https://console.example.com", map[string]string{
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "X-Api-Key",
+	})
+	if preflight.Code != http.StatusNoContent ||
+		preflight.Header().Get("Access-Control-Allow-Origin") != "https://console.example.com" ||
+		preflight.Header().Get("Access-Control-Allow-Headers") != "Content-Type,X-Api-Key" ||
+		preflight.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("preflight of an allowed origin should be replied, got %d %v", preflight.Code, preflight.Header())
+	}
+
+	if recorder := serve(http.MethodOptions, "https://evil.example.com", map[string]string{
+		"Access-Control-Request-Method": "GET",
+	}); recorder.Code != http.StatusForbidden {
+		t.Fatalf("preflight of other origins should be rejected, got %d", recorder.Code)
+	}
+
+	recorder := serve(http.MethodGet, "https://ops.example.com", map[string]string{"X-Api-Key": "key"})
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Access-Control-Allow-Origin") != "https://ops.example.com" {
+		t.Fatalf("request of an allowed origin should carry the allowed origin, got %d %v", recorder.Code, recorder.Header())
+	}
+
+	recorder = serve(http.MethodGet, "https://evil.example.com", map[string]string{"X-Api-Key": "key"})
+	if recorder.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("request of other origins should not carry the allowed origin")
+	}
+
+	if recorder := serve(http.MethodGet, "https://ops.example.com", nil); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("requests of allowed origins should still be authorized, got %d", recorder.Code)
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if _, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Fatal("invalid cidr should be rejected")
+	}
+
+	clientIP := func(proxies string, remoteAddr string) string {
+		engine := gin.New()
+		configureClientIP(engine, &app.Config{ServerTrustedProxies: proxies, ServerClientIPHeaders: "X-Forwarded-For"})
+		engine.GET("/ip", func(c *gin.Context) {
+			c.String(http.StatusOK, c.ClientIP())
+		})
+
+		request := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("X-Forwarded-For", "203.0.113.7")
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder.Body.String()
+	}
+
+	if ip := clientIP("10.0.0.0/8", "10.1.2.3:1234"); ip != "203.0.113.7" {
+		t.Fatalf("client ip should be taken from trusted proxies, got %s", ip)
+	}
+	if ip := clientIP("10.0.0.0/8", "198.51.100.1:1234"); ip != "198.51.100.1" {
+		t.Fatalf("forwarded ip of untrusted clients should be ignored, got %s", ip)
+	}
+	if ip := clientIP("", "10.1.2.3:1234"); ip != "10.1.2.3" {
+		t.Fatalf("no proxy should be trusted by default, got %s", ip)
+	}
+}
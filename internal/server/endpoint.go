@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
@@ -27,9 +28,9 @@ func (app *App) Endpoint(config *app.Config) func(c *gin.Context) {
 		path := c.Param("path")
 
 		if app.endpointHandler != nil {
-			app.endpointHandler(c, hookId, time.Duration(config.PluginMaxExecutionTimeout)*time.Second, path)
+			app.endpointHandler(c, hookId, time.Duration(config_loader.Live(config).PluginMaxExecutionTimeout)*time.Second, path)
 		} else {
-			app.EndpointHandler(c, hookId, time.Duration(config.PluginMaxExecutionTimeout)*time.Second, path)
+			app.EndpointHandler(c, hookId, time.Duration(config_loader.Live(config).PluginMaxExecutionTimeout)*time.Second, path)
 		}
 	}
 }
@@ -70,9 +71,7 @@ func (app *App) EndpointHandler(ctx *gin.Context, hookId string, maxExecutionTim
 	}
 
 	// check if plugin exists in current node
-	if ok, originalError := app.cluster.IsPluginOnCurrentNode(pluginUniqueIdentifier); !ok {
-		app.redirectPluginInvokeByPluginIdentifier(ctx, pluginUniqueIdentifier, originalError)
-	} else {
+	if app.servePluginLocally(ctx, pluginUniqueIdentifier) {
 		service.Endpoint(ctx, &endpoint, &pluginInstallation, maxExecutionTime, path)
 	}
 }
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/fault"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// initFaultInjection hooks db and redis calls and sets the rules of the config, it's called once both
+// of them are initialized
+func (app *App) initFaultInjection(config *app.Config) {
+	log.Warn("fault injection is enabled, faults are injected into the daemon by its rules")
+	fault.Enable()
+
+	if err := db.EnableFaultInjection(); err != nil {
+		log.Panic("enable db fault injection failed: %s", err.Error())
+	}
+
+	if err := cache.EnableFaultInjection(); err != nil {
+		log.Panic("enable redis fault injection failed: %s", err.Error())
+	}
+
+	if config.FaultInjectionRules == "" {
+		return
+	}
+
+	rules := []fault.Rule{}
+	if err := json.Unmarshal([]byte(config.FaultInjectionRules), &rules); err != nil {
+		log.Panic("invalid FAULT_INJECTION_RULES: %s", err.Error())
+	}
+	if err := fault.SetRules(rules); err != nil {
+		log.Panic("invalid FAULT_INJECTION_RULES: %s", err.Error())
+	}
+}
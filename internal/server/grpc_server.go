@@ -0,0 +1,287 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcForwardedMetadata are passed to routes as headers
+var grpcForwardedMetadata = []string{
+	constants.X_API_KEY, constants.X_TENANT_TOKEN, constants.X_REQUEST_ID, constants.IDEMPOTENCY_KEY,
+}
+
+// grpcJSONCodec encodes messages in json, clients set the content subtype of requests to json
+type grpcJSONCodec struct{}
+
+func (grpcJSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (grpcJSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (grpcJSONCodec) Name() string {
+	return "json"
+}
+
+// newGRPCServer creates the grpc server, calls are turned into requests to the http handler,
+// so authentication, validation and redirections across the cluster are shared with the http api
+func (app *App) newGRPCServer(config *app.Config, handler http.Handler) *grpc.Server {
+	options := []grpc.ServerOption{grpc.ForceServerCodec(grpcJSONCodec{})}
+	if app.tls != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(app.tls.ServerConfig(tlsMinVersion(config)))))
+	}
+
+	server := grpc.NewServer(options...)
+	server.RegisterService(&grpcManagementServiceDesc, &grpcGateway{handler: handler})
+	return server
+}
+
+// grpcServer starts the grpc server and returns a function to stop it
+func (app *App) grpcServer(config *app.Config, handler http.Handler) func() {
+	server := app.newGRPCServer(config, handler)
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", config.GRPCServerPort))
+	if err != nil {
+		log.Panic("grpc listen: %s\n", err)
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Panic("grpc serve: %s\n", err)
+		}
+	}()
+
+	return server.GracefulStop
+}
+
+// grpcRoute is the http request a call is served by
+type grpcRoute struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	header http.Header
+}
+
+type grpcGateway struct {
+	handler http.Handler
+}
+
+func (g *grpcGateway) serve(ctx context.Context, route grpcRoute, writer http.ResponseWriter) error {
+	var body io.Reader = http.NoBody
+	if route.body != nil {
+		data, err := json.Marshal(route.body)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		body = bytes.NewReader(data)
+	}
+
+	target := route.path
+	if len(route.query) > 0 {
+		target += "?" + route.query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, route.method, target, body)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, values := range route.header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, key := range grpcForwardedMetadata {
+			if values := md.Get(key); len(values) > 0 {
+				request.Header.Set(key, values[0])
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		request.RemoteAddr = p.Addr.String()
+	}
+
+	g.handler.ServeHTTP(writer, request)
+	return nil
+}
+
+// unary serves a call by a route replying an envelope
+func (g *grpcGateway) unary(ctx context.Context, route grpcRoute) (*entities.GenericResponse[json.RawMessage], error) {
+	writer := newGRPCResponseWriter(ctx, nil)
+	defer writer.close()
+
+	if err := g.serve(ctx, route, writer); err != nil {
+		return nil, err
+	}
+	return writer.envelope()
+}
+
+// stream serves a call by a route replying server sent events, each of them is sent as a message
+func (g *grpcGateway) stream(stream grpc.ServerStream, route grpcRoute) error {
+	writer := newGRPCResponseWriter(stream.Context(), func(event []byte) error {
+		return stream.SendMsg(json.RawMessage(event))
+	})
+
+	err := g.serve(stream.Context(), route, writer)
+	writer.close()
+	if err != nil {
+		return err
+	}
+	if writer.err != nil {
+		return status.Error(codes.Unavailable, writer.err.Error())
+	}
+	if writer.events > 0 {
+		return nil
+	}
+
+	// the request failed before any event, it's replied by a single envelope
+	envelope, err := writer.envelope()
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(envelope)
+}
+
+// grpcResponseWriter buffers the response of a route, events of server sent events are
+// passed to onEvent as soon as they're written
+type grpcResponseWriter struct {
+	mu      sync.Mutex
+	header  http.Header
+	status  int
+	body    bytes.Buffer
+	onEvent func(event []byte) error
+	events  int
+	err     error
+	closed  bool
+	notify  chan bool
+}
+
+func newGRPCResponseWriter(ctx context.Context, onEvent func(event []byte) error) *grpcResponseWriter {
+	w := &grpcResponseWriter{
+		header:  http.Header{},
+		onEvent: onEvent,
+		notify:  make(chan bool, 1),
+	}
+	// streams are stopped once the call is cancelled by the client
+	go func() {
+		<-ctx.Done()
+		w.notify <- true
+	}()
+	return w
+}
+
+func (w *grpcResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *grpcResponseWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *grpcResponseWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.body.Write(data)
+	if w.onEvent != nil && w.status == http.StatusOK {
+		w.flushEvents()
+	}
+	return len(data), nil
+}
+
+func (w *grpcResponseWriter) flushEvents() {
+	for {
+		buffered := w.body.Bytes()
+		if !bytes.HasPrefix(buffered, []byte("data: ")) {
+			return
+		}
+		end := bytes.Index(buffered, []byte("\n\n"))
+		if end == -1 {
+			return
+		}
+
+		event := bytes.Clone(buffered[len("data: "):end])
+		w.body.Next(end + 2)
+		w.events++
+		if w.err == nil {
+			w.err = w.onEvent(event)
+		}
+	}
+}
+
+func (w *grpcResponseWriter) Flush() {}
+
+func (w *grpcResponseWriter) CloseNotify() <-chan bool {
+	return w.notify
+}
+
+func (w *grpcResponseWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+}
+
+// envelope decodes the buffered response, requests rejected with a status other than 200
+// are turned into grpc errors
+func (w *grpcResponseWriter) envelope() (*entities.GenericResponse[json.RawMessage], error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	response := &entities.GenericResponse[json.RawMessage]{}
+	if err := json.Unmarshal(w.body.Bytes(), response); err != nil {
+		return nil, status.Errorf(codes.Internal, "unexpected response with status %d", w.status)
+	}
+	if w.status != http.StatusOK {
+		return nil, status.Error(grpcCode(w.status), response.Message)
+	}
+	return response, nil
+}
+
+func grpcCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestGRPCClient(t *testing.T, engine *gin.Engine) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	server := (&App{}).newGRPCServer(&app.Config{}, engine)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcJSONCodec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCUnaryCallsServedByRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/plugin/:tenant_id/endpoint/enable", func(c *gin.Context) {
+		if c.GetHeader(constants.X_API_KEY) != "key" {
+			c.AbortWithStatusJSON(401, entities.NewDaemonErrorResponse(-401, "unauthorized"))
+			return
+		}
+		request := map[string]string{}
+		c.ShouldBindJSON(&request)
+		c.JSON(200, entities.NewSuccessResponse(c.Param("tenant_id")+"/"+request["endpoint_id"]))
+	})
+	engine.GET("/plugin/:tenant_id/management/list", func(c *gin.Context) {
+		c.JSON(200, entities.NewSuccessResponse(c.Query("page")+","+c.Query("page_size")))
+	})
+
+	conn := newTestGRPCClient(t, engine)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "key")
+
+	response := &entities.GenericResponse[json.RawMessage]{}
+	err := conn.Invoke(ctx, "/"+GRPC_MANAGEMENT_SERVICE+"/EnableEndpoint",
+		&grpcEndpointRequest{TenantID: "tenant", EndpointID: "endpoint"}, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != 0 || string(response.Data) != `"tenant/endpoint"` {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	err = conn.Invoke(ctx, "/"+GRPC_MANAGEMENT_SERVICE+"/ListPlugins",
+		&grpcListRequest{TenantID: "tenant", Page: 2, PageSize: 10}, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Data) != `"2,10"` {
+		t.Fatalf("query should be passed, got %s", response.Data)
+	}
+
+	// rejected requests are grpc errors
+	err = conn.Invoke(context.Background(), "/"+GRPC_MANAGEMENT_SERVICE+"/EnableEndpoint",
+		&grpcEndpointRequest{TenantID: "tenant", EndpointID: "endpoint"}, response)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("request without the key should be unauthenticated, got %v", err)
+	}
+}
+
+func TestGRPCInvokeToolStreamsEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/plugin/:tenant_id/dispatch/tool/invoke", func(c *gin.Context) {
+		if c.GetHeader(constants.X_PLUGIN_ID) != "author/tool" {
+			c.JSON(400, entities.NewDaemonErrorResponse(-400, "plugin_id is required"))
+			return
+		}
+		c.Writer.WriteHeader(200)
+		for _, chunk := range []string{"a", "b", "c"} {
+			c.Writer.Write([]byte("data: "))
+			c.Writer.Write([]byte(`{"code":0,"message":"success","data":"` + chunk + `"}`))
+			c.Writer.Write([]byte("\n\n"))
+			c.Writer.Flush()
+		}
+	})
+
+	conn := newTestGRPCClient(t, engine)
+	desc := &grpc.StreamDesc{StreamName: "InvokeTool", ServerStreams: true}
+
+	invoke := func(pluginID string) ([]string, error) {
+		stream, err := conn.NewStream(context.Background(), desc, "/"+GRPC_MANAGEMENT_SERVICE+"/InvokeTool")
+		if err != nil {
+			return nil, err
+		}
+		request := &grpcInvokeToolRequest{}
+		request.TenantId = "tenant"
+		request.PluginID = pluginID
+		if err := stream.SendMsg(request); err != nil {
+			return nil, err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return nil, err
+		}
+
+		data := []string{}
+		for {
+			response := &entities.GenericResponse[json.RawMessage]{}
+			err := stream.RecvMsg(response)
+			if err == io.EOF {
+				return data, nil
+			}
+			if err != nil {
+				return data, err
+			}
+			data = append(data, string(response.Data))
+		}
+	}
+
+	data, err := invoke("author/tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 || data[0] != `"a"` || data[2] != `"c"` {
+		t.Fatalf("unexpected events %v", data)
+	}
+
+	_, err = invoke("")
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("rejected invocation should be an invalid argument, got %v", err)
+	}
+}
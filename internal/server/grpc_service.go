@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"google.golang.org/grpc"
+)
+
+const GRPC_MANAGEMENT_SERVICE = "dify.plugin_daemon.v1.Management"
+
+type grpcListRequest struct {
+	TenantID string `json:"tenant_id"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+	Cursor   string `json:"cursor"`
+	PluginID string `json:"plugin_id"`
+	Search   string `json:"search"`
+	Enabled  *bool  `json:"enabled"`
+	// Fields and Reveal project listed endpoints
+	Fields string `json:"fields"`
+	Reveal string `json:"reveal"`
+}
+
+type grpcInstallPluginsRequest struct {
+	TenantID                string           `json:"tenant_id"`
+	PluginUniqueIdentifiers []string         `json:"plugin_unique_identifiers"`
+	Source                  string           `json:"source"`
+	Metas                   []map[string]any `json:"metas"`
+}
+
+type grpcUninstallPluginRequest struct {
+	TenantID             string `json:"tenant_id"`
+	PluginInstallationID string `json:"plugin_installation_id"`
+}
+
+type grpcEndpointRequest struct {
+	TenantID   string `json:"tenant_id"`
+	EndpointID string `json:"endpoint_id"`
+}
+
+type grpcInvokeToolRequest = plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]
+
+func tenantPath(tenantID string, path string) string {
+	return "/plugin/" + url.PathEscape(tenantID) + path
+}
+
+func (r *grpcListRequest) query() url.Values {
+	query := url.Values{
+		"page":      {strconv.Itoa(r.Page)},
+		"page_size": {strconv.Itoa(r.PageSize)},
+	}
+	for key, value := range map[string]string{
+		"cursor": r.Cursor, "plugin_id": r.PluginID, "search": r.Search, "fields": r.Fields, "reveal": r.Reveal,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if r.Enabled != nil {
+		query.Set("enabled", strconv.FormatBool(*r.Enabled))
+	}
+	return query
+}
+
+// grpcManagementServer is implemented by the gateway, it's required to register the service
+type grpcManagementServer interface {
+	unary(ctx context.Context, route grpcRoute) (*entities.GenericResponse[json.RawMessage], error)
+	stream(stream grpc.ServerStream, route grpcRoute) error
+}
+
+// grpcUnaryMethod serves a method by the route built from its request, the reply is the envelope of the route
+func grpcUnaryMethod[T any](name string, route func(request *T) grpcRoute) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			request := new(T)
+			if err := dec(request); err != nil {
+				return nil, err
+			}
+
+			handler := func(ctx context.Context, request any) (any, error) {
+				return srv.(grpcManagementServer).unary(ctx, route(request.(*T)))
+			}
+			if interceptor == nil {
+				return handler(ctx, request)
+			}
+			return interceptor(ctx, request, &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + GRPC_MANAGEMENT_SERVICE + "/" + name,
+			}, handler)
+		},
+	}
+}
+
+// grpcManagementServiceDesc describes the service, messages are the json bodies of the http routes with
+// the tenant id, replies are envelopes of the http api, the data of InvokeTool events are tool response chunks
+var grpcManagementServiceDesc = grpc.ServiceDesc{
+	ServiceName: GRPC_MANAGEMENT_SERVICE,
+	HandlerType: (*grpcManagementServer)(nil),
+	Methods: []grpc.MethodDesc{
+		grpcUnaryMethod("ListPlugins", func(r *grpcListRequest) grpcRoute {
+			return grpcRoute{
+				method: http.MethodGet,
+				path:   tenantPath(r.TenantID, "/management/list"),
+				query:  r.query(),
+			}
+		}),
+		grpcUnaryMethod("InstallPlugins", func(r *grpcInstallPluginsRequest) grpcRoute {
+			return grpcRoute{
+				method: http.MethodPost,
+				path:   tenantPath(r.TenantID, "/management/install/identifiers"),
+				body:   r,
+			}
+		}),
+		grpcUnaryMethod("UninstallPlugin", func(r *grpcUninstallPluginRequest) grpcRoute {
+			return grpcRoute{
+				method: http.MethodPost,
+				path:   tenantPath(r.TenantID, "/management/uninstall"),
+				body:   r,
+			}
+		}),
+		grpcUnaryMethod("ListEndpoints", func(r *grpcListRequest) grpcRoute {
+			return grpcRoute{
+				method: http.MethodGet,
+				path:   tenantPath(r.TenantID, "/endpoint/list"),
+				query:  r.query(),
+			}
+		}),
+		grpcUnaryMethod("EnableEndpoint", func(r *grpcEndpointRequest) grpcRoute {
+			return grpcRoute{
+				method: http.MethodPost,
+				path:   tenantPath(r.TenantID, "/endpoint/enable"),
+				body:   r,
+			}
+		}),
+		grpcUnaryMethod("DisableEndpoint", func(r *grpcEndpointRequest) grpcRoute {
+			return grpcRoute{
+				method: http.MethodPost,
+				path:   tenantPath(r.TenantID, "/endpoint/disable"),
+				body:   r,
+			}
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "InvokeTool",
+			ServerStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				request := &grpcInvokeToolRequest{}
+				if err := stream.RecvMsg(request); err != nil {
+					return err
+				}
+				return srv.(grpcManagementServer).stream(stream, grpcRoute{
+					method: http.MethodPost,
+					path:   tenantPath(request.TenantId, "/dispatch/tool/invoke"),
+					body:   request,
+					header: http.Header{constants.X_PLUGIN_ID: {request.PluginID}},
+				})
+			},
+		},
+	},
+}
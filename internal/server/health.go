@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+// /healthz tells whether the process is alive and should be restarted if not, it checks nothing else so
+// that an outage of a dependency doesn't restart every node at once.
+// /readyz tells whether the node should take requests, nodes not ready are removed from the service until
+// their dependencies recover, a draining node reports not ready so that rollouts wait for it.
+
+const (
+	readinessCheckTimeout = 3 * time.Second
+)
+
+type readinessCheck func(ctx context.Context) error
+
+type readinessResult struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+	// Latency is in milliseconds
+	Latency int64 `json:"latency"`
+}
+
+// runReadinessChecks runs checks concurrently, results are sorted by their names
+func runReadinessChecks(ctx context.Context, checks map[string]readinessCheck) ([]readinessResult, bool) {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	results := make([]readinessResult, 0, len(checks))
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check readinessCheck) {
+			defer wg.Done()
+
+			startedAt := time.Now()
+			err := check(ctx)
+			result := readinessResult{
+				Name:    name,
+				Ready:   err == nil,
+				Latency: time.Since(startedAt).Milliseconds(),
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			lock.Lock()
+			results = append(results, result)
+			lock.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	ready := true
+	for _, result := range results {
+		ready = ready && result.Ready
+	}
+	return results, ready
+}
+
+func checkMarketplace(url string) readinessCheck {
+	return func(ctx context.Context) error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode >= 500 {
+			return fmt.Errorf("marketplace responded with status %d", response.StatusCode)
+		}
+		return nil
+	}
+}
+
+func (app *App) readinessChecks(config *app.Config) map[string]readinessCheck {
+	checks := map[string]readinessCheck{
+		"db":    db.Ping,
+		"redis": cache.Ping,
+		"plugins": func(ctx context.Context) error {
+			manager := plugin_manager.Manager()
+			if manager == nil {
+				return errors.New("plugin manager is not initialized")
+			}
+			if !manager.LocalPluginsLaunched() {
+				return errors.New("installed plugins are still launching")
+			}
+			return nil
+		},
+		"cluster": func(ctx context.Context) error {
+			if app.cluster == nil {
+				return errors.New("cluster is not initialized")
+			}
+			if app.cluster.IsFenced() {
+				return errors.New("node is partitioned from the cluster")
+			}
+			if app.cluster.IsDraining() {
+				return errors.New("node is draining")
+			}
+			return nil
+		},
+	}
+	if config.MarketplaceURL != "" {
+		checks["marketplace"] = checkMarketplace(config.MarketplaceURL)
+	}
+	return checks
+}
+
+// Liveness reports the process is alive
+func (app *App) Liveness(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// Readiness reports whether the node is ready to take requests along with each check
+func (app *App) Readiness(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		results, ready := runReadinessChecks(c.Request.Context(), app.readinessChecks(config))
+		if !ready {
+			c.JSON(503, gin.H{"status": "not_ready", "checks": results})
+			return
+		}
+
+		c.JSON(200, gin.H{"status": "ready", "checks": results})
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunReadinessChecks(t *testing.T) {
+	results, ready := runReadinessChecks(context.Background(), map[string]readinessCheck{
+		"redis": func(ctx context.Context) error { return nil },
+		"db":    func(ctx context.Context) error { return errors.New("connection refused") },
+	})
+
+	if ready {
+		t.Fatal("node should not be ready once a check fails")
+	}
+	if len(results) != 2 || results[0].Name != "db" || results[1].Name != "redis" {
+		t.Fatalf("results should be sorted by names, got %+v", results)
+	}
+	if results[0].Ready || results[0].Error != "connection refused" || !results[1].Ready {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestReadinessChecksTimeout(t *testing.T) {
+	startedAt := time.Now()
+	results, ready := runReadinessChecks(context.Background(), map[string]readinessCheck{
+		"marketplace": func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	if ready || results[0].Ready {
+		t.Fatal("checks exceeding the timeout should fail")
+	}
+	if time.Since(startedAt) > readinessCheckTimeout+time.Second {
+		t.Fatal("checks should be canceled once the timeout is reached")
+	}
+}
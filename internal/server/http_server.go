@@ -3,18 +3,29 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/feature_flag"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/compression"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
 	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/versioning"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	sentrygin "github.com/getsentry/sentry-go/gin"
 )
@@ -76,7 +87,7 @@ func requestResponseLogger() gin.HandlerFunc {
 		}
 
 		// 记录日志
-		log.Info(logContent)
+		log.FromContext(c.Request.Context()).Info(logContent)
 	}
 }
 
@@ -91,37 +102,94 @@ func (w *responseWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+// jsonAccessLogFormatter writes access logs in the json format of log.FORMAT_JSON
+func jsonAccessLogFormatter(param gin.LogFormatterParams) string {
+	requestId, _ := param.Keys[constants.CONTEXT_KEY_REQUEST_ID].(string)
+	return parser.MarshalJson(map[string]any{
+		"time":               param.TimeStamp.Format(time.RFC3339Nano),
+		"level":              "INFO",
+		"msg":                "access",
+		log.FIELD_REQUEST_ID: requestId,
+		"client_ip":          param.ClientIP,
+		"method":             param.Method,
+		"path":               param.Path,
+		"proto":              param.Request.Proto,
+		"status":             param.StatusCode,
+		"latency":            param.Latency.Seconds(),
+		"user_agent":         param.Request.UserAgent(),
+		"error":              param.ErrorMessage,
+	}) + "\n"
+}
+
 // server starts a http server and returns a function to stop it
 func (app *App) server(config *app.Config) func() {
+	app.watchKeys(config)
+
 	engine := gin.New()
-	if *config.HealthApiLogEnabled {
-		engine.Use(gin.Logger())
+	configureClientIP(engine, config)
+	// assign the request id before any log of the request
+	engine.Use(RequestID())
+	if config.LogFormat == log.FORMAT_JSON {
+		// a single access log in json is written instead of the text ones below
+		accessLog := gin.LoggerConfig{Formatter: jsonAccessLogFormatter}
+		if !*config.HealthApiLogEnabled {
+			accessLog.SkipPaths = []string{"/health/check", "/healthz", "/readyz"}
+		}
+		engine.Use(gin.LoggerWithConfig(accessLog))
+		engine.Use(gin.Recovery())
 	} else {
-		engine.Use(gin.LoggerWithConfig(gin.LoggerConfig{
-			SkipPaths: []string{"/health/check"},
+		if *config.HealthApiLogEnabled {
+			engine.Use(gin.Logger())
+		} else {
+			engine.Use(gin.LoggerWithConfig(gin.LoggerConfig{
+				SkipPaths: []string{"/health/check", "/healthz", "/readyz"},
+			}))
+		}
+		engine.Use(gin.Recovery())
+		engine.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+			return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\" %s\n",
+				param.ClientIP,
+				param.TimeStamp.Format(time.RFC1123),
+				param.Method,
+				param.Path,
+				param.Request.Proto,
+				param.StatusCode,
+				param.Latency,
+				param.Request.UserAgent(),
+				param.ErrorMessage,
+				param.Keys[constants.CONTEXT_KEY_REQUEST_ID],
+			)
 		}))
 	}
-	engine.Use(gin.Recovery())
-	engine.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
-			param.ClientIP,
-			param.TimeStamp.Format(time.RFC1123),
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.Request.UserAgent(),
-			param.ErrorMessage,
-		)
-	}))
 	engine.Use(requestResponseLogger())
+	if config.RequestLogEnabled {
+		sampler, err := newRequestSampler(*config.RequestLogSampleRate, config.RequestLogRouteSampleRates)
+		if err != nil {
+			log.Panic("request log: %s\n", err)
+		}
+		engine.Use(sampledRequestLogger(sampler))
+	}
+	if config.MetricsEnabled {
+		engine.Use(requestMetrics())
+		engine.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+	if config.TracingEnabled {
+		engine.Use(requestTracing())
+	}
 	engine.GET("/health/check", controllers.HealthCheck(config))
+	engine.GET("/healthz", app.Liveness)
+	engine.GET("/readyz", app.Readiness(config))
 
 	endpointGroup := engine.Group("/e")
 	awsLambdaTransactionGroup := engine.Group("/backwards-invocation")
 	pluginGroup := engine.Group("/plugin/:tenant_id")
 	pprofGroup := engine.Group("/debug/pprof")
+	clusterGroup := engine.Group("/cluster")
+	sloGroup := engine.Group("/slo")
+	adminGroup := engine.Group("/admin")
+	toolGroup := engine.Group("/tools/:tenant_id")
+	mcpGroup := engine.Group("/mcp/:tenant_id")
+	openaiGroup := engine.Group("/openai/:tenant_id")
 
 	if config.SentryEnabled {
 		// setup sentry for all groups
@@ -129,6 +197,9 @@ func (app *App) server(config *app.Config) func() {
 			endpointGroup,
 			awsLambdaTransactionGroup,
 			pluginGroup,
+			toolGroup,
+			mcpGroup,
+			openaiGroup,
 		}
 		for _, group := range sentryGroup {
 			group.Use(sentrygin.New(sentrygin.Options{
@@ -141,19 +212,69 @@ func (app *App) server(config *app.Config) func() {
 	app.awsLambdaTransactionGroup(awsLambdaTransactionGroup, config)
 	app.pluginGroup(pluginGroup, config)
 	app.pprofGroup(pprofGroup, config)
+	app.clusterGroup(clusterGroup, config)
+	app.sloGroup(sloGroup, config)
+	app.adminGroup(adminGroup, config)
+	app.signedAssetGroup(engine.Group("/assets"), config)
+	app.toolInvocationGroup(toolGroup, config)
+	app.mcpGroup(mcpGroup, config, engine)
+	app.openaiGroup(openaiGroup, config, engine)
+
+	if config.OpenAPIEnabled != nil && *config.OpenAPIEnabled {
+		engine.GET("/openapi.json", OpenAPI(engine))
+	}
+
+	stopGRPC := func() {}
+	if config.GRPCServerEnabled {
+		stopGRPC = app.grpcServer(config, engine)
+	}
+
+	deprecations, err := versioning.ParseDeprecations(config.APIDeprecatedVersions)
+	if err != nil {
+		log.Panic("api versions: %s\n", err)
+	}
+
+	var handler http.Handler = versioning.NewRouter(engine, deprecations)
+	// compressed outside of the shims so that they transform plain envelopes
+	if config.ServerCompressionEnabled != nil && *config.ServerCompressionEnabled {
+		handler = compression.NewHandler(handler, config.ServerCompressionMinSize)
+	}
+
+	http2Enabled := config.ServerHTTP2Enabled != nil && *config.ServerHTTP2Enabled
+	if http2Enabled && app.tls == nil {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", config.ServerPort),
-		Handler: engine,
+		Handler: handler,
+	}
+
+	// listen before returning, requests are accepted as soon as the server is set up
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Panic("listen: %s\n", err)
+	}
+	if app.tls != nil {
+		tlsConfig := app.tls.ServerConfig(tlsMinVersion(config))
+		if http2Enabled {
+			// the listener is served by Serve rather than ServeTLS, which doesn't set http/2 up
+			if err := http2.ConfigureServer(srv, &http2.Server{}); err != nil {
+				log.Panic("http2: %s\n", err)
+			}
+			tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+		}
+		listener = tls.NewListener(listener, tlsConfig)
 	}
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Panic("listen: %s\n", err)
 		}
 	}()
 
 	return func() {
+		stopGRPC()
 		if err := srv.Shutdown(context.Background()); err != nil {
 			log.Panic("Server Shutdown: %s\n", err)
 		}
@@ -161,13 +282,14 @@ func (app *App) server(config *app.Config) func() {
 }
 
 func (app *App) pluginGroup(group *gin.RouterGroup, config *app.Config) {
-	group.Use(CheckingKey(config.ServerKey))
+	group.Use(Authorizing(app.serverKey))
+	group.Use(app.verifyingTenantToken(config))
 
 	app.remoteDebuggingGroup(group.Group("/debugging"), config)
 	app.pluginDispatchGroup(group.Group("/dispatch"), config)
 	app.pluginManagementGroup(group.Group("/management"), config)
-	app.endpointManagementGroup(group.Group("/endpoint"))
-	app.pluginAssetGroup(group.Group("/asset"))
+	app.endpointManagementGroup(group.Group("/endpoint"), config)
+	app.pluginAssetGroup(group.Group("/asset"), config)
 }
 
 func (app *App) pluginDispatchGroup(group *gin.RouterGroup, config *app.Config) {
@@ -176,9 +298,15 @@ func (app *App) pluginDispatchGroup(group *gin.RouterGroup, config *app.Config)
 	group.Use(app.InitClusterID())
 
 	group.POST("/tool/invoke", controllers.InvokeTool(config))
+	group.POST("/tool/invoke/typed", RequiringFeature(feature_flag.TOOL_STREAM_TYPED), controllers.InvokeToolTyped(config))
 	group.POST("/tool/validate_credentials", controllers.ValidateToolCredentials(config))
 	group.POST("/tool/get_runtime_parameters", controllers.GetToolRuntimeParameters(config))
 	group.POST("/agent_strategy/invoke", controllers.InvokeAgentStrategy(config))
+	group.POST(
+		"/agent_strategy/invoke/events",
+		RequiringFeature(feature_flag.AGENT_STRATEGY_EVENTS),
+		controllers.InvokeAgentStrategyEvents(config),
+	)
 	group.POST("/llm/invoke", controllers.InvokeLLM(config))
 	group.POST("/llm/num_tokens", controllers.GetLLMNumTokens(config))
 	group.POST("/text_embedding/invoke", controllers.InvokeTextEmbedding(config))
@@ -195,7 +323,7 @@ func (app *App) pluginDispatchGroup(group *gin.RouterGroup, config *app.Config)
 
 func (app *App) remoteDebuggingGroup(group *gin.RouterGroup, config *app.Config) {
 	if config.PluginRemoteInstallingEnabled != nil && *config.PluginRemoteInstallingEnabled {
-		group.POST("/key", CheckingKey(config.ServerKey), controllers.GetRemoteDebuggingKey)
+		group.POST("/key", controllers.GetRemoteDebuggingKey)
 	}
 }
 
@@ -222,29 +350,59 @@ func (appRef *App) awsLambdaTransactionGroup(group *gin.RouterGroup, config *app
 	}
 }
 
-func (app *App) endpointManagementGroup(group *gin.RouterGroup) {
-	group.POST("/setup", controllers.SetupEndpoint)
+func (app *App) endpointManagementGroup(group *gin.RouterGroup, config *app.Config) {
+	idempotent := Idempotent(time.Duration(config.IdempotencyKeyWindow) * time.Second)
+
+	group.POST("/setup", idempotent, controllers.SetupEndpoint)
 	group.POST("/remove", controllers.RemoveEndpoint)
-	group.POST("/update", controllers.UpdateEndpoint)
+	group.POST("/update", idempotent, controllers.UpdateEndpoint)
 	group.GET("/list", controllers.ListEndpoints)
 	group.GET("/list/plugin", controllers.ListPluginEndpoints)
 	group.POST("/enable", controllers.EnableEndpoint)
 	group.POST("/disable", controllers.DisableEndpoint)
+
+	group.GET("/templates", controllers.ListEndpointTemplates)
+	group.POST("/templates/create", controllers.CreateEndpointTemplate)
+	group.POST("/templates/update", controllers.UpdateEndpointTemplate)
+	group.POST("/templates/delete", controllers.DeleteEndpointTemplate)
+	group.POST("/templates/materialize", idempotent, controllers.MaterializeEndpointTemplate)
+	group.GET("/templates/instances", controllers.ListEndpointTemplateInstances)
+	group.POST("/templates/instances/manage", controllers.ManageEndpointTemplateInstances)
 }
 
 func (app *App) pluginManagementGroup(group *gin.RouterGroup, config *app.Config) {
+	idempotent := Idempotent(time.Duration(config.IdempotencyKeyWindow) * time.Second)
+
 	group.POST("/install/upload/package", controllers.UploadPlugin(config))
 	group.POST("/install/upload/bundle", controllers.UploadBundle(config))
-	group.POST("/install/identifiers", controllers.InstallPluginFromIdentifiers(config))
-	group.POST("/install/upgrade", controllers.UpgradePlugin(config))
+	group.POST("/install/identifiers", idempotent, controllers.InstallPluginFromIdentifiers(config))
+	group.POST("/install/batch", idempotent, controllers.BatchInstallPlugins(config))
+	group.POST("/pack", controllers.PackPlugin(config))
+	group.GET("/pack/download", controllers.DownloadPluginPackage)
+	group.POST("/import/openapi", controllers.ImportOpenAPISpec(config))
+	group.POST("/install/upgrade", idempotent, controllers.UpgradePlugin(config))
 	group.GET("/install/tasks/:id", controllers.FetchPluginInstallationTask)
+	group.GET("/install/tasks/:id/watch", controllers.WatchPluginInstallationTask(config))
 	group.POST("/install/tasks/delete_all", controllers.DeleteAllPluginInstallationTasks)
 	group.POST("/install/tasks/:id/delete", controllers.DeletePluginInstallationTask)
 	group.POST("/install/tasks/:id/delete/*identifier", controllers.DeletePluginInstallationItemFromTask)
 	group.GET("/install/tasks", controllers.FetchPluginInstallationTasks)
 	group.GET("/fetch/manifest", controllers.FetchPluginManifest)
+	group.GET("/fetch/declaration/diff", controllers.DiffPluginDeclarations)
+	group.GET("/fetch/declaration/validate", controllers.ValidatePluginDeclaration)
 	group.GET("/fetch/identifier", controllers.FetchPluginFromIdentifier)
-	group.POST("/uninstall", controllers.UninstallPlugin)
+	group.POST("/uninstall", idempotent, controllers.UninstallPlugin)
+	group.POST("/uninstall/batch", idempotent, controllers.BatchUninstallPlugins(config))
+	group.POST("/repair", controllers.RepairPlugin)
+	group.GET("/usage/daily", controllers.ListPluginDailyUsage(config))
+	group.GET("/credential_pools", controllers.ListCredentialPools)
+	group.POST("/credential_pools/create", controllers.CreateCredentialPool)
+	group.POST("/credential_pools/delete", controllers.DeleteCredentialPool)
+	group.POST("/credential_pools/keys/add", controllers.AddCredentialPoolKey)
+	group.POST("/credential_pools/keys/update", controllers.UpdateCredentialPoolKey)
+	group.POST("/credential_pools/keys/remove", controllers.RemoveCredentialPoolKey)
+	group.POST("/credential_pools/keys/reset_cooldown", controllers.ResetCredentialPoolKeyCooldown)
+	group.POST("/model_cache/purge", controllers.PurgeModelCache)
 	group.GET("/list", controllers.ListPlugins)
 	group.POST("/installation/fetch/batch", controllers.BatchFetchPluginInstallationByIDs)
 	group.POST("/installation/missing", controllers.FetchMissingPluginInstallations)
@@ -254,15 +412,158 @@ func (app *App) pluginManagementGroup(group *gin.RouterGroup, config *app.Config
 	group.POST("/tools/check_existence", controllers.CheckToolExistence)
 	group.GET("/agent_strategies", controllers.ListAgentStrategies)
 	group.GET("/agent_strategy", controllers.GetAgentStrategy)
+	group.GET("/policy", controllers.GetPluginPolicy)
+	group.POST("/policy/check", controllers.CheckPluginPolicy)
+	group.GET("/guardrails", controllers.GetGuardrailPolicy)
+	group.POST("/guardrails/update", controllers.UpdateGuardrailPolicy)
+	group.GET("/guardrails/events", controllers.ListGuardrailEvents)
+	group.GET("/storage/usage", controllers.GetTenantStorageUsage(config))
+	group.GET("/webhooks", controllers.ListWebhooks)
+	group.POST("/webhooks/create", controllers.CreateWebhook)
+	group.POST("/webhooks/update", controllers.UpdateWebhook)
+	group.POST("/webhooks/delete", controllers.DeleteWebhook)
+	group.POST("/dev/start", controllers.StartDevPlugin(config))
+	group.POST("/dev/stop", controllers.StopDevPlugin(config))
+	group.GET("/dev/list", controllers.ListDevPlugins(config))
 }
 
-func (app *App) pluginAssetGroup(group *gin.RouterGroup) {
+func (app *App) pluginAssetGroup(group *gin.RouterGroup, config *app.Config) {
 	group.GET("/:id", controllers.GetAsset)
+	if config.AssetSigningSecret != "" {
+		group.POST("/sign", controllers.SignAssetURLs(app.assetSigningSecret, config))
+	}
+}
+
+// signedAssetGroup serves assets and packages of signed urls, the signature authenticates the request
+func (app *App) signedAssetGroup(group *gin.RouterGroup, config *app.Config) {
+	if config.AssetSigningSecret != "" {
+		group.GET("/package", controllers.GetSignedPluginPackage(app.assetSigningSecret))
+		group.GET("/:id", controllers.GetSignedAsset(app.assetSigningSecret))
+	}
+}
+
+// configureClientIP trusts the configured proxies only, gin trusts all of them by default,
+// which lets any client forge its ip by X-Forwarded-For
+func configureClientIP(engine *gin.Engine, config *app.Config) {
+	proxies, err := parseTrustedProxies(config.ServerTrustedProxies)
+	if err != nil {
+		log.Panic("trusted proxies: %s\n", err)
+	}
+	if err := engine.SetTrustedProxies(proxies); err != nil {
+		log.Panic("trusted proxies: %s\n", err)
+	}
+
+	headers := []string{}
+	for _, header := range strings.Split(config.ServerClientIPHeaders, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	engine.RemoteIPHeaders = headers
+}
+
+func adminCORSPolicy(config *app.Config) corsPolicy {
+	return newCORSPolicy(config.AdminCORSAllowedOrigins, config.AdminCORSAllowedHeaders, config.AdminCORSMaxAge)
+}
+
+func (app *App) verifyingTenantToken(config *app.Config) gin.HandlerFunc {
+	return VerifyingTenantToken(
+		app.tenantTokenSecret,
+		config.TenantTokenRequired,
+		time.Duration(config.TenantTokenMaxTTL)*time.Second,
+	)
+}
+
+func (app *App) clusterGroup(group *gin.RouterGroup, config *app.Config) {
+	allowCORS(group, adminCORSPolicy(config))
+	group.Use(AuthorizingOperator(app.serverKey))
+
+	group.GET("/nodes", app.ListClusterNodes)
+	group.POST("/nodes/:id/drain", app.DrainClusterNode)
+	group.GET("/jobs", app.ListSingletonJobs)
+	group.GET("/reconciliation", app.ListPluginReconciliations)
+	group.GET("/log_levels", app.ListLogLevels)
+	group.POST("/log_levels/update", app.UpdateLogLevel)
+}
+
+func (app *App) sloGroup(group *gin.RouterGroup, config *app.Config) {
+	allowCORS(group, adminCORSPolicy(config))
+	group.Use(AuthorizingOperator(app.serverKey))
+
+	group.GET("/reports", controllers.ListPluginSLOReports)
+	group.GET("/objectives", controllers.ListPluginSLOs)
+	group.POST("/objectives/update", controllers.UpdatePluginSLO)
+	group.POST("/objectives/delete", controllers.DeletePluginSLO)
+}
+
+func (app *App) adminGroup(group *gin.RouterGroup, config *app.Config) {
+	allowCORS(group, adminCORSPolicy(config))
+	group.Use(AuthorizingOperator(app.serverKey))
+
+	group.GET("/overview", app.AdminOverview(config))
+	group.GET("/support-bundle", app.SupportBundle(config))
+	group.GET("/whoami", controllers.WhoAmI)
+	group.GET("/encryption/keys", controllers.ListEncryptionKeys)
+	group.POST("/encryption/reencrypt", controllers.ReencryptSecrets)
+	group.GET("/credential_access", controllers.ListCredentialAccessLogs)
+	group.GET("/sessions", controllers.ListSessions)
+	group.POST("/sessions/kill", controllers.KillSession)
+	group.GET("/tokens", controllers.ListAPITokens)
+	group.POST("/tokens/create", controllers.CreateAPIToken)
+	group.POST("/tokens/revoke", controllers.RevokeAPIToken)
+	group.POST("/state/plan", controllers.PlanDeclaredState)
+	group.POST("/state/apply", controllers.ApplyDeclaredState(config))
+	group.GET("/faults", controllers.ListFaults)
+	group.POST("/faults/set", controllers.SetFaults)
+	group.POST("/faults/clear", controllers.ClearFaults)
+	group.GET("/feature_flags", controllers.ListFeatureFlags)
+	group.POST("/feature_flags/update", controllers.UpdateFeatureFlag)
+	group.POST("/feature_flags/delete", controllers.DeleteFeatureFlag)
+	group.GET("/feature_flags/evaluate", controllers.EvaluateFeatureFlags)
+	group.GET("/config/reload", controllers.GetConfigReloadStatus)
+	group.POST("/gc", controllers.CollectPluginGarbage(config))
+	group.GET("/policy", controllers.GetGlobalPluginPolicy)
+	group.POST("/policy/update", controllers.UpdateGlobalPluginPolicy)
+	group.POST("/policy/delete", controllers.DeleteGlobalPluginPolicy)
+	group.POST("/policy/tenant/update", controllers.UpdatePluginPolicy)
+	group.POST("/policy/tenant/delete", controllers.DeletePluginPolicy)
+	group.GET("/webhooks", controllers.ListGlobalWebhooks)
+	group.POST("/webhooks/create", controllers.CreateGlobalWebhook)
+	group.POST("/webhooks/update", controllers.UpdateGlobalWebhook)
+	group.POST("/webhooks/delete", controllers.DeleteGlobalWebhook)
+	group.GET("/serverless/prewarm/stats", controllers.GetServerlessPrewarmStats(config))
+	group.GET("/serverless/transport/stats", controllers.GetServerlessTransportStats(config))
+	group.GET("/serverless/failover/status", controllers.GetServerlessFailoverStatus(config))
+	group.GET("/serverless/regions/status", controllers.GetServerlessRegionStatus(config))
+	group.GET("/serverless/versions", controllers.ListServerlessVersions(config))
+	group.POST("/serverless/rollback", controllers.RollbackServerlessVersion(config))
+	group.POST("/serverless/rollback/cancel", controllers.CancelServerlessRollback(config))
+	group.GET("/serverless/resources", controllers.GetServerlessResources(config))
+	group.POST("/serverless/resources/update", controllers.UpdateServerlessResources(config))
+	group.POST("/serverless/resources/delete", controllers.DeleteServerlessResources(config))
+	group.GET("/serverless/telemetry/costs", controllers.ListServerlessPluginCosts(config))
+	group.GET("/serverless/telemetry/invocations", controllers.ListServerlessInvocationStats(config))
+	group.POST("/storage/quota/update", controllers.UpdateTenantStorageQuota)
+	group.POST("/storage/quota/delete", controllers.DeleteTenantStorageQuota)
+	group.POST("/config/reload", controllers.ReloadConfig)
+}
+
+func (app *App) toolInvocationGroup(group *gin.RouterGroup, config *app.Config) {
+	if config.ToolInvocationAPIEnabled {
+		group.Use(Authorizing(app.serverKey))
+		group.Use(app.verifyingTenantToken(config))
+		group.Use(PluginIDFromBody())
+		group.Use(app.FetchPluginInstallation())
+		group.Use(app.RedirectPluginInvoke())
+		group.Use(app.InitClusterID())
+
+		group.POST("/invoke", controllers.InvokeToolDirectly(config))
+	}
 }
 
 func (app *App) pprofGroup(group *gin.RouterGroup, config *app.Config) {
 	if config.PPROFEnabled {
-		group.Use(CheckingKey(config.ServerKey))
+		group.Use(AuthorizingOperator(app.serverKey))
 
 		group.GET("/", controllers.PprofIndex)
 		group.GET("/cmdline", controllers.PprofCmdline)
@@ -277,3 +578,39 @@ func (app *App) pprofGroup(group *gin.RouterGroup, config *app.Config) {
 		group.GET("/threadcreate", controllers.PprofThreadcreate)
 	}
 }
+
+// adminServer starts a http server for diagnostics on the admin port, apart from the public one
+func (app *App) adminServer(config *app.Config) {
+	engine := gin.New()
+	configureClientIP(engine, config)
+	engine.Use(gin.Recovery())
+
+	group := engine.Group("/debug")
+	group.Use(CheckingKey(app.adminKey))
+	group.GET("/goroutines", controllers.DumpGoroutines)
+	group.GET("/slow_invocations", controllers.ListSlowInvocations)
+
+	pprofGroup := group.Group("/pprof")
+	pprofGroup.GET("/", controllers.PprofIndex)
+	pprofGroup.GET("/cmdline", controllers.PprofCmdline)
+	pprofGroup.GET("/profile", controllers.PprofProfile)
+	pprofGroup.GET("/symbol", controllers.PprofSymbol)
+	pprofGroup.GET("/trace", controllers.PprofTrace)
+	pprofGroup.GET("/goroutine", controllers.PprofGoroutine)
+	pprofGroup.GET("/heap", controllers.PprofHeap)
+	pprofGroup.GET("/allocs", controllers.PprofAllocs)
+	pprofGroup.GET("/block", controllers.PprofBlock)
+	pprofGroup.GET("/mutex", controllers.PprofMutex)
+	pprofGroup.GET("/threadcreate", controllers.PprofThreadcreate)
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", config.AdminPort),
+		Handler: engine,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Panic("admin listen: %s\n", err)
+		}
+	}()
+}
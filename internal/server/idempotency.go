@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	IDEMPOTENCY_KEY_PREFIX = "idempotency"
+
+	maxIdempotencyKeyLength = 255
+	// a request being served keeps its key for at most the timeout, so that retries are not
+	// rejected for the whole window once the node serving it is gone
+	idempotencyPendingTimeout = 5 * time.Minute
+)
+
+// idempotentResult is the result of a request served with an idempotency key, Done is false while it's being served
+type idempotentResult struct {
+	Fingerprint string `json:"fingerprint"`
+	Done        bool   `json:"done"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// idempotencyStore keeps results shared by all the nodes of the cluster
+type idempotencyStore interface {
+	// reserve stores the result if the key is not taken, returns false otherwise
+	reserve(key string, result idempotentResult, expire time.Duration) (bool, error)
+	fetch(key string) (*idempotentResult, error)
+	save(key string, result idempotentResult, expire time.Duration) error
+	release(key string) error
+}
+
+type redisIdempotencyStore struct{}
+
+func (redisIdempotencyStore) reserve(key string, result idempotentResult, expire time.Duration) (bool, error) {
+	return cache.SetNX(key, result, expire)
+}
+
+func (redisIdempotencyStore) fetch(key string) (*idempotentResult, error) {
+	return cache.Get[idempotentResult](key)
+}
+
+func (redisIdempotencyStore) save(key string, result idempotentResult, expire time.Duration) error {
+	return cache.Store(key, result, expire)
+}
+
+func (redisIdempotencyStore) release(key string) error {
+	return cache.Del(key)
+}
+
+// Idempotent serves a request carrying an Idempotency-Key once within the window, retries with the key
+// are replied by the result of the first request, requests failed by internal errors are not kept so that
+// they're retried, reusing the key for a different request of the tenant is rejected
+func Idempotent(window time.Duration) gin.HandlerFunc {
+	return idempotent(redisIdempotencyStore{}, window)
+}
+
+func idempotent(store idempotencyStore, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(constants.IDEMPOTENCY_KEY)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(400, exception.BadRequestError(errors.New("idempotency key is too long")).ToResponse())
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(400, exception.BadRequestError(err).ToResponse())
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := sha256.Sum256(body)
+		result := idempotentResult{Fingerprint: hex.EncodeToString(fingerprint[:])}
+		key := idempotencyCacheKey(c.Param("tenant_id"), c.FullPath(), idempotencyKey)
+
+		reserved, err := store.reserve(key, result, min(window, idempotencyPendingTimeout))
+		if err != nil {
+			c.AbortWithStatusJSON(500, exception.InternalServerError(err).ToResponse())
+			return
+		}
+		if !reserved {
+			replayIdempotentResult(c, store, key, result.Fingerprint)
+			return
+		}
+
+		writer := &responseWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+		c.Next()
+
+		if failedInternally(writer.Status(), writer.body.Bytes()) {
+			if err := store.release(key); err != nil {
+				log.Warn("failed to release idempotency key of %s: %s", c.FullPath(), err.Error())
+			}
+			return
+		}
+
+		result.Done = true
+		result.Status = writer.Status()
+		result.ContentType = writer.Header().Get("Content-Type")
+		result.Body = writer.body.Bytes()
+		if err := store.save(key, result, window); err != nil {
+			log.Warn("failed to save the result of idempotency key of %s: %s", c.FullPath(), err.Error())
+		}
+	}
+}
+
+func replayIdempotentResult(c *gin.Context, store idempotencyStore, key string, fingerprint string) {
+	result, err := store.fetch(key)
+	if err == cache.ErrNotFound {
+		// the first request failed or timed out in between, the caller may retry right away
+		c.AbortWithStatusJSON(409, exception.ConflictError("request with the idempotency key is being served").ToResponse())
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(500, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	if result.Fingerprint != fingerprint {
+		c.AbortWithStatusJSON(422, exception.BadRequestError(
+			errors.New("idempotency key is already used by a different request"),
+		).ToResponse())
+		return
+	}
+	if !result.Done {
+		c.AbortWithStatusJSON(409, exception.ConflictError("request with the idempotency key is being served").ToResponse())
+		return
+	}
+
+	c.Header(constants.IDEMPOTENT_REPLAYED, "true")
+	c.Data(result.Status, result.ContentType, result.Body)
+	c.Abort()
+}
+
+func idempotencyCacheKey(tenantId string, route string, idempotencyKey string) string {
+	hash := sha256.Sum256([]byte(idempotencyKey))
+	return IDEMPOTENCY_KEY_PREFIX + ":" + tenantId + ":" + route + ":" + hex.EncodeToString(hash[:])
+}
+
+// failedInternally returns true if the request failed by a server side error, either by the status
+// or by the code of the envelope, which is replied with 200 by most of the management apis
+func failedInternally(status int, body []byte) bool {
+	if status >= 500 {
+		return true
+	}
+
+	var envelope struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return false
+	}
+	return envelope.Code <= -500
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	results map[string]idempotentResult
+}
+
+func (s *memoryIdempotencyStore) reserve(key string, result idempotentResult, expire time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.results[key]; ok {
+		return false, nil
+	}
+	s.results[key] = result
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) fetch(key string) (*idempotentResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[key]
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+	return &result, nil
+}
+
+func (s *memoryIdempotencyStore) save(key string, result idempotentResult, expire time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[key] = result
+	return nil
+}
+
+func (s *memoryIdempotencyStore) release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.results, key)
+	return nil
+}
+
+func TestIdempotentReplaysResults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{results: map[string]idempotentResult{}}
+	installs := 0
+	failing := false
+
+	engine := gin.New()
+	engine.POST("/plugin/:tenant_id/management/install", idempotent(store, time.Hour), func(c *gin.Context) {
+		if failing {
+			c.JSON(200, exception.InternalServerError(http.ErrHandlerTimeout).ToResponse())
+			return
+		}
+		installs++
+		c.JSON(200, entities.NewSuccessResponse(installs))
+	})
+
+	install := func(tenantId string, key string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/plugin/"+tenantId+"/management/install", strings.NewReader(body))
+		if key != "" {
+			request.Header.Set(constants.IDEMPOTENCY_KEY, key)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	first := install("tenant", "key", `{"a":1}`)
+	retry := install("tenant", "key", `{"a":1}`)
+	if installs != 1 || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry should be replayed, installs %d, body %s", installs, retry.Body.String())
+	}
+	if retry.Header().Get(constants.IDEMPOTENT_REPLAYED) != "true" {
+		t.Fatal("replayed result should be marked")
+	}
+
+	if recorder := install("tenant", "key", `{"a":2}`); recorder.Code != 422 {
+		t.Fatalf("reusing the key for a different request should be rejected, got %d", recorder.Code)
+	}
+
+	// keys are scoped by tenants and requests without a key are always served
+	install("another", "key", `{"a":1}`)
+	install("tenant", "", `{"a":1}`)
+	install("tenant", "", `{"a":1}`)
+	if installs != 4 {
+		t.Fatalf("expected 4 installs, got %d", installs)
+	}
+
+	// requests failed internally are served again on retries
+	failing = true
+	install("tenant", "retried", `{}`)
+	failing = false
+	install("tenant", "retried", `{}`)
+	if installs != 5 {
+		t.Fatalf("failed request should be served again, got %d installs", installs)
+	}
+}
+
+func TestIdempotentRejectsConcurrentRetries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{results: map[string]idempotentResult{}}
+
+	engine := gin.New()
+	engine.POST("/plugin/:tenant_id/endpoint/setup", idempotent(store, time.Hour), func(c *gin.Context) {
+		c.JSON(200, entities.NewSuccessResponse(true))
+	})
+
+	key := idempotencyCacheKey("tenant", "/plugin/:tenant_id/endpoint/setup", "key")
+	fingerprint := sha256.Sum256(nil)
+	store.results[key] = idempotentResult{Fingerprint: hex.EncodeToString(fingerprint[:])}
+
+	request := httptest.NewRequest(http.MethodPost, "/plugin/tenant/endpoint/setup", strings.NewReader(""))
+	request.Header.Set(constants.IDEMPOTENCY_KEY, "key")
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	if recorder.Code != 409 {
+		t.Fatalf("retry of a request being served should be a conflict, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/mcp"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_token"
+	"github.com/langgenius/dify-plugin-daemon/internal/manifest"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+)
+
+// pluginToolsForwardedHeaders are passed from requests of callers to the routes serving tools
+var pluginToolsForwardedHeaders = []string{
+	constants.X_API_KEY, constants.X_TENANT_TOKEN, constants.X_REQUEST_ID,
+}
+
+// handlerTransport serves requests by the http handler in the same process
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
+
+	writer := newGRPCResponseWriter(ctx, nil)
+	t.handler.ServeHTTP(writer, request.WithContext(ctx))
+	writer.close()
+
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+	return &http.Response{
+		StatusCode: writer.status,
+		Header:     writer.header,
+		Body:       io.NopCloser(&writer.body),
+		Request:    request,
+	}, nil
+}
+
+// pluginTools serves tools of the tenant by requests to the routes as the caller, users are told
+// by tenant tokens, or by userID if tokens are not used
+func pluginTools(c *gin.Context, transport http.RoundTripper, userID string) *mcp.PluginTools {
+	header := http.Header{}
+	for _, key := range pluginToolsForwardedHeaders {
+		if value := c.GetHeader(key); value != "" {
+			header.Set(key, value)
+		}
+	}
+	if claims, ok := c.Get(constants.CONTEXT_KEY_TENANT_TOKEN); ok {
+		userID = claims.(*tenant_token.Claims).UserID
+	}
+
+	return mcp.NewPluginTools(mcp.PluginToolsOptions{
+		Transport: transport,
+		Header:    header,
+		TenantID:  c.Param("tenant_id"),
+		UserID:    userID,
+	})
+}
+
+// mcpGroup serves tools of installed plugins of the tenant to mcp clients over server sent events,
+// clients open a stream on /sse and post messages to the url told by the first event of the stream
+func (app *App) mcpGroup(group *gin.RouterGroup, config *app.Config, handler http.Handler) {
+	if !config.MCPServerEnabled {
+		return
+	}
+
+	group.Use(Authorizing(app.serverKey))
+	group.Use(app.verifyingTenantToken(config))
+
+	sessions := mcp.NewSSESessions()
+	transport := handlerTransport{handler: handler}
+
+	group.GET("/sse", func(c *gin.Context) {
+		tenantID := c.Param("tenant_id")
+		messageURL := "/mcp/" + url.PathEscape(tenantID) + "/message"
+		if userID := c.Query("user_id"); userID != "" {
+			messageURL += "?" + url.Values{"user_id": {userID}}.Encode()
+		}
+		sessions.Open(c.Writer, c.Request, tenantID, messageURL)
+	})
+
+	group.POST("/message", func(c *gin.Context) {
+		message, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, exception.BadRequestError(err).ToResponse())
+			return
+		}
+
+		server := mcp.NewServer(pluginTools(c, transport, c.Query("user_id")), manifest.VersionX)
+
+		err = sessions.Post(server, c.Query("session_id"), c.Param("tenant_id"), message)
+		if errors.Is(err, mcp.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, exception.NotFoundError(err).ToResponse())
+			return
+		}
+		c.Status(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+)
+
+// requestMetrics records latencies of requests by the route they matched,
+// requests matching no route are recorded together to keep the cardinality bounded
+func requestMetrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		startedAt := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HTTPRequestDuration.WithLabelValues(
+			ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status()),
+		).Observe(time.Since(startedAt).Seconds())
+	}
+}
+
+// registerMetrics registers metrics read from other subsystems on scrape
+func (app *App) registerMetrics() error {
+	sqlDB, err := db.DifyPluginDB.DB()
+	if err != nil {
+		return err
+	}
+
+	redisStats := func(read func(stats *redis.PoolStats) uint32) func() float64 {
+		return func() float64 {
+			stats := cache.PoolStats()
+			if stats == nil {
+				return 0
+			}
+			return float64(read(stats))
+		}
+	}
+
+	return metrics.Register(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.NAMESPACE,
+			Name:      "sessions_active",
+			Help:      "Sessions being served by the node",
+		}, func() float64 {
+			return float64(session_manager.Count())
+		}),
+		collectors.NewDBStatsCollector(sqlDB, "dify_plugin"),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.NAMESPACE,
+			Name:      "redis_pool_connections",
+			Help:      "Connections in the redis pool",
+		}, redisStats(func(stats *redis.PoolStats) uint32 { return stats.TotalConns })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.NAMESPACE,
+			Name:      "redis_pool_idle_connections",
+			Help:      "Idle connections in the redis pool",
+		}, redisStats(func(stats *redis.PoolStats) uint32 { return stats.IdleConns })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metrics.NAMESPACE,
+			Name:      "redis_pool_hits_total",
+			Help:      "Times a free connection was found in the redis pool",
+		}, redisStats(func(stats *redis.PoolStats) uint32 { return stats.Hits })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metrics.NAMESPACE,
+			Name:      "redis_pool_misses_total",
+			Help:      "Times a free connection was not found in the redis pool",
+		}, redisStats(func(stats *redis.PoolStats) uint32 { return stats.Misses })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metrics.NAMESPACE,
+			Name:      "redis_pool_timeouts_total",
+			Help:      "Times waiting for a connection of the redis pool timed out",
+		}, redisStats(func(stats *redis.PoolStats) uint32 { return stats.Timeouts })),
+		routinePoolCollector{},
+	)
+}
+
+var (
+	routinePoolWorkersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.NAMESPACE, "", "routine_pool_workers"),
+		"Workers of routine pools of subsystems", []string{"pool"}, nil,
+	)
+	routinePoolBusyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.NAMESPACE, "", "routine_pool_busy_workers"),
+		"Workers running tasks of routine pools of subsystems", []string{"pool"}, nil,
+	)
+	routinePoolQueueDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.NAMESPACE, "", "routine_pool_queue_depth"),
+		"Tasks waiting in queues of routine pools of subsystems", []string{"pool"}, nil,
+	)
+	routinePoolRejectedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.NAMESPACE, "", "routine_pool_rejected_total"),
+		"Tasks rejected by routine pools of subsystems once their queues are full", []string{"pool"}, nil,
+	)
+	routinePoolCallerRunsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.NAMESPACE, "", "routine_pool_caller_runs_total"),
+		"Tasks run by callers once queues of routine pools of subsystems are full", []string{"pool"}, nil,
+	)
+)
+
+// routinePoolCollector reads stats of routine pools on scrape
+type routinePoolCollector struct{}
+
+func (routinePoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- routinePoolWorkersDesc
+	ch <- routinePoolBusyDesc
+	ch <- routinePoolQueueDepthDesc
+	ch <- routinePoolRejectedDesc
+	ch <- routinePoolCallerRunsDesc
+}
+
+func (routinePoolCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range routine.FetchPoolStats() {
+		ch <- prometheus.MustNewConstMetric(routinePoolWorkersDesc, prometheus.GaugeValue, float64(stats.Workers), stats.Name)
+		ch <- prometheus.MustNewConstMetric(routinePoolBusyDesc, prometheus.GaugeValue, float64(stats.Busy), stats.Name)
+		ch <- prometheus.MustNewConstMetric(routinePoolQueueDepthDesc, prometheus.GaugeValue, float64(stats.Queued), stats.Name)
+		ch <- prometheus.MustNewConstMetric(routinePoolRejectedDesc, prometheus.CounterValue, float64(stats.Rejected), stats.Name)
+		ch <- prometheus.MustNewConstMetric(routinePoolCallerRunsDesc, prometheus.CounterValue, float64(stats.CallerRuns), stats.Name)
+	}
+}
@@ -1,10 +1,21 @@
 package server
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/admin_auth"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/api_token"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/feature_flag"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/secrets"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_token"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
@@ -13,18 +24,251 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
-func CheckingKey(key string) gin.HandlerFunc {
+const (
+	maxRequestIdLength = 128
+)
+
+// validRequestId returns true if the request id set by the caller is safe to be written to logs
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID assigns the request an id, the one set by the caller is kept so that redirected requests
+// and requests traced by dify are correlated, logs written within the request carry the id
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(constants.X_REQUEST_ID)
+		if !validRequestId(requestId) {
+			requestId = uuid.New().String()
+			c.Request.Header.Set(constants.X_REQUEST_ID, requestId)
+		}
+
+		c.Set(constants.CONTEXT_KEY_REQUEST_ID, requestId)
+		c.Writer.Header().Set(constants.X_REQUEST_ID, requestId)
+
+		logger := log.With(log.FIELD_REQUEST_ID, requestId).
+			With(log.FIELD_TENANT_ID, c.Param("tenant_id")).
+			With(log.FIELD_PLUGIN_ID, c.GetHeader(constants.X_PLUGIN_ID)).
+			With(log.FIELD_CLIENT_IP, c.ClientIP())
+		c.Request = c.Request.WithContext(log.NewContext(c.Request.Context(), logger))
+
+		c.Next()
+	}
+}
+
+// CheckingKey checks the api key against the current one, keys from secret managers may be rotated
+func CheckingKey(key *secrets.Value) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// get header X-Api-Key
-		if c.GetHeader(constants.X_API_KEY) != key {
+		if c.GetHeader(constants.X_API_KEY) != key.Get() {
+			c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+const (
+	CALLER_SERVER_KEY = "server_key"
+)
+
+// Authorizing authenticates the caller by the server key or an api token, api tokens are checked against
+// the scope required by the route and their rate limits, logs of the request are attributed to the caller
+func Authorizing(serverKey *secrets.Value) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(constants.X_API_KEY)
+
+		var caller string
+		switch {
+		case key != "" && key == serverKey.Get():
+			caller = CALLER_SERVER_KEY
+		case api_token.IsToken(key):
+			token, err := api_token.Authenticate(key)
+			if err == api_token.ErrInvalidToken {
+				c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
+				return
+			}
+			if err == api_token.ErrTooManyLookups {
+				c.AbortWithStatusJSON(429, exception.RateLimitedError().ToResponse())
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(500, exception.InternalServerError(err).ToResponse())
+				return
+			}
+
+			scope := requiredScope(c.Request.Method, c.FullPath())
+			if !api_token.Granted(token.Scopes, scope) {
+				c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+					fmt.Sprintf("api token is not granted scope %s", scope),
+				).ToResponse())
+				return
+			}
+			// tokens bound to a tenant are rejected by routes of other tenants and by the ones of no tenant
+			if token.TenantID != "" && c.Param("tenant_id") != token.TenantID {
+				c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+					"api token is bound to another tenant",
+				).ToResponse())
+				return
+			}
+			if !api_token.Allow(token.ID, token.RateLimit) {
+				c.AbortWithStatusJSON(429, exception.RateLimitedError().ToResponse())
+				return
+			}
+
+			caller = fmt.Sprintf("token:%s:%s", token.Prefix, token.Name)
+			if token.TenantID != "" {
+				c.Set(constants.CONTEXT_KEY_CALLER_TENANT_ID, token.TenantID)
+			}
+		default:
+			c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
+			return
+		}
+
+		c.Set(constants.CONTEXT_KEY_CALLER, caller)
+		logger := log.FromContext(c.Request.Context()).With(log.FIELD_CALLER, caller)
+		c.Request = c.Request.WithContext(log.NewContext(c.Request.Context(), logger))
+
+		c.Next()
+	}
+}
+
+// AuthorizingOperator authenticates operators signed in by oidc or ldap, their roles are checked against the
+// scope required by the route, callers sending the server key or api tokens are authorized by Authorizing
+func AuthorizingOperator(serverKey *secrets.Value) gin.HandlerFunc {
+	authorizing := Authorizing(serverKey)
+	return func(c *gin.Context) {
+		authorization := c.GetHeader(constants.AUTHORIZATION)
+		if authorization == "" || c.GetHeader(constants.X_API_KEY) != "" || !admin_auth.Enabled() {
+			authorizing(c)
+			return
+		}
+
+		identity, err := admin_auth.Authenticate(c.Request.Context(), authorization)
+		if err == admin_auth.ErrUnauthenticated {
 			c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
 			return
 		}
+		if err == admin_auth.ErrNoRole {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError(err.Error()).ToResponse())
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(500, exception.InternalServerError(err).ToResponse())
+			return
+		}
+
+		scope := requiredScope(c.Request.Method, c.FullPath())
+		if !identity.Role.Granted(scope) {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+				fmt.Sprintf("role %s is not granted scope %s", identity.Role, scope),
+			).ToResponse())
+			return
+		}
+
+		caller := fmt.Sprintf("%s:%s", identity.Method, identity.Name)
+		c.Set(constants.CONTEXT_KEY_CALLER, caller)
+		c.Set(constants.CONTEXT_KEY_OPERATOR, identity)
+		logger := log.FromContext(c.Request.Context()).With(log.FIELD_CALLER, caller)
+		c.Request = c.Request.WithContext(log.NewContext(c.Request.Context(), logger))
 
 		c.Next()
 	}
 }
 
+// VerifyingTenantToken checks the token signed by the Dify API for the tenant of the route, the token must
+// be issued for the tenant, grant the scope of the route and, if it's issued for a user, the user of the body.
+// Requests without a token are served unless it's required, callers bound to a tenant are checked by Authorizing
+func VerifyingTenantToken(secret *secrets.Value, required bool, maxTTL time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(constants.CONTEXT_KEY_CALLER_TENANT_ID) != "" {
+			c.Next()
+			return
+		}
+
+		token := c.GetHeader(constants.X_TENANT_TOKEN)
+		if token == "" {
+			if required {
+				c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
+				return
+			}
+			c.Next()
+			return
+		}
+
+		claims, err := tenant_token.Verify(secret.Get(), token, maxTTL, time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
+			return
+		}
+
+		if claims.TenantID != c.Param("tenant_id") {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+				"tenant token is issued for another tenant",
+			).ToResponse())
+			return
+		}
+
+		scope := requiredScope(c.Request.Method, c.FullPath())
+		if !api_token.Granted(claims.Scopes, scope) {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+				fmt.Sprintf("tenant token is not granted scope %s", scope),
+			).ToResponse())
+			return
+		}
+
+		if claims.UserID != "" && strings.HasPrefix(c.ContentType(), "application/json") {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.AbortWithStatusJSON(400, exception.BadRequestError(err).ToResponse())
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+			var request struct {
+				UserID string `json:"user_id"`
+			}
+			// malformed bodies are rejected by the handler
+			if json.Unmarshal(body, &request) == nil && request.UserID != "" && request.UserID != claims.UserID {
+				c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+					"tenant token is issued for another user",
+				).ToResponse())
+				return
+			}
+		}
+
+		c.Set(constants.CONTEXT_KEY_TENANT_TOKEN, claims)
+		if claims.UserID != "" {
+			logger := log.FromContext(c.Request.Context()).With(log.FIELD_USER_ID, claims.UserID)
+			c.Request = c.Request.WithContext(log.NewContext(c.Request.Context(), logger))
+		}
+
+		c.Next()
+	}
+}
+
+// RequiringFeature rejects requests of tenants the feature flag is off for
+func RequiringFeature(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !feature_flag.Enabled(name, c.Param("tenant_id")) {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+				fmt.Sprintf("feature %s is not enabled for the tenant", name),
+			).ToResponse())
+			return
+		}
+		c.Next()
+	}
+}
+
 func (app *App) FetchPluginInstallation() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		pluginId := ctx.Request.Header.Get(constants.X_PLUGIN_ID)
@@ -41,6 +285,7 @@ func (app *App) FetchPluginInstallation() gin.HandlerFunc {
 
 		// fetch plugin installation
 		installation, err := db.GetOne[models.PluginInstallation](
+			db.WithContext(ctx.Request.Context()),
 			db.Equal("tenant_id", tenantId),
 			db.Equal("plugin_id", pluginId),
 		)
@@ -67,6 +312,30 @@ func (app *App) FetchPluginInstallation() gin.HandlerFunc {
 	}
 }
 
+// PluginIDFromBody takes the plugin id from the json body for routes which don't carry it in the header,
+// the body is left for the handler
+func PluginIDFromBody() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(400, exception.BadRequestError(err).ToResponse())
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var request struct {
+			PluginID string `json:"plugin_id"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			ctx.AbortWithStatusJSON(400, exception.BadRequestError(err).ToResponse())
+			return
+		}
+
+		ctx.Request.Header.Set(constants.X_PLUGIN_ID, request.PluginID)
+		ctx.Next()
+	}
+}
+
 // RedirectPluginInvoke redirects the request to the correct cluster node
 func (app *App) RedirectPluginInvoke() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -90,8 +359,7 @@ func (app *App) RedirectPluginInvoke() gin.HandlerFunc {
 		}
 
 		// check if plugin in current node
-		if ok, originalError := app.cluster.IsPluginOnCurrentNode(identity); !ok {
-			app.redirectPluginInvokeByPluginIdentifier(ctx, identity, originalError)
+		if !app.servePluginLocally(ctx, identity) {
 			ctx.Abort()
 		} else {
 			ctx.Next()
@@ -99,6 +367,49 @@ func (app *App) RedirectPluginInvoke() gin.HandlerFunc {
 	}
 }
 
+// servePluginLocally returns true if the request should be served by the current node,
+// otherwise it has been redirected to the node picked for the plugin
+func (app *App) servePluginLocally(
+	ctx *gin.Context,
+	identity plugin_entities.PluginUniqueIdentifier,
+) bool {
+	// a fenced node may be gone in the view of other nodes, it neither serves nor redirects requests
+	if app.cluster.IsFenced() {
+		ctx.AbortWithStatusJSON(503, exception.NodeFencedError().ToResponse())
+		return false
+	}
+
+	ok, originalError := app.cluster.IsPluginOnCurrentNode(identity)
+	atCapacity := ok && app.cluster.AtCapacity()
+
+	// requests redirected by another node are served here to avoid redirecting them back and forth,
+	// a draining node or a node at capacity always picks so that new sessions move to other nodes running the plugin
+	pick := app.cluster.LoadBalancingEnabled() || app.cluster.IsDraining() || atCapacity
+	if pick && ctx.GetHeader(constants.X_PLUGIN_REDIRECTED_FROM) == "" {
+		nodeId, err := app.cluster.PickNodeForPlugin(identity)
+		if err != nil {
+			log.Warn("failed to pick node for plugin %s, falling back: %s", identity.String(), err.Error())
+		} else if nodeId == app.cluster.ID() {
+			return true
+		} else if nodeId != "" {
+			app.redirectPluginInvokeToNode(ctx, nodeId)
+			return false
+		}
+	}
+
+	// no other node is able to take the session, the caller is told to retry later or elsewhere
+	if atCapacity {
+		ctx.Header(constants.X_PLUGIN_NODE_AT_CAPACITY, app.cluster.ID())
+		ctx.AbortWithStatusJSON(503, exception.NodeAtCapacityError().ToResponse())
+		return false
+	}
+
+	if !ok {
+		app.redirectPluginInvokeByPluginIdentifier(ctx, identity, originalError)
+	}
+	return ok
+}
+
 func (app *App) redirectPluginInvokeByPluginIdentifier(
 	ctx *gin.Context,
 	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
@@ -124,8 +435,19 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 		return
 	}
 
-	// redirect to the correct node
+	// redirect to the correct node, nodes at capacity are tried only if all the nodes are
 	nodeId := nodes[0]
+	for _, node := range nodes {
+		if !app.cluster.IsNodeAtCapacity(node) {
+			nodeId = node
+			break
+		}
+	}
+	app.redirectPluginInvokeToNode(ctx, nodeId)
+}
+
+func (app *App) redirectPluginInvokeToNode(ctx *gin.Context, nodeId string) {
+	ctx.Request.Header.Set(constants.X_PLUGIN_REDIRECTED_FROM, app.cluster.ID())
 	statusCode, header, body, err := app.cluster.RedirectRequest(nodeId, ctx.Request)
 	if err != nil {
 		log.Error("redirect request failed: %s", err.Error())
@@ -136,16 +458,20 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 		return
 	}
 
-	// set status code
-	ctx.Writer.WriteHeader(statusCode)
+	if statusCode == 503 && header.Get(constants.X_PLUGIN_NODE_AT_CAPACITY) != "" {
+		app.cluster.MarkNodeAtCapacity(nodeId)
+	}
 
-	// set header
+	// set header, headers are sent along with the status code so they're set before it
 	for key, values := range header {
 		for _, value := range values {
 			ctx.Writer.Header().Set(key, value)
 		}
 	}
 
+	// set status code
+	ctx.Writer.WriteHeader(statusCode)
+
 	for {
 		buf := make([]byte, 1024)
 		n, err := body.Read(buf)
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/secrets"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_token"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+)
+
+func TestPluginIDFromBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/tools/:tenant_id/invoke", PluginIDFromBody(), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, c.GetHeader(constants.X_PLUGIN_ID)+" "+string(body))
+	})
+
+	body := `{"plugin_id":"author/tool","provider":"tool"}`
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/tools/tenant/invoke", strings.NewReader(body)))
+	if recorder.Body.String() != "author/tool "+body {
+		t.Fatalf("plugin id should be set and the body kept, got %s", recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/tools/tenant/invoke", strings.NewReader("not json")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("invalid body should be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestVerifyingTenantToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := secrets.Watch("TEST_TENANT_TOKEN_SECRET", "secret")
+
+	newEngine := func(required bool) *gin.Engine {
+		engine := gin.New()
+		engine.POST(
+			"/plugin/:tenant_id/dispatch/tool/invoke",
+			VerifyingTenantToken(secret, required, 5*time.Minute),
+			func(c *gin.Context) {
+				body, _ := io.ReadAll(c.Request.Body)
+				c.String(http.StatusOK, string(body))
+			},
+		)
+		return engine
+	}
+
+	sign := func(claims tenant_token.Claims) string {
+		claims.IssuedAt = time.Now().Unix()
+		claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+		token, err := tenant_token.Sign("secret", claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	serve := func(engine *gin.Engine, tenantId string, token string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/plugin/"+tenantId+"/dispatch/tool/invoke", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			request.Header.Set(constants.X_TENANT_TOKEN, token)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	engine := newEngine(true)
+	token := sign(tenant_token.Claims{TenantID: "tenant", UserID: "user", Scopes: []string{"plugins:invoke"}})
+
+	body := `{"user_id":"user"}`
+	if recorder := serve(engine, "tenant", token, body); recorder.Code != http.StatusOK || recorder.Body.String() != body {
+		t.Fatalf("request of the token should be served with its body, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve(engine, "tenant", "", body); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("request without a token should be rejected once it's required, got %d", recorder.Code)
+	}
+	if recorder := serve(engine, "tenant", token+"x", body); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("request with a forged token should be rejected, got %d", recorder.Code)
+	}
+	if recorder := serve(engine, "victim", token, body); recorder.Code != http.StatusForbidden {
+		t.Fatalf("request to another tenant should be rejected, got %d", recorder.Code)
+	}
+	if recorder := serve(engine, "tenant", token, `{"user_id":"victim"}`); recorder.Code != http.StatusForbidden {
+		t.Fatalf("request on behalf of another user should be rejected, got %d", recorder.Code)
+	}
+
+	readOnly := sign(tenant_token.Claims{TenantID: "tenant", Scopes: []string{"plugins:read"}})
+	if recorder := serve(engine, "tenant", readOnly, body); recorder.Code != http.StatusForbidden {
+		t.Fatalf("request out of the scopes of the token should be rejected, got %d", recorder.Code)
+	}
+
+	if recorder := serve(newEngine(false), "tenant", "", body); recorder.Code != http.StatusOK {
+		t.Fatalf("request without a token should be served unless it's required, got %d", recorder.Code)
+	}
+}
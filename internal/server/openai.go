@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/openai_tools"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// openaiGroup runs tool calls of OpenAI chat completions by tools of installed plugins of the tenant,
+// tools are listed as functions of chat completions on /tools and called on /tool_calls
+func (app *App) openaiGroup(group *gin.RouterGroup, config *app.Config, handler http.Handler) {
+	if !config.OpenAIToolCallsEnabled {
+		return
+	}
+
+	group.Use(Authorizing(app.serverKey))
+	group.Use(app.verifyingTenantToken(config))
+
+	transport := handlerTransport{handler: handler}
+
+	group.GET("/tools", func(c *gin.Context) {
+		controllers.BindRequest(c, func(request struct {
+			UserID string `form:"user"`
+		}) {
+			tools, err := pluginTools(c, transport, request.UserID).ListTools(c.Request.Context())
+			if err != nil {
+				c.JSON(http.StatusOK, exception.InternalServerError(err).ToResponse())
+				return
+			}
+			c.JSON(http.StatusOK, entities.NewSuccessResponse(openai_tools.FunctionTools(tools)))
+		})
+	})
+
+	group.POST("/tool_calls", func(c *gin.Context) {
+		controllers.BindRequest(c, func(request struct {
+			ToolCalls []openai_tools.ToolCall `json:"tool_calls" validate:"required,min=1,max=32,dive"`
+			UserID    string                  `json:"user"`
+			Stream    bool                    `json:"stream"`
+		}) {
+			tools := pluginTools(c, transport, request.UserID)
+
+			if !request.Stream {
+				messages := []openai_tools.ToolMessage{}
+				err := openai_tools.Run(c.Request.Context(), tools, request.ToolCalls, func(message openai_tools.ToolMessage) {
+					messages = append(messages, message)
+				})
+				if err != nil {
+					c.JSON(http.StatusOK, exception.InternalServerError(err).ToResponse())
+					return
+				}
+				c.JSON(http.StatusOK, entities.NewSuccessResponse(messages))
+				return
+			}
+
+			// messages are streamed as chat completions stream chunks, once their calls are done
+			c.Writer.Header().Set("Content-Type", "text/event-stream")
+			c.Writer.WriteHeader(http.StatusOK)
+			err := openai_tools.Run(c.Request.Context(), tools, request.ToolCalls, func(message openai_tools.ToolMessage) {
+				stream.WriteEvent(c.Writer, message)
+				c.Writer.Flush()
+			})
+			if err != nil {
+				stream.WriteEvent(c.Writer, exception.InternalServerError(err).ToResponse())
+			}
+			stream.WriteRawEvent(c.Writer, []byte("[DONE]"))
+			c.Writer.Flush()
+		})
+	})
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/openai_tools"
+	"github.com/langgenius/dify-plugin-daemon/internal/manifest"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/openapi"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/agent_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+// openapiRoutes documents routes by "METHOD path" in the gin syntax, routes registered on the engine
+// but missing here are still listed with their path parameters and an untyped response
+var openapiRoutes = map[string]openapi.Route{
+	// dispatch, the plugin is picked by the X-Plugin-ID header, results are streamed
+	"POST /plugin/:tenant_id/dispatch/tool/invoke": {
+		Summary:  "invoke a tool",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]{},
+		Response: tool_entities.ToolResponseChunk{},
+	},
+	"POST /plugin/:tenant_id/dispatch/tool/invoke/typed": {
+		Summary:  "invoke a tool, results are streamed in typed chunks ended by a usage chunk",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]{},
+		Response: tool_entities.ToolStreamChunk{},
+		Stream:   true,
+	},
+	"POST /tools/:tenant_id/invoke": {
+		Summary:  "invoke a tool of an installed plugin directly",
+		Request:  requests.RequestDirectInvokeTool{},
+		Response: tool_entities.ToolResponseChunk{},
+		Stream:   true,
+	},
+	"POST /plugin/:tenant_id/dispatch/tool/validate_credentials": {
+		Summary:  "validate credentials of a tool provider",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestValidateToolCredentials]{},
+		Response: tool_entities.ValidateCredentialsResult{},
+	},
+	"POST /plugin/:tenant_id/dispatch/tool/get_runtime_parameters": {
+		Summary:  "get runtime parameters of a tool",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestGetToolRuntimeParameters]{},
+		Response: tool_entities.GetToolRuntimeParametersResponse{},
+	},
+	"POST /plugin/:tenant_id/dispatch/agent_strategy/invoke": {
+		Summary:  "invoke an agent strategy",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeAgentStrategy]{},
+		Response: agent_entities.AgentStrategyResponseChunk{},
+	},
+	"POST /plugin/:tenant_id/dispatch/agent_strategy/invoke/events": {
+		Summary:  "invoke an agent strategy, its steps, thoughts and tool calls are streamed as ordered events ended by a finish event",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeAgentStrategy]{},
+		Response: agent_entities.AgentEvent{},
+		Stream:   true,
+	},
+	"POST /plugin/:tenant_id/dispatch/llm/invoke": {
+		Summary:  "invoke a large language model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeLLM]{},
+		Response: model_entities.LLMResultChunk{},
+	},
+	"POST /plugin/:tenant_id/dispatch/llm/num_tokens": {
+		Summary:  "count tokens of prompt messages",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestGetLLMNumTokens]{},
+		Response: model_entities.LLMGetNumTokensResponse{},
+	},
+	"POST /plugin/:tenant_id/dispatch/text_embedding/invoke": {
+		Summary:  "invoke a text embedding model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeTextEmbedding]{},
+		Response: model_entities.TextEmbeddingResult{},
+	},
+	"POST /plugin/:tenant_id/dispatch/text_embedding/num_tokens": {
+		Summary:  "count tokens of texts to embed",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestGetTextEmbeddingNumTokens]{},
+		Response: model_entities.GetTextEmbeddingNumTokensResponse{},
+	},
+	"POST /plugin/:tenant_id/dispatch/rerank/invoke": {
+		Summary:  "invoke a rerank model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeRerank]{},
+		Response: model_entities.RerankResult{},
+	},
+	"POST /plugin/:tenant_id/dispatch/tts/invoke": {
+		Summary:  "invoke a text to speech model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeTTS]{},
+		Response: model_entities.TTSResult{},
+	},
+	"POST /plugin/:tenant_id/dispatch/tts/model/voices": {
+		Summary:  "list voices of a text to speech model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestGetTTSModelVoices]{},
+		Response: model_entities.GetTTSVoicesResponse{},
+	},
+	"POST /plugin/:tenant_id/dispatch/speech2text/invoke": {
+		Summary:  "invoke a speech to text model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeSpeech2Text]{},
+		Response: model_entities.Speech2TextResult{},
+	},
+	"POST /plugin/:tenant_id/dispatch/moderation/invoke": {
+		Summary:  "invoke a moderation model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestInvokeModeration]{},
+		Response: model_entities.ModerationResult{},
+	},
+	"POST /plugin/:tenant_id/dispatch/model/validate_provider_credentials": {
+		Summary:  "validate credentials of a model provider",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestValidateProviderCredentials]{},
+		Response: model_entities.ValidateCredentialsResult{},
+	},
+	"POST /plugin/:tenant_id/dispatch/model/validate_model_credentials": {
+		Summary:  "validate credentials of a model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestValidateModelCredentials]{},
+		Response: model_entities.ValidateCredentialsResult{},
+	},
+	"POST /plugin/:tenant_id/dispatch/model/schema": {
+		Summary:  "get the schema of a model",
+		Request:  plugin_entities.InvokePluginRequest[requests.RequestGetAIModelSchema]{},
+		Response: model_entities.GetModelSchemasResponse{},
+	},
+
+	// management
+	"POST /plugin/:tenant_id/debugging/key":                                   {Summary: "get the remote debugging key of the tenant"},
+	"POST /plugin/:tenant_id/management/install/upload/package":               {Summary: "upload a plugin package"},
+	"POST /plugin/:tenant_id/management/install/upload/bundle":                {Summary: "upload a plugin bundle"},
+	"POST /plugin/:tenant_id/management/install/identifiers":                  {Summary: "install plugins by unique identifiers"},
+	"POST /plugin/:tenant_id/management/install/batch":                        {Summary: "install plugins in a batch"},
+	"POST /plugin/:tenant_id/management/install/upgrade":                      {Summary: "upgrade a plugin"},
+	"GET /plugin/:tenant_id/management/install/tasks":                         {Summary: "list installation tasks"},
+	"GET /plugin/:tenant_id/management/install/tasks/:id":                     {Summary: "get an installation task"},
+	"GET /plugin/:tenant_id/management/install/tasks/:id/watch":               {Summary: "stream transitions of an installation task", Response: models.InstallTask{}, Stream: true},
+	"POST /plugin/:tenant_id/management/install/tasks/delete_all":             {Summary: "delete all installation tasks"},
+	"POST /plugin/:tenant_id/management/install/tasks/:id/delete":             {Summary: "delete an installation task"},
+	"POST /plugin/:tenant_id/management/pack":                                 {Summary: "pack a plugin from source"},
+	"GET /plugin/:tenant_id/management/pack/download":                         {Summary: "download a packed plugin", Raw: true},
+	"POST /plugin/:tenant_id/management/import/openapi":                       {Summary: "generate a tool plugin from an openapi spec"},
+	"GET /plugin/:tenant_id/management/fetch/manifest":                        {Summary: "get the manifest of a plugin"},
+	"GET /plugin/:tenant_id/management/fetch/declaration/diff":                {Summary: "diff declarations of an installed and a candidate version of a plugin", Response: plugin_entities.DeclarationDiff{}},
+	"GET /plugin/:tenant_id/management/fetch/declaration/validate":            {Summary: "lint the declaration of a plugin for problems beyond the schema", Response: []plugin_entities.DeclarationWarning{}},
+	"GET /plugin/:tenant_id/management/fetch/identifier":                      {Summary: "get a plugin by its unique identifier"},
+	"POST /plugin/:tenant_id/management/uninstall":                            {Summary: "uninstall a plugin"},
+	"POST /plugin/:tenant_id/management/uninstall/batch":                      {Summary: "uninstall plugins in a batch"},
+	"POST /plugin/:tenant_id/management/repair":                               {Summary: "repair a plugin installation"},
+	"GET /plugin/:tenant_id/management/list":                                  {Summary: "list installed plugins, labels are resolved by the locale parameter or Accept-Language"},
+	"POST /plugin/:tenant_id/management/installation/fetch/batch":             {Summary: "get installations by ids"},
+	"POST /plugin/:tenant_id/management/installation/missing":                 {Summary: "list plugins which are not installed"},
+	"GET /plugin/:tenant_id/management/models":                                {Summary: "list model providers, labels are resolved by the locale parameter or Accept-Language"},
+	"GET /plugin/:tenant_id/management/tools":                                 {Summary: "list tool providers, labels are resolved by the locale parameter or Accept-Language"},
+	"GET /plugin/:tenant_id/management/tool":                                  {Summary: "get a tool provider"},
+	"POST /plugin/:tenant_id/management/tools/check_existence":                {Summary: "check existence of tool providers"},
+	"GET /plugin/:tenant_id/management/agent_strategies":                      {Summary: "list agent strategy providers, labels are resolved by the locale parameter or Accept-Language"},
+	"GET /plugin/:tenant_id/management/agent_strategy":                        {Summary: "get an agent strategy provider"},
+	"GET /plugin/:tenant_id/management/usage/daily":                           {Summary: "list daily usage of plugins"},
+	"GET /plugin/:tenant_id/management/credential_pools":                      {Summary: "list credential pools of model providers along with their keys"},
+	"POST /plugin/:tenant_id/management/credential_pools/create":              {Summary: "create a credential pool of a model provider"},
+	"POST /plugin/:tenant_id/management/credential_pools/delete":              {Summary: "delete a credential pool along with its keys"},
+	"POST /plugin/:tenant_id/management/credential_pools/keys/add":            {Summary: "add a key to a credential pool"},
+	"POST /plugin/:tenant_id/management/credential_pools/keys/update":         {Summary: "update a key of a credential pool"},
+	"POST /plugin/:tenant_id/management/credential_pools/keys/remove":         {Summary: "remove a key from a credential pool"},
+	"POST /plugin/:tenant_id/management/credential_pools/keys/reset_cooldown": {Summary: "make a rate limited key of a credential pool available again"},
+	"POST /plugin/:tenant_id/management/model_cache/purge":                    {Summary: "drop model responses cached for the tenant"},
+	"GET /plugin/:tenant_id/management/policy":                                {Summary: "get the plugin policy of the tenant"},
+	"POST /plugin/:tenant_id/management/policy/check":                         {Summary: "check a plugin against the policy"},
+	"GET /plugin/:tenant_id/management/guardrails":                            {Summary: "get the guardrail policy inspecting tool inputs and outputs of the tenant"},
+	"POST /plugin/:tenant_id/management/guardrails/update":                    {Summary: "update the guardrail policy of the tenant"},
+	"GET /plugin/:tenant_id/management/guardrails/events":                     {Summary: "list inputs and outputs of tools blocked, flagged or redacted by guardrails"},
+	"GET /plugin/:tenant_id/management/storage/usage":                         {Summary: "get storage usage of the tenant"},
+	"GET /plugin/:tenant_id/management/webhooks":                              {Summary: "list webhooks"},
+	"POST /plugin/:tenant_id/management/webhooks/create":                      {Summary: "create a webhook"},
+	"POST /plugin/:tenant_id/management/webhooks/update":                      {Summary: "update a webhook"},
+	"POST /plugin/:tenant_id/management/webhooks/delete":                      {Summary: "delete a webhook"},
+	"POST /plugin/:tenant_id/endpoint/setup":                                  {Summary: "set up an endpoint"},
+	"POST /plugin/:tenant_id/endpoint/remove":                                 {Summary: "remove an endpoint"},
+	"POST /plugin/:tenant_id/endpoint/update":                                 {Summary: "update an endpoint"},
+	"GET /plugin/:tenant_id/endpoint/list":                                    {Summary: "list endpoints, labels are resolved by the locale parameter or Accept-Language"},
+	"GET /plugin/:tenant_id/endpoint/list/plugin":                             {Summary: "list endpoints of a plugin, labels are resolved by the locale parameter or Accept-Language"},
+	"POST /plugin/:tenant_id/endpoint/enable":                                 {Summary: "enable an endpoint"},
+	"POST /plugin/:tenant_id/endpoint/disable":                                {Summary: "disable an endpoint"},
+	"GET /plugin/:tenant_id/endpoint/templates":                               {Summary: "list endpoint templates"},
+	"POST /plugin/:tenant_id/endpoint/templates/create":                       {Summary: "create an endpoint template"},
+	"POST /plugin/:tenant_id/endpoint/templates/update":                       {Summary: "update an endpoint template"},
+	"POST /plugin/:tenant_id/endpoint/templates/delete":                       {Summary: "delete an endpoint template along with its endpoints"},
+	"POST /plugin/:tenant_id/endpoint/templates/materialize":                  {Summary: "set up endpoints from an endpoint template"},
+	"GET /plugin/:tenant_id/endpoint/templates/instances":                     {Summary: "list endpoints materialized from an endpoint template"},
+	"POST /plugin/:tenant_id/endpoint/templates/instances/manage":             {Summary: "sync, enable, disable or remove endpoints of an endpoint template"},
+	"GET /plugin/:tenant_id/asset/:id":                                        {Summary: "download an asset of a plugin", Raw: true},
+	"POST /plugin/:tenant_id/asset/sign":                                      {Summary: "sign urls of assets and packages"},
+	"GET /assets/:id":                                                         {Summary: "download an asset by a signed url", Raw: true},
+	"GET /assets/package":                                                     {Summary: "download a plugin package by a signed url", Raw: true},
+	"GET /cluster/nodes":                                                      {Summary: "list nodes of the cluster"},
+	"POST /cluster/nodes/:id/drain":                                           {Summary: "drain a node"},
+	"GET /admin/overview":                                                     {Summary: "get an overview of the daemon"},
+	"GET /admin/support-bundle":                                               {Summary: "download a redacted support bundle of the node", Raw: true},
+	"POST /plugin/:tenant_id/management/install/tasks/:id/delete/*identifier": {Summary: "delete a plugin from an installation task"},
+	"POST /plugin/:tenant_id/management/dev/start":                            {Summary: "start a plugin in development mode"},
+	"POST /plugin/:tenant_id/management/dev/stop":                             {Summary: "stop a plugin in development mode"},
+	"GET /plugin/:tenant_id/management/dev/list":                              {Summary: "list plugins in development mode"},
+	"POST /backwards-invocation/transaction":                                  {Summary: "handle a transaction of a serverless plugin", Raw: true},
+	"GET /cluster/jobs":                                                       {Summary: "list singleton jobs"},
+	"GET /cluster/reconciliation":                                             {Summary: "list reconciliations of local plugins"},
+	"GET /cluster/log_levels":                                                 {Summary: "list log levels of nodes"},
+	"POST /cluster/log_levels/update":                                         {Summary: "update the log level"},
+	"GET /slo/reports":                                                        {Summary: "list slo reports of plugins"},
+	"GET /slo/objectives":                                                     {Summary: "list slo objectives of plugins"},
+	"POST /slo/objectives/update":                                             {Summary: "update the slo objective of a plugin"},
+	"POST /slo/objectives/delete":                                             {Summary: "delete the slo objective of a plugin"},
+	"GET /admin/whoami":                                                       {Summary: "get the caller and the role of the operator signed in"},
+	"GET /admin/encryption/keys":                                              {Summary: "list encryption keys"},
+	"POST /admin/encryption/reencrypt":                                        {Summary: "re-encrypt secrets by the active key"},
+	"GET /admin/credential_access":                                            {Summary: "list credential access logs"},
+	"GET /admin/sessions":                                                     {Summary: "list sessions served by the node"},
+	"POST /admin/sessions/kill":                                               {Summary: "kill a session served by the node"},
+	"GET /admin/tokens":                                                       {Summary: "list api tokens"},
+	"POST /admin/tokens/create":                                               {Summary: "create an api token"},
+	"POST /admin/tokens/revoke":                                               {Summary: "revoke an api token"},
+	"POST /admin/state/plan":                                                  {Summary: "plan changes reconciling plugins and endpoints of tenants to a declared state"},
+	"POST /admin/state/apply":                                                 {Summary: "reconcile plugins and endpoints of tenants to a declared state"},
+	"GET /admin/faults":                                                       {Summary: "list fault injection rules of the node"},
+	"POST /admin/faults/set":                                                  {Summary: "replace fault injection rules of the node"},
+	"POST /admin/faults/clear":                                                {Summary: "remove fault injection rules of the node"},
+	"GET /admin/feature_flags":                                                {Summary: "list feature flags declared or configured along with their configurations"},
+	"POST /admin/feature_flags/update":                                        {Summary: "configure a feature flag for tenants or a percentage of them"},
+	"POST /admin/feature_flags/delete":                                        {Summary: "remove the configuration of a feature flag, its default applies again"},
+	"GET /admin/feature_flags/evaluate":                                       {Summary: "tell which feature flags are on for a tenant"},
+	"POST /admin/gc":                                                          {Summary: "collect garbage of plugins in the storage shared by all the tenants"},
+	"GET /admin/policy":                                                       {Summary: "get the plugin policy applying to all the tenants"},
+	"POST /admin/policy/update":                                               {Summary: "update the plugin policy applying to all the tenants"},
+	"POST /admin/policy/delete":                                               {Summary: "delete the plugin policy applying to all the tenants"},
+	"POST /admin/policy/tenant/update":                                        {Summary: "update the plugin policy of a tenant"},
+	"POST /admin/policy/tenant/delete":                                        {Summary: "delete the plugin policy of a tenant"},
+	"GET /admin/webhooks":                                                     {Summary: "list webhooks receiving events of all the tenants"},
+	"POST /admin/webhooks/create":                                             {Summary: "create a webhook receiving events of all the tenants"},
+	"POST /admin/webhooks/update":                                             {Summary: "update a webhook receiving events of all the tenants"},
+	"POST /admin/webhooks/delete":                                             {Summary: "delete a webhook receiving events of all the tenants"},
+	"GET /admin/serverless/prewarm/stats":                                     {Summary: "get stats of prewarmed serverless runtimes"},
+	"GET /admin/serverless/transport/stats":                                   {Summary: "get stats of the serverless transport"},
+	"GET /admin/serverless/failover/status":                                   {Summary: "get the failover status of serverless runtimes"},
+	"GET /admin/serverless/regions/status":                                    {Summary: "get the status of serverless regions"},
+	"GET /admin/serverless/versions":                                          {Summary: "list deployed versions of a serverless plugin"},
+	"POST /admin/serverless/rollback":                                         {Summary: "roll a serverless plugin back to a version"},
+	"POST /admin/serverless/rollback/cancel":                                  {Summary: "cancel a rollback of a serverless plugin"},
+	"GET /admin/serverless/resources":                                         {Summary: "get resources of a serverless plugin"},
+	"POST /admin/serverless/resources/update":                                 {Summary: "update resources of a serverless plugin"},
+	"POST /admin/serverless/resources/delete":                                 {Summary: "reset resources of a serverless plugin"},
+	"GET /admin/serverless/telemetry/costs":                                   {Summary: "list costs of serverless plugins"},
+	"GET /admin/serverless/telemetry/invocations":                             {Summary: "list invocation stats of serverless plugins"},
+	"POST /admin/storage/quota/update":                                        {Summary: "update the storage quota of a tenant"},
+	"POST /admin/storage/quota/delete":                                        {Summary: "delete the storage quota of a tenant"},
+	"GET /admin/config/reload":                                                {Summary: "get the outcome of the latest reload of settings of the node", Response: config_loader.Status{}},
+	"POST /admin/config/reload":                                               {Summary: "reload settings of the node from the environment and the config file", Response: config_loader.Status{}},
+	"GET /mcp/:tenant_id/sse":                                                 {Summary: "open a session of mcp clients, responses are sent as events", Raw: true},
+	"POST /mcp/:tenant_id/message":                                            {Summary: "post a json-rpc message to a session of mcp clients", Raw: true},
+	"GET /openai/:tenant_id/tools":                                            {Summary: "list tools of installed plugins as functions of chat completions"},
+	"POST /openai/:tenant_id/tool_calls":                                      {Summary: "run tool calls of chat completions by tools of installed plugins", Response: []openai_tools.ToolMessage{}},
+	"GET /health/check":                                                       {Summary: "get the health of the daemon", Raw: true},
+	"GET /healthz":                                                            {Summary: "liveness probe", Raw: true},
+	"GET /readyz":                                                             {Summary: "readiness probe", Raw: true},
+	"GET /metrics":                                                            {Summary: "prometheus metrics", Raw: true},
+	"GET /openapi.json":                                                       {Summary: "the openapi document of the api", Raw: true},
+}
+
+// openapiDocument builds the document of all routes registered on the engine
+func openapiDocument(engine *gin.Engine) *openapi.Document {
+	generator := openapi.NewGenerator("dify plugin daemon", manifest.VersionX, constants.X_API_KEY)
+	for _, info := range engine.Routes() {
+		// preflight requests of CORS are not operations
+		if info.Method == http.MethodOptions {
+			continue
+		}
+		route := openapiRoutes[info.Method+" "+info.Path]
+		route.Method = info.Method
+		route.Path = info.Path
+		route.Tags = []string{openapiTag(info.Path)}
+		route.Secured = openapiSecured(info.Path)
+		if strings.Contains(info.Path, "/dispatch/") {
+			route.Headers = []string{constants.X_PLUGIN_ID}
+			route.Stream = true
+		}
+		// endpoints and pprof reply as they like
+		if strings.HasPrefix(info.Path, "/e/") || strings.Contains(info.Path, "/pprof/") {
+			route.Raw = true
+		}
+		generator.Add(route)
+	}
+	return generator.Document()
+}
+
+// openapiTag groups operations by the first segment of the path, routes of a tenant by the one after it
+func openapiTag(path string) string {
+	path = strings.TrimPrefix(path, "/plugin/:tenant_id")
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	switch segment {
+	case "e":
+		return "endpoint_invocation"
+	case "healthz", "readyz":
+		return "health"
+	}
+	return strings.TrimSuffix(segment, ".json")
+}
+
+func openapiSecured(path string) bool {
+	for _, prefix := range []string{"/plugin/", "/tools/", "/cluster/", "/slo/", "/admin/", "/debug/pprof/"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenAPI serves the document, it's built on the first request when all routes are registered
+func OpenAPI(engine *gin.Engine) gin.HandlerFunc {
+	var once sync.Once
+	var document *openapi.Document
+	return func(c *gin.Context) {
+		once.Do(func() {
+			document = openapiDocument(engine)
+		})
+		c.JSON(http.StatusOK, document)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/openapi"
+)
+
+func TestOpenAPIDocumentsAllRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := func(c *gin.Context) {}
+	// every documented route is registered, so schemas of all entities are built
+	for key := range openapiRoutes {
+		method, path, _ := strings.Cut(key, " ")
+		if path != "/openapi.json" {
+			engine.Handle(method, path, handler)
+		}
+	}
+	engine.POST("/plugin/:tenant_id/management/undocumented", handler)
+	engine.GET("/openapi.json", OpenAPI(engine))
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", recorder.Code)
+	}
+
+	document := openapi.Document{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	invoke := (*document.Paths["/plugin/{tenant_id}/dispatch/tool/invoke"])["post"]
+	if invoke == nil || invoke.RequestBody == nil {
+		t.Fatal("tool invocation should be documented with its body")
+	}
+	if invoke.Tags[0] != "dispatch" || len(invoke.Security) == 0 {
+		t.Error("tool invocation should be a secured dispatch operation")
+	}
+	if _, ok := invoke.Responses["200"].Content["text/event-stream"]; !ok {
+		t.Error("tool invocation should stream its results")
+	}
+	header := false
+	for _, parameter := range invoke.Parameters {
+		header = header || (parameter.In == "header" && parameter.Name == "X-Plugin-ID")
+	}
+	if !header {
+		t.Error("tool invocation should require the plugin id header")
+	}
+
+	if _, ok := document.Components.Schemas["tool_entities.ToolResponseChunk"]; !ok {
+		t.Error("tool response should be a component")
+	}
+	if (*document.Paths["/plugin/{tenant_id}/management/undocumented"])["post"] == nil {
+		t.Error("undocumented routes should be listed")
+	}
+	if (*document.Paths["/healthz"])["get"].Security != nil {
+		t.Error("probes are not secured")
+	}
+}
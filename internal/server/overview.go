@@ -0,0 +1,140 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/slo"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	overviewDefaultWindow     = 15
+	overviewDefaultTopPlugins = 10
+)
+
+type pluginCounts struct {
+	Plugins       int64                                       `json:"plugins"`
+	Installations int64                                       `json:"installations"`
+	ByType        map[plugin_entities.PluginRuntimeType]int64 `json:"by_type"`
+}
+
+type clusterOverview struct {
+	Nodes      int                  `json:"nodes"`
+	Draining   int                  `json:"draining"`
+	AtCapacity int                  `json:"at_capacity"`
+	Sessions   int                  `json:"sessions"`
+	Master     string               `json:"master"`
+	Status     []cluster.NodeStatus `json:"status"`
+}
+
+type adminOverview struct {
+	Installed pluginCounts `json:"installed"`
+	// RuntimeStatuses is the number of plugins running on the node serving the request by their status
+	RuntimeStatuses map[string]int  `json:"runtime_statuses"`
+	Cluster         clusterOverview `json:"cluster"`
+	// Usage is nil if slo tracking is disabled, as invocations are not tracked then
+	Usage *slo.Usage `json:"usage"`
+}
+
+func countInstalledPlugins() (pluginCounts, error) {
+	counts := pluginCounts{ByType: map[plugin_entities.PluginRuntimeType]int64{}}
+
+	var err error
+	counts.Plugins, err = db.GetCount[models.Plugin]()
+	if err != nil {
+		return counts, err
+	}
+	counts.Installations, err = db.GetCount[models.PluginInstallation]()
+	if err != nil {
+		return counts, err
+	}
+
+	for _, runtimeType := range []plugin_entities.PluginRuntimeType{
+		plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL,
+		plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE,
+		plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS,
+	} {
+		count, err := db.GetCount[models.Plugin](db.Equal("install_type", string(runtimeType)))
+		if err != nil {
+			return counts, err
+		}
+		counts.ByType[runtimeType] = count
+	}
+	return counts, nil
+}
+
+func (app *App) clusterOverview() (clusterOverview, error) {
+	nodes, err := app.cluster.ListNodes()
+	if err != nil {
+		return clusterOverview{}, err
+	}
+
+	overview := clusterOverview{Nodes: len(nodes), Status: nodes}
+	for _, node := range nodes {
+		if node.Draining {
+			overview.Draining++
+		}
+		if node.AtCapacity {
+			overview.AtCapacity++
+		}
+		if node.Master {
+			overview.Master = node.ID
+		}
+		overview.Sessions += node.Sessions
+	}
+	return overview, nil
+}
+
+// AdminOverview aggregates installed plugins, runtimes, invocations and the cluster into one response
+// to back an operations dashboard
+func (app *App) AdminOverview(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		controllers.BindRequest(c, func(request struct {
+			Window int `form:"window" validate:"omitempty,min=1,max=1440"`
+			Top    int `form:"top" validate:"omitempty,min=1,max=100"`
+		}) {
+			if request.Window == 0 {
+				request.Window = overviewDefaultWindow
+			}
+			if request.Top == 0 {
+				request.Top = overviewDefaultTopPlugins
+			}
+
+			overview := adminOverview{RuntimeStatuses: map[string]int{}}
+
+			var err error
+			overview.Installed, err = countInstalledPlugins()
+			if err != nil {
+				c.JSON(200, exception.InternalServerError(err).ToResponse())
+				return
+			}
+
+			overview.Cluster, err = app.clusterOverview()
+			if err != nil {
+				c.JSON(200, exception.InternalServerError(err).ToResponse())
+				return
+			}
+
+			if manager := plugin_manager.Manager(); manager != nil {
+				overview.RuntimeStatuses = manager.RuntimeStatuses()
+			}
+
+			if config.SLOEnabled {
+				overview.Usage, err = slo.TopUsage(request.Window, request.Top)
+				if err != nil {
+					c.JSON(200, exception.InternalServerError(err).ToResponse())
+					return
+				}
+			}
+
+			c.JSON(200, entities.NewSuccessResponse(overview))
+		})
+	}
+}
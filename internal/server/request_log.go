@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// routes changing installed plugins are rare and always worth a log, operators may override them
+var defaultRouteSampleRates = map[string]float64{
+	"/plugin/:tenant_id/management/install":   1,
+	"/plugin/:tenant_id/management/uninstall": 1,
+}
+
+// requestSampler decides whether a request is logged by the rate of the longest route prefix it matches,
+// requests matching no prefix are logged at the default rate
+type requestSampler struct {
+	defaultRate float64
+	routeRates  map[string]float64
+	random      func() float64
+}
+
+// parseRouteSampleRates parses overrides like `/e/:hook_id=0.01,/plugin/:tenant_id/management/install=1`
+func parseRouteSampleRates(overrides string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for _, override := range strings.Split(overrides, ",") {
+		override = strings.TrimSpace(override)
+		if override == "" {
+			continue
+		}
+
+		route, rate, ok := strings.Cut(override, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid route sample rate %q, expected route=rate", override)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil || value < 0 || value > 1 {
+			return nil, fmt.Errorf("invalid sample rate of route %s: %s", route, rate)
+		}
+		rates[strings.TrimSpace(route)] = value
+	}
+	return rates, nil
+}
+
+func newRequestSampler(defaultRate float64, overrides string) (*requestSampler, error) {
+	rates, err := parseRouteSampleRates(overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	routeRates := map[string]float64{}
+	for route, rate := range defaultRouteSampleRates {
+		routeRates[route] = rate
+	}
+	for route, rate := range rates {
+		routeRates[route] = rate
+	}
+
+	return &requestSampler{
+		defaultRate: defaultRate,
+		routeRates:  routeRates,
+		random:      rand.Float64,
+	}, nil
+}
+
+func (s *requestSampler) rate(route string) float64 {
+	rate := s.defaultRate
+	matched := -1
+	for prefix, prefixRate := range s.routeRates {
+		if len(prefix) > matched && strings.HasPrefix(route, prefix) {
+			rate = prefixRate
+			matched = len(prefix)
+		}
+	}
+	return rate
+}
+
+func (s *requestSampler) sampled(route string) bool {
+	rate := s.rate(route)
+	return rate >= 1 || (rate > 0 && s.random() < rate)
+}
+
+// sampledRequestLogger logs a sample of requests with their route, tenant, status, latency and sizes
+func sampledRequestLogger(sampler *requestSampler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startedAt := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		if !sampler.sampled(route) {
+			return
+		}
+
+		log.FromContext(c.Request.Context()).
+			With(log.FIELD_TENANT_ID, c.Param("tenant_id")).
+			Info(
+				"request %s %s status=%d latency=%dms request_size=%d response_size=%d",
+				c.Request.Method,
+				route,
+				c.Writer.Status(),
+				time.Since(startedAt).Milliseconds(),
+				max(c.Request.ContentLength, 0),
+				max(c.Writer.Size(), 0),
+			)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func TestRequestSamplerMatchesLongestPrefix(t *testing.T) {
+	sampler, err := newRequestSampler(0.1, "/e/:hook_id=0.01, /plugin/:tenant_id/management/install/batch=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]float64{
+		"/plugin/:tenant_id/dispatch/tool/invoke":       0.1,
+		"/e/:hook_id/*path":                             0.01,
+		"/plugin/:tenant_id/management/install/upgrade": 1,
+		"/plugin/:tenant_id/management/uninstall":       1,
+		"/plugin/:tenant_id/management/install/batch":   0,
+		"/plugin/:tenant_id/management/uninstall/batch": 1,
+	}
+	for route, expected := range cases {
+		if rate := sampler.rate(route); rate != expected {
+			t.Errorf("rate of %s should be %v, got %v", route, expected, rate)
+		}
+	}
+}
+
+func TestRequestSamplerSamples(t *testing.T) {
+	sampler, err := newRequestSampler(0.5, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampler.random = func() float64 { return 0.4 }
+	if !sampler.sampled("/health/check") {
+		t.Fatal("request below the rate should be sampled")
+	}
+	sampler.random = func() float64 { return 0.6 }
+	if sampler.sampled("/health/check") {
+		t.Fatal("request above the rate should not be sampled")
+	}
+}
+
+func TestRequestSamplerRateZero(t *testing.T) {
+	rate := 0.0
+	config := app.Config{RequestLogSampleRate: &rate}
+	config.SetDefault()
+
+	sampler, err := newRequestSampler(*config.RequestLogSampleRate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sampler.sampled("/health/check") {
+		t.Fatal("no request should be sampled by rate 0")
+	}
+	if !sampler.sampled("/plugin/:tenant_id/management/uninstall") {
+		t.Fatal("routes overridden should be sampled by their own rates")
+	}
+}
+
+func TestParseRouteSampleRates(t *testing.T) {
+	for _, overrides := range []string{"/e/:hook_id", "/e/:hook_id=2", "/e/:hook_id=abc"} {
+		if _, err := parseRouteSampleRates(overrides); err == nil {
+			t.Errorf("%q should be rejected", overrides)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/api_token"
+)
+
+type scopeRule struct {
+	prefix string
+	method string // empty matches all methods
+	scope  api_token.Scope
+}
+
+// scopeRules map routes to the scopes api tokens need, the first matching rule wins,
+// routes matching none of them are only accessible by the server key
+var scopeRules = []scopeRule{
+	{"/plugin/:tenant_id/dispatch/", "", api_token.SCOPE_PLUGINS_INVOKE},
+	{"/plugin/:tenant_id/debugging/", "", api_token.SCOPE_PLUGINS_DEBUG},
+	{"/plugin/:tenant_id/endpoint/", "", api_token.SCOPE_ENDPOINTS_MANAGE},
+	{"/tools/:tenant_id/", "", api_token.SCOPE_TOOLS_INVOKE},
+	{"/mcp/:tenant_id/", "", api_token.SCOPE_PLUGINS_INVOKE},
+	{"/openai/:tenant_id/", "", api_token.SCOPE_PLUGINS_INVOKE},
+	{"/plugin/:tenant_id/asset/", "", api_token.SCOPE_PLUGINS_READ},
+	{"/plugin/:tenant_id/management/install/", http.MethodPost, api_token.SCOPE_PLUGINS_INSTALL},
+	{"/plugin/:tenant_id/management/uninstall", http.MethodPost, api_token.SCOPE_PLUGINS_INSTALL},
+	{"/plugin/:tenant_id/management/", http.MethodGet, api_token.SCOPE_PLUGINS_READ},
+	{"/plugin/:tenant_id/management/", http.MethodPost, api_token.SCOPE_PLUGINS_MANAGE},
+	{"/admin/tokens", "", api_token.SCOPE_TOKENS_MANAGE},
+	{"/admin/state/plan", http.MethodPost, api_token.SCOPE_ADMIN_READ}, // plans change nothing
+	{"/admin/", http.MethodGet, api_token.SCOPE_ADMIN_READ},
+	{"/admin/", http.MethodPost, api_token.SCOPE_ADMIN_WRITE},
+	{"/cluster/", http.MethodGet, api_token.SCOPE_ADMIN_READ},
+	{"/cluster/", http.MethodPost, api_token.SCOPE_ADMIN_WRITE},
+	{"/slo/", http.MethodGet, api_token.SCOPE_ADMIN_READ},
+	{"/slo/", http.MethodPost, api_token.SCOPE_ADMIN_WRITE},
+	{"/debug/pprof/", "", api_token.SCOPE_ADMIN_READ},
+}
+
+func requiredScope(method string, route string) api_token.Scope {
+	for _, rule := range scopeRules {
+		if strings.HasPrefix(route, rule.prefix) && (rule.method == "" || rule.method == method) {
+			return rule.scope
+		}
+	}
+	return api_token.SCOPE_TOKENS_MANAGE
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/api_token"
+)
+
+func TestRequiredScope(t *testing.T) {
+	cases := []struct {
+		method string
+		route  string
+		scope  api_token.Scope
+	}{
+		{http.MethodPost, "/plugin/:tenant_id/dispatch/llm/invoke", api_token.SCOPE_PLUGINS_INVOKE},
+		{http.MethodPost, "/plugin/:tenant_id/management/install/identifiers", api_token.SCOPE_PLUGINS_INSTALL},
+		{http.MethodGet, "/plugin/:tenant_id/management/install/tasks", api_token.SCOPE_PLUGINS_READ},
+		{http.MethodPost, "/plugin/:tenant_id/management/uninstall/batch", api_token.SCOPE_PLUGINS_INSTALL},
+		{http.MethodPost, "/plugin/:tenant_id/management/policy/check", api_token.SCOPE_PLUGINS_MANAGE},
+		{http.MethodGet, "/plugin/:tenant_id/endpoint/list", api_token.SCOPE_ENDPOINTS_MANAGE},
+		{http.MethodPost, "/tools/:tenant_id/invoke", api_token.SCOPE_TOOLS_INVOKE},
+		{http.MethodGet, "/admin/overview", api_token.SCOPE_ADMIN_READ},
+		{http.MethodPost, "/admin/tokens/create", api_token.SCOPE_TOKENS_MANAGE},
+		{http.MethodPost, "/admin/state/plan", api_token.SCOPE_ADMIN_READ},
+		{http.MethodPost, "/admin/state/apply", api_token.SCOPE_ADMIN_WRITE},
+		{http.MethodPost, "/admin/policy/update", api_token.SCOPE_ADMIN_WRITE},
+		{http.MethodPost, "/admin/policy/tenant/update", api_token.SCOPE_ADMIN_WRITE},
+		{http.MethodPost, "/cluster/nodes/:id/drain", api_token.SCOPE_ADMIN_WRITE},
+		{http.MethodGet, "/unknown", api_token.SCOPE_TOKENS_MANAGE},
+	}
+
+	for _, c := range cases {
+		if scope := requiredScope(c.method, c.route); scope != c.scope {
+			t.Errorf("%s %s requires %s, got %s", c.method, c.route, c.scope, scope)
+		}
+	}
+}
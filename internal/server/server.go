@@ -1,17 +1,43 @@
 package server
 
 import (
+	"time"
+
 	"github.com/getsentry/sentry-go"
 	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/admin_auth"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_schema"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/credential_audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/credential_pool"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/egress"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_template"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/error_report"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/event_export"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/guardrail"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/model_cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/persistence"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/secrets"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/singleton_job"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/slo"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/slow_log"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_encryption"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/usage_analytics"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/webhook"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss/local"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss/s3"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss/tencent_cos"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/redact"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 )
 
@@ -50,7 +76,36 @@ func initOSS(config *app.Config) oss.OSS {
 	return storage
 }
 
+// applyReloadedSettings applies reloadable settings copied by packages at initialization,
+// others are read through config_loader.Live on each use
+func applyReloadedSettings(config *app.Config) error {
+	log.SetLevel(config.LogLevel)
+	slow_log.Configure(config)
+	guardrail.Init(config)
+	plugin_daemon.InitToolOutputSchema(config)
+	if err := webhook.Configure(config); err != nil {
+		return err
+	}
+	return egress.Configure(config)
+}
+
 func (app *App) Run(config *app.Config) {
+	// init logger
+	log.SetFormat(config.LogFormat)
+	log.SetLevel(config.LogLevel)
+
+	// dump the effective config and probe urls the daemon calls
+	config_schema.Diagnose(config)
+
+	// run on a FIPS 140 validated crypto provider only
+	if config.FIPSModeEnabled {
+		if err := encryption.RequireFIPS(); err != nil {
+			log.Panic("fips mode: %s", err.Error())
+		}
+		provider, _ := encryption.FIPSProvider()
+		log.Info("fips mode enabled, crypto provider: %s", provider)
+	}
+
 	// init routine pool
 	if config.SentryEnabled {
 		routine.InitPool(config.RoutinePoolSize, sentry.ClientOptions{
@@ -64,9 +119,72 @@ func (app *App) Run(config *app.Config) {
 		routine.InitPool(config.RoutinePoolSize)
 	}
 
+	// bound tasks of subsystems by their own pools
+	if err := routine.ConfigurePools(config.RoutinePools); err != nil {
+		log.Panic("configure routine pools failed: %s", err.Error())
+	}
+
+	// report crash loops and failed invocations of plugins
+	error_report.Init(config)
+
 	// init db
 	db.Init(config)
 
+	// refresh config secrets read from secret managers
+	secrets.Launch(config)
+
+	// load keys encrypting stored secrets
+	keyring.Init(config)
+
+	// encrypt secret settings by keys of tenants
+	tenant_encryption.Init(config)
+
+	// mask secrets printed by plugins
+	redact.SetEnabled(*config.PluginOutputRedactionEnabled)
+
+	// record decryptions of credentials
+	credential_audit.Init(config)
+
+	// filter outbound connections of plugins by their network permission
+	egress.Init(config)
+
+	// init webhook delivery
+	webhook.Init(config)
+
+	// encrypt values of endpoints materialized from templates
+	endpoint_template.Init()
+
+	// take credentials of model invocations from pools of keys
+	credential_pool.Init()
+
+	// reply deterministic invocations of models from cached responses
+	model_cache.Init(config)
+
+	// inspect inputs and outputs of tools by guardrails of tenants
+	guardrail.Init(config)
+
+	// validate outputs of tools against their output schemas
+	plugin_daemon.InitToolOutputSchema(config)
+
+	// sign in operators by oidc or ldap
+	admin_auth.Init(config)
+
+	// export events of the bus to kafka or nats
+	event_export.Init(config)
+
+	// keep slow invocations
+	slow_log.Init(config)
+
+	// track objectives of plugins
+	slo.Init(config)
+
+	// roll up usage of plugins
+	usage_analytics.Init(config)
+
+	// reload safe settings on SIGHUP or once the config file changes
+	config_loader.Subscribe(applyReloadedSettings)
+	config_loader.Watch(config)
+
 	// init oss
 	oss := initOSS(config)
 
@@ -76,21 +194,70 @@ func (app *App) Run(config *app.Config) {
 	// create cluster
 	app.cluster = cluster.NewCluster(config, manager)
 
+	// serve over tls, nodes talk to each other over tls as well
+	if config.ServerTLSEnabled {
+		app.initTLS(config)
+		app.cluster.UseTLS(app.tls.ClientConfig(tlsMinVersion(config)))
+	}
+
 	// register plugin lifetime event
 	manager.AddPluginRegisterHandler(app.cluster.RegisterPlugin)
 
+	// place local plugins on a subset of nodes
+	if app.cluster.PlacementEnabled() {
+		manager.SetPluginPlacement(app.cluster)
+	}
+
 	// init manager
 	manager.Launch(config)
 
+	// trace requests, plugin invocations and db/redis calls
+	if config.TracingEnabled {
+		app.initTracing(config)
+	}
+
+	// inject faults into db and redis calls and plugins for resilience tests
+	if config.FaultInjectionEnabled {
+		app.initFaultInjection(config)
+	}
+
+	// run singleton jobs registered by the manager on one node of the cluster
+	singleton_job.Launch(
+		app.cluster.ID(), time.Duration(config.SingletonJobLeaseDuration)*time.Second, app.cluster.IsFenced,
+	)
+
 	// init persistence
 	persistence.InitPersistence(oss, config)
 
+	// relay events of sessions served by this node from other nodes
+	if err := session_manager.LaunchRelay(app.cluster.ID(), func(nodeId string) bool {
+		return app.cluster.NodeSupports(nodeId, cluster.PROTOCOL_VERSION_SESSION_RELAY)
+	}); err != nil {
+		log.Panic("launch session relay failed: %s", err.Error())
+	}
+
 	// launch cluster
 	app.cluster.Launch()
 
+	// expose metrics of sessions and pools
+	if config.MetricsEnabled {
+		if err := app.registerMetrics(); err != nil {
+			log.Panic("register metrics failed: %s", err.Error())
+		}
+		metrics.SubscribeLifecycleEvents()
+	}
+
+	// recover stuck subsystems or exit with diagnostics
+	app.launchWatchdog(config)
+
 	// start http server
 	app.server(config)
 
+	// serve diagnostics on the admin port
+	if config.AdminPort != 0 {
+		app.adminServer(config)
+	}
+
 	// block
 	select {}
 }
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/support_bundle"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// SupportBundle streams a redacted zip archive of the state of the node, along with the cluster and plugins
+// installed, to attach to bug reports
+func (app *App) SupportBundle(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filename := fmt.Sprintf("dify-plugin-daemon-support-%s.zip", time.Now().UTC().Format("20060102-150405"))
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Header("Cache-Control", "no-store")
+
+		err := support_bundle.Write(c.Writer, config,
+			support_bundle.Extra{Name: "installed.json", Collect: func() (any, error) {
+				return countInstalledPlugins()
+			}},
+			support_bundle.Extra{Name: "cluster.json", Collect: func() (any, error) {
+				return app.clusterOverview()
+			}},
+		)
+		if err != nil {
+			// headers are sent already, the archive is left truncated
+			log.Error("write support bundle failed: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+)
+
+func tlsMinVersion(config *app.Config) uint16 {
+	if config.ServerTLSMinVersion == "1.3" {
+		return tls.VersionTLS13
+	}
+	return tls.VersionTLS12
+}
+
+// initTLS loads the certificate of the server port and watches it for rotations
+func (app *App) initTLS(config *app.Config) {
+	reloader, err := network.NewCertReloader(
+		config.ServerTLSCertFile, config.ServerTLSKeyFile, config.ServerTLSClientCAFile,
+	)
+	if err != nil {
+		log.Panic("failed to load tls certificate: %s", err.Error())
+	}
+	reloader.Watch(time.Duration(config.ServerTLSReloadInterval) * time.Second)
+
+	app.tls = reloader
+}
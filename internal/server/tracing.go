@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// initTracing registers the tracer provider and traces db and redis calls, it's called once
+// both of them are initialized
+func (app *App) initTracing(config *app.Config) {
+	// the daemon never exits gracefully, spans are flushed by the batcher periodically
+	if _, err := tracing.Init(*config.TracingSampleRate); err != nil {
+		log.Panic("init tracing failed: %s", err.Error())
+	}
+
+	if err := db.EnableTracing(); err != nil {
+		log.Panic("enable db tracing failed: %s", err.Error())
+	}
+
+	if err := cache.EnableTracing(); err != nil {
+		log.Panic("enable redis tracing failed: %s", err.Error())
+	}
+}
+
+// requestTracing starts a server span for each request, continuing the trace propagated by the caller,
+// spans are named by the route they matched to keep their names bounded
+func requestTracing() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		spanCtx := tracing.Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+		spanCtx, span := tracing.Start(spanCtx, fmt.Sprintf("%s %s", ctx.Request.Method, route),
+			trace.SpanKindServer,
+			attribute.String("http.request.method", ctx.Request.Method),
+			attribute.String("http.route", route),
+		)
+		defer span.End()
+
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			tracing.Fail(span, fmt.Errorf("request failed with status %d", status))
+		}
+	}
+}
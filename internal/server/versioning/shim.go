@@ -0,0 +1,188 @@
+package versioning
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// transforms turn envelopes written by routes, which are in the shape of v1, into the shape of a version,
+// so that routes are written once and old versions keep their shapes while newer ones evolve
+var transforms = map[Version]func(envelope []byte) []byte{
+	V2: structuredErrorEnvelope,
+}
+
+type structuredError struct {
+	Type    string         `json:"type"`
+	Message string         `json:"message"`
+	Args    map[string]any `json:"args,omitempty"`
+}
+
+type structuredEnvelope struct {
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Error   *structuredError `json:"error,omitempty"`
+	Data    json.RawMessage  `json:"data"`
+}
+
+// structuredErrorEnvelope replaces the error encoded in the message of a failed envelope by an error object,
+// `{"code":-404,"message":"{\"message\":\"plugin not found\",\"error_type\":\"PluginNotFoundError\"}"}` becomes
+// `{"code":-404,"message":"plugin not found","error":{"type":"PluginNotFoundError","message":"plugin not found"}}`
+func structuredErrorEnvelope(envelope []byte) []byte {
+	var response struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+		Data    json.RawMessage `json:"data"`
+	}
+	// envelopes transformed by the node the request was redirected to are left as they are
+	if err := json.Unmarshal(envelope, &response); err != nil || response.Code == 0 || response.Error != nil {
+		return envelope
+	}
+
+	var legacy struct {
+		Message   string         `json:"message"`
+		ErrorType string         `json:"error_type"`
+		Args      map[string]any `json:"args"`
+	}
+	if err := json.Unmarshal([]byte(response.Message), &legacy); err != nil || legacy.ErrorType == "" {
+		legacy.Message = response.Message
+		legacy.ErrorType = "unknown"
+		legacy.Args = nil
+	}
+
+	transformed, err := json.Marshal(structuredEnvelope{
+		Code:    response.Code,
+		Message: legacy.Message,
+		Error:   &structuredError{Type: legacy.ErrorType, Message: legacy.Message, Args: legacy.Args},
+		Data:    response.Data,
+	})
+	if err != nil {
+		return envelope
+	}
+	return transformed
+}
+
+type shimMode int
+
+const (
+	shimModePending shimMode = iota
+	// json responses are buffered and transformed as a whole once the route returns
+	shimModeJSON
+	// each server sent event is transformed once it's complete
+	shimModeEvents
+	shimModeRaw
+)
+
+// shimWriter transforms envelopes written by routes, responses other than json and
+// server sent events are passed through
+type shimWriter struct {
+	http.ResponseWriter
+	transform func([]byte) []byte
+	mode      shimMode
+	status    int
+	buffer    bytes.Buffer
+}
+
+func shim(version Version, w http.ResponseWriter) *shimWriter {
+	return &shimWriter{ResponseWriter: w, transform: transforms[version]}
+}
+
+func (w *shimWriter) WriteHeader(statusCode int) {
+	if w.mode != shimModePending {
+		return
+	}
+	w.status = statusCode
+
+	contentType := w.Header().Get("Content-Type")
+	switch {
+	case w.transform == nil:
+		w.mode = shimModeRaw
+	case strings.HasPrefix(contentType, "application/json"):
+		w.mode = shimModeJSON
+		// the length changes once the body is transformed
+		w.Header().Del("Content-Length")
+		return
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		w.mode = shimModeEvents
+	default:
+		w.mode = shimModeRaw
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *shimWriter) Write(data []byte) (int, error) {
+	if w.mode == shimModePending {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	switch w.mode {
+	case shimModeJSON:
+		return w.buffer.Write(data)
+	case shimModeEvents:
+		w.buffer.Write(data)
+		if err := w.writeEvents(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// writeEvents writes complete events in the buffer, incomplete ones are kept until the rest is written
+func (w *shimWriter) writeEvents() error {
+	for {
+		buffered := w.buffer.Bytes()
+		end := bytes.Index(buffered, []byte("\n\n"))
+		if end == -1 {
+			return nil
+		}
+
+		event := buffered[:end]
+		if data, ok := bytes.CutPrefix(event, []byte("data: ")); ok {
+			event = append([]byte("data: "), w.transform(data)...)
+		} else {
+			event = bytes.Clone(event)
+		}
+		w.buffer.Next(end + 2)
+
+		if _, err := w.ResponseWriter.Write(append(event, '\n', '\n')); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *shimWriter) Flush() {
+	// buffered json is written once the route returns
+	if w.mode == shimModeJSON {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *shimWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *shimWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish writes what's buffered once the route returns
+func (w *shimWriter) finish() {
+	switch w.mode {
+	case shimModeJSON:
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.transform(w.buffer.Bytes()))
+	case shimModeEvents:
+		if w.buffer.Len() > 0 {
+			w.ResponseWriter.Write(w.buffer.Bytes())
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package versioning
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+)
+
+type Version string
+
+const (
+	V1 Version = "v1"
+	V2 Version = "v2"
+
+	// routes without a version prefix are served as v1, which is what the released versions of Dify call
+	DEFAULT = V1
+	LATEST  = V2
+)
+
+var versions = []Version{V1, V2}
+
+func Valid(version Version) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Deprecation marks a version as deprecated, the routes of the version are removed after the sunset if it's set
+type Deprecation struct {
+	Sunset time.Time
+}
+
+// ParseDeprecations parses deprecated versions like `v1=2027-06-30,v2`, dates are sunsets of the versions
+func ParseDeprecations(deprecations string) (map[Version]Deprecation, error) {
+	result := map[Version]Deprecation{}
+	for _, deprecation := range strings.Split(deprecations, ",") {
+		deprecation = strings.TrimSpace(deprecation)
+		if deprecation == "" {
+			continue
+		}
+
+		version, sunset, hasSunset := strings.Cut(deprecation, "=")
+		version = strings.TrimSpace(version)
+		if !Valid(Version(version)) {
+			return nil, fmt.Errorf("unknown api version %q", version)
+		}
+
+		d := Deprecation{}
+		if hasSunset {
+			date, err := time.Parse(time.DateOnly, strings.TrimSpace(sunset))
+			if err != nil {
+				return nil, fmt.Errorf("invalid sunset of api version %s: %s", version, sunset)
+			}
+			d.Sunset = date
+		}
+		result[Version(version)] = d
+	}
+	return result, nil
+}
+
+// Router serves /v1/... and /v2/... by the routes of the handler without the prefix, the version is
+// passed to routes in the X-Api-Version header, responses are turned into the shape of the version by its shim,
+// unversioned requests are served as the version of the header, or v1 if it's not set
+type Router struct {
+	handler      http.Handler
+	deprecations map[Version]Deprecation
+}
+
+func NewRouter(handler http.Handler, deprecations map[Version]Deprecation) *Router {
+	return &Router{handler: handler, deprecations: deprecations}
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	version, path := splitVersion(request.URL.Path)
+	if version == "" {
+		version = Version(request.Header.Get(constants.X_API_VERSION))
+		if !Valid(version) {
+			version = DEFAULT
+		}
+	} else {
+		request.URL.Path = path
+		if request.URL.RawPath != "" {
+			_, request.URL.RawPath = splitVersion(request.URL.RawPath)
+		}
+	}
+
+	request.Header.Set(constants.X_API_VERSION, string(version))
+	w.Header().Set(constants.X_API_VERSION, string(version))
+	if deprecation, ok := r.deprecations[version]; ok {
+		w.Header().Set("Deprecation", "true")
+		if !deprecation.Sunset.IsZero() {
+			w.Header().Set("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if version != LATEST {
+			w.Header().Set("Link", fmt.Sprintf(`</%s%s>; rel="successor-version"`, LATEST, request.URL.Path))
+		}
+	}
+
+	writer := shim(version, w)
+	defer writer.finish()
+	r.handler.ServeHTTP(writer, request)
+}
+
+// FromRequest returns the version a request is served as
+func FromRequest(request *http.Request) Version {
+	version := Version(request.Header.Get(constants.X_API_VERSION))
+	if !Valid(version) {
+		return DEFAULT
+	}
+	return version
+}
+
+// splitVersion splits /v2/plugin/... into v2 and /plugin/..., the version is empty if the path is not versioned
+func splitVersion(path string) (Version, string) {
+	segment, rest, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !Valid(Version(segment)) {
+		return "", path
+	}
+	return Version(segment), "/" + rest
+}
@@ -0,0 +1,120 @@
+package versioning
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+)
+
+func TestParseDeprecations(t *testing.T) {
+	deprecations, err := ParseDeprecations("v1=2027-06-30, v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deprecations[V1].Sunset.Equal(time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected sunset %v", deprecations[V1].Sunset)
+	}
+	if _, ok := deprecations[V2]; !ok {
+		t.Fatal("v2 should be deprecated")
+	}
+
+	for _, invalid := range []string{"v3", "v1=tomorrow"} {
+		if _, err := ParseDeprecations(invalid); err == nil {
+			t.Fatalf("%s should be rejected", invalid)
+		}
+	}
+}
+
+func TestRouterStripsVersions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(string(FromRequest(r)) + " " + r.URL.Path))
+	})
+	deprecations := map[Version]Deprecation{V1: {Sunset: time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)}}
+	router := NewRouter(handler, deprecations)
+
+	serve := func(path string, header string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			request.Header.Set(constants.X_API_VERSION, header)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	cases := []struct {
+		path   string
+		header string
+		want   string
+	}{
+		{"/plugin/tenant/management/list", "", "v1 /plugin/tenant/management/list"},
+		{"/v1/plugin/tenant/management/list", "", "v1 /plugin/tenant/management/list"},
+		{"/v2/plugin/tenant/management/list", "", "v2 /plugin/tenant/management/list"},
+		{"/plugin/tenant/management/list", "v2", "v2 /plugin/tenant/management/list"},
+		{"/v3/plugin", "", "v1 /v3/plugin"},
+	}
+	for _, c := range cases {
+		if got := serve(c.path, c.header).Body.String(); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.path, c.want, got)
+		}
+	}
+
+	deprecated := serve("/v1/healthz", "")
+	if deprecated.Header().Get("Deprecation") != "true" ||
+		deprecated.Header().Get("Sunset") != "Wed, 30 Jun 2027 00:00:00 GMT" ||
+		deprecated.Header().Get("Link") != `</v2/healthz>; rel="successor-version"` {
+		t.Fatalf("unexpected deprecation headers %v", deprecated.Header())
+	}
+	if serve("/v2/healthz", "").Header().Get("Deprecation") != "" {
+		t.Fatal("v2 is not deprecated")
+	}
+}
+
+func TestShimStructuresErrors(t *testing.T) {
+	legacyError := `{"code":-404,"message":"{\"message\":\"plugin not found\",\"error_type\":\"PluginNotFoundError\",\"args\":null}","data":null}`
+	structured := `{"code":-404,"message":"plugin not found","error":{"type":"PluginNotFoundError","message":"plugin not found"},"data":null}`
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(200)
+			w.Write([]byte(`data: {"code":0,"message":"success","data":1}` + "\n\n"))
+			w.Write([]byte("data: "))
+			w.Write([]byte(legacyError))
+			w.Write([]byte("\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(404)
+		w.Write([]byte(legacyError))
+	})
+	router := NewRouter(handler, nil)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	if got := serve("/v1/json").Body.String(); got != legacyError {
+		t.Fatalf("v1 should keep the legacy envelope, got %s", got)
+	}
+	recorder := serve("/v2/json")
+	if recorder.Code != 404 || recorder.Body.String() != structured {
+		t.Fatalf("v2 should structure the error, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	expected := `data: {"code":0,"message":"success","data":1}` + "\n\n" + "data: " + structured + "\n\n"
+	if got := serve("/v2/stream").Body.String(); got != expected {
+		t.Fatalf("events should be transformed, got %s", got)
+	}
+
+	// structured envelopes are left as they are
+	if got := string(structuredErrorEnvelope([]byte(structured))); got != structured {
+		t.Fatalf("structured envelope should be kept, got %s", got)
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/watchdog"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+// launchWatchdog checks the cluster lifetime loop, redis subscriptions and the database pool
+func (app *App) launchWatchdog(config *app.Config) {
+	loopTimeout := time.Duration(config.WatchdogClusterLoopTimeout) * time.Second
+
+	watchdog.Register(watchdog.Probe{
+		Name: "cluster_lifetime",
+		Check: func(ctx context.Context) error {
+			return app.cluster.CheckLifetime(loopTimeout)
+		},
+	})
+	watchdog.Register(watchdog.Probe{
+		Name: "redis_subscriptions",
+		Check: func(ctx context.Context) error {
+			if dead := cache.DeadSubscriptions(); len(dead) > 0 {
+				return fmt.Errorf("subscriptions of %s are dead", strings.Join(dead, ", "))
+			}
+			return nil
+		},
+		Recover: func() error {
+			cache.RestartDeadSubscriptions()
+			return nil
+		},
+	})
+	// connections held by stuck queries can't be taken back, the failure is escalated without recovering
+	watchdog.Register(watchdog.Probe{
+		Name:  "db_pool",
+		Check: db.CheckPool,
+	})
+
+	watchdog.Launch(config, func() {
+		// stop taking new sessions, the node is removed from the cluster once it stops updating its status
+		app.cluster.Close()
+	})
+}
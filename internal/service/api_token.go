@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/api_token"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+func ListAPITokens() *entities.Response {
+	tokens, err := db.GetAll[models.APIToken](
+		db.OrderBy("created_at", true),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(tokens)
+}
+
+// CreateAPIToken creates a token granted the scopes, the token is only returned here,
+// only its hash is stored, tokens with a tenant id are bound to the tenant
+func CreateAPIToken(
+	name string,
+	tenant_id string,
+	scopes []string,
+	rate_limit int,
+	expires_at *time.Time,
+) *entities.Response {
+	for _, scope := range scopes {
+		if !api_token.ValidScope(scope) {
+			return exception.BadRequestError(fmt.Errorf("unknown scope: %s", scope)).ToResponse()
+		}
+	}
+	if expires_at != nil && expires_at.Before(time.Now()) {
+		return exception.BadRequestError(errors.New("expires_at is in the past")).ToResponse()
+	}
+
+	token, hash, prefix, err := api_token.Generate()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	record := models.APIToken{
+		Name:      name,
+		TokenHash: hash,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		TenantID:  tenant_id,
+		RateLimit: rate_limit,
+		ExpiresAt: expires_at,
+	}
+	if err := db.Create(&record); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"api_token": record,
+		"token":     token,
+	})
+}
+
+// RevokeAPIToken revokes the token, it's rejected by all nodes once their caches expire
+func RevokeAPIToken(token_id string) *entities.Response {
+	record, err := db.GetOne[models.APIToken](
+		db.Equal("id", token_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.NotFoundError(errors.New("api token not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	record.Revoked = true
+	if err := db.Update(&record); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	api_token.Invalidate(record.TokenHash)
+
+	return entities.NewSuccessResponse(record)
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
@@ -32,9 +31,8 @@ func baseSSEService[R any](
 		if atomic.LoadInt32(closed) == 1 {
 			return
 		}
-		writer.Write([]byte("data: "))
-		writer.Write(parser.MarshalJsonBytes(data))
-		writer.Write([]byte("\n\n"))
+		// events are encoded into pooled buffers, large chunks don't leave garbage behind
+		stream.WriteEvent(writer, data)
 		writer.Flush()
 	}
 
@@ -50,6 +48,16 @@ func baseSSEService[R any](
 		"module":   "service",
 		"function": "baseSSEService",
 	}, func() {
+		// done is closed even if the relay panics, otherwise the request hangs until the timeout
+		defer func() {
+			if atomic.CompareAndSwapInt32(doneClosed, 0, 1) {
+				close(done)
+			}
+		}()
+		defer routine.ReportPanic(func(err error) {
+			writeData(exception.InternalServerError(err).ToResponse())
+		})
+
 		for pluginDaemonResponse.Next() {
 			chunk, err := pluginDaemonResponse.Read()
 			if err != nil {
@@ -58,10 +66,6 @@ func baseSSEService[R any](
 			}
 			writeData(entities.NewSuccessResponse(chunk))
 		}
-
-		if atomic.CompareAndSwapInt32(doneClosed, 0, 1) {
-			close(done)
-		}
 	})
 
 	timer := time.NewTimer(time.Duration(max_timeout_seconds) * time.Second)
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+type BatchPluginOperationResult struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	Success                bool                                   `json:"success"`
+	// TaskID is the installation task of the plugin, only set by batch install
+	TaskID       string `json:"task_id,omitempty"`
+	AllInstalled bool   `json:"all_installed,omitempty"`
+	Message      string `json:"message"`
+}
+
+// runPluginBatchOperation runs the operation against every plugin with at most `concurrency` operations running
+// at the same time, it blocks until all of them are finished and keeps the results in the order of the input
+func runPluginBatchOperation(
+	concurrency int,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+	operation func(i int, pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier) BatchPluginOperationResult,
+) []BatchPluginOperationResult {
+	results := make([]BatchPluginOperationResult, len(plugin_unique_identifiers))
+	tasks := make([]func(), 0, len(plugin_unique_identifiers))
+	for i, pluginUniqueIdentifier := range plugin_unique_identifiers {
+		tasks = append(tasks, func() {
+			results[i] = operation(i, pluginUniqueIdentifier)
+			results[i].PluginUniqueIdentifier = pluginUniqueIdentifier
+		})
+	}
+
+	done := make(chan struct{})
+	routine.WithMaxRoutine(concurrency, tasks, func() {
+		close(done)
+	})
+	<-done
+
+	return results
+}
+
+// BatchInstallPlugins creates an installation task for each plugin, a failure of one plugin
+// does not affect the others, the status of each plugin is reported separately
+func BatchInstallPlugins(
+	config *app.Config,
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+	source string,
+	metas []map[string]any,
+) *entities.Response {
+	results := runPluginBatchOperation(
+		config.PluginBatchOperationConcurrency,
+		plugin_unique_identifiers,
+		func(i int, pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier) BatchPluginOperationResult {
+			response, err := InstallPluginRuntimeToTenant(
+				config,
+				tenant_id,
+				[]plugin_entities.PluginUniqueIdentifier{pluginUniqueIdentifier},
+				source,
+				[]map[string]any{metas[i]},
+				installPluginToTenant(config, tenant_id, source),
+			)
+			if err != nil {
+				return BatchPluginOperationResult{
+					Message: installPluginError(err).Error(),
+				}
+			}
+
+			result := BatchPluginOperationResult{
+				Success:      true,
+				TaskID:       response.TaskID,
+				AllInstalled: response.AllInstalled,
+				Message:      "Installing",
+			}
+			if response.AllInstalled {
+				result.Message = "Installed"
+			}
+			return result
+		},
+	)
+
+	return entities.NewSuccessResponse(results)
+}
+
+// BatchUninstallPlugins uninstalls the plugins from the tenant, plugins not installed are reported as failed
+func BatchUninstallPlugins(
+	config *app.Config,
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+) *entities.Response {
+	results := runPluginBatchOperation(
+		config.PluginBatchOperationConcurrency,
+		plugin_unique_identifiers,
+		func(_ int, pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier) BatchPluginOperationResult {
+			installation, err := db.GetOne[models.PluginInstallation](
+				db.Equal("tenant_id", tenant_id),
+				db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
+			)
+			if err == db.ErrDatabaseNotFound {
+				return BatchPluginOperationResult{
+					Message: exception.ErrPluginNotFound().Error(),
+				}
+			}
+			if err != nil {
+				return BatchPluginOperationResult{
+					Message: err.Error(),
+				}
+			}
+
+			if err := uninstallPlugin(tenant_id, &installation); err != nil {
+				return BatchPluginOperationResult{
+					Message: err.Error(),
+				}
+			}
+
+			return BatchPluginOperationResult{
+				Success: true,
+				Message: "Uninstalled",
+			}
+		},
+	)
+
+	return entities.NewSuccessResponse(results)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestRunPluginBatchOperation(t *testing.T) {
+	routine.InitPool(64)
+
+	identifiers := []plugin_entities.PluginUniqueIdentifier{}
+	for i := 0; i < 20; i++ {
+		identifier, err := plugin_entities.NewPluginUniqueIdentifier(
+			fmt.Sprintf("langgenius/plugin_%d:0.0.1@%064d", i, i),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		identifiers = append(identifiers, identifier)
+	}
+
+	running := int32(0)
+	maxRunning := int32(0)
+	results := runPluginBatchOperation(3, identifiers, func(i int, _ plugin_entities.PluginUniqueIdentifier) BatchPluginOperationResult {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			peak := atomic.LoadInt32(&maxRunning)
+			if current <= peak || atomic.CompareAndSwapInt32(&maxRunning, peak, current) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		return BatchPluginOperationResult{
+			Success: i%2 == 0,
+			Message: fmt.Sprintf("%d", i),
+		}
+	})
+
+	if maxRunning > 3 {
+		t.Fatalf("expected at most 3 operations at the same time, got %d", maxRunning)
+	}
+
+	if len(results) != len(identifiers) {
+		t.Fatalf("expected %d results, got %d", len(identifiers), len(results))
+	}
+
+	for i, result := range results {
+		if result.PluginUniqueIdentifier != identifiers[i] {
+			t.Fatalf("result %d belongs to %s, expected %s", i, result.PluginUniqueIdentifier, identifiers[i])
+		}
+		if result.Message != fmt.Sprintf("%d", i) || result.Success != (i%2 == 0) {
+			t.Fatalf("unexpected result %d: %v", i, result)
+		}
+	}
+}
+
+func TestRunPluginBatchOperationEmpty(t *testing.T) {
+	routine.InitPool(64)
+
+	results := runPluginBatchOperation(3, nil, func(int, plugin_entities.PluginUniqueIdentifier) BatchPluginOperationResult {
+		t.Fatal("operation should not be called")
+		return BatchPluginOperationResult{}
+	})
+
+	if len(results) != 0 {
+		t.Fatalf("expected no results, got %v", results)
+	}
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+func GetConfigReloadStatus() *entities.Response {
+	return entities.NewSuccessResponse(config_loader.GetStatus())
+}
+
+// ReloadConfig reloads settings of the node like SIGHUP does, settings of other nodes are not reloaded
+func ReloadConfig() *entities.Response {
+	status, err := config_loader.Reload()
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(status)
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// ListCredentialAccessLogs lists decryptions of credentials, the latest first, filters are ignored if empty
+func ListCredentialAccessLogs(
+	tenant_id string,
+	identity string,
+	caller string,
+	page int,
+	page_size int,
+) *entities.Response {
+	query := []db.GenericQuery{}
+	if tenant_id != "" {
+		query = append(query, db.Equal("tenant_id", tenant_id))
+	}
+	if identity != "" {
+		query = append(query, db.Equal("identity", identity))
+	}
+	if caller != "" {
+		query = append(query, db.Equal("caller", caller))
+	}
+	query = append(query, db.OrderBy("created_at", true), db.Page(page, page_size))
+
+	logs, err := db.GetAll[models.CredentialAccessLog](query...)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(logs)
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/credential_pool"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"gorm.io/gorm"
+)
+
+// withPooledCredentials replaces credentials referencing a credential pool by credentials of a key of the pool
+// before invoking the model
+func withPooledCredentials[R any](
+	session *session_manager.Session,
+	provider string,
+	credentials *requests.Credentials,
+	invoke func() (*stream.Stream[R], error),
+) func() (*stream.Stream[R], error) {
+	return func() (*stream.Stream[R], error) {
+		resolved, err := credential_pool.Resolve(
+			session.ID,
+			session.TenantID,
+			session.PluginUniqueIdentifier.PluginID(),
+			provider,
+			credentials.Credentials,
+		)
+		if err != nil {
+			return nil, err
+		}
+		credentials.Credentials = resolved
+
+		response, err := invoke()
+		if err != nil {
+			credential_pool.Release(session.ID)
+		}
+		return response, err
+	}
+}
+
+// CredentialPool is a pool along with its keys, credentials of keys are masked
+type CredentialPool struct {
+	models.CredentialPool
+	Keys []CredentialPoolKey `json:"keys"`
+}
+
+type CredentialPoolKey struct {
+	models.CredentialPoolKey
+	Credentials      map[string]any `json:"credentials"`
+	CoolingDownUntil *time.Time     `json:"cooling_down_until"`
+}
+
+func ListCredentialPools(tenant_id string) *entities.Response {
+	pools, err := db.GetAll[models.CredentialPool](
+		db.Equal("tenant_id", tenant_id),
+		db.OrderBy("created_at", false),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	result := []CredentialPool{}
+	for _, pool := range pools {
+		keys, err := db.GetAll[models.CredentialPoolKey](
+			db.Equal("pool_id", pool.ID),
+			db.OrderBy("created_at", false),
+		)
+		if err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+
+		item := CredentialPool{CredentialPool: pool, Keys: []CredentialPoolKey{}}
+		for _, key := range keys {
+			credentials, err := credential_pool.Open(&key)
+			if err != nil {
+				return exception.InternalServerError(err).ToResponse()
+			}
+			k := CredentialPoolKey{CredentialPoolKey: key, Credentials: credential_pool.Mask(credentials)}
+			if until, ok := credential_pool.CoolingDownUntil(key.ID); ok {
+				k.CoolingDownUntil = &until
+			}
+			item.Keys = append(item.Keys, k)
+		}
+		result = append(result, item)
+	}
+
+	return entities.NewSuccessResponse(result)
+}
+
+func CreateCredentialPool(pool models.CredentialPool) *entities.Response {
+	_, err := db.GetOne[models.CredentialPool](
+		db.Equal("tenant_id", pool.TenantID),
+		db.Equal("plugin_id", pool.PluginID),
+		db.Equal("provider", pool.Provider),
+		db.Equal("name", pool.Name),
+	)
+	if err == nil {
+		return exception.BadRequestError(fmt.Errorf("credential pool %s already exists", pool.Name)).ToResponse()
+	}
+	if err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if err := db.Create(&pool); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(pool)
+}
+
+func DeleteCredentialPool(tenant_id string, pool_id string) *entities.Response {
+	pool, perr := getCredentialPool(tenant_id, pool_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		if err := db.DeleteByCondition(models.CredentialPoolKey{PoolID: pool.ID}, tx); err != nil {
+			return err
+		}
+		return db.Delete(pool, tx)
+	})
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(true)
+}
+
+func getCredentialPool(tenant_id string, pool_id string) (*models.CredentialPool, exception.PluginDaemonError) {
+	pool, err := db.GetOne[models.CredentialPool](
+		db.Equal("id", pool_id),
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, exception.NotFoundError(errors.New("credential pool not found"))
+	}
+	if err != nil {
+		return nil, exception.InternalServerError(err)
+	}
+	return &pool, nil
+}
+
+// getCredentialPoolKey returns the key if its pool belongs to the tenant
+func getCredentialPoolKey(tenant_id string, key_id string) (*models.CredentialPoolKey, exception.PluginDaemonError) {
+	key, err := db.GetOne[models.CredentialPoolKey](
+		db.Equal("id", key_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, exception.NotFoundError(errors.New("credential pool key not found"))
+	}
+	if err != nil {
+		return nil, exception.InternalServerError(err)
+	}
+	if _, perr := getCredentialPool(tenant_id, key.PoolID); perr != nil {
+		return nil, exception.NotFoundError(errors.New("credential pool key not found"))
+	}
+	return &key, nil
+}
+
+func AddCredentialPoolKey(tenant_id string, pool_id string, key models.CredentialPoolKey, credentials map[string]any) *entities.Response {
+	pool, perr := getCredentialPool(tenant_id, pool_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+	if _, ok := credentials[credential_pool.POOL_REFERENCE]; ok {
+		return exception.BadRequestError(errors.New("keys can't reference credential pools")).ToResponse()
+	}
+
+	key.PoolID = pool.ID
+	if err := credential_pool.Seal(&key, credentials); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	if err := db.Create(&key); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(key)
+}
+
+// UpdateCredentialPoolKey updates the key, credentials are kept if they're not given
+func UpdateCredentialPoolKey(tenant_id string, key_id string, update models.CredentialPoolKey, credentials map[string]any) *entities.Response {
+	key, perr := getCredentialPoolKey(tenant_id, key_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+	if _, ok := credentials[credential_pool.POOL_REFERENCE]; ok {
+		return exception.BadRequestError(errors.New("keys can't reference credential pools")).ToResponse()
+	}
+
+	key.Label = update.Label
+	key.Weight = update.Weight
+	key.CooldownSeconds = update.CooldownSeconds
+	key.Disabled = update.Disabled
+	if credentials != nil {
+		if err := credential_pool.Seal(key, credentials); err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+		// new credentials are not rate limited
+		if err := credential_pool.ResetCooldown(key.ID); err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+	}
+	if err := db.Update(key); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(key)
+}
+
+func RemoveCredentialPoolKey(tenant_id string, key_id string) *entities.Response {
+	key, perr := getCredentialPoolKey(tenant_id, key_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+	if err := db.Delete(key); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(true)
+}
+
+func ResetCredentialPoolKeyCooldown(tenant_id string, key_id string) *entities.Response {
+	key, perr := getCredentialPoolKey(tenant_id, key_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+	if err := credential_pool.ResetCooldown(key.ID); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(true)
+}
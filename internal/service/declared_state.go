@@ -0,0 +1,556 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/secrets"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// A declared state lists plugins and endpoints tenants should have, applying it installs missing plugins,
+// upgrades drifted ones, sets up endpoints and removes whatever is not declared, tenants not listed are
+// left untouched. Values of endpoint settings may reference secret managers like `vault://path#key`, they
+// are resolved while applying, drifts of settings are told by fingerprints of the settings declared.
+
+type DeclaredState struct {
+	Tenants []DeclaredTenant `json:"tenants" validate:"required,dive"`
+}
+
+type DeclaredTenant struct {
+	TenantID string `json:"tenant_id" validate:"required"`
+	// UserID owns endpoints set up for the tenant
+	UserID    string             `json:"user_id"`
+	Plugins   []DeclaredPlugin   `json:"plugins" validate:"dive"`
+	Endpoints []DeclaredEndpoint `json:"endpoints" validate:"dive"`
+}
+
+type DeclaredPlugin struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier" validate:"required"`
+	// Source is the source plugins are installed from, `package` by default
+	Source string         `json:"source"`
+	Meta   map[string]any `json:"meta"`
+}
+
+type DeclaredEndpoint struct {
+	PluginID string         `json:"plugin_id" validate:"required"`
+	Name     string         `json:"name" validate:"required"`
+	Settings map[string]any `json:"settings"`
+	// Enabled is true by default
+	Enabled *bool `json:"enabled"`
+}
+
+type DeclaredAction string
+
+const (
+	DECLARED_ACTION_INSTALL          DeclaredAction = "install"
+	DECLARED_ACTION_UPGRADE          DeclaredAction = "upgrade"
+	DECLARED_ACTION_UNINSTALL        DeclaredAction = "uninstall"
+	DECLARED_ACTION_CREATE_ENDPOINT  DeclaredAction = "create_endpoint"
+	DECLARED_ACTION_UPDATE_ENDPOINT  DeclaredAction = "update_endpoint"
+	DECLARED_ACTION_REMOVE_ENDPOINT  DeclaredAction = "remove_endpoint"
+	DECLARED_ACTION_ENABLE_ENDPOINT  DeclaredAction = "enable_endpoint"
+	DECLARED_ACTION_DISABLE_ENDPOINT DeclaredAction = "disable_endpoint"
+)
+
+type DeclaredChangeStatus string
+
+const (
+	DECLARED_CHANGE_PLANNED DeclaredChangeStatus = "planned"
+	DECLARED_CHANGE_APPLIED DeclaredChangeStatus = "applied"
+	DECLARED_CHANGE_FAILED  DeclaredChangeStatus = "failed"
+)
+
+// DeclaredChange is a change needed to reach the declared state
+type DeclaredChange struct {
+	TenantID string         `json:"tenant_id"`
+	Action   DeclaredAction `json:"action"`
+	PluginID string         `json:"plugin_id"`
+	// From and To are plugin unique identifiers before and after the change
+	From         string               `json:"from,omitempty"`
+	To           string               `json:"to,omitempty"`
+	EndpointName string               `json:"endpoint_name,omitempty"`
+	EndpointID   string               `json:"endpoint_id,omitempty"`
+	Status       DeclaredChangeStatus `json:"status"`
+	Message      string               `json:"message,omitempty"`
+	TaskID       string               `json:"task_id,omitempty"`
+
+	// declared ones the change is made for, they're not reported
+	plugin   *DeclaredPlugin
+	endpoint *DeclaredEndpoint
+	source   string
+}
+
+type DeclaredStateResult struct {
+	Changes []DeclaredChange `json:"changes"`
+	// Converged is true if no change is needed or all of them are applied
+	Converged bool `json:"converged"`
+}
+
+const (
+	// installations of plugins are waited for before endpoints of them are set up
+	declaredStateInstallTimeout = 10 * time.Minute
+	declaredStatePollInterval   = time.Second
+)
+
+func (s *DeclaredState) validate() error {
+	tenants := map[string]bool{}
+	for _, tenant := range s.Tenants {
+		if tenants[tenant.TenantID] {
+			return fmt.Errorf("tenant %s is declared more than once", tenant.TenantID)
+		}
+		tenants[tenant.TenantID] = true
+
+		plugins := map[string]bool{}
+		for i := range tenant.Plugins {
+			plugin := &tenant.Plugins[i]
+			identifier, err := plugin_entities.NewPluginUniqueIdentifier(plugin.PluginUniqueIdentifier.String())
+			if err != nil {
+				return fmt.Errorf("invalid plugin of tenant %s: %w", tenant.TenantID, err)
+			}
+			if plugins[identifier.PluginID()] {
+				return fmt.Errorf("plugin %s of tenant %s is declared more than once", identifier.PluginID(), tenant.TenantID)
+			}
+			plugins[identifier.PluginID()] = true
+		}
+
+		endpoints := map[string]bool{}
+		for _, endpoint := range tenant.Endpoints {
+			if !plugins[endpoint.PluginID] {
+				return fmt.Errorf("endpoint %s of tenant %s belongs to plugin %s which is not declared",
+					endpoint.Name, tenant.TenantID, endpoint.PluginID)
+			}
+			key := endpoint.PluginID + "/" + endpoint.Name
+			if endpoints[key] {
+				return fmt.Errorf("endpoint %s of tenant %s is declared more than once", key, tenant.TenantID)
+			}
+			endpoints[key] = true
+		}
+	}
+	return nil
+}
+
+// settingsFingerprint is the hash of settings as declared, references to secrets are hashed as they are
+// so rotating a secret in the secret manager is not a drift
+func settingsFingerprint(settings map[string]any) string {
+	if settings == nil {
+		settings = map[string]any{}
+	}
+	// keys of maps are sorted by json
+	data, _ := json.Marshal(settings)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// planTenant returns changes making installations and endpoints of the tenant match the declaration,
+// fingerprints are the ones of settings declared by endpoint ids
+func planTenant(
+	tenant DeclaredTenant,
+	installations []models.PluginInstallation,
+	endpoints []models.Endpoint,
+	fingerprints map[string]string,
+) []DeclaredChange {
+	change := func(action DeclaredAction, pluginID string) DeclaredChange {
+		return DeclaredChange{
+			TenantID: tenant.TenantID,
+			Action:   action,
+			PluginID: pluginID,
+			Status:   DECLARED_CHANGE_PLANNED,
+		}
+	}
+
+	installed := map[string]models.PluginInstallation{}
+	for _, installation := range installations {
+		installed[installation.PluginID] = installation
+	}
+
+	declaredPlugins := map[string]bool{}
+	pluginChanges := []DeclaredChange{}
+	for i := range tenant.Plugins {
+		plugin := &tenant.Plugins[i]
+		pluginID := plugin.PluginUniqueIdentifier.PluginID()
+		declaredPlugins[pluginID] = true
+
+		installation, ok := installed[pluginID]
+		if !ok {
+			c := change(DECLARED_ACTION_INSTALL, pluginID)
+			c.To = plugin.PluginUniqueIdentifier.String()
+			c.plugin = plugin
+			pluginChanges = append(pluginChanges, c)
+		} else if installation.PluginUniqueIdentifier != plugin.PluginUniqueIdentifier.String() {
+			c := change(DECLARED_ACTION_UPGRADE, pluginID)
+			c.From = installation.PluginUniqueIdentifier
+			c.To = plugin.PluginUniqueIdentifier.String()
+			c.plugin = plugin
+			c.source = installation.Source
+			pluginChanges = append(pluginChanges, c)
+		}
+	}
+
+	uninstalls := []DeclaredChange{}
+	for _, installation := range installations {
+		if declaredPlugins[installation.PluginID] {
+			continue
+		}
+		c := change(DECLARED_ACTION_UNINSTALL, installation.PluginID)
+		c.From = installation.PluginUniqueIdentifier
+		uninstalls = append(uninstalls, c)
+	}
+	sort.Slice(uninstalls, func(i, j int) bool {
+		return uninstalls[i].PluginID < uninstalls[j].PluginID
+	})
+
+	declaredEndpoints := map[string]*DeclaredEndpoint{}
+	for i := range tenant.Endpoints {
+		endpoint := &tenant.Endpoints[i]
+		declaredEndpoints[endpoint.PluginID+"/"+endpoint.Name] = endpoint
+	}
+
+	// the first endpoint of a name is kept, the others are extras
+	matched := map[string]bool{}
+	removals := []DeclaredChange{}
+	endpointChanges := []DeclaredChange{}
+	for _, endpoint := range endpoints {
+		key := endpoint.PluginID + "/" + endpoint.Name
+		declared, ok := declaredEndpoints[key]
+		if !ok || matched[key] {
+			c := change(DECLARED_ACTION_REMOVE_ENDPOINT, endpoint.PluginID)
+			c.EndpointName = endpoint.Name
+			c.EndpointID = endpoint.ID
+			removals = append(removals, c)
+			continue
+		}
+		matched[key] = true
+
+		if fingerprints[endpoint.ID] != settingsFingerprint(declared.Settings) {
+			c := change(DECLARED_ACTION_UPDATE_ENDPOINT, endpoint.PluginID)
+			c.EndpointName = endpoint.Name
+			c.EndpointID = endpoint.ID
+			c.endpoint = declared
+			endpointChanges = append(endpointChanges, c)
+		}
+
+		enabled := declared.Enabled == nil || *declared.Enabled
+		if enabled != endpoint.Enabled {
+			action := DECLARED_ACTION_DISABLE_ENDPOINT
+			if enabled {
+				action = DECLARED_ACTION_ENABLE_ENDPOINT
+			}
+			c := change(action, endpoint.PluginID)
+			c.EndpointName = endpoint.Name
+			c.EndpointID = endpoint.ID
+			endpointChanges = append(endpointChanges, c)
+		}
+	}
+
+	for i := range tenant.Endpoints {
+		endpoint := &tenant.Endpoints[i]
+		if matched[endpoint.PluginID+"/"+endpoint.Name] {
+			continue
+		}
+		c := change(DECLARED_ACTION_CREATE_ENDPOINT, endpoint.PluginID)
+		c.EndpointName = endpoint.Name
+		c.endpoint = endpoint
+		for _, plugin := range tenant.Plugins {
+			if plugin.PluginUniqueIdentifier.PluginID() == endpoint.PluginID {
+				c.To = plugin.PluginUniqueIdentifier.String()
+			}
+		}
+		endpointChanges = append(endpointChanges, c)
+	}
+
+	// endpoints are removed before plugins they belong to, and set up after plugins are installed
+	changes := append(removals, uninstalls...)
+	changes = append(changes, pluginChanges...)
+	return append(changes, endpointChanges...)
+}
+
+func planDeclaredState(state *DeclaredState) ([]DeclaredChange, error) {
+	changes := []DeclaredChange{}
+	for _, tenant := range state.Tenants {
+		installations, err := db.GetAll[models.PluginInstallation](
+			db.Equal("tenant_id", tenant.TenantID),
+		)
+		if err != nil {
+			return nil, err
+		}
+		endpoints, err := db.GetAll[models.Endpoint](
+			db.Equal("tenant_id", tenant.TenantID),
+			db.OrderBy("created_at", false),
+		)
+		if err != nil {
+			return nil, err
+		}
+		declared, err := db.GetAll[models.DeclaredEndpoint](
+			db.Equal("tenant_id", tenant.TenantID),
+		)
+		if err != nil {
+			return nil, err
+		}
+		fingerprints := map[string]string{}
+		for _, endpoint := range declared {
+			fingerprints[endpoint.EndpointID] = endpoint.Fingerprint
+		}
+
+		changes = append(changes, planTenant(tenant, installations, endpoints, fingerprints)...)
+	}
+	return changes, nil
+}
+
+// PlanDeclaredState reports changes applying the state would make without making them
+func PlanDeclaredState(state *DeclaredState) *entities.Response {
+	if err := state.validate(); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	changes, err := planDeclaredState(state)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(DeclaredStateResult{
+		Changes:   changes,
+		Converged: len(changes) == 0,
+	})
+}
+
+// ApplyDeclaredState makes the changes the plan of the state reports, a failed change does not stop
+// the others, failures are reported by the status of each change
+func ApplyDeclaredState(ctx context.Context, config *app.Config, state *DeclaredState) *entities.Response {
+	if err := state.validate(); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	changes, err := planDeclaredState(state)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	users := map[string]string{}
+	for _, tenant := range state.Tenants {
+		users[tenant.TenantID] = tenant.UserID
+	}
+
+	converged := true
+	for i := range changes {
+		c := &changes[i]
+		if err := applyDeclaredChange(ctx, config, users[c.TenantID], c); err != nil {
+			c.Status = DECLARED_CHANGE_FAILED
+			c.Message = err.Error()
+			converged = false
+		} else {
+			c.Status = DECLARED_CHANGE_APPLIED
+		}
+	}
+
+	return entities.NewSuccessResponse(DeclaredStateResult{
+		Changes:   changes,
+		Converged: converged,
+	})
+}
+
+func responseError(response *entities.Response) error {
+	if response.Code != 0 {
+		return errors.New(response.Message)
+	}
+	return nil
+}
+
+func applyDeclaredChange(ctx context.Context, config *app.Config, user_id string, c *DeclaredChange) error {
+	switch c.Action {
+	case DECLARED_ACTION_REMOVE_ENDPOINT:
+		if err := responseError(RemoveEndpoint(c.EndpointID, c.TenantID)); err != nil {
+			return err
+		}
+		return db.DeleteByCondition(models.DeclaredEndpoint{EndpointID: c.EndpointID})
+	case DECLARED_ACTION_UNINSTALL:
+		installation, err := db.GetOne[models.PluginInstallation](
+			db.Equal("tenant_id", c.TenantID),
+			db.Equal("plugin_id", c.PluginID),
+		)
+		if err != nil {
+			return err
+		}
+		if err := uninstallPlugin(c.TenantID, &installation); err != nil {
+			return err
+		}
+		return nil
+	case DECLARED_ACTION_INSTALL:
+		source := c.plugin.Source
+		if source == "" {
+			source = "package"
+		}
+		response, err := InstallPluginRuntimeToTenant(
+			config,
+			c.TenantID,
+			[]plugin_entities.PluginUniqueIdentifier{c.plugin.PluginUniqueIdentifier},
+			source,
+			[]map[string]any{c.plugin.Meta},
+			installPluginToTenant(config, c.TenantID, source),
+		)
+		if err != nil {
+			return err
+		}
+		return waitDeclaredInstallation(ctx, c, response)
+	case DECLARED_ACTION_UPGRADE:
+		response := UpgradePlugin(
+			config,
+			c.TenantID,
+			c.source,
+			c.plugin.Meta,
+			plugin_entities.PluginUniqueIdentifier(c.From),
+			c.plugin.PluginUniqueIdentifier,
+		)
+		if err := responseError(response); err != nil {
+			return err
+		}
+		installResponse, _ := response.Data.(*InstallPluginResponse)
+		return waitDeclaredInstallation(ctx, c, installResponse)
+	case DECLARED_ACTION_CREATE_ENDPOINT:
+		settings, err := resolveDeclaredSettings(ctx, config, c.endpoint.Settings)
+		if err != nil {
+			return err
+		}
+		if err := responseError(SetupEndpoint(
+			c.TenantID,
+			user_id,
+			plugin_entities.PluginUniqueIdentifier(c.To),
+			c.EndpointName,
+			settings,
+		)); err != nil {
+			return err
+		}
+		endpoint, err := db.GetOne[models.Endpoint](
+			db.Equal("tenant_id", c.TenantID),
+			db.Equal("plugin_id", c.PluginID),
+			db.Equal("name", c.EndpointName),
+			db.OrderBy("created_at", true),
+		)
+		if err != nil {
+			return err
+		}
+		c.EndpointID = endpoint.ID
+		if err := recordDeclaredEndpoint(c.TenantID, endpoint.ID, c.endpoint.Settings); err != nil {
+			return err
+		}
+		if c.endpoint.Enabled != nil && !*c.endpoint.Enabled {
+			return responseError(DisableEndpoint(endpoint.ID, c.TenantID))
+		}
+		return nil
+	case DECLARED_ACTION_UPDATE_ENDPOINT:
+		settings, err := resolveDeclaredSettings(ctx, config, c.endpoint.Settings)
+		if err != nil {
+			return err
+		}
+		if err := responseError(UpdateEndpoint(
+			ctx, c.EndpointID, c.TenantID, user_id, c.EndpointName, settings,
+		)); err != nil {
+			return err
+		}
+		return recordDeclaredEndpoint(c.TenantID, c.EndpointID, c.endpoint.Settings)
+	case DECLARED_ACTION_ENABLE_ENDPOINT:
+		return responseError(EnableEndpoint(c.EndpointID, c.TenantID))
+	case DECLARED_ACTION_DISABLE_ENDPOINT:
+		return responseError(DisableEndpoint(c.EndpointID, c.TenantID))
+	}
+	return fmt.Errorf("unknown action %s", c.Action)
+}
+
+// waitDeclaredInstallation waits for the installation task, endpoints of the plugin can't be set up
+// before the plugin is installed
+func waitDeclaredInstallation(ctx context.Context, c *DeclaredChange, response *InstallPluginResponse) error {
+	if response == nil || response.AllInstalled || response.TaskID == "" {
+		return nil
+	}
+	c.TaskID = response.TaskID
+
+	ctx, cancel := context.WithTimeout(ctx, declaredStateInstallTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(declaredStatePollInterval)
+	defer ticker.Stop()
+	for {
+		task, err := db.GetOne[models.InstallTask](db.Equal("id", response.TaskID))
+		if err == db.ErrDatabaseNotFound {
+			// tasks succeeded may be deleted
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if installTaskFinished(task) {
+			for _, plugin := range task.Plugins {
+				if plugin.Status == models.InstallTaskStatusFailed {
+					return fmt.Errorf("failed to install %s: %s", plugin.PluginUniqueIdentifier, plugin.Message)
+				}
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("installation task %s is not finished: %w", response.TaskID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func recordDeclaredEndpoint(tenant_id string, endpoint_id string, settings map[string]any) error {
+	declared, err := db.GetOne[models.DeclaredEndpoint](db.Equal("endpoint_id", endpoint_id))
+	if err == db.ErrDatabaseNotFound {
+		return db.Create(&models.DeclaredEndpoint{
+			TenantID:    tenant_id,
+			EndpointID:  endpoint_id,
+			Fingerprint: settingsFingerprint(settings),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	declared.Fingerprint = settingsFingerprint(settings)
+	return db.Update(&declared)
+}
+
+// resolveDeclaredSettings replaces references to secret managers in settings by the secrets
+func resolveDeclaredSettings(ctx context.Context, config *app.Config, settings map[string]any) (map[string]any, error) {
+	resolved := map[string]any{}
+	for key, value := range settings {
+		v, err := resolveDeclaredValue(ctx, config, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve setting %s: %w", key, err)
+		}
+		resolved[key] = v
+	}
+	return resolved, nil
+}
+
+func resolveDeclaredValue(ctx context.Context, config *app.Config, value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return secrets.ResolveReference(ctx, config, v)
+	case map[string]any:
+		return resolveDeclaredSettings(ctx, config, v)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := resolveDeclaredValue(ctx, config, item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = resolved
+		}
+		return items, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func declaredIdentifier(t *testing.T, identifier string) plugin_entities.PluginUniqueIdentifier {
+	id, err := plugin_entities.NewPluginUniqueIdentifier(identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestPlanTenant(t *testing.T) {
+	disabled := false
+	tenant := DeclaredTenant{
+		TenantID: "tenant",
+		Plugins: []DeclaredPlugin{
+			{PluginUniqueIdentifier: declaredIdentifier(t, "langgenius/new:0.0.1@"+declaredChecksum('1'))},
+			{PluginUniqueIdentifier: declaredIdentifier(t, "langgenius/drifted:0.0.2@"+declaredChecksum('2'))},
+			{PluginUniqueIdentifier: declaredIdentifier(t, "langgenius/kept:0.0.1@"+declaredChecksum('3'))},
+		},
+		Endpoints: []DeclaredEndpoint{
+			{PluginID: "langgenius/kept", Name: "same", Settings: map[string]any{"token": "vault://dify#token"}},
+			{PluginID: "langgenius/kept", Name: "changed", Settings: map[string]any{"token": "b"}, Enabled: &disabled},
+			{PluginID: "langgenius/new", Name: "created"},
+		},
+	}
+	installations := []models.PluginInstallation{
+		{PluginID: "langgenius/drifted", PluginUniqueIdentifier: "langgenius/drifted:0.0.1@" + declaredChecksum('4'), Source: "marketplace"},
+		{PluginID: "langgenius/kept", PluginUniqueIdentifier: "langgenius/kept:0.0.1@" + declaredChecksum('3')},
+		{PluginID: "langgenius/extra", PluginUniqueIdentifier: "langgenius/extra:0.0.1@" + declaredChecksum('5')},
+	}
+	endpoints := []models.Endpoint{
+		{Model: models.Model{ID: "e1"}, PluginID: "langgenius/kept", Name: "same", Enabled: true},
+		{Model: models.Model{ID: "e2"}, PluginID: "langgenius/kept", Name: "changed", Enabled: true},
+		{Model: models.Model{ID: "e3"}, PluginID: "langgenius/kept", Name: "same", Enabled: true},
+		{Model: models.Model{ID: "e4"}, PluginID: "langgenius/extra", Name: "default", Enabled: true},
+	}
+	fingerprints := map[string]string{
+		"e1": settingsFingerprint(map[string]any{"token": "vault://dify#token"}),
+		"e2": settingsFingerprint(map[string]any{"token": "a"}),
+	}
+
+	changes := planTenant(tenant, installations, endpoints, fingerprints)
+
+	expected := []struct {
+		action     DeclaredAction
+		pluginID   string
+		endpointID string
+	}{
+		{DECLARED_ACTION_REMOVE_ENDPOINT, "langgenius/kept", "e3"},
+		{DECLARED_ACTION_REMOVE_ENDPOINT, "langgenius/extra", "e4"},
+		{DECLARED_ACTION_UNINSTALL, "langgenius/extra", ""},
+		{DECLARED_ACTION_INSTALL, "langgenius/new", ""},
+		{DECLARED_ACTION_UPGRADE, "langgenius/drifted", ""},
+		{DECLARED_ACTION_UPDATE_ENDPOINT, "langgenius/kept", "e2"},
+		{DECLARED_ACTION_DISABLE_ENDPOINT, "langgenius/kept", "e2"},
+		{DECLARED_ACTION_CREATE_ENDPOINT, "langgenius/new", ""},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), changes)
+	}
+	for i, e := range expected {
+		c := changes[i]
+		if c.Action != e.action || c.PluginID != e.pluginID || c.EndpointID != e.endpointID {
+			t.Fatalf("change %d should be %v, got %+v", i, e, c)
+		}
+		if c.Status != DECLARED_CHANGE_PLANNED || c.TenantID != "tenant" {
+			t.Fatalf("unexpected change %+v", c)
+		}
+	}
+	if changes[4].From != "langgenius/drifted:0.0.1@"+declaredChecksum('4') || changes[4].source != "marketplace" {
+		t.Fatalf("upgrades should start from the installed plugin, got %+v", changes[4])
+	}
+	if changes[7].To != "langgenius/new:0.0.1@"+declaredChecksum('1') {
+		t.Fatalf("endpoints should be created for the declared plugin, got %+v", changes[7])
+	}
+
+	// nothing to do once converged
+	tenant.Endpoints = tenant.Endpoints[:1]
+	tenant.Plugins = tenant.Plugins[2:]
+	changes = planTenant(tenant, installations[1:2], endpoints[:1], fingerprints)
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestDeclaredStateValidate(t *testing.T) {
+	plugin := DeclaredPlugin{PluginUniqueIdentifier: declaredIdentifier(t, "langgenius/a:0.0.1@"+declaredChecksum('1'))}
+
+	cases := []DeclaredState{
+		{Tenants: []DeclaredTenant{{TenantID: "t"}, {TenantID: "t"}}},
+		{Tenants: []DeclaredTenant{{TenantID: "t", Plugins: []DeclaredPlugin{plugin, plugin}}}},
+		{Tenants: []DeclaredTenant{{TenantID: "t", Plugins: []DeclaredPlugin{{PluginUniqueIdentifier: "invalid"}}}}},
+		{Tenants: []DeclaredTenant{{TenantID: "t", Endpoints: []DeclaredEndpoint{{PluginID: "langgenius/a", Name: "e"}}}}},
+	}
+	for i, state := range cases {
+		if err := state.validate(); err == nil {
+			t.Fatalf("state %d should be invalid", i)
+		}
+	}
+
+	valid := DeclaredState{Tenants: []DeclaredTenant{{
+		TenantID:  "t",
+		Plugins:   []DeclaredPlugin{plugin},
+		Endpoints: []DeclaredEndpoint{{PluginID: "langgenius/a", Name: "e"}},
+	}}}
+	if err := valid.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func declaredChecksum(c byte) string {
+	b := make([]byte, 64)
+	for i := range b {
+		b[i] = c
+	}
+	return string(b)
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	PLUGIN_SOURCE_DEV = "dev"
+)
+
+func checkDevMode(config *app.Config) exception.PluginDaemonError {
+	if config.PluginDevModeEnabled == nil || !*config.PluginDevModeEnabled {
+		return exception.PermissionDeniedError("plugin dev mode is disabled")
+	}
+
+	if config.Platform != app.PLATFORM_LOCAL {
+		return exception.BadRequestError(errors.New("plugin dev mode is only available on local platform"))
+	}
+
+	return nil
+}
+
+// StartDevPlugin runs the plugin from a local source directory and installs it to the tenant,
+// an existing installation of the same plugin is upgraded to keep its settings
+func StartDevPlugin(config *app.Config, tenant_id string, source_path string) *entities.Response {
+	if err := checkDevMode(config); err != nil {
+		return err.ToResponse()
+	}
+
+	manager := plugin_manager.Manager()
+	if manager == nil {
+		return exception.InternalServerError(errors.New("plugin manager is not initialized")).ToResponse()
+	}
+
+	pluginUniqueIdentifier, declaration, err := manager.WatchDevPlugin(source_path)
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("plugin_id", pluginUniqueIdentifier.PluginID()),
+	)
+
+	if err == db.ErrDatabaseNotFound {
+		_, installation, err := curd.InstallPlugin(
+			tenant_id,
+			pluginUniqueIdentifier,
+			plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL,
+			declaration,
+			PLUGIN_SOURCE_DEV,
+			map[string]any{},
+		)
+		if err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+
+		return entities.NewSuccessResponse(map[string]any{
+			"plugin_unique_identifier": pluginUniqueIdentifier,
+			"installation_id":          installation.ID,
+		})
+	}
+
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if installation.PluginUniqueIdentifier != pluginUniqueIdentifier.String() {
+		originalPluginUniqueIdentifier, err := plugin_entities.NewPluginUniqueIdentifier(
+			installation.PluginUniqueIdentifier,
+		)
+		if err != nil {
+			return exception.UniqueIdentifierError(err).ToResponse()
+		}
+
+		originalDeclaration, err := helper.CombinedGetPluginDeclaration(
+			originalPluginUniqueIdentifier,
+			plugin_entities.PluginRuntimeType(installation.RuntimeType),
+		)
+		if err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+
+		upgradeResponse, err := curd.UpgradePlugin(
+			tenant_id,
+			originalPluginUniqueIdentifier,
+			pluginUniqueIdentifier,
+			originalDeclaration,
+			declaration,
+			plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL,
+			PLUGIN_SOURCE_DEV,
+			installation.Meta,
+		)
+		if err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+
+		if upgradeResponse.IsOriginalPluginDeleted && string(upgradeResponse.DeletedPlugin.InstallType) == string(
+			plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL,
+		) {
+			if installation.Source == PLUGIN_SOURCE_DEV {
+				// the plugin was running from another source directory
+				err = manager.UnwatchDevPlugin(originalPluginUniqueIdentifier)
+				if errors.Is(err, plugin_manager.ErrDevPluginNotFound) {
+					err = nil
+				}
+			} else {
+				err = manager.UninstallFromLocal(originalPluginUniqueIdentifier)
+			}
+			if err != nil {
+				return exception.InternalServerError(err).ToResponse()
+			}
+		}
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"plugin_unique_identifier": pluginUniqueIdentifier,
+		"installation_id":          installation.ID,
+	})
+}
+
+// StopDevPlugin stops watching the source directory of the plugin,
+// the installation is kept so that the settings are reused by the next dev session
+func StopDevPlugin(config *app.Config, plugin_unique_identifier plugin_entities.PluginUniqueIdentifier) *entities.Response {
+	if err := checkDevMode(config); err != nil {
+		return err.ToResponse()
+	}
+
+	manager := plugin_manager.Manager()
+	if manager == nil {
+		return exception.InternalServerError(errors.New("plugin manager is not initialized")).ToResponse()
+	}
+
+	if err := manager.UnwatchDevPlugin(plugin_unique_identifier); err != nil {
+		if errors.Is(err, plugin_manager.ErrDevPluginNotFound) {
+			return exception.NotFoundError(err).ToResponse()
+		}
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+func ListDevPlugins(config *app.Config) *entities.Response {
+	if err := checkDevMode(config); err != nil {
+		return err.ToResponse()
+	}
+
+	manager := plugin_manager.Manager()
+	if manager == nil {
+		return exception.InternalServerError(errors.New("plugin manager is not initialized")).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(manager.ListDevPlugins())
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// ListEncryptionKeys lists versions of the keys configured and counts stored secrets by the versions
+// encrypting them, a key no secret is encrypted with is safe to be removed
+func ListEncryptionKeys() *entities.Response {
+	stores, err := keyring.Status()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"versions":       keyring.Versions(),
+		"active_version": keyring.ActiveVersion(),
+		"stores":         stores,
+	})
+}
+
+// ReencryptSecrets re-encrypts stored secrets by the active key right away instead of waiting for the job
+func ReencryptSecrets() *entities.Response {
+	return entities.NewSuccessResponse(keyring.Reencrypt())
+}
@@ -8,33 +8,44 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/credential_audit"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_template"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_encryption"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/webhook"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"gorm.io/gorm"
 )
 
-func copyRequest(req *http.Request, hookId string, path string) (*bytes.Buffer, error) {
+func copyRequest(
+	req *http.Request, hookId string, path string, mapping endpoint_template.Mapping,
+) (*bytes.Buffer, error) {
 	newReq := req.Clone(context.Background())
 	// get query params
 	queryParams := req.URL.Query()
 
-	// replace path with endpoint path
-	newReq.URL.Path = path
+	// replace path with endpoint path, prefixed by the template the endpoint is materialized from
+	newReq.URL.Path = mapping.PathPrefix + path
 	// set query params
 	newReq.URL.RawQuery = queryParams.Encode()
 
@@ -57,13 +68,21 @@ func copyRequest(req *http.Request, hookId string, path string) (*bytes.Buffer,
 	newReq.Header.Del("X-Original-Url")
 	newReq.Header.Del("X-Original-Host")
 
+	for name, value := range mapping.Headers {
+		newReq.Header.Set(name, value)
+	}
+
 	// setup hook id to request
 	newReq.Header.Set("Dify-Hook-Id", hookId)
 	// check if Dify-Hook-Url is set
 	if url := req.Header.Get("Dify-Hook-Url"); url == "" {
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
 		newReq.Header.Set(
 			"Dify-Hook-Url",
-			fmt.Sprintf("http://%s/e/%s%s", req.Host, hookId, path),
+			fmt.Sprintf("%s://%s/e/%s%s", scheme, req.Host, hookId, path),
 		)
 	}
 
@@ -83,12 +102,19 @@ func Endpoint(
 	maxExecutionTime time.Duration,
 	path string,
 ) {
+	startedAt := time.Now()
 	if !endpoint.Enabled {
 		ctx.JSON(404, exception.NotFoundError(errors.New("endpoint not found")).ToResponse())
 		return
 	}
 
-	buffer, err := copyRequest(ctx.Request, endpoint.HookID, path)
+	mapping, err := endpointMapping(endpoint.ID)
+	if err != nil {
+		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	buffer, err := copyRequest(ctx.Request, endpoint.HookID, path, mapping)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
 		return
@@ -100,6 +126,17 @@ func Endpoint(
 		return
 	}
 
+	defer func() {
+		events.Publish(events.EndpointInvoked{
+			EndpointID:             endpoint.ID,
+			TenantID:               endpoint.TenantID,
+			PluginUniqueIdentifier: identifier,
+			Method:                 ctx.Request.Method,
+			StatusCode:             ctx.Writer.Status(),
+			Duration:               time.Since(startedAt),
+		})
+	}()
+
 	// fetch plugin
 	manager := plugin_manager.Manager()
 	runtime, err := manager.Get(identifier)
@@ -131,10 +168,20 @@ func Endpoint(
 		},
 	})
 
+	access := credential_audit.Access{
+		TenantID:  endpoint.TenantID,
+		Namespace: string(dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT),
+		Identity:  endpoint.ID,
+		PluginID:  endpoint.PluginID,
+		Reason:    credential_audit.REASON_INVOKE_ENDPOINT,
+		Caller:    credential_audit.CALLER_ENDPOINT,
+	}
 	if err != nil {
+		credential_audit.Record(ctx.Request.Context(), access, err)
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
 		return
 	}
+	reencryptSettings(*endpoint, settings, endpointDeclaration.Settings)
 
 	session := session_manager.NewSession(
 		session_manager.NewSessionPayload{
@@ -148,12 +195,17 @@ func Endpoint(
 			BackwardsInvocation:    manager.BackwardsInvocation(),
 			IgnoreCache:            false,
 			EndpointID:             &endpoint.ID,
+			Context:                ctx.Request.Context(),
 		},
 	)
 	defer session.Close(session_manager.CloseSessionPayload{
 		IgnoreCache: false,
 	})
 
+	// the settings decrypted are sent to the plugin by the session
+	access.SessionID = session.ID
+	credential_audit.Record(ctx.Request.Context(), access, nil)
+
 	session.BindRuntime(runtime)
 
 	statusCode, headers, response, err := plugin_daemon.InvokeEndpoint(
@@ -185,22 +237,21 @@ func Endpoint(
 	}
 	defer close()
 
-	routine.Submit(map[string]string{
+	// streams of endpoints run on their own pool, the request is rejected once it's full
+	if err := routine.SubmitTo(routine.POOL_ENDPOINT_STREAM, map[string]string{
 		"module":   "service",
 		"function": "Endpoint",
 	}, func() {
 		defer close()
-		for response.Next() {
-			chunk, err := response.Read()
-			if err != nil {
-				ctx.Writer.Write([]byte(err.Error()))
-				ctx.Writer.Flush()
-				return
-			}
-			ctx.Writer.Write(chunk)
+		// chunks are handed to the writer as they are, each of them is flushed to the client at once
+		if _, err := io.Copy(flushWriter{ctx.Writer}, stream.NewReader(response)); err != nil {
+			ctx.Writer.Write([]byte(err.Error()))
 			ctx.Writer.Flush()
 		}
-	})
+	}); err != nil {
+		ctx.JSON(503, exception.NodeAtCapacityError().ToResponse())
+		return
+	}
 
 	select {
 	case <-ctx.Writer.CloseNotify():
@@ -210,6 +261,17 @@ func Endpoint(
 	}
 }
 
+// flushWriter flushes each chunk once it's written, so chunks reach the client as they arrive
+type flushWriter struct {
+	w gin.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.w.Flush()
+	return n, err
+}
+
 func EnableEndpoint(endpoint_id string, tenant_id string) *entities.Response {
 
 	if err := install_service.EnabledEndpoint(endpoint_id, tenant_id); err != nil {
@@ -225,19 +287,45 @@ func DisableEndpoint(endpoint_id string, tenant_id string) *entities.Response {
 		return exception.InternalServerError(errors.New("failed to disable endpoint")).ToResponse()
 	}
 
+	webhook.Dispatch(tenant_id, webhook.EVENT_ENDPOINT_DISABLED, map[string]any{
+		"endpoint_id": endpoint_id,
+	})
+
 	return entities.NewSuccessResponse(true)
 }
 
-func ListEndpoints(tenant_id string, page int, page_size int) *entities.Response {
-	endpoints, err := db.GetAll[models.Endpoint](
-		db.Equal("tenant_id", tenant_id),
+// endpointFilters returns the conditions of endpoints of the tenant matching the options
+func endpointFilters(tenant_id string, options ListOptions) []db.GenericQuery {
+	filters := []db.GenericQuery{db.Equal("tenant_id", tenant_id)}
+	if options.PluginID != "" {
+		filters = append(filters, db.Equal("plugin_id", options.PluginID))
+	}
+	if options.Search != "" {
+		filters = append(filters, db.Like("name", options.Search))
+	}
+	if options.Enabled != nil {
+		filters = append(filters, db.Equal("enabled", *options.Enabled))
+	}
+	return filters
+}
+
+func ListEndpoints(
+	ctx context.Context, tenant_id string, options ListOptions, projection EndpointProjection,
+) *entities.Response {
+	endpoints, total, err := listPage[models.Endpoint](
+		options,
 		db.OrderBy("created_at", true),
-		db.Page(page, page_size),
+		endpointFilters(tenant_id, options)...,
 	)
 	if err != nil {
 		return exception.InternalServerError(fmt.Errorf("failed to list endpoints: %v", err)).ToResponse()
 	}
 
+	// settings are not decrypted if they're not replied
+	if !projection.revealsSettings() {
+		return projectEndpoints(projection, endpoints, total, options)
+	}
+
 	manager := plugin_manager.Manager()
 	if manager == nil {
 		return exception.InternalServerError(errors.New("failed to get plugin manager")).ToResponse()
@@ -299,12 +387,21 @@ func ListEndpoints(tenant_id string, page int, page_size int) *entities.Response
 				Config:    pluginDeclaration.Endpoint.Settings,
 			},
 		})
+		credential_audit.Record(ctx, credential_audit.Access{
+			TenantID:  tenant_id,
+			Namespace: string(dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT),
+			Identity:  endpoint.ID,
+			PluginID:  endpoint.PluginID,
+			Reason:    credential_audit.REASON_LIST_ENDPOINTS,
+		}, err)
 		if err != nil {
 			return exception.InternalServerError(
 				fmt.Errorf("failed to decrypt settings: %v", err),
 			).ToResponse()
 		}
 
+		reencryptSettings(endpoint, decryptedSettings, pluginDeclaration.Endpoint.Settings)
+
 		// mask settings
 		decryptedSettings = encryption.MaskConfigCredentials(decryptedSettings, pluginDeclaration.Endpoint.Settings)
 
@@ -314,15 +411,17 @@ func ListEndpoints(tenant_id string, page int, page_size int) *entities.Response
 		endpoints[i] = endpoint
 	}
 
-	return entities.NewSuccessResponse(endpoints)
+	return projectEndpoints(projection, endpoints, total, options)
 }
 
-func ListPluginEndpoints(tenant_id string, plugin_id string, page int, page_size int) *entities.Response {
-	endpoints, err := db.GetAll[models.Endpoint](
-		db.Equal("plugin_id", plugin_id),
-		db.Equal("tenant_id", tenant_id),
+func ListPluginEndpoints(
+	ctx context.Context, tenant_id string, plugin_id string, options ListOptions, projection EndpointProjection,
+) *entities.Response {
+	options.PluginID = plugin_id
+	endpoints, total, err := listPage[models.Endpoint](
+		options,
 		db.OrderBy("created_at", true),
-		db.Page(page, page_size),
+		endpointFilters(tenant_id, options)...,
 	)
 	if err != nil {
 		return exception.InternalServerError(
@@ -330,6 +429,11 @@ func ListPluginEndpoints(tenant_id string, plugin_id string, page int, page_size
 		).ToResponse()
 	}
 
+	// settings are not decrypted if they're not replied
+	if !projection.revealsSettings() {
+		return projectEndpoints(projection, endpoints, total, options)
+	}
+
 	manager := plugin_manager.Manager()
 	if manager == nil {
 		return exception.InternalServerError(
@@ -385,12 +489,21 @@ func ListPluginEndpoints(tenant_id string, plugin_id string, page int, page_size
 				Config:    pluginDeclaration.Endpoint.Settings,
 			},
 		})
+		credential_audit.Record(ctx, credential_audit.Access{
+			TenantID:  tenant_id,
+			Namespace: string(dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT),
+			Identity:  endpoint.ID,
+			PluginID:  endpoint.PluginID,
+			Reason:    credential_audit.REASON_LIST_PLUGIN_ENDPOINTS,
+		}, err)
 		if err != nil {
 			return exception.InternalServerError(
 				fmt.Errorf("failed to decrypt settings: %v", err),
 			).ToResponse()
 		}
 
+		reencryptSettings(endpoint, decryptedSettings, pluginDeclaration.Endpoint.Settings)
+
 		// mask settings
 		decryptedSettings = encryption.MaskConfigCredentials(decryptedSettings, pluginDeclaration.Endpoint.Settings)
 
@@ -400,5 +513,60 @@ func ListPluginEndpoints(tenant_id string, plugin_id string, page int, page_size
 		endpoints[i] = endpoint
 	}
 
-	return entities.NewSuccessResponse(endpoints)
+	return projectEndpoints(projection, endpoints, total, options)
+}
+
+// reencryptSettings saves the settings encrypted by the active key of the tenant if they were decrypted from
+// stale ones, settings updated in the meantime are left as they are
+func reencryptSettings(
+	endpoint models.Endpoint, settings map[string]any, config []plugin_entities.ProviderConfig,
+) {
+	if !tenant_encryption.Stale(endpoint.Settings, config) {
+		return
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "service",
+		"function": "reencryptSettings",
+	}, func() {
+		encryptedSettings, err := plugin_manager.Manager().BackwardsInvocation().InvokeEncrypt(
+			&dify_invocation.InvokeEncryptRequest{
+				BaseInvokeDifyRequest: dify_invocation.BaseInvokeDifyRequest{
+					TenantId: endpoint.TenantID,
+					Type:     dify_invocation.INVOKE_TYPE_ENCRYPT,
+				},
+				InvokeEncryptSchema: dify_invocation.InvokeEncryptSchema{
+					Opt:       dify_invocation.ENCRYPT_OPT_ENCRYPT,
+					Namespace: dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT,
+					Identity:  endpoint.ID,
+					Data:      settings,
+					Config:    config,
+				},
+			},
+		)
+		if err != nil {
+			log.Error("failed to re-encrypt settings of endpoint %s: %s", endpoint.ID, err.Error())
+			return
+		}
+
+		if err := db.WithTransaction(func(tx *gorm.DB) error {
+			record, err := db.GetOne[models.Endpoint](
+				db.WithTransactionContext(tx),
+				db.Equal("id", endpoint.ID),
+				db.WLock(),
+			)
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(record.Settings, endpoint.Settings) {
+				// updated or re-encrypted by another request
+				return nil
+			}
+
+			record.Settings = encryptedSettings
+			return db.Update(&record, tx)
+		}); err != nil {
+			log.Error("failed to save re-encrypted settings of endpoint %s: %s", endpoint.ID, err.Error())
+		}
+	})
 }
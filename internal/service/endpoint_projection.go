@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+const (
+	// settings are decrypted and credentials in them are masked, it's the default
+	REVEAL_PARTIAL = "partial"
+	// settings are neither decrypted nor replied
+	REVEAL_FALSE = "false"
+)
+
+// endpointFields are the fields of endpoints a projection picks from
+var endpointFields = []string{
+	"id", "created_at", "updated_at", "name", "hook_id", "tenant_id", "user_id",
+	"plugin_id", "expired_at", "enabled", "settings", "declaration",
+}
+
+// EndpointProjection picks the fields of listed endpoints, settings and declarations are the expensive
+// ones as they're decrypted and fetched from the manifests of plugins, they're skipped if not picked
+type EndpointProjection struct {
+	// Fields are all the fields if it's empty
+	Fields []string
+	Reveal string
+}
+
+// ParseEndpointProjection parses fields like `id,name,enabled` and the reveal mode
+func ParseEndpointProjection(fields string, reveal string) (EndpointProjection, error) {
+	projection := EndpointProjection{Reveal: reveal}
+	if projection.Reveal == "" {
+		projection.Reveal = REVEAL_PARTIAL
+	}
+	if projection.Reveal != REVEAL_PARTIAL && projection.Reveal != REVEAL_FALSE {
+		return projection, fmt.Errorf("invalid reveal %q, expected false or partial", reveal)
+	}
+
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(endpointFields, field) {
+			return projection, fmt.Errorf("unknown field %q of endpoints", field)
+		}
+		projection.Fields = append(projection.Fields, field)
+	}
+	return projection, nil
+}
+
+func (p EndpointProjection) picks(field string) bool {
+	return len(p.Fields) == 0 || slices.Contains(p.Fields, field)
+}
+
+// revealsSettings returns true if settings or declarations are replied, which requires decrypting settings
+func (p EndpointProjection) revealsSettings() bool {
+	return p.Reveal != REVEAL_FALSE && (p.picks("settings") || p.picks("declaration"))
+}
+
+// project returns endpoints as they are if all fields are picked, otherwise the picked fields of them
+func (p EndpointProjection) project(endpoints []models.Endpoint) ([]any, error) {
+	items := make([]any, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if len(p.Fields) == 0 && p.revealsSettings() {
+			items = append(items, endpoint)
+			continue
+		}
+
+		fields, err := parser.TryStructToMap(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		for field := range fields {
+			if !p.picks(field) || (!p.revealsSettings() && (field == "settings" || field == "declaration")) {
+				delete(fields, field)
+			}
+		}
+		items = append(items, fields)
+	}
+	return items, nil
+}
+
+// projectEndpoints replies the page of endpoints projected
+func projectEndpoints(
+	projection EndpointProjection, endpoints []models.Endpoint, total int64, options ListOptions,
+) *entities.Response {
+	items, err := projection.project(endpoints)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(newList(items, total, options))
+}
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_template"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	ENDPOINT_TEMPLATE_ACTION_SYNC    = "sync"
+	ENDPOINT_TEMPLATE_ACTION_ENABLE  = "enable"
+	ENDPOINT_TEMPLATE_ACTION_DISABLE = "disable"
+	ENDPOINT_TEMPLATE_ACTION_REMOVE  = "remove"
+)
+
+// EndpointTemplateResult is the result of materializing or managing an endpoint of a template
+type EndpointTemplateResult struct {
+	EndpointID string `json:"endpoint_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// Values are values of variables with secret ones redacted
+	Values  map[string]string `json:"values,omitempty"`
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+}
+
+// EndpointTemplateInstance is an endpoint materialized from a template
+type EndpointTemplateInstance struct {
+	models.EndpointTemplateInstance
+	Name    string            `json:"name"`
+	HookID  string            `json:"hook_id"`
+	Enabled bool              `json:"enabled"`
+	Values  map[string]string `json:"values"`
+}
+
+// endpointMapping returns the mapping of requests to the endpoint if it's materialized from a template
+func endpointMapping(endpoint_id string) (endpoint_template.Mapping, error) {
+	instance, err := db.GetOne[models.EndpointTemplateInstance](
+		db.Equal("endpoint_id", endpoint_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return endpoint_template.Mapping{}, nil
+	}
+	if err != nil {
+		return endpoint_template.Mapping{}, err
+	}
+
+	_, mapping, err := endpoint_template.Open(&instance)
+	return mapping, err
+}
+
+// templatePlugin returns the plugin the endpoints of templates are set up for, the installed version
+// of the tenant is used
+func templatePlugin(
+	tenant_id string, plugin_id string,
+) (plugin_entities.PluginUniqueIdentifier, *plugin_entities.PluginDeclaration, exception.PluginDaemonError) {
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("plugin_id", plugin_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return "", nil, exception.ErrPluginNotFound()
+	}
+	if err != nil {
+		return "", nil, exception.InternalServerError(err)
+	}
+
+	identifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+	if err != nil {
+		return "", nil, exception.UniqueIdentifierError(err)
+	}
+
+	declaration, err := helper.CombinedGetPluginDeclaration(
+		identifier, plugin_entities.PluginRuntimeType(installation.RuntimeType),
+	)
+	if err != nil {
+		return "", nil, exception.ErrPluginNotFound()
+	}
+	if declaration.Endpoint == nil {
+		return "", nil, exception.BadRequestError(errors.New("plugin does not have an endpoint"))
+	}
+
+	return identifier, declaration, nil
+}
+
+func getEndpointTemplate(tenant_id string, template_id string) (*models.EndpointTemplate, exception.PluginDaemonError) {
+	template, err := db.GetOne[models.EndpointTemplate](
+		db.Equal("id", template_id),
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, exception.NotFoundError(errors.New("endpoint template not found"))
+	}
+	if err != nil {
+		return nil, exception.InternalServerError(err)
+	}
+	return &template, nil
+}
+
+func ListEndpointTemplates(tenant_id string) *entities.Response {
+	templates, err := db.GetAll[models.EndpointTemplate](
+		db.Equal("tenant_id", tenant_id),
+		db.OrderBy("created_at", true),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(templates)
+}
+
+// CreateEndpointTemplate validates the template against settings of the endpoint declared by the plugin
+func CreateEndpointTemplate(template models.EndpointTemplate) *entities.Response {
+	_, declaration, perr := templatePlugin(template.TenantID, template.PluginID)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+	if err := endpoint_template.Validate(&template, declaration.Endpoint.Settings); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	_, err := db.GetOne[models.EndpointTemplate](
+		db.Equal("tenant_id", template.TenantID),
+		db.Equal("name", template.Name),
+	)
+	if err == nil {
+		return exception.BadRequestError(fmt.Errorf("endpoint template %s already exists", template.Name)).ToResponse()
+	}
+	if err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if err := db.Create(&template); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(template)
+}
+
+// UpdateEndpointTemplate replaces the template, endpoints materialized from it are changed once they're synced
+func UpdateEndpointTemplate(tenant_id string, template_id string, update models.EndpointTemplate) *entities.Response {
+	template, perr := getEndpointTemplate(tenant_id, template_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+
+	template.EndpointName = update.EndpointName
+	template.Variables = update.Variables
+	template.Settings = update.Settings
+	template.PathPrefix = update.PathPrefix
+	template.Headers = update.Headers
+
+	_, declaration, perr := templatePlugin(tenant_id, template.PluginID)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+	if err := endpoint_template.Validate(template, declaration.Endpoint.Settings); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	if err := db.Update(template); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(template)
+}
+
+// DeleteEndpointTemplate removes endpoints materialized from the template along with it, the template
+// is kept if any of them fails to be removed
+func DeleteEndpointTemplate(tenant_id string, template_id string) *entities.Response {
+	template, perr := getEndpointTemplate(tenant_id, template_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+
+	results, err := manageEndpointTemplateInstances(
+		context.Background(), template, "", ENDPOINT_TEMPLATE_ACTION_REMOVE, nil,
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	for _, result := range results {
+		if !result.Success {
+			return exception.InternalServerError(
+				fmt.Errorf("failed to remove endpoint %s: %s", result.EndpointID, result.Message),
+			).ToResponse()
+		}
+	}
+
+	if err := db.Delete(template); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(results)
+}
+
+// MaterializeEndpointTemplate sets up an endpoint for each set of values, a failure of one endpoint
+// does not affect the others
+func MaterializeEndpointTemplate(
+	tenant_id string,
+	user_id string,
+	template_id string,
+	instances []map[string]string,
+) *entities.Response {
+	template, perr := getEndpointTemplate(tenant_id, template_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+	identifier, _, perr := templatePlugin(tenant_id, template.PluginID)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+
+	results := make([]EndpointTemplateResult, 0, len(instances))
+	for _, values := range instances {
+		result := EndpointTemplateResult{Values: endpoint_template.MaskValues(template, values)}
+		if err := materializeEndpoint(template, identifier, user_id, values, &result); err != nil {
+			result.Message = err.Error()
+		} else {
+			result.Success = true
+			result.Message = "Materialized"
+		}
+		results = append(results, result)
+	}
+
+	return entities.NewSuccessResponse(results)
+}
+
+func materializeEndpoint(
+	template *models.EndpointTemplate,
+	identifier plugin_entities.PluginUniqueIdentifier,
+	user_id string,
+	values map[string]string,
+	result *EndpointTemplateResult,
+) error {
+	rendered, err := endpoint_template.Render(template, values)
+	if err != nil {
+		return err
+	}
+	result.Name = rendered.Name
+
+	endpoint, perr := setupEndpoint(template.TenantID, user_id, identifier, rendered.Name, rendered.Settings)
+	if perr != nil {
+		return perr
+	}
+	result.EndpointID = endpoint.ID
+
+	instance := models.EndpointTemplateInstance{
+		TenantID:   template.TenantID,
+		TemplateID: template.ID,
+		EndpointID: endpoint.ID,
+	}
+	if err := endpoint_template.Seal(&instance, values, rendered.Mapping); err != nil {
+		return err
+	}
+	return db.Create(&instance)
+}
+
+func ListEndpointTemplateInstances(tenant_id string, template_id string) *entities.Response {
+	template, perr := getEndpointTemplate(tenant_id, template_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+
+	instances, err := db.GetAll[models.EndpointTemplateInstance](
+		db.Equal("template_id", template.ID),
+		db.OrderBy("created_at", false),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	endpoints, err := templateEndpoints(tenant_id, instances)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	result := []EndpointTemplateInstance{}
+	for _, instance := range instances {
+		endpoint, ok := endpoints[instance.EndpointID]
+		if !ok {
+			// removed along with the plugin
+			continue
+		}
+		values, _, err := endpoint_template.Open(&instance)
+		if err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+		result = append(result, EndpointTemplateInstance{
+			EndpointTemplateInstance: instance,
+			Name:                     endpoint.Name,
+			HookID:                   endpoint.HookID,
+			Enabled:                  endpoint.Enabled,
+			Values:                   endpoint_template.MaskValues(template, values),
+		})
+	}
+
+	return entities.NewSuccessResponse(result)
+}
+
+// templateEndpoints returns endpoints of the instances by their ids
+func templateEndpoints(tenant_id string, instances []models.EndpointTemplateInstance) (map[string]models.Endpoint, error) {
+	endpoints := map[string]models.Endpoint{}
+	if len(instances) == 0 {
+		return endpoints, nil
+	}
+
+	ids := make([]interface{}, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, instance.EndpointID)
+	}
+	records, err := db.GetAll[models.Endpoint](
+		db.Equal("tenant_id", tenant_id),
+		db.InArray("id", ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, endpoint := range records {
+		endpoints[endpoint.ID] = endpoint
+	}
+	return endpoints, nil
+}
+
+// ManageEndpointTemplateInstances runs the action against endpoints materialized from the template, all of
+// them if endpoint ids are empty, syncing renders endpoints by the template again with the values they were
+// materialized with
+func ManageEndpointTemplateInstances(
+	ctx context.Context,
+	tenant_id string,
+	user_id string,
+	template_id string,
+	action string,
+	endpoint_ids []string,
+) *entities.Response {
+	template, perr := getEndpointTemplate(tenant_id, template_id)
+	if perr != nil {
+		return perr.ToResponse()
+	}
+	if action == ENDPOINT_TEMPLATE_ACTION_SYNC && user_id == "" {
+		return exception.BadRequestError(errors.New("user_id is required to sync endpoints")).ToResponse()
+	}
+
+	results, err := manageEndpointTemplateInstances(ctx, template, user_id, action, endpoint_ids)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(results)
+}
+
+func manageEndpointTemplateInstances(
+	ctx context.Context,
+	template *models.EndpointTemplate,
+	user_id string,
+	action string,
+	endpoint_ids []string,
+) ([]EndpointTemplateResult, error) {
+	filters := []db.GenericQuery{
+		db.Equal("template_id", template.ID),
+		db.OrderBy("created_at", false),
+	}
+	if len(endpoint_ids) > 0 {
+		ids := make([]interface{}, 0, len(endpoint_ids))
+		for _, id := range endpoint_ids {
+			ids = append(ids, id)
+		}
+		filters = append(filters, db.InArray("endpoint_id", ids))
+	}
+	instances, err := db.GetAll[models.EndpointTemplateInstance](filters...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]EndpointTemplateResult, 0, len(instances))
+	for _, instance := range instances {
+		result := EndpointTemplateResult{EndpointID: instance.EndpointID}
+		if err := manageEndpointTemplateInstance(ctx, template, user_id, action, &instance, &result); err != nil {
+			result.Message = err.Error()
+		} else {
+			result.Success = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func manageEndpointTemplateInstance(
+	ctx context.Context,
+	template *models.EndpointTemplate,
+	user_id string,
+	action string,
+	instance *models.EndpointTemplateInstance,
+	result *EndpointTemplateResult,
+) error {
+	switch action {
+	case ENDPOINT_TEMPLATE_ACTION_ENABLE:
+		return responseError(EnableEndpoint(instance.EndpointID, template.TenantID))
+	case ENDPOINT_TEMPLATE_ACTION_DISABLE:
+		return responseError(DisableEndpoint(instance.EndpointID, template.TenantID))
+	case ENDPOINT_TEMPLATE_ACTION_REMOVE:
+		_, err := db.GetOne[models.Endpoint](
+			db.Equal("id", instance.EndpointID),
+			db.Equal("tenant_id", template.TenantID),
+		)
+		if err == db.ErrDatabaseNotFound {
+			// the endpoint was removed along with the plugin, only the instance is left
+			return db.Delete(instance)
+		}
+		if err != nil {
+			return err
+		}
+		return responseError(RemoveEndpoint(instance.EndpointID, template.TenantID))
+	case ENDPOINT_TEMPLATE_ACTION_SYNC:
+		values, _, err := endpoint_template.Open(instance)
+		if err != nil {
+			return err
+		}
+		result.Values = endpoint_template.MaskValues(template, values)
+
+		rendered, err := endpoint_template.Render(template, values)
+		if err != nil {
+			return err
+		}
+		result.Name = rendered.Name
+
+		if err := responseError(UpdateEndpoint(
+			ctx, instance.EndpointID, template.TenantID, user_id, rendered.Name, rendered.Settings,
+		)); err != nil {
+			return err
+		}
+		if err := endpoint_template.Seal(instance, values, rendered.Mapping); err != nil {
+			return err
+		}
+		return db.Update(instance)
+	}
+	return fmt.Errorf("unknown action %s", action)
+}
@@ -5,6 +5,10 @@ import (
 	"io"
 	"net/http"
 	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_template"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 )
 
 func TestCopyRequest(t *testing.T) {
@@ -14,7 +18,7 @@ func TestCopyRequest(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	buffer, err := copyRequest(req, "123", "/test")
+	buffer, err := copyRequest(req, "123", "/test", endpoint_template.Mapping{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -24,3 +28,88 @@ func TestCopyRequest(t *testing.T) {
 		t.Fatal("request body is not equal, ", str)
 	}
 }
+
+func TestCopyRequestWithMapping(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://localhost:8080/e/123/events", bytes.NewReader([]byte("test")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer, err := copyRequest(req, "123", "/events", endpoint_template.Mapping{
+		PathPrefix: "/teams/acme",
+		Headers:    map[string]string{"X-Team": "acme"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := buffer.String()
+	if str != "POST /teams/acme/events HTTP/1.1\r\nHost: localhost:8080\r\nUser-Agent: Go-http-client/1.1\r\nContent-Length: 4\r\nDify-Hook-Id: 123\r\nDify-Hook-Url: http://localhost:8080/e/123/events\r\nX-Team: acme\r\n\r\ntest" {
+		t.Fatal("request is not mapped, ", str)
+	}
+}
+
+func TestEndpointProjection(t *testing.T) {
+	endpoints := []models.Endpoint{{
+		Model:    models.Model{ID: "id"},
+		Name:     "hook",
+		Enabled:  true,
+		Settings: map[string]any{"api_key": "secret"},
+	}}
+
+	projection, err := ParseEndpointProjection("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !projection.revealsSettings() {
+		t.Fatal("settings are revealed partially by default")
+	}
+	items, err := projection.project(endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := items[0].(models.Endpoint); !ok {
+		t.Fatal("endpoints should be kept as they are if all fields are picked")
+	}
+
+	projection, err = ParseEndpointProjection("id, name,enabled", "partial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projection.revealsSettings() {
+		t.Fatal("settings should not be decrypted if they're not picked")
+	}
+	items, err = projection.project(endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := items[0].(map[string]any)
+	if len(fields) != 3 || fields["id"] != "id" || fields["name"] != "hook" || fields["enabled"] != true {
+		t.Fatalf("unexpected projection %v", fields)
+	}
+
+	projection, err = ParseEndpointProjection("", "false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err = projection.project(endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = items[0].(map[string]any)
+	if _, ok := fields["settings"]; ok || projection.revealsSettings() {
+		t.Fatal("settings should be skipped once they're not revealed")
+	}
+	if fields["hook_id"] != "" || fields["id"] != "id" {
+		t.Fatalf("other fields should be kept, got %v", fields)
+	}
+	if _, ok := fields["created_at"].(time.Time); !ok {
+		t.Fatalf("times should be kept as they are, got %v", fields["created_at"])
+	}
+
+	for _, invalid := range [][2]string{{"secret", ""}, {"", "true"}} {
+		if _, err := ParseEndpointProjection(invalid[0], invalid[1]); err == nil {
+			t.Fatalf("%v should be rejected", invalid)
+		}
+	}
+}
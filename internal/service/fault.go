@@ -0,0 +1,37 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/fault"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// ListFaults lists fault injection rules of the node along with how many times they have fired
+func ListFaults() *entities.Response {
+	return entities.NewSuccessResponse(map[string]any{
+		"enabled": fault.Enabled(),
+		"rules":   fault.Rules(),
+	})
+}
+
+// SetFaults replaces fault injection rules of the node
+func SetFaults(rules []fault.Rule) *entities.Response {
+	if err := fault.SetRules(rules); err != nil {
+		if errors.Is(err, fault.ErrDisabled) {
+			return exception.BadRequestError(errors.New("fault injection is disabled, set FAULT_INJECTION_ENABLED to enable it")).ToResponse()
+		}
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	log.Warn("fault injection rules are replaced, %d rules are active", len(rules))
+	return entities.NewSuccessResponse(fault.Rules())
+}
+
+// ClearFaults removes all fault injection rules of the node
+func ClearFaults() *entities.Response {
+	fault.Clear()
+	return entities.NewSuccessResponse(true)
+}
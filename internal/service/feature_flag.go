@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/feature_flag"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+var featureFlagName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// FeatureFlag is a flag declared or configured, flags configured but not declared are kept for nodes of
+// other versions declaring them
+type FeatureFlag struct {
+	feature_flag.Flag
+	Declared bool                `json:"declared"`
+	Config   *models.FeatureFlag `json:"config"`
+}
+
+func ListFeatureFlags() *entities.Response {
+	configs, err := db.GetAll[models.FeatureFlag](
+		db.OrderBy("name", false),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	flags := []FeatureFlag{}
+	indexes := map[string]int{}
+	for _, flag := range feature_flag.Declared() {
+		indexes[flag.Name] = len(flags)
+		flags = append(flags, FeatureFlag{Flag: flag, Declared: true})
+	}
+	for i := range configs {
+		if index, ok := indexes[configs[i].Name]; ok {
+			flags[index].Config = &configs[i]
+			continue
+		}
+		flags = append(flags, FeatureFlag{Flag: feature_flag.Flag{Name: configs[i].Name}, Config: &configs[i]})
+	}
+
+	return entities.NewSuccessResponse(flags)
+}
+
+// UpdateFeatureFlag configures the flag, the configuration replaces the default of the flag
+func UpdateFeatureFlag(
+	name string,
+	enabled bool,
+	percentage int,
+	tenants []string,
+	blocked_tenants []string,
+) *entities.Response {
+	if !featureFlagName.MatchString(name) {
+		return exception.BadRequestError(fmt.Errorf("invalid feature flag name %q", name)).ToResponse()
+	}
+
+	flag, err := db.GetOne[models.FeatureFlag](
+		db.Equal("name", name),
+	)
+	if err != nil && err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	flag.Name = name
+	flag.Enabled = enabled
+	flag.Percentage = percentage
+	flag.Tenants = tenants
+	flag.BlockedTenants = blocked_tenants
+
+	if err == db.ErrDatabaseNotFound {
+		err = db.Create(&flag)
+	} else {
+		err = db.Update(&flag)
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	if err := feature_flag.Invalidate(); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(flag)
+}
+
+// DeleteFeatureFlag removes the configuration of the flag, the default of the flag applies again
+func DeleteFeatureFlag(name string) *entities.Response {
+	if err := db.DeleteByCondition(models.FeatureFlag{
+		Name: name,
+	}); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	if err := feature_flag.Invalidate(); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+// EvaluateFeatureFlags tells which flags declared or configured are on for the tenant
+func EvaluateFeatureFlags(tenant_id string) *entities.Response {
+	configs, err := db.GetAll[models.FeatureFlag]()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	flags := map[string]bool{}
+	for _, flag := range feature_flag.Declared() {
+		flags[flag.Name] = flag.Default
+	}
+	for _, config := range configs {
+		flags[config.Name] = feature_flag.Evaluate(config, tenant_id)
+	}
+
+	return entities.NewSuccessResponse(flags)
+}
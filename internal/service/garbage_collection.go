@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// CollectPluginGarbage reports plugin files no longer referenced by any plugin and deletes them unless dry_run is set
+func CollectPluginGarbage(config *app.Config, dry_run bool) *entities.Response {
+	manager := plugin_manager.Manager()
+	if manager == nil {
+		return exception.InternalServerError(errors.New("plugin manager is not initialized")).ToResponse()
+	}
+
+	report, err := manager.CollectGarbage(dry_run, time.Duration(config.PluginGCGracePeriod)*time.Second)
+	if errors.Is(err, plugin_manager.ErrGarbageCollectionRunning) {
+		return exception.BadRequestError(err).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(report)
+}
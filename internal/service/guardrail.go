@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/guardrail"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+// withGuardrails runs the guardrail pipeline of the tenant around the tool, parameters are inspected before
+// invoking it and chunks of the response are inspected by inspect as they arrive
+func withGuardrails[T any](
+	ctx *gin.Context,
+	session *session_manager.Session,
+	request *requests.RequestInvokeTool,
+	invoke func() (*stream.Stream[T], error),
+	inspect func(ctx *gin.Context, pipeline *guardrail.Pipeline, chunk *T) ([]guardrail.Finding, error),
+) func() (*stream.Stream[T], error) {
+	return func() (*stream.Stream[T], error) {
+		pipeline, err := guardrail.For(session.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load guardrails: %w", err)
+		}
+		if pipeline == nil {
+			return invoke()
+		}
+
+		subject := guardrail.Subject{
+			TenantID:  session.TenantID,
+			SessionID: session.ID,
+			PluginID:  session.PluginUniqueIdentifier.PluginID(),
+			Provider:  request.Provider,
+			Tool:      request.Tool,
+		}
+
+		parameters, findings, err := pipeline.InspectValue(
+			ctx.Request.Context(), guardrail.STAGE_INPUT, request.ToolParameters,
+		)
+		guardrail.Record(subject, findings)
+		if err != nil {
+			return nil, err
+		}
+		if parameters, ok := parameters.(map[string]any); ok {
+			request.ToolParameters = parameters
+		}
+
+		response, err := invoke()
+		if err != nil {
+			return nil, err
+		}
+		return guardrail.Guard(response, func(chunk *T) error {
+			findings, err := inspect(ctx, pipeline, chunk)
+			guardrail.Record(subject, findings)
+			return err
+		}), nil
+	}
+}
+
+// inspectToolResponseChunk inspects texts, json objects and values of variables responded by the tool
+func inspectToolResponseChunk(
+	ctx *gin.Context,
+	pipeline *guardrail.Pipeline,
+	chunk *tool_entities.ToolResponseChunk,
+) ([]guardrail.Finding, error) {
+	var key string
+	switch chunk.Type {
+	case tool_entities.ToolResponseChunkTypeText:
+		key = "text"
+	case tool_entities.ToolResponseChunkTypeJson:
+		key = "json_object"
+	case tool_entities.ToolResponseChunkTypeVariable:
+		key = "variable_value"
+	default:
+		return nil, nil
+	}
+
+	value, ok := chunk.Message[key]
+	if !ok {
+		return nil, nil
+	}
+	inspected, findings, err := pipeline.InspectValue(ctx.Request.Context(), guardrail.STAGE_OUTPUT, value)
+	if err != nil {
+		return findings, err
+	}
+	chunk.Message[key] = inspected
+	return findings, nil
+}
+
+// inspectToolStreamChunk inspects text and json chunks of typed tool streams
+func inspectToolStreamChunk(
+	ctx *gin.Context,
+	pipeline *guardrail.Pipeline,
+	chunk *tool_entities.ToolStreamChunk,
+) ([]guardrail.Finding, error) {
+	switch {
+	case chunk.Text != nil:
+		text, findings, err := pipeline.Inspect(ctx.Request.Context(), guardrail.STAGE_OUTPUT, chunk.Text.Text)
+		if err != nil {
+			return findings, err
+		}
+		chunk.Text.Text = text
+		return findings, nil
+	case chunk.Json != nil:
+		value, findings, err := pipeline.InspectValue(ctx.Request.Context(), guardrail.STAGE_OUTPUT, chunk.Json.Value)
+		if err != nil {
+			return findings, err
+		}
+		chunk.Json.Value = value
+		return findings, nil
+	}
+	return nil, nil
+}
+
+func GetGuardrailPolicy(tenant_id string) *entities.Response {
+	policy, err := db.GetOne[models.GuardrailPolicy](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		// no policy means no guardrails
+		return entities.NewSuccessResponse(models.GuardrailPolicy{
+			TenantID: tenant_id,
+			Hooks:    []models.GuardrailHook{},
+		})
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(policy)
+}
+
+func UpdateGuardrailPolicy(tenant_id string, enabled bool, hooks []models.GuardrailHook) *entities.Response {
+	if _, err := guardrail.Compile(hooks); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+	for _, hook := range hooks {
+		if hook.Type == guardrail.TYPE_MODERATION && !guardrail.ModerationConfigured() {
+			return exception.BadRequestError(errors.New("moderation api is not configured")).ToResponse()
+		}
+	}
+
+	policy, err := db.GetOne[models.GuardrailPolicy](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err != nil && err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	policy.TenantID = tenant_id
+	policy.Enabled = enabled
+	policy.Hooks = hooks
+
+	if err == db.ErrDatabaseNotFound {
+		err = db.Create(&policy)
+	} else {
+		err = db.Update(&policy)
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	guardrail.Invalidate(tenant_id)
+
+	return entities.NewSuccessResponse(policy)
+}
+
+// ListGuardrailEvents lists findings of guardrails of the tenant, the latest first, filters are ignored if empty
+func ListGuardrailEvents(
+	tenant_id string,
+	outcome string,
+	session_id string,
+	page int,
+	page_size int,
+) *entities.Response {
+	query := []db.GenericQuery{db.Equal("tenant_id", tenant_id)}
+	if outcome != "" {
+		query = append(query, db.Equal("outcome", outcome))
+	}
+	if session_id != "" {
+		query = append(query, db.Equal("session_id", session_id))
+	}
+	query = append(query, db.OrderBy("created_at", true), db.Page(page, page_size))
+
+	events, err := db.GetAll[models.GuardrailEvent](query...)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(events)
+}
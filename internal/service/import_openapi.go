@@ -0,0 +1,30 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/openapi_converter"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// ImportOpenAPISpec generates a tool plugin from an OpenAPI 3 spec and packs it as a plugin source,
+// the plugin is installed to the tenant if install is true
+func ImportOpenAPISpec(
+	config *app.Config,
+	tenant_id string,
+	spec []byte,
+	options openapi_converter.Options,
+	install bool,
+) *entities.Response {
+	result, err := openapi_converter.Convert(spec, options)
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	archive, err := result.Archive()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return PackPlugin(config, tenant_id, PackPluginSource{Archive: archive}, install)
+}
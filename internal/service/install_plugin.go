@@ -5,14 +5,18 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/webhook"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
@@ -52,6 +56,17 @@ func InstallPluginRuntimeToTenant(
 		return nil, fmt.Errorf("unsupported platform: %s", config.Platform)
 	}
 
+	// refuse installations exceeding the storage quota of the tenant
+	if err := checkTenantStorageQuota(config, tenant_id, plugin_unique_identifiers); err != nil {
+		return nil, err
+	}
+
+	// policies are fetched once for all the plugins
+	policies, err := install_service.FetchPluginPolicies(tenant_id)
+	if err != nil {
+		return nil, err
+	}
+
 	task := &models.InstallTask{
 		Status:           models.InstallTaskStatusRunning,
 		TenantID:         tenant_id,
@@ -70,6 +85,21 @@ func InstallPluginRuntimeToTenant(
 			return nil, err
 		}
 
+		// refuse packages blocked by content scanning
+		if err := checkPluginScanReport(config, pluginUniqueIdentifier); err != nil {
+			return nil, err
+		}
+
+		// refuse plugins the tenant is not allowed to install
+		if err := install_service.EvaluatePluginPolicy(
+			policies,
+			pluginUniqueIdentifier.PluginID(),
+			pluginUniqueIdentifier.Author(),
+			pluginDeclaration.Verified,
+		).Err(); err != nil {
+			return nil, err
+		}
+
 		// check if plugin is already installed
 		_, err = db.GetOne[models.Plugin](
 			db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
@@ -109,7 +139,7 @@ func InstallPluginRuntimeToTenant(
 		return response, nil
 	}
 
-	err := db.Create(task)
+	err = db.Create(task)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +147,8 @@ func InstallPluginRuntimeToTenant(
 	response.TaskID = task.ID
 	manager := plugin_manager.Manager()
 
-	tasks := []func(){}
+	// tasks are called with the error rejecting them if the pool of installations is full
+	tasks := []func(rejected error){}
 	for i, pluginUniqueIdentifier := range pluginsWaitForInstallation {
 		// copy the variable to avoid race condition
 		pluginUniqueIdentifier := pluginUniqueIdentifier
@@ -131,8 +162,15 @@ func InstallPluginRuntimeToTenant(
 		}
 
 		i := i
-		tasks = append(tasks, func() {
+		tasks = append(tasks, func(rejected error) {
+			logger := log.Component(log.COMPONENT_INSTALL).
+				With(log.FIELD_TENANT_ID, tenant_id).
+				With(log.FIELD_PLUGIN_ID, pluginUniqueIdentifier.PluginID())
+
 			updateTaskStatus := func(modifier func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus)) {
+				var from, to models.InstallTaskStatus
+				var message string
+				var updated *models.InstallTask
 				if err := db.WithTransaction(func(tx *gorm.DB) error {
 					task, err := db.GetOne[models.InstallTask](
 						db.WithTransactionContext(tx),
@@ -161,7 +199,10 @@ func InstallPluginRuntimeToTenant(
 						return nil
 					}
 
+					from = pluginStatus.Status
 					modifier(taskPointer, pluginStatus)
+					to = pluginStatus.Status
+					message = pluginStatus.Message
 
 					successes := 0
 					for _, plugin := range taskPointer.Plugins {
@@ -178,12 +219,41 @@ func InstallPluginRuntimeToTenant(
 							db.Delete(taskPointer)
 						})
 					}
+					updated = taskPointer
 					return db.Update(taskPointer, tx)
 				}); err != nil {
-					log.Error("failed to update install task status %s", err.Error())
+					logger.Error("failed to update install task status %s", err.Error())
+				} else {
+					if updated != nil {
+						publishInstallTask(*updated)
+					}
+					metrics.RecordInstallTaskTransition(from, to)
+					if from != to {
+						logger.Debug(
+							"install task %s of plugin %s: %s -> %s", task.ID, pluginUniqueIdentifier, from, to,
+						)
+					}
+					if from != to && (to == models.InstallTaskStatusSuccess || to == models.InstallTaskStatusFailed) {
+						events.Publish(events.InstallCompleted{
+							TaskID:                 task.ID,
+							TenantID:               tenant_id,
+							PluginUniqueIdentifier: pluginUniqueIdentifier,
+							Succeeded:              to == models.InstallTaskStatusSuccess,
+							Message:                message,
+						})
+					}
 				}
 			}
 
+			if rejected != nil {
+				updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
+					task.Status = models.InstallTaskStatusFailed
+					plugin.Status = models.InstallTaskStatusFailed
+					plugin.Message = rejected.Error()
+				})
+				return
+			}
+
 			updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
 				plugin.Status = models.InstallTaskStatusRunning
 				plugin.Message = "Installing"
@@ -254,6 +324,22 @@ func InstallPluginRuntimeToTenant(
 					return
 				}
 
+				// persist stage transitions so that the progress can be inspected through the task
+				if message.Event == plugin_manager.PluginInstallEventInfo && message.Stage != "" {
+					updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
+						plugin.Message = message.Data
+						if len(plugin.Stages) > 0 && plugin.Stages[len(plugin.Stages)-1].Stage == string(message.Stage) {
+							plugin.Stages[len(plugin.Stages)-1].Message = message.Data
+							return
+						}
+						plugin.Stages = append(plugin.Stages, models.InstallTaskPluginStage{
+							Stage:     string(message.Stage),
+							Message:   message.Data,
+							StartedAt: time.Now(),
+						})
+					})
+				}
+
 				if message.Event == plugin_manager.PluginInstallEventDone {
 					if err := onDone(pluginUniqueIdentifier, declaration, metas[i]); err != nil {
 						updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
@@ -279,12 +365,77 @@ func InstallPluginRuntimeToTenant(
 		})
 	}
 
-	// submit async tasks
-	routine.WithMaxRoutine(5, tasks)
+	// submit async tasks, installations run on their own pool, plugins beyond its queue fail at once
+	for _, install := range tasks {
+		if err := routine.SubmitTo(routine.POOL_INSTALL_TASK, map[string]string{
+			"module":   "service",
+			"function": "InstallPluginRuntimeToTenant",
+		}, func() {
+			install(nil)
+		}); err != nil {
+			install(err)
+		}
+	}
 
 	return response, nil
 }
 
+// installPluginToTenant returns the handler to create the installation record once the runtime is ready
+func installPluginToTenant(
+	config *app.Config,
+	tenant_id string,
+	source string,
+) InstallPluginOnDoneHandler {
+	return func(
+		pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+		declaration *plugin_entities.PluginDeclaration,
+		meta map[string]any,
+	) error {
+		runtimeType := plugin_entities.PluginRuntimeType("")
+
+		switch config.Platform {
+		case app.PLATFORM_SERVERLESS:
+			runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS
+		case app.PLATFORM_LOCAL:
+			runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
+		default:
+			return fmt.Errorf("unsupported platform: %s", config.Platform)
+		}
+
+		_, installation, err := curd.InstallPlugin(
+			tenant_id,
+			pluginUniqueIdentifier,
+			runtimeType,
+			declaration,
+			source,
+			meta,
+		)
+		if err != nil {
+			return err
+		}
+
+		webhook.Dispatch(tenant_id, webhook.EVENT_PLUGIN_INSTALLED, map[string]any{
+			"plugin_id":                pluginUniqueIdentifier.PluginID(),
+			"plugin_unique_identifier": pluginUniqueIdentifier.String(),
+			"installation_id":          installation.ID,
+			"runtime_type":             runtimeType,
+			"source":                   source,
+		})
+		return nil
+	}
+}
+
+// installPluginError converts errors of InstallPluginRuntimeToTenant into daemon errors
+func installPluginError(err error) exception.PluginDaemonError {
+	if errors.Is(err, install_service.ErrPluginBlockedByPolicy) || errors.Is(err, ErrStorageQuotaExceeded) {
+		return exception.PermissionDeniedError(err.Error())
+	}
+	if errors.Is(err, curd.ErrPluginAlreadyInstalled) || errors.Is(err, ErrPluginBlockedByScan) {
+		return exception.BadRequestError(err)
+	}
+	return exception.InternalServerError(err)
+}
+
 func InstallPluginFromIdentifiers(
 	config *app.Config,
 	tenant_id string,
@@ -298,38 +449,10 @@ func InstallPluginFromIdentifiers(
 		plugin_unique_identifiers,
 		source,
 		metas,
-		func(
-			pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
-			declaration *plugin_entities.PluginDeclaration,
-			meta map[string]any,
-		) error {
-			runtimeType := plugin_entities.PluginRuntimeType("")
-
-			switch config.Platform {
-			case app.PLATFORM_SERVERLESS:
-				runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS
-			case app.PLATFORM_LOCAL:
-				runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
-			default:
-				return fmt.Errorf("unsupported platform: %s", config.Platform)
-			}
-
-			_, _, err := curd.InstallPlugin(
-				tenant_id,
-				pluginUniqueIdentifier,
-				runtimeType,
-				declaration,
-				source,
-				meta,
-			)
-			return err
-		},
+		installPluginToTenant(config, tenant_id, source),
 	)
 	if err != nil {
-		if errors.Is(err, curd.ErrPluginAlreadyInstalled) {
-			return exception.BadRequestError(err).ToResponse()
-		}
-		return exception.InternalServerError(err).ToResponse()
+		return installPluginError(err).ToResponse()
 	}
 
 	return entities.NewSuccessResponse(response)
@@ -425,32 +548,47 @@ func UpgradePlugin(
 				}
 			}
 
+			webhook.Dispatch(tenant_id, webhook.EVENT_PLUGIN_UPGRADED, map[string]any{
+				"plugin_id":                         new_plugin_unique_identifier.PluginID(),
+				"original_plugin_unique_identifier": original_plugin_unique_identifier.String(),
+				"plugin_unique_identifier":          new_plugin_unique_identifier.String(),
+				"installation_id":                   installation.ID,
+				"runtime_type":                      installation.RuntimeType,
+				"source":                            source,
+			})
 			return nil
 		},
 	)
 
 	if err != nil {
-		return exception.InternalServerError(err).ToResponse()
+		return installPluginError(err).ToResponse()
 	}
 
 	return entities.NewSuccessResponse(response)
 }
 
+// FetchPluginInstallationTasks lists installation tasks of the tenant, tasks are filtered by the status
+// and by the plugins they install
 func FetchPluginInstallationTasks(
 	tenant_id string,
-	page int,
-	page_size int,
+	status models.InstallTaskStatus,
+	options ListOptions,
 ) *entities.Response {
-	tasks, err := db.GetAll[models.InstallTask](
-		db.Equal("tenant_id", tenant_id),
-		db.OrderBy("created_at", true),
-		db.Page(page, page_size),
-	)
+	filters := []db.GenericQuery{db.Equal("tenant_id", tenant_id)}
+	if status != "" {
+		filters = append(filters, db.Equal("status", string(status)))
+	}
+	if options.PluginID != "" {
+		// plugins of a task are stored in json
+		filters = append(filters, db.Like("plugins", fmt.Sprintf(`"plugin_id":%q`, options.PluginID)))
+	}
+
+	tasks, total, err := listPage[models.InstallTask](options, db.OrderBy("created_at", true), filters...)
 	if err != nil {
 		return exception.InternalServerError(err).ToResponse()
 	}
 
-	return entities.NewSuccessResponse(tasks)
+	return entities.NewSuccessResponse(newList(tasks, total, options))
 }
 
 func FetchPluginInstallationTask(
@@ -584,9 +722,20 @@ func UninstallPlugin(
 		return exception.InternalServerError(err).ToResponse()
 	}
 
+	if err := uninstallPlugin(tenant_id, &installation); err != nil {
+		return err.ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+func uninstallPlugin(
+	tenant_id string,
+	installation *models.PluginInstallation,
+) exception.PluginDaemonError {
 	pluginUniqueIdentifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
 	if err != nil {
-		return exception.UniqueIdentifierError(err).ToResponse()
+		return exception.UniqueIdentifierError(err)
 	}
 
 	// get declaration
@@ -595,7 +744,7 @@ func UninstallPlugin(
 		plugin_entities.PluginRuntimeType(installation.RuntimeType),
 	)
 	if err != nil {
-		return exception.InternalServerError(err).ToResponse()
+		return exception.InternalServerError(err)
 	}
 
 	// Uninstall the plugin
@@ -606,7 +755,7 @@ func UninstallPlugin(
 		declaration,
 	)
 	if err != nil {
-		return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin: %s", err.Error())).ToResponse()
+		return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin: %s", err.Error()))
 	}
 
 	if deleteResponse.IsPluginDeleted {
@@ -617,10 +766,16 @@ func UninstallPlugin(
 		) {
 			err = manager.UninstallFromLocal(pluginUniqueIdentifier)
 			if err != nil {
-				return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin: %s", err.Error())).ToResponse()
+				return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin: %s", err.Error()))
 			}
 		}
 	}
 
-	return entities.NewSuccessResponse(true)
+	webhook.Dispatch(tenant_id, webhook.EVENT_PLUGIN_UNINSTALLED, map[string]any{
+		"plugin_id":                pluginUniqueIdentifier.PluginID(),
+		"plugin_unique_identifier": pluginUniqueIdentifier.String(),
+		"installation_id":          installation.ID,
+		"runtime_type":             installation.RuntimeType,
+	})
+	return nil
 }
@@ -0,0 +1,131 @@
+package install_service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	str "github.com/langgenius/dify-plugin-daemon/internal/utils/strings"
+)
+
+var (
+	ErrPluginBlockedByPolicy = errors.New("blocked by policy")
+)
+
+// PolicyError carries the reason why a plugin was blocked, it matches ErrPluginBlockedByPolicy
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPluginBlockedByPolicy
+}
+
+type PolicyDecision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Err returns a PolicyError if the plugin is not allowed
+func (d PolicyDecision) Err() error {
+	if d.Allowed {
+		return nil
+	}
+	return &PolicyError{Reason: d.Reason}
+}
+
+// EvaluatePluginPolicy checks a plugin against all the given policies, a plugin is allowed only
+// if every policy allows it
+func EvaluatePluginPolicy(
+	policies []models.TenantPluginPolicy,
+	plugin_id string,
+	author string,
+	verified bool,
+) PolicyDecision {
+	for _, policy := range policies {
+		if decision := evaluatePolicy(&policy, plugin_id, author, verified); !decision.Allowed {
+			return decision
+		}
+	}
+
+	return PolicyDecision{Allowed: true}
+}
+
+func evaluatePolicy(
+	policy *models.TenantPluginPolicy,
+	plugin_id string,
+	author string,
+	verified bool,
+) PolicyDecision {
+	scope := "tenant"
+	if policy.TenantID == models.PLUGIN_POLICY_GLOBAL_TENANT {
+		scope = "global"
+	}
+
+	blocked := func(reason string) PolicyDecision {
+		return PolicyDecision{
+			Allowed: false,
+			Reason:  fmt.Sprintf("blocked by %s policy, %s", scope, reason),
+		}
+	}
+
+	if containsFold(policy.BlockedPlugins, plugin_id) {
+		return blocked(fmt.Sprintf("plugin %s is blocked", plugin_id))
+	}
+
+	if containsFold(policy.BlockedAuthors, author) {
+		return blocked(fmt.Sprintf("author %s is blocked", author))
+	}
+
+	if len(policy.AllowedPlugins) > 0 || len(policy.AllowedAuthors) > 0 {
+		if !containsFold(policy.AllowedPlugins, plugin_id) && !containsFold(policy.AllowedAuthors, author) {
+			return blocked(fmt.Sprintf("plugin %s is not in the allowlist", plugin_id))
+		}
+	}
+
+	if policy.VerifiedOnly && !verified {
+		return blocked("only verified plugins are allowed")
+	}
+
+	return PolicyDecision{Allowed: true}
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchPluginPolicies returns the global policy and the policy of the tenant if they exist
+func FetchPluginPolicies(tenant_id string) ([]models.TenantPluginPolicy, error) {
+	return db.GetAll[models.TenantPluginPolicy](
+		db.InArray("tenant_id", str.Map(
+			[]string{models.PLUGIN_POLICY_GLOBAL_TENANT, tenant_id},
+			func(id string) any { return id },
+		)),
+	)
+}
+
+// CheckPluginPolicy returns ErrPluginBlockedByPolicy if the tenant is not allowed to install the plugin
+func CheckPluginPolicy(
+	tenant_id string,
+	plugin_id string,
+	author string,
+	verified bool,
+) error {
+	policies, err := FetchPluginPolicies(tenant_id)
+	if err != nil {
+		return err
+	}
+
+	return EvaluatePluginPolicy(policies, plugin_id, author, verified).Err()
+}
@@ -0,0 +1,68 @@
+package install_service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func TestEvaluatePluginPolicy(t *testing.T) {
+	policies := []models.TenantPluginPolicy{
+		{
+			TenantID:       models.PLUGIN_POLICY_GLOBAL_TENANT,
+			BlockedAuthors: []string{"evil"},
+		},
+		{
+			TenantID:       "tenant",
+			AllowedAuthors: []string{"langgenius"},
+			AllowedPlugins: []string{"someone/allowed"},
+			BlockedPlugins: []string{"langgenius/blocked"},
+			VerifiedOnly:   true,
+		},
+	}
+
+	cases := []struct {
+		pluginID string
+		author   string
+		verified bool
+		allowed  bool
+	}{
+		{"langgenius/openai", "langgenius", true, true},
+		{"langgenius/openai", "langgenius", false, false},
+		{"langgenius/blocked", "langgenius", true, false},
+		{"someone/allowed", "someone", true, true},
+		{"someone/other", "someone", true, false},
+		{"evil/allowed", "evil", true, false},
+		{"LangGenius/OpenAI", "LangGenius", true, true},
+	}
+
+	for _, c := range cases {
+		decision := EvaluatePluginPolicy(policies, c.pluginID, c.author, c.verified)
+		if decision.Allowed != c.allowed {
+			t.Errorf("plugin %s: expected allowed=%v, got %v (%s)", c.pluginID, c.allowed, decision.Allowed, decision.Reason)
+		}
+		if !decision.Allowed && decision.Reason == "" {
+			t.Errorf("plugin %s: blocked decision should carry a reason", c.pluginID)
+		}
+	}
+
+	if decision := EvaluatePluginPolicy(nil, "any/plugin", "any", false); !decision.Allowed {
+		t.Errorf("no policy should allow everything")
+	}
+
+	decision := EvaluatePluginPolicy(policies, "evil/allowed", "evil", true)
+	if decision.Reason != "blocked by global policy, author evil is blocked" {
+		t.Errorf("unexpected reason %q", decision.Reason)
+	}
+	if err := decision.Err(); !errors.Is(err, ErrPluginBlockedByPolicy) || err.Error() != decision.Reason {
+		t.Errorf("expected a policy error carrying the reason, got %v", err)
+	}
+}
+
+func TestPolicyError(t *testing.T) {
+	var err error = &PolicyError{Reason: "blocked by tenant policy"}
+	if !errors.Is(err, ErrPluginBlockedByPolicy) {
+		t.Errorf("policy error should match ErrPluginBlockedByPolicy")
+	}
+}
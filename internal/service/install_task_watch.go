@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+)
+
+const (
+	INSTALL_TASK_CHANNEL_PREFIX = "plugin_daemon:install_task:"
+
+	// tasks are fetched periodically as well, in case a transition is missed by the subscription
+	installTaskPollInterval = 5 * time.Second
+)
+
+func installTaskChannel(task_id string) string {
+	return INSTALL_TASK_CHANNEL_PREFIX + task_id
+}
+
+// publishInstallTask notifies watchers of the task on all nodes of a transition
+func publishInstallTask(task models.InstallTask) {
+	if err := cache.Publish(installTaskChannel(task.ID), task); err != nil {
+		log.Warn("failed to publish install task %s: %s", task.ID, err.Error())
+	}
+}
+
+// installTaskFinished returns true once all the plugins of the task are installed or failed
+func installTaskFinished(task models.InstallTask) bool {
+	for _, plugin := range task.Plugins {
+		if plugin.Status != models.InstallTaskStatusSuccess && plugin.Status != models.InstallTaskStatusFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// WatchPluginInstallationTask streams the task as server sent events, the current state is sent first
+// and then each transition of it, the stream ends once the task is finished or deleted
+func WatchPluginInstallationTask(
+	ctx *gin.Context,
+	tenant_id string,
+	task_id string,
+	max_timeout_seconds int,
+) {
+	_, err := db.GetOne[models.InstallTask](
+		db.Equal("id", task_id),
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		ctx.JSON(200, exception.NotFoundError(errors.New("install task not found")).ToResponse())
+		return
+	}
+	if err != nil {
+		ctx.JSON(200, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	baseSSEService(func() (*stream.Stream[models.InstallTask], error) {
+		return watchInstallTask(tenant_id, task_id)
+	}, ctx, max_timeout_seconds)
+}
+
+func watchInstallTask(tenant_id string, task_id string) (*stream.Stream[models.InstallTask], error) {
+	// subscribe before fetching the task so that no transition is missed in between
+	updates, unsubscribe := cache.Subscribe[models.InstallTask](installTaskChannel(task_id))
+	stopWatching := func() {
+		unsubscribe()
+		// the subscription blocks on delivering until the channel is closed
+		go func() {
+			for range updates {
+			}
+		}()
+	}
+
+	fetch := func() (models.InstallTask, error) {
+		return db.GetOne[models.InstallTask](
+			db.Equal("id", task_id),
+			db.Equal("tenant_id", tenant_id),
+		)
+	}
+
+	task, err := fetch()
+	if err != nil {
+		stopWatching()
+		return nil, err
+	}
+
+	response := stream.NewStream[models.InstallTask](128)
+	response.Write(task)
+	if installTaskFinished(task) {
+		stopWatching()
+		response.Close()
+		return response, nil
+	}
+
+	closed := make(chan bool)
+	response.OnClose(func() {
+		close(closed)
+	})
+
+	routine.Submit(map[string]string{
+		"module":   "service",
+		"function": "watchInstallTask",
+	}, func() {
+		defer stopWatching()
+		defer response.Close()
+
+		ticker := time.NewTicker(installTaskPollInterval)
+		defer ticker.Stop()
+
+		lastUpdatedAt := task.UpdatedAt
+		// send writes transitions only, snapshots older than the last one sent are dropped, returns true once finished
+		send := func(task models.InstallTask) bool {
+			if task.UpdatedAt.After(lastUpdatedAt) {
+				lastUpdatedAt = task.UpdatedAt
+				response.Write(task)
+			}
+			return installTaskFinished(task)
+		}
+
+		for {
+			select {
+			case <-closed:
+				return
+			case task, ok := <-updates:
+				if !ok || send(task) {
+					return
+				}
+			case <-ticker.C:
+				task, err := fetch()
+				if err == db.ErrDatabaseNotFound {
+					return
+				}
+				if err != nil {
+					log.Warn("failed to fetch install task %s: %s", task_id, err.Error())
+					continue
+				}
+				if send(task) {
+					return
+				}
+			}
+		}
+	})
+
+	return response, nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func TestInstallTaskFinished(t *testing.T) {
+	task := models.InstallTask{Plugins: []models.InstallTaskPluginStatus{
+		{Status: models.InstallTaskStatusSuccess},
+		{Status: models.InstallTaskStatusRunning},
+	}}
+	if installTaskFinished(task) {
+		t.Fatal("task with a running plugin is not finished")
+	}
+
+	// a failed plugin fails the task, but the others keep installing
+	task.Plugins[0].Status = models.InstallTaskStatusFailed
+	if installTaskFinished(task) {
+		t.Fatal("task with a running plugin is not finished")
+	}
+
+	task.Plugins[1].Status = models.InstallTaskStatusSuccess
+	if !installTaskFinished(task) {
+		t.Fatal("task should be finished once all plugins are installed or failed")
+	}
+}
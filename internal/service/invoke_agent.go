@@ -22,7 +22,7 @@ func InvokeAgentStrategy(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_AGENT_STRATEGY,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_AGENT_STRATEGY,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -40,3 +40,34 @@ func InvokeAgentStrategy(
 		max_timeout_seconds,
 	)
 }
+
+// InvokeAgentStrategyEvents streams the trace of the agent strategy as events, see
+// plugin_daemon.InvokeAgentStrategyEvents
+func InvokeAgentStrategyEvents(
+	r *plugin_entities.InvokePluginRequest[requests.RequestInvokeAgentStrategy],
+	ctx *gin.Context,
+	max_timeout_seconds int,
+) {
+	// create session
+	session, err := createSession(
+		r,
+		access_types.PLUGIN_ACCESS_TYPE_AGENT_STRATEGY,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_AGENT_STRATEGY,
+		ctx,
+	)
+	if err != nil {
+		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		return
+	}
+	defer session.Close(session_manager.CloseSessionPayload{
+		IgnoreCache: false,
+	})
+
+	baseSSEService(
+		func() (*stream.Stream[agent_entities.AgentEvent], error) {
+			return plugin_daemon.InvokeAgentStrategyEvents(session, &r.Data)
+		},
+		ctx,
+		max_timeout_seconds,
+	)
+}
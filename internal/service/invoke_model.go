@@ -22,7 +22,7 @@ func InvokeLLM(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_LLM,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -33,9 +33,11 @@ func InvokeLLM(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.LLMResultChunk], error) {
-			return plugin_daemon.InvokeLLM(session, &r.Data)
-		},
+		withModelCache(ctx, session, &r.Data, withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.LLMResultChunk], error) {
+				return plugin_daemon.InvokeLLM(session, &r.Data)
+			},
+		)),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -51,7 +53,7 @@ func InvokeTextEmbedding(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TEXT_EMBEDDING,
-		ctx.GetString("cluster_id"))
+		ctx)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
 		return
@@ -61,9 +63,11 @@ func InvokeTextEmbedding(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.TextEmbeddingResult], error) {
-			return plugin_daemon.InvokeTextEmbedding(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.TextEmbeddingResult], error) {
+				return plugin_daemon.InvokeTextEmbedding(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -79,7 +83,7 @@ func InvokeRerank(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_RERANK,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -90,9 +94,11 @@ func InvokeRerank(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.RerankResult], error) {
-			return plugin_daemon.InvokeRerank(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.RerankResult], error) {
+				return plugin_daemon.InvokeRerank(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -108,7 +114,7 @@ func InvokeTTS(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TTS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -119,9 +125,11 @@ func InvokeTTS(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.TTSResult], error) {
-			return plugin_daemon.InvokeTTS(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.TTSResult], error) {
+				return plugin_daemon.InvokeTTS(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -137,7 +145,7 @@ func InvokeSpeech2Text(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_SPEECH2TEXT,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -148,9 +156,11 @@ func InvokeSpeech2Text(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.Speech2TextResult], error) {
-			return plugin_daemon.InvokeSpeech2Text(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.Speech2TextResult], error) {
+				return plugin_daemon.InvokeSpeech2Text(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -166,7 +176,7 @@ func InvokeModeration(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_MODERATION,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -177,9 +187,11 @@ func InvokeModeration(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.ModerationResult], error) {
-			return plugin_daemon.InvokeModeration(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.ModerationResult], error) {
+				return plugin_daemon.InvokeModeration(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -195,7 +207,7 @@ func ValidateProviderCredentials(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_PROVIDER_CREDENTIALS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -224,7 +236,7 @@ func ValidateModelCredentials(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_MODEL_CREDENTIALS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -252,7 +264,7 @@ func GetTTSModelVoices(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_TTS_MODEL_VOICES,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -263,9 +275,11 @@ func GetTTSModelVoices(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.GetTTSVoicesResponse], error) {
-			return plugin_daemon.GetTTSModelVoices(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.GetTTSVoicesResponse], error) {
+				return plugin_daemon.GetTTSModelVoices(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -280,7 +294,7 @@ func GetTextEmbeddingNumTokens(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_TEXT_EMBEDDING_NUM_TOKENS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -291,9 +305,11 @@ func GetTextEmbeddingNumTokens(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.GetTextEmbeddingNumTokensResponse], error) {
-			return plugin_daemon.GetTextEmbeddingNumTokens(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.GetTextEmbeddingNumTokensResponse], error) {
+				return plugin_daemon.GetTextEmbeddingNumTokens(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -308,7 +324,7 @@ func GetAIModelSchema(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_AI_MODEL_SCHEMAS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -319,9 +335,11 @@ func GetAIModelSchema(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.GetModelSchemasResponse], error) {
-			return plugin_daemon.GetAIModelSchema(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.GetModelSchemasResponse], error) {
+				return plugin_daemon.GetAIModelSchema(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -336,7 +354,7 @@ func GetLLMNumTokens(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_LLM_NUM_TOKENS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -347,9 +365,11 @@ func GetLLMNumTokens(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[model_entities.LLMGetNumTokensResponse], error) {
-			return plugin_daemon.GetLLMNumTokens(session, &r.Data)
-		},
+		withPooledCredentials(session, r.Data.Provider, &r.Data.Credentials,
+			func() (*stream.Stream[model_entities.LLMGetNumTokensResponse], error) {
+				return plugin_daemon.GetLLMNumTokens(session, &r.Data)
+			},
+		),
 		ctx,
 		max_timeout_seconds,
 	)
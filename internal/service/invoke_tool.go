@@ -22,7 +22,7 @@ func InvokeTool(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_TOOL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -33,9 +33,39 @@ func InvokeTool(
 	})
 
 	baseSSEService(
-		func() (*stream.Stream[tool_entities.ToolResponseChunk], error) {
+		withGuardrails(ctx, session, &r.Data, func() (*stream.Stream[tool_entities.ToolResponseChunk], error) {
 			return plugin_daemon.InvokeTool(session, &r.Data)
-		},
+		}, inspectToolResponseChunk),
+		ctx,
+		max_timeout_seconds,
+	)
+}
+
+// InvokeToolTyped streams typed chunks of the tool, see plugin_daemon.InvokeToolTyped
+func InvokeToolTyped(
+	r *plugin_entities.InvokePluginRequest[requests.RequestInvokeTool],
+	ctx *gin.Context,
+	max_timeout_seconds int,
+) {
+	// create session
+	session, err := createSession(
+		r,
+		access_types.PLUGIN_ACCESS_TYPE_TOOL,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL,
+		ctx,
+	)
+	if err != nil {
+		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		return
+	}
+	defer session.Close(session_manager.CloseSessionPayload{
+		IgnoreCache: false,
+	})
+
+	baseSSEService(
+		withGuardrails(ctx, session, &r.Data, func() (*stream.Stream[tool_entities.ToolStreamChunk], error) {
+			return plugin_daemon.InvokeToolTyped(session, &r.Data)
+		}, inspectToolStreamChunk),
 		ctx,
 		max_timeout_seconds,
 	)
@@ -51,7 +81,7 @@ func ValidateToolCredentials(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_TOOL,
 		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_TOOL_CREDENTIALS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -80,7 +110,7 @@ func GetToolRuntimeParameters(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_TOOL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_TOOL_RUNTIME_PARAMETERS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
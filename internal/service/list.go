@@ -0,0 +1,81 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions are the pagination and the filters of list apis, filters not supported by an api are ignored
+type ListOptions struct {
+	Page     int
+	PageSize int
+	PluginID string
+	// Search matches names, plugins are matched by their plugin ids as names are not stored
+	Search  string
+	Enabled *bool
+}
+
+// NewListOptions returns options of the page, the cursor replied by the previous page takes precedence
+func NewListOptions(page int, pageSize int, cursor string) (ListOptions, error) {
+	if cursor != "" {
+		var err error
+		page, err = decodeCursor(cursor)
+		if err != nil {
+			return ListOptions{}, err
+		}
+	}
+	if page < 1 {
+		page = 1
+	}
+	return ListOptions{Page: page, PageSize: pageSize}, nil
+}
+
+// cursors are opaque to callers, so that pages may be turned into keyset pagination later
+func encodeCursor(page int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("page:" + strconv.Itoa(page)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(decoded) <= len("page:") || string(decoded[:len("page:")]) != "page:" {
+		return 0, ErrInvalidCursor
+	}
+	page, err := strconv.Atoi(string(decoded[len("page:"):]))
+	if err != nil || page < 1 {
+		return 0, ErrInvalidCursor
+	}
+	return page, nil
+}
+
+// newList returns the list of a page, the next cursor is set if there are items after the page
+func newList[T any](items []T, total int64, options ListOptions) entities.List[T] {
+	if items == nil {
+		items = []T{}
+	}
+	list := entities.List[T]{Items: items, Total: total}
+	if int64(options.Page*options.PageSize) < total {
+		list.NextCursor = encodeCursor(options.Page + 1)
+	}
+	return list
+}
+
+// listPage counts the items matching the filters and fetches the page of them
+func listPage[T any](options ListOptions, order db.GenericQuery, filters ...db.GenericQuery) ([]T, int64, error) {
+	total, err := db.GetCount[T](filters...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := append([]db.GenericQuery{}, filters...)
+	items, err := db.GetAll[T](append(query, order, db.Page(options.Page, options.PageSize))...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
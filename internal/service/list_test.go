@@ -0,0 +1,34 @@
+package service
+
+import "testing"
+
+func TestListCursor(t *testing.T) {
+	options, err := NewListOptions(1, 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := newList([]int{1, 2, 3}, 25, options)
+	if list.NextCursor == "" {
+		t.Fatal("first page of 25 items should have a next cursor")
+	}
+
+	options, err = NewListOptions(0, 10, list.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Page != 2 {
+		t.Fatalf("cursor should point to page 2, got %d", options.Page)
+	}
+
+	options.Page = 3
+	if list := newList[int](nil, 25, options); list.NextCursor != "" || list.Items == nil {
+		t.Fatalf("last page should have no next cursor and empty items, got %+v", list)
+	}
+
+	for _, cursor := range []string{"not a cursor", encodeCursor(0), "cGFnZTp4"} {
+		if _, err := NewListOptions(1, 10, cursor); err != ErrInvalidCursor {
+			t.Fatalf("cursor %q should be invalid, got %v", cursor, err)
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
-func ListPlugins(tenant_id string, page int, page_size int) *entities.Response {
+func ListPlugins(tenant_id string, options ListOptions) *entities.Response {
 	type installation struct {
 		ID                     string                             `json:"id"`
 		Name                   string                             `json:"name"`
@@ -34,9 +34,18 @@ func ListPlugins(tenant_id string, page int, page_size int) *entities.Response {
 		Meta                   map[string]any                     `json:"meta"`
 	}
 
-	pluginInstallations, err := db.GetAll[models.PluginInstallation](
-		db.Equal("tenant_id", tenant_id),
-		db.Page(page, page_size),
+	filters := []db.GenericQuery{db.Equal("tenant_id", tenant_id)}
+	if options.PluginID != "" {
+		filters = append(filters, db.Equal("plugin_id", options.PluginID))
+	}
+	if options.Search != "" {
+		filters = append(filters, db.Like("plugin_id", options.Search))
+	}
+
+	pluginInstallations, total, err := listPage[models.PluginInstallation](
+		options,
+		db.OrderBy("created_at", true),
+		filters...,
 	)
 
 	if err != nil {
@@ -81,7 +90,7 @@ func ListPlugins(tenant_id string, page int, page_size int) *entities.Response {
 		})
 	}
 
-	return entities.NewSuccessResponse(data)
+	return entities.NewSuccessResponse(newList(data, total, options))
 }
 
 // Using plugin_ids to fetch plugin installations
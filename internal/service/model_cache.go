@@ -0,0 +1,61 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/feature_flag"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/model_cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+// withModelCache replies deterministic invocations from the model response cache, the key is taken before
+// invoking as credentials referencing credential pools are replaced by the invocation
+func withModelCache(
+	ctx *gin.Context,
+	session *session_manager.Session,
+	request *requests.RequestInvokeLLM,
+	invoke func() (*stream.Stream[model_entities.LLMResultChunk], error),
+) func() (*stream.Stream[model_entities.LLMResultChunk], error) {
+	return func() (*stream.Stream[model_entities.LLMResultChunk], error) {
+		if !model_cache.Enabled() ||
+			!feature_flag.Enabled(feature_flag.MODEL_RESPONSE_CACHE, session.TenantID) ||
+			!model_cache.Cacheable(request) {
+			return invoke()
+		}
+		if ctx.GetHeader(constants.X_MODEL_CACHE) == model_cache.STATUS_BYPASS {
+			ctx.Header(constants.X_MODEL_CACHE, model_cache.STATUS_BYPASS)
+			return invoke()
+		}
+
+		key, err := model_cache.Key(session.TenantID, session.PluginUniqueIdentifier.String(), request)
+		if err != nil {
+			log.FromContext(ctx.Request.Context()).Warn("failed to key model response cache: %s", err.Error())
+			return invoke()
+		}
+		if chunks, ok := model_cache.Lookup[model_entities.LLMResultChunk](key); ok {
+			ctx.Header(constants.X_MODEL_CACHE, model_cache.STATUS_HIT)
+			return model_cache.Replay(chunks), nil
+		}
+
+		response, err := invoke()
+		if err != nil {
+			return nil, err
+		}
+		ctx.Header(constants.X_MODEL_CACHE, model_cache.STATUS_MISS)
+		return model_cache.Record(key, response), nil
+	}
+}
+
+// PurgeModelCache drops model responses cached for the tenant
+func PurgeModelCache(tenant_id string) *entities.Response {
+	if err := model_cache.Purge(tenant_id); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(true)
+}
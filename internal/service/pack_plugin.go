@@ -0,0 +1,327 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/config_loader"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
+)
+
+var (
+	ErrInvalidPluginSource = errors.New("invalid plugin source")
+)
+
+type PackPluginSource struct {
+	// Archive is a zip archive of the plugin source directory
+	Archive []byte
+	// GitRepository and GitRef point to the plugin source in a git repository, used if Archive is empty
+	GitRepository string
+	GitRef        string
+}
+
+// PackPlugin builds a package from the plugin source, validates and scans it and stores it as an
+// uploaded package, the plugin is installed to the tenant if install is true. Packages are never signed
+// by the daemon, so tenants can't make their plugins verified by packing them
+func PackPlugin(
+	config *app.Config,
+	tenant_id string,
+	source PackPluginSource,
+	install bool,
+) *entities.Response {
+	workspace, err := os.MkdirTemp("", "dify-plugin-pack-")
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	defer os.RemoveAll(workspace)
+
+	sourcePath := filepath.Join(workspace, "source")
+	if len(source.Archive) > 0 {
+		err = extractPluginSourceArchive(source.Archive, sourcePath, config.MaxPluginPackageSize)
+	} else if source.GitRepository != "" {
+		if config.PluginPackGitEnabled == nil || !*config.PluginPackGitEnabled {
+			return exception.BadRequestError(errors.New("packing plugins from git repositories is disabled")).ToResponse()
+		}
+		var guard *network.SSRFGuard
+		if guard, err = packPluginGuard(config); err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+		err = clonePluginSource(
+			source.GitRepository,
+			source.GitRef,
+			sourcePath,
+			time.Duration(config.PluginPackGitTimeout)*time.Second,
+			guard,
+		)
+	} else {
+		err = errors.Join(ErrInvalidPluginSource, errors.New("either a source archive or a git repository is required"))
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidPluginSource) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	sourceDecoder, err := decoder.NewFSPluginDecoder(sourcePath)
+	if err != nil {
+		return exception.BadRequestError(errors.Join(err, errors.New("failed to load plugin source"))).ToResponse()
+	}
+
+	pkg, err := packager.NewPackager(sourceDecoder).Pack(config.MaxPluginPackageSize)
+	if err != nil {
+		return exception.BadRequestError(errors.Join(err, errors.New("failed to pack plugin"))).ToResponse()
+	}
+
+	zipDecoder, err := decoder.NewZipPluginDecoderWithSizeLimit(pkg, config.MaxPluginPackageSize)
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	pluginUniqueIdentifier, err := zipDecoder.UniqueIdentity()
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	if pluginUniqueIdentifier.RemoteLike() {
+		return exception.BadRequestError(errors.New("author cannot be a uuid")).ToResponse()
+	}
+
+	scanReport, err := scanPluginPackage(config, pluginUniqueIdentifier, zipDecoder)
+	if err != nil {
+		if errors.Is(err, ErrPluginBlockedByScan) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	manager := plugin_manager.Manager()
+	declaration, err := manager.SavePackage(pluginUniqueIdentifier, pkg)
+	if err != nil {
+		return exception.BadRequestError(errors.Join(err, errors.New("failed to save package"))).ToResponse()
+	}
+
+	result := map[string]any{
+		"unique_identifier": pluginUniqueIdentifier,
+		"manifest":          declaration,
+		"scan_report":       scanReport,
+		"warnings":          plugin_entities.ValidateDeclaration(declaration),
+		"size":              len(pkg),
+	}
+
+	if install {
+		if config.ForceVerifyingSignature != nil && *config.ForceVerifyingSignature && !declaration.Verified {
+			return exception.BadRequestError(errors.New(
+				"plugin verification has been enabled, and packed plugins are not signed, sign the package offline and upload it instead",
+			)).ToResponse()
+		}
+
+		response, err := InstallPluginRuntimeToTenant(
+			config,
+			tenant_id,
+			[]plugin_entities.PluginUniqueIdentifier{pluginUniqueIdentifier},
+			"package",
+			[]map[string]any{{}},
+			installPluginToTenant(config, tenant_id, "package"),
+		)
+		if err != nil {
+			return installPluginError(err).ToResponse()
+		}
+		result["install"] = response
+	}
+
+	return entities.NewSuccessResponse(result)
+}
+
+// FetchPluginPackage returns the uploaded package, it's used to download packages built by PackPlugin
+func FetchPluginPackage(
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+) ([]byte, error) {
+	return plugin_manager.Manager().GetPackage(plugin_unique_identifier)
+}
+
+// extractPluginSourceArchive extracts a zip archive of the plugin source into target,
+// entries escaping the target directory and archives larger than maxSize are rejected
+// packPluginGuard returns the guard keeping git repositories inside the network of the daemon from
+// being fetched, nil if the ssrf protection is disabled
+func packPluginGuard(config *app.Config) (*network.SSRFGuard, error) {
+	if config.SSRFProtectionEnabled == nil || !*config.SSRFProtectionEnabled {
+		return nil, nil
+	}
+
+	return network.NewSSRFGuard(config_loader.Live(config).SSRFAllowedCIDRs, []string{"https"})
+}
+
+func extractPluginSourceArchive(archive []byte, target string, maxSize int64) error {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return errors.Join(ErrInvalidPluginSource, err)
+	}
+
+	// the archive may contain the source directory itself instead of its content
+	prefix := ""
+	if len(reader.File) > 0 {
+		first := strings.SplitN(reader.File[0].Name, "/", 2)[0] + "/"
+		prefix = first
+		for _, file := range reader.File {
+			if !strings.HasPrefix(file.Name, first) {
+				prefix = ""
+				break
+			}
+		}
+	}
+
+	totalSize := int64(0)
+	for _, file := range reader.File {
+		name := strings.TrimPrefix(file.Name, prefix)
+		if name == "" {
+			continue
+		}
+
+		if !file.Mode().IsRegular() && !file.Mode().IsDir() {
+			return errors.Join(ErrInvalidPluginSource, fmt.Errorf("unsupported file type: %s", file.Name))
+		}
+
+		path := filepath.Join(target, filepath.FromSlash(name))
+		if path != target && !strings.HasPrefix(path, target+string(os.PathSeparator)) {
+			return errors.Join(ErrInvalidPluginSource, fmt.Errorf("illegal file path: %s", file.Name))
+		}
+
+		if file.Mode().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		totalSize += int64(file.UncompressedSize64)
+		if totalSize > maxSize {
+			return errors.Join(ErrInvalidPluginSource, fmt.Errorf(
+				"plugin source is too large, please ensure the uncompressed size is less than %d bytes", maxSize,
+			))
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		if err := extractPluginSourceFile(file, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractPluginSourceFile(file *zip.File, path string) error {
+	reader, err := file.Open()
+	if err != nil {
+		return errors.Join(ErrInvalidPluginSource, err)
+	}
+	defer reader.Close()
+
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	// the declared size could be forged, never copy more than it
+	if _, err := io.CopyN(writer, reader, int64(file.UncompressedSize64)); err != nil && err != io.EOF {
+		return errors.Join(ErrInvalidPluginSource, err)
+	}
+
+	return nil
+}
+
+// clonePluginSource fetches a single revision of the repository into target, ref could be
+// a branch, a tag or a commit, the default branch is used if ref is empty. The repository is fetched
+// from the address checked by guard if it's set, redirects are never followed
+func clonePluginSource(
+	repository string,
+	ref string,
+	target string,
+	timeout time.Duration,
+	guard *network.SSRFGuard,
+) error {
+	repositoryURL, err := url.Parse(repository)
+	if err != nil || repositoryURL.Scheme != "https" || repositoryURL.Host == "" {
+		return errors.Join(ErrInvalidPluginSource, errors.New("only https git repositories are supported"))
+	}
+
+	if strings.HasPrefix(ref, "-") {
+		return errors.Join(ErrInvalidPluginSource, fmt.Errorf("invalid git ref: %s", ref))
+	}
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	options := []string{
+		"-c", "protocol.allow=never",
+		"-c", "protocol.https.allow=always",
+		"-c", "http.followRedirects=false",
+	}
+	if guard != nil {
+		ip, err := guard.ResolveHost(ctx, repositoryURL.Hostname())
+		if err != nil {
+			return errors.Join(ErrInvalidPluginSource, fmt.Errorf("git repository %s is not allowed", repositoryURL.Host))
+		}
+
+		// pin the checked address, git would resolve the host again otherwise
+		port := repositoryURL.Port()
+		if port == "" {
+			port = "443"
+		}
+		address := ip.String()
+		if ip.To4() == nil {
+			address = "[" + address + "]"
+		}
+		options = append(options, "-c", fmt.Sprintf("http.curloptResolve=%s:%s:%s", repositoryURL.Hostname(), port, address))
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", "--", repositoryURL.String(), ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", append(options, args...)...)
+		cmd.Dir = target
+		// never prompt for credentials
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			// outputs of git may carry responses of the remote, keep them in logs only
+			log.Warn("git %s of %s failed: %s: %s", args[0], repositoryURL.Redacted(), err.Error(), strings.TrimSpace(string(output)))
+			return errors.Join(ErrInvalidPluginSource, fmt.Errorf("git %s failed", args[0]))
+		}
+	}
+
+	// repository metadata is not part of the plugin
+	return os.RemoveAll(filepath.Join(target, ".git"))
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+)
+
+func buildSourceArchive(t *testing.T, files map[string]string) []byte {
+	buffer := new(bytes.Buffer)
+	writer := zip.NewWriter(buffer)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestExtractPluginSourceArchive(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "source")
+	archive := buildSourceArchive(t, map[string]string{
+		"manifest.yaml":      "version: 0.0.1",
+		"provider/tool.yaml": "identity: {}",
+	})
+
+	if err := extractPluginSourceArchive(archive, target, 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(target, "provider/tool.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "identity: {}" {
+		t.Fatalf("unexpected content: %s", content)
+	}
+}
+
+func TestExtractPluginSourceArchiveStripsRootDirectory(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "source")
+	archive := buildSourceArchive(t, map[string]string{
+		"my_plugin/manifest.yaml": "version: 0.0.1",
+		"my_plugin/main.py":       "print(1)",
+	})
+
+	if err := extractPluginSourceArchive(archive, target, 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(target, "manifest.yaml")); err != nil {
+		t.Fatalf("root directory should be stripped: %v", err)
+	}
+}
+
+func TestExtractPluginSourceArchiveRejectsIllegalArchives(t *testing.T) {
+	cases := map[string]map[string]string{
+		"escaping path": {
+			"manifest.yaml":    "version: 0.0.1",
+			"../../etc/passwd": "root",
+		},
+		"too large": {
+			"manifest.yaml": string(make([]byte, 2048)),
+		},
+	}
+
+	for name, files := range cases {
+		target := filepath.Join(t.TempDir(), "source")
+		err := extractPluginSourceArchive(buildSourceArchive(t, files), target, 1024)
+		if !errors.Is(err, ErrInvalidPluginSource) {
+			t.Fatalf("%s: expected ErrInvalidPluginSource, got %v", name, err)
+		}
+	}
+}
+
+func TestClonePluginSourceRejectsUnsafeSources(t *testing.T) {
+	for _, source := range [][2]string{
+		{"file:///etc", ""},
+		{"ssh://example.com/repo.git", ""},
+		{"https://example.com/repo.git", "--upload-pack=evil"},
+	} {
+		err := clonePluginSource(source[0], source[1], t.TempDir(), 0, nil)
+		if !errors.Is(err, ErrInvalidPluginSource) {
+			t.Fatalf("%v: expected ErrInvalidPluginSource, got %v", source, err)
+		}
+	}
+}
+
+func TestClonePluginSourceRejectsInternalHosts(t *testing.T) {
+	guard, err := network.NewSSRFGuard(nil, []string{"https"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, repository := range []string{
+		"https://127.0.0.1/repo.git",
+		"https://169.254.169.254/repo.git",
+		"https://[::1]:8443/repo.git",
+	} {
+		err := clonePluginSource(repository, "", t.TempDir(), time.Second, guard)
+		if !errors.Is(err, ErrInvalidPluginSource) {
+			t.Fatalf("%s: expected ErrInvalidPluginSource, got %v", repository, err)
+		}
+	}
+}
@@ -7,8 +7,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 	"github.com/langgenius/dify-plugin-daemon/pkg/bundle_packager"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
@@ -44,6 +46,14 @@ func UploadPluginPkg(
 		return exception.BadRequestError(errors.New("author cannot be a uuid")).ToResponse()
 	}
 
+	scanReport, err := scanPluginPackage(config, pluginUniqueIdentifier, decoder)
+	if err != nil {
+		if errors.Is(err, ErrPluginBlockedByScan) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		return exception.InternalServerError(err).ToResponse()
+	}
+
 	manager := plugin_manager.Manager()
 	declaration, err := manager.SavePackage(pluginUniqueIdentifier, pluginFile)
 	if err != nil {
@@ -61,6 +71,9 @@ func UploadPluginPkg(
 	return entities.NewSuccessResponse(map[string]any{
 		"unique_identifier": pluginUniqueIdentifier,
 		"manifest":          declaration,
+		"scan_report":       scanReport,
+		"permissions":       declaration.Resource.Permission.Requested(),
+		"warnings":          plugin_entities.ValidateDeclaration(declaration),
 	})
 }
 
@@ -133,6 +146,14 @@ func UploadPluginBundle(
 						return exception.BadRequestError(errors.Join(errors.New("failed to get package unique identifier"), err)).ToResponse()
 					}
 
+					scanReport, err := scanPluginPackage(config, pluginUniqueIdentifier, decoder)
+					if err != nil {
+						if errors.Is(err, ErrPluginBlockedByScan) {
+							return exception.BadRequestError(err).ToResponse()
+						}
+						return exception.InternalServerError(err).ToResponse()
+					}
+
 					declaration, err := manager.SavePackage(pluginUniqueIdentifier, asset)
 					if err != nil {
 						return exception.InternalServerError(errors.Join(errors.New("failed to save package"), err)).ToResponse()
@@ -151,6 +172,9 @@ func UploadPluginBundle(
 						"value": map[string]any{
 							"unique_identifier": pluginUniqueIdentifier,
 							"manifest":          declaration,
+							"scan_report":       scanReport,
+							"permissions":       declaration.Resource.Permission.Requested(),
+							"warnings":          plugin_entities.ValidateDeclaration(declaration),
 						},
 					})
 				}
@@ -164,21 +188,84 @@ func UploadPluginBundle(
 func FetchPluginManifest(
 	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
 ) *entities.Response {
+	pluginManifestCache, err := fetchPluginDeclaration(pluginUniqueIdentifier)
+	if err == helper.ErrPluginNotFound {
+		return exception.BadRequestError(errors.New("plugin not found")).ToResponse()
+	}
+
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(pluginManifestCache)
+}
+
+func fetchPluginDeclaration(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+) (*plugin_entities.PluginDeclaration, error) {
 	runtimeType := plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
 	if pluginUniqueIdentifier.RemoteLike() {
 		runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE
 	}
 
-	pluginManifestCache, err := helper.CombinedGetPluginDeclaration(
-		pluginUniqueIdentifier, runtimeType,
-	)
+	return helper.CombinedGetPluginDeclaration(pluginUniqueIdentifier, runtimeType)
+}
+
+// ValidatePluginDeclaration lints the declaration of the plugin, warnings never block installing it
+func ValidatePluginDeclaration(pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier) *entities.Response {
+	declaration, err := fetchPluginDeclaration(pluginUniqueIdentifier)
 	if err == helper.ErrPluginNotFound {
-		return exception.BadRequestError(errors.New("plugin not found")).ToResponse()
+		return exception.NotFoundError(errors.New("plugin not found")).ToResponse()
 	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(plugin_entities.ValidateDeclaration(declaration))
+}
+
+// DiffPluginDeclarations compares the declaration of the candidate to the original one, which is
+// the version installed by the tenant if it's not specified, to preview what changes once upgraded
+func DiffPluginDeclarations(
+	tenant_id string,
+	originalPluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+) *entities.Response {
+	if originalPluginUniqueIdentifier == "" {
+		installation, err := db.GetOne[models.PluginInstallation](
+			db.Equal("tenant_id", tenant_id),
+			db.Equal("plugin_id", pluginUniqueIdentifier.PluginID()),
+		)
+		if err == db.ErrDatabaseNotFound {
+			return exception.NotFoundError(errors.New("plugin is not installed")).ToResponse()
+		}
+		if err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
 
+		originalPluginUniqueIdentifier, err = plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+		if err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+	} else if originalPluginUniqueIdentifier.PluginID() != pluginUniqueIdentifier.PluginID() {
+		return exception.BadRequestError(errors.New("declarations of different plugins can't be compared")).ToResponse()
+	}
+
+	original, err := fetchPluginDeclaration(originalPluginUniqueIdentifier)
+	if err == helper.ErrPluginNotFound {
+		return exception.NotFoundError(errors.New("original plugin not found")).ToResponse()
+	}
 	if err != nil {
 		return exception.InternalServerError(err).ToResponse()
 	}
 
-	return entities.NewSuccessResponse(pluginManifestCache)
+	candidate, err := fetchPluginDeclaration(pluginUniqueIdentifier)
+	if err == helper.ErrPluginNotFound {
+		return exception.NotFoundError(errors.New("plugin not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(plugin_entities.DiffDeclarations(original, candidate))
 }
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func GetPluginPolicy(tenant_id string) *entities.Response {
+	policy, err := db.GetOne[models.TenantPluginPolicy](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		// no policy means everything is allowed
+		return entities.NewSuccessResponse(models.TenantPluginPolicy{
+			TenantID:       tenant_id,
+			AllowedPlugins: []string{},
+			BlockedPlugins: []string{},
+			AllowedAuthors: []string{},
+			BlockedAuthors: []string{},
+		})
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(policy)
+}
+
+func UpdatePluginPolicy(
+	tenant_id string,
+	allowed_plugins []string,
+	blocked_plugins []string,
+	allowed_authors []string,
+	blocked_authors []string,
+	verified_only bool,
+) *entities.Response {
+	policy, err := db.GetOne[models.TenantPluginPolicy](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err != nil && err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	policy.TenantID = tenant_id
+	policy.AllowedPlugins = allowed_plugins
+	policy.BlockedPlugins = blocked_plugins
+	policy.AllowedAuthors = allowed_authors
+	policy.BlockedAuthors = blocked_authors
+	policy.VerifiedOnly = verified_only
+
+	if err == db.ErrDatabaseNotFound {
+		err = db.Create(&policy)
+	} else {
+		err = db.Update(&policy)
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(policy)
+}
+
+func DeletePluginPolicy(tenant_id string) *entities.Response {
+	if err := db.DeleteByCondition(models.TenantPluginPolicy{
+		TenantID: tenant_id,
+	}); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+// CheckPluginPolicy tells whether the tenant is allowed to install the plugins,
+// it's used to mark plugins as blocked by policy while browsing the marketplace
+func CheckPluginPolicy(
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+) *entities.Response {
+	type PluginPolicyCheck struct {
+		PluginUniqueIdentifier string `json:"plugin_unique_identifier"`
+		install_service.PolicyDecision
+	}
+
+	policies, err := install_service.FetchPluginPolicies(tenant_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	result := make([]PluginPolicyCheck, 0, len(plugin_unique_identifiers))
+	for _, pluginUniqueIdentifier := range plugin_unique_identifiers {
+		// verification status is only known once the package has been uploaded,
+		// packages never seen before are treated as unverified
+		runtimeType := plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
+		if pluginUniqueIdentifier.RemoteLike() {
+			runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE
+		}
+
+		verified := false
+		declaration, err := helper.CombinedGetPluginDeclaration(pluginUniqueIdentifier, runtimeType)
+		if err == nil {
+			verified = declaration.Verified
+		}
+
+		result = append(result, PluginPolicyCheck{
+			PluginUniqueIdentifier: pluginUniqueIdentifier.String(),
+			PolicyDecision: install_service.EvaluatePluginPolicy(
+				policies,
+				pluginUniqueIdentifier.PluginID(),
+				pluginUniqueIdentifier.Author(),
+				verified,
+			),
+		})
+	}
+
+	return entities.NewSuccessResponse(result)
+}
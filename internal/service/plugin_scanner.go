@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/scanner"
+)
+
+var (
+	ErrPluginBlockedByScan = errors.New("plugin package was blocked by content scanning")
+)
+
+func newPluginScanPipeline(config *app.Config) *scanner.Pipeline {
+	return scanner.NewPipeline(
+		scanner.NewManifestScanner(),
+		scanner.NewDeclarationScanner(),
+		scanner.NewFileLimitScanner(config.PluginPackageScanMaxFiles, config.PluginPackageScanMaxFileSize),
+		scanner.NewDangerousFileScanner(),
+		scanner.NewRequirementsScanner(config.PluginPackageScanBlockedPackages),
+	)
+}
+
+func pluginPackageScanEnabled(config *app.Config) bool {
+	return config.PluginPackageScanEnabled != nil && *config.PluginPackageScanEnabled
+}
+
+// scanPluginPackage runs the scanning pipeline against the package and stores the report,
+// ErrPluginBlockedByScan is returned if the package is not allowed by the scan policy
+func scanPluginPackage(
+	config *app.Config,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	packageDecoder decoder.PluginDecoder,
+) (*scanner.Report, error) {
+	if !pluginPackageScanEnabled(config) {
+		return nil, nil
+	}
+
+	report, err := newPluginScanPipeline(config).Run(packageDecoder)
+	if err != nil {
+		return nil, errors.Join(err, errors.New("failed to scan plugin package"))
+	}
+
+	if err := savePluginScanReport(pluginUniqueIdentifier, report); err != nil {
+		return nil, err
+	}
+
+	if report.Blocking() {
+		if config.PluginPackageScanPolicy == app.PLUGIN_PACKAGE_SCAN_POLICY_BLOCK {
+			return report, errors.Join(ErrPluginBlockedByScan, errors.New(formatScanFindings(report)))
+		}
+		log.Warn("plugin package %s has scanning findings: %s", pluginUniqueIdentifier, formatScanFindings(report))
+	}
+
+	return report, nil
+}
+
+func savePluginScanReport(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	report *scanner.Report,
+) error {
+	record, err := db.GetOne[models.PluginScanReport](
+		db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return db.Create(&models.PluginScanReport{
+			PluginUniqueIdentifier: pluginUniqueIdentifier.String(),
+			Blocking:               report.Blocking(),
+			Findings:               report.Findings,
+		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	record.Blocking = report.Blocking()
+	record.Findings = report.Findings
+	return db.Update(&record)
+}
+
+// checkPluginScanReport checks the stored scan report before installing a plugin,
+// packages scanned before the policy was switched to block are rejected here
+func checkPluginScanReport(
+	config *app.Config,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+) error {
+	if !pluginPackageScanEnabled(config) || config.PluginPackageScanPolicy != app.PLUGIN_PACKAGE_SCAN_POLICY_BLOCK {
+		return nil
+	}
+
+	record, err := db.GetOne[models.PluginScanReport](
+		db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if record.Blocking {
+		return errors.Join(
+			ErrPluginBlockedByScan,
+			errors.New(formatScanFindings(&scanner.Report{Findings: record.Findings})),
+		)
+	}
+
+	return nil
+}
+
+func formatScanFindings(report *scanner.Report) string {
+	message := ""
+	for _, finding := range report.Findings {
+		if finding.Severity != scanner.SEVERITY_ERROR {
+			continue
+		}
+		if message != "" {
+			message += "; "
+		}
+		if finding.File != "" {
+			message += fmt.Sprintf("[%s] %s: %s", finding.Scanner, finding.File, finding.Message)
+		} else {
+			message += fmt.Sprintf("[%s] %s", finding.Scanner, finding.Message)
+		}
+	}
+	return message
+}
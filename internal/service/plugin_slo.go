@@ -0,0 +1,69 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/slo"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// ListPluginSLOReports reports success rate, latency and error budget of plugins invoked recently,
+// or of the plugin if it's given
+func ListPluginSLOReports(plugin_id string) *entities.Response {
+	reports, err := slo.Reports(plugin_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(reports)
+}
+
+func ListPluginSLOs() *entities.Response {
+	slos, err := db.GetAll[models.PluginSLO]()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(slos)
+}
+
+func UpdatePluginSLO(
+	plugin_id string,
+	success_rate float64,
+	latency_p99 int64,
+	window int,
+) *entities.Response {
+	pluginSLO, err := db.GetOne[models.PluginSLO](
+		db.Equal("plugin_id", plugin_id),
+	)
+	if err != nil && err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	pluginSLO.PluginID = plugin_id
+	pluginSLO.SuccessRate = success_rate
+	pluginSLO.LatencyP99 = latency_p99
+	pluginSLO.Window = window
+
+	if err == db.ErrDatabaseNotFound {
+		err = db.Create(&pluginSLO)
+	} else {
+		err = db.Update(&pluginSLO)
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(pluginSLO)
+}
+
+func DeletePluginSLO(plugin_id string) *entities.Response {
+	if err := db.DeleteByCondition(models.PluginSLO{
+		PluginID: plugin_id,
+	}); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+const (
+	defaultPluginUsageRange = 30 * 24 * time.Hour
+)
+
+// ListPluginDailyUsage returns daily usage of plugins by the tenant within the range in unix seconds,
+// the last 30 days are used by default, usage of all plugins is returned if plugin_id is empty
+func ListPluginDailyUsage(
+	config *app.Config,
+	tenant_id string,
+	plugin_id string,
+	start int64,
+	end int64,
+) *entities.Response {
+	if !config.UsageAnalyticsEnabled {
+		return exception.BadRequestError(errors.New("usage analytics is disabled")).ToResponse()
+	}
+
+	endAt := time.Now()
+	if end > 0 {
+		endAt = time.Unix(end, 0)
+	}
+	startAt := endAt.Add(-defaultPluginUsageRange)
+	if start > 0 {
+		startAt = time.Unix(start, 0)
+	}
+	if !startAt.Before(endAt) {
+		return exception.BadRequestError(errors.New("start must be before end")).ToResponse()
+	}
+
+	query := []db.GenericQuery{
+		db.Equal("tenant_id", tenant_id),
+		// a day is included if the range covers any part of it
+		db.WhereSQL("day >= ? AND day < ?", startAt.UTC().Truncate(24*time.Hour), endAt.UTC()),
+		db.OrderBy("day", false),
+	}
+	if plugin_id != "" {
+		query = append(query, db.Equal("plugin_id", plugin_id))
+	}
+
+	usage, err := db.GetAll[models.PluginUsageDaily](query...)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(usage)
+}
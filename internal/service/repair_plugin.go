@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// RepairPlugin re-verifies an installation against its records and restores what's broken,
+// it's useful after disk issues or interrupted installations
+func RepairPlugin(
+	tenant_id string,
+	plugin_installation_id string,
+) *entities.Response {
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("id", plugin_installation_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.ErrPluginNotFound().ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if installation.RuntimeType != string(plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL) {
+		return exception.BadRequestError(errors.New("only local plugins could be repaired")).ToResponse()
+	}
+
+	pluginUniqueIdentifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+	if err != nil {
+		return exception.UniqueIdentifierError(err).ToResponse()
+	}
+
+	manager := plugin_manager.Manager()
+	report, err := manager.RepairLocalPlugin(pluginUniqueIdentifier)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if report.Failed() {
+		return entities.NewSuccessResponse(report)
+	}
+
+	// the declaration record is required to serve the plugin, restore it from the package if it's missing
+	_, err = helper.CombinedGetPluginDeclaration(pluginUniqueIdentifier, plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)
+	if err == helper.ErrPluginNotFound {
+		pkg, err := manager.GetPackage(pluginUniqueIdentifier)
+		if err == nil {
+			_, err = manager.SavePackage(pluginUniqueIdentifier, pkg)
+		}
+		if err != nil {
+			report.Checks = append(report.Checks, plugin_manager.PluginRepairCheck{
+				Name:    "declaration",
+				Status:  plugin_manager.PluginRepairStatusFailed,
+				Message: "declaration record is missing, failed to restore: " + err.Error(),
+			})
+		} else {
+			report.Checks = append(report.Checks, plugin_manager.PluginRepairCheck{
+				Name:    "declaration",
+				Status:  plugin_manager.PluginRepairStatusFixed,
+				Message: "declaration record is missing, restored from the uploaded package",
+			})
+		}
+	} else if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	} else {
+		report.Checks = append(report.Checks, plugin_manager.PluginRepairCheck{
+			Name:    "declaration",
+			Status:  plugin_manager.PluginRepairStatusOK,
+			Message: "declaration record exists",
+		})
+	}
+
+	return entities.NewSuccessResponse(report)
+}
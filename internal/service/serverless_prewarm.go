@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// GetServerlessPrewarmStats returns warm and cold hit ratios of serverless functions invoked by this node
+func GetServerlessPrewarmStats(config *app.Config) *entities.Response {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return exception.BadRequestError(errors.New("pre-warming is only available on serverless platform")).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(serverless_runtime.GetPrewarmStats())
+}
+
+// GetServerlessTransportStats returns connection reuse and retries of invocations sent by this node
+func GetServerlessTransportStats(config *app.Config) *entities.Response {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return exception.BadRequestError(errors.New("transport stats are only available on serverless platform")).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(serverless_runtime.GetTransportStats())
+}
+
+// GetServerlessFailoverStatus returns serverless plugins failing or failed over to the local runtime on this node
+func GetServerlessFailoverStatus(config *app.Config) *entities.Response {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return exception.BadRequestError(errors.New("failover is only available on serverless platform")).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(plugin_manager.Manager().ServerlessFailoverStatus())
+}
+
+// GetServerlessRegionStatus returns health of the regions serverless invocations of this node are routed to
+func GetServerlessRegionStatus(config *app.Config) *entities.Response {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return exception.BadRequestError(errors.New("regional routing is only available on serverless platform")).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(plugin_manager.Manager().ServerlessRegionStatus())
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// GetServerlessResources returns the override of the plugin along with the limits of the operator
+func GetServerlessResources(config *app.Config, plugin_id string) *entities.Response {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return exception.BadRequestError(errors.New("serverless resources are only available on serverless platform")).ToResponse()
+	}
+
+	var override *models.ServerlessResourceOverride
+	record, err := db.GetOne[models.ServerlessResourceOverride](
+		db.Equal("plugin_id", plugin_id),
+	)
+	if err == nil {
+		override = &record
+	} else if err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"plugin_id": plugin_id,
+		"override":  override,
+		"limits":    serverless.GetResourceLimits(),
+	})
+}
+
+// UpdateServerlessResources overrides resources of the plugin, installed versions are redeployed with them
+func UpdateServerlessResources(
+	config *app.Config,
+	plugin_id string,
+	resources serverless.FunctionResources,
+) *entities.Response {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return exception.BadRequestError(errors.New("serverless resources are only available on serverless platform")).ToResponse()
+	}
+
+	if resources.MemoryMB < 0 || resources.TimeoutSeconds < 0 || resources.EphemeralStorageMB < 0 || resources.Concurrency < 0 {
+		return exception.BadRequestError(errors.New("resources must not be negative")).ToResponse()
+	}
+
+	if err := resources.Validate(serverless.GetResourceLimits().Max); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	record, err := db.GetOne[models.ServerlessResourceOverride](
+		db.Equal("plugin_id", plugin_id),
+	)
+	if err != nil && err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	record.PluginID = plugin_id
+	record.MemoryMB = resources.MemoryMB
+	record.TimeoutSeconds = resources.TimeoutSeconds
+	record.EphemeralStorageMB = resources.EphemeralStorageMB
+	record.Concurrency = resources.Concurrency
+
+	if err == db.ErrDatabaseNotFound {
+		err = db.Create(&record)
+	} else {
+		err = db.Update(&record)
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if err := plugin_manager.Manager().RedeployServerlessPlugin(plugin_id); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(record)
+}
+
+// DeleteServerlessResources restores resources of the plugin to the ones required by its manifest, installed
+// versions are redeployed with them
+func DeleteServerlessResources(config *app.Config, plugin_id string) *entities.Response {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return exception.BadRequestError(errors.New("serverless resources are only available on serverless platform")).ToResponse()
+	}
+
+	if err := db.DeleteByCondition(models.ServerlessResourceOverride{PluginID: plugin_id}); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if err := plugin_manager.Manager().RedeployServerlessPlugin(plugin_id); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+const (
+	defaultServerlessTelemetryRange = 7 * 24 * time.Hour
+)
+
+type ServerlessPluginCost struct {
+	PluginID      string  `json:"plugin_id"`
+	Invocations   int64   `json:"invocations"`
+	Errors        int64   `json:"errors"`
+	DurationMs    int64   `json:"duration_ms"`
+	GBSeconds     float64 `json:"gb_seconds"`
+	EstimatedCost float64 `json:"estimated_cost"`
+	// Versions are plugin unique identifiers invoked within the range
+	Versions []string `json:"versions"`
+}
+
+// serverlessTelemetryRange converts the range in unix seconds, the last 7 days are used by default
+func serverlessTelemetryRange(start int64, end int64) (time.Time, time.Time, error) {
+	endAt := time.Now()
+	if end > 0 {
+		endAt = time.Unix(end, 0)
+	}
+	startAt := endAt.Add(-defaultServerlessTelemetryRange)
+	if start > 0 {
+		startAt = time.Unix(start, 0)
+	}
+	if !startAt.Before(endAt) {
+		return time.Time{}, time.Time{}, errors.New("start must be before end")
+	}
+	return startAt.UTC(), endAt.UTC(), nil
+}
+
+func listServerlessInvocationStats(
+	config *app.Config,
+	plugin_id string,
+	start int64,
+	end int64,
+) ([]models.ServerlessInvocationStat, exception.PluginDaemonError) {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return nil, exception.BadRequestError(errors.New("telemetry is only available on serverless platform"))
+	}
+
+	startAt, endAt, err := serverlessTelemetryRange(start, end)
+	if err != nil {
+		return nil, exception.BadRequestError(err)
+	}
+
+	query := []db.GenericQuery{
+		db.WhereSQL("hour >= ? AND hour < ?", startAt, endAt),
+		db.OrderBy("hour", false),
+	}
+	if plugin_id != "" {
+		query = append(query, db.Equal("plugin_id", plugin_id))
+	}
+
+	stats, err := db.GetAll[models.ServerlessInvocationStat](query...)
+	if err != nil {
+		return nil, exception.InternalServerError(err)
+	}
+	return stats, nil
+}
+
+// ListServerlessPluginCosts sums up invocations by plugin, the most expensive plugin comes first
+func ListServerlessPluginCosts(config *app.Config, start int64, end int64) *entities.Response {
+	stats, err := listServerlessInvocationStats(config, "", start, end)
+	if err != nil {
+		return err.ToResponse()
+	}
+
+	costs := map[string]*ServerlessPluginCost{}
+	versions := map[string]map[string]bool{}
+	for _, stat := range stats {
+		cost, ok := costs[stat.PluginID]
+		if !ok {
+			cost = &ServerlessPluginCost{PluginID: stat.PluginID, Versions: []string{}}
+			costs[stat.PluginID] = cost
+			versions[stat.PluginID] = map[string]bool{}
+		}
+
+		cost.Invocations += stat.Invocations
+		cost.Errors += stat.Errors
+		cost.DurationMs += stat.DurationMs
+		cost.GBSeconds += stat.GBSeconds
+		cost.EstimatedCost += stat.EstimatedCost
+		if !versions[stat.PluginID][stat.PluginUniqueIdentifier] {
+			versions[stat.PluginID][stat.PluginUniqueIdentifier] = true
+			cost.Versions = append(cost.Versions, stat.PluginUniqueIdentifier)
+		}
+	}
+
+	result := make([]ServerlessPluginCost, 0, len(costs))
+	for _, cost := range costs {
+		result = append(result, *cost)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].EstimatedCost > result[j].EstimatedCost
+	})
+
+	return entities.NewSuccessResponse(result)
+}
+
+// ListServerlessInvocationStats returns hourly invocations of the plugin
+func ListServerlessInvocationStats(config *app.Config, plugin_id string, start int64, end int64) *entities.Response {
+	stats, err := listServerlessInvocationStats(config, plugin_id, start, end)
+	if err != nil {
+		return err.ToResponse()
+	}
+
+	return entities.NewSuccessResponse(stats)
+}
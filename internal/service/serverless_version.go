@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func checkServerlessPlatform(config *app.Config) exception.PluginDaemonError {
+	if config.Platform != app.PLATFORM_SERVERLESS {
+		return exception.BadRequestError(errors.New("serverless versions are only available on serverless platform"))
+	}
+
+	if plugin_manager.Manager() == nil {
+		return exception.InternalServerError(errors.New("plugin manager is not initialized"))
+	}
+
+	return nil
+}
+
+func serverlessVersionError(err error) *entities.Response {
+	if errors.Is(err, plugin_manager.ErrServerlessVersionNotFound) ||
+		errors.Is(err, plugin_manager.ErrNoPreviousServerlessVersion) {
+		return exception.NotFoundError(err).ToResponse()
+	}
+	return exception.InternalServerError(err).ToResponse()
+}
+
+// ListServerlessVersions returns deployed functions of all versions of the plugin
+func ListServerlessVersions(config *app.Config, plugin_id string) *entities.Response {
+	if err := checkServerlessPlatform(config); err != nil {
+		return err.ToResponse()
+	}
+
+	versions, err := plugin_manager.Manager().ListServerlessVersions(plugin_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(versions)
+}
+
+// RollbackServerlessVersion switches invocations of the plugin to the function of target,
+// the previous deployed version is used if target is empty
+func RollbackServerlessVersion(
+	config *app.Config,
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	target plugin_entities.PluginUniqueIdentifier,
+) *entities.Response {
+	if err := checkServerlessPlatform(config); err != nil {
+		return err.ToResponse()
+	}
+
+	manager := plugin_manager.Manager()
+	if target == "" {
+		var err error
+		target, err = manager.RollbackServerlessVersion(plugin_unique_identifier)
+		if err != nil {
+			return serverlessVersionError(err)
+		}
+	} else if err := manager.PinServerlessVersion(plugin_unique_identifier, target); err != nil {
+		return serverlessVersionError(err)
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"plugin_unique_identifier":        plugin_unique_identifier,
+		"target_plugin_unique_identifier": target,
+	})
+}
+
+// CancelServerlessRollback switches invocations of the plugin back to its own function
+func CancelServerlessRollback(
+	config *app.Config,
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+) *entities.Response {
+	if err := checkServerlessPlatform(config); err != nil {
+		return err.ToResponse()
+	}
+
+	if err := plugin_manager.Manager().UnpinServerlessVersion(plugin_unique_identifier); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
@@ -3,9 +3,12 @@ package service
 import (
 	"errors"
 
+	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -13,7 +16,7 @@ func createSession[T any](
 	r *plugin_entities.InvokePluginRequest[T],
 	access_type access_types.PluginAccessType,
 	access_action access_types.PluginAccessAction,
-	cluster_id string,
+	ctx *gin.Context,
 ) (*session_manager.Session, error) {
 	manager := plugin_manager.Manager()
 	if manager == nil {
@@ -32,7 +35,7 @@ func createSession[T any](
 			TenantID:               r.TenantId,
 			UserID:                 r.UserId,
 			PluginUniqueIdentifier: r.UniqueIdentifier,
-			ClusterID:              cluster_id,
+			ClusterID:              ctx.GetString("cluster_id"),
 			InvokeFrom:             access_type,
 			Action:                 access_action,
 			Declaration:            runtime.Configuration(),
@@ -42,9 +45,23 @@ func createSession[T any](
 			MessageID:              r.MessageID,
 			AppID:                  r.AppID,
 			EndpointID:             r.EndpointID,
+			Context:                ctx.Request.Context(),
 		},
 	)
 
 	session.BindRuntime(runtime)
 	return session, nil
 }
+
+// ListSessions lists the sessions being served by this node
+func ListSessions() *entities.Response {
+	return entities.NewSuccessResponse(session_manager.List())
+}
+
+// KillSession stops the invocation served by a session of this node
+func KillSession(session_id string) *entities.Response {
+	if !session_manager.Kill(session_id) {
+		return exception.NotFoundError(errors.New("session is not served by this node")).ToResponse()
+	}
+	return entities.NewSuccessResponse(true)
+}
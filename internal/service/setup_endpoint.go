@@ -1,11 +1,14 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/credential_audit"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/webhook"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
@@ -23,13 +26,28 @@ func SetupEndpoint(
 	name string,
 	settings map[string]any,
 ) *entities.Response {
+	if _, err := setupEndpoint(tenant_id, user_id, pluginUniqueIdentifier, name, settings); err != nil {
+		return err.ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+// setupEndpoint creates the endpoint with settings encrypted, the endpoint is returned
+func setupEndpoint(
+	tenant_id string,
+	user_id string,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	name string,
+	settings map[string]any,
+) (*models.Endpoint, exception.PluginDaemonError) {
 	// try find plugin installation
 	installation, err := db.GetOne[models.PluginInstallation](
 		db.Equal("tenant_id", tenant_id),
 		db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
 	)
 	if err != nil {
-		return exception.ErrPluginNotFound().ToResponse()
+		return nil, exception.ErrPluginNotFound()
 	}
 
 	// try get plugin
@@ -39,20 +57,20 @@ func SetupEndpoint(
 	)
 
 	if err != nil {
-		return exception.ErrPluginNotFound().ToResponse()
+		return nil, exception.ErrPluginNotFound()
 	}
 
 	if !pluginDeclaration.Resource.Permission.AllowRegisterEndpoint() {
-		return exception.PermissionDeniedError("permission denied, you need to enable endpoint access in plugin manifest").ToResponse()
+		return nil, exception.PermissionDeniedError("permission denied, you need to enable endpoint access in plugin manifest")
 	}
 
 	if pluginDeclaration.Endpoint == nil {
-		return exception.BadRequestError(errors.New("plugin does not have an endpoint")).ToResponse()
+		return nil, exception.BadRequestError(errors.New("plugin does not have an endpoint"))
 	}
 
 	// check settings
 	if err := plugin_entities.ValidateProviderConfigs(settings, pluginDeclaration.Endpoint.Settings); err != nil {
-		return exception.BadRequestError(fmt.Errorf("failed to validate settings: %v", err)).ToResponse()
+		return nil, exception.BadRequestError(fmt.Errorf("failed to validate settings: %v", err))
 	}
 
 	endpoint, err := install_service.InstallEndpoint(
@@ -64,12 +82,12 @@ func SetupEndpoint(
 		map[string]any{},
 	)
 	if err != nil {
-		return exception.InternalServerError(fmt.Errorf("failed to setup endpoint: %v", err)).ToResponse()
+		return nil, exception.InternalServerError(fmt.Errorf("failed to setup endpoint: %v", err))
 	}
 
 	manager := plugin_manager.Manager()
 	if manager == nil {
-		return exception.InternalServerError(errors.New("failed to get plugin manager")).ToResponse()
+		return nil, exception.InternalServerError(errors.New("failed to get plugin manager"))
 	}
 
 	// encrypt settings
@@ -91,14 +109,22 @@ func SetupEndpoint(
 	)
 
 	if err != nil {
-		return exception.InternalServerError(fmt.Errorf("failed to encrypt settings: %v", err)).ToResponse()
+		return nil, exception.InternalServerError(fmt.Errorf("failed to encrypt settings: %v", err))
 	}
 
 	if err := install_service.UpdateEndpoint(endpoint, name, encryptedSettings); err != nil {
-		return exception.InternalServerError(fmt.Errorf("failed to update endpoint: %v", err)).ToResponse()
+		return nil, exception.InternalServerError(fmt.Errorf("failed to update endpoint: %v", err))
 	}
 
-	return entities.NewSuccessResponse(true)
+	webhook.Dispatch(tenant_id, webhook.EVENT_ENDPOINT_CREATED, map[string]any{
+		"endpoint_id":              endpoint.ID,
+		"name":                     name,
+		"plugin_id":                pluginUniqueIdentifier.PluginID(),
+		"plugin_unique_identifier": pluginUniqueIdentifier.String(),
+		"user_id":                  user_id,
+	})
+
+	return endpoint, nil
 }
 
 func RemoveEndpoint(endpoint_id string, tenant_id string) *entities.Response {
@@ -115,6 +141,11 @@ func RemoveEndpoint(endpoint_id string, tenant_id string) *entities.Response {
 		return exception.InternalServerError(fmt.Errorf("failed to remove endpoint: %v", err)).ToResponse()
 	}
 
+	// endpoints materialized from templates are detached from them
+	if err := db.DeleteByCondition(models.EndpointTemplateInstance{EndpointID: endpoint.ID}); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to remove endpoint: %v", err)).ToResponse()
+	}
+
 	manager := plugin_manager.Manager()
 	if manager == nil {
 		return exception.InternalServerError(errors.New("failed to get plugin manager")).ToResponse()
@@ -139,7 +170,9 @@ func RemoveEndpoint(endpoint_id string, tenant_id string) *entities.Response {
 	return entities.NewSuccessResponse(true)
 }
 
-func UpdateEndpoint(endpoint_id string, tenant_id string, user_id string, name string, settings map[string]any) *entities.Response {
+func UpdateEndpoint(
+	ctx context.Context, endpoint_id string, tenant_id string, user_id string, name string, settings map[string]any,
+) *entities.Response {
 	// get endpoint
 	endpoint, err := db.GetOne[models.Endpoint](
 		db.Equal("id", endpoint_id),
@@ -200,6 +233,14 @@ func UpdateEndpoint(endpoint_id string, tenant_id string, user_id string, name s
 			},
 		},
 	)
+	credential_audit.Record(ctx, credential_audit.Access{
+		TenantID:  tenant_id,
+		Namespace: string(dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT),
+		Identity:  endpoint.ID,
+		PluginID:  endpoint.PluginID,
+		UserID:    user_id,
+		Reason:    credential_audit.REASON_UPDATE_ENDPOINT,
+	}, err)
 	if err != nil {
 		return exception.InternalServerError(fmt.Errorf("failed to decrypt settings: %v", err)).ToResponse()
 	}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var (
+	ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+type TenantStorageUsage struct {
+	TenantID string `json:"tenant_id"`
+	// Quota is the max disk usage of the tenant in bytes, 0 means unlimited
+	Quota   int64                               `json:"quota"`
+	Usage   int64                               `json:"usage"`
+	Plugins []plugin_manager.PluginStorageUsage `json:"plugins"`
+}
+
+// tenantStorageQuota returns the quota of the tenant, the default one is used if it's not overridden
+func tenantStorageQuota(config *app.Config, tenant_id string) (int64, error) {
+	quota, err := db.GetOne[models.TenantStorageQuota](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return config.PluginTenantStorageQuota, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return quota.Quota, nil
+}
+
+// tenantStorageUsage sums up disk usage of plugins installed by the tenant, plugins in excludedPluginIDs are skipped,
+// shared packages are counted for every tenant using them
+func tenantStorageUsage(
+	tenant_id string,
+	excludedPluginIDs map[string]bool,
+) ([]plugin_manager.PluginStorageUsage, int64, error) {
+	installations, err := db.GetAll[models.PluginInstallation](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	manager := plugin_manager.Manager()
+	if manager == nil {
+		return nil, 0, errors.New("plugin manager is not initialized")
+	}
+
+	plugins := []plugin_manager.PluginStorageUsage{}
+	total := int64(0)
+	for _, installation := range installations {
+		if excludedPluginIDs[installation.PluginID] {
+			continue
+		}
+
+		usage := manager.PluginStorageUsage(plugin_entities.PluginUniqueIdentifier(installation.PluginUniqueIdentifier))
+		plugins = append(plugins, usage)
+		total += usage.Total()
+	}
+
+	return plugins, total, nil
+}
+
+// checkTenantStorageQuota refuses the installation if the tenant would exceed its quota,
+// installed versions of the same plugins are replaced so they're not counted
+func checkTenantStorageQuota(
+	config *app.Config,
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+) error {
+	quota, err := tenantStorageQuota(config, tenant_id)
+	if err != nil {
+		return err
+	}
+	if quota <= 0 {
+		return nil
+	}
+
+	pluginIDs := map[string]bool{}
+	for _, identifier := range plugin_unique_identifiers {
+		pluginIDs[identifier.PluginID()] = true
+	}
+
+	_, usage, err := tenantStorageUsage(tenant_id, pluginIDs)
+	if err != nil {
+		return err
+	}
+
+	manager := plugin_manager.Manager()
+	required := int64(0)
+	for _, identifier := range plugin_unique_identifiers {
+		required += manager.PluginStorageUsage(identifier).Total()
+	}
+
+	if usage+required > quota {
+		return errors.Join(ErrStorageQuotaExceeded, fmt.Errorf(
+			"installing requires %d bytes but the tenant has used %d of %d bytes", required, usage, quota,
+		))
+	}
+
+	return nil
+}
+
+func GetTenantStorageUsage(config *app.Config, tenant_id string) *entities.Response {
+	quota, err := tenantStorageQuota(config, tenant_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	plugins, usage, err := tenantStorageUsage(tenant_id, nil)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(TenantStorageUsage{
+		TenantID: tenant_id,
+		Quota:    quota,
+		Usage:    usage,
+		Plugins:  plugins,
+	})
+}
+
+// UpdateTenantStorageQuota overrides the default quota of the tenant, 0 means unlimited
+func UpdateTenantStorageQuota(tenant_id string, quota int64) *entities.Response {
+	if quota < 0 {
+		return exception.BadRequestError(errors.New("quota must not be negative")).ToResponse()
+	}
+
+	record, err := db.GetOne[models.TenantStorageQuota](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		record = models.TenantStorageQuota{TenantID: tenant_id, Quota: quota}
+		err = db.Create(&record)
+	} else if err == nil {
+		record.Quota = quota
+		err = db.Update(&record)
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(record)
+}
+
+// DeleteTenantStorageQuota restores the default quota of the tenant
+func DeleteTenantStorageQuota(tenant_id string) *entities.Response {
+	if err := db.DeleteByCondition(models.TenantStorageQuota{TenantID: tenant_id}); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
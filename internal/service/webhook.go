@@ -0,0 +1,148 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/keyring"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/webhook"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+func validateWebhook(webhook_url string, events []string) error {
+	u, err := url.Parse(webhook_url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url: %s", webhook_url)
+	}
+
+	if err := webhook.CheckURL(webhook_url); err != nil {
+		return fmt.Errorf("webhook url is not allowed: %s", err.Error())
+	}
+
+	for _, event := range events {
+		if event == "*" {
+			continue
+		}
+
+		valid := false
+		for _, eventType := range webhook.EventTypes {
+			if event == string(eventType) {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return fmt.Errorf("unknown webhook event: %s", event)
+		}
+	}
+
+	return nil
+}
+
+func ListWebhooks(tenant_id string) *entities.Response {
+	webhooks, err := db.GetAll[models.Webhook](
+		db.Equal("tenant_id", tenant_id),
+		db.OrderBy("created_at", true),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(webhooks)
+}
+
+// CreateWebhook registers a webhook, a random secret is generated if it's empty,
+// the secret is only returned here, it's used to verify the signature of deliveries
+func CreateWebhook(
+	tenant_id string,
+	webhook_url string,
+	secret string,
+	events []string,
+) *entities.Response {
+	if err := validateWebhook(webhook_url, events); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return exception.InternalServerError(err).ToResponse()
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	encrypted, version, err := keyring.Encrypt(secret)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	record := models.Webhook{
+		TenantID:         tenant_id,
+		URL:              webhook_url,
+		Secret:           encrypted,
+		SecretKeyVersion: version,
+		Events:           events,
+		Enabled:          true,
+	}
+	if err := db.Create(&record); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"webhook": record,
+		"secret":  secret,
+	})
+}
+
+func UpdateWebhook(
+	tenant_id string,
+	webhook_id string,
+	webhook_url string,
+	events []string,
+	enabled bool,
+) *entities.Response {
+	if err := validateWebhook(webhook_url, events); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	record, err := db.GetOne[models.Webhook](
+		db.Equal("id", webhook_id),
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.NotFoundError(errors.New("webhook not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	record.URL = webhook_url
+	record.Events = events
+	record.Enabled = enabled
+
+	if err := db.Update(&record); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(record)
+}
+
+func DeleteWebhook(tenant_id string, webhook_id string) *entities.Response {
+	if err := db.DeleteByCondition(models.Webhook{
+		Model: models.Model{
+			ID: webhook_id,
+		},
+		TenantID: tenant_id,
+	}); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
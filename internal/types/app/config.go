@@ -11,6 +11,41 @@ type Config struct {
 	ServerPort uint16 `envconfig:"SERVER_PORT" validate:"required"`
 	ServerKey  string `envconfig:"SERVER_KEY" validate:"required"`
 
+	// requests to routes of tenants carry a token signed by the Dify API in X-Tenant-Token once the secret is set,
+	// the token pins the tenant, the user and the scopes of the request, it's required if TENANT_TOKEN_REQUIRED is set
+	TenantTokenSecret   string `envconfig:"TENANT_TOKEN_SECRET"`
+	TenantTokenRequired bool   `envconfig:"TENANT_TOKEN_REQUIRED"`
+	TenantTokenMaxTTL   int    `envconfig:"TENANT_TOKEN_MAX_TTL" validate:"omitempty,min=1"` // in seconds
+
+	// tls of the server port, clients must present a certificate signed by SERVER_TLS_CLIENT_CA_FILE if it's set,
+	// nodes of the cluster talk to each other with their own certificates, files are reloaded on SIGHUP or changes
+	ServerTLSEnabled        bool   `envconfig:"SERVER_TLS_ENABLED"`
+	ServerTLSCertFile       string `envconfig:"SERVER_TLS_CERT_FILE"`
+	ServerTLSKeyFile        string `envconfig:"SERVER_TLS_KEY_FILE"`
+	ServerTLSClientCAFile   string `envconfig:"SERVER_TLS_CLIENT_CA_FILE"`
+	ServerTLSMinVersion     string `envconfig:"SERVER_TLS_MIN_VERSION" validate:"omitempty,oneof=1.2 1.3"`
+	ServerTLSReloadInterval int    `envconfig:"SERVER_TLS_RELOAD_INTERVAL" validate:"omitempty,min=1"` // in seconds
+
+	// client ips of requests are taken from the headers only if they're sent by the trusted proxies,
+	// which are ips or cidrs of load balancers, the address of the connection is the client ip otherwise
+	ServerTrustedProxies  string `envconfig:"SERVER_TRUSTED_PROXIES"`
+	ServerClientIPHeaders string `envconfig:"SERVER_CLIENT_IP_HEADERS"`
+
+	// routes are served under /v1 and /v2 as well, unversioned ones are v1, responses of deprecated versions carry
+	// Deprecation and Sunset headers, e.g. `v1=2027-06-30` deprecates v1 and sunsets it on the date
+	APIDeprecatedVersions string `envconfig:"API_DEPRECATED_VERSIONS"`
+
+	// http/2 is served over tls, and in cleartext (h2c) to clients speaking it with prior knowledge or upgrades
+	ServerHTTP2Enabled *bool `envconfig:"SERVER_HTTP2_ENABLED"`
+	// responses larger than the min size are compressed by zstd or gzip as accepted by clients
+	ServerCompressionEnabled *bool `envconfig:"SERVER_COMPRESSION_ENABLED"`
+	ServerCompressionMinSize int   `envconfig:"SERVER_COMPRESSION_MIN_SIZE" validate:"omitempty,min=1"` // in bytes
+
+	// grpc server exposing the management apis and tool invocations, messages are encoded in json,
+	// it shares the certificate and the keys of the server port
+	GRPCServerEnabled bool   `envconfig:"GRPC_SERVER_ENABLED"`
+	GRPCServerPort    uint16 `envconfig:"GRPC_SERVER_PORT"`
+
 	// dify inner api
 	DifyInnerApiURL string `envconfig:"DIFY_INNER_API_URL" validate:"required"`
 	DifyInnerApiKey string `envconfig:"DIFY_INNER_API_KEY" validate:"required"`
@@ -41,15 +76,56 @@ type Config struct {
 	// plugin endpoint
 	PluginEndpointEnabled *bool `envconfig:"PLUGIN_ENDPOINT_ENABLED"`
 
+	// tools of installed plugins are invoked directly on /tools/{tenant_id}/invoke by api tokens granted tools:invoke,
+	// apart from Dify apps, tokens bound to a tenant only invoke tools of the tenant
+	ToolInvocationAPIEnabled bool `envconfig:"TOOL_INVOCATION_API_ENABLED"`
+
+	// tools of installed plugins are served to mcp clients over server sent events on /mcp/{tenant_id}/sse,
+	// calls are invoked as /plugin/{tenant_id}/dispatch/tool/invoke, so callers need the key or plugins:invoke and plugins:read
+	MCPServerEnabled bool `envconfig:"MCP_SERVER_ENABLED"`
+
+	// tool calls of OpenAI chat completions are run by tools of installed plugins on /openai/{tenant_id}/tool_calls,
+	// tools are named as for mcp clients and listed as functions on /openai/{tenant_id}/tools
+	OpenAIToolCallsEnabled bool `envconfig:"OPENAI_TOOL_CALLS_ENABLED"`
+
+	// install, uninstall and endpoint setup/update requests carrying an Idempotency-Key are replied by the
+	// cached result when they're retried with the key within the window
+	IdempotencyKeyWindow int `envconfig:"IDEMPOTENCY_KEY_WINDOW" validate:"omitempty,min=1"` // in seconds
+
+	// invocations of llms with a temperature of 0 are replied from responses cached for each tenant when they're
+	// repeated with the same plugin, model, credentials, prompts and parameters, callers skip the cache by
+	// the X-Model-Cache: bypass header
+	ModelResponseCacheEnabled  bool `envconfig:"MODEL_RESPONSE_CACHE_ENABLED"`
+	ModelResponseCacheTTL      int  `envconfig:"MODEL_RESPONSE_CACHE_TTL" validate:"omitempty,min=1"` // in seconds
+	ModelResponseCacheMaxBytes int  `envconfig:"MODEL_RESPONSE_CACHE_MAX_BYTES" validate:"omitempty,min=1"`
+
+	// guardrails of tenants enabling moderation post texts of tool inputs and outputs to GUARDRAIL_MODERATION_URL,
+	// which replies `{"flagged": bool, "categories": [...]}`, tenants can't set the url themselves
+	GuardrailModerationURL     string `envconfig:"GUARDRAIL_MODERATION_URL" validate:"omitempty,url"`
+	GuardrailModerationToken   string `envconfig:"GUARDRAIL_MODERATION_TOKEN"`
+	GuardrailModerationTimeout int    `envconfig:"GUARDRAIL_MODERATION_TIMEOUT" validate:"omitempty,min=1" reload:"true"` // in seconds
+
+	// outputs of tools are validated against output schemas declared by them, violations fail the invocation
+	// if the mode is `enforce`, are only logged and counted if it's `warn`, and are not checked if it's `off`
+	ToolOutputSchemaMode string `envconfig:"TOOL_OUTPUT_SCHEMA_MODE" validate:"omitempty,oneof=enforce warn off" reload:"true"`
+
 	// storage
 	PluginWorkingPath      string `envconfig:"PLUGIN_WORKING_PATH"` // where the plugin finally running
 	PluginMediaCacheSize   uint16 `envconfig:"PLUGIN_MEDIA_CACHE_SIZE"`
 	PluginMediaCachePath   string `envconfig:"PLUGIN_MEDIA_CACHE_PATH"`
 	PluginInstalledPath    string `envconfig:"PLUGIN_INSTALLED_PATH" validate:"required"` // where the plugin finally installed
 	PluginPackageCachePath string `envconfig:"PLUGIN_PACKAGE_CACHE_PATH"`                 // where plugin packages stored
+	// assets of plugins like icons larger than it are rejected on installing
+	PluginAssetMaxSize int64 `envconfig:"PLUGIN_ASSET_MAX_SIZE" validate:"omitempty,min=1"` // in bytes
+	// urls of assets and packages signed by the secret are served on /assets without the server key until they
+	// expire, so browsers and cdns fetch icons directly, urls are built on the base url like the cdn in front of
+	// the daemon, they're relative paths if it's empty
+	AssetSigningSecret string `envconfig:"ASSET_SIGNING_SECRET"`
+	AssetSignedURLTTL  int    `envconfig:"ASSET_SIGNED_URL_TTL" validate:"omitempty,min=60"` // in seconds
+	AssetBaseURL       string `envconfig:"ASSET_BASE_URL" validate:"omitempty,url"`
 
 	// request timeout
-	PluginMaxExecutionTimeout int `envconfig:"PLUGIN_MAX_EXECUTION_TIMEOUT" validate:"required"`
+	PluginMaxExecutionTimeout int `envconfig:"PLUGIN_MAX_EXECUTION_TIMEOUT" validate:"required" reload:"true"`
 
 	// local launching max concurrent
 	PluginLocalLaunchingConcurrent int `envconfig:"PLUGIN_LOCAL_LAUNCHING_CONCURRENT" validate:"required"`
@@ -57,8 +133,14 @@ type Config struct {
 	// platform like local or aws lambda
 	Platform PlatformType `envconfig:"PLATFORM" validate:"required"`
 
+	// role of this node in the cluster, gateways forward plugin invocations to runners and run no plugin
+	NodeRole NodeRole `envconfig:"NODE_ROLE"`
+
 	// routine pool
 	RoutinePoolSize int `envconfig:"ROUTINE_POOL_SIZE" validate:"required"`
+	// pools bounding tasks of subsystems like `install_task=16:1024:abort`, workers:queue:policy of each, pools are
+	// endpoint_stream, stdio_reader and install_task, policies are abort, caller_runs and block
+	RoutinePools string `envconfig:"ROUTINE_POOLS"`
 
 	// redis
 	RedisHost   string `envconfig:"REDIS_HOST" validate:"required"`
@@ -75,14 +157,64 @@ type Config struct {
 	DBDatabase        string `envconfig:"DB_DATABASE" validate:"required"`
 	DBDefaultDatabase string `envconfig:"DB_DEFAULT_DATABASE" validate:"required"`
 	DBSslMode         string `envconfig:"DB_SSL_MODE" validate:"required,oneof=disable require"`
+	// DBMaxOpenConns limits connections to the database, 0 means unlimited
+	DBMaxOpenConns int `envconfig:"DB_MAX_OPEN_CONNS" validate:"omitempty,min=0"`
 
 	// persistence storage
 	PersistenceStoragePath    string `envconfig:"PERSISTENCE_STORAGE_PATH"`
 	PersistenceStorageMaxSize int64  `envconfig:"PERSISTENCE_STORAGE_MAX_SIZE"`
+	// PersistenceStorageType is where values are kept, `plugin_storage` shares the storage of plugin packages,
+	// `database` keeps them in the database, `local` and `aws_s3` keep them in a storage of their own
+	PersistenceStorageType      string `envconfig:"PERSISTENCE_STORAGE_TYPE" validate:"omitempty,oneof=plugin_storage database local aws_s3"`
+	PersistenceStorageOSSBucket string `envconfig:"PERSISTENCE_STORAGE_OSS_BUCKET"`
+	PersistenceStorageLocalRoot string `envconfig:"PERSISTENCE_STORAGE_LOCAL_ROOT"`
+	// values larger than the max cache size are loaded from the storage without being cached in redis
+	PersistenceCacheMaxSize int64 `envconfig:"PERSISTENCE_CACHE_MAX_SIZE" validate:"omitempty,min=0"`
 
 	// force verifying signature for all plugins, not allowing install plugin not signed
 	ForceVerifyingSignature *bool `envconfig:"FORCE_VERIFYING_SIGNATURE"`
 
+	// plugin package scanning, run on every uploaded package
+	PluginPackageScanEnabled         *bool    `envconfig:"PLUGIN_PACKAGE_SCAN_ENABLED"`
+	PluginPackageScanPolicy          string   `envconfig:"PLUGIN_PACKAGE_SCAN_POLICY" validate:"omitempty,oneof=block warn"`
+	PluginPackageScanMaxFiles        int      `envconfig:"PLUGIN_PACKAGE_SCAN_MAX_FILES"`
+	PluginPackageScanMaxFileSize     int64    `envconfig:"PLUGIN_PACKAGE_SCAN_MAX_FILE_SIZE"`
+	PluginPackageScanBlockedPackages []string `envconfig:"PLUGIN_PACKAGE_SCAN_BLOCKED_PACKAGES"`
+
+	// max number of plugins handled at the same time by batch install and uninstall
+	PluginBatchOperationConcurrency int `envconfig:"PLUGIN_BATCH_OPERATION_CONCURRENCY"`
+
+	// packing plugins from source, git sources are disabled by default since they make the daemon reach arbitrary hosts
+	PluginPackGitEnabled *bool `envconfig:"PLUGIN_PACK_GIT_ENABLED"`
+	PluginPackGitTimeout int   `envconfig:"PLUGIN_PACK_GIT_TIMEOUT"` // in seconds
+
+	// dev mode runs plugins from local source directories and restarts them on changes, only for local platform
+	PluginDevModeEnabled   *bool `envconfig:"PLUGIN_DEV_MODE_ENABLED"`
+	PluginDevWatchInterval int   `envconfig:"PLUGIN_DEV_WATCH_INTERVAL"` // in seconds
+
+	// default quota of disk usage of installed plugins per tenant in bytes, 0 means unlimited
+	PluginTenantStorageQuota int64 `envconfig:"PLUGIN_TENANT_STORAGE_QUOTA"`
+
+	// garbage collection of packages, working directories and media files no longer referenced by any plugin
+	PluginGCEnabled     *bool `envconfig:"PLUGIN_GC_ENABLED"`
+	PluginGCInterval    int   `envconfig:"PLUGIN_GC_INTERVAL"`     // in seconds
+	PluginGCGracePeriod int   `envconfig:"PLUGIN_GC_GRACE_PERIOD"` // in seconds, files modified recently are kept
+
+	// outbound webhooks of plugin lifecycle events
+	WebhookTimeout    int `envconfig:"WEBHOOK_TIMEOUT" reload:"true"` // in seconds
+	WebhookMaxRetries int `envconfig:"WEBHOOK_MAX_RETRIES" reload:"true"`
+
+	// events of the bus like invocations and lifecycles of plugins are exported to kafka or nats once the type is set,
+	// brokers are `host:port` of kafka or urls of nats, events are dropped once the buffer is full
+	EventExportType        string   `envconfig:"EVENT_EXPORT_TYPE" validate:"omitempty,oneof=kafka nats"`
+	EventExportBrokers     []string `envconfig:"EVENT_EXPORT_BROKERS"`
+	EventExportTopicPrefix string   `envconfig:"EVENT_EXPORT_TOPIC_PREFIX"`
+	EventExportEvents      []string `envconfig:"EVENT_EXPORT_EVENTS"` // all events are exported if it's empty
+	EventExportUsername    string   `envconfig:"EVENT_EXPORT_USERNAME"`
+	EventExportPassword    string   `envconfig:"EVENT_EXPORT_PASSWORD"`
+	EventExportTLSEnabled  bool     `envconfig:"EVENT_EXPORT_TLS_ENABLED"`
+	EventExportBufferSize  int      `envconfig:"EVENT_EXPORT_BUFFER_SIZE" validate:"omitempty,min=1"`
+
 	// lifetime state management
 	LifetimeCollectionHeartbeatInterval int `envconfig:"LIFETIME_COLLECTION_HEARTBEAT_INTERVAL"  validate:"required"`
 	LifetimeCollectionGCInterval        int `envconfig:"LIFETIME_COLLECTION_GC_INTERVAL" validate:"required"`
@@ -90,9 +222,69 @@ type Config struct {
 
 	DifyInvocationConnectionIdleTimeout int `envconfig:"DIFY_INVOCATION_CONNECTION_IDLE_TIMEOUT" validate:"required"`
 
+	// provider to deploy serverless plugins, aws deploys through the serverless connector,
+	// http deploys through a deployer of HTTP functions like Knative or Cloud Run
+	ServerlessProvider string `envconfig:"SERVERLESS_PROVIDER" validate:"omitempty,oneof=aws http"`
+
 	DifyPluginServerlessConnectorURL    *string `envconfig:"DIFY_PLUGIN_SERVERLESS_CONNECTOR_URL"`
 	DifyPluginServerlessConnectorAPIKey *string `envconfig:"DIFY_PLUGIN_SERVERLESS_CONNECTOR_API_KEY"`
 
+	ServerlessHTTPDeployerURL    string `envconfig:"SERVERLESS_HTTP_DEPLOYER_URL"`
+	ServerlessHTTPDeployerAPIKey string `envconfig:"SERVERLESS_HTTP_DEPLOYER_API_KEY"`
+
+	// regions functions are deployed to, the nearest one of this node comes first, invocations are
+	// routed to the first healthy region
+	ServerlessRegions                 []string `envconfig:"SERVERLESS_REGIONS"`
+	ServerlessRegionFailureThreshold  int      `envconfig:"SERVERLESS_REGION_FAILURE_THRESHOLD" validate:"omitempty,min=1"`
+	ServerlessRegionUnhealthyCooldown int      `envconfig:"SERVERLESS_REGION_UNHEALTHY_COOLDOWN"` // in seconds
+
+	// pre-warming pings serverless functions predicted to be invoked soon to avoid cold starts
+	ServerlessPrewarmEnabled    *bool `envconfig:"SERVERLESS_PREWARM_ENABLED"`
+	ServerlessPrewarmInterval   int   `envconfig:"SERVERLESS_PREWARM_INTERVAL"`    // in seconds
+	ServerlessPrewarmWarmWindow int   `envconfig:"SERVERLESS_PREWARM_WARM_WINDOW"` // in seconds, how long an instance stays warm
+
+	// connections to serverless functions are pooled per function
+	ServerlessMaxIdleConnsPerFunction int `envconfig:"SERVERLESS_MAX_IDLE_CONNS_PER_FUNCTION"`
+	ServerlessMaxConnsPerFunction     int `envconfig:"SERVERLESS_MAX_CONNS_PER_FUNCTION"` // 0 means unlimited
+	ServerlessIdleConnTimeout         int `envconfig:"SERVERLESS_IDLE_CONN_TIMEOUT"`      // in seconds
+	ServerlessKeepAlive               int `envconfig:"SERVERLESS_KEEP_ALIVE"`             // in seconds
+	ServerlessDialTimeout             int `envconfig:"SERVERLESS_DIAL_TIMEOUT"`           // in seconds
+	ServerlessResponseIdleTimeout     int `envconfig:"SERVERLESS_RESPONSE_IDLE_TIMEOUT"`  // in seconds
+	// invocations failed to connect are retried, retries are limited to a percentage of invocations,
+	// pointers tell unset ones from 0 which turns retries off
+	ServerlessRetryBudgetPercent *int `envconfig:"SERVERLESS_RETRY_BUDGET_PERCENT" validate:"omitempty,min=0,max=100"`
+	ServerlessMaxRetries         *int `envconfig:"SERVERLESS_MAX_RETRIES" validate:"omitempty,min=0"`
+
+	// authenticate the daemon to serverless functions by mTLS and/or signed requests,
+	// files are reloaded once they change so that credentials are rotated without restarting
+	ServerlessTLSClientCertFile         string `envconfig:"SERVERLESS_TLS_CLIENT_CERT_FILE"`
+	ServerlessTLSClientKeyFile          string `envconfig:"SERVERLESS_TLS_CLIENT_KEY_FILE"`
+	ServerlessTLSCAFile                 string `envconfig:"SERVERLESS_TLS_CA_FILE"`
+	ServerlessRequestSigningKeyFile     string `envconfig:"SERVERLESS_REQUEST_SIGNING_KEY_FILE"`
+	ServerlessCredentialsReloadInterval int    `envconfig:"SERVERLESS_CREDENTIALS_RELOAD_INTERVAL"` // in seconds
+
+	// resources of serverless functions, manifests and overrides beyond the max ones are refused, 0 means no limit
+	ServerlessDefaultTimeout          int   `envconfig:"SERVERLESS_DEFAULT_TIMEOUT"`           // in seconds
+	ServerlessDefaultEphemeralStorage int64 `envconfig:"SERVERLESS_DEFAULT_EPHEMERAL_STORAGE"` // in MB
+	ServerlessMaxMemory               int64 `envconfig:"SERVERLESS_MAX_MEMORY"`                // in MB
+	ServerlessMaxTimeout              int   `envconfig:"SERVERLESS_MAX_TIMEOUT"`               // in seconds
+	ServerlessMaxEphemeralStorage     int64 `envconfig:"SERVERLESS_MAX_EPHEMERAL_STORAGE"`     // in MB
+	ServerlessMaxConcurrency          int   `envconfig:"SERVERLESS_MAX_CONCURRENCY"`
+
+	// invocations of serverless plugins are reported hourly with the estimated cost
+	ServerlessTelemetryEnabled       *bool   `envconfig:"SERVERLESS_TELEMETRY_ENABLED"`
+	ServerlessTelemetryFlushInterval int     `envconfig:"SERVERLESS_TELEMETRY_FLUSH_INTERVAL"` // in seconds
+	ServerlessCostPerGBSecond        float64 `envconfig:"SERVERLESS_COST_PER_GB_SECOND"`
+	ServerlessCostPerMillionRequests float64 `envconfig:"SERVERLESS_COST_PER_MILLION_REQUESTS"`
+
+	// serverless plugins are launched locally once the provider keeps failing within the window,
+	// traffic is routed back once the provider recovers
+	ServerlessFailoverEnabled          *bool `envconfig:"SERVERLESS_FAILOVER_ENABLED"`
+	ServerlessFailoverThreshold        int   `envconfig:"SERVERLESS_FAILOVER_THRESHOLD" validate:"omitempty,min=1"`
+	ServerlessFailoverWindow           int   `envconfig:"SERVERLESS_FAILOVER_WINDOW"`            // in seconds
+	ServerlessFailoverRecoveryInterval int   `envconfig:"SERVERLESS_FAILOVER_RECOVERY_INTERVAL"` // in seconds
+	ServerlessFailoverDrainTimeout     int   `envconfig:"SERVERLESS_FAILOVER_DRAIN_TIMEOUT"`     // in seconds
+
 	MaxPluginPackageSize            int64 `envconfig:"MAX_PLUGIN_PACKAGE_SIZE" validate:"required"`
 	MaxBundlePackageSize            int64 `envconfig:"MAX_BUNDLE_PACKAGE_SIZE" validate:"required"`
 	MaxServerlessTransactionTimeout int   `envconfig:"MAX_SERVERLESS_TRANSACTION_TIMEOUT"`
@@ -107,7 +299,74 @@ type Config struct {
 
 	DisplayClusterLog bool `envconfig:"DISPLAY_CLUSTER_LOG"`
 
+	// new sessions are redirected to the least loaded node running the plugin once the current node
+	// serves more than ClusterLoadBalanceThreshold sessions beyond it
+	ClusterLoadBalancingEnabled *bool `envconfig:"CLUSTER_LOAD_BALANCING_ENABLED"`
+	ClusterLoadBalanceThreshold int   `envconfig:"CLUSTER_LOAD_BALANCE_THRESHOLD" validate:"omitempty,min=0"`
+
+	// each plugin runs on PluginPlacementReplicas runner nodes picked by consistent hashing,
+	// every runner node runs all plugins if it's 0
+	PluginPlacementReplicas int `envconfig:"PLUGIN_PLACEMENT_REPLICAS" validate:"omitempty,min=0"`
+
+	// capacity of the node, new sessions are forwarded to other nodes or rejected once the node is saturated
+	// and no more plugins are launched beyond the limits, 0 means unlimited
+	NodeMaxPlugins  int   `envconfig:"NODE_MAX_PLUGINS" validate:"omitempty,min=0"`
+	NodeMaxSessions int   `envconfig:"NODE_MAX_SESSIONS" validate:"omitempty,min=0"`
+	NodeMaxMemoryMB int64 `envconfig:"NODE_MAX_MEMORY_MB" validate:"omitempty,min=0"` // by memory declared by plugins
+
+	// local plugins of each node are reconciled with installations in the database periodically
+	PluginReconcileEnabled  *bool `envconfig:"PLUGIN_RECONCILE_ENABLED"`
+	PluginReconcileInterval int   `envconfig:"PLUGIN_RECONCILE_INTERVAL" validate:"omitempty,min=1"` // in seconds
+
+	// singleton jobs run on the node holding their lease, another node takes over once it expires
+	SingletonJobLeaseDuration int `envconfig:"SINGLETON_JOB_LEASE_DURATION" validate:"omitempty,min=3"` // in seconds
+
 	PPROFEnabled bool `envconfig:"PPROF_ENABLED"`
+	// pprof and goroutine dumps are served on the admin port apart from the public one if it's set,
+	// requests are authenticated by the admin key, which falls back to the server key
+	AdminPort uint16 `envconfig:"ADMIN_PORT"`
+	AdminKey  string `envconfig:"ADMIN_KEY"`
+	// browsers on the origins, e.g. admin consoles, are allowed to call /admin, /cluster and /slo, `*` allows all
+	AdminCORSAllowedOrigins string `envconfig:"ADMIN_CORS_ALLOWED_ORIGINS"`
+	AdminCORSAllowedHeaders string `envconfig:"ADMIN_CORS_ALLOWED_HEADERS"`
+	AdminCORSMaxAge         int    `envconfig:"ADMIN_CORS_MAX_AGE" validate:"omitempty,min=1"` // in seconds
+	// operators of /admin, /cluster, /slo and pprof sign in by OIDC, sending id tokens issued for the client as
+	// bearer tokens, or by LDAP, sending their users by basic auth. Roles are mapped from their groups by
+	// ADMIN_AUTH_ROLE_MAPPING like `dify-admins=admin,sre=operator,*=viewer`, the highest matching role wins
+	AdminAuthOIDCIssuer         string   `envconfig:"ADMIN_AUTH_OIDC_ISSUER"`
+	AdminAuthOIDCClientID       string   `envconfig:"ADMIN_AUTH_OIDC_CLIENT_ID"`
+	AdminAuthOIDCGroupsClaim    string   `envconfig:"ADMIN_AUTH_OIDC_GROUPS_CLAIM"`
+	AdminAuthLDAPURL            string   `envconfig:"ADMIN_AUTH_LDAP_URL"`
+	AdminAuthLDAPBindDN         string   `envconfig:"ADMIN_AUTH_LDAP_BIND_DN"`
+	AdminAuthLDAPBindPassword   string   `envconfig:"ADMIN_AUTH_LDAP_BIND_PASSWORD"`
+	AdminAuthLDAPBaseDN         string   `envconfig:"ADMIN_AUTH_LDAP_BASE_DN"`
+	AdminAuthLDAPUserFilter     string   `envconfig:"ADMIN_AUTH_LDAP_USER_FILTER"` // %s is replaced by the username
+	AdminAuthLDAPGroupAttribute string   `envconfig:"ADMIN_AUTH_LDAP_GROUP_ATTRIBUTE"`
+	AdminAuthRoleMapping        []string `envconfig:"ADMIN_AUTH_ROLE_MAPPING"`
+	// metrics of all subsystems are exposed on /metrics in the prometheus format
+	MetricsEnabled bool `envconfig:"METRICS_ENABLED"`
+	// the openapi document of all routes is served on /openapi.json
+	OpenAPIEnabled *bool `envconfig:"OPENAPI_ENABLED"`
+	// traces are exported by OTLP over http, the exporter is configured by the standard OTEL_EXPORTER_OTLP_* envs,
+	// the pointer tells an unset rate from 0 which traces only requests sampled by callers
+	TracingEnabled    bool     `envconfig:"TRACING_ENABLED"`
+	TracingSampleRate *float64 `envconfig:"TRACING_SAMPLE_RATE" validate:"omitempty,min=0,max=1"`
+	// invocations taking longer than the threshold are logged and kept in a ring buffer served on the admin port,
+	// payloads are attached to the sampled ones with credentials redacted
+	SlowLogEnabled           bool    `envconfig:"SLOW_LOG_ENABLED"`
+	SlowLogThreshold         int     `envconfig:"SLOW_LOG_THRESHOLD" validate:"omitempty,min=1" reload:"true"` // in milliseconds
+	SlowLogCapacity          int     `envconfig:"SLOW_LOG_CAPACITY" validate:"omitempty,min=1"`
+	SlowLogPayloadSampleRate float64 `envconfig:"SLOW_LOG_PAYLOAD_SAMPLE_RATE" validate:"omitempty,min=0,max=1" reload:"true"`
+	// success rate and latency of each plugin are tracked over a sliding window, plugins without their own
+	// objectives are checked against the default ones, the latency objective is disabled if it's 0
+	SLOEnabled     bool    `envconfig:"SLO_ENABLED"`
+	SLOWindow      int     `envconfig:"SLO_WINDOW" validate:"omitempty,min=1,max=1440"` // in minutes
+	SLOSuccessRate float64 `envconfig:"SLO_SUCCESS_RATE" validate:"omitempty,min=0,max=1"`
+	SLOLatencyP99  int64   `envconfig:"SLO_LATENCY_P99" validate:"omitempty,min=0"` // in milliseconds
+
+	// invocations are rolled up into daily usage of each tenant and plugin, hourly rows are kept for the retention
+	UsageAnalyticsEnabled         bool `envconfig:"USAGE_ANALYTICS_ENABLED"`
+	UsageAnalyticsHourlyRetention int  `envconfig:"USAGE_ANALYTICS_HOURLY_RETENTION" validate:"omitempty,min=2"` // in days
 
 	SentryEnabled          bool    `envconfig:"SENTRY_ENABLED"`
 	SentryDSN              string  `envconfig:"SENTRY_DSN"`
@@ -115,13 +374,111 @@ type Config struct {
 	SentryTracingEnabled   bool    `envconfig:"SENTRY_TRACING_ENABLED"`
 	SentryTracesSampleRate float64 `envconfig:"SENTRY_TRACES_SAMPLE_RATE"`
 	SentrySampleRate       float64 `envconfig:"SENTRY_SAMPLE_RATE"`
+	// plugins crashing SENTRY_CRASH_LOOP_THRESHOLD times within SENTRY_CRASH_LOOP_WINDOW seconds are reported
+	// with their stderr, failed invocations are reported with the identity of the plugin
+	SentryCrashLoopThreshold      int   `envconfig:"SENTRY_CRASH_LOOP_THRESHOLD" validate:"omitempty,min=1"`
+	SentryCrashLoopWindow         int   `envconfig:"SENTRY_CRASH_LOOP_WINDOW" validate:"omitempty,min=1"`
+	SentryInvocationErrorsEnabled *bool `envconfig:"SENTRY_INVOCATION_ERRORS_ENABLED"`
 
 	// proxy settings
 	HttpProxy  string `envconfig:"HTTP_PROXY"`
 	HttpsProxy string `envconfig:"HTTPS_PROXY"`
 
+	// marketplace is checked by the readiness probe if it's set
+	MarketplaceURL string `envconfig:"MARKETPLACE_URL" validate:"omitempty,url"`
+
+	// subsystems stuck for WATCHDOG_FAILURE_THRESHOLD checks are recovered, diagnostics are logged once they can't
+	// be recovered within WATCHDOG_MAX_RECOVERIES attempts, the process exits as well if WATCHDOG_EXIT_ENABLED is set
+	WatchdogEnabled          bool `envconfig:"WATCHDOG_ENABLED"`
+	WatchdogInterval         int  `envconfig:"WATCHDOG_INTERVAL" validate:"omitempty,min=1"` // in seconds
+	WatchdogFailureThreshold int  `envconfig:"WATCHDOG_FAILURE_THRESHOLD" validate:"omitempty,min=1"`
+	WatchdogMaxRecoveries    int  `envconfig:"WATCHDOG_MAX_RECOVERIES" validate:"omitempty,min=1"`
+	WatchdogExitEnabled      bool `envconfig:"WATCHDOG_EXIT_ENABLED"`
+	// the cluster lifetime loop is stuck once it handles no event for the timeout
+	WatchdogClusterLoopTimeout int `envconfig:"WATCHDOG_CLUSTER_LOOP_TIMEOUT" validate:"omitempty,min=1"` // in seconds
+
+	// latency, dropped plugin events, plugin crashes and db or redis errors are injected by rules of each node,
+	// FAULT_INJECTION_RULES is a json array of rules set on startup, rules are replaced by the admin api as well,
+	// it's meant for resilience tests and should never be enabled in production
+	FaultInjectionEnabled bool   `envconfig:"FAULT_INJECTION_ENABLED"`
+	FaultInjectionRules   string `envconfig:"FAULT_INJECTION_RULES"`
+
+	// config values like `vault://secret/data/dify#db_password`, `aws-sm://dify/daemon#server_key` or
+	// `gcp-sm://projects/dify/secrets/server-key` are read from secret managers on startup and refreshed
+	// every SECRETS_REFRESH_INTERVAL, rotated server keys take effect right away, the others after a restart
+	SecretsRefreshInterval int    `envconfig:"SECRETS_REFRESH_INTERVAL" validate:"omitempty,min=1"` // in seconds
+	VaultAddr              string `envconfig:"VAULT_ADDR" validate:"omitempty,url"`
+	VaultToken             string `envconfig:"VAULT_TOKEN"`
+	VaultNamespace         string `envconfig:"VAULT_NAMESPACE"`
+	SecretsAWSRegion       string `envconfig:"SECRETS_AWS_REGION"` // region of the default aws config if empty
+
+	// credentials sent to plugins and values looking like common secrets are masked in logs, stderr and errors of plugins
+	PluginOutputRedactionEnabled *bool `envconfig:"PLUGIN_OUTPUT_REDACTION_ENABLED"`
+
+	// local plugins reach the outside through a filtering proxy only letting connections to the domains
+	// declared in their network permission through, plugins declaring no network permission are unrestricted
+	// unless PLUGIN_EGRESS_ENFORCE_UNDECLARED is set
+	PluginEgressPolicyEnabled     bool `envconfig:"PLUGIN_EGRESS_POLICY_ENABLED"`
+	PluginEgressProxyPort         int  `envconfig:"PLUGIN_EGRESS_PROXY_PORT" validate:"omitempty,min=1,max=65535"` // a random port if empty
+	PluginEgressEnforceUndeclared bool `envconfig:"PLUGIN_EGRESS_ENFORCE_UNDECLARED"`
+
+	// local plugins run as dedicated users with uids and gids from PLUGIN_RUN_AS_USER_UID_BASE on, each of them
+	// owns its working directory exclusively and inherits no environment of the daemon, the daemon has to run as root
+	PluginRunAsUserEnabled  bool `envconfig:"PLUGIN_RUN_AS_USER_ENABLED"`
+	PluginRunAsUserUIDBase  int  `envconfig:"PLUGIN_RUN_AS_USER_UID_BASE" validate:"omitempty,min=1000"`
+	PluginRunAsUserUIDCount int  `envconfig:"PLUGIN_RUN_AS_USER_UID_COUNT" validate:"omitempty,min=1"`
+
+	// urls fetched on behalf of tenants and plugins, like webhooks and connections of the egress proxy of plugins,
+	// can't reach loopback, private, link-local or cloud metadata addresses unless they are in SSRF_ALLOWED_CIDRS,
+	// addresses are checked after resolving so guarded connections bypass HTTP_PROXY
+	SSRFProtectionEnabled *bool    `envconfig:"SSRF_PROTECTION_ENABLED"`
+	SSRFAllowedCIDRs      []string `envconfig:"SSRF_ALLOWED_CIDRS" reload:"true"`
+	SSRFAllowedSchemes    []string `envconfig:"SSRF_ALLOWED_SCHEMES" reload:"true"`
+
+	// decryptions of credentials like endpoint settings are recorded into an append-only table,
+	// records are posted to CREDENTIAL_AUDIT_EXPORT_URL as newline delimited json as well if it's set
+	CredentialAuditEnabled     *bool  `envconfig:"CREDENTIAL_AUDIT_ENABLED"`
+	CredentialAuditExportURL   string `envconfig:"CREDENTIAL_AUDIT_EXPORT_URL" validate:"omitempty,url"`
+	CredentialAuditExportToken string `envconfig:"CREDENTIAL_AUDIT_EXPORT_TOKEN"`
+
+	// secrets stored by the daemon like signing secrets of webhooks are encrypted by the active one of
+	// ENCRYPTION_KEYS like `1:<base64 key>,2:<base64 key>`, ENCRYPTION_ACTIVE_KEY_VERSION is required once there are more than one,
+	// secrets encrypted by other keys are re-encrypted every ENCRYPTION_REENCRYPT_INTERVAL
+	EncryptionKeys              string `envconfig:"ENCRYPTION_KEYS"`
+	EncryptionActiveKeyVersion  int    `envconfig:"ENCRYPTION_ACTIVE_KEY_VERSION" validate:"omitempty,min=1"`
+	EncryptionReencryptInterval int    `envconfig:"ENCRYPTION_REENCRYPT_INTERVAL" validate:"omitempty,min=1"` // in seconds
+	// cipher of new secrets, aes-gcm or chacha20-poly1305, ciphertexts record their ciphers
+	EncryptionCipher string `envconfig:"ENCRYPTION_CIPHER" validate:"omitempty,oneof=aes-gcm chacha20-poly1305"`
+	// the daemon refuses to start unless it runs on a FIPS 140 validated crypto provider, the native module of
+	// Go 1.24+ enabled by GODEBUG=fips140=on or BoringCrypto, ciphers not approved by FIPS are refused as well
+	FIPSModeEnabled bool `envconfig:"FIPS_MODE_ENABLED"`
+	// secret settings passed through the encrypt invocation, like settings of endpoints, are encrypted by keys
+	// derived for each tenant from ENCRYPTION_KEYS rather than by dify, secrets encrypted by dify are still
+	// decrypted by it and re-encrypted by tenant keys once endpoints are accessed
+	TenantEncryptionEnabled bool `envconfig:"TENANT_ENCRYPTION_ENABLED"`
+
+	// a sample of requests is logged with their route, tenant, status, latency and sizes, the rate of a route is
+	// overridden by REQUEST_LOG_ROUTE_SAMPLE_RATES like `/e/:hook_id=0.01`, the longest matching prefix wins,
+	// the pointer tells an unset rate from 0 which logs none but the routes overridden
+	RequestLogEnabled          bool     `envconfig:"REQUEST_LOG_ENABLED"`
+	RequestLogSampleRate       *float64 `envconfig:"REQUEST_LOG_SAMPLE_RATE" validate:"omitempty,min=0,max=1"`
+	RequestLogRouteSampleRates string   `envconfig:"REQUEST_LOG_ROUTE_SAMPLE_RATES"`
+
 	// log settings
-	HealthApiLogEnabled *bool `envconfig:"HEALTH_API_LOG_ENABLED"`
+	HealthApiLogEnabled *bool  `envconfig:"HEALTH_API_LOG_ENABLED"`
+	LogFormat           string `envconfig:"LOG_FORMAT" validate:"omitempty,oneof=text json"`
+	LogLevel            string `envconfig:"LOG_LEVEL" validate:"omitempty,oneof=debug info warn error" reload:"true"`
+
+	// settings are read from the yaml file as well, keys of it are names of the environment variables and variables
+	// set in the environment take precedence, settings tagged by `reload` are reloaded on SIGHUP or once the file
+	// changes, changes of other settings take effect after restarting
+	ConfigFile              string `envconfig:"CONFIG_FILE"`
+	ConfigFileWatchInterval int    `envconfig:"CONFIG_FILE_WATCH_INTERVAL" validate:"omitempty,min=1"` // in seconds
+	// settings in effect are logged at startup with sensitive ones masked, hosts of urls the daemon calls like
+	// DIFY_INNER_API_URL are probed, unreachable ones are warned or keep the daemon from starting if it's enforce
+	ConfigDumpEnabled     *bool  `envconfig:"CONFIG_DUMP_ENABLED"`
+	ConfigURLCheck        string `envconfig:"CONFIG_URL_CHECK" validate:"omitempty,oneof=off warn enforce"`
+	ConfigURLCheckTimeout int    `envconfig:"CONFIG_URL_CHECK_TIMEOUT" validate:"omitempty,min=1"` // in seconds
 }
 
 func (c *Config) Validate() error {
@@ -146,13 +503,47 @@ func (c *Config) Validate() error {
 		}
 	}
 
-	if c.Platform == PLATFORM_SERVERLESS {
-		if c.DifyPluginServerlessConnectorURL == nil {
-			return fmt.Errorf("dify plugin serverless connector url is empty")
-		}
+	if c.TenantTokenRequired && c.TenantTokenSecret == "" {
+		return fmt.Errorf("tenant token secret is required once tenant tokens are required")
+	}
 
-		if c.DifyPluginServerlessConnectorAPIKey == nil {
-			return fmt.Errorf("dify plugin serverless connector api key is empty")
+	if c.AdminAuthOIDCIssuer != "" && c.AdminAuthOIDCClientID == "" {
+		return fmt.Errorf("admin auth oidc client id is required once the oidc issuer is set")
+	}
+
+	if c.AdminAuthLDAPURL != "" && c.AdminAuthLDAPBaseDN == "" {
+		return fmt.Errorf("admin auth ldap base dn is required once the ldap url is set")
+	}
+
+	if (c.AdminAuthOIDCIssuer != "" || c.AdminAuthLDAPURL != "") && len(c.AdminAuthRoleMapping) == 0 {
+		return fmt.Errorf("admin auth role mapping is required once oidc or ldap is set")
+	}
+
+	if c.PersistenceStorageType == "aws_s3" && c.PersistenceStorageOSSBucket == "" {
+		return fmt.Errorf("persistence storage oss bucket is required once the persistence storage type is aws_s3")
+	}
+
+	if c.EventExportType != "" && len(c.EventExportBrokers) == 0 {
+		return fmt.Errorf("event export brokers are required once the event export type is set")
+	}
+
+	if c.ServerTLSEnabled && (c.ServerTLSCertFile == "" || c.ServerTLSKeyFile == "") {
+		return fmt.Errorf("server tls cert file and key file are required once tls is enabled")
+	}
+
+	if c.Platform == PLATFORM_SERVERLESS {
+		if c.ServerlessProvider == SERVERLESS_PROVIDER_HTTP {
+			if c.ServerlessHTTPDeployerURL == "" {
+				return fmt.Errorf("serverless http deployer url is empty")
+			}
+		} else {
+			if c.DifyPluginServerlessConnectorURL == nil {
+				return fmt.Errorf("dify plugin serverless connector url is empty")
+			}
+
+			if c.DifyPluginServerlessConnectorAPIKey == nil {
+				return fmt.Errorf("dify plugin serverless connector api key is empty")
+			}
 		}
 
 		if c.MaxServerlessTransactionTimeout == 0 {
@@ -166,6 +557,16 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid platform")
 	}
 
+	switch c.NodeRole {
+	case NODE_ROLE_ALL, NODE_ROLE_RUNNER:
+	case NODE_ROLE_GATEWAY:
+		if c.Platform != PLATFORM_LOCAL {
+			return fmt.Errorf("gateway role is only available on local platform")
+		}
+	default:
+		return fmt.Errorf("invalid node role: %s", c.NodeRole)
+	}
+
 	if c.PluginPackageCachePath == "" {
 		return fmt.Errorf("plugin package cache path is empty")
 	}
@@ -185,7 +586,28 @@ func (c *Config) Validate() error {
 
 type PlatformType string
 
+const (
+	PLUGIN_PACKAGE_SCAN_POLICY_BLOCK = "block"
+	PLUGIN_PACKAGE_SCAN_POLICY_WARN  = "warn"
+)
+
+const (
+	SERVERLESS_PROVIDER_AWS  = "aws"
+	SERVERLESS_PROVIDER_HTTP = "http"
+)
+
 const (
 	PLATFORM_LOCAL      PlatformType = "local"
 	PLATFORM_SERVERLESS PlatformType = "serverless"
 )
+
+type NodeRole string
+
+const (
+	// NODE_ROLE_ALL serves HTTP requests and runs plugins
+	NODE_ROLE_ALL NodeRole = "all"
+	// NODE_ROLE_GATEWAY serves HTTP requests only, plugin invocations are forwarded to runners
+	NODE_ROLE_GATEWAY NodeRole = "gateway"
+	// NODE_ROLE_RUNNER runs plugins, it's expected to receive invocations forwarded by gateways
+	NODE_ROLE_RUNNER NodeRole = "runner"
+)
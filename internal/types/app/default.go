@@ -4,6 +4,7 @@ import "golang.org/x/exp/constraints"
 
 func (config *Config) SetDefault() {
 	setDefaultInt(&config.ServerPort, 5002)
+	setDefaultInt(&config.GRPCServerPort, 5004)
 	setDefaultInt(&config.RoutinePoolSize, 10000)
 	setDefaultInt(&config.LifetimeCollectionGCInterval, 60)
 	setDefaultInt(&config.LifetimeCollectionHeartbeatInterval, 5)
@@ -12,9 +13,76 @@ func (config *Config) SetDefault() {
 	setDefaultInt(&config.PluginRemoteInstallServerEventLoopNums, 8)
 	setDefaultInt(&config.PluginRemoteInstallingMaxConn, 256)
 	setDefaultInt(&config.MaxPluginPackageSize, 52428800)
+	setDefaultInt(&config.PluginAssetMaxSize, 5242880)
+	setDefaultInt(&config.AssetSignedURLTTL, 3600)
 	setDefaultInt(&config.MaxBundlePackageSize, 52428800*12)
 	setDefaultInt(&config.MaxServerlessTransactionTimeout, 300)
+	setDefaultString(&config.ServerlessProvider, SERVERLESS_PROVIDER_AWS)
+	setDefaultBoolPtr(&config.ServerlessPrewarmEnabled, false)
+	setDefaultInt(&config.ServerlessPrewarmInterval, 60)
+	setDefaultInt(&config.ServerlessPrewarmWarmWindow, 600)
+	setDefaultInt(&config.ServerlessMaxIdleConnsPerFunction, 100)
+	setDefaultInt(&config.ServerlessIdleConnTimeout, 120)
+	setDefaultInt(&config.ServerlessKeepAlive, 120)
+	setDefaultInt(&config.ServerlessDialTimeout, 5)
+	setDefaultInt(&config.ServerlessResponseIdleTimeout, 240)
+	setDefaultIntPtr(&config.ServerlessRetryBudgetPercent, 10)
+	setDefaultIntPtr(&config.ServerlessMaxRetries, 2)
+	setDefaultInt(&config.ServerlessCredentialsReloadInterval, 30)
+	setDefaultInt(&config.ServerlessRegionFailureThreshold, 3)
+	setDefaultInt(&config.ServerlessRegionUnhealthyCooldown, 30)
+	setDefaultInt(&config.ServerlessDefaultTimeout, 300)
+	setDefaultInt(&config.ServerlessDefaultEphemeralStorage, 512)
+	setDefaultInt(&config.ServerlessMaxMemory, 10240)
+	setDefaultInt(&config.ServerlessMaxTimeout, 900)
+	setDefaultInt(&config.ServerlessMaxEphemeralStorage, 10240)
+	setDefaultBoolPtr(&config.ServerlessTelemetryEnabled, true)
+	setDefaultInt(&config.ServerlessTelemetryFlushInterval, 60)
+	// prices of AWS Lambda on x86
+	setDefaultFloat(&config.ServerlessCostPerGBSecond, 0.0000166667)
+	setDefaultFloat(&config.ServerlessCostPerMillionRequests, 0.2)
+	setDefaultFloatPtr(&config.TracingSampleRate, 1.0)
+	setDefaultInt(&config.SlowLogThreshold, 10000)
+	setDefaultInt(&config.ConfigFileWatchInterval, 10)
+	setDefaultBoolPtr(&config.ConfigDumpEnabled, true)
+	setDefaultString(&config.ConfigURLCheck, "warn")
+	setDefaultInt(&config.ConfigURLCheckTimeout, 3)
+	setDefaultInt(&config.SlowLogCapacity, 256)
+	setDefaultInt(&config.SLOWindow, 60)
+	setDefaultFloat(&config.SLOSuccessRate, 0.99)
+	setDefaultString(&config.AdminKey, config.ServerKey)
+	setDefaultInt(&config.UsageAnalyticsHourlyRetention, 7)
+	setDefaultFloatPtr(&config.RequestLogSampleRate, 1.0)
+	setDefaultString(&config.ServerTLSMinVersion, "1.2")
+	setDefaultInt(&config.ServerTLSReloadInterval, 30)
+	setDefaultInt(&config.EncryptionReencryptInterval, 3600)
+	setDefaultString(&config.EncryptionCipher, "aes-gcm")
+	setDefaultBoolPtr(&config.CredentialAuditEnabled, true)
+	setDefaultBoolPtr(&config.PluginOutputRedactionEnabled, true)
+	setDefaultInt(&config.PluginRunAsUserUIDBase, 200000)
+	setDefaultInt(&config.PluginRunAsUserUIDCount, 10000)
+	setDefaultBoolPtr(&config.SSRFProtectionEnabled, true)
+	setDefaultStrings(&config.SSRFAllowedSchemes, []string{"http", "https"})
+	setDefaultInt(&config.SecretsRefreshInterval, 300)
+	setDefaultInt(&config.WatchdogInterval, 10)
+	setDefaultInt(&config.WatchdogFailureThreshold, 3)
+	setDefaultInt(&config.WatchdogMaxRecoveries, 3)
+	setDefaultInt(&config.WatchdogClusterLoopTimeout, 60)
+	setDefaultInt(&config.SentryCrashLoopThreshold, 3)
+	setDefaultInt(&config.SentryCrashLoopWindow, 600)
+	setDefaultBoolPtr(&config.SentryInvocationErrorsEnabled, true)
+	setDefaultBoolPtr(&config.ServerlessFailoverEnabled, false)
+	setDefaultInt(&config.ServerlessFailoverThreshold, 5)
+	setDefaultInt(&config.ServerlessFailoverWindow, 60)
+	setDefaultInt(&config.ServerlessFailoverRecoveryInterval, 30)
+	setDefaultInt(&config.ServerlessFailoverDrainTimeout, 60)
 	setDefaultInt(&config.PluginMaxExecutionTimeout, 10*60)
+	setDefaultString(&config.NodeRole, NODE_ROLE_ALL)
+	setDefaultBoolPtr(&config.ClusterLoadBalancingEnabled, true)
+	setDefaultInt(&config.ClusterLoadBalanceThreshold, 10)
+	setDefaultInt(&config.SingletonJobLeaseDuration, 15)
+	setDefaultBoolPtr(&config.PluginReconcileEnabled, true)
+	setDefaultInt(&config.PluginReconcileInterval, 300)
 	setDefaultString(&config.PluginStorageType, "local")
 	setDefaultInt(&config.PluginMediaCacheSize, 1024)
 	setDefaultInt(&config.PluginRemoteInstallingMaxSingleTenantConn, 5)
@@ -27,10 +95,43 @@ func (config *Config) SetDefault() {
 	setDefaultString(&config.PersistenceStoragePath, "persistence")
 	setDefaultInt(&config.PluginLocalLaunchingConcurrent, 2)
 	setDefaultInt(&config.PersistenceStorageMaxSize, 100*1024*1024)
+	setDefaultString(&config.EventExportTopicPrefix, "dify.plugin")
+	setDefaultInt(&config.EventExportBufferSize, 10000)
+	setDefaultString(&config.PersistenceStorageType, "plugin_storage")
+	setDefaultString(&config.PersistenceStorageLocalRoot, "persistence_storage")
+	setDefaultInt(&config.PersistenceCacheMaxSize, 64*1024)
 	setDefaultString(&config.PluginPackageCachePath, "plugin_packages")
 	setDefaultString(&config.PythonInterpreterPath, "/usr/bin/python3")
 	setDefaultInt(&config.PythonEnvInitTimeout, 120)
 	setDefaultBoolPtr(&config.ForceVerifyingSignature, true)
+	setDefaultBoolPtr(&config.PluginPackageScanEnabled, true)
+	setDefaultString(&config.PluginPackageScanPolicy, PLUGIN_PACKAGE_SCAN_POLICY_WARN)
+	setDefaultInt(&config.PluginPackageScanMaxFiles, 10000)
+	setDefaultInt(&config.PluginBatchOperationConcurrency, 8)
+	setDefaultBoolPtr(&config.PluginPackGitEnabled, false)
+	setDefaultInt(&config.PluginPackGitTimeout, 120)
+	setDefaultBoolPtr(&config.PluginDevModeEnabled, false)
+	setDefaultInt(&config.PluginDevWatchInterval, 2)
+	setDefaultBoolPtr(&config.PluginGCEnabled, false)
+	setDefaultInt(&config.PluginGCInterval, 3600)
+	setDefaultInt(&config.PluginGCGracePeriod, 86400)
+	setDefaultInt(&config.WebhookTimeout, 10)
+	setDefaultInt(&config.IdempotencyKeyWindow, 86400)
+	setDefaultInt(&config.ModelResponseCacheTTL, 3600)
+	setDefaultInt(&config.ModelResponseCacheMaxBytes, 1024*1024)
+	setDefaultInt(&config.GuardrailModerationTimeout, 5)
+	setDefaultString(&config.ToolOutputSchemaMode, "enforce")
+	setDefaultInt(&config.TenantTokenMaxTTL, 300)
+	setDefaultBoolPtr(&config.ServerHTTP2Enabled, true)
+	setDefaultBoolPtr(&config.ServerCompressionEnabled, true)
+	setDefaultInt(&config.ServerCompressionMinSize, 1024)
+	setDefaultString(&config.ServerClientIPHeaders, "X-Forwarded-For,X-Real-IP")
+	setDefaultString(&config.AdminCORSAllowedHeaders, "Content-Type,Authorization,X-Api-Key,X-Request-Id,X-Api-Version,Idempotency-Key")
+	setDefaultInt(&config.AdminCORSMaxAge, 600)
+	setDefaultString(&config.AdminAuthOIDCGroupsClaim, "groups")
+	setDefaultString(&config.AdminAuthLDAPUserFilter, "(uid=%s)")
+	setDefaultString(&config.AdminAuthLDAPGroupAttribute, "memberOf")
+	setDefaultInt(&config.WebhookMaxRetries, 3)
 	setDefaultBoolPtr(&config.PipPreferBinary, true)
 	setDefaultBoolPtr(&config.PipVerbose, true)
 	if config.DBType == "postgresql" {
@@ -39,6 +140,9 @@ func (config *Config) SetDefault() {
 		setDefaultString(&config.DBDefaultDatabase, "mysql")
 	}
 	setDefaultBoolPtr(&config.HealthApiLogEnabled, true)
+	setDefaultBoolPtr(&config.OpenAPIEnabled, true)
+	setDefaultString(&config.LogFormat, "text")
+	setDefaultString(&config.LogLevel, "debug")
 }
 
 func setDefaultInt[T constraints.Integer](value *T, defaultValue T) {
@@ -47,14 +151,38 @@ func setDefaultInt[T constraints.Integer](value *T, defaultValue T) {
 	}
 }
 
-func setDefaultString(value *string, defaultValue string) {
+func setDefaultFloat[T constraints.Float](value *T, defaultValue T) {
+	if *value == 0 {
+		*value = defaultValue
+	}
+}
+
+func setDefaultString[T ~string](value *T, defaultValue T) {
 	if *value == "" {
 		*value = defaultValue
 	}
 }
 
+func setDefaultStrings(value *[]string, defaultValue []string) {
+	if len(*value) == 0 {
+		*value = defaultValue
+	}
+}
+
 func setDefaultBoolPtr(value **bool, defaultValue bool) {
 	if *value == nil {
 		*value = &defaultValue
 	}
 }
+
+func setDefaultIntPtr(value **int, defaultValue int) {
+	if *value == nil {
+		*value = &defaultValue
+	}
+}
+
+func setDefaultFloatPtr(value **float64, defaultValue float64) {
+	if *value == nil {
+		*value = &defaultValue
+	}
+}
@@ -1,9 +1,13 @@
 package exception
 
 import (
+	"errors"
 	"runtime/debug"
+	"strings"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
 )
 
 const (
@@ -19,6 +23,12 @@ const (
 	PluginPermissionDeniedError       = "PluginPermissionDeniedError"
 	PluginInvokeError                 = "PluginInvokeError"
 	PluginConnectionClosedError       = "ConnectionClosedError"
+	PluginDaemonNodeAtCapacityError   = "PluginDaemonNodeAtCapacityError"
+	PluginDaemonNodeFencedError       = "PluginDaemonNodeFencedError"
+	PluginDaemonRateLimitedError      = "PluginDaemonRateLimitedError"
+	PluginDaemonConflictError         = "PluginDaemonConflictError"
+	PluginDaemonValidationError       = "PluginDaemonValidationError"
+	PluginToolOutputSchemaError       = "PluginToolOutputSchemaError"
 )
 
 func InternalServerError(err error) PluginDaemonError {
@@ -57,6 +67,11 @@ func PermissionDeniedError(msg string) PluginDaemonError {
 }
 
 func InvokePluginError(err error) PluginDaemonError {
+	// errors described by the daemon already are kept as they are
+	var daemonError PluginDaemonError
+	if errors.As(err, &daemonError) {
+		return daemonError
+	}
 	return ErrorWithTypeAndCode(err.Error(), PluginInvokeError, -500)
 }
 
@@ -65,3 +80,56 @@ func InvokePluginError(err error) PluginDaemonError {
 func ConnectionClosedError() PluginDaemonError {
 	return ErrorWithTypeAndCode("connection closed", PluginConnectionClosedError, -500)
 }
+
+// NodeAtCapacityError is returned once the node serves as many sessions as it's configured to,
+// the caller should retry on another node
+func NodeAtCapacityError() PluginDaemonError {
+	return ErrorWithTypeAndCode("node at capacity", PluginDaemonNodeAtCapacityError, -503)
+}
+
+// NodeFencedError is returned once the node is partitioned from the cluster and takes no new session
+func NodeFencedError() PluginDaemonError {
+	return ErrorWithTypeAndCode("node is partitioned from the cluster", PluginDaemonNodeFencedError, -503)
+}
+
+// RateLimitedError is returned once the caller exceeds the rate limit of its api token
+func RateLimitedError() PluginDaemonError {
+	return ErrorWithTypeAndCode("rate limit exceeded", PluginDaemonRateLimitedError, -429)
+}
+
+// ConflictError is returned once the request conflicts with another one being served
+func ConflictError(msg string) PluginDaemonError {
+	return ErrorWithTypeAndCode(msg, PluginDaemonConflictError, -409)
+}
+
+// ValidationError is returned once fields of the request are missing or invalid,
+// each of them is described in the `fields` arg
+func ValidationError(fields []validators.FieldError) PluginDaemonError {
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field.String())
+	}
+
+	return &genericError{
+		Message:   "invalid request: " + strings.Join(messages, "; "),
+		code:      -422,
+		ErrorType: PluginDaemonValidationError,
+		Args:      map[string]any{"fields": fields},
+	}
+}
+
+// ToolOutputSchemaError is returned once variables emitted by the tool violate the output schema declared by it,
+// each violation is described in the `violations` arg
+func ToolOutputSchemaError(tool string, violations []tool_entities.ToolOutputSchemaViolation) PluginDaemonError {
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, violation.String())
+	}
+
+	return &genericError{
+		Message:   "output of tool " + tool + " violates its output schema: " + strings.Join(messages, "; "),
+		code:      -500,
+		ErrorType: PluginToolOutputSchemaError,
+		Args:      map[string]any{"tool": tool, "violations": violations},
+	}
+}
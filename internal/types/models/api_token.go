@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// APIToken authenticates a caller of the apis with the scopes granted to it, only the hash of the token
+// is stored, the token itself is returned once on creation
+type APIToken struct {
+	Model
+	Name      string   `json:"name" gorm:"column:name;size:127;not null"`
+	TokenHash string   `json:"-" gorm:"column:token_hash;size:64;uniqueIndex;not null"`
+	Prefix    string   `json:"prefix" gorm:"column:prefix;size:16"` // tells tokens apart without revealing them
+	Scopes    []string `json:"scopes" gorm:"column:scopes;serializer:json;type:text"`
+	// TenantID binds the token to a tenant, it's only accepted by routes of the tenant then
+	TenantID string `json:"tenant_id" gorm:"column:tenant_id;size:255;index"`
+	// RateLimit is the max number of requests per minute on each node, 0 means unlimited
+	RateLimit  int        `json:"rate_limit" gorm:"column:rate_limit;default:0"`
+	ExpiresAt  *time.Time `json:"expires_at" gorm:"column:expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" gorm:"column:last_used_at"`
+	Revoked    bool       `json:"revoked" gorm:"column:revoked;default:false"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// CredentialAccessLog records a decryption of credentials like endpoint settings, logs are append-only
+type CredentialAccessLog struct {
+	Model
+	TenantID  string `json:"tenant_id" gorm:"column:tenant_id;size:64;index;not null"`
+	Namespace string `json:"namespace" gorm:"column:namespace;size:32;not null"`
+	// Identity is the id of the credentials owner, like the id of the endpoint
+	Identity string `json:"identity" gorm:"column:identity;size:64;index;not null"`
+	PluginID string `json:"plugin_id" gorm:"column:plugin_id;size:255"`
+	// Caller is the server key or the api token decrypting the credentials, or `endpoint` for endpoint invocations
+	Caller    string `json:"caller" gorm:"column:caller;size:255"`
+	UserID    string `json:"user_id" gorm:"column:user_id;size:64"`
+	SessionID string `json:"session_id" gorm:"column:session_id;size:64"`
+	RequestID string `json:"request_id" gorm:"column:request_id;size:128"`
+	ClientIP  string `json:"client_ip" gorm:"column:client_ip;size:64"`
+	Reason    string `json:"reason" gorm:"column:reason;size:64;not null"`
+	Success   bool   `json:"success" gorm:"column:success"`
+	Error     string `json:"error" gorm:"column:error;size:1024"`
+}
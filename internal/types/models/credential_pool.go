@@ -0,0 +1,25 @@
+package models
+
+// CredentialPool is a pool of credentials of a model provider of a tenant, model invocations referencing
+// the pool by name take a key of it
+type CredentialPool struct {
+	Model
+	TenantID string `json:"tenant_id" gorm:"column:tenant_id;size:64;uniqueIndex:idx_credential_pool_name;not null"`
+	PluginID string `json:"plugin_id" gorm:"column:plugin_id;size:255;uniqueIndex:idx_credential_pool_name;not null"`
+	Provider string `json:"provider" gorm:"column:provider;size:127;uniqueIndex:idx_credential_pool_name;not null"`
+	Name     string `json:"name" gorm:"column:name;size:127;uniqueIndex:idx_credential_pool_name;not null"`
+}
+
+// CredentialPoolKey is a set of credentials of a pool, keys are taken by their weights and skipped
+// for their cooldowns once plugins report them rate limited
+type CredentialPoolKey struct {
+	Model
+	PoolID string `json:"pool_id" gorm:"column:pool_id;size:36;index;not null"`
+	Label  string `json:"label" gorm:"column:label;size:127"`
+	// Credentials is the json of credentials encrypted by the keyring
+	Credentials           string `json:"-" gorm:"column:credentials;type:text;not null"`
+	CredentialsKeyVersion int    `json:"-" gorm:"column:credentials_key_version;default:0;index"`
+	Weight                int    `json:"weight" gorm:"column:weight;default:1"`
+	CooldownSeconds       int    `json:"cooldown_seconds" gorm:"column:cooldown_seconds;default:60"`
+	Disabled              bool   `json:"disabled" gorm:"column:disabled;default:false"`
+}
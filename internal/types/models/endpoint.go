@@ -19,3 +19,45 @@ type Endpoint struct {
 	Settings    map[string]any                               `json:"settings" gorm:"column:settings;serializer:json"`
 	Declaration *plugin_entities.EndpointProviderDeclaration `json:"declaration" gorm:"-"` // not stored in db
 }
+
+// DeclaredEndpoint marks an endpoint set up by a declared state, the fingerprint is the one of settings
+// declared, settings are stored encrypted so drifts are told by fingerprints instead of comparing settings
+type DeclaredEndpoint struct {
+	Model
+	TenantID    string `json:"tenant_id" gorm:"index;size:64;column:tenant_id"`
+	EndpointID  string `json:"endpoint_id" gorm:"unique;size:36;column:endpoint_id"`
+	Fingerprint string `json:"fingerprint" gorm:"size:64;column:fingerprint"`
+}
+
+// EndpointTemplate is a parameterized endpoint of a plugin, endpoints materialized from it have their names,
+// settings, path prefixes and header values interpolated by values of its variables like `{{team}}`
+type EndpointTemplate struct {
+	Model
+	TenantID     string                     `json:"tenant_id" gorm:"size:64;column:tenant_id;uniqueIndex:idx_endpoint_template_name"`
+	PluginID     string                     `json:"plugin_id" gorm:"size:255;column:plugin_id;index"`
+	Name         string                     `json:"name" gorm:"size:127;column:name;uniqueIndex:idx_endpoint_template_name"`
+	EndpointName string                     `json:"endpoint_name" gorm:"size:255;column:endpoint_name"`
+	Variables    []EndpointTemplateVariable `json:"variables" gorm:"column:variables;serializer:json;type:text"`
+	Settings     map[string]any             `json:"settings" gorm:"column:settings;serializer:json;type:text"`
+	// PathPrefix is prepended to paths of requests sent to the plugin
+	PathPrefix string `json:"path_prefix" gorm:"size:255;column:path_prefix"`
+	// Headers are set on requests sent to the plugin
+	Headers map[string]string `json:"headers" gorm:"column:headers;serializer:json;type:text"`
+}
+
+// EndpointTemplateVariable is a variable of a template, values of secret ones are never replied
+type EndpointTemplateVariable struct {
+	Name   string `json:"name"`
+	Secret bool   `json:"secret"`
+}
+
+// EndpointTemplateInstance links an endpoint to the template it's materialized from, values of the variables
+// and the path prefix and headers rendered by them are encrypted into data as they may carry secrets
+type EndpointTemplateInstance struct {
+	Model
+	TenantID       string `json:"tenant_id" gorm:"index;size:64;column:tenant_id"`
+	TemplateID     string `json:"template_id" gorm:"index;size:36;column:template_id"`
+	EndpointID     string `json:"endpoint_id" gorm:"unique;size:36;column:endpoint_id"`
+	Data           string `json:"-" gorm:"column:data;type:text"`
+	DataKeyVersion int    `json:"-" gorm:"column:data_key_version;default:0;index"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// FeatureFlag configures a flag gating a behavior of the daemon, the flag is on for tenants listed in Tenants,
+// off for tenants listed in BlockedTenants, and on for the percentage of other tenants, it's off for all the
+// tenants if it's not enabled
+type FeatureFlag struct {
+	Model
+	Name           string   `json:"name" gorm:"column:name;size:127;uniqueIndex;not null"`
+	Enabled        bool     `json:"enabled" gorm:"column:enabled"`
+	Percentage     int      `json:"percentage" gorm:"column:percentage;default:0"`
+	Tenants        []string `json:"tenants" gorm:"column:tenants;serializer:json;type:text"`
+	BlockedTenants []string `json:"blocked_tenants" gorm:"column:blocked_tenants;serializer:json;type:text"`
+}
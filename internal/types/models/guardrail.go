@@ -0,0 +1,39 @@
+package models
+
+// GuardrailPolicy is the pipeline of hooks inspecting inputs and outputs of tools invoked by a tenant,
+// hooks run in order and each of them sees texts transformed by the previous ones
+type GuardrailPolicy struct {
+	Model
+	TenantID string          `json:"tenant_id" gorm:"column:tenant_id;size:64;uniqueIndex;not null"`
+	Enabled  bool            `json:"enabled" gorm:"column:enabled"`
+	Hooks    []GuardrailHook `json:"hooks" gorm:"column:hooks;serializer:json;type:text"`
+}
+
+// GuardrailHook inspects texts of the stage, `regex` matches the pattern, `pii` matches kinds of personal
+// information like emails, `moderation` asks the moderation api configured for the daemon
+type GuardrailHook struct {
+	Name   string `json:"name" validate:"required,max=64"`
+	Type   string `json:"type" validate:"required,oneof=regex pii moderation"`
+	Stage  string `json:"stage" validate:"required,oneof=input output both"`
+	Action string `json:"action" validate:"required,oneof=block flag redact"`
+	// Pattern is the regular expression of `regex` hooks
+	Pattern string `json:"pattern,omitempty"`
+	// PII are kinds matched by `pii` hooks, all the kinds if it's empty
+	PII []string `json:"pii,omitempty"`
+	// Replacement replaces matches of `redact` hooks, [REDACTED] if it's empty
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// GuardrailEvent records a hook blocking, flagging or redacting an input or output of a tool
+type GuardrailEvent struct {
+	Model
+	TenantID  string `json:"tenant_id" gorm:"column:tenant_id;size:64;index:idx_guardrail_event_tenant;not null"`
+	SessionID string `json:"session_id" gorm:"column:session_id;size:64"`
+	PluginID  string `json:"plugin_id" gorm:"column:plugin_id;size:255"`
+	Provider  string `json:"provider" gorm:"column:provider;size:127"`
+	Tool      string `json:"tool" gorm:"column:tool;size:127"`
+	Stage     string `json:"stage" gorm:"column:stage;size:16"`
+	Hook      string `json:"hook" gorm:"column:hook;size:64"`
+	Outcome   string `json:"outcome" gorm:"column:outcome;size:16;index:idx_guardrail_event_tenant"`
+	Detail    string `json:"detail" gorm:"column:detail;size:1024"`
+}
@@ -30,6 +30,37 @@ type ServerlessRuntime struct {
 	FunctionName           string                `json:"function_name" gorm:"size:127"`
 	Type                   ServerlessRuntimeType `json:"type" gorm:"size:127"`
 	Checksum               string                `json:"checksum" gorm:"size:127;index"`
+	// MemoryMB is the memory the function is deployed with, 0 if it's left to the provider
+	MemoryMB int64 `json:"memory_mb"`
+}
+
+// ServerlessRuntimePin routes invocations of a plugin to the function deployed for another version of it,
+// it's used to roll back a misbehaving release without redeploying
+type ServerlessRuntimePin struct {
+	Model
+	PluginUniqueIdentifier       string `json:"plugin_unique_identifier" gorm:"size:255;unique"`
+	TargetPluginUniqueIdentifier string `json:"target_plugin_unique_identifier" gorm:"size:255;index"`
+}
+
+// ServerlessRegionalFunction is the function of a plugin deployed to a region, ServerlessRuntime keeps
+// the function of the first region so that it's still served if regional deployment is disabled later
+type ServerlessRegionalFunction struct {
+	Model
+	PluginUniqueIdentifier string `json:"plugin_unique_identifier" gorm:"size:255;uniqueIndex:idx_serverless_regional_function"`
+	Region                 string `json:"region" gorm:"size:63;uniqueIndex:idx_serverless_regional_function"`
+	FunctionURL            string `json:"function_url" gorm:"size:255"`
+	FunctionName           string `json:"function_name" gorm:"size:127"`
+}
+
+// ServerlessResourceOverride overrides resources required by the manifest of a plugin on deploying,
+// fields of 0 are not overridden
+type ServerlessResourceOverride struct {
+	Model
+	PluginID           string `json:"plugin_id" gorm:"size:255;unique"`
+	MemoryMB           int64  `json:"memory_mb"`
+	TimeoutSeconds     int    `json:"timeout_seconds"`
+	EphemeralStorageMB int64  `json:"ephemeral_storage_mb"`
+	Concurrency        int    `json:"concurrency"`
 }
 
 type PluginDeclaration struct {
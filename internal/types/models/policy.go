@@ -0,0 +1,17 @@
+package models
+
+// TenantPluginPolicy restricts which plugins a tenant is allowed to install,
+// the policy with tenant id PLUGIN_POLICY_GLOBAL_TENANT applies to all tenants
+type TenantPluginPolicy struct {
+	Model
+	TenantID       string   `json:"tenant_id" gorm:"column:tenant_id;size:64;uniqueIndex;not null"`
+	AllowedPlugins []string `json:"allowed_plugins" gorm:"column:allowed_plugins;serializer:json;type:text"`
+	BlockedPlugins []string `json:"blocked_plugins" gorm:"column:blocked_plugins;serializer:json;type:text"`
+	AllowedAuthors []string `json:"allowed_authors" gorm:"column:allowed_authors;serializer:json;type:text"`
+	BlockedAuthors []string `json:"blocked_authors" gorm:"column:blocked_authors;serializer:json;type:text"`
+	VerifiedOnly   bool     `json:"verified_only" gorm:"column:verified_only"`
+}
+
+const (
+	PLUGIN_POLICY_GLOBAL_TENANT = "global"
+)
@@ -0,0 +1,11 @@
+package models
+
+import "github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/scanner"
+
+// PluginScanReport stores the result of the content scanning of a plugin package
+type PluginScanReport struct {
+	Model
+	PluginUniqueIdentifier string            `json:"plugin_unique_identifier" gorm:"size:255;unique"`
+	Blocking               bool              `json:"blocking"`
+	Findings               []scanner.Finding `json:"findings" gorm:"serializer:json;type:text"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// PluginSLO is the objectives of a plugin set by operators, it overrides the default objectives
+type PluginSLO struct {
+	Model
+	PluginID    string  `json:"plugin_id" gorm:"column:plugin_id;size:255;uniqueIndex;not null"`
+	SuccessRate float64 `json:"success_rate" gorm:"column:success_rate"`
+	// LatencyP99 is in milliseconds, 0 disables the latency objective
+	LatencyP99 int64 `json:"latency_p99" gorm:"column:latency_p99"`
+	// Window is in minutes
+	Window int `json:"window" gorm:"column:window"`
+}
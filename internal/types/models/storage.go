@@ -6,3 +6,21 @@ type TenantStorage struct {
 	PluginID string `gorm:"column:plugin_id;type:varchar(255);not null;index"`
 	Size     int64  `gorm:"column:size;type:bigint;not null"`
 }
+
+// TenantStorageQuota overrides the default quota of disk usage of installed plugins for a tenant,
+// a quota of 0 means unlimited
+type TenantStorageQuota struct {
+	Model
+	TenantID string `json:"tenant_id" gorm:"column:tenant_id;size:64;uniqueIndex;not null"`
+	Quota    int64  `json:"quota" gorm:"column:quota;type:bigint;not null"`
+}
+
+// PersistenceValue is a value of the persistent storage of plugins, it's kept in the database once
+// the persistence storage type is `database`
+type PersistenceValue struct {
+	Model
+	TenantID string `gorm:"column:tenant_id;size:64;not null;uniqueIndex:idx_persistence_value_key"`
+	PluginID string `gorm:"column:plugin_id;size:255;not null;uniqueIndex:idx_persistence_value_key"`
+	Key      string `gorm:"column:key;size:256;not null;uniqueIndex:idx_persistence_value_key"`
+	Value    []byte `gorm:"column:value;not null"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
 
 type InstallTaskStatus string
 
@@ -18,6 +22,14 @@ type InstallTaskPluginStatus struct {
 	PluginID               string                                 `json:"plugin_id"`
 	Status                 InstallTaskStatus                      `json:"status"`
 	Message                string                                 `json:"message"`
+	// Stages are the deploying stages the plugin went through, only reported by serverless installations
+	Stages []InstallTaskPluginStage `json:"stages,omitempty"`
+}
+
+type InstallTaskPluginStage struct {
+	Stage     string    `json:"stage"`
+	Message   string    `json:"message"`
+	StartedAt time.Time `json:"started_at"`
 }
 
 type InstallTask struct {
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ServerlessInvocationStat aggregates invocations of a serverless plugin within an hour,
+// the cost is estimated from the configured prices when the stat is reported
+type ServerlessInvocationStat struct {
+	Model
+	PluginUniqueIdentifier string    `json:"plugin_unique_identifier" gorm:"size:255;uniqueIndex:idx_serverless_invocation_stat_hour"`
+	PluginID               string    `json:"plugin_id" gorm:"size:255;index"`
+	Hour                   time.Time `json:"hour" gorm:"uniqueIndex:idx_serverless_invocation_stat_hour;index"`
+	FunctionName           string    `json:"function_name" gorm:"size:127"`
+	Invocations            int64     `json:"invocations"`
+	Errors                 int64     `json:"errors"`
+	// DurationMs is the total duration of the invocations in milliseconds
+	DurationMs    int64   `json:"duration_ms"`
+	MemoryMB      int64   `json:"memory_mb"`
+	GBSeconds     float64 `json:"gb_seconds"`
+	EstimatedCost float64 `json:"estimated_cost"`
+}
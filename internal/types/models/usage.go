@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// PluginUsageHourly is invocations of a plugin by a tenant within an hour, rows are accumulated by all nodes
+// and rolled up into PluginUsageDaily, they're removed once they're older than the retention
+type PluginUsageHourly struct {
+	Model
+	TenantID    string         `json:"tenant_id" gorm:"size:64;uniqueIndex:idx_plugin_usage_hourly"`
+	PluginID    string         `json:"plugin_id" gorm:"size:255;uniqueIndex:idx_plugin_usage_hourly"`
+	Hour        time.Time      `json:"hour" gorm:"uniqueIndex:idx_plugin_usage_hourly;index"`
+	Invocations int64          `json:"invocations"`
+	Errors      int64          `json:"errors"`
+	Tokens      int64          `json:"tokens"`
+	Latencies   LatencyBuckets `json:"latencies" gorm:"embedded;embeddedPrefix:latency_"`
+}
+
+// LatencyBuckets is the histogram of latencies, see histogram.Bounds, each bucket is a column so that
+// histograms of all nodes are accumulated by adding columns up
+type LatencyBuckets struct {
+	Le50    int64 `json:"le_50" gorm:"column:le_50"`
+	Le100   int64 `json:"le_100" gorm:"column:le_100"`
+	Le250   int64 `json:"le_250" gorm:"column:le_250"`
+	Le500   int64 `json:"le_500" gorm:"column:le_500"`
+	Le1000  int64 `json:"le_1000" gorm:"column:le_1000"`
+	Le2500  int64 `json:"le_2500" gorm:"column:le_2500"`
+	Le5000  int64 `json:"le_5000" gorm:"column:le_5000"`
+	Le10000 int64 `json:"le_10000" gorm:"column:le_10000"`
+	Le30000 int64 `json:"le_30000" gorm:"column:le_30000"`
+	Le60000 int64 `json:"le_60000" gorm:"column:le_60000"`
+	Over    int64 `json:"over" gorm:"column:over"`
+}
+
+// Buckets returns pointers to the buckets in the order of histogram.Bounds
+func (l *LatencyBuckets) Buckets() []*int64 {
+	return []*int64{
+		&l.Le50, &l.Le100, &l.Le250, &l.Le500, &l.Le1000, &l.Le2500,
+		&l.Le5000, &l.Le10000, &l.Le30000, &l.Le60000, &l.Over,
+	}
+}
+
+// PluginUsageDaily is invocations of a plugin by a tenant within a day in UTC
+type PluginUsageDaily struct {
+	Model
+	TenantID    string    `json:"tenant_id" gorm:"size:64;uniqueIndex:idx_plugin_usage_daily"`
+	PluginID    string    `json:"plugin_id" gorm:"size:255;uniqueIndex:idx_plugin_usage_daily;index"`
+	Day         time.Time `json:"day" gorm:"uniqueIndex:idx_plugin_usage_daily;index"`
+	Invocations int64     `json:"invocations"`
+	Errors      int64     `json:"errors"`
+	Tokens      int64     `json:"tokens"`
+	// latencies are in milliseconds
+	LatencyP50 int64 `json:"latency_p50"`
+	LatencyP95 int64 `json:"latency_p95"`
+	LatencyP99 int64 `json:"latency_p99"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// Webhook receives plugin lifecycle events of a tenant,
+// webhooks with tenant id WEBHOOK_GLOBAL_TENANT receive events of all tenants and events not bound to a tenant
+type Webhook struct {
+	Model
+	TenantID string `json:"tenant_id" gorm:"column:tenant_id;size:64;index;not null"`
+	URL      string `json:"url" gorm:"column:url;size:1024;not null"`
+	Secret   string `json:"-" gorm:"column:secret;size:512;not null"`
+	// version of the key encrypting the secret, 0 means it's stored in plain text
+	SecretKeyVersion int      `json:"-" gorm:"column:secret_key_version;default:0;index"`
+	Events           []string `json:"events" gorm:"column:events;serializer:json;type:text"` // empty means all events
+	Enabled          bool     `json:"enabled" gorm:"column:enabled;default:true"`
+}
+
+const (
+	WEBHOOK_GLOBAL_TENANT = "global"
+)
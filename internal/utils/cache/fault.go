@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/fault"
+	"github.com/redis/go-redis/v9"
+)
+
+// faultHook injects faults of fault.POINT_REDIS into commands before they're sent
+type faultHook struct{}
+
+// EnableFaultInjection injects faults into commands, pipelines fail as a whole
+func EnableFaultInjection() error {
+	if client == nil {
+		return ErrDBNotInit
+	}
+
+	client.AddHook(faultHook{})
+	return nil
+}
+
+func (faultHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (faultHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if f := fault.Inject(fault.POINT_REDIS, cmd.Name()); f != nil {
+			cmd.SetErr(f)
+			return f
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (faultHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if f := fault.Inject(fault.POINT_REDIS, cmd.Name()); f != nil {
+				for _, cmd := range cmds {
+					cmd.SetErr(f)
+				}
+				return f
+			}
+		}
+		return next(ctx, cmds)
+	}
+}
@@ -121,11 +121,21 @@ func (c *memCache) set(key string, declaration *plugin_entities.PluginDeclaratio
 	c.itemSize++
 }
 
-func CombinedGetPluginDeclaration(
+func (c *memCache) delete(key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, exists := c.items[key]; exists {
+		c.itemSize--
+		delete(c.items, key)
+	}
+}
+
+func declarationCacheKey(
 	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
 	runtimeType plugin_entities.PluginRuntimeType,
-) (*plugin_entities.PluginDeclaration, error) {
-	cacheKey := strings.Join(
+) string {
+	return strings.Join(
 		[]string{
 			"declaration_cache",
 			string(runtimeType),
@@ -133,6 +143,24 @@ func CombinedGetPluginDeclaration(
 		},
 		":",
 	)
+}
+
+// DeletePluginDeclarationCache removes the cached declaration, it's required once a declaration is modified,
+// NOTE: only the memory cache of current node is removed, other nodes keep it until it expires
+func DeletePluginDeclarationCache(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	runtimeType plugin_entities.PluginRuntimeType,
+) error {
+	cacheKey := declarationCacheKey(pluginUniqueIdentifier, runtimeType)
+	pluginCache.delete(cacheKey)
+	return cache.AutoDelete[plugin_entities.PluginDeclaration](cacheKey)
+}
+
+func CombinedGetPluginDeclaration(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	runtimeType plugin_entities.PluginRuntimeType,
+) (*plugin_entities.PluginDeclaration, error) {
+	cacheKey := declarationCacheKey(pluginUniqueIdentifier, runtimeType)
 
 	// Try memory cache first
 	if declaration := pluginCache.get(cacheKey); declaration != nil {
@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/redis/go-redis/v9"
 )
@@ -51,14 +50,56 @@ func Close() error {
 	return client.Close()
 }
 
+// Ping checks the connection to redis
+func Ping(ctx context.Context) error {
+	if client == nil {
+		return ErrDBNotInit
+	}
+
+	return client.Ping(ctx).Err()
+}
+
+// PoolStats returns stats of the connection pool, nil if the client is not initialized
+func PoolStats() *redis.PoolStats {
+	if client == nil {
+		return nil
+	}
+
+	return client.PoolStats()
+}
+
 func getCmdable(context ...redis.Cmdable) redis.Cmdable {
 	if len(context) > 0 {
+		if traced, ok := context[0].(contextCmdable); ok {
+			return traced.Cmdable
+		}
 		return context[0]
 	}
 
 	return client
 }
 
+// contextCmdable carries the context commands are issued with, see WithContext
+type contextCmdable struct {
+	redis.Cmdable
+	ctx context.Context
+}
+
+// WithContext returns a cmdable passed as the last argument of the helpers so that commands are issued
+// within ctx, which makes them traced under the span of ctx
+func WithContext(ctx context.Context) redis.Cmdable {
+	return contextCmdable{Cmdable: client, ctx: ctx}
+}
+
+func contextOf(cmdables ...redis.Cmdable) context.Context {
+	if len(cmdables) > 0 {
+		if traced, ok := cmdables[0].(contextCmdable); ok {
+			return traced.ctx
+		}
+	}
+	return ctx
+}
+
 func serialKey(keys ...string) string {
 	return strings.Join(append(
 		[]string{"plugin_daemon"},
@@ -85,7 +126,7 @@ func store(key string, value any, time time.Duration, context ...redis.Cmdable)
 		}
 	}
 
-	return getCmdable(context...).Set(ctx, key, value, time).Err()
+	return getCmdable(context...).Set(contextOf(context...), key, value, time).Err()
 }
 
 // Get the value with key
@@ -98,7 +139,7 @@ func get[T any](key string, context ...redis.Cmdable) (*T, error) {
 		return nil, ErrDBNotInit
 	}
 
-	val, err := getCmdable(context...).Get(ctx, key).Bytes()
+	val, err := getCmdable(context...).Get(contextOf(context...), key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrNotFound
@@ -120,7 +161,7 @@ func GetString(key string, context ...redis.Cmdable) (string, error) {
 		return "", ErrDBNotInit
 	}
 
-	v, err := getCmdable(context...).Get(ctx, serialKey(key)).Result()
+	v, err := getCmdable(context...).Get(contextOf(context...), serialKey(key)).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return "", ErrNotFound
@@ -140,7 +181,7 @@ func del(key string, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	_, err := getCmdable(context...).Del(ctx, key).Result()
+	_, err := getCmdable(context...).Del(contextOf(context...), key).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return ErrNotFound
@@ -158,7 +199,7 @@ func Exist(key string, context ...redis.Cmdable) (int64, error) {
 		return 0, ErrDBNotInit
 	}
 
-	return getCmdable(context...).Exists(ctx, serialKey(key)).Result()
+	return getCmdable(context...).Exists(contextOf(context...), serialKey(key)).Result()
 }
 
 // Increase the key
@@ -167,7 +208,7 @@ func Increase(key string, context ...redis.Cmdable) (int64, error) {
 		return 0, ErrDBNotInit
 	}
 
-	num, err := getCmdable(context...).Incr(ctx, serialKey(key)).Result()
+	num, err := getCmdable(context...).Incr(contextOf(context...), serialKey(key)).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return 0, ErrNotFound
@@ -184,7 +225,7 @@ func Decrease(key string, context ...redis.Cmdable) (int64, error) {
 		return 0, ErrDBNotInit
 	}
 
-	return getCmdable(context...).Decr(ctx, serialKey(key)).Result()
+	return getCmdable(context...).Decr(contextOf(context...), serialKey(key)).Result()
 }
 
 // SetExpire set the expire time for the key
@@ -193,7 +234,7 @@ func SetExpire(key string, time time.Duration, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	return getCmdable(context...).Expire(ctx, serialKey(key), time).Err()
+	return getCmdable(context...).Expire(contextOf(context...), serialKey(key), time).Err()
 }
 
 // SetMapField set the map field with key
@@ -202,7 +243,7 @@ func SetMapField(key string, v map[string]any, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	return getCmdable(context...).HMSet(ctx, serialKey(key), v).Err()
+	return getCmdable(context...).HMSet(contextOf(context...), serialKey(key), v).Err()
 }
 
 // SetMapOneField set the map field with key
@@ -215,7 +256,7 @@ func SetMapOneField(key string, field string, value any, context ...redis.Cmdabl
 		value = parser.MarshalJson(value)
 	}
 
-	return getCmdable(context...).HSet(ctx, serialKey(key), field, value).Err()
+	return getCmdable(context...).HSet(contextOf(context...), serialKey(key), field, value).Err()
 }
 
 // GetMapField get the map field with key
@@ -224,7 +265,7 @@ func GetMapField[T any](key string, field string, context ...redis.Cmdable) (*T,
 		return nil, ErrDBNotInit
 	}
 
-	val, err := getCmdable(context...).HGet(ctx, serialKey(key), field).Result()
+	val, err := getCmdable(context...).HGet(contextOf(context...), serialKey(key), field).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrNotFound
@@ -242,7 +283,7 @@ func GetMapFieldString(key string, field string, context ...redis.Cmdable) (stri
 		return "", ErrDBNotInit
 	}
 
-	val, err := getCmdable(context...).HGet(ctx, serialKey(key), field).Result()
+	val, err := getCmdable(context...).HGet(contextOf(context...), serialKey(key), field).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return "", ErrNotFound
@@ -259,7 +300,7 @@ func DelMapField(key string, field string, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	return getCmdable(context...).HDel(ctx, serialKey(key), field).Err()
+	return getCmdable(context...).HDel(contextOf(context...), serialKey(key), field).Err()
 }
 
 // GetMap get the map with key
@@ -268,7 +309,7 @@ func GetMap[V any](key string, context ...redis.Cmdable) (map[string]V, error) {
 		return nil, ErrDBNotInit
 	}
 
-	val, err := getCmdable(context...).HGetAll(ctx, serialKey(key)).Result()
+	val, err := getCmdable(context...).HGetAll(contextOf(context...), serialKey(key)).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrNotFound
@@ -289,6 +330,52 @@ func GetMap[V any](key string, context ...redis.Cmdable) (map[string]V, error) {
 	return result, nil
 }
 
+// GetMaps gets maps of the keys in one round trip, keys not found are left out
+func GetMaps[V any](keys []string, context ...redis.Cmdable) (map[string]map[string]V, error) {
+	if client == nil {
+		return nil, ErrDBNotInit
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(keys))
+	if _, err := getCmdable(context...).Pipelined(contextOf(context...), func(p redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = p.HGetAll(contextOf(context...), serialKey(key))
+		}
+		return nil
+	}); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]V)
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		if err != nil || len(val) == 0 {
+			continue
+		}
+
+		m := make(map[string]V)
+		for k, v := range val {
+			value, err := parser.UnmarshalJson[V](v)
+			if err != nil {
+				continue
+			}
+			m[k] = value
+		}
+		result[keys[i]] = m
+	}
+
+	return result, nil
+}
+
+// IncreaseMapField increases the map field with key by n
+func IncreaseMapField(key string, field string, n int64, context ...redis.Cmdable) (int64, error) {
+	if client == nil {
+		return 0, ErrDBNotInit
+	}
+
+	return getCmdable(context...).HIncrBy(contextOf(context...), serialKey(key), field, n).Result()
+}
+
 // ScanKeys scan the keys with match pattern
 func ScanKeys(match string, context ...redis.Cmdable) ([]string, error) {
 	if client == nil {
@@ -300,7 +387,7 @@ func ScanKeys(match string, context ...redis.Cmdable) ([]string, error) {
 	if err := ScanKeysAsync(match, func(keys []string) error {
 		result = append(result, keys...)
 		return nil
-	}); err != nil {
+	}, context...); err != nil {
 		return nil, err
 	}
 
@@ -316,7 +403,7 @@ func ScanKeysAsync(match string, fn func([]string) error, context ...redis.Cmdab
 	cursor := uint64(0)
 
 	for {
-		keys, newCursor, err := getCmdable(context...).Scan(ctx, cursor, match, 32).Result()
+		keys, newCursor, err := getCmdable(context...).Scan(contextOf(context...), cursor, match, 32).Result()
 		if err != nil {
 			return err
 		}
@@ -349,7 +436,7 @@ func ScanMap[V any](key string, match string, context ...redis.Cmdable) (map[str
 		}
 
 		return nil
-	})
+	}, context...)
 
 	return result, nil
 }
@@ -364,7 +451,7 @@ func ScanMapAsync[V any](key string, match string, fn func(map[string]V) error,
 
 	for {
 		kvs, newCursor, err := getCmdable(context...).
-			HScan(ctx, serialKey(key), cursor, match, 32).
+			HScan(contextOf(context...), serialKey(key), cursor, match, 32).
 			Result()
 
 		if err != nil {
@@ -407,7 +494,7 @@ func SetNX[T any](key string, value T, expire time.Duration, context ...redis.Cm
 		return false, err
 	}
 
-	return getCmdable(context...).SetNX(ctx, serialKey(key), bytes, expire).Result()
+	return getCmdable(context...).SetNX(contextOf(context...), serialKey(key), bytes, expire).Result()
 }
 
 var (
@@ -427,7 +514,7 @@ func Lock(key string, expire time.Duration, tryLockTimeout time.Duration, contex
 	defer ticker.Stop()
 
 	for range ticker.C {
-		if _, err := getCmdable(context...).SetNX(ctx, serialKey(key), "1", expire).Result(); err == nil {
+		if _, err := getCmdable(context...).SetNX(contextOf(context...), serialKey(key), "1", expire).Result(); err == nil {
 			return nil
 		}
 
@@ -445,7 +532,7 @@ func Unlock(key string, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	return getCmdable(context...).Del(ctx, serialKey(key)).Err()
+	return getCmdable(context...).Del(contextOf(context...), serialKey(key)).Err()
 }
 
 func Expire(key string, time time.Duration, context ...redis.Cmdable) (bool, error) {
@@ -453,7 +540,119 @@ func Expire(key string, time time.Duration, context ...redis.Cmdable) (bool, err
 		return false, ErrDBNotInit
 	}
 
-	return getCmdable(context...).Expire(ctx, serialKey(key), time).Result()
+	return getCmdable(context...).Expire(contextOf(context...), serialKey(key), time).Result()
+}
+
+var (
+	expireIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	delIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// ExpireIfEqual refreshes the expire time of key set by SetNX only if it still holds value,
+// it's used to renew a lease without extending the one taken over by others
+func ExpireIfEqual[T any](key string, value T, expire time.Duration, context ...redis.Cmdable) (bool, error) {
+	if client == nil {
+		return false, ErrDBNotInit
+	}
+
+	bytes, err := parser.MarshalCBOR(value)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := expireIfEqualScript.Run(
+		contextOf(context...), getCmdable(context...), []string{serialKey(key)}, bytes, expire.Milliseconds(),
+	).Int()
+	return result == 1, err
+}
+
+// DelIfEqual deletes key set by SetNX only if it still holds value
+func DelIfEqual[T any](key string, value T, context ...redis.Cmdable) (bool, error) {
+	if client == nil {
+		return false, ErrDBNotInit
+	}
+
+	bytes, err := parser.MarshalCBOR(value)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := delIfEqualScript.Run(contextOf(context...), getCmdable(context...), []string{serialKey(key)}, bytes).Int()
+	return result == 1, err
+}
+
+// XAdd appends values to the stream, the stream is trimmed to approximately maxLen entries
+func XAdd(stream string, values map[string]any, maxLen int64, context ...redis.Cmdable) (string, error) {
+	if client == nil {
+		return "", ErrDBNotInit
+	}
+
+	return getCmdable(context...).XAdd(contextOf(context...), &redis.XAddArgs{
+		Stream: serialKey(stream),
+		MaxLen: maxLen,
+		Approx: true,
+		Values: values,
+	}).Result()
+}
+
+// XGroupCreate creates the consumer group of the stream along with the stream, an existing group is kept
+func XGroupCreate(stream string, group string, context ...redis.Cmdable) error {
+	if client == nil {
+		return ErrDBNotInit
+	}
+
+	err := getCmdable(context...).XGroupCreateMkStream(contextOf(context...), serialKey(stream), group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// XReadGroup reads entries of the stream for consumer of the group, id ">" reads entries never delivered
+// while "0" reads entries delivered to the consumer but not acknowledged yet
+func XReadGroup(
+	stream string, group string, consumer string, id string, count int64, block time.Duration,
+	context ...redis.Cmdable,
+) ([]redis.XMessage, error) {
+	if client == nil {
+		return nil, ErrDBNotInit
+	}
+
+	streams, err := getCmdable(context...).XReadGroup(contextOf(context...), &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{serialKey(stream), id},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	messages := []redis.XMessage{}
+	for _, s := range streams {
+		messages = append(messages, s.Messages...)
+	}
+	return messages, nil
+}
+
+// XAck acknowledges entries of the stream so that they're no longer delivered to the group
+func XAck(stream string, group string, ids []string, context ...redis.Cmdable) error {
+	if client == nil {
+		return ErrDBNotInit
+	}
+
+	return getCmdable(context...).XAck(contextOf(context...), serialKey(stream), group, ids...).Err()
 }
 
 func Transaction(fn func(redis.Pipeliner) error) error {
@@ -481,47 +680,5 @@ func Publish(channel string, message any, context ...redis.Cmdable) error {
 		message = parser.MarshalJson(message)
 	}
 
-	return getCmdable(context...).Publish(ctx, channel, message).Err()
-}
-
-func Subscribe[T any](channel string) (<-chan T, func()) {
-	pubsub := client.Subscribe(ctx, channel)
-	ch := make(chan T)
-	connectionEstablished := make(chan bool)
-
-	go func() {
-		defer close(ch)
-		defer close(connectionEstablished)
-
-		alive := true
-		for alive {
-			iface, err := pubsub.Receive(context.Background())
-			if err != nil {
-				log.Error("failed to receive message from redis: %s, will retry in 1 second", err.Error())
-				time.Sleep(1 * time.Second)
-				continue
-			}
-			switch data := iface.(type) {
-			case *redis.Subscription:
-				connectionEstablished <- true
-			case *redis.Message:
-				v, err := parser.UnmarshalJson[T](data.Payload)
-				if err != nil {
-					continue
-				}
-
-				ch <- v
-			case *redis.Pong:
-			default:
-				alive = false
-			}
-		}
-	}()
-
-	// wait for the connection to be established
-	<-connectionEstablished
-
-	return ch, func() {
-		pubsub.Close()
-	}
+	return getCmdable(context...).Publish(contextOf(context...), channel, message).Err()
 }
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/redis/go-redis/v9"
+)
+
+// subscription keeps receiving messages of a channel, the channel returned by Subscribe outlives the
+// connection, once the connection dies the subscription is reported by DeadSubscriptions until it's
+// restarted by RestartDeadSubscriptions
+type subscription struct {
+	channel string
+	// deliver decodes and sends the payload to the subscriber
+	deliver func(payload string)
+	// done is called once the subscription is cancelled
+	done func()
+
+	lock      sync.Mutex
+	pubsub    *redis.PubSub
+	cancelled bool
+	dead      atomic.Bool
+	// set while it's restarted by RestartDeadSubscriptions
+	restarting atomic.Bool
+}
+
+var subscriptions sync.Map // map[*subscription]bool
+
+func Subscribe[T any](channel string) (<-chan T, func()) {
+	ch := make(chan T)
+	s := &subscription{
+		channel: channel,
+		deliver: func(payload string) {
+			v, err := parser.UnmarshalJson[T](payload)
+			if err != nil {
+				return
+			}
+			ch <- v
+		},
+		done: func() { close(ch) },
+	}
+
+	s.start()
+	subscriptions.Store(s, true)
+
+	return ch, func() {
+		s.lock.Lock()
+		s.cancelled = true
+		pubsub := s.pubsub
+		s.lock.Unlock()
+
+		subscriptions.Delete(s)
+		pubsub.Close()
+		// no receiver is left to close the channel once the subscription is dead
+		if s.dead.Load() {
+			s.done()
+		}
+	}
+}
+
+// start subscribes to the channel and blocks until the subscription is established
+func (s *subscription) start() {
+	pubsub := client.Subscribe(ctx, s.channel)
+	s.lock.Lock()
+	s.pubsub = pubsub
+	s.lock.Unlock()
+	s.dead.Store(false)
+
+	established := make(chan bool)
+	once := sync.Once{}
+	notify := func() { once.Do(func() { close(established) }) }
+
+	go func() {
+		defer notify()
+
+		for {
+			iface, err := pubsub.Receive(ctx)
+			if s.isCancelled() {
+				s.done()
+				return
+			}
+			if errors.Is(err, redis.ErrClosed) {
+				s.markDead()
+				return
+			}
+			if err != nil {
+				log.Error("failed to receive message from redis: %s, will retry in 1 second", err.Error())
+				time.Sleep(1 * time.Second)
+				continue
+			}
+
+			switch data := iface.(type) {
+			case *redis.Subscription:
+				notify()
+			case *redis.Message:
+				s.deliver(data.Payload)
+			case *redis.Pong:
+			default:
+				s.markDead()
+				return
+			}
+		}
+	}()
+
+	<-established
+}
+
+func (s *subscription) isCancelled() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cancelled
+}
+
+func (s *subscription) markDead() {
+	log.Error("subscription of redis channel %s is dead", s.channel)
+	s.dead.Store(true)
+}
+
+// DeadSubscriptions returns channels whose subscriptions stopped receiving messages
+func DeadSubscriptions() []string {
+	channels := []string{}
+	subscriptions.Range(func(key, value any) bool {
+		if s := key.(*subscription); s.dead.Load() {
+			channels = append(channels, s.channel)
+		}
+		return true
+	})
+	return channels
+}
+
+// RestartDeadSubscriptions subscribes to channels of dead subscriptions again, subscribers keep reading from
+// the channels they got. Subscribing blocks until redis responds, so it's done in the background and the
+// caller is never held up by redis
+func RestartDeadSubscriptions() {
+	dead := []*subscription{}
+	subscriptions.Range(func(key, value any) bool {
+		if s := key.(*subscription); s.dead.Load() && !s.isCancelled() {
+			dead = append(dead, s)
+		}
+		return true
+	})
+
+	for _, s := range dead {
+		if !s.restarting.CompareAndSwap(false, true) {
+			continue
+		}
+		log.Info("restarting subscription of redis channel %s", s.channel)
+		go func() {
+			defer s.restarting.Store(false)
+			s.start()
+		}()
+	}
+}
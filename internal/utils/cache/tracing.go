@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"net"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHook traces commands issued within a traced context, keys and arguments are left out of spans
+// as they may carry tenant data
+type tracingHook struct{}
+
+// EnableTracing traces commands issued through WithContext under the span of the context
+func EnableTracing() error {
+	if client == nil {
+		return ErrDBNotInit
+	}
+
+	client.AddHook(tracingHook{})
+	return nil
+}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span, ok := tracing.StartChild(ctx, "redis."+cmd.Name(), trace.SpanKindClient,
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", cmd.Name()),
+		)
+		if !ok {
+			return next(ctx, cmd)
+		}
+		defer span.End()
+
+		err := next(ctx, cmd)
+		if err != nil && err != redis.Nil {
+			tracing.Fail(span, err)
+		}
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span, ok := tracing.StartChild(ctx, "redis.pipeline", trace.SpanKindClient,
+			attribute.String("db.system", "redis"),
+			attribute.Int("db.redis.commands", len(cmds)),
+		)
+		if !ok {
+			return next(ctx, cmds)
+		}
+		defer span.End()
+
+		err := next(ctx, cmds)
+		if err != nil && err != redis.Nil {
+			tracing.Fail(span, err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,123 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Ciphertexts are stored as envelopes prefixed by the identifier of the cipher producing them, like
+// `aes-gcm$<base64>`, so that the cipher can be replaced, for example by a FIPS approved one, without
+// migrating stored ciphertexts at once. Envelopes without prefix were produced by aes-gcm.
+const (
+	CIPHER_AES_GCM           = "aes-gcm"
+	CIPHER_CHACHA20_POLY1305 = "chacha20-poly1305"
+
+	envelopeSeparator = "$"
+)
+
+// Cipher is an authenticated cipher, the nonce is prepended to the ciphertext
+type Cipher struct {
+	ID string
+	// FIPSApproved ciphers are the only ones available once FIPS mode is required
+	FIPSApproved bool
+	Seal         func(key []byte, plaintext []byte) ([]byte, error)
+	Open         func(key []byte, ciphertext []byte) ([]byte, error)
+}
+
+var ciphers = map[string]Cipher{
+	CIPHER_AES_GCM: {
+		ID:           CIPHER_AES_GCM,
+		FIPSApproved: true,
+		Seal:         AESEncrypt,
+		Open:         AESDecrypt,
+	},
+	CIPHER_CHACHA20_POLY1305: {
+		ID:   CIPHER_CHACHA20_POLY1305,
+		Seal: chacha20Poly1305Seal,
+		Open: chacha20Poly1305Open,
+	},
+}
+
+// GetCipher returns the cipher of the id, ciphers not approved by FIPS are refused once FIPS mode is required
+func GetCipher(id string) (Cipher, error) {
+	cipher, ok := ciphers[id]
+	if !ok {
+		return Cipher{}, fmt.Errorf("unknown cipher %q", id)
+	}
+
+	if fipsRequired && !cipher.FIPSApproved {
+		return Cipher{}, fmt.Errorf("cipher %q is not approved by FIPS 140", id)
+	}
+
+	return cipher, nil
+}
+
+// SealEnvelope encrypts plaintext by the cipher of the id and wraps the ciphertext into an envelope
+func SealEnvelope(id string, key []byte, plaintext []byte) (string, error) {
+	cipher, err := GetCipher(id)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := cipher.Seal(key, plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return id + envelopeSeparator + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// OpenEnvelope decrypts the envelope by the cipher it was sealed with
+func OpenEnvelope(key []byte, envelope string) ([]byte, error) {
+	id, encoded, ok := strings.Cut(envelope, envelopeSeparator)
+	if !ok {
+		// base64 never contains the separator, it's an envelope sealed before ciphers were recorded
+		id, encoded = CIPHER_AES_GCM, envelope
+	}
+
+	cipher, err := GetCipher(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.Open(key, ciphertext)
+}
+
+func chacha20Poly1305Seal(key []byte, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func chacha20Poly1305Open(key []byte, ciphertext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
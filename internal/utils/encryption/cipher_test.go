@@ -0,0 +1,49 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	for _, id := range []string{CIPHER_AES_GCM, CIPHER_CHACHA20_POLY1305} {
+		envelope, err := SealEnvelope(id, key, []byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plaintext, err := OpenEnvelope(key, envelope)
+		if err != nil || string(plaintext) != "secret" {
+			t.Fatalf("unexpected plaintext %q of %s: %v", plaintext, id, err)
+		}
+	}
+
+	// envelopes sealed before ciphers were recorded are aes-gcm
+	ciphertext, err := AESEncrypt(key, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := OpenEnvelope(key, base64.StdEncoding.EncodeToString(ciphertext))
+	if err != nil || string(plaintext) != "secret" {
+		t.Fatalf("unexpected plaintext %q of a legacy envelope: %v", plaintext, err)
+	}
+
+	if _, err := OpenEnvelope(key, "rot13$c2VjcmV0"); err == nil {
+		t.Error("unknown ciphers should be rejected")
+	}
+}
+
+func TestFIPSRequiredRefusesUnapprovedCiphers(t *testing.T) {
+	fipsRequired = true
+	defer func() { fipsRequired = false }()
+
+	if _, err := GetCipher(CIPHER_CHACHA20_POLY1305); err == nil {
+		t.Error("chacha20-poly1305 should be refused in fips mode")
+	}
+	if _, err := GetCipher(CIPHER_AES_GCM); err != nil {
+		t.Errorf("aes-gcm should be available in fips mode: %s", err)
+	}
+}
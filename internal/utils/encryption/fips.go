@@ -0,0 +1,36 @@
+package encryption
+
+import "fmt"
+
+// The encryption helpers run on the crypto provider the daemon is built with, the standard library by
+// default. Regulated deployments use a FIPS 140 validated provider instead, either
+//
+//   - the native module of Go 1.24+, by building with GOFIPS140=v1.0.0 or running with GODEBUG=fips140=on
+//   - BoringCrypto, by building with GOEXPERIMENT=boringcrypto
+//
+// and set FIPS_MODE_ENABLED so that the daemon refuses to start if the provider is not active, ciphers not
+// approved by FIPS are refused as well.
+
+var fipsRequired = false
+
+// FIPSProvider returns the name of the FIPS 140 provider the daemon is built with and whether it's active
+func FIPSProvider() (string, bool) {
+	return fipsProvider()
+}
+
+// RequireFIPS fails unless a FIPS 140 provider is active, ciphers not approved by FIPS are refused afterwards
+func RequireFIPS() error {
+	name, active := fipsProvider()
+	if name == "" {
+		return fmt.Errorf(
+			"the daemon is built without a FIPS 140 provider, build it by Go 1.24+ or with GOEXPERIMENT=boringcrypto",
+		)
+	}
+
+	if !active {
+		return fmt.Errorf("FIPS 140 provider %s is not active, enable it by GODEBUG=fips140=on", name)
+	}
+
+	fipsRequired = true
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build boringcrypto
+
+package encryption
+
+import (
+	"crypto/boring"
+	"crypto/cipher"
+)
+
+func fipsProvider() (string, bool) {
+	return "boringcrypto", boring.Enabled()
+}
+
+func aesGCMSeal(block cipher.Block, data []byte) ([]byte, error) {
+	return aesGCMSealWithNonce(block, data)
+}
+
+func aesGCMOpen(block cipher.Block, data []byte) ([]byte, error) {
+	return aesGCMOpenWithNonce(block, data)
+}
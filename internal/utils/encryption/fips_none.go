@@ -0,0 +1,17 @@
+//go:build !go1.24 && !boringcrypto
+
+package encryption
+
+import "crypto/cipher"
+
+func fipsProvider() (string, bool) {
+	return "", false
+}
+
+func aesGCMSeal(block cipher.Block, data []byte) ([]byte, error) {
+	return aesGCMSealWithNonce(block, data)
+}
+
+func aesGCMOpen(block cipher.Block, data []byte) ([]byte, error) {
+	return aesGCMOpenWithNonce(block, data)
+}
@@ -29,6 +29,20 @@ func AESEncrypt(aesKey []byte, data []byte) ([]byte, error) {
 		return nil, err
 	}
 
+	return aesGCMSeal(block, data)
+}
+
+func AESDecrypt(aesKey []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return aesGCMOpen(block, data)
+}
+
+// aesGCMSealWithNonce seals data by GCM with a nonce generated here, the nonce is prepended to the ciphertext
+func aesGCMSealWithNonce(block cipher.Block, data []byte) ([]byte, error) {
 	aesGCM, err := cipher.NewGCM(block)
 	if err != nil {
 		return nil, err
@@ -43,12 +57,7 @@ func AESEncrypt(aesKey []byte, data []byte) ([]byte, error) {
 	return cipherText, nil
 }
 
-func AESDecrypt(aesKey []byte, data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(aesKey)
-	if err != nil {
-		return nil, err
-	}
-
+func aesGCMOpenWithNonce(block cipher.Block, data []byte) ([]byte, error) {
 	aesGCM, err := cipher.NewGCM(block)
 	if err != nil {
 		return nil, err
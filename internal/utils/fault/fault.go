@@ -0,0 +1,195 @@
+// Package fault injects faults into code paths of the daemon to check it recovers from them, like latency
+// of db and redis calls, errors of them, events of plugins dropped and plugins crashing.
+//
+// Faults are injected by rules of each node, a rule applies to calls of its point whose target contains
+// the target of the rule, like tables of db calls, commands of redis calls or plugin ids, and fires with
+// its probability until it has fired as many times as it's limited to.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Point string
+
+const (
+	// POINT_DB is statements run by gorm, targets are tables
+	POINT_DB Point = "db"
+	// POINT_REDIS is commands sent to redis, targets are names of commands
+	POINT_REDIS Point = "redis"
+	// POINT_PLUGIN_EVENT is events received from local plugins, targets are plugin unique identifiers
+	POINT_PLUGIN_EVENT Point = "plugin_event"
+	// POINT_PLUGIN_WRITE is requests written to local plugins, targets are plugin unique identifiers
+	POINT_PLUGIN_WRITE Point = "plugin_write"
+)
+
+type Kind string
+
+const (
+	KIND_LATENCY Kind = "latency"
+	KIND_ERROR   Kind = "error"
+	KIND_DROP    Kind = "drop"
+	KIND_CRASH   Kind = "crash"
+)
+
+// kinds are faults each point supports besides latency
+var kinds = map[Point]Kind{
+	POINT_DB:           KIND_ERROR,
+	POINT_REDIS:        KIND_ERROR,
+	POINT_PLUGIN_EVENT: KIND_DROP,
+	POINT_PLUGIN_WRITE: KIND_CRASH,
+}
+
+type Rule struct {
+	Point  Point  `json:"point" validate:"required"`
+	Kind   Kind   `json:"kind" validate:"required"`
+	Target string `json:"target"` // calls whose targets contain it, all calls if empty
+	// Probability is the chance the rule fires on each call, 1 if it's 0
+	Probability float64 `json:"probability" validate:"min=0,max=1"`
+	Latency     int     `json:"latency"` // in milliseconds, for latency rules
+	Message     string  `json:"message"` // message of errors
+	// Times limits how many times the rule fires, unlimited if it's 0
+	Times int `json:"times" validate:"min=0"`
+}
+
+// State is a rule along with how many times it has fired
+type State struct {
+	Rule
+	Fired int64 `json:"fired"`
+}
+
+type rule struct {
+	Rule
+	fired atomic.Int64
+}
+
+// Fault is the error of a fault injected
+type Fault struct {
+	Point   Point
+	Kind    Kind
+	Message string
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("fault injected into %s: %s", f.Point, f.Message)
+}
+
+var (
+	ErrDisabled = errors.New("fault injection is disabled")
+
+	enabled atomic.Bool
+	// rules are replaced as a whole, calls load them without locking
+	rules atomic.Pointer[[]*rule]
+)
+
+// Enable allows rules to be set, it's called on startup if fault injection is enabled by the config
+func Enable() {
+	enabled.Store(true)
+}
+
+func Enabled() bool {
+	return enabled.Load()
+}
+
+func (r *Rule) validate() error {
+	kind, ok := kinds[r.Point]
+	if !ok {
+		return fmt.Errorf("unknown point %q", r.Point)
+	}
+	if r.Kind != KIND_LATENCY && r.Kind != kind {
+		return fmt.Errorf("%s faults can't be injected into %s, only latency and %s", r.Kind, r.Point, kind)
+	}
+	if r.Kind == KIND_LATENCY && r.Latency <= 0 {
+		return errors.New("latency rules need a latency")
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return errors.New("probability should be between 0 and 1")
+	}
+	if r.Times < 0 {
+		return errors.New("times should not be negative")
+	}
+	return nil
+}
+
+// SetRules replaces the rules, counts of rules fired are reset
+func SetRules(newRules []Rule) error {
+	if !Enabled() {
+		return ErrDisabled
+	}
+
+	replaced := make([]*rule, 0, len(newRules))
+	for i, r := range newRules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("invalid rule %d: %w", i, err)
+		}
+		replaced = append(replaced, &rule{Rule: r})
+	}
+
+	rules.Store(&replaced)
+	return nil
+}
+
+// Rules returns the rules along with how many times they have fired
+func Rules() []State {
+	states := []State{}
+	current := rules.Load()
+	if current == nil {
+		return states
+	}
+	for _, r := range *current {
+		states = append(states, State{Rule: r.Rule, Fired: r.fired.Load()})
+	}
+	return states
+}
+
+// Clear removes all rules
+func Clear() {
+	rules.Store(nil)
+}
+
+// fire returns true if the rule fires on this call, the count is taken only if it fires
+func (r *rule) fire() bool {
+	if r.Probability > 0 && r.Probability < 1 && rand.Float64() >= r.Probability {
+		return false
+	}
+	for {
+		fired := r.fired.Load()
+		if r.Times > 0 && fired >= int64(r.Times) {
+			return false
+		}
+		if r.fired.CompareAndSwap(fired, fired+1) {
+			return true
+		}
+	}
+}
+
+// Inject applies rules of the point matching the target, latency is waited for right away, the fault of the
+// first other rule firing is returned, callers fail the call by it the way the point fails
+func Inject(point Point, target string) *Fault {
+	current := rules.Load()
+	if current == nil {
+		return nil
+	}
+
+	for _, r := range *current {
+		if r.Point != point || !strings.Contains(target, r.Target) || !r.fire() {
+			continue
+		}
+		if r.Kind == KIND_LATENCY {
+			time.Sleep(time.Duration(r.Latency) * time.Millisecond)
+			continue
+		}
+
+		message := r.Message
+		if message == "" {
+			message = fmt.Sprintf("%s of %s", r.Kind, target)
+		}
+		return &Fault{Point: point, Kind: r.Kind, Message: message}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package fault
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetRules(t *testing.T) {
+	enabled.Store(false)
+	defer Clear()
+
+	if err := SetRules([]Rule{{Point: POINT_DB, Kind: KIND_ERROR}}); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("rules should be refused while disabled, got %v", err)
+	}
+
+	Enable()
+	for _, invalid := range []Rule{
+		{Point: "unknown", Kind: KIND_ERROR},
+		{Point: POINT_DB, Kind: KIND_CRASH},
+		{Point: POINT_PLUGIN_EVENT, Kind: KIND_ERROR},
+		{Point: POINT_REDIS, Kind: KIND_LATENCY},
+		{Point: POINT_REDIS, Kind: KIND_ERROR, Probability: 2},
+	} {
+		if err := SetRules([]Rule{invalid}); err == nil {
+			t.Fatalf("rule %+v should be invalid", invalid)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	Enable()
+	defer Clear()
+
+	if f := Inject(POINT_DB, "plugins"); f != nil {
+		t.Fatal("nothing should be injected without rules")
+	}
+
+	err := SetRules([]Rule{
+		{Point: POINT_REDIS, Kind: KIND_LATENCY, Latency: 50},
+		{Point: POINT_DB, Kind: KIND_ERROR, Target: "plugin_installations", Message: "connection reset", Times: 2},
+		{Point: POINT_PLUGIN_EVENT, Kind: KIND_DROP, Target: "langgenius/openai"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	if f := Inject(POINT_REDIS, "get"); f != nil || time.Since(started) < 50*time.Millisecond {
+		t.Fatalf("latency should be injected without failing the call, got %v after %s", f, time.Since(started))
+	}
+
+	if f := Inject(POINT_DB, "plugins"); f != nil {
+		t.Fatalf("statements of other tables should not fail, got %v", f)
+	}
+	for i := 0; i < 2; i++ {
+		f := Inject(POINT_DB, "plugin_installations")
+		if f == nil || f.Kind != KIND_ERROR || f.Error() != "fault injected into db: connection reset" {
+			t.Fatalf("statement %d should fail, got %v", i, f)
+		}
+	}
+	if f := Inject(POINT_DB, "plugin_installations"); f != nil {
+		t.Fatal("rules should stop firing once they fired as many times as they're limited to")
+	}
+
+	if f := Inject(POINT_PLUGIN_EVENT, "langgenius/openai:0.0.1@checksum"); f == nil || f.Kind != KIND_DROP {
+		t.Fatalf("events of the plugin should be dropped, got %v", f)
+	}
+
+	states := Rules()
+	if len(states) != 3 || states[0].Fired != 1 || states[1].Fired != 2 || states[2].Fired != 1 {
+		t.Fatalf("unexpected states %+v", states)
+	}
+
+	Clear()
+	if f := Inject(POINT_PLUGIN_EVENT, "langgenius/openai:0.0.1@checksum"); f != nil || len(Rules()) != 0 {
+		t.Fatal("rules should be cleared")
+	}
+}
+
+func TestProbability(t *testing.T) {
+	Enable()
+	defer Clear()
+
+	if err := SetRules([]Rule{{Point: POINT_REDIS, Kind: KIND_ERROR, Probability: 0.5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if Inject(POINT_REDIS, "set") != nil {
+			failed++
+		}
+	}
+	if failed < 350 || failed > 650 {
+		t.Fatalf("about half of the calls should fail, got %d of 1000", failed)
+	}
+}
@@ -0,0 +1,57 @@
+// Package histogram counts latencies into fixed buckets, histograms of different nodes and periods are merged
+// by adding them up, and percentiles are estimated from them without keeping every latency.
+package histogram
+
+import "time"
+
+// Bounds are upper bounds of latency buckets in milliseconds, latencies above the last bound fall into an
+// extra bucket, percentiles are estimated by the upper bounds of the buckets they fall into
+var Bounds = []int64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// New returns an empty histogram, it has one more bucket than Bounds
+func New() []int64 {
+	return make([]int64, len(Bounds)+1)
+}
+
+// Observe counts the latency into the bucket it falls into
+func Observe(histogram []int64, latency time.Duration) {
+	ms := latency.Milliseconds()
+	i := 0
+	for i < len(Bounds) && ms > Bounds[i] {
+		i++
+	}
+	histogram[i]++
+}
+
+// Merge adds other to histogram, histograms with fewer buckets are tolerated
+func Merge(histogram []int64, other []int64) {
+	for i := 0; i < len(histogram) && i < len(other); i++ {
+		histogram[i] += other[i]
+	}
+}
+
+// Percentile returns the upper bound of the bucket the p-th latency falls into, latencies above the last
+// bound are reported as the last bound
+func Percentile(histogram []int64, p float64) int64 {
+	total := int64(0)
+	for _, n := range histogram {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := int64(float64(total)*p + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	cumulative := int64(0)
+	for i, n := range histogram {
+		cumulative += n
+		if cumulative >= rank && i < len(Bounds) {
+			return Bounds[i]
+		}
+	}
+	return Bounds[len(Bounds)-1]
+}
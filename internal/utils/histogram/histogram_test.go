@@ -0,0 +1,37 @@
+package histogram
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	histogram := New()
+	for i := 0; i < 98; i++ {
+		Observe(histogram, 80*time.Millisecond)
+	}
+	Observe(histogram, 3*time.Second)
+	Observe(histogram, 2*time.Minute)
+
+	if p := Percentile(histogram, 0.5); p != 100 {
+		t.Errorf("expected p50 100, got %d", p)
+	}
+	if p := Percentile(histogram, 0.99); p != 5000 {
+		t.Errorf("expected p99 5000, got %d", p)
+	}
+	if p := Percentile(histogram, 1); p != Bounds[len(Bounds)-1] {
+		t.Errorf("latencies above the last bound should be reported as it, got %d", p)
+	}
+	if p := Percentile(New(), 0.5); p != 0 {
+		t.Errorf("expected 0 of an empty histogram, got %d", p)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	histogram := New()
+	Merge(histogram, []int64{1, 2})
+	Merge(histogram, New())
+	if histogram[0] != 1 || histogram[1] != 2 {
+		t.Fatalf("unexpected histogram %v", histogram)
+	}
+}
@@ -109,6 +109,9 @@ func RequestAndParseStream[T any](client *http.Client, url string, method string
 		"module":   "http_requests",
 		"function": "RequestAndParseStream",
 	}, func() {
+		defer ch.Close()
+		defer routine.ReportPanic(ch.WriteError)
+
 		scanner := bufio.NewScanner(resp.Body)
 		defer resp.Body.Close()
 
@@ -145,8 +148,6 @@ func RequestAndParseStream[T any](client *http.Client, url string, method string
 
 			ch.Write(t)
 		}
-
-		ch.Close()
 	})
 
 	return ch, nil
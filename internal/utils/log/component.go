@@ -0,0 +1,93 @@
+package log
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Components are subsystems whose verbosity is adjusted at runtime apart from the others, logs written
+// by a component carry its name, and the level overridden for the component applies to them instead of
+// the global one, so debug logs of a single subsystem are captured without flooding the rest.
+
+const (
+	FIELD_COMPONENT = "component"
+
+	COMPONENT_STDIO                = "stdio"
+	COMPONENT_CLUSTER              = "cluster"
+	COMPONENT_INSTALL              = "install"
+	COMPONENT_BACKWARDS_INVOCATION = "backwards_invocation"
+	COMPONENT_WATCHDOG             = "watchdog"
+)
+
+var Components = []string{
+	COMPONENT_STDIO,
+	COMPONENT_CLUSTER,
+	COMPONENT_INSTALL,
+	COMPONENT_BACKWARDS_INVOCATION,
+	COMPONENT_WATCHDOG,
+}
+
+type levelOverrides struct {
+	// global is -1 if the level set by SetLevel applies
+	global     int
+	components map[string]int
+}
+
+var overrides atomic.Pointer[levelOverrides]
+
+// Component returns the logger of the component
+func Component(name string) *Logger {
+	return With(FIELD_COMPONENT, name)
+}
+
+// ValidLevel returns true if the level is one of debug, info, warn and error
+func ValidLevel(level string) bool {
+	order, ok := levelOrder[strings.ToUpper(level)]
+	return ok && order < levelOrder["PANIC"]
+}
+
+// ValidComponent returns true if the component is one of Components
+func ValidComponent(component string) bool {
+	return slices.Contains(Components, component)
+}
+
+// SetOverrides overrides levels at runtime, global replaces the level set by SetLevel unless it's empty,
+// levels of components apply to their logs regardless of the global one, invalid levels are ignored
+func SetOverrides(global string, components map[string]string) {
+	o := &levelOverrides{
+		global:     -1,
+		components: map[string]int{},
+	}
+	if ValidLevel(global) {
+		o.global = levelOrder[strings.ToUpper(global)]
+	}
+	for component, level := range components {
+		if ValidLevel(level) {
+			o.components[component] = levelOrder[strings.ToUpper(level)]
+		}
+	}
+	overrides.Store(o)
+}
+
+// minLevel returns the order of the least level written along with the fields
+func minLevel(fields []field) int {
+	o := overrides.Load()
+	if o == nil {
+		return int(min_level.Load())
+	}
+
+	for _, f := range fields {
+		if f.key != FIELD_COMPONENT {
+			continue
+		}
+		if order, ok := o.components[f.value]; ok {
+			return order
+		}
+	}
+
+	if o.global >= 0 {
+		return o.global
+	}
+	return int(min_level.Load())
+}
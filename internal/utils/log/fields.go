@@ -0,0 +1,121 @@
+package log
+
+import (
+	"context"
+	"strings"
+)
+
+// Fields correlate logs written while serving a request, the request id is generated once a request
+// reaches the daemon and is carried along with the sessions it creates, so logs of plugins invoked by
+// the request are told apart by it.
+
+const (
+	FIELD_REQUEST_ID = "request_id"
+	FIELD_TENANT_ID  = "tenant_id"
+	FIELD_PLUGIN_ID  = "plugin_id"
+	FIELD_SESSION_ID = "session_id"
+	// FIELD_CALLER attributes requests to the server key or the api token authenticating them
+	FIELD_CALLER = "caller"
+	// FIELD_USER_ID is the user the tenant token of the request is issued for
+	FIELD_USER_ID = "user_id"
+	// FIELD_CLIENT_IP is taken from the headers set by trusted proxies, or the address of the connection
+	FIELD_CLIENT_IP = "client_ip"
+)
+
+type field struct {
+	key   string
+	value string
+}
+
+// Logger writes logs along with its fields, a nil Logger writes logs without any field
+type Logger struct {
+	fields []field
+}
+
+// With returns a logger writing the field along with fields of the package level logger
+func With(key, value string) *Logger {
+	return (*Logger)(nil).With(key, value)
+}
+
+// With returns a copy of the logger with the field set, empty values are left out
+func (l *Logger) With(key, value string) *Logger {
+	fields := []field{}
+	if l != nil {
+		for _, f := range l.fields {
+			if f.key != key {
+				fields = append(fields, f)
+			}
+		}
+	}
+	if value != "" {
+		fields = append(fields, field{key: key, value: value})
+	}
+	return &Logger{fields: fields}
+}
+
+// Field returns the value of the field, empty if it's not set
+func (l *Logger) Field(key string) string {
+	if l == nil {
+		return ""
+	}
+	for _, f := range l.fields {
+		if f.key == key {
+			return f.value
+		}
+	}
+	return ""
+}
+
+func (l *Logger) getFields() []field {
+	if l == nil {
+		return nil
+	}
+	return l.fields
+}
+
+func (l *Logger) Debug(format string, v ...interface{}) {
+	writeLog("DEBUG", l.getFields(), format, v...)
+}
+
+func (l *Logger) Info(format string, v ...interface{}) {
+	writeLog("INFO", l.getFields(), format, v...)
+}
+
+func (l *Logger) Warn(format string, v ...interface{}) {
+	writeLog("WARN", l.getFields(), format, v...)
+}
+
+func (l *Logger) Error(format string, v ...interface{}) {
+	writeLog("ERROR", l.getFields(), format, v...)
+}
+
+// Log writes the log at a level named by others, like plugins, unknown levels are written as info
+func (l *Logger) Log(level string, format string, v ...interface{}) {
+	level = strings.ToUpper(level)
+	switch level {
+	case "WARNING":
+		level = "WARN"
+	case "CRITICAL", "FATAL":
+		level = "ERROR"
+	}
+	if _, ok := levelOrder[level]; !ok || level == "PANIC" {
+		level = "INFO"
+	}
+	writeLog(level, l.getFields(), format, v...)
+}
+
+type loggerKey struct{}
+
+// NewContext returns ctx carrying the logger
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, nil if there is none, which is still usable
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return nil
+	}
+	logger, _ := ctx.Value(loggerKey{}).(*Logger)
+	return logger
+}
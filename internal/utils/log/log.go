@@ -6,13 +6,28 @@ package log
 */
 
 import (
+	"context"
 	"fmt"
+	"io"
 	go_log "log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 var show_log bool = true
 var logger = go_log.New(os.Stdout, "", go_log.Ldate|go_log.Ltime|go_log.Lshortfile)
+var jsonLogger = newJsonLogger(os.Stdout)
+
+var output_format = FORMAT_TEXT
+
+// order of the least level written, DEBUG by default, it's set again once settings are reloaded
+var min_level atomic.Int64
 
 const (
 	LOG_LEVEL_DEBUG_COLOR = "\033[34m"
@@ -22,46 +37,139 @@ const (
 	LOG_LEVEL_COLOR_END   = "\033[0m"
 )
 
-func writeLog(level string, format string, stdout bool, v ...interface{}) {
-	//write log
-	format = fmt.Sprintf("["+level+"]"+format, v...)
-
-	if show_log && stdout {
-		if level == "DEBUG" {
-			logger.Output(3, LOG_LEVEL_DEBUG_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "INFO" {
-			logger.Output(3, LOG_LEVEL_INFO_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "WARN" {
-			logger.Output(3, LOG_LEVEL_WARN_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "ERROR" {
-			logger.Output(3, LOG_LEVEL_ERROR_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "PANIC" {
-			logger.Output(3, LOG_LEVEL_ERROR_COLOR+format+LOG_LEVEL_COLOR_END)
-			panic(format)
+const (
+	// FORMAT_TEXT writes colored lines for humans, fields are appended as key=value
+	FORMAT_TEXT = "text"
+	// FORMAT_JSON writes one json object per line for log aggregation
+	FORMAT_JSON = "json"
+)
+
+var levelOrder = map[string]int{
+	"DEBUG": 0,
+	"INFO":  1,
+	"WARN":  2,
+	"ERROR": 3,
+	"PANIC": 4,
+}
+
+var levelColor = map[string]string{
+	"DEBUG": LOG_LEVEL_DEBUG_COLOR,
+	"INFO":  LOG_LEVEL_INFO_COLOR,
+	"WARN":  LOG_LEVEL_WARN_COLOR,
+	"ERROR": LOG_LEVEL_ERROR_COLOR,
+	"PANIC": LOG_LEVEL_ERROR_COLOR,
+}
+
+const (
+	slogLevelPanic = slog.LevelError + 4
+)
+
+var slogLevel = map[string]slog.Level{
+	"DEBUG": slog.LevelDebug,
+	"INFO":  slog.LevelInfo,
+	"WARN":  slog.LevelWarn,
+	"ERROR": slog.LevelError,
+	"PANIC": slogLevelPanic,
+}
+
+func newJsonLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelDebug,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			switch attr.Key {
+			case slog.LevelKey:
+				if level, ok := attr.Value.Any().(slog.Level); ok && level == slogLevelPanic {
+					return slog.String(slog.LevelKey, "PANIC")
+				}
+			case slog.SourceKey:
+				// keep the caller short like the text format does
+				if source, ok := attr.Value.Any().(*slog.Source); ok {
+					return slog.String("caller", filepath.Base(source.File)+":"+strconv.Itoa(source.Line))
+				}
+			}
+			return attr
+		},
+	}))
+}
+
+// writeLog is called by exported functions only, the caller of which is recorded
+func writeLog(level string, fields []field, format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+
+	if !show_log {
+		return
+	}
+
+	if levelOrder[level] >= minLevel(fields) {
+		keepRecent(level, fields, message)
+
+		if output_format == FORMAT_JSON {
+			// skip runtime.Callers, writeLog and the exported function
+			var pcs [1]uintptr
+			runtime.Callers(3, pcs[:])
+
+			record := slog.NewRecord(time.Now(), slogLevel[level], message, pcs[0])
+			for _, f := range fields {
+				record.AddAttrs(slog.String(f.key, f.value))
+			}
+			jsonLogger.Handler().Handle(context.Background(), record)
+		} else {
+			line := "[" + level + "]" + message
+			for _, f := range fields {
+				line += " " + f.key + "=" + f.value
+			}
+			logger.Output(3, levelColor[level]+line+LOG_LEVEL_COLOR_END)
 		}
 	}
+
+	if level == "PANIC" {
+		panic(message)
+	}
+}
+
+// setOutput redirects logs of both formats to w
+func setOutput(w io.Writer) {
+	logger.SetOutput(w)
+	jsonLogger = newJsonLogger(w)
 }
 
 func SetShowLog(show bool) {
 	show_log = show
 }
 
+// SetFormat switches the output between FORMAT_TEXT and FORMAT_JSON
+func SetFormat(format string) {
+	if format == FORMAT_JSON {
+		output_format = FORMAT_JSON
+	} else {
+		output_format = FORMAT_TEXT
+	}
+}
+
+// SetLevel drops logs below the level, one of debug, info, warn and error
+func SetLevel(level string) {
+	if order, ok := levelOrder[strings.ToUpper(level)]; ok {
+		min_level.Store(int64(order))
+	}
+}
+
 func Debug(format string, v ...interface{}) {
-	writeLog("DEBUG", format, true, v...)
+	writeLog("DEBUG", nil, format, v...)
 }
 
 func Info(format string, v ...interface{}) {
-	writeLog("INFO", format, true, v...)
+	writeLog("INFO", nil, format, v...)
 }
 
 func Warn(format string, v ...interface{}) {
-	writeLog("WARN", format, true, v...)
+	writeLog("WARN", nil, format, v...)
 }
 
 func Error(format string, v ...interface{}) {
-	writeLog("ERROR", format, true, v...)
+	writeLog("ERROR", nil, format, v...)
 }
 
 func Panic(format string, v ...interface{}) {
-	writeLog("PANIC", format, true, v...)
+	writeLog("PANIC", nil, format, v...)
 }
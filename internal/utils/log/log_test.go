@@ -0,0 +1,123 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func captureLogs(t *testing.T, format string) *bytes.Buffer {
+	buffer := &bytes.Buffer{}
+	setOutput(buffer)
+	SetFormat(format)
+	t.Cleanup(func() {
+		setOutput(os.Stdout)
+		SetFormat(FORMAT_TEXT)
+		SetLevel("debug")
+	})
+	return buffer
+}
+
+func TestJsonLogCarriesFields(t *testing.T) {
+	buffer := captureLogs(t, FORMAT_JSON)
+
+	ctx := NewContext(context.Background(), With(FIELD_REQUEST_ID, "request"))
+	FromContext(ctx).With(FIELD_SESSION_ID, "session").Info("invoked %s", "tool")
+
+	entry := map[string]any{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("log should be json: %s", err.Error())
+	}
+
+	if entry["msg"] != "invoked tool" || entry["level"] != "INFO" {
+		t.Fatalf("unexpected entry: %v", entry)
+	}
+	if entry[FIELD_REQUEST_ID] != "request" || entry[FIELD_SESSION_ID] != "session" {
+		t.Fatalf("fields should be written: %v", entry)
+	}
+	if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "log_test.go:") {
+		t.Fatalf("caller should be the test, got %v", entry["caller"])
+	}
+}
+
+func TestTextLogAppendsFields(t *testing.T) {
+	buffer := captureLogs(t, FORMAT_TEXT)
+
+	With(FIELD_PLUGIN_ID, "langgenius/openai").With(FIELD_TENANT_ID, "").Warn("restarted")
+
+	line := buffer.String()
+	if !strings.Contains(line, "[WARN]restarted plugin_id=langgenius/openai") {
+		t.Fatalf("unexpected line: %s", line)
+	}
+	if strings.Contains(line, FIELD_TENANT_ID) {
+		t.Fatalf("empty fields should be left out: %s", line)
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	buffer := captureLogs(t, FORMAT_TEXT)
+	SetLevel("warn")
+
+	Info("dropped")
+	(*Logger)(nil).Log("warning", "kept")
+
+	if strings.Contains(buffer.String(), "dropped") {
+		t.Fatal("logs below the level should be dropped")
+	}
+	if !strings.Contains(buffer.String(), "[WARN]kept") {
+		t.Fatalf("plugin levels should be mapped, got: %s", buffer.String())
+	}
+}
+
+func TestComponentLevelOverrides(t *testing.T) {
+	buffer := captureLogs(t, FORMAT_TEXT)
+	SetLevel("info")
+	t.Cleanup(func() { overrides.Store(nil) })
+
+	SetOverrides("warn", map[string]string{COMPONENT_STDIO: "debug", COMPONENT_CLUSTER: "invalid"})
+
+	Component(COMPONENT_STDIO).Debug("stdio debug")
+	Component(COMPONENT_CLUSTER).Info("cluster info")
+	Info("global info")
+	Warn("global warn")
+
+	if !strings.Contains(buffer.String(), "stdio debug") {
+		t.Fatal("debug logs of the overridden component should be written")
+	}
+	if strings.Contains(buffer.String(), "cluster info") || strings.Contains(buffer.String(), "global info") {
+		t.Fatalf("the global override should apply to the others, got: %s", buffer.String())
+	}
+	if !strings.Contains(buffer.String(), "global warn") {
+		t.Fatal("logs above the global override should be written")
+	}
+
+	buffer.Reset()
+	SetOverrides("", nil)
+	Info("falls back")
+	if !strings.Contains(buffer.String(), "falls back") {
+		t.Fatal("the configured level should apply once overrides are removed")
+	}
+}
+
+func TestRecentLogs(t *testing.T) {
+	captureLogs(t, FORMAT_TEXT)
+
+	for i := 0; i < RECENT_LOGS_SIZE+10; i++ {
+		With(FIELD_PLUGIN_ID, "neko").Info("line %d", i)
+	}
+
+	lines := Recent()
+	if len(lines) != RECENT_LOGS_SIZE {
+		t.Fatalf("expected %d recent logs, got %d", RECENT_LOGS_SIZE, len(lines))
+	}
+	if !strings.HasSuffix(lines[0], "[INFO]line 10 plugin_id=neko") {
+		t.Fatalf("the oldest logs should be dropped first, got %s", lines[0])
+	}
+	if !strings.Contains(lines[len(lines)-1], fmt.Sprintf("line %d", RECENT_LOGS_SIZE+9)) {
+		t.Fatalf("the latest log should be the last one, got %s", lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// Recent logs are kept in memory apart from the output, support bundles carry them so bug reports come with
+// logs of the moment even if the output isn't collected anywhere.
+
+const (
+	RECENT_LOGS_SIZE = 1000
+)
+
+var recent = struct {
+	lock  sync.Mutex
+	lines []string
+	// next is the index the next line is written to once lines are full
+	next int
+}{}
+
+func keepRecent(level string, fields []field, message string) {
+	line := time.Now().UTC().Format(time.RFC3339Nano) + " [" + level + "]" + message
+	for _, f := range fields {
+		line += " " + f.key + "=" + f.value
+	}
+
+	recent.lock.Lock()
+	defer recent.lock.Unlock()
+	if len(recent.lines) < RECENT_LOGS_SIZE {
+		recent.lines = append(recent.lines, line)
+		return
+	}
+	recent.lines[recent.next] = line
+	recent.next = (recent.next + 1) % RECENT_LOGS_SIZE
+}
+
+// Recent returns the latest logs written, the oldest first
+func Recent() []string {
+	recent.lock.Lock()
+	defer recent.lock.Unlock()
+	lines := make([]string, 0, len(recent.lines))
+	lines = append(lines, recent.lines[recent.next:]...)
+	return append(lines, recent.lines[:recent.next]...)
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/events"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Metrics of all subsystems are collected in one registry and exposed on /metrics in the prometheus format,
+// subsystems update the metrics defined here directly, while metrics read from other packages on scrape,
+// like sessions or pool stats, are registered by the server through Register.
+//
+// Labels of plugins are plugin ids instead of unique identifiers, which keeps the cardinality bounded
+// by the plugins installed rather than growing with each version.
+
+const (
+	NAMESPACE = "plugin_daemon"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of http requests by route",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	PluginRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "plugin_restarts_total",
+		Help:      "Restarts of plugin processes",
+	}, []string{"plugin_id", "runtime_type"})
+
+	StdioParseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "plugin_stdio_parse_errors_total",
+		Help:      "Lines written to stdout by plugins which are not valid events",
+	}, []string{"plugin_id"})
+
+	BackwardsInvocationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "backwards_invocation_duration_seconds",
+		Help:      "Latency of invocations from plugins back to dify by type",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"type"})
+
+	InstallTasks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "install_tasks_total",
+		Help:      "Plugins installed by install tasks by the final status",
+	}, []string{"status"})
+
+	InstallTasksRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "install_tasks_running",
+		Help:      "Plugins being installed by install tasks",
+	})
+
+	ToolOutputSchemaViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "tool_output_schema_violations_total",
+		Help:      "Invocations of tools whose output violates the output schema declared by them",
+	}, []string{"plugin_id", "tool", "mode"})
+
+	RoutinePanics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "routine_panics_total",
+		Help:      "Panics recovered from tasks submitted to routine pools by the module and function of the task",
+	}, []string{"module", "function"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		PluginRestarts,
+		StdioParseErrors,
+		BackwardsInvocationDuration,
+		InstallTasks,
+		InstallTasksRunning,
+		ToolOutputSchemaViolations,
+		RoutinePanics,
+	)
+}
+
+// Register adds collectors backed by other subsystems, registering a collector twice is an error
+func Register(collectors ...prometheus.Collector) error {
+	for _, collector := range collectors {
+		if err := registry.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the metrics in the prometheus format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Write writes a snapshot of the metrics in the prometheus text format
+func Write(w io.Writer) error {
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordInstallTaskTransition tracks a plugin of an install task moving from a status to another
+func RecordInstallTaskTransition(from models.InstallTaskStatus, to models.InstallTaskStatus) {
+	if from == to {
+		return
+	}
+
+	if from == models.InstallTaskStatusRunning {
+		InstallTasksRunning.Dec()
+	}
+
+	switch to {
+	case models.InstallTaskStatusRunning:
+		InstallTasksRunning.Inc()
+	case models.InstallTaskStatusSuccess, models.InstallTaskStatusFailed:
+		InstallTasks.WithLabelValues(string(to)).Inc()
+	}
+}
+
+// SubscribeLifecycleEvents updates metrics on lifecycle events published by other subsystems
+func SubscribeLifecycleEvents() {
+	events.Subscribe(func(event events.PluginCrashed) {
+		PluginRestarts.WithLabelValues(event.PluginUniqueIdentifier.PluginID(), string(event.RuntimeType)).Inc()
+	})
+}
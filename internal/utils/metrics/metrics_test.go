@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordInstallTaskTransition(t *testing.T) {
+	RecordInstallTaskTransition(models.InstallTaskStatusPending, models.InstallTaskStatusRunning)
+	RecordInstallTaskTransition(models.InstallTaskStatusPending, models.InstallTaskStatusRunning)
+	if running := testutil.ToFloat64(InstallTasksRunning); running != 2 {
+		t.Fatalf("expected 2 running tasks, got %v", running)
+	}
+
+	// updates within the same status are not transitions
+	RecordInstallTaskTransition(models.InstallTaskStatusRunning, models.InstallTaskStatusRunning)
+	RecordInstallTaskTransition(models.InstallTaskStatusRunning, models.InstallTaskStatusSuccess)
+	RecordInstallTaskTransition(models.InstallTaskStatusRunning, models.InstallTaskStatusFailed)
+
+	if running := testutil.ToFloat64(InstallTasksRunning); running != 0 {
+		t.Fatalf("expected no running tasks, got %v", running)
+	}
+	if succeeded := testutil.ToFloat64(InstallTasks.WithLabelValues("success")); succeeded != 1 {
+		t.Fatalf("expected 1 succeeded task, got %v", succeeded)
+	}
+	if failed := testutil.ToFloat64(InstallTasks.WithLabelValues("failed")); failed != 1 {
+		t.Fatalf("expected 1 failed task, got %v", failed)
+	}
+}
@@ -0,0 +1,190 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("address is not allowed")
+
+// ranges inside the network of the daemon, loopback, private, link-local (including cloud metadata
+// endpoints like 169.254.169.254 and fd00:ec2::254), carrier-grade nat, multicast and reserved ones
+var forbiddenRanges = parseCIDRs(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+	"192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15", "224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "64:ff9b::/96", "fc00::/7", "fe80::/10", "ff00::/8",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// SSRFGuard keeps urls fetched on behalf of tenants and plugins from reaching the network of the daemon
+type SSRFGuard struct {
+	allowed []*net.IPNet
+	schemes []string
+}
+
+// NewSSRFGuard creates a guard allowing the given cidrs, or single ips, out of the forbidden ranges,
+// only urls of the given schemes can be fetched
+func NewSSRFGuard(allowed []string, schemes []string) (*SSRFGuard, error) {
+	guard := &SSRFGuard{schemes: schemes}
+	for _, cidr := range allowed {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowed address: %s", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed cidr: %s", cidr)
+		}
+		guard.allowed = append(guard.allowed, n)
+	}
+
+	return guard, nil
+}
+
+// CheckIP returns ErrForbiddenAddress if ip is inside a forbidden range and not allowed
+func (g *SSRFGuard) CheckIP(ip net.IP) error {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	for _, n := range g.allowed {
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+
+	for _, n := range forbiddenRanges {
+		if n.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip.String())
+		}
+	}
+
+	return nil
+}
+
+// CheckURL checks the scheme of rawURL and the host of it if it's an ip, hostnames are checked once
+// they are resolved on connecting
+func (g *SSRFGuard) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(g.schemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("scheme %s is not allowed", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return errors.New("host is empty")
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return g.CheckIP(ip)
+	}
+
+	return nil
+}
+
+// CheckHost resolves host and checks all of its addresses, only use it if the connection is made
+// by someone else like a proxy, connections made by Dialer are checked against the address they
+// are actually made to, which leaves no room for dns rebinding
+func (g *SSRFGuard) CheckHost(ctx context.Context, host string) error {
+	_, err := g.ResolveHost(ctx, host)
+	return err
+}
+
+// ResolveHost resolves host and checks all of its addresses like CheckHost, it returns the first address
+// so that the connection made by someone else could be pinned to it
+func (g *SSRFGuard) ResolveHost(ctx context.Context, host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, g.CheckIP(ip)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address found for %s", host)
+	}
+
+	for _, addr := range addrs {
+		if err := g.CheckIP(addr.IP); err != nil {
+			return nil, err
+		}
+	}
+
+	return addrs[0].IP, nil
+}
+
+// Dialer returns a dialer refusing connections to forbidden addresses, the check runs on the resolved
+// address right before connecting
+func (g *SSRFGuard) Dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+
+			return g.CheckIP(ip)
+		},
+	}
+}
+
+// Client returns an http client whose connections and redirects are checked
+func (g *SSRFGuard) Client(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would make the connection instead
+	transport.Proxy = nil
+	transport.DialContext = g.Dialer(30 * time.Second).DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return g.CheckURL(req.URL.String())
+		},
+	}
+}
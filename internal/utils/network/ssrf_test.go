@@ -0,0 +1,99 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSRFGuardCheckIP(t *testing.T) {
+	guard, err := NewSSRFGuard([]string{"10.1.0.0/16", "192.168.1.10"}, []string{"http", "https"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, allowed := range map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"169.254.169.254":  false,
+		"fd00:ec2::254":    false,
+		"10.0.0.1":         false,
+		"172.16.5.4":       false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+		"::ffff:8.8.8.8":   true,
+		"10.1.2.3":         true,
+		"192.168.1.10":     true,
+		"192.168.1.11":     false,
+	} {
+		err := guard.CheckIP(net.ParseIP(ip))
+		if allowed && err != nil {
+			t.Errorf("expected %s to be allowed, got %s", ip, err)
+		}
+		if !allowed && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("expected %s to be forbidden", ip)
+		}
+	}
+}
+
+func TestSSRFGuardCheckURL(t *testing.T) {
+	guard, err := NewSSRFGuard(nil, []string{"https"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := guard.CheckURL("https://example.com/hook"); err != nil {
+		t.Errorf("expected url to be allowed, got %s", err)
+	}
+	if err := guard.CheckURL("http://example.com/hook"); err == nil {
+		t.Error("expected scheme http to be denied")
+	}
+	if err := guard.CheckURL("file:///etc/passwd"); err == nil {
+		t.Error("expected scheme file to be denied")
+	}
+	if err := guard.CheckURL("https://169.254.169.254/latest/meta-data"); err == nil {
+		t.Error("expected metadata address to be denied")
+	}
+	if err := guard.CheckURL("https://[::1]:8080/"); err == nil {
+		t.Error("expected loopback address to be denied")
+	}
+
+	if _, err := NewSSRFGuard([]string{"not-an-ip"}, nil); err == nil {
+		t.Error("expected invalid allowed address to be rejected")
+	}
+}
+
+func TestSSRFGuardClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// hostnames resolving to loopback are refused on connecting
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	guard, _ := NewSSRFGuard(nil, []string{"http"})
+	if _, err := guard.Client(time.Second).Get("http://localhost:" + port); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("expected connection to localhost to be refused, got %v", err)
+	}
+
+	if err := guard.CheckHost(context.Background(), "localhost"); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("expected localhost to be forbidden, got %v", err)
+	}
+
+	allowed, _ := NewSSRFGuard([]string{"127.0.0.0/8", "::1"}, []string{"http"})
+	response, err := allowed.Client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("unexpected status %d", response.StatusCode)
+	}
+}
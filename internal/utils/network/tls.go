@@ -0,0 +1,191 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// CertReloader serves a certificate and the CA verifying peers from files, they're reloaded on SIGHUP
+// or once the files change so that certificates are rotated without restarting the daemon
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string // empty if peers are not verified by a custom CA
+
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+func NewCertReloader(certFile, keyFile, caFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the certificate and the CA again, the previous ones are kept if they fail to load
+func (r *CertReloader) Reload() error {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var caPool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		caPool = x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", r.caFile)
+		}
+	}
+
+	r.lock.Lock()
+	r.cert = &cert
+	r.caPool = caPool
+	r.modTimes = modTimes
+	r.lock.Unlock()
+	return nil
+}
+
+func (r *CertReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+func (r *CertReloader) changed() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err == nil && !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch reloads the files on SIGHUP and once they're changed, changes are checked every interval
+func (r *CertReloader) Watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+			case <-ticker.C:
+				if !r.changed() {
+					continue
+				}
+			}
+
+			if err := r.Reload(); err != nil {
+				log.Error("failed to reload tls certificate, keep serving the previous one: %s", err.Error())
+				continue
+			}
+			log.Info("tls certificate reloaded from %s", r.certFile)
+		}
+	}()
+}
+
+func (r *CertReloader) certificate() *tls.Certificate {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.cert
+}
+
+func (r *CertReloader) pool() *x509.CertPool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.caPool
+}
+
+// ServerConfig returns the tls config of the listener, clients are required to present a certificate
+// signed by the CA if the CA is set
+func (r *CertReloader) ServerConfig(minVersion uint16) *tls.Config {
+	return &tls.Config{
+		MinVersion: minVersion,
+		// the config is built per connection so that a reloaded CA takes effect
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config := &tls.Config{
+				MinVersion:   minVersion,
+				Certificates: []tls.Certificate{*r.certificate()},
+			}
+			if pool := r.pool(); pool != nil {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = pool
+			}
+			return config, nil
+		},
+	}
+}
+
+// ClientConfig returns the tls config of requests between nodes of the cluster, nodes present their own
+// certificate and verify the chain of peers against the CA, or the system roots if it's not set.
+// Nodes are addressed by ip so host names are not verified.
+func (r *CertReloader) ClientConfig(minVersion uint16) *tls.Config {
+	return &tls.Config{
+		MinVersion: minVersion,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.certificate(), nil
+		},
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("no certificate presented by the peer")
+			}
+
+			certs := make([]*x509.Certificate, 0, len(rawCerts))
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+			}
+
+			intermediates := x509.NewCertPool()
+			for _, cert := range certs[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := certs[0].Verify(x509.VerifyOptions{
+				Roots:         r.pool(),
+				Intermediates: intermediates,
+			})
+			return err
+		},
+	}
+}
@@ -0,0 +1,136 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, path string, blockType string, der []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// issue writes a ca and a certificate of both server and client auth signed by it into dir
+func issue(t *testing.T, dir string, serial int64) (certFile, keyFile, caFile string) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(serial + 1),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, cert, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	caFile = filepath.Join(dir, "ca.pem")
+	writePEM(t, certFile, "CERTIFICATE", certDER)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	writePEM(t, caFile, "CERTIFICATE", caDER)
+	return certFile, keyFile, caFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	certFile, keyFile, caFile := issue(t, t.TempDir(), 1)
+	reloader, err := NewCertReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Listener = tls.NewListener(server.Listener, reloader.ServerConfig(tls.VersionTLS12))
+	server.Start()
+	defer server.Close()
+
+	url := "https://" + server.Listener.Addr().String()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: reloader.ClientConfig(tls.VersionTLS12)}}
+	response, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("nodes presenting certificates of the ca should be accepted: %v", err)
+	}
+	response.Body.Close()
+
+	// a client without certificate is rejected
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	if response, err := anonymous.Get(url); err == nil {
+		response.Body.Close()
+		t.Fatal("clients without certificate should be rejected")
+	}
+
+	// a node of another ca is rejected by the client as well
+	otherCert, otherKey, otherCA := issue(t, t.TempDir(), 10)
+	other, err := NewCertReloader(otherCert, otherKey, otherCA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: other.ClientConfig(tls.VersionTLS12)}}
+	if response, err := client.Get(url); err == nil {
+		response.Body.Close()
+		t.Fatal("certificates of another ca should be rejected")
+	}
+}
+
+func TestCertReloaderChanged(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := issue(t, dir, 1)
+	reloader, err := NewCertReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloader.changed() {
+		t.Fatal("files are not changed yet")
+	}
+
+	previous := reloader.certificate()
+	issue(t, dir, 20)
+	later := time.Now().Add(time.Second)
+	for _, file := range []string{certFile, keyFile, caFile} {
+		os.Chtimes(file, later, later)
+	}
+	if !reloader.changed() {
+		t.Fatal("rotated files should be detected")
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if reloader.certificate() == previous {
+		t.Fatal("certificate should be reloaded")
+	}
+}
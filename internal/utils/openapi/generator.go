@@ -0,0 +1,381 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const API_KEY_SECURITY_SCHEME = "api_key"
+
+// Route describes an operation of the api, fields of the request tagged by `uri` become path parameters,
+// `form` query parameters or form fields, and `json` the json body, in the same way they're bound by gin
+type Route struct {
+	Method  string
+	Path    string // in the gin syntax, e.g. /plugin/:tenant_id/asset/:id
+	Summary string
+	Tags    []string
+	// Headers are required by the operation besides the api key
+	Headers []string
+	// Request is a value of the type the request is bound to, nil if the request carries nothing
+	Request any
+	// Response is a value of the type of `data` in the response envelope, nil if it's not typed
+	Response any
+	// Stream responses are server sent events carrying envelopes, Raw ones are not wrapped at all
+	Stream  bool
+	Raw     bool
+	Secured bool
+}
+
+// Generator builds a document from routes, schemas of named types are shared as components,
+// secured operations are authenticated by the api key in the given header
+type Generator struct {
+	doc   *Document
+	types map[string]reflect.Type
+}
+
+func NewGenerator(title string, version string, apiKeyHeader string) *Generator {
+	return &Generator{
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Info:    Info{Title: title, Version: version},
+			Paths:   map[string]*PathItem{},
+			Components: Components{
+				Schemas: map[string]*Schema{},
+				SecuritySchemes: map[string]*SecurityScheme{
+					API_KEY_SECURITY_SCHEME: {Type: "apiKey", In: "header", Name: apiKeyHeader},
+				},
+			},
+		},
+		types: map[string]reflect.Type{},
+	}
+}
+
+func (g *Generator) Document() *Document {
+	return g.doc
+}
+
+var ginPathParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Add adds the operation of a route, the route replaces an existing one with the same method and path
+func (g *Generator) Add(route Route) {
+	method := strings.ToLower(route.Method)
+	specPath := ginPathParam.ReplaceAllString(route.Path, "{$1}")
+
+	operation := &Operation{
+		OperationID: operationID(method, specPath),
+		Summary:     route.Summary,
+		Tags:        route.Tags,
+		Responses:   map[string]*Response{},
+	}
+	if route.Secured {
+		operation.Security = []map[string][]string{{API_KEY_SECURITY_SCHEME: {}}}
+	}
+
+	for _, header := range route.Headers {
+		operation.Parameters = append(operation.Parameters, &Parameter{
+			Name: header, In: "header", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	g.addRequest(operation, method, route)
+
+	// path parameters are declared by the route itself even if the request doesn't bind them
+	for _, match := range ginPathParam.FindAllStringSubmatch(route.Path, -1) {
+		if !hasParameter(operation, match[1], "path") {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+	}
+
+	operation.Responses["200"] = g.response(route)
+
+	item, ok := g.doc.Paths[specPath]
+	if !ok {
+		item = &PathItem{}
+		g.doc.Paths[specPath] = item
+	}
+	(*item)[method] = operation
+}
+
+func (g *Generator) response(route Route) *Response {
+	if route.Raw {
+		return &Response{Description: "success"}
+	}
+
+	data := &Schema{}
+	if route.Response != nil {
+		data = g.SchemaOf(reflect.TypeOf(route.Response))
+	}
+	envelope := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Description: "0 on success, an error code otherwise"},
+			"message": {Type: "string"},
+			"data":    data,
+		},
+		Required: []string{"code", "message", "data"},
+	}
+
+	if route.Stream {
+		return &Response{
+			Description: "server sent events, each of them carries an envelope in its data field",
+			Content:     map[string]*MediaType{"text/event-stream": {Schema: envelope}},
+		}
+	}
+	return &Response{
+		Description: "envelope of the result",
+		Content:     map[string]*MediaType{"application/json": {Schema: envelope}},
+	}
+}
+
+func (g *Generator) addRequest(operation *Operation, method string, route Route) {
+	if route.Request == nil {
+		return
+	}
+	t := reflect.TypeOf(route.Request)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	inQuery := method == "get" || method == "head" || method == "delete"
+	form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	multipartForm := false
+	hasJSON := false
+
+	for _, field := range flattenFields(t) {
+		required := isRequired(field)
+		if name := tagName(field, "uri"); name != "" {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name: name, In: "path", Required: true, Schema: g.SchemaOf(field.Type),
+			})
+			continue
+		}
+		if name := tagName(field, "json"); name != "" {
+			hasJSON = true
+			continue
+		}
+		if name := tagName(field, "form"); name != "" {
+			if inQuery {
+				operation.Parameters = append(operation.Parameters, &Parameter{
+					Name: name, In: "query", Required: required, Schema: g.SchemaOf(field.Type),
+				})
+				continue
+			}
+			form.Properties[name] = g.SchemaOf(field.Type)
+			if isFile(field.Type) {
+				multipartForm = true
+			}
+			if required {
+				form.Required = append(form.Required, name)
+			}
+		}
+	}
+
+	switch {
+	case hasJSON:
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: g.SchemaOf(t)}},
+		}
+	case len(form.Properties) > 0:
+		contentType := "application/x-www-form-urlencoded"
+		if multipartForm {
+			contentType = "multipart/form-data"
+		}
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{contentType: {Schema: form}},
+		}
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// SchemaOf returns the schema of values of t encoded by encoding/json, named structs are referenced
+func (g *Generator) SchemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	case fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.SchemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.SchemaOf(t.Elem())}
+	case reflect.Struct:
+		// the encoding of a custom marshaler is unknown
+		if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+			return &Schema{}
+		}
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := g.componentName(t)
+		if _, ok := g.doc.Components.Schemas[name]; !ok {
+			// registered before it's built, so recursive types end up referencing themselves
+			schema := &Schema{}
+			g.doc.Components.Schemas[name] = schema
+			*schema = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// interfaces, any value is accepted
+	return &Schema{}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, field := range flattenFields(t) {
+		name := tagName(field, "json")
+		if name == "" {
+			continue
+		}
+		schema.Properties[name] = g.SchemaOf(field.Type)
+		if isRequired(field) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+var packagePath = regexp.MustCompile(`(?:[A-Za-z0-9_.\-~]+/)+`)
+var invalidComponentChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]+`)
+
+// componentName names t by its package and type name, instantiations of generic types
+// are named after their type arguments
+func (g *Generator) componentName(t reflect.Type) string {
+	name := path.Base(t.PkgPath()) + "." + packagePath.ReplaceAllString(t.Name(), "")
+	name = strings.Trim(invalidComponentChars.ReplaceAllString(name, "_"), "_")
+
+	// types sharing the name in different packages are told apart by a suffix
+	candidate := name
+	for i := 2; ; i++ {
+		existing, ok := g.types[candidate]
+		if !ok {
+			g.types[candidate] = t
+			return candidate
+		}
+		if existing == t {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+}
+
+// flattenFields returns the exported fields of t, fields of embedded structs without a json name are inlined
+func flattenFields(t reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && tagName(field, "json") == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, flattenFields(embedded)...)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// tagName returns the name in the tag key of the field, empty if it's not tagged or ignored
+func tagName(field reflect.StructField, key string) string {
+	tag, ok := field.Tag.Lookup(key)
+	if !ok {
+		if key == "json" && field.IsExported() && !field.Anonymous &&
+			field.Tag.Get("uri") == "" && field.Tag.Get("form") == "" {
+			// untagged fields are encoded by their names
+			return field.Name
+		}
+		return ""
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" && key == "json" {
+		return field.Name
+	}
+	return name
+}
+
+func isRequired(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func isFile(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t == fileHeaderType
+}
+
+func hasParameter(operation *Operation, name string, in string) bool {
+	for _, parameter := range operation.Parameters {
+		if parameter.Name == name && parameter.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+func operationID(method string, specPath string) string {
+	segments := []string{method}
+	for _, segment := range strings.Split(specPath, "/") {
+		segment = strings.Trim(segment, "{}")
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return invalidComponentChars.ReplaceAllString(strings.Join(segments, "_"), "_")
+}
@@ -0,0 +1,131 @@
+package openapi
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testNode struct {
+	Name     string     `json:"name" validate:"required"`
+	Children []testNode `json:"children"`
+	internal string
+}
+
+type testBase struct {
+	TenantID string `json:"tenant_id" uri:"tenant_id" validate:"required"`
+}
+
+type testRequest[T any] struct {
+	testBase
+
+	CreatedAt time.Time       `json:"created_at"`
+	Raw       json.RawMessage `json:"raw"`
+	Ignored   string          `json:"-"`
+	Data      T               `json:"data" validate:"required"`
+}
+
+func TestSchemaOfReferencesNamedStructs(t *testing.T) {
+	g := NewGenerator("test", "0.0.1", "X-Api-Key")
+
+	schema := g.SchemaOf(reflect.TypeOf(testRequest[testNode]{}))
+	if schema.Ref != "#/components/schemas/openapi.testRequest_openapi.testNode" {
+		t.Fatalf("unexpected reference %s", schema.Ref)
+	}
+
+	request := g.doc.Components.Schemas["openapi.testRequest_openapi.testNode"]
+	for _, name := range []string{"tenant_id", "created_at", "raw", "data"} {
+		if _, ok := request.Properties[name]; !ok {
+			t.Errorf("property %s should be present", name)
+		}
+	}
+	if _, ok := request.Properties["Ignored"]; ok {
+		t.Error("ignored field should not be present")
+	}
+	if request.Properties["created_at"].Format != "date-time" {
+		t.Error("time should be a date-time string")
+	}
+	if len(request.Required) != 2 {
+		t.Errorf("tenant_id and data should be required, got %v", request.Required)
+	}
+
+	// recursive types reference themselves
+	node := g.doc.Components.Schemas["openapi.testNode"]
+	if node.Properties["children"].Items.Ref != "#/components/schemas/openapi.testNode" {
+		t.Fatal("children should reference the node")
+	}
+	if _, ok := node.Properties["internal"]; ok {
+		t.Fatal("unexported field should not be present")
+	}
+}
+
+func TestAddSplitsParameters(t *testing.T) {
+	g := NewGenerator("test", "0.0.1", "X-Api-Key")
+
+	g.Add(Route{
+		Method: "GET",
+		Path:   "/plugin/:tenant_id/list/*path",
+		Request: struct {
+			TenantID string `uri:"tenant_id" validate:"required"`
+			Page     int    `form:"page" validate:"required,min=1"`
+			PageSize int    `form:"page_size"`
+		}{},
+		Response: []testNode{},
+		Secured:  true,
+	})
+	g.Add(Route{
+		Method: "POST",
+		Path:   "/plugin/:tenant_id/upload",
+		Request: struct {
+			TenantID string                `uri:"tenant_id"`
+			File     *multipart.FileHeader `form:"file" validate:"required"`
+		}{},
+		Raw: true,
+	})
+
+	list := (*g.doc.Paths["/plugin/{tenant_id}/list/{path}"])["get"]
+	if list == nil {
+		t.Fatal("operation should be added with the path in the openapi syntax")
+	}
+	if list.OperationID != "get_plugin_tenant_id_list_path" {
+		t.Errorf("unexpected operation id %s", list.OperationID)
+	}
+	in := map[string]string{}
+	for _, parameter := range list.Parameters {
+		in[parameter.Name] = parameter.In
+	}
+	expected := map[string]string{"tenant_id": "path", "page": "query", "page_size": "query", "path": "path"}
+	for name, where := range expected {
+		if in[name] != where {
+			t.Errorf("parameter %s should be in %s, got %s", name, where, in[name])
+		}
+	}
+	if list.RequestBody != nil {
+		t.Error("get should not have a body")
+	}
+	if len(list.Security) != 1 {
+		t.Error("secured operation should require the api key")
+	}
+	data := list.Responses["200"].Content["application/json"].Schema.Properties["data"]
+	if data.Type != "array" || data.Items.Ref != "#/components/schemas/openapi.testNode" {
+		t.Error("data should be an array of nodes")
+	}
+
+	upload := (*g.doc.Paths["/plugin/{tenant_id}/upload"])["post"]
+	form, ok := upload.RequestBody.Content["multipart/form-data"]
+	if !ok {
+		t.Fatal("files should be uploaded by multipart forms")
+	}
+	if form.Schema.Properties["file"].Format != "binary" {
+		t.Error("file should be binary")
+	}
+	if upload.Responses["200"].Content != nil {
+		t.Error("raw response should not be wrapped")
+	}
+
+	if _, err := json.Marshal(g.Document()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,28 +1,230 @@
 package parser
 
 import (
-	"github.com/mitchellh/mapstructure"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 )
 
-func StructToMap(data any) map[string]any {
-	result := make(map[string]any)
+// StructToMapHook converts a value before it's put into the map, handled is false to leave the value to
+// the next hook or the default conversion
+type StructToMapHook func(value reflect.Value) (result any, handled bool, err error)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+)
 
-	decoder := &mapstructure.DecoderConfig{
-		Metadata: nil,
-		Result:   &result,
-		TagName:  "json",
-		Squash:   true,
+// defaultStructToMapHooks run after the hooks of callers
+var defaultStructToMapHooks = []StructToMapHook{
+	RawMessageHook,
+	MarshalerHook,
+}
+
+// RawMessageHook decodes json.RawMessage into the value it holds, so the map reads like the json of the
+// struct rather than holding bytes
+func RawMessageHook(value reflect.Value) (any, bool, error) {
+	if value.Type() != rawMessageType {
+		return nil, false, nil
+	}
+	raw := value.Bytes()
+	if len(raw) == 0 {
+		return nil, true, nil
 	}
+	var result any
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, true, err
+	}
+	return result, true, nil
+}
 
-	d, err := mapstructure.NewDecoder(decoder)
-	if err != nil {
-		return nil
+// MarshalerHook keeps structs marshaling themselves like time.Time as they are, their fields don't tell
+// what they marshal to
+func MarshalerHook(value reflect.Value) (any, bool, error) {
+	if value.Kind() != reflect.Struct {
+		return nil, false, nil
+	}
+	if value.Type().Implements(jsonMarshalerType) {
+		return value.Interface(), true, nil
+	}
+	if reflect.PointerTo(value.Type()).Implements(jsonMarshalerType) {
+		// the marshaler is only called through a pointer, copy the value if it's not addressable
+		if value.CanAddr() {
+			return value.Addr().Interface(), true, nil
+		}
+		pointer := reflect.New(value.Type())
+		pointer.Elem().Set(value)
+		return pointer.Interface(), true, nil
 	}
+	return nil, false, nil
+}
 
-	err = d.Decode(data)
+// StructToMap converts the struct into a map keyed by json names of its fields, nil is returned if it fails,
+// use TryStructToMap to know why
+func StructToMap(data any) map[string]any {
+	result, err := TryStructToMap(data)
 	if err != nil {
 		return nil
 	}
-
 	return result
 }
+
+// TryStructToMap converts the struct or the map into a map keyed by json names of its fields, fields follow
+// the json tags including `-` and omitempty, fields of embedded structs are squashed into the map, nested
+// structs are converted into maps, other values are kept as they are unless a hook converts them
+func TryStructToMap(data any, hooks ...StructToMapHook) (map[string]any, error) {
+	c := structToMapConverter{
+		hooks: append(append([]StructToMapHook{}, hooks...), defaultStructToMapHooks...),
+	}
+
+	value := reflect.ValueOf(data)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, fmt.Errorf("expected a struct or a map, got nil %s", value.Type())
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		result := map[string]any{}
+		if err := c.fields(value, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	case reflect.Map:
+		return c.entries(value)
+	}
+	if !value.IsValid() {
+		return nil, fmt.Errorf("expected a struct or a map, got nil")
+	}
+	return nil, fmt.Errorf("expected a struct or a map, got %s", value.Type())
+}
+
+type structToMapConverter struct {
+	hooks []StructToMapHook
+}
+
+// fields puts the fields of the struct into the map
+func (c structToMapConverter) fields(value reflect.Value, result map[string]any) error {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		v := value.Field(i)
+		if name == "" && field.Anonymous {
+			embedded := v
+			if embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := c.fields(embedded, result); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(","+options+",", ",omitempty,") && isEmptyValue(v) {
+			continue
+		}
+
+		converted, err := c.convert(v)
+		if err != nil {
+			return fmt.Errorf("error converting field %s: %w", field.Name, err)
+		}
+		result[name] = converted
+	}
+	return nil
+}
+
+// entries converts values of the map with string keys
+func (c structToMapConverter) entries(value reflect.Value) (map[string]any, error) {
+	if value.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("expected a map with string keys, got %s", value.Type())
+	}
+	if value.IsNil() {
+		return nil, fmt.Errorf("expected a struct or a map, got nil %s", value.Type())
+	}
+
+	result := make(map[string]any, value.Len())
+	iter := value.MapRange()
+	for iter.Next() {
+		converted, err := c.convert(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("error converting key %s: %w", iter.Key().String(), err)
+		}
+		result[iter.Key().String()] = converted
+	}
+	return result, nil
+}
+
+// convert converts a value of a field, nested structs become maps
+func (c structToMapConverter) convert(value reflect.Value) (any, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+
+	for _, hook := range c.hooks {
+		result, handled, err := hook(value)
+		if err != nil {
+			return nil, err
+		}
+		if handled {
+			return result, nil
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return nil, nil
+		}
+		// pointers to other values are kept as they are
+		if value.Elem().Kind() == reflect.Struct {
+			return c.convert(value.Elem())
+		}
+	case reflect.Struct:
+		result := map[string]any{}
+		if err := c.fields(value, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	return value.Interface(), nil
+}
+
+// isEmptyValue tells if the value is omitted by omitempty, the same as encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestStruct2Map(t *testing.T) {
@@ -32,3 +35,188 @@ func TestStruct2Map(t *testing.T) {
 		t.Error("b should be 2")
 	}
 }
+
+type struct2MapNested struct {
+	Name string `json:"name"`
+}
+
+type struct2MapSample struct {
+	ID        string            `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiredAt *time.Time        `json:"expired_at"`
+	Raw       json.RawMessage   `json:"raw"`
+	Optional  string            `json:"optional,omitempty"`
+	Ignored   string            `json:"-"`
+	Nested    struct2MapNested  `json:"nested"`
+	Pointer   *struct2MapNested `json:"pointer"`
+	Untagged  int
+}
+
+func TestTryStructToMap(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	result, err := TryStructToMap(&struct2MapSample{
+		ID:        "id",
+		CreatedAt: createdAt,
+		Raw:       json.RawMessage(`{"a":[1,"b"]}`),
+		Ignored:   "ignored",
+		Nested:    struct2MapNested{Name: "nested"},
+		Untagged:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"id":         "id",
+		"created_at": createdAt,
+		"expired_at": nil,
+		"raw":        map[string]any{"a": []any{float64(1), "b"}},
+		"nested":     map[string]any{"name": "nested"},
+		"pointer":    nil,
+		"Untagged":   1,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %#v, got %#v", expected, result)
+	}
+}
+
+func TestTryStructToMapFails(t *testing.T) {
+	if _, err := TryStructToMap(struct2MapSample{Raw: json.RawMessage(`{`)}); err == nil {
+		t.Error("expected invalid json of raw messages to fail")
+	}
+	if StructToMap(struct2MapSample{Raw: json.RawMessage(`{`)}) != nil {
+		t.Error("expected StructToMap to return nil once it fails")
+	}
+	for _, data := range []any{nil, 1, (*struct2MapSample)(nil), map[int]any{1: 1}} {
+		if _, err := TryStructToMap(data); err == nil {
+			t.Errorf("expected %#v to be rejected", data)
+		}
+	}
+}
+
+func TestTryStructToMapHooks(t *testing.T) {
+	// times are formatted by the hook of the caller, which runs before the default ones
+	formatTime := func(value reflect.Value) (any, bool, error) {
+		if tm, ok := value.Interface().(time.Time); ok {
+			return tm.Format(time.DateOnly), true, nil
+		}
+		return nil, false, nil
+	}
+
+	result, err := TryStructToMap(struct2MapSample{
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}, formatTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["created_at"] != "2024-01-02" {
+		t.Errorf("expected the time to be formatted by the hook, got %v", result["created_at"])
+	}
+}
+
+// struct2MapMasked marshals itself through a pointer receiver only
+type struct2MapMasked struct {
+	Secret string
+}
+
+func (m *struct2MapMasked) MarshalJSON() ([]byte, error) {
+	return json.Marshal("***")
+}
+
+func TestTryStructToMapPointerMarshaler(t *testing.T) {
+	type sample struct {
+		Masked struct2MapMasked `json:"masked"`
+	}
+
+	// fields of addressable structs and copies of unaddressable ones are both marshaled by the marshaler
+	for _, data := range []any{&sample{Masked: struct2MapMasked{Secret: "secret"}}, sample{Masked: struct2MapMasked{Secret: "secret"}}} {
+		result, err := TryStructToMap(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := result["masked"].(*struct2MapMasked); !ok {
+			t.Fatalf("expected the marshaler to be kept, got %#v", result["masked"])
+		}
+		if encoded := MarshalJson(result); encoded != `{"masked":"***"}` {
+			t.Errorf("expected the custom marshaler to be used, got %s", encoded)
+		}
+	}
+}
+
+// the json of the map is the one of the struct
+func assertSameJson(t *testing.T, data any) {
+	result, err := TryStructToMap(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromStruct, fromMap any
+	if err := json.Unmarshal(MarshalJsonBytes(data), &fromStruct); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(MarshalJsonBytes(result), &fromMap); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromStruct, fromMap) {
+		t.Errorf("expected json of the map %v to be the one of the struct %v", fromMap, fromStruct)
+	}
+}
+
+func TestStructToMapRoundTrip(t *testing.T) {
+	expiredAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	sample := struct2MapSample{
+		ID:        "id",
+		CreatedAt: time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC),
+		ExpiredAt: &expiredAt,
+		Raw:       json.RawMessage(`[1,2,3]`),
+		Optional:  "optional",
+		Nested:    struct2MapNested{Name: "nested"},
+		Pointer:   &struct2MapNested{Name: "pointer"},
+		Untagged:  2,
+	}
+	assertSameJson(t, sample)
+
+	// the map is decoded back into the struct, raw messages are left out as they're decoded into values
+	type base struct {
+		ID        string           `json:"id"`
+		CreatedAt time.Time        `json:"created_at"`
+		Nested    struct2MapNested `json:"nested"`
+	}
+	original := base{ID: sample.ID, CreatedAt: sample.CreatedAt, Nested: sample.Nested}
+	decoded, err := MapToStruct[base](StructToMap(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*decoded, original) {
+		t.Errorf("expected %v, got %v", original, *decoded)
+	}
+}
+
+func FuzzStructToMap(f *testing.F) {
+	f.Add("id", "", int64(0), true, []byte(`{"a":1}`))
+	f.Add("", "optional", int64(-1), false, []byte(`null`))
+	f.Fuzz(func(t *testing.T, id string, optional string, seconds int64, enabled bool, raw []byte) {
+		if !json.Valid(raw) {
+			raw = nil
+		}
+		createdAt := time.Unix(seconds%(1<<40), 0).UTC()
+		type Embedded struct {
+			Name string `json:"name"`
+		}
+		assertSameJson(t, struct {
+			Embedded
+			ID        string          `json:"id"`
+			Optional  string          `json:"optional,omitempty"`
+			Enabled   bool            `json:"enabled"`
+			CreatedAt time.Time       `json:"created_at"`
+			Raw       json.RawMessage `json:"raw"`
+		}{
+			Embedded:  Embedded{Name: id},
+			ID:        id,
+			Optional:  optional,
+			Enabled:   enabled,
+			CreatedAt: createdAt,
+			Raw:       raw,
+		})
+	})
+}
@@ -0,0 +1,164 @@
+package profiling
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Goroutines launched by routine.Submit and the ones writing to a session carry pprof labels, the labels
+// are kept by the goroutine profile, so goroutines leaked by a plugin are told apart by them.
+
+const (
+	LABEL_PLUGIN_ID  = "plugin_id"
+	LABEL_SESSION_ID = "session_id"
+)
+
+// GoroutineGroup is goroutines sharing the same stack and labels
+type GoroutineGroup struct {
+	Count  int               `json:"count"`
+	Labels map[string]string `json:"labels"`
+	Stack  []string          `json:"stack"`
+}
+
+// GoroutineFilter selects goroutine groups by their labels, empty fields match any group
+type GoroutineFilter struct {
+	// Plugin matches both plugin ids and unique identifiers of the plugin
+	Plugin  string
+	Session string
+}
+
+func (f GoroutineFilter) match(group GoroutineGroup) bool {
+	if f.Plugin != "" && !matchPlugin(group.Labels[LABEL_PLUGIN_ID], f.Plugin) {
+		return false
+	}
+	if f.Session != "" && group.Labels[LABEL_SESSION_ID] != f.Session {
+		return false
+	}
+	return true
+}
+
+// matchPlugin returns true if the label is the plugin or one of its unique identifiers
+func matchPlugin(label string, plugin string) bool {
+	return label == plugin || strings.HasPrefix(label, plugin+":") || strings.HasPrefix(label, plugin+"@")
+}
+
+// pluginIdOf strips the version and checksum of a unique identifier
+func pluginIdOf(label string) string {
+	if i := strings.IndexAny(label, ":@"); i >= 0 {
+		return label[:i]
+	}
+	return label
+}
+
+// GoroutineDump is the goroutines of the current process grouped by plugin and session
+type GoroutineDump struct {
+	Total     int              `json:"total"`
+	ByPlugin  map[string]int   `json:"by_plugin"`
+	BySession map[string]int   `json:"by_session"`
+	Groups    []GoroutineGroup `json:"groups"`
+}
+
+// DumpGoroutines returns goroutines matching the filter, groups with the most goroutines come first
+func DumpGoroutines(filter GoroutineFilter) (*GoroutineDump, error) {
+	buffer := &bytes.Buffer{}
+	if err := pprof.Lookup("goroutine").WriteTo(buffer, 1); err != nil {
+		return nil, err
+	}
+
+	groups, err := ParseGoroutineProfile(buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &GoroutineDump{
+		ByPlugin:  map[string]int{},
+		BySession: map[string]int{},
+		Groups:    []GoroutineGroup{},
+	}
+	for _, group := range groups {
+		if !filter.match(group) {
+			continue
+		}
+
+		dump.Total += group.Count
+		dump.Groups = append(dump.Groups, group)
+		if plugin := group.Labels[LABEL_PLUGIN_ID]; plugin != "" {
+			dump.ByPlugin[pluginIdOf(plugin)] += group.Count
+		}
+		if session := group.Labels[LABEL_SESSION_ID]; session != "" {
+			dump.BySession[session] += group.Count
+		}
+	}
+
+	sort.SliceStable(dump.Groups, func(i, j int) bool {
+		return dump.Groups[i].Count > dump.Groups[j].Count
+	})
+	return dump, nil
+}
+
+// ParseGoroutineProfile parses the goroutine profile written in the text format, aka debug=1
+func ParseGoroutineProfile(data []byte) ([]GoroutineGroup, error) {
+	groups := []GoroutineGroup{}
+	var current *GoroutineGroup
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			if current != nil {
+				groups = append(groups, *current)
+				current = nil
+			}
+		case strings.HasPrefix(line, "# labels: "):
+			if current == nil {
+				continue
+			}
+			// labels are written as {"key":"value", ...}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "# labels: ")), &current.Labels); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "#"):
+			if current == nil {
+				continue
+			}
+			// frames are written as #\t<pc>\t<function>+<offset>\t<file>:<line>
+			fields := strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "#")), "\t")
+			if len(fields) < 3 {
+				continue
+			}
+			function, _, _ := strings.Cut(fields[1], "+")
+			current.Stack = append(current.Stack, function+" "+fields[2])
+		default:
+			// a group starts with <count> @ <pcs>
+			count, _, ok := strings.Cut(line, " @ ")
+			if !ok {
+				continue
+			}
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				continue
+			}
+			current = &GoroutineGroup{
+				Count:  n,
+				Labels: map[string]string{},
+				Stack:  []string{},
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if current != nil {
+		groups = append(groups, *current)
+	}
+	return groups, nil
+}
@@ -0,0 +1,69 @@
+package profiling
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestDumpGoroutinesByLabels(t *testing.T) {
+	blocked := make(chan struct{})
+	started := make(chan struct{})
+	defer close(blocked)
+
+	for i := 0; i < 2; i++ {
+		go pprof.Do(context.Background(), pprof.Labels(
+			LABEL_PLUGIN_ID, "langgenius/openai:0.0.1@abc",
+			LABEL_SESSION_ID, "session",
+		), func(ctx context.Context) {
+			started <- struct{}{}
+			<-blocked
+		})
+		<-started
+	}
+
+	dump, err := DumpGoroutines(GoroutineFilter{Plugin: "langgenius/openai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dump.Total != 2 || dump.ByPlugin["langgenius/openai"] != 2 || dump.BySession["session"] != 2 {
+		t.Fatalf("labeled goroutines should be grouped, got %+v", dump)
+	}
+	if len(dump.Groups) != 1 || len(dump.Groups[0].Stack) == 0 {
+		t.Fatalf("goroutines sharing the stack should be in one group, got %+v", dump.Groups)
+	}
+
+	dump, err = DumpGoroutines(GoroutineFilter{Plugin: "langgenius/openai_api_compatible"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.Total != 0 {
+		t.Fatalf("goroutines of other plugins should be filtered out, got %+v", dump)
+	}
+}
+
+func TestParseGoroutineProfile(t *testing.T) {
+	groups, err := ParseGoroutineProfile([]byte(`goroutine profile: total 3
+2 @ 0x43a3d5 0x44b1c5
+# labels: {"module":"cluster", "plugin_id":"langgenius/openai"}
+#	0x43a3d4	runtime.gopark+0xd4	/usr/local/go/src/runtime/proc.go:398
+#	0x44b1c4	main.main+0x24	/src/main.go:10
+
+1 @ 0x43a3d5
+#	0x43a3d4	runtime.gopark+0xd4	/usr/local/go/src/runtime/proc.go:398
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 2 || groups[0].Count != 2 || groups[1].Count != 1 {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+	if groups[0].Labels["module"] != "cluster" || groups[0].Labels[LABEL_PLUGIN_ID] != "langgenius/openai" {
+		t.Fatalf("labels should be parsed, got %v", groups[0].Labels)
+	}
+	if groups[0].Stack[1] != "main.main /src/main.go:10" {
+		t.Fatalf("frames should be parsed, got %v", groups[0].Stack)
+	}
+}
@@ -0,0 +1,193 @@
+// Package redact masks secrets in text written by plugins, like their logs, stderr and errors, before
+// it's logged or returned. Values of credentials sent to a session are masked in text of the session,
+// values looking like common secrets, like api keys and bearer tokens, are masked everywhere.
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	REDACTED = "[REDACTED]"
+
+	// shorter values of credentials are left as they're likely not secrets, like model names
+	minSecretLength = 8
+)
+
+// sensitiveKeys are keys carrying secrets of tenants, matched case-insensitively as substrings
+var sensitiveKeys = []string{
+	"credential",
+	"settings",
+	"secret",
+	"password",
+	"token",
+	"api_key",
+	"authorization",
+	"cookie",
+}
+
+// SensitiveKey returns true if values of the key are secrets
+func SensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type pattern struct {
+	regexp *regexp.Regexp
+	// group is the submatch masked, 0 masks the whole match
+	group int
+}
+
+// patterns match common secrets
+var patterns = []pattern{
+	// openai and anthropic like api keys
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{20,}`), 0},
+	// aws access key ids
+	{regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), 0},
+	// google api keys
+	{regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`), 0},
+	// github and slack tokens
+	{regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr|github_pat)_[A-Za-z0-9_]{20,}`), 0},
+	{regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9\-]{10,}`), 0},
+	// bearer tokens of authorization headers
+	{regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{8,}=*)`), 1},
+	// assignments like api_key=xxx or "password": "xxx"
+	{regexp.MustCompile(`(?i)(api[_-]?key|secret|token|password|passwd)["']?\s*[:=]\s*["']?([^\s"',;]{8,})`), 2},
+}
+
+var enabled atomic.Bool
+
+func init() {
+	enabled.Store(true)
+}
+
+// SetEnabled turns redaction on or off, it's on by default
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+// String masks values looking like common secrets
+func String(text string) string {
+	if !enabled.Load() {
+		return text
+	}
+
+	for _, p := range patterns {
+		if p.group == 0 {
+			text = p.regexp.ReplaceAllString(text, REDACTED)
+			continue
+		}
+
+		text = p.regexp.ReplaceAllStringFunc(text, func(match string) string {
+			submatches := p.regexp.FindStringSubmatchIndex(match)
+			start, end := submatches[2*p.group], submatches[2*p.group+1]
+			if start < 0 {
+				return match
+			}
+			return match[:start] + REDACTED + match[end:]
+		})
+	}
+	return text
+}
+
+var (
+	sessionsLock sync.RWMutex
+	// sessions maps session ids to values of credentials sent to them, longest first
+	sessions = map[string][]string{}
+)
+
+// SecretValues collects values of sensitive keys of the payload, nested values of them included
+func SecretValues(payload map[string]any) []string {
+	values := map[string]bool{}
+	var walk func(value any, sensitive bool)
+	walk = func(value any, sensitive bool) {
+		switch v := value.(type) {
+		case map[string]any:
+			for key, value := range v {
+				walk(value, sensitive || SensitiveKey(key))
+			}
+		case []any:
+			for _, value := range v {
+				walk(value, sensitive)
+			}
+		case string:
+			if sensitive && len(v) >= minSecretLength {
+				values[v] = true
+			}
+		}
+	}
+	walk(payload, false)
+
+	result := make([]string, 0, len(values))
+	for value := range values {
+		result = append(result, value)
+	}
+	// longer values first, so that a value containing another one is masked as a whole
+	sort.Slice(result, func(i, j int) bool { return len(result[i]) > len(result[j]) })
+	return result
+}
+
+// RegisterSession registers values of credentials sent to the session, they're masked in text of the session
+// until the session is unregistered
+func RegisterSession(sessionId string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	sessionsLock.Lock()
+	sessions[sessionId] = values
+	sessionsLock.Unlock()
+}
+
+func UnregisterSession(sessionId string) {
+	sessionsLock.Lock()
+	delete(sessions, sessionId)
+	sessionsLock.Unlock()
+}
+
+// Session masks values of credentials sent to the session and values looking like common secrets
+func Session(sessionId string, text string) string {
+	if !enabled.Load() {
+		return text
+	}
+
+	sessionsLock.RLock()
+	values := sessions[sessionId]
+	sessionsLock.RUnlock()
+
+	return String(replaceValues(text, values))
+}
+
+// Any masks values of credentials sent to any session and values looking like common secrets,
+// it's used for text not bound to a session like stderr of plugins
+func Any(text string) string {
+	if !enabled.Load() {
+		return text
+	}
+
+	sessionsLock.RLock()
+	for _, values := range sessions {
+		text = replaceValues(text, values)
+	}
+	sessionsLock.RUnlock()
+
+	return String(text)
+}
+
+func replaceValues(text string, values []string) string {
+	for _, value := range values {
+		if strings.Contains(text, value) {
+			text = strings.ReplaceAll(text, value, REDACTED)
+		}
+	}
+	return text
+}
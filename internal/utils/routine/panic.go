@@ -0,0 +1,58 @@
+package routine
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+)
+
+// PanicError is a panic recovered from a task
+type PanicError struct {
+	Value any
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// ReportPanic reports a panic of the task to its owner through report, then panics again so the pool
+// still logs and counts it. Tasks copying responses defer it after closing their streams, consumers
+// get the error instead of a stream ending as if it succeeded:
+//
+//	defer response.Close()
+//	defer routine.ReportPanic(response.WriteError)
+func ReportPanic(report func(err error)) {
+	if r := recover(); r != nil {
+		report(&PanicError{Value: r})
+		panic(r)
+	}
+}
+
+// handlePanic logs the panic with its stack and the labels of the task, which tell the session or plugin
+// it serves, counts it and reports it to sentry
+func handlePanic(ctx context.Context, labels map[string]string, value any) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+
+	log.Error("routine panicked: %v, labels: [%s]\n%s", value, strings.Join(pairs, " "), debug.Stack())
+	metrics.RoutinePanics.WithLabelValues(labels["module"], labels["function"]).Inc()
+
+	hub := sentry.CurrentHub().Clone()
+	hub.ConfigureScope(func(scope *sentry.Scope) {
+		scope.SetTags(labels)
+	})
+	hub.RecoverWithContext(ctx, value)
+}
@@ -0,0 +1,46 @@
+package routine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReportPanic(t *testing.T) {
+	counter := metrics.RoutinePanics.WithLabelValues("routine", "TestReportPanic")
+	before := testutil.ToFloat64(counter)
+
+	p := newPool("test", PoolConfig{Workers: 1, Queue: 0, Policy: POLICY_ABORT})
+	reported := make(chan error, 1)
+	if err := p.Submit(map[string]string{
+		"module":   "routine",
+		"function": "TestReportPanic",
+	}, func() {
+		defer ReportPanic(func(err error) { reported <- err })
+		panic("boom")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-reported:
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+			t.Errorf("expected the panic to be reported, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the panic to be reported to the owner")
+	}
+
+	// the panic goes on to the pool, which counts it
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(counter) == before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if testutil.ToFloat64(counter) != before+1 {
+		t.Errorf("expected the panic to be counted, got %v", testutil.ToFloat64(counter)-before)
+	}
+}
@@ -45,18 +45,27 @@ func Submit(labels map[string]string, f func()) {
 	}
 
 	p.Submit(func() {
-		label := []string{
-			"LaunchedAt", time.Now().Format(time.RFC3339),
+		run(labels, f)
+	})
+}
+
+// run runs the task labeled for profiles, panics of it are recovered by handlePanic
+func run(labels map[string]string, f func()) {
+	label := []string{
+		"LaunchedAt", time.Now().Format(time.RFC3339),
+	}
+	if len(labels) > 0 {
+		for k, v := range labels {
+			label = append(label, k, v)
 		}
-		if len(labels) > 0 {
-			for k, v := range labels {
-				label = append(label, k, v)
+	}
+	pprof.Do(context.Background(), pprof.Labels(label...), func(ctx context.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				handlePanic(ctx, labels, err)
 			}
-		}
-		pprof.Do(context.Background(), pprof.Labels(label...), func(ctx context.Context) {
-			defer sentry.Recover()
-			f()
-		})
+		}()
+		f()
 	})
 }
 
@@ -0,0 +1,274 @@
+package routine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// POLICY_ABORT rejects tasks once the queue is full, Submit returns ErrPoolFull
+	POLICY_ABORT = "abort"
+	// POLICY_CALLER_RUNS runs tasks on the goroutine of the caller once the queue is full
+	POLICY_CALLER_RUNS = "caller_runs"
+	// POLICY_BLOCK blocks the caller until a worker is free or the queue has room
+	POLICY_BLOCK = "block"
+)
+
+// pools of subsystems, tasks of them run on their own workers rather than the global pool
+const (
+	POOL_ENDPOINT_STREAM = "endpoint_stream"
+	POOL_STDIO_READER    = "stdio_reader"
+	POOL_INSTALL_TASK    = "install_task"
+)
+
+var ErrPoolFull = errors.New("too many tasks are running, try again later")
+
+// PoolConfig bounds a pool, tasks beyond the workers wait in the queue, the policy applies once it's full
+type PoolConfig struct {
+	Workers int    `json:"workers"`
+	Queue   int    `json:"queue"`
+	Policy  string `json:"policy"`
+}
+
+// defaultPools are configurations of pools unless ROUTINE_POOLS overrides them, readers of stdio and
+// streams of endpoints live as long as plugins and requests, so they're never queued
+var defaultPools = map[string]PoolConfig{
+	POOL_ENDPOINT_STREAM: {Workers: 1024, Queue: 0, Policy: POLICY_ABORT},
+	POOL_STDIO_READER:    {Workers: 2048, Queue: 0, Policy: POLICY_ABORT},
+	POOL_INSTALL_TASK:    {Workers: 16, Queue: 1024, Policy: POLICY_ABORT},
+}
+
+// unqueuedPools are pools whose tasks are only run right away or rejected, a queued reader or stream would
+// wait for the end of another plugin or request, running it on the caller or blocking it stalls the caller
+var unqueuedPools = map[string]bool{
+	POOL_ENDPOINT_STREAM: true,
+	POOL_STDIO_READER:    true,
+}
+
+type task struct {
+	labels map[string]string
+	f      func()
+}
+
+// Pool is a named pool of workers bounding tasks of a subsystem, so a spike of it can't exhaust
+// the scheduler or the memory, workers are started on demand and exit once the queue is empty
+type Pool struct {
+	name   string
+	config PoolConfig
+
+	lock sync.Mutex
+	// signaled once a worker is free or the queue has room, callers blocked by the policy wait on it
+	cond     *sync.Cond
+	busy     int
+	queue    chan task
+	rejected uint64
+	// tasks run by callers since the queue was full
+	callerRuns uint64
+}
+
+var (
+	pools     = map[string]*Pool{}
+	poolsLock sync.Mutex
+)
+
+// ConfigurePools creates the pools, configurations of spec like `install_task=16:1024:abort` override
+// the defaults, workers:queue:policy of each pool
+func ConfigurePools(spec string) error {
+	configs, err := ParsePools(spec)
+	if err != nil {
+		return err
+	}
+
+	poolsLock.Lock()
+	defer poolsLock.Unlock()
+	for name, config := range configs {
+		pools[name] = newPool(name, config)
+	}
+	return nil
+}
+
+// ParsePools returns configurations of all the pools with those of spec overriding the defaults
+func ParsePools(spec string) (map[string]PoolConfig, error) {
+	configs := map[string]PoolConfig{}
+	for name, config := range defaultPools {
+		configs[name] = config
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pool %q, expected name=workers:queue:policy", item)
+		}
+		if _, ok := defaultPools[name]; !ok {
+			return nil, fmt.Errorf("unknown pool %s", name)
+		}
+		config, err := parsePoolConfig(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pool %s: %w", name, err)
+		}
+		if unqueuedPools[name] && (config.Queue > 0 || config.Policy != POLICY_ABORT) {
+			return nil, fmt.Errorf("invalid pool %s: queue should be 0 and policy should be abort", name)
+		}
+		configs[name] = config
+	}
+	return configs, nil
+}
+
+func parsePoolConfig(value string) (PoolConfig, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return PoolConfig{}, errors.New("expected workers:queue:policy")
+	}
+
+	workers, err := strconv.Atoi(parts[0])
+	if err != nil || workers < 1 {
+		return PoolConfig{}, errors.New("workers should be a positive number")
+	}
+	queue, err := strconv.Atoi(parts[1])
+	if err != nil || queue < 0 {
+		return PoolConfig{}, errors.New("queue should be a non-negative number")
+	}
+	switch parts[2] {
+	case POLICY_ABORT, POLICY_CALLER_RUNS, POLICY_BLOCK:
+	default:
+		return PoolConfig{}, fmt.Errorf("unknown policy %s", parts[2])
+	}
+	return PoolConfig{Workers: workers, Queue: queue, Policy: parts[2]}, nil
+}
+
+func newPool(name string, config PoolConfig) *Pool {
+	p := &Pool{
+		name:   name,
+		config: config,
+		queue:  make(chan task, config.Queue),
+	}
+	p.cond = sync.NewCond(&p.lock)
+	return p
+}
+
+// GetPool returns the pool by its name, the default configuration applies if pools are not configured
+func GetPool(name string) *Pool {
+	poolsLock.Lock()
+	defer poolsLock.Unlock()
+
+	if p, ok := pools[name]; ok {
+		return p
+	}
+	config, ok := defaultPools[name]
+	if !ok {
+		panic("unknown routine pool " + name)
+	}
+	pools[name] = newPool(name, config)
+	return pools[name]
+}
+
+// SubmitTo submits the task to the pool of the name
+func SubmitTo(name string, labels map[string]string, f func()) error {
+	return GetPool(name).Submit(labels, f)
+}
+
+// Submit runs the task on a worker of the pool, or queues it if all the workers are busy, the policy of
+// the pool applies once the queue is full
+func (p *Pool) Submit(labels map[string]string, f func()) error {
+	t := task{labels: map[string]string{"pool": p.name}, f: f}
+	for k, v := range labels {
+		t.labels[k] = v
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for {
+		if p.busy < p.config.Workers {
+			p.busy++
+			go p.work(t)
+			return nil
+		}
+		select {
+		case p.queue <- t:
+			return nil
+		default:
+		}
+
+		switch p.config.Policy {
+		case POLICY_CALLER_RUNS:
+			p.callerRuns++
+			p.lock.Unlock()
+			run(t.labels, t.f)
+			p.lock.Lock()
+			return nil
+		case POLICY_BLOCK:
+			p.cond.Wait()
+			continue
+		}
+		p.rejected++
+		return ErrPoolFull
+	}
+}
+
+func (p *Pool) work(t task) {
+	for {
+		run(t.labels, t.f)
+
+		p.lock.Lock()
+		select {
+		case t = <-p.queue:
+			p.cond.Broadcast()
+			p.lock.Unlock()
+			continue
+		default:
+		}
+		p.busy--
+		p.cond.Broadcast()
+		p.lock.Unlock()
+		return
+	}
+}
+
+type PoolStats struct {
+	Name string `json:"name"`
+	PoolConfig
+	Busy       int    `json:"busy"`
+	Queued     int    `json:"queued"`
+	Rejected   uint64 `json:"rejected"`
+	CallerRuns uint64 `json:"caller_runs"`
+}
+
+func (p *Pool) Stats() PoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return PoolStats{
+		Name:       p.name,
+		PoolConfig: p.config,
+		Busy:       p.busy,
+		Queued:     len(p.queue),
+		Rejected:   p.rejected,
+		CallerRuns: p.callerRuns,
+	}
+}
+
+// FetchPoolStats returns stats of all the pools ordered by their names
+func FetchPoolStats() []PoolStats {
+	poolsLock.Lock()
+	list := make([]*Pool, 0, len(pools))
+	for _, p := range pools {
+		list = append(list, p)
+	}
+	poolsLock.Unlock()
+
+	stats := make([]PoolStats, 0, len(list))
+	for _, p := range list {
+		stats = append(stats, p.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -0,0 +1,154 @@
+package routine
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParsePools(t *testing.T) {
+	configs, err := ParsePools("install_task=4:8:block, endpoint_stream=2:0:abort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs[POOL_INSTALL_TASK] != (PoolConfig{Workers: 4, Queue: 8, Policy: POLICY_BLOCK}) {
+		t.Errorf("unexpected config of install_task: %+v", configs[POOL_INSTALL_TASK])
+	}
+	if configs[POOL_ENDPOINT_STREAM] != (PoolConfig{Workers: 2, Queue: 0, Policy: POLICY_ABORT}) {
+		t.Errorf("unexpected config of endpoint_stream: %+v", configs[POOL_ENDPOINT_STREAM])
+	}
+	if configs[POOL_STDIO_READER] != defaultPools[POOL_STDIO_READER] {
+		t.Errorf("expected unlisted pools to keep the defaults, got %+v", configs[POOL_STDIO_READER])
+	}
+
+	for _, spec := range []string{
+		"unknown=1:1:abort",
+		"install_task",
+		"install_task=0:1:abort",
+		"install_task=1:-1:abort",
+		"install_task=1:1:drop",
+		"install_task=1:1",
+		"endpoint_stream=2:1:abort",
+		"endpoint_stream=2:0:caller_runs",
+		"stdio_reader=2:0:block",
+		"stdio_reader=2:8:abort",
+	} {
+		if _, err := ParsePools(spec); err == nil {
+			t.Errorf("expected %s to be invalid", spec)
+		}
+	}
+}
+
+// occupy submits tasks blocking on release until the pool is full
+func occupy(t *testing.T, p *Pool, n int, release chan struct{}) *sync.WaitGroup {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		if err := p.Submit(nil, func() {
+			defer wg.Done()
+			<-release
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return wg
+}
+
+func TestPoolQueuesAndAborts(t *testing.T) {
+	p := newPool("test", PoolConfig{Workers: 2, Queue: 1, Policy: POLICY_ABORT})
+	release := make(chan struct{})
+	wg := occupy(t, p, 3, release)
+
+	stats := p.Stats()
+	if stats.Busy != 2 || stats.Queued != 1 {
+		t.Errorf("expected 2 busy workers and 1 queued task, got %+v", stats)
+	}
+
+	if err := p.Submit(nil, func() {}); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("expected ErrPoolFull, got %v", err)
+	}
+	if p.Stats().Rejected != 1 {
+		t.Errorf("expected the rejection to be counted, got %+v", p.Stats())
+	}
+
+	close(release)
+	wg.Wait()
+
+	// workers exit once the queue is drained
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Busy != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if p.Stats().Busy != 0 {
+		t.Errorf("expected workers to exit, got %+v", p.Stats())
+	}
+}
+
+func TestPoolCallerRuns(t *testing.T) {
+	p := newPool("test", PoolConfig{Workers: 1, Queue: 0, Policy: POLICY_CALLER_RUNS})
+	release := make(chan struct{})
+	wg := occupy(t, p, 1, release)
+	defer func() {
+		close(release)
+		wg.Wait()
+	}()
+
+	ran := false
+	if err := p.Submit(nil, func() { ran = true }); err != nil {
+		t.Fatal(err)
+	}
+	if !ran || p.Stats().CallerRuns != 1 {
+		t.Errorf("expected the task to run on the caller, got %+v", p.Stats())
+	}
+}
+
+func TestPoolBlocks(t *testing.T) {
+	p := newPool("test", PoolConfig{Workers: 1, Queue: 0, Policy: POLICY_BLOCK})
+	release := make(chan struct{})
+	wg := occupy(t, p, 1, release)
+
+	var ran int32
+	submitted := make(chan error)
+	go func() {
+		submitted <- p.Submit(nil, func() { atomic.StoreInt32(&ran, 1) })
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("expected the caller to be blocked while the worker is busy")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-submitted; err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&ran) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&ran) == 0 {
+		t.Error("expected the blocked task to run")
+	}
+}
+
+func TestPoolRecoversPanics(t *testing.T) {
+	p := newPool("test", PoolConfig{Workers: 1, Queue: 1, Policy: POLICY_ABORT})
+	done := make(chan struct{})
+	if err := p.Submit(nil, func() { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Submit(nil, func() { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("expected the pool to keep running tasks after a panic")
+	}
+}
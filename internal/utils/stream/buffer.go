@@ -0,0 +1,30 @@
+package stream
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MAX_POOLED_BUFFER_SIZE bounds buffers kept by the pool, a huge chunk would otherwise pin its buffer
+// for the lifetime of the process
+const MAX_POOLED_BUFFER_SIZE = 1024 * 1024
+
+var buffers = sync.Pool{
+	New: func() any {
+		return &bytes.Buffer{}
+	},
+}
+
+// GetBuffer returns an empty buffer from the pool, it's returned by PutBuffer once its content is written
+func GetBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+// PutBuffer returns the buffer to the pool, the buffer must not be used afterwards
+func PutBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > MAX_POOLED_BUFFER_SIZE {
+		return
+	}
+	buf.Reset()
+	buffers.Put(buf)
+}
@@ -0,0 +1,61 @@
+package stream
+
+import (
+	"io"
+)
+
+// Reader reads chunks of a byte stream as an io.Reader, io.Copy hands the chunks to the writer as they
+// are through WriteTo instead of copying them into an intermediate buffer
+type Reader struct {
+	s *Stream[[]byte]
+	// the rest of the chunk not read by Read yet
+	rest []byte
+}
+
+func NewReader(s *Stream[[]byte]) *Reader {
+	return &Reader{s: s}
+}
+
+func (r *Reader) next() ([]byte, error) {
+	if !r.s.Next() {
+		return nil, io.EOF
+	}
+	return r.s.Read()
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.rest) == 0 {
+		chunk, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		r.rest = chunk
+	}
+
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
+
+// WriteTo writes chunks to w until the stream ends, an error written to the stream is returned as is
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for {
+		chunk := r.rest
+		r.rest = nil
+		if len(chunk) == 0 {
+			var err error
+			if chunk, err = r.next(); err == io.EOF {
+				return written, nil
+			} else if err != nil {
+				return written, err
+			}
+		}
+
+		n, err := w.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+}
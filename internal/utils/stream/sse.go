@@ -0,0 +1,37 @@
+package stream
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteEvent writes data as a server-sent event, it's encoded into a pooled buffer and written at once,
+// so there is neither an intermediate slice of the json nor a write per part of the event
+func WriteEvent(w io.Writer, data any) error {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	buf.WriteString("data: ")
+	// Encode appends a newline to the json, which is the first one of the delimiter
+	if err := json.NewEncoder(buf).Encode(data); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteRawEvent writes data encoded already as a server-sent event
+func WriteRawEvent(w io.Writer, data []byte) error {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	buf.Grow(len(data) + 8)
+	buf.WriteString("data: ")
+	buf.Write(data)
+	buf.WriteString("\n\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -1,7 +1,9 @@
 package stream
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"sync"
 	"testing"
 	"time"
@@ -91,3 +93,87 @@ func TestStreamGeneratorWrapper(t *testing.T) {
 		t.Errorf("Expected 10000 messages, got %d", nums)
 	}
 }
+
+// chunkWriter records chunks written to it
+type chunkWriter struct {
+	chunks [][]byte
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, p)
+	return len(p), nil
+}
+
+func TestReaderWritesChunksAsTheyAre(t *testing.T) {
+	response := NewStream[[]byte](8)
+	first, second := []byte("hello "), []byte("world")
+	response.Write(first)
+	response.Write(second)
+	response.Close()
+
+	w := &chunkWriter{}
+	n, err := io.Copy(w, NewReader(response))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 11 || len(w.chunks) != 2 {
+		t.Fatalf("expected 2 chunks of 11 bytes, got %d chunks of %d bytes", len(w.chunks), n)
+	}
+	// the chunks are not copied
+	if &w.chunks[0][0] != &first[0] || &w.chunks[1][0] != &second[0] {
+		t.Error("expected chunks to be written without copies")
+	}
+}
+
+func TestReaderRead(t *testing.T) {
+	response := NewStream[[]byte](8)
+	response.Write([]byte("hello "))
+	response.Write([]byte("world"))
+	response.WriteError(errors.New("broken"))
+
+	reader := NewReader(response)
+	buf := make([]byte, 4)
+	content := []byte{}
+	for {
+		n, err := reader.Read(buf)
+		content = append(content, buf[:n]...)
+		if err != nil {
+			if err.Error() != "broken" {
+				t.Errorf("expected the error of the stream, got %v", err)
+			}
+			break
+		}
+	}
+	if string(content) != "hello world" {
+		t.Errorf("expected hello world, got %s", content)
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteEvent(buf, map[string]any{"data": "<chunk>"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteRawEvent(buf, []byte("[DONE]")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "data: {\"data\":\"\\u003cchunk\\u003e\"}\n\ndata: [DONE]\n\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestPutBufferDropsLargeBuffers(t *testing.T) {
+	buf := GetBuffer()
+	buf.Grow(MAX_POOLED_BUFFER_SIZE + 1)
+	PutBuffer(buf)
+
+	// a dropped buffer is never handed out again, the pool may drop small ones as well so only the
+	// large one is checked
+	for i := 0; i < 8; i++ {
+		if GetBuffer() == buf {
+			t.Fatal("expected the large buffer to be dropped")
+		}
+	}
+}
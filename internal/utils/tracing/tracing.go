@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/manifest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spans are exported to the collector configured by the standard OTEL_EXPORTER_OTLP_* variables over http,
+// the trace context is propagated in the w3c traceparent header, so a request traced by dify continues
+// its trace within the daemon and across nodes redirecting it.
+//
+// Until Init is called, the global tracer provider is a no-op, so spans cost nothing if tracing is disabled.
+
+const (
+	SERVICE_NAME = "dify-plugin-daemon"
+)
+
+// Init registers the global tracer provider exporting spans over OTLP and returns a function to flush
+// and stop it, sampleRate is the ratio of traces started by the daemon which are sampled, traces continued
+// from a caller follow the caller's decision
+func Init(sampleRate float64) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(SERVICE_NAME),
+		semconv.ServiceVersion(manifest.VersionX),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRate))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(SERVICE_NAME)
+}
+
+// Start starts a span as a child of the span in ctx
+func Start(
+	ctx context.Context, name string, kind trace.SpanKind, attributes ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes...))
+}
+
+// StartChild starts a span only if ctx carries a span, work done outside of a traced request,
+// like periodic tasks polling redis, would otherwise flood the collector with root spans
+func StartChild(
+	ctx context.Context, name string, kind trace.SpanKind, attributes ...attribute.KeyValue,
+) (context.Context, trace.Span, bool) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil, false
+	}
+
+	ctx, span := Start(ctx, name, kind, attributes...)
+	return ctx, span, true
+}
+
+// Fail records the error on the span and marks the span failed
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Extract returns ctx carrying the trace context propagated in the headers
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// Inject writes the trace context of ctx to the headers
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartChildWithoutParent(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ctx, span, ok := StartChild(context.Background(), "child", trace.SpanKindClient)
+	if ok || span != nil {
+		t.Fatal("span should not be started without a parent")
+	}
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Fatal("context should not carry a span")
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx, span := Start(context.Background(), "request", trace.SpanKindServer)
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, propagation.HeaderCarrier(header))
+	if header.Get("traceparent") == "" {
+		t.Fatal("traceparent should be injected")
+	}
+
+	remote := Extract(context.Background(), propagation.HeaderCarrier(header))
+	_, child, ok := StartChild(remote, "redirected", trace.SpanKindServer)
+	if !ok {
+		t.Fatal("span should be continued from the propagated context")
+	}
+	defer child.End()
+
+	if child.SpanContext().TraceID() != span.SpanContext().TraceID() {
+		t.Fatal("trace id should be propagated")
+	}
+}
@@ -0,0 +1,83 @@
+package agent_entities
+
+import "github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+
+// chunk types agent strategies emit besides types of tool responses to report their traces, messages of them
+// are AgentStep, AgentThought and AgentToolCall
+const (
+	AgentStrategyResponseChunkTypeStep     tool_entities.ToolResponseChunkType = "agent_step"
+	AgentStrategyResponseChunkTypeThought  tool_entities.ToolResponseChunkType = "agent_thought"
+	AgentStrategyResponseChunkTypeToolCall tool_entities.ToolResponseChunkType = "agent_tool_call"
+)
+
+type AgentStepStatus string
+
+const (
+	AgentStepStatusStarted  AgentStepStatus = "started"
+	AgentStepStatusFinished AgentStepStatus = "finished"
+	AgentStepStatusFailed   AgentStepStatus = "failed"
+)
+
+// AgentStep is a round of the strategy, thoughts and tool calls belong to the step started before them
+type AgentStep struct {
+	ID       string          `json:"id"`
+	Index    int             `json:"index"`
+	Status   AgentStepStatus `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Metadata map[string]any  `json:"metadata,omitempty"`
+}
+
+// AgentThought is a thought of the model in the step
+type AgentThought struct {
+	StepID string `json:"step_id"`
+	Text   string `json:"text"`
+}
+
+type AgentToolCallStatus string
+
+const (
+	AgentToolCallStatusStarted   AgentToolCallStatus = "started"
+	AgentToolCallStatusSucceeded AgentToolCallStatus = "succeeded"
+	AgentToolCallStatusFailed    AgentToolCallStatus = "failed"
+)
+
+// AgentToolCall is a call of a tool in the step, calls of the same id update each other
+type AgentToolCall struct {
+	ID       string              `json:"id"`
+	StepID   string              `json:"step_id"`
+	ToolName string              `json:"tool_name"`
+	Input    map[string]any      `json:"input,omitempty"`
+	Status   AgentToolCallStatus `json:"status"`
+	Output   any                 `json:"output,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+type AgentEventType string
+
+const (
+	AgentEventTypeStep     AgentEventType = "step"
+	AgentEventTypeThought  AgentEventType = "thought"
+	AgentEventTypeToolCall AgentEventType = "tool_call"
+	// AgentEventTypeOutput carries other chunks of the strategy, like texts, variables and files
+	AgentEventTypeOutput AgentEventType = "output"
+	AgentEventTypeFinish AgentEventType = "finish"
+)
+
+// AgentEvent is an event of the trace of an agent strategy, the field named by the type is set
+type AgentEvent struct {
+	Type AgentEventType `json:"type"`
+	// Seq orders events of a stream, starting from 0
+	Seq      int                              `json:"seq"`
+	Step     *AgentStep                       `json:"step,omitempty"`
+	Thought  *AgentThought                    `json:"thought,omitempty"`
+	ToolCall *AgentToolCall                   `json:"tool_call,omitempty"`
+	Output   *tool_entities.ToolResponseChunk `json:"output,omitempty"`
+	Finish   *AgentFinish                     `json:"finish,omitempty"`
+}
+
+// AgentFinish ends a stream of events
+type AgentFinish struct {
+	Steps     int   `json:"steps"`
+	ToolCalls int   `json:"tool_calls"`
+	ElapsedMs int64 `json:"elapsed_ms"`
+}
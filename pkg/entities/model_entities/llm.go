@@ -189,6 +189,14 @@ type LLMResultChunk struct {
 	Delta             LLMResultChunkDelta `json:"delta" validate:"required"`
 }
 
+// Tokens returns the total tokens reported by the chunk, only the last chunk of a response carries the usage
+func (c LLMResultChunk) Tokens() int64 {
+	if c.Delta.Usage == nil || c.Delta.Usage.TotalTokens == nil {
+		return 0
+	}
+	return int64(*c.Delta.Usage.TotalTokens)
+}
+
 type LLMUsage struct {
 	PromptTokens        *int            `json:"prompt_tokens" validate:"required"`
 	PromptUnitPrice     decimal.Decimal `json:"prompt_unit_price" validate:"required"`
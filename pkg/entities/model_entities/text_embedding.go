@@ -18,6 +18,14 @@ type TextEmbeddingResult struct {
 	Usage      EmbeddingUsage `json:"usage" validate:"required"`
 }
 
+// Tokens returns the total tokens used to embed the texts
+func (r TextEmbeddingResult) Tokens() int64 {
+	if r.Usage.TotalTokens == nil {
+		return 0
+	}
+	return int64(*r.Usage.TotalTokens)
+}
+
 type GetTextEmbeddingNumTokensResponse struct {
 	NumTokens []int `json:"num_tokens" validate:"required"`
 }
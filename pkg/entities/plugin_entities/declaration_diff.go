@@ -0,0 +1,478 @@
+package plugin_entities
+
+import (
+	"fmt"
+	"slices"
+)
+
+type DeclarationSection string
+
+const (
+	DECLARATION_SECTION_TOOLS            DeclarationSection = "tools"
+	DECLARATION_SECTION_PARAMETERS       DeclarationSection = "parameters"
+	DECLARATION_SECTION_SETTINGS         DeclarationSection = "settings"
+	DECLARATION_SECTION_PERMISSIONS      DeclarationSection = "permissions"
+	DECLARATION_SECTION_MODELS           DeclarationSection = "models"
+	DECLARATION_SECTION_AGENT_STRATEGIES DeclarationSection = "agent_strategies"
+	DECLARATION_SECTION_ENDPOINTS        DeclarationSection = "endpoints"
+)
+
+type DeclarationChangeKind string
+
+const (
+	DECLARATION_CHANGE_ADDED   DeclarationChangeKind = "added"
+	DECLARATION_CHANGE_REMOVED DeclarationChangeKind = "removed"
+	DECLARATION_CHANGE_CHANGED DeclarationChangeKind = "changed"
+)
+
+type DeclarationChangeSeverity string
+
+const (
+	// breaking changes fail apps, workflows or configurations working with the installed version
+	DECLARATION_CHANGE_BREAKING DeclarationChangeSeverity = "breaking"
+	// warnings need a review, e.g. permissions newly requested by the plugin
+	DECLARATION_CHANGE_WARNING DeclarationChangeSeverity = "warning"
+	DECLARATION_CHANGE_INFO    DeclarationChangeSeverity = "info"
+)
+
+type DeclarationChange struct {
+	Section DeclarationSection    `json:"section"`
+	Kind    DeclarationChangeKind `json:"kind"`
+	// Path locates the change in the declaration, like `tool.tools.search.parameters.query.type`
+	Path     string                    `json:"path"`
+	Before   any                       `json:"before,omitempty"`
+	After    any                       `json:"after,omitempty"`
+	Severity DeclarationChangeSeverity `json:"severity"`
+	Message  string                    `json:"message"`
+}
+
+// DeclarationDiff is what changes once a plugin is upgraded from a version to another
+type DeclarationDiff struct {
+	FromVersion string              `json:"from_version"`
+	ToVersion   string              `json:"to_version"`
+	Breaking    bool                `json:"breaking"`
+	Changes     []DeclarationChange `json:"changes"`
+}
+
+type declarationDiffer struct {
+	changes []DeclarationChange
+}
+
+func (d *declarationDiffer) add(change DeclarationChange) {
+	d.changes = append(d.changes, change)
+}
+
+// DiffDeclarations compares the declarations of two versions of a plugin
+func DiffDeclarations(from *PluginDeclaration, to *PluginDeclaration) DeclarationDiff {
+	d := &declarationDiffer{changes: []DeclarationChange{}}
+
+	d.diffTools(from.Tool, to.Tool)
+	d.diffModels(from.Model, to.Model)
+	d.diffAgentStrategies(from.AgentStrategy, to.AgentStrategy)
+	d.diffEndpoints(from.Endpoint, to.Endpoint)
+	d.diffPermissions(from.Resource.Permission, to.Resource.Permission)
+
+	diff := DeclarationDiff{
+		FromVersion: string(from.Version),
+		ToVersion:   string(to.Version),
+		Changes:     d.changes,
+	}
+	for _, change := range d.changes {
+		if change.Severity == DECLARATION_CHANGE_BREAKING {
+			diff.Breaking = true
+		}
+	}
+	return diff
+}
+
+// diffNamed reports items added to or removed from a list by their keys, items kept are passed to changed
+func diffNamed[T any](
+	d *declarationDiffer,
+	section DeclarationSection,
+	path string,
+	noun string,
+	removedSeverity DeclarationChangeSeverity,
+	before []T,
+	after []T,
+	key func(T) string,
+	changed func(path string, before T, after T),
+) {
+	afterByKey := map[string]T{}
+	for _, item := range after {
+		afterByKey[key(item)] = item
+	}
+	beforeKeys := map[string]bool{}
+
+	for _, item := range before {
+		name := key(item)
+		beforeKeys[name] = true
+		kept, ok := afterByKey[name]
+		if !ok {
+			d.add(DeclarationChange{
+				Section: section, Kind: DECLARATION_CHANGE_REMOVED, Path: path + "." + name, Before: name,
+				Severity: removedSeverity, Message: fmt.Sprintf("%s %s is removed", noun, name),
+			})
+			continue
+		}
+		if changed != nil {
+			changed(path+"."+name, item, kept)
+		}
+	}
+
+	for _, item := range after {
+		name := key(item)
+		if beforeKeys[name] {
+			continue
+		}
+		d.add(DeclarationChange{
+			Section: section, Kind: DECLARATION_CHANGE_ADDED, Path: path + "." + name, After: name,
+			Severity: DECLARATION_CHANGE_INFO, Message: fmt.Sprintf("%s %s is added", noun, name),
+		})
+	}
+}
+
+func (d *declarationDiffer) diffTools(from *ToolProviderDeclaration, to *ToolProviderDeclaration) {
+	if from == nil {
+		from = &ToolProviderDeclaration{}
+	}
+	if to == nil {
+		to = &ToolProviderDeclaration{}
+	}
+
+	diffNamed(d, DECLARATION_SECTION_TOOLS, "tool.tools", "tool", DECLARATION_CHANGE_BREAKING,
+		from.Tools, to.Tools,
+		func(tool ToolDeclaration) string { return tool.Identity.Name },
+		func(path string, before ToolDeclaration, after ToolDeclaration) {
+			d.diffFields(DECLARATION_SECTION_PARAMETERS, path+".parameters", "parameter",
+				toolParameterFields(before.Parameters), toolParameterFields(after.Parameters))
+		},
+	)
+	d.diffFields(DECLARATION_SECTION_SETTINGS, "tool.credentials_schema", "credential",
+		providerConfigFields(from.CredentialsSchema), providerConfigFields(to.CredentialsSchema))
+}
+
+func (d *declarationDiffer) diffModels(from *ModelProviderDeclaration, to *ModelProviderDeclaration) {
+	if from == nil {
+		from = &ModelProviderDeclaration{}
+	}
+	if to == nil {
+		to = &ModelProviderDeclaration{}
+	}
+
+	diffNamed(d, DECLARATION_SECTION_MODELS, "model.supported_model_types", "model type", DECLARATION_CHANGE_BREAKING,
+		from.SupportedModelTypes, to.SupportedModelTypes,
+		func(modelType ModelType) string { return string(modelType) },
+		nil,
+	)
+	diffNamed(d, DECLARATION_SECTION_MODELS, "model.models", "model", DECLARATION_CHANGE_BREAKING,
+		from.Models, to.Models,
+		func(model ModelDeclaration) string { return string(model.ModelType) + ":" + model.Model },
+		func(path string, before ModelDeclaration, after ModelDeclaration) {
+			if !before.Deprecated && after.Deprecated {
+				d.add(DeclarationChange{
+					Section: DECLARATION_SECTION_MODELS, Kind: DECLARATION_CHANGE_CHANGED, Path: path + ".deprecated",
+					Before: false, After: true, Severity: DECLARATION_CHANGE_WARNING,
+					Message: fmt.Sprintf("model %s is deprecated", after.Model),
+				})
+			}
+		},
+	)
+
+	var fromProvider, toProvider, fromModel, toModel []ModelProviderCredentialFormSchema
+	if from.ProviderCredentialSchema != nil {
+		fromProvider = from.ProviderCredentialSchema.CredentialFormSchemas
+	}
+	if to.ProviderCredentialSchema != nil {
+		toProvider = to.ProviderCredentialSchema.CredentialFormSchemas
+	}
+	if from.ModelCredentialSchema != nil {
+		fromModel = from.ModelCredentialSchema.CredentialFormSchemas
+	}
+	if to.ModelCredentialSchema != nil {
+		toModel = to.ModelCredentialSchema.CredentialFormSchemas
+	}
+	d.diffFields(DECLARATION_SECTION_SETTINGS, "model.provider_credential_schema", "credential",
+		credentialFormFields(fromProvider), credentialFormFields(toProvider))
+	d.diffFields(DECLARATION_SECTION_SETTINGS, "model.model_credential_schema", "credential",
+		credentialFormFields(fromModel), credentialFormFields(toModel))
+}
+
+func (d *declarationDiffer) diffAgentStrategies(
+	from *AgentStrategyProviderDeclaration,
+	to *AgentStrategyProviderDeclaration,
+) {
+	if from == nil {
+		from = &AgentStrategyProviderDeclaration{}
+	}
+	if to == nil {
+		to = &AgentStrategyProviderDeclaration{}
+	}
+
+	diffNamed(d, DECLARATION_SECTION_AGENT_STRATEGIES, "agent_strategy.strategies", "agent strategy",
+		DECLARATION_CHANGE_BREAKING, from.Strategies, to.Strategies,
+		func(strategy AgentStrategyDeclaration) string { return strategy.Identity.Name },
+		func(path string, before AgentStrategyDeclaration, after AgentStrategyDeclaration) {
+			d.diffFields(DECLARATION_SECTION_PARAMETERS, path+".parameters", "parameter",
+				agentStrategyParameterFields(before.Parameters), agentStrategyParameterFields(after.Parameters))
+		},
+	)
+}
+
+func (d *declarationDiffer) diffEndpoints(from *EndpointProviderDeclaration, to *EndpointProviderDeclaration) {
+	if from == nil {
+		from = &EndpointProviderDeclaration{}
+	}
+	if to == nil {
+		to = &EndpointProviderDeclaration{}
+	}
+
+	diffNamed(d, DECLARATION_SECTION_ENDPOINTS, "endpoint.endpoints", "endpoint", DECLARATION_CHANGE_BREAKING,
+		from.Endpoints, to.Endpoints,
+		func(endpoint EndpointDeclaration) string { return string(endpoint.Method) + " " + endpoint.Path },
+		nil,
+	)
+	d.diffFields(DECLARATION_SECTION_SETTINGS, "endpoint.settings", "setting",
+		providerConfigFields(from.Settings), providerConfigFields(to.Settings))
+}
+
+// declarationField is a parameter of tools and agent strategies, or a field of settings and credentials
+type declarationField struct {
+	name       string
+	fieldType  string
+	form       string
+	required   bool
+	hasDefault bool
+	options    []string
+}
+
+func toolParameterFields(parameters []ToolParameter) []declarationField {
+	fields := make([]declarationField, 0, len(parameters))
+	for _, parameter := range parameters {
+		field := declarationField{
+			name:       parameter.Name,
+			fieldType:  string(parameter.Type),
+			form:       string(parameter.Form),
+			required:   parameter.Required,
+			hasDefault: parameter.Default != nil,
+		}
+		for _, option := range parameter.Options {
+			field.options = append(field.options, option.Value)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func agentStrategyParameterFields(parameters []AgentStrategyParameter) []declarationField {
+	fields := make([]declarationField, 0, len(parameters))
+	for _, parameter := range parameters {
+		field := declarationField{
+			name:       parameter.Name,
+			fieldType:  string(parameter.Type),
+			required:   parameter.Required,
+			hasDefault: parameter.Default != nil,
+		}
+		for _, option := range parameter.Options {
+			field.options = append(field.options, option.Value)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func providerConfigFields(configs []ProviderConfig) []declarationField {
+	fields := make([]declarationField, 0, len(configs))
+	for _, config := range configs {
+		field := declarationField{
+			name:       config.Name,
+			fieldType:  string(config.Type),
+			required:   config.Required,
+			hasDefault: config.Default != nil,
+		}
+		for _, option := range config.Options {
+			field.options = append(field.options, option.Value)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func credentialFormFields(schemas []ModelProviderCredentialFormSchema) []declarationField {
+	fields := make([]declarationField, 0, len(schemas))
+	for _, schema := range schemas {
+		field := declarationField{
+			name:       schema.Variable,
+			fieldType:  string(schema.Type),
+			required:   schema.Required,
+			hasDefault: schema.Default != nil,
+		}
+		for _, option := range schema.Options {
+			field.options = append(field.options, option.Value)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// diffFields compares parameters or fields of settings, parameters removed break apps passing them
+// while settings removed are ignored, fields newly required without a default break existing configurations
+func (d *declarationDiffer) diffFields(
+	section DeclarationSection,
+	path string,
+	noun string,
+	before []declarationField,
+	after []declarationField,
+) {
+	removedSeverity := DECLARATION_CHANGE_INFO
+	if section == DECLARATION_SECTION_PARAMETERS {
+		removedSeverity = DECLARATION_CHANGE_BREAKING
+	}
+
+	afterByName := map[string]declarationField{}
+	for _, field := range after {
+		afterByName[field.name] = field
+	}
+	beforeNames := map[string]bool{}
+
+	for _, field := range before {
+		beforeNames[field.name] = true
+		fieldPath := path + "." + field.name
+		kept, ok := afterByName[field.name]
+		if !ok {
+			d.add(DeclarationChange{
+				Section: section, Kind: DECLARATION_CHANGE_REMOVED, Path: fieldPath, Before: field.name,
+				Severity: removedSeverity, Message: fmt.Sprintf("%s %s is removed", noun, field.name),
+			})
+			continue
+		}
+
+		if field.fieldType != kept.fieldType {
+			d.add(DeclarationChange{
+				Section: section, Kind: DECLARATION_CHANGE_CHANGED, Path: fieldPath + ".type",
+				Before: field.fieldType, After: kept.fieldType, Severity: DECLARATION_CHANGE_BREAKING,
+				Message: fmt.Sprintf("type of %s %s changes from %s to %s", noun, field.name, field.fieldType, kept.fieldType),
+			})
+		}
+		if field.form != kept.form {
+			d.add(DeclarationChange{
+				Section: section, Kind: DECLARATION_CHANGE_CHANGED, Path: fieldPath + ".form",
+				Before: field.form, After: kept.form, Severity: DECLARATION_CHANGE_BREAKING,
+				Message: fmt.Sprintf("form of %s %s changes from %s to %s", noun, field.name, field.form, kept.form),
+			})
+		}
+		if field.required != kept.required {
+			severity := DECLARATION_CHANGE_INFO
+			if kept.required && !kept.hasDefault {
+				severity = DECLARATION_CHANGE_BREAKING
+			}
+			d.add(DeclarationChange{
+				Section: section, Kind: DECLARATION_CHANGE_CHANGED, Path: fieldPath + ".required",
+				Before: field.required, After: kept.required, Severity: severity,
+				Message: fmt.Sprintf("%s %s becomes %s", noun, field.name, map[bool]string{true: "required", false: "optional"}[kept.required]),
+			})
+		}
+		for _, option := range field.options {
+			if !slices.Contains(kept.options, option) {
+				d.add(DeclarationChange{
+					Section: section, Kind: DECLARATION_CHANGE_REMOVED, Path: fieldPath + ".options." + option,
+					Before: option, Severity: DECLARATION_CHANGE_BREAKING,
+					Message: fmt.Sprintf("option %s of %s %s is removed", option, noun, field.name),
+				})
+			}
+		}
+		for _, option := range kept.options {
+			if !slices.Contains(field.options, option) {
+				d.add(DeclarationChange{
+					Section: section, Kind: DECLARATION_CHANGE_ADDED, Path: fieldPath + ".options." + option,
+					After: option, Severity: DECLARATION_CHANGE_INFO,
+					Message: fmt.Sprintf("option %s of %s %s is added", option, noun, field.name),
+				})
+			}
+		}
+	}
+
+	for _, field := range after {
+		if beforeNames[field.name] {
+			continue
+		}
+		severity := DECLARATION_CHANGE_INFO
+		message := fmt.Sprintf("%s %s is added", noun, field.name)
+		if field.required && !field.hasDefault {
+			severity = DECLARATION_CHANGE_BREAKING
+			message = fmt.Sprintf("required %s %s is added without a default", noun, field.name)
+		}
+		d.add(DeclarationChange{
+			Section: section, Kind: DECLARATION_CHANGE_ADDED, Path: path + "." + field.name, After: field.name,
+			Severity: severity, Message: message,
+		})
+	}
+}
+
+// permissionGrants flattens the permissions into the ones able to be granted, like `model.llm`
+func permissionGrants(p *PluginPermissionRequirement) map[string]bool {
+	return map[string]bool{
+		"tool":                 p.AllowInvokeTool(),
+		"model":                p.AllowInvokeModel(),
+		"model.llm":            p.AllowInvokeLLM(),
+		"model.text_embedding": p.AllowInvokeTextEmbedding(),
+		"model.rerank":         p.AllowInvokeRerank(),
+		"model.tts":            p.AllowInvokeTTS(),
+		"model.speech2text":    p.AllowInvokeSpeech2Text(),
+		"model.moderation":     p.AllowInvokeModeration(),
+		"node":                 p.AllowInvokeNode(),
+		"app":                  p.AllowInvokeApp(),
+		"endpoint":             p.AllowRegisterEndpoint(),
+		"storage":              p != nil && p.Storage != nil && p.Storage.Enabled,
+		"network":              p != nil && p.Network != nil && p.Network.Enabled,
+	}
+}
+
+// permissionOrder keeps changes of permissions in a stable order
+var permissionOrder = []string{
+	"tool", "model", "model.llm", "model.text_embedding", "model.rerank", "model.tts", "model.speech2text",
+	"model.moderation", "node", "app", "endpoint", "storage", "network",
+}
+
+// diffPermissions warns about permissions newly requested, which administrators should review before upgrading
+func (d *declarationDiffer) diffPermissions(from *PluginPermissionRequirement, to *PluginPermissionRequirement) {
+	before, after := permissionGrants(from), permissionGrants(to)
+	for _, permission := range permissionOrder {
+		path := "resource.permission." + permission
+		switch {
+		case !before[permission] && after[permission]:
+			d.add(DeclarationChange{
+				Section: DECLARATION_SECTION_PERMISSIONS, Kind: DECLARATION_CHANGE_ADDED, Path: path, After: true,
+				Severity: DECLARATION_CHANGE_WARNING, Message: fmt.Sprintf("permission %s is requested", permission),
+			})
+		case before[permission] && !after[permission]:
+			d.add(DeclarationChange{
+				Section: DECLARATION_SECTION_PERMISSIONS, Kind: DECLARATION_CHANGE_REMOVED, Path: path, Before: true,
+				Severity: DECLARATION_CHANGE_INFO, Message: fmt.Sprintf("permission %s is no longer requested", permission),
+			})
+		}
+	}
+
+	if before["storage"] && after["storage"] && to.Storage.Size > from.Storage.Size {
+		d.add(DeclarationChange{
+			Section: DECLARATION_SECTION_PERMISSIONS, Kind: DECLARATION_CHANGE_CHANGED, Path: "resource.permission.storage.size",
+			Before: from.Storage.Size, After: to.Storage.Size, Severity: DECLARATION_CHANGE_WARNING,
+			Message: fmt.Sprintf("storage requested grows from %d to %d bytes", from.Storage.Size, to.Storage.Size),
+		})
+	}
+
+	if before["network"] && after["network"] {
+		diffNamed(d, DECLARATION_SECTION_PERMISSIONS, "resource.permission.network.domains", "network domain",
+			DECLARATION_CHANGE_INFO, from.Network.Domains, to.Network.Domains,
+			func(domain string) string { return domain },
+			nil,
+		)
+		// domains newly reachable by the plugin need a review as well
+		for i := range d.changes {
+			change := &d.changes[i]
+			if change.Section == DECLARATION_SECTION_PERMISSIONS && change.Kind == DECLARATION_CHANGE_ADDED &&
+				change.Path == "resource.permission.network.domains."+fmt.Sprint(change.After) {
+				change.Severity = DECLARATION_CHANGE_WARNING
+			}
+		}
+	}
+}
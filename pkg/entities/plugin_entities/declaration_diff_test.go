@@ -0,0 +1,96 @@
+package plugin_entities
+
+import (
+	"testing"
+)
+
+func TestDiffDeclarations(t *testing.T) {
+	from := &PluginDeclaration{
+		PluginDeclarationWithoutAdvancedFields: PluginDeclarationWithoutAdvancedFields{
+			Version: "0.0.1",
+			Resource: PluginResourceRequirement{Permission: &PluginPermissionRequirement{
+				Tool:    &PluginPermissionToolRequirement{Enabled: true},
+				Network: &PluginPermissionNetworkRequirement{Enabled: true, Domains: []string{"api.example.com"}},
+			}},
+		},
+		Tool: &ToolProviderDeclaration{
+			CredentialsSchema: []ProviderConfig{{Name: "api_key", Type: CONFIG_TYPE_SECRET_INPUT, Required: true}},
+			Tools: []ToolDeclaration{
+				{
+					Identity: ToolIdentity{Name: "search"},
+					Parameters: []ToolParameter{
+						{Name: "query", Type: TOOL_PARAMETER_TYPE_STRING, Form: TOOL_PARAMETER_FORM_LLM, Required: true},
+						{Name: "limit", Type: TOOL_PARAMETER_TYPE_STRING, Form: TOOL_PARAMETER_FORM_FORM},
+						{Name: "engine", Type: TOOL_PARAMETER_TYPE_SELECT, Form: TOOL_PARAMETER_FORM_FORM, Options: []ToolParameterOption{
+							{Value: "google"}, {Value: "bing"},
+						}},
+					},
+				},
+				{Identity: ToolIdentity{Name: "fetch"}},
+			},
+		},
+	}
+	to := &PluginDeclaration{
+		PluginDeclarationWithoutAdvancedFields: PluginDeclarationWithoutAdvancedFields{
+			Version: "0.1.0",
+			Resource: PluginResourceRequirement{Permission: &PluginPermissionRequirement{
+				Tool:    &PluginPermissionToolRequirement{Enabled: true},
+				Storage: &PluginPermissionStorageRequirement{Enabled: true, Size: 1024},
+				Network: &PluginPermissionNetworkRequirement{Enabled: true, Domains: []string{"api.example.com", "cdn.example.com"}},
+			}},
+		},
+		Tool: &ToolProviderDeclaration{
+			CredentialsSchema: []ProviderConfig{
+				{Name: "api_key", Type: CONFIG_TYPE_SECRET_INPUT, Required: true},
+				{Name: "region", Type: CONFIG_TYPE_TEXT_INPUT, Required: true, Default: "us"},
+			},
+			Tools: []ToolDeclaration{
+				{
+					Identity: ToolIdentity{Name: "search"},
+					Parameters: []ToolParameter{
+						{Name: "query", Type: TOOL_PARAMETER_TYPE_STRING, Form: TOOL_PARAMETER_FORM_LLM, Required: true},
+						{Name: "limit", Type: TOOL_PARAMETER_TYPE_NUMBER, Form: TOOL_PARAMETER_FORM_FORM},
+						{Name: "engine", Type: TOOL_PARAMETER_TYPE_SELECT, Form: TOOL_PARAMETER_FORM_FORM, Options: []ToolParameterOption{
+							{Value: "google"}, {Value: "duckduckgo"},
+						}},
+						{Name: "language", Type: TOOL_PARAMETER_TYPE_STRING, Form: TOOL_PARAMETER_FORM_FORM, Required: true},
+					},
+				},
+				{Identity: ToolIdentity{Name: "summarize"}},
+			},
+		},
+	}
+
+	diff := DiffDeclarations(from, to)
+	if diff.FromVersion != "0.0.1" || diff.ToVersion != "0.1.0" || !diff.Breaking {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+
+	expected := map[string]DeclarationChangeSeverity{
+		"tool.tools.fetch":                                       DECLARATION_CHANGE_BREAKING,
+		"tool.tools.summarize":                                   DECLARATION_CHANGE_INFO,
+		"tool.tools.search.parameters.limit.type":                DECLARATION_CHANGE_BREAKING,
+		"tool.tools.search.parameters.engine.options.bing":       DECLARATION_CHANGE_BREAKING,
+		"tool.tools.search.parameters.engine.options.duckduckgo": DECLARATION_CHANGE_INFO,
+		"tool.tools.search.parameters.language":                  DECLARATION_CHANGE_BREAKING,
+		"tool.credentials_schema.region":                         DECLARATION_CHANGE_INFO,
+		"resource.permission.storage":                            DECLARATION_CHANGE_WARNING,
+		"resource.permission.network.domains.cdn.example.com":    DECLARATION_CHANGE_WARNING,
+	}
+	if len(diff.Changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), diff.Changes)
+	}
+	for _, change := range diff.Changes {
+		severity, ok := expected[change.Path]
+		if !ok {
+			t.Fatalf("unexpected change %+v", change)
+		}
+		if change.Severity != severity {
+			t.Fatalf("expected %s to be %s, got %s", change.Path, severity, change.Severity)
+		}
+	}
+
+	if diff := DiffDeclarations(from, from); diff.Breaking || len(diff.Changes) != 0 {
+		t.Fatalf("same declarations should have no changes, got %+v", diff.Changes)
+	}
+}
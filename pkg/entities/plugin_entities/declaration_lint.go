@@ -0,0 +1,222 @@
+package plugin_entities
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type DeclarationLintRule string
+
+const (
+	// labels or descriptions without en_US, or without a language the label of the plugin is translated into
+	DECLARATION_LINT_MISSING_I18N DeclarationLintRule = "missing_i18n"
+	// tools, parameters, models, strategies or endpoints declared more than once, only one of them is served
+	DECLARATION_LINT_DUPLICATE_IDENTITY DeclarationLintRule = "duplicate_identity"
+	// permissions granting more than the plugin is likely to need, e.g. network access to any host
+	DECLARATION_LINT_BROAD_PERMISSION DeclarationLintRule = "broad_permission"
+	// the plugin or its providers have no icon to be shown in the marketplace and the console
+	DECLARATION_LINT_MISSING_ICON DeclarationLintRule = "missing_icon"
+)
+
+// storage beyond it is considered broad, plugins keep caches or small files in it
+const DECLARATION_LINT_BROAD_STORAGE_SIZE = 512 * 1024 * 1024
+
+// DeclarationWarning is a problem of a declaration which is valid against the schema, it never blocks
+// packing or installing the plugin but should be fixed by its author
+type DeclarationWarning struct {
+	Rule DeclarationLintRule `json:"rule"`
+	// Path locates the problem in the declaration, like `tool.tools.search.parameters.query.label`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type declarationLinter struct {
+	// languages the label of the plugin is translated into besides en_US
+	languages []string
+	warnings  []DeclarationWarning
+}
+
+func (l *declarationLinter) warn(rule DeclarationLintRule, path string, format string, v ...any) {
+	l.warnings = append(l.warnings, DeclarationWarning{Rule: rule, Path: path, Message: fmt.Sprintf(format, v...)})
+}
+
+// ValidateDeclaration checks the declaration for common problems beyond the schema, warnings are
+// ordered by the sections of the declaration
+func ValidateDeclaration(declaration *PluginDeclaration) []DeclarationWarning {
+	l := &declarationLinter{
+		languages: i18nLanguages(declaration.Label),
+		warnings:  []DeclarationWarning{},
+	}
+
+	l.lintI18n("label", declaration.Label)
+	l.lintI18n("description", declaration.Description)
+	if declaration.Icon == "" {
+		l.warn(DECLARATION_LINT_MISSING_ICON, "icon", "the plugin has no icon")
+	}
+
+	l.lintTools(declaration.Tool)
+	l.lintModels(declaration.Model)
+	l.lintAgentStrategies(declaration.AgentStrategy)
+	l.lintEndpoints(declaration.Endpoint)
+	l.lintPermissions(declaration.Resource.Permission)
+	return l.warnings
+}
+
+func i18nLanguages(object I18nObject) []string {
+	languages := []string{}
+	for _, locale := range []string{I18N_LOCALE_ZH_HANS, I18N_LOCALE_JA_JP, I18N_LOCALE_PT_BR} {
+		if object.Get(locale) != "" {
+			languages = append(languages, locale)
+		}
+	}
+	return languages
+}
+
+func (l *declarationLinter) lintI18n(path string, object I18nObject) {
+	if object.EnUS == "" {
+		l.warn(DECLARATION_LINT_MISSING_I18N, path, "en_US is missing, it's shown when no other language matches")
+		return
+	}
+
+	provided := i18nLanguages(object)
+	missing := []string{}
+	for _, language := range l.languages {
+		if !slices.Contains(provided, language) {
+			missing = append(missing, language)
+		}
+	}
+	if len(missing) > 0 {
+		l.warn(
+			DECLARATION_LINT_MISSING_I18N, path,
+			"%s is missing while the label of the plugin is translated into it, en_US is shown instead",
+			strings.Join(missing, ", "),
+		)
+	}
+}
+
+// lintDuplicates warns about names declared more than once
+func lintDuplicates[T any](l *declarationLinter, path string, kind string, items []T, name func(T) string) {
+	seen := map[string]bool{}
+	reported := map[string]bool{}
+	for _, item := range items {
+		n := name(item)
+		if seen[n] && !reported[n] {
+			reported[n] = true
+			l.warn(DECLARATION_LINT_DUPLICATE_IDENTITY, path+"."+n, "%s %s is declared more than once", kind, n)
+		}
+		seen[n] = true
+	}
+}
+
+func (l *declarationLinter) lintTools(tool *ToolProviderDeclaration) {
+	if tool == nil {
+		return
+	}
+
+	l.lintI18n("tool.identity.label", tool.Identity.Label)
+	if tool.Identity.Icon == "" {
+		l.warn(DECLARATION_LINT_MISSING_ICON, "tool.identity.icon", "the tool provider has no icon")
+	}
+
+	lintDuplicates(l, "tool.tools", "tool", tool.Tools, func(t ToolDeclaration) string { return t.Identity.Name })
+	for _, t := range tool.Tools {
+		path := "tool.tools." + t.Identity.Name
+		l.lintI18n(path+".label", t.Identity.Label)
+		l.lintI18n(path+".description.human", t.Description.Human)
+
+		lintDuplicates(l, path+".parameters", "parameter", t.Parameters, func(p ToolParameter) string { return p.Name })
+		for _, parameter := range t.Parameters {
+			l.lintI18n(path+".parameters."+parameter.Name+".label", parameter.Label)
+		}
+	}
+}
+
+func (l *declarationLinter) lintModels(model *ModelProviderDeclaration) {
+	if model == nil {
+		return
+	}
+
+	l.lintI18n("model.label", model.Label)
+	if model.IconSmall == nil || model.IconSmall.EnUS == "" {
+		l.warn(DECLARATION_LINT_MISSING_ICON, "model.icon_small", "the model provider has no icon")
+	}
+
+	// models of different types may share a name
+	lintDuplicates(l, "model.models", "model", model.Models, func(m ModelDeclaration) string {
+		return string(m.ModelType) + "." + m.Model
+	})
+	for _, m := range model.Models {
+		l.lintI18n("model.models."+string(m.ModelType)+"."+m.Model+".label", m.Label)
+	}
+}
+
+func (l *declarationLinter) lintAgentStrategies(agent *AgentStrategyProviderDeclaration) {
+	if agent == nil {
+		return
+	}
+
+	l.lintI18n("agent_strategy.identity.label", agent.Identity.Label)
+	if agent.Identity.Icon == "" {
+		l.warn(DECLARATION_LINT_MISSING_ICON, "agent_strategy.identity.icon", "the agent strategy provider has no icon")
+	}
+
+	lintDuplicates(l, "agent_strategy.strategies", "strategy", agent.Strategies, func(s AgentStrategyDeclaration) string {
+		return s.Identity.Name
+	})
+	for _, s := range agent.Strategies {
+		path := "agent_strategy.strategies." + s.Identity.Name
+		l.lintI18n(path+".label", s.Identity.Label)
+		l.lintI18n(path+".description", s.Description)
+	}
+}
+
+func (l *declarationLinter) lintEndpoints(endpoint *EndpointProviderDeclaration) {
+	if endpoint == nil {
+		return
+	}
+
+	lintDuplicates(l, "endpoint.endpoints", "endpoint", endpoint.Endpoints, func(e EndpointDeclaration) string {
+		return string(e.Method) + " " + e.Path
+	})
+}
+
+func (l *declarationLinter) lintPermissions(permission *PluginPermissionRequirement) {
+	if permission == nil {
+		return
+	}
+
+	if network := permission.Network; network != nil && network.Enabled {
+		if len(network.Domains) == 0 {
+			l.warn(
+				DECLARATION_LINT_BROAD_PERMISSION, "resource.permission.network.domains",
+				"the plugin may connect to any host, list the domains it connects to",
+			)
+		}
+		for _, domain := range network.Domains {
+			if isBroadDomain(domain) {
+				l.warn(
+					DECLARATION_LINT_BROAD_PERMISSION, "resource.permission.network.domains",
+					"%s matches hosts of too many sites, list the domains the plugin connects to", domain,
+				)
+			}
+		}
+	}
+
+	if storage := permission.Storage; storage != nil && storage.Enabled && storage.Size > DECLARATION_LINT_BROAD_STORAGE_SIZE {
+		l.warn(
+			DECLARATION_LINT_BROAD_PERMISSION, "resource.permission.storage.size",
+			"%d bytes of storage is requested, plugins rarely need more than %d",
+			storage.Size, DECLARATION_LINT_BROAD_STORAGE_SIZE,
+		)
+	}
+}
+
+// isBroadDomain tells if the domain is a wildcard of a top level domain like `*` or `*.com`
+func isBroadDomain(domain string) bool {
+	if domain == "*" {
+		return true
+	}
+	rest, ok := strings.CutPrefix(domain, "*.")
+	return ok && !strings.Contains(rest, ".")
+}
@@ -0,0 +1,88 @@
+package plugin_entities
+
+import (
+	"testing"
+)
+
+func TestValidateDeclaration(t *testing.T) {
+	declaration := &PluginDeclaration{
+		PluginDeclarationWithoutAdvancedFields: PluginDeclarationWithoutAdvancedFields{
+			Label:       I18nObject{EnUS: "Search", ZhHans: "搜索"},
+			Description: I18nObject{EnUS: "Search the web", ZhHans: "搜索网页"},
+			Icon:        "icon.svg",
+			Resource: PluginResourceRequirement{Permission: &PluginPermissionRequirement{
+				Storage: &PluginPermissionStorageRequirement{Enabled: true, Size: 1024 * 1024 * 1024},
+				Network: &PluginPermissionNetworkRequirement{Enabled: true, Domains: []string{"api.example.com", "*.com"}},
+			}},
+		},
+		Tool: &ToolProviderDeclaration{
+			Identity: ToolProviderIdentity{Label: I18nObject{EnUS: "Search", ZhHans: "搜索"}},
+			Tools: []ToolDeclaration{
+				{
+					Identity:    ToolIdentity{Name: "search", Label: I18nObject{EnUS: "Search", ZhHans: "搜索"}},
+					Description: ToolDescription{Human: I18nObject{EnUS: "Search the web", ZhHans: "搜索网页"}},
+					Parameters: []ToolParameter{
+						{Name: "query", Label: I18nObject{EnUS: "Query"}},
+						{Name: "query", Label: I18nObject{EnUS: "Query", ZhHans: "查询"}},
+					},
+				},
+				{
+					Identity:    ToolIdentity{Name: "search", Label: I18nObject{ZhHans: "搜索"}},
+					Description: ToolDescription{Human: I18nObject{EnUS: "Search the web", ZhHans: "搜索网页"}},
+				},
+			},
+		},
+	}
+
+	warnings := ValidateDeclaration(declaration)
+
+	expected := []DeclarationWarning{
+		{Rule: DECLARATION_LINT_MISSING_ICON, Path: "tool.identity.icon"},
+		{Rule: DECLARATION_LINT_DUPLICATE_IDENTITY, Path: "tool.tools.search"},
+		{Rule: DECLARATION_LINT_DUPLICATE_IDENTITY, Path: "tool.tools.search.parameters.query"},
+		{Rule: DECLARATION_LINT_MISSING_I18N, Path: "tool.tools.search.parameters.query.label"},
+		{Rule: DECLARATION_LINT_MISSING_I18N, Path: "tool.tools.search.label"},
+		{Rule: DECLARATION_LINT_BROAD_PERMISSION, Path: "resource.permission.network.domains"},
+		{Rule: DECLARATION_LINT_BROAD_PERMISSION, Path: "resource.permission.storage.size"},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("expected %d warnings, got %d: %+v", len(expected), len(warnings), warnings)
+	}
+	for i, warning := range warnings {
+		if warning.Rule != expected[i].Rule || warning.Path != expected[i].Path {
+			t.Errorf("expected %s at %s, got %+v", expected[i].Rule, expected[i].Path, warning)
+		}
+		if warning.Message == "" {
+			t.Errorf("expected a message of %+v", warning)
+		}
+	}
+}
+
+func TestValidateDeclarationNetwork(t *testing.T) {
+	lint := func(network *PluginPermissionNetworkRequirement) []DeclarationWarning {
+		return ValidateDeclaration(&PluginDeclaration{
+			PluginDeclarationWithoutAdvancedFields: PluginDeclarationWithoutAdvancedFields{
+				Label:       I18nObject{EnUS: "Fetch"},
+				Description: I18nObject{EnUS: "Fetch pages"},
+				Icon:        "icon.svg",
+				Resource:    PluginResourceRequirement{Permission: &PluginPermissionRequirement{Network: network}},
+			},
+		})
+	}
+
+	if warnings := lint(&PluginPermissionNetworkRequirement{Enabled: true}); len(warnings) != 1 ||
+		warnings[0].Rule != DECLARATION_LINT_BROAD_PERMISSION {
+		t.Errorf("expected network access to any host to be broad, got %+v", warnings)
+	}
+	if warnings := lint(&PluginPermissionNetworkRequirement{Enabled: true, Domains: []string{"*"}}); len(warnings) != 1 {
+		t.Errorf("expected * to be broad, got %+v", warnings)
+	}
+	if warnings := lint(&PluginPermissionNetworkRequirement{
+		Enabled: true, Domains: []string{"*.example.com", "api.example.com"},
+	}); len(warnings) != 0 {
+		t.Errorf("expected subdomains of a site not to be broad, got %+v", warnings)
+	}
+	if warnings := lint(&PluginPermissionNetworkRequirement{Enabled: false}); len(warnings) != 0 {
+		t.Errorf("expected disabled network not to be linted, got %+v", warnings)
+	}
+}
@@ -15,15 +15,17 @@ type PluginUniversalEvent struct {
 
 // ParsePluginUniversalEvent parses bytes into struct contains basic info of a message
 // it's the outermost layer of the protocol
-// error_handler will be called when data is not standard or itself it's an error message
+// error_handler will be called when data is not standard or itself it's an error message,
+// log_handler is called with logs written by the plugin, along with the session they're written in if any,
+// the error is returned only if data is not standard
 func ParsePluginUniversalEvent(
 	data []byte,
 	statusText string,
 	sessionHandler func(sessionId string, data []byte),
 	heartbeatHandler func(),
 	errorHandler func(err string),
-	infoHandler func(message string),
-) {
+	logHandler func(sessionId string, event PluginLogEvent),
+) error {
 	// handle event
 	event, err := parser.UnmarshalJsonBytes[PluginUniversalEvent](data)
 	if err != nil {
@@ -32,7 +34,7 @@ func ParsePluginUniversalEvent(
 		} else {
 			errorHandler(err.Error() + " status: " + statusText + " original response: " + string(data))
 		}
-		return
+		return err
 	}
 
 	sessionId := event.SessionId
@@ -45,10 +47,10 @@ func ParsePluginUniversalEvent(
 			)
 			if err != nil {
 				log.Error("unmarshal json failed: %s", err.Error())
-				return
+				return err
 			}
 
-			infoHandler(logEvent.Message)
+			logHandler(sessionId, logEvent)
 		}
 	case PLUGIN_EVENT_SESSION:
 		sessionHandler(sessionId, event.Data)
@@ -57,6 +59,8 @@ func ParsePluginUniversalEvent(
 	case PLUGIN_EVENT_HEARTBEAT:
 		heartbeatHandler()
 	}
+
+	return nil
 }
 
 type PluginEventType string
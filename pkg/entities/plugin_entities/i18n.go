@@ -0,0 +1,161 @@
+package plugin_entities
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// locales of I18nObject, en_US is the one every object has
+const (
+	I18N_LOCALE_EN_US   = "en_US"
+	I18N_LOCALE_ZH_HANS = "zh_Hans"
+	I18N_LOCALE_JA_JP   = "ja_JP"
+	I18N_LOCALE_PT_BR   = "pt_BR"
+)
+
+// i18nFallbacks are tried once none of the requested locales is translated
+var i18nFallbacks = []string{I18N_LOCALE_EN_US, I18N_LOCALE_ZH_HANS, I18N_LOCALE_JA_JP, I18N_LOCALE_PT_BR}
+
+// i18nLanguageLocales map primary subtags of language tags to locales, regional variants of a language
+// fall back to the locale of it, e.g. zh-TW to zh_Hans and pt-PT to pt_BR
+var i18nLanguageLocales = map[string]string{
+	"en": I18N_LOCALE_EN_US,
+	"zh": I18N_LOCALE_ZH_HANS,
+	"ja": I18N_LOCALE_JA_JP,
+	"pt": I18N_LOCALE_PT_BR,
+}
+
+// Get returns the translation of the locale, empty if it's not translated or the locale is unknown
+func (i I18nObject) Get(locale string) string {
+	switch locale {
+	case I18N_LOCALE_EN_US:
+		return i.EnUS
+	case I18N_LOCALE_ZH_HANS:
+		return i.ZhHans
+	case I18N_LOCALE_JA_JP:
+		return i.JaJp
+	case I18N_LOCALE_PT_BR:
+		return i.PtBr
+	}
+	return ""
+}
+
+// Resolve returns the translation of the first requested locale it has, en_US and then any other
+// translation are the fallbacks
+func (i I18nObject) Resolve(locales []string) string {
+	for _, locale := range locales {
+		if value := i.Get(locale); value != "" {
+			return value
+		}
+	}
+	for _, locale := range i18nFallbacks {
+		if value := i.Get(locale); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ParseLocales parses a locale like `zh_Hans` or an Accept-Language header like `zh-CN,zh;q=0.9,en;q=0.8`
+// into the locales of I18nObject in the order of preference, unknown languages are skipped
+func ParseLocales(acceptLanguage string) []string {
+	type weighted struct {
+		locale  string
+		quality float64
+	}
+
+	candidates := []weighted{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		language, _, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+		locale, ok := i18nLanguageLocales[strings.ToLower(language)]
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, weighted{locale: locale, quality: quality})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+
+	locales := []string{}
+	for _, candidate := range candidates {
+		if !slices.Contains(locales, candidate.locale) {
+			locales = append(locales, candidate.locale)
+		}
+	}
+	return locales
+}
+
+// LocalizeI18n returns the json form of data with every I18nObject in it resolved to the translation
+// of the locales, so clients get the strings of their language instead of all the translations
+func LocalizeI18n(data any, locales []string) (any, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var document any
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		return nil, err
+	}
+	return localizeValue(document, locales), nil
+}
+
+func localizeValue(value any, locales []string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		if object, ok := asI18nObject(v); ok {
+			return object.Resolve(locales)
+		}
+		for key, item := range v {
+			v[key] = localizeValue(item, locales)
+		}
+	case []any:
+		for index, item := range v {
+			v[index] = localizeValue(item, locales)
+		}
+	}
+	return value
+}
+
+// asI18nObject tells if the json object is an I18nObject, which has en_US and nothing but translations
+func asI18nObject(object map[string]any) (I18nObject, bool) {
+	if _, ok := object[I18N_LOCALE_EN_US]; !ok {
+		return I18nObject{}, false
+	}
+
+	result := I18nObject{}
+	for key, value := range object {
+		translation, ok := value.(string)
+		if !ok {
+			return I18nObject{}, false
+		}
+		switch key {
+		case I18N_LOCALE_EN_US:
+			result.EnUS = translation
+		case I18N_LOCALE_ZH_HANS:
+			result.ZhHans = translation
+		case I18N_LOCALE_JA_JP:
+			result.JaJp = translation
+		case I18N_LOCALE_PT_BR:
+			result.PtBr = translation
+		default:
+			return I18nObject{}, false
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,73 @@
+package plugin_entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLocales(t *testing.T) {
+	cases := map[string][]string{
+		"zh_Hans":                       {I18N_LOCALE_ZH_HANS},
+		"ja-JP":                         {I18N_LOCALE_JA_JP},
+		"zh-CN,zh;q=0.9,en;q=0.8":       {I18N_LOCALE_ZH_HANS, I18N_LOCALE_EN_US},
+		"en;q=0.5, pt-PT;q=0.8, fr":     {I18N_LOCALE_PT_BR, I18N_LOCALE_EN_US},
+		"ja;q=0, en-GB":                 {I18N_LOCALE_EN_US},
+		"de-DE, *;q=0.1, zh-TW;q=bogus": {},
+	}
+	for header, expected := range cases {
+		if locales := ParseLocales(header); !reflect.DeepEqual(locales, expected) {
+			t.Errorf("expected %v of %q, got %v", expected, header, locales)
+		}
+	}
+}
+
+func TestI18nObjectResolve(t *testing.T) {
+	object := I18nObject{EnUS: "Search", ZhHans: "搜索"}
+	if value := object.Resolve([]string{I18N_LOCALE_JA_JP, I18N_LOCALE_ZH_HANS}); value != "搜索" {
+		t.Errorf("expected the first translated locale, got %s", value)
+	}
+	if value := object.Resolve([]string{I18N_LOCALE_PT_BR}); value != "Search" {
+		t.Errorf("expected en_US to be the fallback, got %s", value)
+	}
+	if value := (I18nObject{JaJp: "検索"}).Resolve(nil); value != "検索" {
+		t.Errorf("expected any translation once en_US is missing, got %s", value)
+	}
+}
+
+func TestLocalizeI18n(t *testing.T) {
+	declaration := &ToolProviderDeclaration{
+		Identity: ToolProviderIdentity{
+			Name:  "search",
+			Label: I18nObject{EnUS: "Search", ZhHans: "搜索"},
+		},
+		Tools: []ToolDeclaration{{
+			Identity:    ToolIdentity{Name: "web", Label: I18nObject{EnUS: "Web", ZhHans: "网页"}},
+			Description: ToolDescription{Human: I18nObject{EnUS: "Search the web"}, LLM: "search the web"},
+		}},
+	}
+
+	localized, err := LocalizeI18n(map[string]any{
+		"declaration": declaration,
+		// objects with keys other than locales are kept
+		"meta": map[string]any{"en_US": "x", "version": "1"},
+	}, []string{I18N_LOCALE_ZH_HANS})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document := localized.(map[string]any)
+	provider := document["declaration"].(map[string]any)
+	if label := provider["identity"].(map[string]any)["label"]; label != "搜索" {
+		t.Errorf("expected the label of the provider to be resolved, got %v", label)
+	}
+	tool := provider["tools"].([]any)[0].(map[string]any)
+	if label := tool["identity"].(map[string]any)["label"]; label != "网页" {
+		t.Errorf("expected the label of the tool to be resolved, got %v", label)
+	}
+	if human := tool["description"].(map[string]any)["human"]; human != "Search the web" {
+		t.Errorf("expected the description to fall back to en_US, got %v", human)
+	}
+	if meta := document["meta"].(map[string]any); meta["version"] != "1" || meta["en_US"] != "x" {
+		t.Errorf("expected objects other than I18nObject to be kept, got %v", meta)
+	}
+}
@@ -0,0 +1,219 @@
+package plugin_entities
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifests declare the version of their schema by manifest_version, manifests without it are of version 1,
+// which covers all the packages built before the version is declared. Documents of older versions are
+// upgraded by shims one version at a time before they're decoded, so PluginDeclaration only models the
+// latest version while old packages keep installing. Scalars other than strings reach the shims as they're
+// written, *yaml.Node of yaml documents and json.Number of json ones, so that re-encoding the upgraded
+// document never retypes them, e.g. an unquoted runner version 3.10 stays 3.10 instead of becoming 3.1.
+const (
+	MANIFEST_VERSION_1 = 1
+	MANIFEST_VERSION_2 = 2
+
+	MANIFEST_VERSION_LATEST = MANIFEST_VERSION_2
+)
+
+var ErrUnsupportedManifestVersion = errors.New("unsupported manifest version")
+
+// manifestShims upgrade documents of the version to the next one
+var manifestShims = map[int]func(document map[string]any) error{
+	MANIFEST_VERSION_1: upgradeManifestV1,
+}
+
+// UpgradeManifest upgrades the document of a manifest to the latest version in place, it returns the version
+// the document is declared as
+func UpgradeManifest(document map[string]any) (int, error) {
+	declared, err := manifestVersionOf(document)
+	if err != nil {
+		return 0, err
+	}
+	if declared > MANIFEST_VERSION_LATEST {
+		return declared, fmt.Errorf(
+			"%w: manifest_version %d is newer than %d supported by the daemon, upgrade the daemon to install the plugin",
+			ErrUnsupportedManifestVersion, declared, MANIFEST_VERSION_LATEST,
+		)
+	}
+
+	for version := declared; version < MANIFEST_VERSION_LATEST; version++ {
+		if err := manifestShims[version](document); err != nil {
+			return declared, fmt.Errorf("failed to upgrade manifest from version %d: %w", version, err)
+		}
+	}
+	document["manifest_version"] = MANIFEST_VERSION_LATEST
+	return declared, nil
+}
+
+func manifestVersionOf(document map[string]any) (int, error) {
+	value, ok := document["manifest_version"]
+	if !ok || value == nil {
+		return MANIFEST_VERSION_1, nil
+	}
+
+	var version int
+	switch v := value.(type) {
+	case int:
+		version = v
+	case *yaml.Node:
+		if v.Kind != yaml.ScalarNode || v.Tag != "!!int" || v.Decode(&version) != nil {
+			return 0, fmt.Errorf("%w: manifest_version should be an integer, got %v", ErrUnsupportedManifestVersion, v.Value)
+		}
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil || n > math.MaxInt32 {
+			return 0, fmt.Errorf("%w: manifest_version should be an integer, got %v", ErrUnsupportedManifestVersion, v)
+		}
+		version = int(n)
+	case float64:
+		// versions of json documents
+		if v != math.Trunc(v) || v > math.MaxInt32 {
+			return 0, fmt.Errorf("%w: manifest_version should be an integer, got %v", ErrUnsupportedManifestVersion, v)
+		}
+		version = int(v)
+	default:
+		return 0, fmt.Errorf("%w: manifest_version should be an integer, got %v", ErrUnsupportedManifestVersion, v)
+	}
+	if version < MANIFEST_VERSION_1 {
+		return 0, fmt.Errorf("%w: manifest_version should be at least %d, got %d",
+			ErrUnsupportedManifestVersion, MANIFEST_VERSION_1, version)
+	}
+	return version, nil
+}
+
+// upgradeManifestV1 upgrades manifests written before the schema is versioned, the type defaults to plugin,
+// single files of plugins and a single arch are written as scalars by some of them
+func upgradeManifestV1(document map[string]any) error {
+	if _, ok := document["type"]; !ok {
+		document["type"] = "plugin"
+	}
+
+	if plugins, ok := document["plugins"].(map[string]any); ok {
+		for _, key := range []string{"tools", "models", "endpoints", "agent_strategies"} {
+			if file, ok := plugins[key].(string); ok {
+				plugins[key] = []any{file}
+			}
+		}
+	}
+
+	if meta, ok := document["meta"].(map[string]any); ok {
+		if arch, ok := meta["arch"].(string); ok {
+			meta["arch"] = []any{arch}
+		}
+	}
+	return nil
+}
+
+// UnmarshalManifestFromYaml decodes manifest.yaml of a package, manifests of older versions are upgraded to
+// the latest one, the declaration is not validated
+func UnmarshalManifestFromYaml(data []byte) (PluginDeclaration, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return PluginDeclaration{}, err
+	}
+	document := map[string]any{}
+	if len(root.Content) > 0 {
+		mapping, ok := yamlNodeToDocument(root.Content[0]).(map[string]any)
+		if !ok {
+			return PluginDeclaration{}, errors.New("manifest should be a mapping")
+		}
+		document = mapping
+	}
+	if _, err := UpgradeManifest(document); err != nil {
+		return PluginDeclaration{}, err
+	}
+
+	upgraded, err := yaml.Marshal(document)
+	if err != nil {
+		return PluginDeclaration{}, err
+	}
+	return parser.UnmarshalYamlBytes[PluginDeclaration](upgraded)
+}
+
+// UnmarshalManifestFromJSON decodes the declaration sent by a plugin in json, declarations of older versions
+// are upgraded to the latest one, the declaration is validated
+func UnmarshalManifestFromJSON(data []byte) (PluginDeclaration, error) {
+	document := map[string]any{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return PluginDeclaration{}, err
+	}
+	if _, err := UpgradeManifest(document); err != nil {
+		return PluginDeclaration{}, err
+	}
+
+	upgraded, err := json.Marshal(document)
+	if err != nil {
+		return PluginDeclaration{}, err
+	}
+	return parser.UnmarshalJsonBytes[PluginDeclaration](upgraded)
+}
+
+// yamlNodeToDocument converts the node to maps, slices and strings the shims work on, other scalars are kept
+// as nodes which are encoded back as they're written
+func yamlNodeToDocument(node *yaml.Node) any {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return yamlNodeToDocument(node.Content[0])
+	case yaml.AliasNode:
+		return yamlNodeToDocument(node.Alias)
+	case yaml.MappingNode:
+		mapping := map[string]any{}
+		merged := []*yaml.Node{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				merged = append(merged, value)
+				continue
+			}
+			mapping[key.Value] = yamlNodeToDocument(value)
+		}
+		// keys of the mapping take precedence over the merged ones
+		for _, value := range merged {
+			sources := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				sources = value.Content
+			}
+			for _, source := range sources {
+				if m, ok := yamlNodeToDocument(source).(map[string]any); ok {
+					for k, v := range m {
+						if _, exists := mapping[k]; !exists {
+							mapping[k] = v
+						}
+					}
+				}
+			}
+		}
+		return mapping
+	case yaml.SequenceNode:
+		sequence := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			sequence = append(sequence, yamlNodeToDocument(item))
+		}
+		return sequence
+	default:
+		switch node.Tag {
+		case "!!str":
+			return node.Value
+		case "!!null":
+			return nil
+		}
+		// anchors are resolved already, an anchored scalar referred more than once is encoded once per reference
+		scalar := *node
+		scalar.Anchor = ""
+		return &scalar
+	}
+}
@@ -0,0 +1,110 @@
+package plugin_entities
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const manifestV1 = `version: 0.0.1
+author: langgenius
+name: neko
+label:
+  en_US: Neko
+description:
+  en_US: Neko
+icon: icon.svg
+resource:
+  memory: 1048576
+plugins:
+  tools: provider/neko.yaml
+meta:
+  version: 0.0.1
+  arch: amd64
+  runner:
+    language: python
+    version: "3.12"
+    entrypoint: main
+created_at: 2024-07-12T08:03:44.658609186Z
+`
+
+func TestUpgradeManifestV1(t *testing.T) {
+	declaration, err := UnmarshalPluginDeclarationFromYaml([]byte(manifestV1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if declaration.ManifestVersion != MANIFEST_VERSION_LATEST {
+		t.Errorf("expected the manifest to be upgraded to %d, got %d", MANIFEST_VERSION_LATEST, declaration.ManifestVersion)
+	}
+	if declaration.Type != "plugin" {
+		t.Errorf("expected the type to default to plugin, got %s", declaration.Type)
+	}
+	if len(declaration.Plugins.Tools) != 1 || declaration.Plugins.Tools[0] != "provider/neko.yaml" {
+		t.Errorf("expected the single tool file to be listed, got %v", declaration.Plugins.Tools)
+	}
+	if len(declaration.Meta.Arch) != 1 || declaration.Meta.Arch[0] != "amd64" {
+		t.Errorf("expected the single arch to be listed, got %v", declaration.Meta.Arch)
+	}
+}
+
+func TestUpgradeManifestOfLatestVersion(t *testing.T) {
+	manifest := strings.Replace(manifestV1, "plugins:\n  tools: provider/neko.yaml",
+		"type: plugin\nmanifest_version: 2\nplugins:\n  tools:\n    - provider/neko.yaml", 1)
+	manifest = strings.Replace(manifest, "arch: amd64", "arch:\n    - amd64", 1)
+
+	declaration, err := UnmarshalPluginDeclarationFromYaml([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if declaration.ManifestVersion != MANIFEST_VERSION_2 || len(declaration.Plugins.Tools) != 1 {
+		t.Errorf("unexpected declaration %+v", declaration.PluginDeclarationWithoutAdvancedFields)
+	}
+
+	// declarations in json are upgraded as well
+	decoded, err := UnmarshalManifestFromJSON([]byte(`{"manifest_version": 3}`))
+	if !errors.Is(err, ErrUnsupportedManifestVersion) {
+		t.Errorf("expected json of future versions to be rejected, got %v, %+v", err, decoded)
+	}
+}
+
+func TestUpgradeManifestRejectsUnsupportedVersions(t *testing.T) {
+	for value, message := range map[any]string{
+		MANIFEST_VERSION_LATEST + 1: "newer than",
+		0:                           "at least",
+		"2":                         "should be an integer",
+		2.5:                         "should be an integer",
+	} {
+		_, err := UpgradeManifest(map[string]any{"manifest_version": value})
+		if !errors.Is(err, ErrUnsupportedManifestVersion) || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected manifest_version %v to be rejected as %q, got %v", value, message, err)
+		}
+	}
+
+	if _, err := UnmarshalPluginDeclarationFromYaml([]byte(manifestV1 + "manifest_version: 99\n")); !errors.Is(err, ErrUnsupportedManifestVersion) {
+		t.Errorf("expected manifests of future versions to be rejected, got %v", err)
+	}
+}
+
+func TestUpgradeManifestKeepsScalars(t *testing.T) {
+	manifest := strings.Replace(manifestV1, "version: 0.0.1\nauthor", "version: 1.10\nauthor", 1)
+	manifest = strings.Replace(manifest, `version: "3.12"`, "version: 3.10", 1)
+	manifest = strings.Replace(manifest, "  version: 0.0.1\n  arch", "  version: 2.0\n  arch", 1)
+
+	declaration, err := UnmarshalManifestFromYaml([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if declaration.Version != "1.10" {
+		t.Errorf("expected the version to be kept as written, got %s", declaration.Version)
+	}
+	if declaration.Meta.Runner.Version != "3.10" {
+		t.Errorf("expected the runner version to be kept as written, got %s", declaration.Meta.Runner.Version)
+	}
+	if declaration.Meta.Version != "2.0" {
+		t.Errorf("expected the meta version to be kept as written, got %s", declaration.Meta.Version)
+	}
+	if declaration.Resource.Memory != 1048576 {
+		t.Errorf("expected the memory to be decoded, got %d", declaration.Resource.Memory)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -29,6 +31,7 @@ type PluginPermissionRequirement struct {
 	Endpoint *PluginPermissionEndpointRequirement `json:"endpoint,omitempty" yaml:"endpoint,omitempty" validate:"omitempty"`
 	App      *PluginPermissionAppRequirement      `json:"app,omitempty" yaml:"app,omitempty" validate:"omitempty"`
 	Storage  *PluginPermissionStorageRequirement  `json:"storage,omitempty" yaml:"storage,omitempty" validate:"omitempty"`
+	Network  *PluginPermissionNetworkRequirement  `json:"network,omitempty" yaml:"network,omitempty" validate:"omitempty"`
 }
 
 func (p *PluginPermissionRequirement) AllowInvokeTool() bool {
@@ -79,6 +82,117 @@ func (p *PluginPermissionRequirement) AllowInvokeStorage() bool {
 	return p != nil && p.Storage != nil && p.Storage.Enabled
 }
 
+func (p *PluginPermissionRequirement) AllowNetwork() bool {
+	return p != nil && p.Network != nil && p.Network.Enabled
+}
+
+// AllowDomain reports whether outbound connections to host are declared,
+// an enabled network permission without domains allows any host
+func (p *PluginPermissionRequirement) AllowDomain(host string) bool {
+	if !p.AllowNetwork() {
+		return false
+	}
+
+	if len(p.Network.Domains) == 0 {
+		return true
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, domain := range p.Network.Domains {
+		if matchDomain(strings.TrimSuffix(strings.ToLower(domain), "."), host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchDomain matches host against `*`, `example.com` or `*.example.com`,
+// the wildcard form matches subdomains only
+func matchDomain(pattern string, host string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+
+	return pattern == host
+}
+
+// PluginRequestedPermission is a permission a plugin asks for, listed on install so that
+// tenants know what they consent to
+type PluginRequestedPermission struct {
+	Permission string `json:"permission"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// Requested lists the permissions declared in the manifest
+func (p *PluginPermissionRequirement) Requested() []PluginRequestedPermission {
+	permissions := []PluginRequestedPermission{}
+	if p == nil {
+		return permissions
+	}
+
+	if p.AllowInvokeTool() {
+		permissions = append(permissions, PluginRequestedPermission{Permission: "tool"})
+	}
+
+	if p.AllowInvokeModel() {
+		models := []string{}
+		for name, allowed := range map[string]bool{
+			"llm":            p.Model.LLM,
+			"text_embedding": p.Model.TextEmbedding,
+			"rerank":         p.Model.Rerank,
+			"tts":            p.Model.TTS,
+			"speech2text":    p.Model.Speech2text,
+			"moderation":     p.Model.Moderation,
+		} {
+			if allowed {
+				models = append(models, name)
+			}
+		}
+		sort.Strings(models)
+		permissions = append(permissions, PluginRequestedPermission{
+			Permission: "model",
+			Detail:     strings.Join(models, ","),
+		})
+	}
+
+	if p.AllowInvokeNode() {
+		permissions = append(permissions, PluginRequestedPermission{Permission: "node"})
+	}
+
+	if p.AllowInvokeApp() {
+		permissions = append(permissions, PluginRequestedPermission{Permission: "app"})
+	}
+
+	if p.AllowRegisterEndpoint() {
+		permissions = append(permissions, PluginRequestedPermission{Permission: "endpoint"})
+	}
+
+	if p.AllowInvokeStorage() {
+		permissions = append(permissions, PluginRequestedPermission{
+			Permission: "storage",
+			Detail:     fmt.Sprintf("%d bytes", p.Storage.Size),
+		})
+	}
+
+	if p.AllowNetwork() {
+		detail := "*"
+		if len(p.Network.Domains) > 0 {
+			detail = strings.Join(p.Network.Domains, ",")
+		}
+		permissions = append(permissions, PluginRequestedPermission{
+			Permission: "network",
+			Detail:     detail,
+		})
+	}
+
+	return permissions
+}
+
 type PluginPermissionToolRequirement struct {
 	Enabled bool `json:"enabled" yaml:"enabled"`
 }
@@ -110,11 +224,28 @@ type PluginPermissionStorageRequirement struct {
 	Size    uint64 `json:"size" yaml:"size" validate:"min=1024,max=1073741824"` // min 1024 bytes, max 1G
 }
 
+type PluginPermissionNetworkRequirement struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// outbound connections are limited to these domains, `*.example.com` matches subdomains
+	Domains []string `json:"domains,omitempty" yaml:"domains,omitempty" validate:"omitempty,max=64,dive,max=253"`
+}
+
 type PluginResourceRequirement struct {
 	// Memory in bytes
 	Memory int64 `json:"memory" yaml:"memory" validate:"required"`
 	// Permission requirements
 	Permission *PluginPermissionRequirement `json:"permission,omitempty" yaml:"permission,omitempty" validate:"omitempty"`
+	// Serverless requirements, only used when the plugin is deployed as a serverless function
+	Serverless *PluginServerlessRequirement `json:"serverless,omitempty" yaml:"serverless,omitempty" validate:"omitempty"`
+}
+
+type PluginServerlessRequirement struct {
+	// Timeout of an invocation in seconds
+	Timeout int `json:"timeout,omitempty" yaml:"timeout,omitempty" validate:"omitempty,min=1"`
+	// EphemeralStorage in bytes
+	EphemeralStorage int64 `json:"ephemeral_storage,omitempty" yaml:"ephemeral_storage,omitempty" validate:"omitempty,min=0"`
+	// Concurrency is the max concurrent executions of the function, 0 means unlimited
+	Concurrency int `json:"concurrency,omitempty" yaml:"concurrency,omitempty" validate:"omitempty,min=0"`
 }
 
 type PluginDeclarationPlatformArch string
@@ -139,19 +270,20 @@ type PluginExtensions struct {
 }
 
 type PluginDeclarationWithoutAdvancedFields struct {
-	Version     manifest_entities.Version          `json:"version" yaml:"version,omitempty" validate:"required,version"`
-	Type        manifest_entities.DifyManifestType `json:"type" yaml:"type,omitempty" validate:"required,eq=plugin"`
-	Author      string                             `json:"author" yaml:"author,omitempty" validate:"omitempty,max=64"`
-	Name        string                             `json:"name" yaml:"name,omitempty" validate:"required,max=128"`
-	Label       I18nObject                         `json:"label" yaml:"label" validate:"required"`
-	Description I18nObject                         `json:"description" yaml:"description" validate:"required"`
-	Icon        string                             `json:"icon" yaml:"icon,omitempty" validate:"required,max=128"`
-	Resource    PluginResourceRequirement          `json:"resource" yaml:"resource,omitempty" validate:"required"`
-	Plugins     PluginExtensions                   `json:"plugins" yaml:"plugins,omitempty" validate:"required"`
-	Meta        PluginMeta                         `json:"meta" yaml:"meta,omitempty" validate:"required"`
-	Tags        []manifest_entities.PluginTag      `json:"tags" yaml:"tags,omitempty" validate:"omitempty,dive,plugin_tag,max=128"`
-	CreatedAt   time.Time                          `json:"created_at" yaml:"created_at,omitempty" validate:"required"`
-	Privacy     *string                            `json:"privacy,omitempty" yaml:"privacy,omitempty" validate:"omitempty"`
+	Version         manifest_entities.Version          `json:"version" yaml:"version,omitempty" validate:"required,version"`
+	Type            manifest_entities.DifyManifestType `json:"type" yaml:"type,omitempty" validate:"required,eq=plugin"`
+	Author          string                             `json:"author" yaml:"author,omitempty" validate:"omitempty,max=64"`
+	Name            string                             `json:"name" yaml:"name,omitempty" validate:"required,max=128"`
+	Label           I18nObject                         `json:"label" yaml:"label" validate:"required"`
+	Description     I18nObject                         `json:"description" yaml:"description" validate:"required"`
+	Icon            string                             `json:"icon" yaml:"icon,omitempty" validate:"required,max=128"`
+	Resource        PluginResourceRequirement          `json:"resource" yaml:"resource,omitempty" validate:"required"`
+	Plugins         PluginExtensions                   `json:"plugins" yaml:"plugins,omitempty" validate:"required"`
+	Meta            PluginMeta                         `json:"meta" yaml:"meta,omitempty" validate:"required"`
+	Tags            []manifest_entities.PluginTag      `json:"tags" yaml:"tags,omitempty" validate:"omitempty,dive,plugin_tag,max=128"`
+	CreatedAt       time.Time                          `json:"created_at" yaml:"created_at,omitempty" validate:"required"`
+	Privacy         *string                            `json:"privacy,omitempty" yaml:"privacy,omitempty" validate:"omitempty"`
+	ManifestVersion int                                `json:"manifest_version,omitempty" yaml:"manifest_version,omitempty" validate:"omitempty,min=1"`
 }
 
 func (p *PluginDeclarationWithoutAdvancedFields) UnmarshalJSON(data []byte) error {
@@ -297,7 +429,7 @@ func init() {
 }
 
 func UnmarshalPluginDeclarationFromYaml(data []byte) (*PluginDeclaration, error) {
-	obj, err := parser.UnmarshalYamlBytes[PluginDeclaration](data)
+	obj, err := UnmarshalManifestFromYaml(data)
 	if err != nil {
 		return nil, err
 	}
@@ -312,7 +444,7 @@ func UnmarshalPluginDeclarationFromYaml(data []byte) (*PluginDeclaration, error)
 }
 
 func UnmarshalPluginDeclarationFromJSON(data []byte) (*PluginDeclaration, error) {
-	obj, err := parser.UnmarshalJsonBytes[PluginDeclaration](data)
+	obj, err := UnmarshalManifestFromJSON(data)
 	if err != nil {
 		return nil, err
 	}
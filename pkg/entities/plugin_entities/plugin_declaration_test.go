@@ -191,3 +191,67 @@ func TestPluginDeclarationIncorrectName(t *testing.T) {
 		return
 	}
 }
+
+func TestPluginPermissionAllowDomain(t *testing.T) {
+	permission := &PluginPermissionRequirement{
+		Network: &PluginPermissionNetworkRequirement{
+			Enabled: true,
+			Domains: []string{"api.openai.com", "*.example.com"},
+		},
+	}
+
+	for host, allowed := range map[string]bool{
+		"api.openai.com":      true,
+		"API.OpenAI.com.":     true,
+		"openai.com":          false,
+		"a.example.com":       true,
+		"a.b.example.com":     true,
+		"example.com":         false,
+		"badexample.com":      false,
+		"api.openai.com.evil": false,
+	} {
+		if permission.AllowDomain(host) != allowed {
+			t.Errorf("expected %s to be allowed=%v", host, allowed)
+		}
+	}
+
+	permission.Network.Domains = nil
+	if !permission.AllowDomain("anything.org") {
+		t.Errorf("network permission without domains should allow any host")
+	}
+
+	permission.Network.Enabled = false
+	if permission.AllowDomain("api.openai.com") {
+		t.Errorf("disabled network permission should deny every host")
+	}
+
+	if (*PluginPermissionRequirement)(nil).AllowDomain("api.openai.com") {
+		t.Errorf("missing permission should deny every host")
+	}
+}
+
+func TestPluginPermissionRequested(t *testing.T) {
+	permission := &PluginPermissionRequirement{
+		Tool:    &PluginPermissionToolRequirement{Enabled: true},
+		Model:   &PluginPermissionModelRequirement{Enabled: true, LLM: true, Rerank: true},
+		Storage: &PluginPermissionStorageRequirement{Enabled: true, Size: 1024},
+		Network: &PluginPermissionNetworkRequirement{Enabled: true, Domains: []string{"api.openai.com"}},
+	}
+
+	requested := permission.Requested()
+	expected := []PluginRequestedPermission{
+		{Permission: "tool"},
+		{Permission: "model", Detail: "llm,rerank"},
+		{Permission: "storage", Detail: "1024 bytes"},
+		{Permission: "network", Detail: "api.openai.com"},
+	}
+
+	if len(requested) != len(expected) {
+		t.Fatalf("expected %d permissions, got %d", len(expected), len(requested))
+	}
+	for i := range expected {
+		if requested[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], requested[i])
+		}
+	}
+}
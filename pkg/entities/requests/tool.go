@@ -34,6 +34,13 @@ type RequestInvokeTool struct {
 	Credentials
 }
 
+// RequestDirectInvokeTool invokes a tool of an installed plugin directly, apart from Dify apps
+type RequestDirectInvokeTool struct {
+	PluginID string `json:"plugin_id" validate:"required"`
+	UserID   string `json:"user_id"`
+	RequestInvokeTool
+}
+
 type RequestValidateToolCredentials struct {
 	Provider    string         `json:"provider" validate:"required"`
 	Credentials map[string]any `json:"credentials" validate:"omitempty"`
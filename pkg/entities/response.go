@@ -31,3 +31,19 @@ type GenericResponse[T any] struct {
 	Message string `json:"message"`
 	Data    T      `json:"data"`
 }
+
+// List is the envelope of list apis, NextCursor is empty once there are no more items
+type List[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// Lister is implemented by lists, callers of apis which used to reply arrays get the items only
+type Lister interface {
+	ListItems() any
+}
+
+func (l List[T]) ListItems() any {
+	return l.Items
+}
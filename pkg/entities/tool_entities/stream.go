@@ -0,0 +1,83 @@
+package tool_entities
+
+// ToolStreamChunkType is the type of chunks of typed tool streams, which are converted from chunks responded
+// by plugins so that callers render partial results and files as they arrive
+type ToolStreamChunkType string
+
+const (
+	ToolStreamChunkTypeText  ToolStreamChunkType = "text"
+	ToolStreamChunkTypeJson  ToolStreamChunkType = "json"
+	ToolStreamChunkTypeFile  ToolStreamChunkType = "file"
+	ToolStreamChunkTypeLog   ToolStreamChunkType = "log"
+	ToolStreamChunkTypeUsage ToolStreamChunkType = "usage"
+)
+
+// ToolStreamChunk is a chunk of a typed tool stream, the field named by the type is set
+type ToolStreamChunk struct {
+	Type ToolStreamChunkType `json:"type"`
+	// Seq orders chunks of a stream, starting from 0
+	Seq   int              `json:"seq"`
+	Text  *ToolStreamText  `json:"text,omitempty"`
+	Json  *ToolStreamJson  `json:"json,omitempty"`
+	File  *ToolStreamFile  `json:"file,omitempty"`
+	Log   *ToolStreamLog   `json:"log,omitempty"`
+	Usage *ToolStreamUsage `json:"usage,omitempty"`
+	Meta  map[string]any   `json:"meta,omitempty"`
+}
+
+// ToolStreamText is text to be appended to the result, or to the variable if it's set
+type ToolStreamText struct {
+	Text     string `json:"text"`
+	Variable string `json:"variable,omitempty"`
+}
+
+// ToolStreamJson is a json object of the result, or the value of the variable if it's set
+type ToolStreamJson struct {
+	Value    any    `json:"value"`
+	Variable string `json:"variable,omitempty"`
+}
+
+type ToolStreamFileKind string
+
+const (
+	ToolStreamFileKindBlob      ToolStreamFileKind = "blob"
+	ToolStreamFileKindImage     ToolStreamFileKind = "image"
+	ToolStreamFileKindLink      ToolStreamFileKind = "link"
+	ToolStreamFileKindReference ToolStreamFileKind = "reference"
+)
+
+// ToolStreamFile is a part of a file, files of links and images are referenced by urls, blobs are sent in
+// parts of the same id as they're received from the plugin, the file is complete once a part ends it
+type ToolStreamFile struct {
+	ID   string             `json:"id"`
+	Kind ToolStreamFileKind `json:"kind"`
+	URL  string             `json:"url,omitempty"`
+	// Offset is where the data of the part starts in the file
+	Offset      int    `json:"offset"`
+	Data        []byte `json:"data,omitempty"` // encoded in base64
+	TotalLength int    `json:"total_length,omitempty"`
+	MimeType    string `json:"mime_type,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	End         bool   `json:"end"`
+}
+
+// ToolStreamLog is a log of the tool, logs of the same id update each other
+type ToolStreamLog struct {
+	ID       string         `json:"id"`
+	ParentID string         `json:"parent_id,omitempty"`
+	Label    string         `json:"label"`
+	Status   string         `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// ToolStreamUsage ends a stream, tokens and prices are summed from metadata of logs reporting them
+type ToolStreamUsage struct {
+	ElapsedMs   int64   `json:"elapsed_ms"`
+	Chunks      int     `json:"chunks"`
+	FileBytes   int     `json:"file_bytes"`
+	TotalTokens int     `json:"total_tokens,omitempty"`
+	TotalPrice  float64 `json:"total_price,omitempty"`
+	Currency    string  `json:"currency,omitempty"`
+}
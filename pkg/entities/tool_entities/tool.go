@@ -56,3 +56,15 @@ type ToolResponseChunk struct {
 type GetToolRuntimeParametersResponse struct {
 	Parameters []plugin_entities.ToolParameter `json:"parameters"`
 }
+
+// ToolOutputSchemaViolation describes a variable emitted by a tool which violates the output schema declared by it
+type ToolOutputSchemaViolation struct {
+	// Field is the path of the variable like `result.items.0`, `(root)` for the whole output
+	Field       string `json:"field"`
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+}
+
+func (v ToolOutputSchemaViolation) String() string {
+	return v.Field + ": " + v.Description
+}
@@ -26,7 +26,8 @@ func (p *PluginDecoderHelper) Manifest(decoder PluginDecoder) (plugin_entities.P
 		return plugin_entities.PluginDeclaration{}, err
 	}
 
-	dec, err := parser.UnmarshalYamlBytes[plugin_entities.PluginDeclaration](manifest)
+	// manifests of older versions are upgraded to the latest one
+	dec, err := plugin_entities.UnmarshalManifestFromYaml(manifest)
 	if err != nil {
 		return plugin_entities.PluginDeclaration{}, err
 	}
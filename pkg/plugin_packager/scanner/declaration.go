@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+// DeclarationScanner lints the declaration for problems beyond the schema, like missing translations
+// or broad permissions, they're reported as warnings
+type DeclarationScanner struct{}
+
+func NewDeclarationScanner() *DeclarationScanner {
+	return &DeclarationScanner{}
+}
+
+func (s *DeclarationScanner) Name() string {
+	return "declaration"
+}
+
+func (s *DeclarationScanner) Scan(decoder decoder.PluginDecoder) ([]Finding, error) {
+	findings := []Finding{}
+
+	declaration, err := decoder.Manifest()
+	if err != nil {
+		// reported by the manifest scanner
+		return findings, nil
+	}
+
+	for _, warning := range plugin_entities.ValidateDeclaration(&declaration) {
+		findings = append(findings, Finding{
+			Severity: SEVERITY_WARNING,
+			File:     "manifest.yaml",
+			Message:  fmt.Sprintf("%s: %s (%s)", warning.Path, warning.Message, warning.Rule),
+		})
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,163 @@
+package scanner
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+// FileLimitScanner limits the number of files and the size of every single file in a package
+type FileLimitScanner struct {
+	maxFiles    int
+	maxFileSize int64
+}
+
+// NewFileLimitScanner creates a FileLimitScanner, a non-positive limit disables the check
+func NewFileLimitScanner(maxFiles int, maxFileSize int64) *FileLimitScanner {
+	return &FileLimitScanner{
+		maxFiles:    maxFiles,
+		maxFileSize: maxFileSize,
+	}
+}
+
+func (s *FileLimitScanner) Name() string {
+	return "file_limit"
+}
+
+func (s *FileLimitScanner) Scan(decoder decoder.PluginDecoder) ([]Finding, error) {
+	findings := []Finding{}
+	files := 0
+
+	err := decoder.Walk(func(filename, dir string) error {
+		if filename == "" {
+			// directory entry
+			return nil
+		}
+
+		files++
+
+		if s.maxFileSize <= 0 {
+			return nil
+		}
+
+		fullPath := path.Join(dir, filename)
+		info, err := decoder.Stat(fullPath)
+		if err != nil {
+			// unreadable entries are reported by DangerousFileScanner
+			return nil
+		}
+
+		if info.Size() > s.maxFileSize {
+			findings = append(findings, Finding{
+				Severity: SEVERITY_ERROR,
+				File:     fullPath,
+				Message: fmt.Sprintf(
+					"file size %d exceeds the maximum limit of %d bytes", info.Size(), s.maxFileSize,
+				),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.maxFiles > 0 && files > s.maxFiles {
+		findings = append(findings, Finding{
+			Severity: SEVERITY_ERROR,
+			Message:  fmt.Sprintf("package contains %d files, exceeds the maximum limit of %d", files, s.maxFiles),
+		})
+	}
+
+	return findings, nil
+}
+
+// DangerousFileScanner detects files which should never be shipped in a plugin package,
+// like setuid binaries, symlinks, device files and paths escaping the package root
+type DangerousFileScanner struct{}
+
+func NewDangerousFileScanner() *DangerousFileScanner {
+	return &DangerousFileScanner{}
+}
+
+func (s *DangerousFileScanner) Name() string {
+	return "dangerous_file"
+}
+
+func (s *DangerousFileScanner) Scan(decoder decoder.PluginDecoder) ([]Finding, error) {
+	findings := []Finding{}
+
+	err := decoder.Walk(func(filename, dir string) error {
+		if filename == "" {
+			return nil
+		}
+
+		fullPath := path.Join(dir, filename)
+		if isEscapingPath(dir + filename) {
+			findings = append(findings, Finding{
+				Severity: SEVERITY_ERROR,
+				File:     dir + filename,
+				Message:  "path escapes the package root",
+			})
+			return nil
+		}
+
+		info, err := decoder.Stat(fullPath)
+		if err != nil {
+			findings = append(findings, Finding{
+				Severity: SEVERITY_ERROR,
+				File:     fullPath,
+				Message:  fmt.Sprintf("failed to stat file: %s", err.Error()),
+			})
+			return nil
+		}
+
+		mode := info.Mode()
+		switch {
+		case mode&fs.ModeSymlink != 0:
+			findings = append(findings, Finding{
+				Severity: SEVERITY_ERROR,
+				File:     fullPath,
+				Message:  "symbolic links are not allowed",
+			})
+		case mode&(fs.ModeSetuid|fs.ModeSetgid) != 0:
+			findings = append(findings, Finding{
+				Severity: SEVERITY_ERROR,
+				File:     fullPath,
+				Message:  "setuid or setgid files are not allowed",
+			})
+		case mode&(fs.ModeDevice|fs.ModeCharDevice|fs.ModeNamedPipe|fs.ModeSocket) != 0:
+			findings = append(findings, Finding{
+				Severity: SEVERITY_ERROR,
+				File:     fullPath,
+				Message:  "special files are not allowed",
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+func isEscapingPath(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") {
+		return true
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+
+	return false
+}
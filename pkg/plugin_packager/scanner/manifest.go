@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+// ManifestScanner validates the manifest strictly, including the cross field rules
+// which are not covered by the decoder itself
+type ManifestScanner struct{}
+
+func NewManifestScanner() *ManifestScanner {
+	return &ManifestScanner{}
+}
+
+func (s *ManifestScanner) Name() string {
+	return "manifest"
+}
+
+func (s *ManifestScanner) Scan(decoder decoder.PluginDecoder) ([]Finding, error) {
+	findings := []Finding{}
+
+	declaration, err := decoder.Manifest()
+	if err != nil {
+		return append(findings, Finding{
+			Severity: SEVERITY_ERROR,
+			File:     "manifest.yaml",
+			Message:  err.Error(),
+		}), nil
+	}
+
+	if err := validators.GlobalEntitiesValidator.Struct(declaration); err != nil {
+		findings = append(findings, Finding{
+			Severity: SEVERITY_ERROR,
+			File:     "manifest.yaml",
+			Message:  err.Error(),
+		})
+	}
+
+	if err := declaration.ManifestValidate(); err != nil {
+		findings = append(findings, Finding{
+			Severity: SEVERITY_ERROR,
+			File:     "manifest.yaml",
+			Message:  err.Error(),
+		})
+	}
+
+	if err := decoder.CheckAssetsValid(); err != nil {
+		findings = append(findings, Finding{
+			Severity: SEVERITY_ERROR,
+			Message:  err.Error(),
+		})
+	}
+
+	return findings, nil
+}
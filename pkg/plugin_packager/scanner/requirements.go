@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+var (
+	requirementNameRegex      = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)`)
+	requirementNormalizeRegex = regexp.MustCompile(`[-_.]+`)
+)
+
+// RequirementsScanner checks requirements.txt of python plugins against a list of blocked packages
+type RequirementsScanner struct {
+	blocked map[string]bool
+}
+
+func NewRequirementsScanner(blockedPackages []string) *RequirementsScanner {
+	blocked := make(map[string]bool, len(blockedPackages))
+	for _, pkg := range blockedPackages {
+		pkg = strings.TrimSpace(pkg)
+		if pkg == "" {
+			continue
+		}
+		blocked[normalizeRequirementName(pkg)] = true
+	}
+
+	return &RequirementsScanner{
+		blocked: blocked,
+	}
+}
+
+func (s *RequirementsScanner) Name() string {
+	return "requirements"
+}
+
+func (s *RequirementsScanner) Scan(decoder decoder.PluginDecoder) ([]Finding, error) {
+	findings := []Finding{}
+
+	declaration, err := decoder.Manifest()
+	if err != nil {
+		// reported by ManifestScanner
+		return findings, nil
+	}
+
+	if declaration.Meta.Runner.Language != constants.Python {
+		return findings, nil
+	}
+
+	requirements, err := decoder.ReadFile("requirements.txt")
+	if err != nil {
+		return append(findings, Finding{
+			Severity: SEVERITY_WARNING,
+			File:     "requirements.txt",
+			Message:  "requirements.txt not found",
+		}), nil
+	}
+
+	for i, line := range strings.Split(string(requirements), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "-") {
+			// pip options like --index-url or -e redirect pip to arbitrary sources
+			findings = append(findings, Finding{
+				Severity: SEVERITY_WARNING,
+				File:     "requirements.txt",
+				Message:  fmt.Sprintf("line %d: pip option %q is not recommended", i+1, line),
+			})
+			continue
+		}
+
+		name := requirementNameRegex.FindString(line)
+		if name == "" {
+			continue
+		}
+
+		if s.blocked[normalizeRequirementName(name)] {
+			findings = append(findings, Finding{
+				Severity: SEVERITY_ERROR,
+				File:     "requirements.txt",
+				Message:  fmt.Sprintf("line %d: package %q is blocked", i+1, name),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// normalizeRequirementName normalizes a python package name as described in PEP 503
+func normalizeRequirementName(name string) string {
+	return strings.ToLower(requirementNormalizeRegex.ReplaceAllString(name, "-"))
+}
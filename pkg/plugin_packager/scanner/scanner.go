@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+type Severity string
+
+const (
+	// SEVERITY_WARNING is reported but never blocks an installation
+	SEVERITY_WARNING Severity = "warning"
+	// SEVERITY_ERROR blocks the installation when the scan policy is set to block
+	SEVERITY_ERROR Severity = "error"
+)
+
+type Finding struct {
+	Scanner  string   `json:"scanner"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file,omitempty"`
+	Message  string   `json:"message"`
+}
+
+type Report struct {
+	Findings []Finding `json:"findings"`
+}
+
+// Blocking returns true if any finding in the report is an error
+func (r *Report) Blocking() bool {
+	for _, finding := range r.Findings {
+		if finding.Severity == SEVERITY_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// Scanner inspects a decoded plugin package and reports what it found,
+// an error returned from Scan means the scanner itself failed, not the package
+type Scanner interface {
+	// Name returns the name of the scanner, it's used as the source of findings
+	Name() string
+	// Scan scans the package and returns the findings
+	Scan(decoder decoder.PluginDecoder) ([]Finding, error)
+}
+
+type Pipeline struct {
+	scanners []Scanner
+}
+
+func NewPipeline(scanners ...Scanner) *Pipeline {
+	return &Pipeline{
+		scanners: scanners,
+	}
+}
+
+// Use appends scanners to the end of the pipeline
+func (p *Pipeline) Use(scanners ...Scanner) {
+	p.scanners = append(p.scanners, scanners...)
+}
+
+// Run executes all scanners in order and merges their findings into one report
+func (p *Pipeline) Run(decoder decoder.PluginDecoder) (*Report, error) {
+	report := &Report{
+		Findings: []Finding{},
+	}
+
+	for _, scanner := range p.scanners {
+		findings, err := scanner.Scan(decoder)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, finding := range findings {
+			finding.Scanner = scanner.Name()
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,171 @@
+package scanner
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+const testManifest = `version: 0.0.1
+type: plugin
+author: "test"
+name: "scanner"
+icon: icon.svg
+description:
+  en_US: "test"
+label:
+  en_US: "Scanner"
+created_at: "2024-07-12T08:03:44.658609186Z"
+resource:
+  memory: 1048576
+plugins:
+  endpoints:
+    - "endpoint.yaml"
+meta:
+  version: 0.0.1
+  arch:
+    - "amd64"
+  runner:
+    language: "python"
+    version: "3.12"
+    entrypoint: "main"
+`
+
+type testFile struct {
+	name    string
+	content string
+	mode    fs.FileMode
+}
+
+func buildPackage(t *testing.T, files []testFile) *decoder.ZipPluginDecoder {
+	buffer := new(bytes.Buffer)
+	writer := zip.NewWriter(buffer)
+
+	files = append([]testFile{
+		{name: "manifest.yaml", content: testManifest, mode: 0644},
+		{name: "endpoint.yaml", content: "settings: []\n", mode: 0644},
+	}, files...)
+	for _, file := range files {
+		header := &zip.FileHeader{Name: file.name, Method: zip.Deflate}
+		header.SetMode(file.mode)
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder, err := decoder.NewZipPluginDecoder(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return decoder
+}
+
+func TestDangerousFileScanner(t *testing.T) {
+	decoder := buildPackage(t, []testFile{
+		{name: "main.py", content: "print(1)", mode: 0644},
+		{name: "bin/su", content: "bin", mode: 0755 | fs.ModeSetuid},
+		{name: "link", content: "/etc/passwd", mode: 0777 | fs.ModeSymlink},
+	})
+
+	findings, err := NewDangerousFileScanner().Scan(decoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %v", findings)
+	}
+
+	files := map[string]bool{}
+	for _, finding := range findings {
+		files[finding.File] = true
+	}
+
+	if !files["bin/su"] || !files["link"] {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+}
+
+func TestFileLimitScanner(t *testing.T) {
+	decoder := buildPackage(t, []testFile{
+		{name: "main.py", content: "print(1)", mode: 0644},
+		{name: "large.bin", content: string(make([]byte, 2048)), mode: 0644},
+	})
+
+	findings, err := NewFileLimitScanner(2, 1024).Scan(decoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one for the large file, one for the file count
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %v", findings)
+	}
+
+	findings, err = NewFileLimitScanner(0, 0).Scan(decoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(findings) != 0 {
+		t.Fatalf("expected no findings when limits are disabled, got %v", findings)
+	}
+}
+
+func TestRequirementsScanner(t *testing.T) {
+	decoder := buildPackage(t, []testFile{
+		{name: "requirements.txt", content: "dify_plugin>=0.0.1\n# comment\nPy_Crypto==2.6\n--index-url http://evil\n", mode: 0644},
+	})
+
+	findings, err := NewRequirementsScanner([]string{"py-crypto"}).Scan(decoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %v", findings)
+	}
+
+	if findings[0].Severity != SEVERITY_ERROR {
+		t.Fatalf("blocked package should be an error, got %v", findings[0])
+	}
+
+	if findings[1].Severity != SEVERITY_WARNING {
+		t.Fatalf("pip option should be a warning, got %v", findings[1])
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	decoder := buildPackage(t, []testFile{
+		{name: "requirements.txt", content: "requests\n", mode: 0644},
+		{name: "link", content: "../../etc/passwd", mode: 0777 | fs.ModeSymlink},
+	})
+
+	report, err := NewPipeline(
+		NewRequirementsScanner(nil),
+		NewDangerousFileScanner(),
+	).Run(decoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Blocking() {
+		t.Fatalf("report should be blocking: %v", report.Findings)
+	}
+
+	if report.Findings[0].Scanner != "dangerous_file" {
+		t.Fatalf("finding should be attributed to its scanner, got %v", report.Findings[0])
+	}
+}
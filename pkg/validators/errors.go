@@ -0,0 +1,174 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes a field of a request which failed validation
+type FieldError struct {
+	// Field is the path of the field by the names it's sent as, like `settings.api_key` or `metas[0]`,
+	// it's empty if the request could not be parsed at all
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + " " + e.Message
+}
+
+// FieldErrors describes the failures of validating a value of type t, the names of fields are taken from
+// their json, form or uri tags, it returns nil if err is not returned by validation
+func FieldErrors(err error, t reflect.Type) []FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+
+	result := make([]FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		result = append(result, FieldError{
+			Field:   fieldPath(t, fe.StructNamespace()),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: ruleMessage(fe),
+		})
+	}
+	return result
+}
+
+// fieldPath turns a namespace like `Request.Settings.Items[0]` into `settings.items[0]`
+func fieldPath(t reflect.Type, namespace string) string {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// the namespace starts with the name of the type unless it's anonymous, names of generic types contain dots
+	if t != nil && t.Name() != "" {
+		namespace = strings.TrimPrefix(namespace, t.Name()+".")
+	}
+
+	path := []string{}
+	for _, segment := range splitNamespace(namespace) {
+		name, index, _ := strings.Cut(segment, "[")
+		if index != "" {
+			index = "[" + index
+		}
+
+		field, ok := structField(t, name)
+		if !ok {
+			// the rest of the path can't be resolved, the names of go fields are used
+			t = nil
+			path = append(path, segment)
+			continue
+		}
+
+		t = field.Type
+		if requestName := requestFieldName(field); requestName != "" {
+			path = append(path, requestName+index)
+		} else if index != "" || !field.Anonymous {
+			path = append(path, name+index)
+		}
+		// fields of embedded structs are sent inline
+
+		for i := strings.Count(index, "["); i > 0 && t != nil; i-- {
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+			default:
+				t = nil
+			}
+		}
+	}
+
+	return strings.Join(path, ".")
+}
+
+func structField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	return t.FieldByName(name)
+}
+
+func requestFieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return ""
+}
+
+// splitNamespace splits a namespace by dots, dots in keys of maps are kept
+func splitNamespace(namespace string) []string {
+	segments := []string{}
+	depth, start := 0, 0
+	for i, c := range namespace {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				segments = append(segments, namespace[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(namespace) {
+		segments = append(segments, namespace[start:])
+	}
+	return segments
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without",
+		"required_with_all", "required_without_all":
+		return "is required"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "gt":
+		return fmt.Sprintf("must be greater than %s%s", fe.Param(), unit)
+	case "lt":
+		return fmt.Sprintf("must be less than %s%s", fe.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s", fe.Param(), unit)
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", fe.Param())
+	case "eq":
+		return fmt.Sprintf("must be %s", fe.Param())
+	case "ne":
+		return fmt.Sprintf("must not be %s", fe.Param())
+	}
+
+	// rules like url, uuid and those registered by entities, e.g. plugin_unique_identifier
+	return fmt.Sprintf("must be a valid %s", fe.Tag())
+}